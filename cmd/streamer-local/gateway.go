@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+// writeTimeout bounds how long a write may block on a slow client
+const writeTimeout = 10 * time.Second
+
+// EventHandler handles one API Gateway WebSocket event, like a Lambda would
+type EventHandler func(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Gateway stands in for API Gateway. It accepts WebSocket connections, turns
+// their lifecycle into $connect, $default and $disconnect events, and lets the
+// connection manager post to the live sockets through connection.APIGatewayClient.
+type Gateway struct {
+	connect    EventHandler
+	route      EventHandler
+	disconnect EventHandler
+	stage      string
+	upgrader   websocket.Upgrader
+	logger     *log.Logger
+
+	mu      sync.RWMutex
+	sockets map[string]*socket
+}
+
+// socket is one live client connection
+type socket struct {
+	conn        *websocket.Conn
+	writeMu     sync.Mutex
+	connectedAt time.Time
+	sourceIP    string
+	userAgent   string
+}
+
+// Ensure Gateway implements the APIGatewayClient interface
+var _ connection.APIGatewayClient = (*Gateway)(nil)
+
+// NewGateway creates a gateway that sends each event to the given handlers
+func NewGateway(connect, route, disconnect EventHandler, stage string, logger *log.Logger) *Gateway {
+	return &Gateway{
+		connect:    connect,
+		route:      route,
+		disconnect: disconnect,
+		stage:      stage,
+		upgrader: websocket.Upgrader{
+			// Browsers on any local origin may connect, as with API Gateway
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		logger:  logger,
+		sockets: make(map[string]*socket),
+	}
+}
+
+// ServeHTTP runs $connect for an upgrade request and serves the socket until
+// either side closes it
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+
+	connectionID := newConnectionID()
+	connectedAt := time.Now()
+
+	// The connect handler decides whether the upgrade goes ahead
+	resp, err := g.connect(r.Context(), g.newEvent(r, connectionID, connectedAt, "$connect", "CONNECT"))
+	if err != nil {
+		g.logger.Printf("$connect failed for connection %s: %v", connectionID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		for key, value := range resp.Headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(resp.StatusCode)
+		fmt.Fprint(w, resp.Body)
+		return
+	}
+
+	header := http.Header{}
+	if protocol := resp.Headers["Sec-WebSocket-Protocol"]; protocol != "" {
+		header.Set("Sec-WebSocket-Protocol", protocol)
+	}
+
+	conn, err := g.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		// The upgrade already wrote an error response; undo the connect
+		g.logger.Printf("Upgrade failed for connection %s: %v", connectionID, err)
+		g.handleDisconnect(r, connectionID, connectedAt, websocket.CloseAbnormalClosure, err.Error())
+		return
+	}
+
+	s := &socket{
+		conn:        conn,
+		connectedAt: connectedAt,
+		sourceIP:    sourceIP(r),
+		userAgent:   r.UserAgent(),
+	}
+	g.mu.Lock()
+	g.sockets[connectionID] = s
+	g.mu.Unlock()
+
+	g.logger.Printf("Connection %s opened", connectionID)
+	code, reason := g.serve(r, connectionID, s)
+
+	g.mu.Lock()
+	delete(g.sockets, connectionID)
+	g.mu.Unlock()
+	conn.Close()
+
+	g.handleDisconnect(r, connectionID, connectedAt, code, reason)
+	g.logger.Printf("Connection %s closed: %d %s", connectionID, code, reason)
+}
+
+// serve sends each message from the client to the router until the socket
+// closes, and returns the close code and reason
+func (g *Gateway) serve(r *http.Request, connectionID string, s *socket) (int, string) {
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				return closeErr.Code, closeErr.Text
+			}
+			return websocket.CloseAbnormalClosure, err.Error()
+		}
+
+		// Messages are handled in order, one at a time per connection
+		event := g.newEvent(r, connectionID, s.connectedAt, "$default", "MESSAGE")
+		if messageType == websocket.BinaryMessage {
+			event.Body = base64.StdEncoding.EncodeToString(data)
+			event.IsBase64Encoded = true
+		} else {
+			event.Body = string(data)
+		}
+
+		resp, err := g.route(context.Background(), event)
+		if err != nil {
+			g.logger.Printf("$default failed for connection %s: %v", connectionID, err)
+		} else if resp.StatusCode >= http.StatusBadRequest {
+			g.logger.Printf("$default returned %d for connection %s: %s", resp.StatusCode, connectionID, resp.Body)
+		}
+	}
+}
+
+// handleDisconnect runs $disconnect for a closed socket
+func (g *Gateway) handleDisconnect(r *http.Request, connectionID string, connectedAt time.Time, code int, reason string) {
+	event := g.newEvent(r, connectionID, connectedAt, "$disconnect", "DISCONNECT")
+	event.RequestContext.DisconnectStatusCode = int64(code)
+	event.RequestContext.DisconnectReason = &reason
+
+	if _, err := g.disconnect(context.Background(), event); err != nil {
+		g.logger.Printf("$disconnect failed for connection %s: %v", connectionID, err)
+	}
+}
+
+// newEvent builds the event API Gateway would send for a route
+func (g *Gateway) newEvent(r *http.Request, connectionID string, connectedAt time.Time, routeKey, eventType string) events.APIGatewayWebsocketProxyRequest {
+	now := time.Now()
+	event := events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			Stage:        g.stage,
+			RequestID:    uuid.NewString(),
+			ConnectedAt:  connectedAt.UnixMilli(),
+			ConnectionID: connectionID,
+			DomainName:   r.Host,
+			EventType:    eventType,
+			RouteKey:     routeKey,
+			// Epoch milliseconds, as API Gateway sends them
+			RequestTimeEpoch: now.UnixMilli(),
+			MessageDirection: "IN",
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sourceIP(r),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+
+	// Only $connect carries the handshake's headers and query string
+	if routeKey == "$connect" {
+		event.Headers = make(map[string]string, len(r.Header))
+		event.MultiValueHeaders = make(map[string][]string, len(r.Header))
+		for key, values := range r.Header {
+			event.Headers[key] = strings.Join(values, ",")
+			event.MultiValueHeaders[key] = values
+		}
+		// Go canonicalizes the header as Sec-Websocket-Protocol
+		if protocols := r.Header.Values("Sec-WebSocket-Protocol"); len(protocols) > 0 {
+			event.Headers["Sec-WebSocket-Protocol"] = strings.Join(protocols, ",")
+		}
+
+		query := r.URL.Query()
+		event.QueryStringParameters = make(map[string]string, len(query))
+		event.MultiValueQueryStringParameters = query
+		for key := range query {
+			event.QueryStringParameters[key] = query.Get(key)
+		}
+	}
+
+	return event
+}
+
+// PostToConnection sends data to a WebSocket connection
+func (g *Gateway) PostToConnection(ctx context.Context, connectionID string, data []byte) error {
+	s, err := g.socket(connectionID)
+	if err != nil {
+		return err
+	}
+
+	// Text frames for JSON, binary frames for MessagePack or compressed payloads
+	messageType := websocket.TextMessage
+	if !utf8.Valid(data) {
+		messageType = websocket.BinaryMessage
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := s.conn.WriteMessage(messageType, data); err != nil {
+		return connection.GoneError{
+			ConnectionID: connectionID,
+			Message:      fmt.Sprintf("connection %s is gone: %v", connectionID, err),
+		}
+	}
+	return nil
+}
+
+// DeleteConnection closes a WebSocket connection. The socket's read loop then
+// runs $disconnect, as API Gateway does.
+func (g *Gateway) DeleteConnection(ctx context.Context, connectionID string) error {
+	s, err := g.socket(connectionID)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeTimeout))
+	return s.conn.Close()
+}
+
+// GetConnection retrieves connection information
+func (g *Gateway) GetConnection(ctx context.Context, connectionID string) (*connection.ConnectionInfo, error) {
+	s, err := g.socket(connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connection.ConnectionInfo{
+		ConnectionID: connectionID,
+		ConnectedAt:  s.connectedAt.String(),
+		SourceIP:     s.sourceIP,
+		UserAgent:    s.userAgent,
+	}, nil
+}
+
+// Connections returns the number of open sockets
+func (g *Gateway) Connections() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.sockets)
+}
+
+// socket returns the live socket for a connection, or a GoneError
+func (g *Gateway) socket(connectionID string) (*socket, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	s, ok := g.sockets[connectionID]
+	if !ok {
+		return nil, connection.GoneError{
+			ConnectionID: connectionID,
+			Message:      fmt.Sprintf("connection %s is gone", connectionID),
+		}
+	}
+	return s, nil
+}
+
+// newConnectionID returns an ID shaped like the ones API Gateway assigns
+func newConnectionID() string {
+	id := uuid.New()
+	return base64.RawURLEncoding.EncodeToString(id[:12])
+}
+
+// sourceIP returns the client's address without the port
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+func TestGateway_RejectsPlainHTTP(t *testing.T) {
+	server := newTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGateway_APIGatewayClient(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	conn, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+	require.NoError(t, err)
+
+	conns, err := server.connections.ListByUser(ctx, "user-1", store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, conns.Items, 1)
+	connectionID := conns.Items[0].ConnectionID
+
+	t.Run("PostToConnection", func(t *testing.T) {
+		require.NoError(t, server.gateway.PostToConnection(ctx, connectionID, []byte(`{"type":"ping"}`)))
+		message := readMessage(t, conn)
+		assert.Equal(t, "ping", message["type"])
+
+		// Payloads that are not UTF-8 go out as binary frames
+		require.NoError(t, server.gateway.PostToConnection(ctx, connectionID, []byte{0x82, 0xa1}))
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		messageType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.BinaryMessage, messageType)
+		assert.Equal(t, []byte{0x82, 0xa1}, data)
+	})
+
+	t.Run("GetConnection", func(t *testing.T) {
+		info, err := server.gateway.GetConnection(ctx, connectionID)
+		require.NoError(t, err)
+		assert.Equal(t, connectionID, info.ConnectionID)
+		assert.Equal(t, "127.0.0.1", info.SourceIP)
+		assert.NotEmpty(t, info.ConnectedAt)
+	})
+
+	t.Run("DeleteConnection", func(t *testing.T) {
+		require.NoError(t, server.gateway.DeleteConnection(ctx, connectionID))
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "expected a normal close, got %v", err)
+
+		// Closing the socket runs $disconnect
+		assert.Eventually(t, func() bool {
+			conns, err := server.connections.ListByUser(ctx, "user-1", store.PageOptions{})
+			return err == nil && len(conns.Items) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("gone connection", func(t *testing.T) {
+		var gone connection.GoneError
+
+		err := server.gateway.PostToConnection(ctx, connectionID, []byte(`{}`))
+		assert.True(t, errors.As(err, &gone), "expected GoneError, got %v", err)
+
+		err = server.gateway.DeleteConnection(ctx, connectionID)
+		assert.True(t, errors.As(err, &gone), "expected GoneError, got %v", err)
+
+		_, err = server.gateway.GetConnection(ctx, connectionID)
+		assert.True(t, errors.As(err, &gone), "expected GoneError, got %v", err)
+	})
+}
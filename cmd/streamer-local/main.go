@@ -0,0 +1,114 @@
+// Command streamer-local runs the connect, router, disconnect and processor
+// handlers behind a local WebSocket server with in-memory stores, so clients
+// can be tried end-to-end without deploying to AWS.
+//
+// The JWT settings are read from the same environment variables as the
+// Lambdas (JWT_PUBLIC_KEY, JWT_ISSUER, JWKS_URL, JWKS_FILE, JWKS), or the
+// public key from a PEM file with -jwt-public-key-file. Clients pass their
+// token in the Authorization query parameter or header.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pay-theory/streamer/internal/store/redisstore"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+func main() {
+	defaults := DefaultConfig()
+	var (
+		addr           = flag.String("addr", "localhost:8080", "Address to listen on")
+		stage          = flag.String("stage", defaults.Stage, "Stage name reported to the handlers")
+		publicKeyFile  = flag.String("jwt-public-key-file", "", "PEM file with the RSA public key that verifies client tokens")
+		allowedTenants = flag.String("allowed-tenants", os.Getenv("ALLOWED_TENANTS"), "Comma-separated tenants allowed to connect (default all)")
+		asyncThreshold = flag.Duration("async-threshold", defaults.AsyncThreshold, "Estimated duration above which requests are processed asynchronously")
+		redisAddr      = flag.String("redis-addr", os.Getenv("REDIS_ADDR"), "Redis address to receive tenant and user broadcasts from (default in-process)")
+	)
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "[LOCAL] ", log.LstdFlags|log.Lshortfile)
+
+	// There is no X-Ray daemon to send the handlers' segments to
+	xray.Configure(xray.Config{ContextMissingStrategy: ctxmissing.NewDefaultIgnoreErrorStrategy()})
+
+	verifierConfig, err := shared.LoadVerifierConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load JWT verifier config: %v", err)
+	}
+	if *publicKeyFile != "" {
+		key, err := os.ReadFile(*publicKeyFile)
+		if err != nil {
+			logger.Fatalf("Failed to read JWT public key: %v", err)
+		}
+		verifierConfig.PublicKey = string(key)
+	}
+
+	if raw := os.Getenv("QUOTA_CONFIG"); raw != "" {
+		defaults.Quotas, err = streamer.ParseQuotaConfig([]byte(raw))
+		if err != nil {
+			logger.Fatalf("Failed to load quota config: %v", err)
+		}
+	}
+
+	cfg := defaults
+	cfg.Stage = *stage
+	cfg.Verifier = verifierConfig
+	cfg.AsyncThreshold = *asyncThreshold
+	for _, tenant := range strings.Split(*allowedTenants, ",") {
+		if tenant = strings.TrimSpace(tenant); tenant != "" {
+			cfg.AllowedTenants = append(cfg.AllowedTenants, tenant)
+		}
+	}
+
+	if *redisAddr != "" {
+		client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+		defer client.Close()
+		cfg.PubSub = redisstore.NewPubSub(client, "")
+	}
+
+	server, err := NewServer(cfg, logger)
+	if err != nil {
+		logger.Fatalf("Failed to create server: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	processed := make(chan struct{})
+	go func() {
+		server.Run(ctx)
+		close(processed)
+	}()
+
+	httpServer := &http.Server{
+		Addr:    *addr,
+		Handler: server.Handler(),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("Listening on ws://%s", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("Server failed: %v", err)
+	}
+	<-processed
+}
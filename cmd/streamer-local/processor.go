@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/processor/executor"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// streamQueue stands in for the DynamoDB stream on the requests table: every
+// request enqueued or resumed through it is published to Records once it is stored.
+type streamQueue struct {
+	store.RequestQueue
+	records chan string
+}
+
+// newStreamQueue wraps a request queue so its inserts can be consumed
+func newStreamQueue(queue store.RequestQueue, buffer int) *streamQueue {
+	return &streamQueue{
+		RequestQueue: queue,
+		records:      make(chan string, buffer),
+	}
+}
+
+// Enqueue stores the request and publishes its ID
+func (q *streamQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) error {
+	if err := q.RequestQueue.Enqueue(ctx, req); err != nil {
+		return err
+	}
+	return q.publish(ctx, req.RequestID)
+}
+
+// Resume clears the request's deferral and publishes its ID
+func (q *streamQueue) Resume(ctx context.Context, requestID string) error {
+	if err := q.RequestQueue.Resume(ctx, requestID); err != nil {
+		return err
+	}
+	return q.publish(ctx, requestID)
+}
+
+// publish hands a stored request ID to the consumer
+func (q *streamQueue) publish(ctx context.Context, requestID string) error {
+	select {
+	case q.records <- requestID:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Records returns the IDs of inserted and resumed requests, in write order
+func (q *streamQueue) Records() <-chan string {
+	return q.records
+}
+
+// deferredSweepInterval is how often deferred requests are resumed and due
+// callbacks sent, as the reaper does on its schedule
+const deferredSweepInterval = 5 * time.Second
+
+// callbackBatch bounds the callbacks sent on each sweep
+const callbackBatch = 25
+
+// Processor runs enqueued requests in-process, as the processor Lambda does
+// for each record on the requests stream
+type Processor struct {
+	queue     store.RequestQueue
+	exec      *executor.AsyncExecutor
+	callbacks *streamer.CallbackSender
+	timeout   time.Duration
+	sweep     time.Duration
+	logger    *log.Logger
+	wg        sync.WaitGroup
+}
+
+// NewProcessor creates a processor that hands requests to exec
+func NewProcessor(queue store.RequestQueue, exec *executor.AsyncExecutor, timeout time.Duration, logger *log.Logger) *Processor {
+	return &Processor{
+		queue:   queue,
+		exec:    exec,
+		timeout: timeout,
+		sweep:   deferredSweepInterval,
+		logger:  logger,
+	}
+}
+
+// SetCallbackSender sets what sends the callbacks of finished requests on each sweep
+func (p *Processor) SetCallbackSender(callbacks *streamer.CallbackSender) {
+	p.callbacks = callbacks
+}
+
+// Run processes each request ID from records concurrently until ctx is done,
+// then waits for the requests in flight. Deferred requests are resumed on
+// each sweep so they come back through records, and due callbacks are sent.
+func (p *Processor) Run(ctx context.Context, records <-chan string) {
+	defer p.wg.Wait()
+
+	sweep := time.NewTicker(p.sweep)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case now := <-sweep.C:
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				if _, err := streamer.ResumeDeferred(ctx, p.queue, now); err != nil {
+					p.logger.Printf("Failed to resume deferred requests: %v", err)
+				}
+				if p.callbacks == nil {
+					return
+				}
+				if _, err := p.callbacks.DeliverDue(ctx, callbackBatch); err != nil {
+					p.logger.Printf("Failed to deliver callbacks: %v", err)
+				}
+			}()
+		case requestID := <-records:
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				p.process(ctx, requestID)
+			}()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process runs one request with the executor's retry logic
+func (p *Processor) process(ctx context.Context, requestID string) {
+	asyncReq, err := p.queue.Get(ctx, requestID)
+	if err != nil {
+		p.logger.Printf("Failed to load request %s: %v", requestID, err)
+		return
+	}
+
+	// Skip if not in PENDING status
+	if asyncReq.Status != store.StatusPending {
+		p.logger.Printf("Skipping request %s with status %s", asyncReq.RequestID, asyncReq.Status)
+		return
+	}
+
+	// A deferred request waits for the sweep to resume it
+	if asyncReq.RetryAfter.After(time.Now()) {
+		p.logger.Printf("Skipping request %s deferred until %s", asyncReq.RequestID, asyncReq.RetryAfter.Format(time.RFC3339))
+		return
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// The executor marks the request as failed if every attempt fails
+	if err := p.exec.ProcessWithRetry(processCtx, asyncReq); err != nil {
+		p.logger.Printf("Failed to process request %s: %v", asyncReq.RequestID, err)
+	}
+}
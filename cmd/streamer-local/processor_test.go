@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/memory"
+	"github.com/pay-theory/streamer/lambda/processor/executor"
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+func newAsyncRequest(requestID, action string) *store.AsyncRequest {
+	return &store.AsyncRequest{
+		RequestID:    requestID,
+		ConnectionID: "conn-1",
+		Action:       action,
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Payload:      map[string]interface{}{},
+	}
+}
+
+func TestStreamQueue_Enqueue(t *testing.T) {
+	ctx := context.Background()
+	queue := newStreamQueue(memory.NewRequestQueue(), 10)
+
+	require.NoError(t, queue.Enqueue(ctx, newAsyncRequest("req-1", "quick")))
+	assert.Equal(t, "req-1", <-queue.Records())
+
+	// A failed insert publishes nothing
+	assert.True(t, store.IsAlreadyExists(queue.Enqueue(ctx, newAsyncRequest("req-1", "quick"))))
+	assert.Empty(t, queue.Records())
+
+	t.Run("full buffer", func(t *testing.T) {
+		full := newStreamQueue(memory.NewRequestQueue(), 0)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		assert.ErrorIs(t, full.Enqueue(cancelled, newAsyncRequest("req-2", "quick")), context.Canceled)
+	})
+}
+
+func TestProcessor_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := memory.NewRequestQueue()
+	queue := newStreamQueue(requests, 10)
+	logger := log.New(io.Discard, "", 0)
+
+	exec := executor.New(connection.NewMockConnectionManager(), requests, logger)
+	require.NoError(t, exec.RegisterHandler("quick", &quickAsyncHandler{}))
+
+	processor := NewProcessor(requests, exec, time.Minute, logger)
+	done := make(chan struct{})
+	go func() {
+		processor.Run(ctx, queue.Records())
+		close(done)
+	}()
+
+	// Requests that already left PENDING are skipped, like stream MODIFY records
+	require.NoError(t, requests.Enqueue(ctx, newAsyncRequest("req-cancelled", "quick")))
+	require.NoError(t, requests.UpdateStatus(ctx, "req-cancelled", store.StatusCancelled, "cancelled"))
+	queue.records <- "req-cancelled"
+	require.NoError(t, queue.Enqueue(ctx, newAsyncRequest("req-1", "quick")))
+
+	assert.Eventually(t, func() bool {
+		req, err := requests.Get(ctx, "req-1")
+		return err == nil && req.Status == store.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	req, err := requests.Get(context.Background(), "req-cancelled")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusCancelled, req.Status)
+}
+
+func TestProcessor_ResumesDeferred(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := memory.NewRequestQueue()
+	queue := newStreamQueue(requests, 10)
+	logger := log.New(io.Discard, "", 0)
+
+	exec := executor.New(connection.NewMockConnectionManager(), queue, logger)
+	require.NoError(t, exec.RegisterHandler("quick", &quickAsyncHandler{}))
+
+	processor := NewProcessor(queue, exec, time.Minute, logger)
+	processor.sweep = 10 * time.Millisecond
+
+	now := time.Now()
+	require.NoError(t, requests.Enqueue(ctx, newAsyncRequest("req-due", "quick")))
+	require.NoError(t, requests.Defer(ctx, "req-due", now.Add(-time.Second)))
+	require.NoError(t, requests.Enqueue(ctx, newAsyncRequest("req-later", "quick")))
+	require.NoError(t, requests.Defer(ctx, "req-later", now.Add(time.Hour)))
+
+	done := make(chan struct{})
+	go func() {
+		processor.Run(ctx, queue.Records())
+		close(done)
+	}()
+
+	// A record for a request deferred into the future is skipped
+	queue.records <- "req-later"
+
+	assert.Eventually(t, func() bool {
+		req, err := requests.Get(ctx, "req-due")
+		return err == nil && req.Status == store.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	req, err := requests.Get(context.Background(), "req-later")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusPending, req.Status)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+	"github.com/pay-theory/streamer/internal/store/memory"
+	connecthandler "github.com/pay-theory/streamer/lambda/connect/handler"
+	disconnecthandler "github.com/pay-theory/streamer/lambda/disconnect/handler"
+	"github.com/pay-theory/streamer/lambda/processor/executor"
+	processorhandlers "github.com/pay-theory/streamer/lambda/processor/handlers"
+	"github.com/pay-theory/streamer/lambda/router/handlers"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/connection"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// Config holds the settings for a local server
+type Config struct {
+	// Stage is reported to the handlers as the API Gateway stage
+	Stage string
+
+	// Verifier checks the JWT each client connects with
+	Verifier shared.VerifierConfig
+
+	// AllowedTenants restricts which tenants may connect; empty allows all
+	AllowedTenants []string
+
+	// AsyncThreshold is the estimated duration above which requests are queued
+	AsyncThreshold time.Duration
+
+	// ProcessTimeout bounds how long one queued request may run
+	ProcessTimeout time.Duration
+
+	// Quotas limits each tenant's queued and running requests
+	Quotas streamer.QuotaConfig
+
+	// PubSub carries tenant and user broadcasts to the server. Nil keeps
+	// them in process; use redisstore.PubSub to publish from elsewhere.
+	PubSub connection.PubSub
+}
+
+// DefaultConfig returns the settings the Lambdas use by default
+func DefaultConfig() Config {
+	return Config{
+		Stage:          "local",
+		AsyncThreshold: 5 * time.Second,
+		ProcessTimeout: 14 * time.Minute,
+	}
+}
+
+// Server wires the connect, router, disconnect and processor handlers to
+// in-memory stores behind a local gateway
+type Server struct {
+	gateway     *Gateway
+	router      *streamer.DefaultRouter
+	exec        *executor.AsyncExecutor
+	processor   *Processor
+	queue       *streamQueue
+	connections store.ConnectionStore
+	requests    store.RequestQueue
+	broadcaster *connection.TopicBroadcaster
+	logger      *log.Logger
+}
+
+// NewServer creates a local server
+func NewServer(cfg Config, logger *log.Logger) (*Server, error) {
+	if !cfg.Verifier.Configured() {
+		return nil, fmt.Errorf("a JWT public key or JWKS source is required")
+	}
+	verifier, err := shared.NewVerifier(cfg.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWT verifier: %w", err)
+	}
+
+	connStore := memory.NewConnectionStore()
+	requests := memory.NewRequestQueue()
+	if err := lifecycle.Observe(requests, lifecycle.NewAuditLog(logger)); err != nil {
+		return nil, fmt.Errorf("failed to attach request lifecycle hooks: %w", err)
+	}
+	queue := newStreamQueue(requests, 100)
+	metrics := discardMetrics{}
+
+	connectHandler := connecthandler.NewHandlerWithVerifier(connStore, &connecthandler.HandlerConfig{
+		JWTIssuer:      cfg.Verifier.Issuer,
+		AllowedTenants: cfg.AllowedTenants,
+	}, metrics, verifier)
+	disconnectHandler := disconnecthandler.NewHandler(connStore, nil, nil, &disconnecthandler.HandlerConfig{}, metrics)
+
+	// The router posts back through the gateway, so it is created second
+	var router *streamer.DefaultRouter
+	route := func(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if err := router.Route(ctx, event); err != nil {
+			logger.Printf("Error routing request: %v", err)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       "Internal Server Error",
+			}, nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	}
+	gateway := NewGateway(connectHandler.Handle, route, disconnectHandler.Handle, cfg.Stage, logger)
+
+	// The gateway posts to the sockets directly, so the manager needs no endpoint
+	connManager := connection.NewManager(connStore, gateway, "")
+	connManager.SetLogger(logger.Printf)
+
+	router = streamer.NewRouter(streamer.NewRequestQueueAdapter(queue), connManager)
+	router.SetAsyncThreshold(cfg.AsyncThreshold)
+	router.SetPrincipalResolver(streamer.NewConnectionPrincipalResolver(connStore))
+	router.SetHeartbeatRecorder(connStore)
+
+	// Callback secrets are sealed under a key that lasts as long as the
+	// in-memory requests do
+	key := make([]byte, streamer.SecretBoxKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate callback secret key: %w", err)
+	}
+	secretBox, err := streamer.NewSecretBox(key)
+	if err != nil {
+		return nil, err
+	}
+	router.SetSecretBox(secretBox)
+
+	router.SetMiddleware(
+		streamer.LoggingMiddleware(logger.Printf),
+		handlers.ValidationMiddleware(),
+		handlers.MetricsMiddleware(logger.Printf),
+	)
+
+	if err := handlers.RegisterHandlers(router); err != nil {
+		return nil, fmt.Errorf("failed to register handlers: %w", err)
+	}
+	if err := handlers.RegisterDeliveryHandlers(router, connManager); err != nil {
+		return nil, fmt.Errorf("failed to register delivery handlers: %w", err)
+	}
+	if err := handlers.RegisterRequestHandlers(router, requests); err != nil {
+		return nil, fmt.Errorf("failed to register request handlers: %w", err)
+	}
+	if err := handlers.RegisterAuthHandlers(router, verifier, connStore); err != nil {
+		return nil, fmt.Errorf("failed to register auth handlers: %w", err)
+	}
+
+	// Callbacks are scheduled as requests finish and sent by the processor's sweep
+	callbacks := streamer.NewCallbackSender(memory.NewCallbackStore(), requests, streamer.CallbackConfig{})
+	callbacks.SetLogger(logger.Printf)
+	callbacks.SetSecretBox(secretBox)
+	if err := lifecycle.Observe(requests, callbacks); err != nil {
+		return nil, fmt.Errorf("failed to attach callback hook: %w", err)
+	}
+
+	exec := executor.New(connManager, queue, logger)
+
+	// Capped requests are deferred and resumed by the processor's sweep
+	quotas := streamer.NewTenantQuotaEnforcer(nil, requests, cfg.Quotas)
+	quotas.SetConcurrencyStore(memory.NewConcurrencyStore())
+	router.SetQuotaChecker(quotas)
+	exec.SetConcurrencyLimiter(quotas)
+	if err := registerAsyncHandlers(router, exec); err != nil {
+		return nil, err
+	}
+
+	// This server is the long-running process that delivers topic broadcasts
+	pubsub := cfg.PubSub
+	if pubsub == nil {
+		pubsub = connection.NewMemoryPubSub()
+	}
+
+	processor := NewProcessor(queue, exec, cfg.ProcessTimeout, logger)
+	processor.SetCallbackSender(callbacks)
+
+	return &Server{
+		gateway:     gateway,
+		router:      router,
+		exec:        exec,
+		processor:   processor,
+		queue:       queue,
+		connections: connStore,
+		requests:    requests,
+		broadcaster: connection.NewTopicBroadcaster(connManager, pubsub),
+		logger:      logger,
+	}, nil
+}
+
+// registerAsyncHandlers registers the processor's handlers. Those the
+// production router has no entry for are added to the router too, so that
+// every action in the demo client can be queued.
+func registerAsyncHandlers(router *streamer.DefaultRouter, exec *executor.AsyncExecutor) error {
+	asyncHandlers := map[string]streamer.Handler{
+		"delay":           streamer.NewDelayHandler(30 * time.Second),
+		"generate_report": processorhandlers.NewReportAsyncHandler(),
+		"process_data":    processorhandlers.NewDataProcessorHandler(),
+		"echo_async":      processorhandlers.NewEchoAsyncHandler(),
+	}
+
+	for action, handler := range asyncHandlers {
+		if err := exec.RegisterHandler(action, handler); err != nil {
+			return fmt.Errorf("failed to register async handler %s: %w", action, err)
+		}
+	}
+
+	for _, action := range []string{"delay", "echo_async"} {
+		if err := router.Handle(action, asyncHandlers[action]); err != nil {
+			return fmt.Errorf("failed to register %s handler: %w", action, err)
+		}
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler that accepts WebSocket connections
+func (s *Server) Handler() http.Handler {
+	return s.gateway
+}
+
+// Broadcaster returns the broadcaster that publishes to tenant and user topics
+func (s *Server) Broadcaster() *connection.TopicBroadcaster {
+	return s.broadcaster
+}
+
+// Run delivers topic broadcasts and processes queued requests until ctx is done
+func (s *Server) Run(ctx context.Context) {
+	if err := s.broadcaster.Start(ctx); err != nil {
+		s.logger.Printf("Failed to subscribe to broadcast topics: %v", err)
+	}
+	s.processor.Run(ctx, s.queue.Records())
+}
+
+// discardMetrics drops the CloudWatch metrics the handlers publish
+type discardMetrics struct{}
+
+func (discardMetrics) PublishMetric(ctx context.Context, namespace, metricName string, value float64, unit types.StandardUnit, dimensions ...types.Dimension) error {
+	return nil
+}
+
+func (discardMetrics) PublishLatency(ctx context.Context, namespace, metricName string, duration time.Duration, dimensions ...types.Dimension) error {
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// testServer is a local server listening on a random port
+type testServer struct {
+	*Server
+	url string
+	key *rsa.PrivateKey
+}
+
+// newTestServer starts a local server that trusts a freshly generated key
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Verifier = shared.VerifierConfig{
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+	}
+
+	server, err := NewServer(cfg, log.New(io.Discard, "", 0))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.Run(ctx)
+		close(done)
+	}()
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		cancel()
+		<-done
+	})
+
+	return &testServer{
+		Server: server,
+		url:    "ws" + strings.TrimPrefix(httpServer.URL, "http"),
+		key:    key,
+	}
+}
+
+// token signs a token for the user and tenant
+func (s *testServer) token(t *testing.T, userID, tenantID string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":         userID,
+		"tenant_id":   tenantID,
+		"permissions": []string{"read", "write"},
+		"iat":         time.Now().Unix(),
+		"exp":         time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(s.key)
+	require.NoError(t, err)
+	return signed
+}
+
+// dial connects with the token in the Authorization query parameter
+func (s *testServer) dial(t *testing.T, token string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	conn, resp, err := websocket.DefaultDialer.Dial(s.url+"/?Authorization="+url.QueryEscape(token), nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readMessage reads the next JSON message from the socket
+func readMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var message map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&message))
+	return message
+}
+
+// quickAsyncHandler is queued by the router and finishes straight away
+type quickAsyncHandler struct{}
+
+func (h *quickAsyncHandler) EstimatedDuration() time.Duration {
+	return time.Minute
+}
+
+func (h *quickAsyncHandler) Validate(req *streamer.Request) error {
+	return nil
+}
+
+func (h *quickAsyncHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	return &streamer.Result{RequestID: req.ID, Success: true, Data: map[string]interface{}{"done": true}}, nil
+}
+
+func TestServer_Connect(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Run("missing token", func(t *testing.T) {
+		_, resp, err := server.dial(t, "")
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, resp, err := server.dial(t, "not-a-token")
+		require.Error(t, err)
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		_, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+		require.NoError(t, err)
+
+		conns, err := server.connections.ListByUser(context.Background(), "user-1", store.PageOptions{})
+		require.NoError(t, err)
+		require.Len(t, conns.Items, 1)
+		assert.Equal(t, "tenant-1", conns.Items[0].TenantID)
+		assert.Equal(t, 1, server.gateway.Connections())
+	})
+}
+
+func TestServer_SyncRequest(t *testing.T) {
+	server := newTestServer(t)
+	conn, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"action":  "echo",
+		"id":      "req-echo",
+		"payload": map[string]interface{}{"message": "hello"},
+	}))
+
+	response := readMessage(t, conn)
+	assert.Equal(t, "response", response["type"])
+	assert.Equal(t, "req-echo", response["request_id"])
+	assert.Equal(t, true, response["success"])
+
+	t.Run("unknown action", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{"action": "missing"}))
+		response := readMessage(t, conn)
+		assert.Equal(t, "error", response["type"])
+	})
+
+	t.Run("binary frame", func(t *testing.T) {
+		body, err := json.Marshal(map[string]interface{}{"action": "health", "id": "req-binary"})
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, body))
+
+		response := readMessage(t, conn)
+		assert.Equal(t, "response", response["type"])
+		assert.Equal(t, "req-binary", response["request_id"])
+	})
+}
+
+func TestServer_AsyncRequest(t *testing.T) {
+	server := newTestServer(t)
+	handler := &quickAsyncHandler{}
+	require.NoError(t, server.router.Handle("quick", handler))
+	require.NoError(t, server.exec.RegisterHandler("quick", handler))
+
+	conn, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"action":  "quick",
+		"id":      "req-quick",
+		"payload": map[string]interface{}{},
+	}))
+
+	ack := readMessage(t, conn)
+	assert.Equal(t, "acknowledgment", ack["type"])
+	assert.Equal(t, "queued", ack["status"])
+
+	// Progress updates are batched, so only the final message is certain
+	var complete map[string]interface{}
+	for complete == nil {
+		message := readMessage(t, conn)
+		switch message["type"] {
+		case "progress":
+		case "complete":
+			complete = message
+		default:
+			t.Fatalf("unexpected message: %v", message)
+		}
+	}
+	assert.Equal(t, "req-quick", complete["request_id"])
+
+	request, err := server.requests.Get(context.Background(), "req-quick")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusCompleted, request.Status)
+	assert.Equal(t, "user-1", request.UserID)
+}
+
+func TestServer_Disconnect(t *testing.T) {
+	server := newTestServer(t)
+	conn, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")))
+
+	// $disconnect removes the connection record
+	assert.Eventually(t, func() bool {
+		conns, err := server.connections.ListByUser(context.Background(), "user-1", store.PageOptions{})
+		return err == nil && len(conns.Items) == 0 && server.gateway.Connections() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServer_TopicBroadcast(t *testing.T) {
+	server := newTestServer(t)
+	first, _, err := server.dial(t, server.token(t, "user-1", "tenant-1"))
+	require.NoError(t, err)
+	second, _, err := server.dial(t, server.token(t, "user-2", "tenant-1"))
+	require.NoError(t, err)
+	other, _, err := server.dial(t, server.token(t, "user-3", "tenant-2"))
+	require.NoError(t, err)
+
+	// Publish until the broadcaster started by Run has subscribed
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			server.Broadcaster().PublishToTenant(context.Background(), "tenant-1", map[string]interface{}{"type": "notice"})
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	for _, conn := range []*websocket.Conn{first, second} {
+		assert.Equal(t, "notice", readMessage(t, conn)["type"])
+	}
+
+	// Other tenants' connections get nothing
+	require.NoError(t, other.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err = other.ReadMessage()
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+// Command streamer-migrate-requests copies async requests from the legacy
+// requests table (streamer_requests), where they were keyed with their status
+// in the sort key (STATUS#<status>), to streamer_requests_v2, keyed by request
+// ID alone so status updates are made in place.
+//
+// The legacy table is only read. Cut over in this order:
+//
+//  1. Deploy the infrastructure, which adds the new table and keeps the
+//     legacy one.
+//  2. Let pending requests drain, then deploy the release that uses the new
+//     table. It copies a request it cannot find there out of the legacy
+//     table on the spot, so requests are found before this has run.
+//  3. Run this command. It scans the legacy table a page at a time and logs
+//     a resume token after each page; if it fails or is stopped, run it again
+//     with -resume and the last token it logged.
+//  4. Repeat with -dry-run until it finds no requests left to migrate, then
+//     remove the legacy table.
+//
+// Copied requests are marked with migrated_at. Writing them emits an INSERT
+// on the new table's stream, which the processor skips so that pending
+// requests are not started a second time.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pay-theory/dynamorm/pkg/session"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+)
+
+func main() {
+	var (
+		region   = flag.String("region", os.Getenv("AWS_REGION"), "AWS region of the requests tables")
+		endpoint = flag.String("endpoint", "", "DynamoDB endpoint, for example DynamoDB Local")
+		dryRun   = flag.Bool("dry-run", false, "Count the requests left to migrate without writing anything")
+		resume   = flag.String("resume", "", "Resume token logged by an earlier run")
+		pageSize = flag.Int("page-size", store.DefaultPageSize, "Legacy items scanned per page")
+	)
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "[MIGRATE] ", log.LstdFlags)
+
+	if *region == "" {
+		logger.Fatal("A region is required; set -region or AWS_REGION")
+	}
+
+	factory, err := dynamorm.NewStoreFactory(session.Config{
+		Region:   *region,
+		Endpoint: *endpoint,
+	})
+	if err != nil {
+		logger.Fatalf("Failed to connect to DynamoDB: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := dynamorm.MigrateRequests(ctx, factory.DB(), dynamorm.RequestMigrationOptions{
+		DryRun:    *dryRun,
+		NextToken: *resume,
+		PageSize:  *pageSize,
+		OnPage: func(result *dynamorm.RequestMigrationResult) {
+			if result.NextToken != "" {
+				logger.Printf("Scanned %d legacy items; resume token: %s", result.LegacyItems, result.NextToken)
+			}
+		},
+	})
+	if result != nil {
+		logger.Printf("Scanned %d legacy items; migrated %d requests, %d already migrated",
+			result.LegacyItems, result.Migrated, result.AlreadyMigrated)
+		if *dryRun {
+			logger.Printf("Dry run; %d requests left to migrate, nothing was written", result.Remaining)
+		}
+	}
+	if err != nil {
+		if result != nil && result.NextToken != "" {
+			logger.Fatalf("Migration failed: %v; rerun with -resume %s", err, result.NextToken)
+		}
+		logger.Fatalf("Migration failed: %v; rerun to start again", err)
+	}
+}
@@ -76,7 +76,7 @@ func createIAMRoles(ctx *pulumi.Context, environment string, tables map[string]*
 	}
 
 	// Connect Lambda specific role and policies
-	connectRole, err := createConnectLambdaRole(ctx, environment, baseLambdaRole, tables["connections"], jwtSecretArn)
+	connectRole, err := createConnectLambdaRole(ctx, environment, baseLambdaRole, tables["connections"], tables["deliveries"], jwtSecretArn)
 	if err != nil {
 		return nil, err
 	}
@@ -103,10 +103,17 @@ func createIAMRoles(ctx *pulumi.Context, environment string, tables map[string]*
 	}
 	roles["processor"] = processorRole
 
+	// Reaper Lambda specific role and policies
+	reaperRole, err := createReaperLambdaRole(ctx, environment, baseLambdaRole, tables, region)
+	if err != nil {
+		return nil, err
+	}
+	roles["reaper"] = reaperRole
+
 	return roles, nil
 }
 
-func createConnectLambdaRole(ctx *pulumi.Context, environment string, baseRole *iam.Role, connectionsTable *dynamodb.Table, jwtSecretArn pulumi.StringOutput) (*iam.Role, error) {
+func createConnectLambdaRole(ctx *pulumi.Context, environment string, baseRole *iam.Role, connectionsTable, deliveriesTable *dynamodb.Table, jwtSecretArn pulumi.StringOutput) (*iam.Role, error) {
 	role, err := iam.NewRole(ctx, "connect-lambda-role", &iam.RoleArgs{
 		Name:             pulumi.Sprintf("streamer-%s-connect", environment),
 		AssumeRolePolicy: baseRole.AssumeRolePolicy,
@@ -120,9 +127,11 @@ func createConnectLambdaRole(ctx *pulumi.Context, environment string, baseRole *
 		return nil, err
 	}
 
-	// DynamoDB policy for connections table
-	dynamoPolicy := pulumi.All(connectionsTable.Arn).ApplyT(func(args []interface{}) (string, error) {
+	// DynamoDB policy for the connections table, and the deliveries table
+	// whose unacked messages are replayed to reconnecting clients
+	dynamoPolicy := pulumi.All(connectionsTable.Arn, deliveriesTable.Arn).ApplyT(func(args []interface{}) (string, error) {
 		tableArn := args[0].(string)
+		deliveriesArn := args[1].(string)
 		policy := map[string]interface{}{
 			"Version": "2012-10-17",
 			"Statement": []interface{}{
@@ -134,6 +143,14 @@ func createConnectLambdaRole(ctx *pulumi.Context, environment string, baseRole *
 					},
 					"Resource": tableArn,
 				},
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:Query",
+						"dynamodb:PutItem",
+					},
+					"Resource": deliveriesArn,
+				},
 			},
 		}
 		policyJSON, err := json.Marshal(policy)
@@ -257,9 +274,17 @@ func createRouterLambdaRole(ctx *pulumi.Context, environment string, baseRole *i
 		tables["connections"].Arn,
 		tables["subscriptions"].Arn,
 		tables["requests"].Arn,
+		tables["rate_limits"].Arn,
+		tables["tenant_quotas"].Arn,
+		tables["deliveries"].Arn,
+		// Read last, for requests not yet migrated out of it
+		tables["legacy_requests"].Arn,
 	}
 
 	dynamoPolicy := pulumi.All(tableArns...).ApplyT(func(args []interface{}) (string, error) {
+		legacyRequestsArn := args[len(args)-1].(string)
+		args = args[:len(args)-1]
+
 		resources := make([]string, len(args))
 		for i, arn := range args {
 			resources[i] = arn.(string)
@@ -283,6 +308,14 @@ func createRouterLambdaRole(ctx *pulumi.Context, environment string, baseRole *i
 					},
 					"Resource": resources,
 				},
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:GetItem",
+						"dynamodb:Query",
+					},
+					"Resource": []string{legacyRequestsArn},
+				},
 			},
 		}
 		policyJSON, err := json.Marshal(policy)
@@ -362,6 +395,118 @@ func createProcessorLambdaRole(ctx *pulumi.Context, environment string, baseRole
 	return role, nil
 }
 
+func createReaperLambdaRole(ctx *pulumi.Context, environment string, baseRole *iam.Role, tables map[string]*dynamodb.Table, region string) (*iam.Role, error) {
+	role, err := iam.NewRole(ctx, "reaper-lambda-role", &iam.RoleArgs{
+		Name:             pulumi.Sprintf("streamer-%s-reaper", environment),
+		AssumeRolePolicy: baseRole.AssumeRolePolicy,
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+			"Function":    pulumi.String("reaper"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The reaper scans for idle connections and deletes them, resumes
+	// requests deferred by a tenant's concurrency cap, redelivers unacked
+	// messages to the user's connections and retries callbacks, which are
+	// scheduled in the deliveries table and logged on their request. Requests
+	// not yet migrated are read from the legacy requests table.
+	dynamoPolicy := pulumi.All(tables["connections"].Arn, tables["requests"].Arn, tables["deliveries"].Arn, tables["legacy_requests"].Arn).ApplyT(func(args []interface{}) (string, error) {
+		connectionsArn := args[0].(string)
+		requestsArn := args[1].(string)
+		deliveriesArn := args[2].(string)
+		legacyRequestsArn := args[3].(string)
+		policy := map[string]interface{}{
+			"Version": "2012-10-17",
+			"Statement": []interface{}{
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:Scan",
+						"dynamodb:Query",
+						"dynamodb:GetItem",
+						"dynamodb:DeleteItem",
+						"dynamodb:BatchWriteItem",
+					},
+					"Resource": []string{
+						connectionsArn,
+						connectionsArn + "/index/*",
+					},
+				},
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:Query",
+						"dynamodb:PutItem",
+						"dynamodb:DeleteItem",
+					},
+					"Resource": []string{
+						deliveriesArn,
+						deliveriesArn + "/index/*",
+					},
+				},
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:Query",
+						"dynamodb:GetItem",
+						"dynamodb:PutItem",
+						"dynamodb:UpdateItem",
+					},
+					"Resource": []string{
+						requestsArn,
+						requestsArn + "/index/*",
+					},
+				},
+				map[string]interface{}{
+					"Effect": "Allow",
+					"Action": []string{
+						"dynamodb:Query",
+						"dynamodb:GetItem",
+					},
+					"Resource": []string{legacyRequestsArn},
+				},
+			},
+		}
+		policyJSON, err := json.Marshal(policy)
+		return string(policyJSON), err
+	}).(pulumi.StringOutput)
+
+	_, err = iam.NewRolePolicy(ctx, "reaper-dynamo-policy", &iam.RolePolicyArgs{
+		Role:   role.Name,
+		Policy: dynamoPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// API Gateway Management API policy for close notices and disconnects
+	apiGatewayPolicy := pulumi.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Action": [
+				"execute-api:ManageConnections"
+			],
+			"Resource": "arn:aws:execute-api:%s:*:*/@connections/*"
+		}]
+	}`, region)
+
+	_, err = iam.NewRolePolicy(ctx, "reaper-api-gateway-policy", &iam.RolePolicyArgs{
+		Role:   role.Name,
+		Policy: apiGatewayPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachBasicPolicies(ctx, "reaper", role)
+	return role, nil
+}
+
 func attachBasicPolicies(ctx *pulumi.Context, functionName string, role *iam.Role) error {
 	// Basic Lambda execution
 	_, err := iam.NewRolePolicyAttachment(ctx, fmt.Sprintf("%s-basic-execution", functionName), &iam.RolePolicyAttachmentArgs{
@@ -32,6 +32,11 @@ func createLambdaFunctions(ctx *pulumi.Context, environment string, roles map[st
 		reservedConcurrency = 100
 	}
 
+	// Key the router seals callback secrets with and the processor and
+	// reaper open them with: 32 random bytes, base64 encoded, set with
+	// `pulumi config set --secret callbackSecretKey`
+	callbackSecretKey := cfg.RequireSecret("callbackSecretKey")
+
 	// Connect Lambda
 	connectFunc, err := lambda.NewFunction(ctx, "connect", &lambda.FunctionArgs{
 		Name:        pulumi.Sprintf("streamer-connect-%s", environment),
@@ -155,12 +160,14 @@ func createLambdaFunctions(ctx *pulumi.Context, environment string, roles map[st
 				tables["connections"].Name,
 				tables["subscriptions"].Name,
 				tables["requests"].Name,
+				callbackSecretKey,
 			).ApplyT(
 				func(args []interface{}) pulumi.StringMap {
 					return pulumi.StringMap{
 						"CONNECTIONS_TABLE":   pulumi.String(args[0].(string)),
 						"SUBSCRIPTIONS_TABLE": pulumi.String(args[1].(string)),
 						"REQUESTS_TABLE":      pulumi.String(args[2].(string)),
+						"CALLBACK_SECRET_KEY": pulumi.String(args[3].(string)),
 						"ENVIRONMENT":         pulumi.String(environment),
 						"LOG_LEVEL":           pulumi.String(getLogLevel(environment)),
 						"METRICS_NAMESPACE":   pulumi.String("Streamer"),
@@ -203,12 +210,14 @@ func createLambdaFunctions(ctx *pulumi.Context, environment string, roles map[st
 				tables["connections"].Name,
 				tables["subscriptions"].Name,
 				tables["requests"].Name,
+				callbackSecretKey,
 			).ApplyT(
 				func(args []interface{}) pulumi.StringMap {
 					return pulumi.StringMap{
 						"CONNECTIONS_TABLE":   pulumi.String(args[0].(string)),
 						"SUBSCRIPTIONS_TABLE": pulumi.String(args[1].(string)),
 						"REQUESTS_TABLE":      pulumi.String(args[2].(string)),
+						"CALLBACK_SECRET_KEY": pulumi.String(args[3].(string)),
 						"ENVIRONMENT":         pulumi.String(environment),
 						"LOG_LEVEL":           pulumi.String(getLogLevel(environment)),
 						"METRICS_NAMESPACE":   pulumi.String("Streamer"),
@@ -234,9 +243,84 @@ func createLambdaFunctions(ctx *pulumi.Context, environment string, roles map[st
 	}
 	functions["processor"] = processorFunc
 
+	// Reaper Lambda (closes idle connections on a schedule)
+	reaperFunc, err := lambda.NewFunction(ctx, "reaper", &lambda.FunctionArgs{
+		Name:        pulumi.Sprintf("streamer-reaper-%s", environment),
+		Description: pulumi.String("Idle connection reaper"),
+		Runtime:     pulumi.String("go1.x"),
+		Handler:     pulumi.String("main"),
+		Role:        roles["reaper"].Arn,
+		MemorySize:  pulumi.Int(memorySize),
+		Timeout:     pulumi.Int(120),
+
+		Environment: &lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.All(tables["connections"].Name, callbackSecretKey).ApplyT(
+				func(args []interface{}) pulumi.StringMap {
+					return pulumi.StringMap{
+						"CONNECTIONS_TABLE":   pulumi.String(args[0].(string)),
+						"CALLBACK_SECRET_KEY": pulumi.String(args[1].(string)),
+						"IDLE_TIMEOUT":        pulumi.String(cfg.Get("idleTimeout")),
+						"ENVIRONMENT":         pulumi.String(environment),
+						"LOG_LEVEL":           pulumi.String(getLogLevel(environment)),
+						"METRICS_NAMESPACE":   pulumi.String("Streamer"),
+					}
+				},
+			).(pulumi.StringMapOutput),
+		},
+
+		TracingConfig: &lambda.FunctionTracingConfigArgs{
+			Mode: pulumi.String("Active"),
+		},
+
+		ReservedConcurrentExecutions: pulumi.Int(1), // One sweep at a time
+
+		Code: pulumi.NewFileArchive("../../lambda/reaper/deployment.zip"),
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+			"Function":    pulumi.String("reaper"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	functions["reaper"] = reaperFunc
+
+	if err := createReaperSchedule(ctx, environment, reaperFunc); err != nil {
+		return nil, err
+	}
+
 	return functions, nil
 }
 
+func createReaperSchedule(ctx *pulumi.Context, environment string, function *lambda.Function) error {
+	rule, err := cloudwatch.NewEventRule(ctx, "reaper-schedule", &cloudwatch.EventRuleArgs{
+		Name:               pulumi.Sprintf("streamer-reaper-%s", environment),
+		Description:        pulumi.String("Close idle WebSocket connections and resume deferred requests"),
+		ScheduleExpression: pulumi.String("rate(1 minute)"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewEventTarget(ctx, "reaper-schedule-target", &cloudwatch.EventTargetArgs{
+		Rule: rule.Name,
+		Arn:  function.Arn,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = lambda.NewPermission(ctx, "reaper-schedule-permission", &lambda.PermissionArgs{
+		Action:    pulumi.String("lambda:InvokeFunction"),
+		Function:  function.Name,
+		Principal: pulumi.String("events.amazonaws.com"),
+		SourceArn: rule.Arn,
+	})
+	return err
+}
+
 func getLogLevel(environment string) string {
 	switch environment {
 	case "dev":
@@ -103,9 +103,14 @@ func main() {
 		ctx.Export("disconnectFunctionArn", lambdaFunctions["disconnect"].Arn)
 		ctx.Export("routerFunctionArn", lambdaFunctions["router"].Arn)
 		ctx.Export("processorFunctionArn", lambdaFunctions["processor"].Arn)
+		ctx.Export("reaperFunctionArn", lambdaFunctions["reaper"].Arn)
 		ctx.Export("connectionsTableName", tables["connections"].Name)
 		ctx.Export("subscriptionsTableName", tables["subscriptions"].Name)
 		ctx.Export("requestsTableName", tables["requests"].Name)
+		ctx.Export("legacyRequestsTableName", tables["legacy_requests"].Name)
+		ctx.Export("rateLimitsTableName", tables["rate_limits"].Name)
+		ctx.Export("tenantQuotasTableName", tables["tenant_quotas"].Name)
+		ctx.Export("deliveriesTableName", tables["deliveries"].Name)
 
 		return nil
 	})
@@ -222,8 +227,10 @@ func createDynamoDBTables(ctx *pulumi.Context, environment string, kmsKeyArn pul
 	}
 	tables["subscriptions"] = subscriptionsTable
 
-	// Requests table
-	requestsTable, err := dynamodb.NewTable(ctx, "requests", &dynamodb.TableArgs{
+	// Legacy requests table. Its schema is left as it was so that updating
+	// the stack does not replace it; requests are migrated out of it by
+	// cmd/streamer-migrate-requests and it is kept, read only, until then.
+	legacyRequestsTable, err := dynamodb.NewTable(ctx, "requests", &dynamodb.TableArgs{
 		Name:        pulumi.Sprintf("streamer-%s-requests", environment),
 		BillingMode: pulumi.String("PAY_PER_REQUEST"),
 		HashKey:     pulumi.String("requestId"),
@@ -237,6 +244,14 @@ func createDynamoDBTables(ctx *pulumi.Context, environment string, kmsKeyArn pul
 				Name: pulumi.String("connectionId"),
 				Type: pulumi.String("S"),
 			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("tenant_id"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("created_at"),
+				Type: pulumi.String("S"),
+			},
 		},
 
 		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
@@ -245,6 +260,102 @@ func createDynamoDBTables(ctx *pulumi.Context, environment string, kmsKeyArn pul
 				HashKey:        pulumi.String("connectionId"),
 				ProjectionType: pulumi.String("ALL"),
 			},
+			// Quota checks count a tenant's requests by status
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("tenant-index"),
+				HashKey:        pulumi.String("tenant_id"),
+				RangeKey:       pulumi.String("created_at"),
+				ProjectionType: pulumi.String("ALL"),
+			},
+		},
+
+		Ttl: &dynamodb.TableTtlArgs{
+			AttributeName: pulumi.String("ttl"),
+			Enabled:       pulumi.Bool(true),
+		},
+
+		ServerSideEncryption: &dynamodb.TableServerSideEncryptionArgs{
+			Enabled:   pulumi.Bool(true),
+			KmsKeyArn: kmsKeyArn,
+		},
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+		},
+	}, pulumi.Protect(true), pulumi.RetainOnDelete(true))
+	if err != nil {
+		return nil, err
+	}
+	tables["legacy_requests"] = legacyRequestsTable
+
+	// Requests table, keyed REQ#<id>/METADATA with the status an indexed
+	// attribute so status changes are made in place. It is a new table
+	// rather than a change to the legacy one, which would replace it and
+	// lose its requests.
+	requestsTable, err := dynamodb.NewTable(ctx, "requests-v2", &dynamodb.TableArgs{
+		Name:        pulumi.Sprintf("streamer-%s-requests-v2", environment),
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("pk"),
+		RangeKey:    pulumi.String("sk"),
+
+		Attributes: dynamodb.TableAttributeArray{
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("pk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("sk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("connection_id"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("status"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("user_id"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("tenant_id"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("created_at"),
+				Type: pulumi.String("S"),
+			},
+		},
+
+		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("connection-index"),
+				HashKey:        pulumi.String("connection_id"),
+				ProjectionType: pulumi.String("ALL"),
+			},
+			// Dequeue and the reaper's deferred requests read by status
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("status-index"),
+				HashKey:        pulumi.String("status"),
+				ProjectionType: pulumi.String("ALL"),
+			},
+			// list_requests pages a user's requests newest first
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("user-index"),
+				HashKey:        pulumi.String("user_id"),
+				RangeKey:       pulumi.String("created_at"),
+				ProjectionType: pulumi.String("ALL"),
+			},
+			// Quota checks count a tenant's requests by status
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("tenant-index"),
+				HashKey:        pulumi.String("tenant_id"),
+				RangeKey:       pulumi.String("created_at"),
+				ProjectionType: pulumi.String("ALL"),
+			},
 		},
 
 		Ttl: &dynamodb.TableTtlArgs{
@@ -267,6 +378,136 @@ func createDynamoDBTables(ctx *pulumi.Context, environment string, kmsKeyArn pul
 	}
 	tables["requests"] = requestsTable
 
+	// Rate limits table
+	rateLimitsTable, err := dynamodb.NewTable(ctx, "rate-limits", &dynamodb.TableArgs{
+		Name:        pulumi.Sprintf("streamer-%s-rate-limits", environment),
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("pk"),
+		RangeKey:    pulumi.String("sk"),
+
+		Attributes: dynamodb.TableAttributeArray{
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("pk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("sk"),
+				Type: pulumi.String("S"),
+			},
+		},
+
+		Ttl: &dynamodb.TableTtlArgs{
+			AttributeName: pulumi.String("ttl"),
+			Enabled:       pulumi.Bool(true),
+		},
+
+		ServerSideEncryption: &dynamodb.TableServerSideEncryptionArgs{
+			Enabled:   pulumi.Bool(true),
+			KmsKeyArn: kmsKeyArn,
+		},
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tables["rate_limits"] = rateLimitsTable
+
+	// Tenant quotas table
+	tenantQuotasTable, err := dynamodb.NewTable(ctx, "tenant-quotas", &dynamodb.TableArgs{
+		Name:        pulumi.Sprintf("streamer-%s-tenant-quotas", environment),
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("pk"),
+		RangeKey:    pulumi.String("sk"),
+
+		Attributes: dynamodb.TableAttributeArray{
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("pk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("sk"),
+				Type: pulumi.String("S"),
+			},
+		},
+
+		ServerSideEncryption: &dynamodb.TableServerSideEncryptionArgs{
+			Enabled:   pulumi.Bool(true),
+			KmsKeyArn: kmsKeyArn,
+		},
+
+		PointInTimeRecovery: &dynamodb.TablePointInTimeRecoveryArgs{
+			Enabled: pulumi.Bool(true),
+		},
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tables["tenant_quotas"] = tenantQuotasTable
+
+	// Pending deliveries table
+	deliveriesTable, err := dynamodb.NewTable(ctx, "deliveries", &dynamodb.TableArgs{
+		Name:        pulumi.Sprintf("streamer-%s-deliveries", environment),
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("pk"),
+		RangeKey:    pulumi.String("sk"),
+
+		Attributes: dynamodb.TableAttributeArray{
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("pk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("sk"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("due_shard"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("next_attempt_at"),
+				Type: pulumi.String("S"),
+			},
+		},
+
+		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("due-index"),
+				HashKey:        pulumi.String("due_shard"),
+				RangeKey:       pulumi.String("next_attempt_at"),
+				ProjectionType: pulumi.String("ALL"),
+			},
+		},
+
+		Ttl: &dynamodb.TableTtlArgs{
+			AttributeName: pulumi.String("ttl"),
+			Enabled:       pulumi.Bool(true),
+		},
+
+		ServerSideEncryption: &dynamodb.TableServerSideEncryptionArgs{
+			Enabled:   pulumi.Bool(true),
+			KmsKeyArn: kmsKeyArn,
+		},
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String(environment),
+			"Service":     pulumi.String("streamer"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tables["deliveries"] = deliveriesTable
+
 	return tables, nil
 }
 
@@ -292,7 +533,7 @@ func createLogGroups(ctx *pulumi.Context, environment string, kmsKeyArn pulumi.S
 	logGroups["api-gateway"] = apiGatewayLogs
 
 	// Lambda function logs
-	lambdaNames := []string{"connect", "disconnect", "router", "processor"}
+	lambdaNames := []string{"connect", "disconnect", "router", "processor", "reaper"}
 	for _, name := range lambdaNames {
 		logGroup, err := cloudwatch.NewLogGroup(ctx, fmt.Sprintf("%s-logs", name), &cloudwatch.LogGroupArgs{
 			Name:            pulumi.Sprintf("/aws/lambda/streamer-%s-%s", name, environment),
@@ -0,0 +1,86 @@
+package dynamorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// callbackStore implements CallbackStore using DynamORM. Pending callbacks
+// are kept in the deliveries table.
+type callbackStore struct {
+	db core.DB
+}
+
+// NewCallbackStore creates a new DynamORM-backed pending callback store
+func NewCallbackStore(db core.DB) store.CallbackStore {
+	return &callbackStore{
+		db: db,
+	}
+}
+
+// Save creates or replaces the pending callback for a request
+func (s *callbackStore) Save(ctx context.Context, callback *store.PendingCallback) error {
+	if callback == nil {
+		return store.NewValidationError("callback", "cannot be nil")
+	}
+	if callback.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+
+	if callback.CreatedAt.IsZero() {
+		callback.CreatedAt = time.Now()
+	}
+
+	dynamormCallback := &PendingCallback{}
+	dynamormCallback.FromStoreModel(callback)
+
+	if err := s.db.Model(dynamormCallback).CreateOrUpdate(); err != nil {
+		return store.NewStoreError("Save", store.DeliveriesTable, callback.RequestID, fmt.Errorf("failed to save callback: %w", err))
+	}
+
+	return nil
+}
+
+// Delete removes a request's pending callback
+func (s *callbackStore) Delete(ctx context.Context, requestID string) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	callback := &PendingCallback{RequestID: requestID}
+	callback.SetKeys()
+
+	if err := s.db.Model(callback).Delete(); err != nil {
+		return store.NewStoreError("Delete", store.DeliveriesTable, requestID, fmt.Errorf("failed to delete callback: %w", err))
+	}
+
+	return nil
+}
+
+// ListDue returns pending callbacks whose next attempt is due
+func (s *callbackStore) ListDue(ctx context.Context, before time.Time, limit int) ([]*store.PendingCallback, error) {
+	var callbacks []PendingCallback
+
+	query := s.db.Model(&PendingCallback{}).
+		Index("due-index").
+		Where("due_shard", "=", callbackDueShard).
+		Where("next_attempt_at", "<=", before)
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.All(&callbacks); err != nil {
+		return nil, store.NewStoreError("ListDue", store.DeliveriesTable, "", fmt.Errorf("failed to list due callbacks: %w", err))
+	}
+
+	result := make([]*store.PendingCallback, len(callbacks))
+	for i := range callbacks {
+		result[i] = callbacks[i].ToStoreModel()
+	}
+	return result, nil
+}
@@ -0,0 +1,170 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestCallbackStore_Save tests the Save method
+func TestCallbackStore_Save(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		callback  *store.PendingCallback
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantErr   bool
+		errMsg    string
+	}{
+		{
+			name:     "successful save",
+			callback: &store.PendingCallback{RequestID: "req-1"},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.MatchedBy(func(c *dynamorm.PendingCallback) bool {
+					return c.PK == "CALLBACK#req-1" && c.SK == "CALLBACK" && c.DueShard == "CALLBACK"
+				})).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(nil)
+			},
+		},
+		{
+			name:     "dynamodb error",
+			callback: &store.PendingCallback{RequestID: "req-1"},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingCallback")).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+			errMsg:  "failed to save callback",
+		},
+		{
+			name:     "nil callback",
+			callback: nil,
+			wantErr:  true,
+			errMsg:   "cannot be nil",
+		},
+		{
+			name:     "missing request ID",
+			callback: &store.PendingCallback{},
+			wantErr:  true,
+			errMsg:   "RequestID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery)
+			}
+
+			err := dynamorm.NewCallbackStore(mockDB).Save(ctx, tt.callback)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, tt.callback.CreatedAt.IsZero())
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
+
+// TestCallbackStore_Delete tests the Delete method
+func TestCallbackStore_Delete(t *testing.T) {
+	ctx := context.Background()
+	mockDB := new(mocks.MockDB)
+	mockQuery := new(mocks.MockQuery)
+
+	mockDB.On("Model", mock.MatchedBy(func(c *dynamorm.PendingCallback) bool {
+		return c.PK == "CALLBACK#req-1" && c.SK == "CALLBACK"
+	})).Return(mockQuery)
+	mockQuery.On("Delete").Return(nil)
+
+	callbackStore := dynamorm.NewCallbackStore(mockDB)
+	assert.NoError(t, callbackStore.Delete(ctx, "req-1"))
+	assert.Error(t, callbackStore.Delete(ctx, ""))
+
+	mockDB.AssertExpectations(t)
+	mockQuery.AssertExpectations(t)
+}
+
+// TestCallbackStore_ListDue tests the ListDue method
+func TestCallbackStore_ListDue(t *testing.T) {
+	ctx := context.Background()
+	before := time.Now()
+
+	tests := []struct {
+		name      string
+		limit     int
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantIDs   []string
+		wantErr   bool
+	}{
+		{
+			name:  "due callbacks are read from their due index partition",
+			limit: 25,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingCallback")).Return(mockQuery)
+				mockQuery.On("Index", "due-index").Return(mockQuery)
+				mockQuery.On("Where", "due_shard", "=", "CALLBACK").Return(mockQuery)
+				mockQuery.On("Where", "next_attempt_at", "<=", before).Return(mockQuery)
+				mockQuery.On("Limit", 25).Return(mockQuery)
+				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.PendingCallback")).Run(func(args mock.Arguments) {
+					dest := args.Get(0).(*[]dynamorm.PendingCallback)
+					*dest = []dynamorm.PendingCallback{{RequestID: "req-1", Attempts: 1}, {RequestID: "req-2"}}
+				}).Return(nil)
+			},
+			wantIDs: []string{"req-1", "req-2"},
+		},
+		{
+			name:  "dynamodb error",
+			limit: 0,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingCallback")).Return(mockQuery)
+				mockQuery.On("Index", "due-index").Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, mock.Anything, mock.Anything).Return(mockQuery)
+				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.PendingCallback")).Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			tt.setupMock(mockDB, mockQuery)
+
+			got, err := dynamorm.NewCallbackStore(mockDB).ListDue(ctx, before, tt.limit)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to list due callbacks")
+			} else {
+				assert.NoError(t, err)
+				ids := make([]string, len(got))
+				for i, callback := range got {
+					ids[i] = callback.RequestID
+				}
+				assert.Equal(t, tt.wantIDs, ids)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
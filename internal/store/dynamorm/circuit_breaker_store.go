@@ -0,0 +1,131 @@
+package dynamorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// breakerFields are the connection attributes holding circuit breaker state
+var breakerFields = []string{
+	"breaker_failures",
+	"breaker_last_failure",
+	"breaker_opened_at",
+	"breaker_probes",
+	"breaker_probe_window",
+	"breaker_version",
+}
+
+// circuitBreakerStore implements CircuitBreakerStore on the connection record,
+// so breaker state is removed along with the connection
+type circuitBreakerStore struct {
+	db core.DB
+}
+
+// NewCircuitBreakerStore creates a new DynamORM-backed circuit breaker store
+func NewCircuitBreakerStore(db core.DB) store.CircuitBreakerStore {
+	return &circuitBreakerStore{
+		db: db,
+	}
+}
+
+// Get retrieves the breaker state stored on a connection
+func (s *circuitBreakerStore) Get(ctx context.Context, connectionID string) (*store.CircuitBreakerState, error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	conn := &Connection{ConnectionID: connectionID}
+	conn.SetKeys()
+
+	if err := s.db.Model(conn).
+		Where("pk", "=", conn.PK).
+		Where("sk", "=", conn.SK).
+		First(conn); err != nil {
+		if err.Error() == "item not found" {
+			return nil, store.NewStoreError("Get", conn.TableName(), connectionID, store.ErrNotFound)
+		}
+		return nil, store.NewStoreError("Get", conn.TableName(), connectionID, fmt.Errorf("failed to get circuit breaker: %w", err))
+	}
+
+	// A connection that has never failed has no breaker state
+	if conn.BreakerVersion == 0 {
+		return nil, store.NewStoreError("Get", conn.TableName(), connectionID, store.ErrNotFound)
+	}
+
+	return conn.ToBreakerState(), nil
+}
+
+// Save writes the breaker state onto the connection if its version is unchanged.
+// It never creates a connection record.
+func (s *circuitBreakerStore) Save(ctx context.Context, state *store.CircuitBreakerState) error {
+	if state == nil {
+		return store.NewValidationError("state", "cannot be nil")
+	}
+	if state.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+
+	conn := &Connection{ConnectionID: state.ConnectionID}
+	conn.SetKeys()
+
+	builder := s.db.Model(conn).
+		Where("pk", "=", conn.PK).
+		Where("sk", "=", conn.SK).
+		UpdateBuilder().
+		Set("breaker_failures", state.Failures).
+		Set("breaker_last_failure", state.LastFailure).
+		Set("breaker_opened_at", state.OpenedAt).
+		Set("breaker_probes", state.Probes).
+		Set("breaker_probe_window", state.ProbeWindow).
+		Set("breaker_version", state.Version+1).
+		ConditionExists("connection_id")
+
+	if state.Version == 0 {
+		builder = builder.ConditionNotExists("breaker_version")
+	} else {
+		builder = builder.Condition("breaker_version", "=", state.Version)
+	}
+
+	if err := builder.Execute(); err != nil {
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return store.NewStoreError("Save", conn.TableName(), state.ConnectionID, store.ErrConcurrentModification)
+		}
+		return store.NewStoreError("Save", conn.TableName(), state.ConnectionID, fmt.Errorf("failed to save circuit breaker: %w", err))
+	}
+
+	state.Version++
+	return nil
+}
+
+// Delete removes the breaker state from a connection
+func (s *circuitBreakerStore) Delete(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	conn := &Connection{ConnectionID: connectionID}
+	conn.SetKeys()
+
+	builder := s.db.Model(conn).
+		Where("pk", "=", conn.PK).
+		Where("sk", "=", conn.SK).
+		UpdateBuilder()
+	for _, field := range breakerFields {
+		builder = builder.Remove(field)
+	}
+
+	if err := builder.ConditionExists("connection_id").Execute(); err != nil {
+		// Nothing to clear once the connection itself is gone
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return nil
+		}
+		return store.NewStoreError("Delete", conn.TableName(), connectionID, fmt.Errorf("failed to delete circuit breaker: %w", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCircuitBreakerStore_Get tests the Get method
+func TestCircuitBreakerStore_Get(t *testing.T) {
+	ctx := context.Background()
+	openedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		connectionID string
+		setupMock    func(*mocks.MockDB, *mocks.MockQuery)
+		want         *store.CircuitBreakerState
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{
+			name:         "state is read from the connection",
+			connectionID: "conn-123",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+				mockQuery.On("Where", "pk", "=", "CONN#conn-123").Return(mockQuery)
+				mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.Connection")).Run(func(args mock.Arguments) {
+					conn := args.Get(0).(*dynamorm.Connection)
+					conn.BreakerFailures = 3
+					conn.BreakerOpenedAt = openedAt
+					conn.BreakerVersion = 2
+				}).Return(nil)
+			},
+			want: &store.CircuitBreakerState{
+				ConnectionID: "conn-123",
+				Failures:     3,
+				OpenedAt:     openedAt,
+				Version:      2,
+			},
+		},
+		{
+			name:         "connection without breaker state",
+			connectionID: "conn-123",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.Connection")).Return(nil)
+			},
+			wantNotFound: true,
+		},
+		{
+			name:         "missing connection",
+			connectionID: "conn-123",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.Connection")).Return(errors.New("item not found"))
+			},
+			wantNotFound: true,
+		},
+		{
+			name:         "empty connection ID",
+			connectionID: "",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery)
+			}
+
+			state, err := dynamorm.NewCircuitBreakerStore(mockDB).Get(ctx, tt.connectionID)
+
+			switch {
+			case tt.wantNotFound:
+				assert.True(t, store.IsNotFound(err))
+			case tt.wantErr:
+				assert.Error(t, err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, state)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
+
+// TestCircuitBreakerStore_Save tests the Save method
+func TestCircuitBreakerStore_Save(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(executeErr error, condition func(*mocks.MockUpdateBuilder)) (*mocks.MockDB, *mocks.MockQuery, *mocks.MockUpdateBuilder) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+		mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+
+		mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+		mockQuery.On("Where", "pk", "=", "CONN#conn-123").Return(mockQuery)
+		mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+		mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", "breaker_failures", 3).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", mock.Anything, mock.Anything).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("ConditionExists", "connection_id").Return(mockUpdateBuilder)
+		condition(mockUpdateBuilder)
+		mockUpdateBuilder.On("Execute").Return(executeErr)
+		return mockDB, mockQuery, mockUpdateBuilder
+	}
+
+	t.Run("first save requires no existing state", func(t *testing.T) {
+		mockDB, _, mockUpdateBuilder := setup(nil, func(b *mocks.MockUpdateBuilder) {
+			b.On("ConditionNotExists", "breaker_version").Return(b)
+		})
+
+		state := &store.CircuitBreakerState{ConnectionID: "conn-123", Failures: 3}
+		require.NoError(t, dynamorm.NewCircuitBreakerStore(mockDB).Save(ctx, state))
+		assert.Equal(t, int64(1), state.Version)
+		mockUpdateBuilder.AssertCalled(t, "Set", "breaker_version", int64(1))
+		mockUpdateBuilder.AssertExpectations(t)
+	})
+
+	t.Run("update is conditioned on the version", func(t *testing.T) {
+		mockDB, _, mockUpdateBuilder := setup(nil, func(b *mocks.MockUpdateBuilder) {
+			b.On("Condition", "breaker_version", "=", int64(4)).Return(b)
+		})
+
+		state := &store.CircuitBreakerState{ConnectionID: "conn-123", Failures: 3, Version: 4}
+		require.NoError(t, dynamorm.NewCircuitBreakerStore(mockDB).Save(ctx, state))
+		assert.Equal(t, int64(5), state.Version)
+		mockUpdateBuilder.AssertExpectations(t)
+	})
+
+	t.Run("concurrent modification", func(t *testing.T) {
+		mockDB, _, _ := setup(dynamormErrors.ErrConditionFailed, func(b *mocks.MockUpdateBuilder) {
+			b.On("Condition", "breaker_version", "=", int64(4)).Return(b)
+		})
+
+		state := &store.CircuitBreakerState{ConnectionID: "conn-123", Failures: 3, Version: 4}
+		err := dynamorm.NewCircuitBreakerStore(mockDB).Save(ctx, state)
+		assert.ErrorIs(t, err, store.ErrConcurrentModification)
+		assert.Equal(t, int64(4), state.Version)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		breakers := dynamorm.NewCircuitBreakerStore(new(mocks.MockDB))
+		assert.Error(t, breakers.Save(ctx, nil))
+		assert.Error(t, breakers.Save(ctx, &store.CircuitBreakerState{}))
+	})
+}
+
+// TestCircuitBreakerStore_Delete tests the Delete method
+func TestCircuitBreakerStore_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		executeErr error
+		wantErr    bool
+	}{
+		{name: "breaker fields are removed"},
+		{name: "missing connection is ignored", executeErr: dynamormErrors.ErrConditionFailed},
+		{name: "database error", executeErr: errors.New("throttled"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+
+			mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+			mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+			mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("Remove", mock.AnythingOfType("string")).Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("ConditionExists", "connection_id").Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("Execute").Return(tt.executeErr)
+
+			err := dynamorm.NewCircuitBreakerStore(mockDB).Delete(ctx, "conn-123")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockUpdateBuilder.AssertCalled(t, "Remove", "breaker_version")
+			mockUpdateBuilder.AssertNumberOfCalls(t, "Remove", 6)
+		})
+	}
+}
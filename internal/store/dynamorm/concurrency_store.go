@@ -0,0 +1,185 @@
+package dynamorm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// concurrencyStore implements ConcurrencyStore using DynamORM
+type concurrencyStore struct {
+	db core.DB
+}
+
+// NewConcurrencyStore creates a new DynamORM-backed concurrency lease store
+func NewConcurrencyStore(db core.DB) store.ConcurrencyStore {
+	return &concurrencyStore{
+		db: db,
+	}
+}
+
+// Acquire adds a lease to the counter if it holds fewer than limit. The check,
+// the lease and the increment are one conditional update, so concurrent
+// processors cannot both take the last slot. A full counter has its expired
+// leases reclaimed before the lease is tried again.
+func (s *concurrencyStore) Acquire(ctx context.Context, key string, limit int, expiresAt time.Time) (string, error) {
+	if key == "" {
+		return "", store.NewValidationError("key", "cannot be empty")
+	}
+	if limit <= 0 {
+		return "", store.NewValidationError("limit", "must be positive")
+	}
+
+	counter := &ConcurrencyCounter{Key: key}
+	counter.SetKeys()
+
+	lease, err := newLeaseID()
+	if err != nil {
+		return "", store.NewStoreError("Acquire", counter.TableName(), key, err)
+	}
+
+	acquired, err := s.take(counter, lease, expiresAt, limit)
+	if err != nil || acquired {
+		return leaseIf(lease, acquired), err
+	}
+
+	// The condition also fails on a counter that has never been written, or
+	// that another processor created since
+	existing := &ConcurrencyCounter{}
+	if err := s.db.Model(existing).
+		Where("pk", "=", counter.PK).
+		Where("sk", "=", counter.SK).
+		First(existing); err != nil {
+		if err.Error() != "item not found" {
+			return "", store.NewStoreError("Acquire", counter.TableName(), key, fmt.Errorf("failed to get counter: %w", err))
+		}
+
+		counter.Running = 1
+		counter.Leases = map[string]int64{lease: expiresAt.Unix()}
+		counter.UpdatedAt = time.Now()
+		err := s.db.Model(counter).Create()
+		if err == nil {
+			return lease, nil
+		}
+		if !errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return "", store.NewStoreError("Acquire", counter.TableName(), key, fmt.Errorf("failed to create counter: %w", err))
+		}
+		// Another processor created it first
+	} else if existing.Running >= limit {
+		reclaimed, err := s.reclaim(counter, existing.Leases, time.Now())
+		if err != nil || reclaimed == 0 {
+			return "", err
+		}
+	}
+
+	acquired, err = s.take(counter, lease, expiresAt, limit)
+	return leaseIf(lease, acquired), err
+}
+
+// Release removes a lease from the counter. A lease that is no longer on the
+// counter, because it was released or reclaimed, is left alone.
+func (s *concurrencyStore) Release(ctx context.Context, key, lease string) error {
+	if key == "" {
+		return store.NewValidationError("key", "cannot be empty")
+	}
+	if lease == "" {
+		return store.NewValidationError("lease", "cannot be empty")
+	}
+
+	counter := &ConcurrencyCounter{Key: key}
+	counter.SetKeys()
+
+	_, err := s.drop("Release", counter, lease)
+	return err
+}
+
+// take adds the lease and increments the counter if it is below limit,
+// reporting whether it was below
+func (s *concurrencyStore) take(counter *ConcurrencyCounter, lease string, expiresAt time.Time, limit int) (bool, error) {
+	err := s.db.Model(counter).
+		Where("pk", "=", counter.PK).
+		Where("sk", "=", counter.SK).
+		UpdateBuilder().
+		Set(leasePath(lease), expiresAt.Unix()).
+		Add("running", 1).
+		Set("updated_at", time.Now()).
+		Condition("running", "<", limit).
+		Execute()
+	if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, store.NewStoreError("Acquire", counter.TableName(), counter.Key, fmt.Errorf("failed to update counter: %w", err))
+	}
+	return true, nil
+}
+
+// reclaim drops the leases that expired before now, returning how many this
+// call dropped
+func (s *concurrencyStore) reclaim(counter *ConcurrencyCounter, leases map[string]int64, now time.Time) (int, error) {
+	reclaimed := 0
+	for lease, expiry := range leases {
+		if expiry > now.Unix() {
+			continue
+		}
+		dropped, err := s.drop("Acquire", counter, lease)
+		if err != nil {
+			return reclaimed, err
+		}
+		if dropped {
+			reclaimed++
+		}
+	}
+	return reclaimed, nil
+}
+
+// drop removes the lease and decrements the counter in one update conditioned
+// on the lease still being there, so a lease is only ever counted off once
+func (s *concurrencyStore) drop(op string, counter *ConcurrencyCounter, lease string) (bool, error) {
+	err := s.db.Model(counter).
+		Where("pk", "=", counter.PK).
+		Where("sk", "=", counter.SK).
+		UpdateBuilder().
+		Remove(leasePath(lease)).
+		Add("running", -1).
+		Set("updated_at", time.Now()).
+		ConditionExists(leasePath(lease)).
+		Execute()
+	if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, store.NewStoreError(op, counter.TableName(), counter.Key, fmt.Errorf("failed to update counter: %w", err))
+	}
+	return true, nil
+}
+
+// leasePath is the document path of a lease on its counter
+func leasePath(lease string) string {
+	return "leases." + lease
+}
+
+// leaseIf returns the lease if it was acquired
+func leaseIf(lease string, acquired bool) string {
+	if !acquired {
+		return ""
+	}
+	return lease
+}
+
+// newLeaseID returns a random lease ID that is safe to use as a map key in
+// an update expression
+func newLeaseID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lease ID: %w", err)
+	}
+	return "L" + hex.EncodeToString(b), nil
+}
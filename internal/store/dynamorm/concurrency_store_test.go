@@ -0,0 +1,192 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// expectLeaseTake sets up the conditional update that adds a lease to a counter
+func expectLeaseTake(mockQuery *mocks.MockQuery, limit int, err error) *mocks.MockUpdateBuilder {
+	mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+	mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Set", mock.MatchedBy(func(field string) bool { return len(field) > len("leases.") }), mock.AnythingOfType("int64")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Add", "running", 1).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "updated_at", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Condition", "running", "<", limit).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Execute").Return(err)
+	return mockUpdateBuilder
+}
+
+// expectLeaseDrop sets up the conditional update that removes a lease from a counter
+func expectLeaseDrop(mockQuery *mocks.MockQuery, lease string, err error) *mocks.MockUpdateBuilder {
+	mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+	mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Remove", "leases."+lease).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Add", "running", -1).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "updated_at", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("ConditionExists", "leases."+lease).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Execute").Return(err)
+	return mockUpdateBuilder
+}
+
+// expectCounterRead sets up reading the counter, returning counter or err
+func expectCounterRead(mockQuery *mocks.MockQuery, counter *dynamorm.ConcurrencyCounter, err error) {
+	mockQuery.On("First", mock.AnythingOfType("*dynamorm.ConcurrencyCounter")).Run(func(args mock.Arguments) {
+		if counter != nil {
+			*args.Get(0).(*dynamorm.ConcurrencyCounter) = *counter
+		}
+	}).Return(err)
+}
+
+func TestConcurrencyStore_Acquire(t *testing.T) {
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Minute).Unix()
+	live := time.Now().Add(time.Minute).Unix()
+
+	tests := []struct {
+		name         string
+		limit        int
+		setupMock    func(*mocks.MockQuery) []*mocks.MockUpdateBuilder
+		wantAcquired bool
+		wantErr      bool
+	}{
+		{
+			name:  "slot below the limit is taken",
+			limit: 3,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				return []*mocks.MockUpdateBuilder{expectLeaseTake(mockQuery, 3, nil)}
+			},
+			wantAcquired: true,
+		},
+		{
+			name:  "missing counter is created with the lease",
+			limit: 3,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				take := expectLeaseTake(mockQuery, 3, dynamormErrors.ErrConditionFailed)
+				expectCounterRead(mockQuery, nil, errors.New("item not found"))
+				mockQuery.On("Create").Return(nil)
+				return []*mocks.MockUpdateBuilder{take}
+			},
+			wantAcquired: true,
+		},
+		{
+			name:  "counter created by another processor is taken again",
+			limit: 3,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				first := expectLeaseTake(mockQuery, 3, dynamormErrors.ErrConditionFailed)
+				expectCounterRead(mockQuery, nil, errors.New("item not found"))
+				mockQuery.On("Create").Return(dynamormErrors.ErrConditionFailed)
+				second := expectLeaseTake(mockQuery, 3, nil)
+				return []*mocks.MockUpdateBuilder{first, second}
+			},
+			wantAcquired: true,
+		},
+		{
+			name:  "full counter is refused",
+			limit: 3,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				take := expectLeaseTake(mockQuery, 3, dynamormErrors.ErrConditionFailed)
+				expectCounterRead(mockQuery, &dynamorm.ConcurrencyCounter{
+					Running: 3,
+					Leases:  map[string]int64{"La": live, "Lb": live, "Lc": live},
+				}, nil)
+				return []*mocks.MockUpdateBuilder{take}
+			},
+			wantAcquired: false,
+		},
+		{
+			name:  "expired leases are reclaimed",
+			limit: 2,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				first := expectLeaseTake(mockQuery, 2, dynamormErrors.ErrConditionFailed)
+				expectCounterRead(mockQuery, &dynamorm.ConcurrencyCounter{
+					Running: 2,
+					Leases:  map[string]int64{"La": expired, "Lb": live},
+				}, nil)
+				drop := expectLeaseDrop(mockQuery, "La", nil)
+				second := expectLeaseTake(mockQuery, 2, nil)
+				return []*mocks.MockUpdateBuilder{first, drop, second}
+			},
+			wantAcquired: true,
+		},
+		{
+			name:  "lease reclaimed by another processor is not counted",
+			limit: 1,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				take := expectLeaseTake(mockQuery, 1, dynamormErrors.ErrConditionFailed)
+				expectCounterRead(mockQuery, &dynamorm.ConcurrencyCounter{
+					Running: 1,
+					Leases:  map[string]int64{"La": expired},
+				}, nil)
+				drop := expectLeaseDrop(mockQuery, "La", dynamormErrors.ErrConditionFailed)
+				return []*mocks.MockUpdateBuilder{take, drop}
+			},
+			wantAcquired: false,
+		},
+		{
+			name:  "update error",
+			limit: 3,
+			setupMock: func(mockQuery *mocks.MockQuery) []*mocks.MockUpdateBuilder {
+				return []*mocks.MockUpdateBuilder{expectLeaseTake(mockQuery, 3, errors.New("throttled"))}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+
+			mockDB.On("Model", mock.AnythingOfType("*dynamorm.ConcurrencyCounter")).Return(mockQuery)
+			mockQuery.On("Where", "pk", "=", "COUNTER#tenant#acme#*").Return(mockQuery)
+			mockQuery.On("Where", "sk", "=", "LEASES").Return(mockQuery)
+			builders := tt.setupMock(mockQuery)
+
+			lease, err := dynamorm.NewConcurrencyStore(mockDB).Acquire(ctx, "tenant#acme#*", tt.limit, time.Now().Add(time.Minute))
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAcquired, lease != "")
+			for _, b := range builders {
+				b.AssertExpectations(t)
+			}
+		})
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		s := dynamorm.NewConcurrencyStore(new(mocks.MockDB))
+		_, err := s.Acquire(ctx, "", 1, time.Now())
+		var validationErr *store.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		_, err = s.Acquire(ctx, "tenant#acme#*", 0, time.Now())
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestConcurrencyStore_Release(t *testing.T) {
+	for _, execErr := range []error{nil, dynamormErrors.ErrConditionFailed} {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", mock.AnythingOfType("*dynamorm.ConcurrencyCounter")).Return(mockQuery)
+		mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+		drop := expectLeaseDrop(mockQuery, "La", execErr)
+
+		// A lease already released or reclaimed is left alone
+		assert.NoError(t, dynamorm.NewConcurrencyStore(mockDB).Release(context.Background(), "tenant#acme#*", "La"))
+		drop.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,91 @@
+package dynamorm_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/google/uuid"
+	"github.com/pay-theory/dynamorm/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+// newLocalFactory connects to DynamoDB Local at DYNAMODB_ENDPOINT, skipping
+// the test when it is not set
+func newLocalFactory(t *testing.T) *dynamorm.StoreFactory {
+	t.Helper()
+
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set; skipping DynamoDB Local conformance tests")
+	}
+
+	factory, err := dynamorm.NewStoreFactory(session.Config{
+		Region:              "us-east-1",
+		Endpoint:            endpoint,
+		CredentialsProvider: credentials.NewStaticCredentialsProvider("dummy", "dummy", ""),
+	})
+	require.NoError(t, err)
+	require.NoError(t, factory.EnsureTables(context.Background()))
+	return factory
+}
+
+func TestConnectionStore_Conformance(t *testing.T) {
+	factory := newLocalFactory(t)
+	storetest.RunConnectionStoreTests(t, func(t *testing.T) store.ConnectionStore {
+		return factory.ConnectionStore()
+	})
+}
+
+func TestRequestQueue_Conformance(t *testing.T) {
+	factory := newLocalFactory(t)
+	storetest.RunRequestQueueTests(t, func(t *testing.T) store.RequestQueue {
+		return factory.RequestQueue()
+	})
+}
+
+// TestRequestQueue_CountByTenant_Local counts through the tenant index, which
+// DynamoDB only queries by the index's partition key
+func TestRequestQueue_CountByTenant_Local(t *testing.T) {
+	factory := newLocalFactory(t)
+	q := factory.RequestQueue()
+	ctx := context.Background()
+	tenantID := "tenant-" + uuid.NewString()
+
+	for i, action := range []string{"generate_report", "generate_report", "export_data"} {
+		require.NoError(t, q.Enqueue(ctx, &store.AsyncRequest{
+			RequestID:    "req-" + uuid.NewString(),
+			ConnectionID: "conn-1",
+			Action:       action,
+			UserID:       "user-1",
+			TenantID:     tenantID,
+			CreatedAt:    time.Now().Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	count, err := q.CountByTenant(ctx, tenantID, "", store.StatusPending)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	count, err = q.CountByTenant(ctx, tenantID, "generate_report", store.StatusPending, store.StatusProcessing)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = q.CountByTenant(ctx, "tenant-"+uuid.NewString(), "", store.StatusPending)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestConcurrencyStore_Conformance(t *testing.T) {
+	factory := newLocalFactory(t)
+	storetest.RunConcurrencyStoreTests(t, func(t *testing.T) store.ConcurrencyStore {
+		return factory.ConcurrencyStore()
+	})
+}
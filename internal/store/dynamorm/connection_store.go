@@ -86,54 +86,50 @@ func (s *connectionStore) Delete(ctx context.Context, connectionID string) error
 	return nil
 }
 
-// ListByUser returns all connections for a user
-func (s *connectionStore) ListByUser(ctx context.Context, userID string) ([]*store.Connection, error) {
+// ListByUser returns a page of a user's connections
+func (s *connectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
 	if userID == "" {
 		return nil, store.NewValidationError("userID", "cannot be empty")
 	}
 
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
+	}
+
 	var connections []Connection
 
-	// Query using the user index with v1.0.9 API
-	if err := s.db.Model(&Connection{}).
+	// Query a page of the user index
+	next, err := queryPage(s.db.Model(&Connection{}).
 		Index("user-index").
-		Where("user_id", "=", userID).
-		All(&connections); err != nil {
+		Where("user_id", "=", userID), opts, &connections)
+	if err != nil {
 		return nil, store.NewStoreError("ListByUser", store.ConnectionsTable, userID, fmt.Errorf("failed to list connections by user: %w", err))
 	}
 
-	// Convert to store models
-	result := make([]*store.Connection, len(connections))
-	for i := range connections {
-		result[i] = connections[i].ToStoreModel()
-	}
-
-	return result, nil
+	return pageConnections(connections, next), nil
 }
 
-// ListByTenant returns all connections for a tenant
-func (s *connectionStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.Connection, error) {
+// ListByTenant returns a page of a tenant's connections
+func (s *connectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
 	if tenantID == "" {
 		return nil, store.NewValidationError("tenantID", "cannot be empty")
 	}
 
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
+	}
+
 	var connections []Connection
 
-	// Query using the tenant index with v1.0.9 API
-	if err := s.db.Model(&Connection{}).
+	// Query a page of the tenant index
+	next, err := queryPage(s.db.Model(&Connection{}).
 		Index("tenant-index").
-		Where("tenant_id", "=", tenantID).
-		All(&connections); err != nil {
+		Where("tenant_id", "=", tenantID), opts, &connections)
+	if err != nil {
 		return nil, store.NewStoreError("ListByTenant", store.ConnectionsTable, tenantID, fmt.Errorf("failed to list connections by tenant: %w", err))
 	}
 
-	// Convert to store models
-	result := make([]*store.Connection, len(connections))
-	for i := range connections {
-		result[i] = connections[i].ToStoreModel()
-	}
-
-	return result, nil
+	return pageConnections(connections, next), nil
 }
 
 // UpdateLastPing updates the last ping timestamp
@@ -165,18 +161,80 @@ func (s *connectionStore) UpdateLastPing(ctx context.Context, connectionID strin
 	return nil
 }
 
+// UpdateClaims replaces a connection's metadata and token expiry
+func (s *connectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	// Create model with keys
+	conn := &Connection{ConnectionID: connectionID}
+	conn.SetKeys()
+
+	err := s.db.Model(conn).
+		UpdateBuilder().
+		Set("metadata", metadata).
+		Set("token_expires_at", tokenExpiresAt).
+		Execute()
+
+	if err != nil {
+		if err.Error() == "item not found" {
+			return store.NewStoreError("UpdateClaims", conn.TableName(), connectionID, store.ErrNotFound)
+		}
+		return store.NewStoreError("UpdateClaims", conn.TableName(), connectionID, fmt.Errorf("failed to update claims: %w", err))
+	}
+
+	return nil
+}
+
+// ListExpiring returns a page of connections whose token expires before the
+// specified time. Connections without a token expiry have no token_expires_at
+// attribute and never match.
+func (s *connectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
+	}
+
+	var connections []Connection
+
+	// Scan a page of the table
+	next, err := queryPage(s.db.Model(&Connection{}).
+		Where("token_expires_at", "<", before), opts, &connections)
+	if err != nil {
+		return nil, store.NewStoreError("ListExpiring", store.ConnectionsTable, "", fmt.Errorf("failed to scan expiring connections: %w", err))
+	}
+
+	return pageConnections(connections, next), nil
+}
+
+// ListStale returns a page of connections whose last ping is older than the specified time
+func (s *connectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
+	}
+
+	var connections []Connection
+
+	// Scan a page of the table for old connections
+	next, err := queryPage(s.db.Model(&Connection{}).
+		Where("last_ping", "<", before), opts, &connections)
+	if err != nil {
+		return nil, store.NewStoreError("ListStale", store.ConnectionsTable, "", fmt.Errorf("failed to scan stale connections: %w", err))
+	}
+
+	return pageConnections(connections, next), nil
+}
+
 // DeleteStale removes connections older than the specified time
 func (s *connectionStore) DeleteStale(ctx context.Context, before time.Time) error {
 	// In production, this would be handled by DynamoDB TTL
 	// This method is primarily for testing and manual cleanup
 
-	var connections []Connection
-
-	// Scan for old connections using v1.0.9 API
-	if err := s.db.Model(&Connection{}).
-		Where("last_ping", "<", before).
-		Scan(&connections); err != nil {
-		return store.NewStoreError("DeleteStale", store.ConnectionsTable, "", fmt.Errorf("failed to scan stale connections: %w", err))
+	connections, err := store.Collect(ctx, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return s.ListStale(ctx, before, opts)
+	})
+	if err != nil {
+		return err
 	}
 
 	// Delete each stale connection
@@ -192,6 +250,19 @@ func (s *connectionStore) DeleteStale(ctx context.Context, before time.Time) err
 	return nil
 }
 
+// pageConnections converts the connections a query returned to a page of
+// store models
+func pageConnections(connections []Connection, next string) *store.Page[*store.Connection] {
+	page := &store.Page[*store.Connection]{
+		Items:     make([]*store.Connection, len(connections)),
+		NextToken: next,
+	}
+	for i := range connections {
+		page.Items[i] = connections[i].ToStoreModel()
+	}
+	return page
+}
+
 // validateConnection validates a connection before saving
 func (s *connectionStore) validateConnection(conn *store.Connection) error {
 	if conn == nil {
@@ -6,11 +6,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pay-theory/dynamorm/pkg/core"
 	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/dynamorm/pkg/query"
 	"github.com/pay-theory/streamer/internal/store"
 	"github.com/pay-theory/streamer/internal/store/dynamorm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNewConnectionStore tests the constructor
@@ -362,12 +366,15 @@ func TestConnectionStore_Delete(t *testing.T) {
 // TestConnectionStore_ListByUser tests the ListByUser method
 func TestConnectionStore_ListByUser(t *testing.T) {
 	ctx := context.Background()
+	pageToken := dynamormPageToken(t, "conn1")
 
 	tests := []struct {
 		name      string
 		userID    string
+		opts      store.PageOptions
 		setupMock func(*mocks.MockDB, *mocks.MockQuery)
 		want      int // number of connections expected
+		wantMore  bool
 		wantErr   bool
 		errMsg    string
 	}{
@@ -391,15 +398,66 @@ func TestConnectionStore_ListByUser(t *testing.T) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Index", "user-index").Return(mockQuery)
 				mockQuery.On("Where", "user_id", "=", "user123").Return(mockQuery)
-				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.Connection")).
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).(*[]dynamorm.Connection)
 						*dest = expectedConnections
-					}).Return(nil)
+					}).Return(&core.PaginatedResult{}, nil)
 			},
 			want:    2,
 			wantErr: false,
 		},
+		{
+			name:   "limited page",
+			userID: "user123",
+			opts:   store.PageOptions{Limit: 1},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+				mockQuery.On("Index", "user-index").Return(mockQuery)
+				mockQuery.On("Where", "user_id", "=", "user123").Return(mockQuery)
+				mockQuery.On("Limit", 1).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
+					Run(func(args mock.Arguments) {
+						dest := args.Get(0).(*[]dynamorm.Connection)
+						*dest = []dynamorm.Connection{
+							{ConnectionID: "conn1", UserID: "user123", TenantID: "tenant123"},
+						}
+					}).Return(&core.PaginatedResult{NextCursor: pageToken}, nil)
+			},
+			want:     1,
+			wantMore: true,
+		},
+		{
+			name:   "next page",
+			userID: "user123",
+			opts:   store.PageOptions{Limit: 1, NextToken: pageToken},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+				mockQuery.On("Index", "user-index").Return(mockQuery)
+				mockQuery.On("Where", "user_id", "=", "user123").Return(mockQuery)
+				mockQuery.On("Cursor", pageToken).Return(mockQuery)
+				mockQuery.On("Limit", 1).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
+					Run(func(args mock.Arguments) {
+						dest := args.Get(0).(*[]dynamorm.Connection)
+						*dest = []dynamorm.Connection{
+							{ConnectionID: "conn2", UserID: "user123", TenantID: "tenant123"},
+						}
+					}).Return(&core.PaginatedResult{}, nil)
+			},
+			want: 1,
+		},
+		{
+			name:   "invalid page token",
+			userID: "user123",
+			opts:   store.PageOptions{NextToken: "not a token"},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				// The token is rejected before querying
+			},
+			wantErr: true,
+			errMsg:  "not a valid page token",
+		},
 		{
 			name:   "empty user ID",
 			userID: "",
@@ -416,7 +474,8 @@ func TestConnectionStore_ListByUser(t *testing.T) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Index", "user-index").Return(mockQuery)
 				mockQuery.On("Where", "user_id", "=", "user123").Return(mockQuery)
-				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.Connection")).Return(errors.New("dynamodb error"))
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).Return(nil, errors.New("dynamodb error"))
 			},
 			wantErr: true,
 			errMsg:  "failed to list connections by user",
@@ -428,11 +487,12 @@ func TestConnectionStore_ListByUser(t *testing.T) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Index", "user-index").Return(mockQuery)
 				mockQuery.On("Where", "user_id", "=", "user123").Return(mockQuery)
-				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.Connection")).
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).(*[]dynamorm.Connection)
 						*dest = []dynamorm.Connection{}
-					}).Return(nil)
+					}).Return(&core.PaginatedResult{}, nil)
 			},
 			want:    0,
 			wantErr: false,
@@ -449,7 +509,7 @@ func TestConnectionStore_ListByUser(t *testing.T) {
 			}
 
 			connStore := dynamorm.NewConnectionStore(mockDB)
-			got, err := connStore.ListByUser(ctx, tt.userID)
+			got, err := connStore.ListByUser(ctx, tt.userID, tt.opts)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -458,7 +518,8 @@ func TestConnectionStore_ListByUser(t *testing.T) {
 				}
 			} else {
 				assert.NoError(t, err)
-				assert.Len(t, got, tt.want)
+				assert.Len(t, got.Items, tt.want)
+				assert.Equal(t, tt.wantMore, got.NextToken != "")
 			}
 
 			mockDB.AssertExpectations(t)
@@ -499,11 +560,12 @@ func TestConnectionStore_ListByTenant(t *testing.T) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Index", "tenant-index").Return(mockQuery)
 				mockQuery.On("Where", "tenant_id", "=", "tenant123").Return(mockQuery)
-				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.Connection")).
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).(*[]dynamorm.Connection)
 						*dest = expectedConnections
-					}).Return(nil)
+					}).Return(&core.PaginatedResult{}, nil)
 			},
 			want:    2,
 			wantErr: false,
@@ -524,7 +586,8 @@ func TestConnectionStore_ListByTenant(t *testing.T) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Index", "tenant-index").Return(mockQuery)
 				mockQuery.On("Where", "tenant_id", "=", "tenant123").Return(mockQuery)
-				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.Connection")).Return(errors.New("dynamodb error"))
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).Return(nil, errors.New("dynamodb error"))
 			},
 			wantErr: true,
 			errMsg:  "failed to list connections by tenant",
@@ -541,7 +604,7 @@ func TestConnectionStore_ListByTenant(t *testing.T) {
 			}
 
 			connStore := dynamorm.NewConnectionStore(mockDB)
-			got, err := connStore.ListByTenant(ctx, tt.tenantID)
+			got, err := connStore.ListByTenant(ctx, tt.tenantID, store.PageOptions{})
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -550,7 +613,7 @@ func TestConnectionStore_ListByTenant(t *testing.T) {
 				}
 			} else {
 				assert.NoError(t, err)
-				assert.Len(t, got, tt.want)
+				assert.Len(t, got.Items, tt.want)
 			}
 
 			mockDB.AssertExpectations(t)
@@ -673,11 +736,12 @@ func TestConnectionStore_DeleteStale(t *testing.T) {
 				// Mock the scan
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Where", "last_ping", "<", staleTime).Return(mockQuery)
-				mockQuery.On("Scan", mock.AnythingOfType("*[]dynamorm.Connection")).
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).(*[]dynamorm.Connection)
 						*dest = staleConnections
-					}).Return(nil)
+					}).Return(&core.PaginatedResult{}, nil)
 
 				// Mock the deletes
 				for range staleConnections {
@@ -694,7 +758,8 @@ func TestConnectionStore_DeleteStale(t *testing.T) {
 			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Where", "last_ping", "<", staleTime).Return(mockQuery)
-				mockQuery.On("Scan", mock.AnythingOfType("*[]dynamorm.Connection")).Return(errors.New("scan error"))
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).Return(nil, errors.New("scan error"))
 			},
 			wantErr: true,
 			errMsg:  "failed to scan stale connections",
@@ -705,11 +770,12 @@ func TestConnectionStore_DeleteStale(t *testing.T) {
 			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
 				mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
 				mockQuery.On("Where", "last_ping", "<", staleTime).Return(mockQuery)
-				mockQuery.On("Scan", mock.AnythingOfType("*[]dynamorm.Connection")).
+				mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+				mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).(*[]dynamorm.Connection)
 						*dest = []dynamorm.Connection{}
-					}).Return(nil)
+					}).Return(&core.PaginatedResult{}, nil)
 			},
 			wantErr: false,
 		},
@@ -741,3 +807,170 @@ func TestConnectionStore_DeleteStale(t *testing.T) {
 		})
 	}
 }
+
+// TestConnectionStore_ListStale tests the ListStale method
+func TestConnectionStore_ListStale(t *testing.T) {
+	ctx := context.Background()
+	staleTime := time.Now().Add(-1 * time.Hour)
+
+	t.Run("returns stale connections", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+		mockQuery.On("Where", "last_ping", "<", staleTime).Return(mockQuery)
+		mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+		mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
+			Run(func(args mock.Arguments) {
+				dest := args.Get(0).(*[]dynamorm.Connection)
+				*dest = []dynamorm.Connection{
+					{ConnectionID: "conn1", UserID: "user1", TenantID: "tenant1", LastPing: staleTime.Add(-time.Minute)},
+					{ConnectionID: "conn2", UserID: "user2", TenantID: "tenant1", LastPing: staleTime.Add(-time.Hour)},
+				}
+			}).Return(&core.PaginatedResult{}, nil)
+
+		connections, err := dynamorm.NewConnectionStore(mockDB).ListStale(ctx, staleTime, store.PageOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, connections.Items, 2)
+		assert.Equal(t, "conn1", connections.Items[0].ConnectionID)
+		assert.Equal(t, "user2", connections.Items[1].UserID)
+
+		mockDB.AssertExpectations(t)
+		mockQuery.AssertExpectations(t)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+		mockQuery.On("Where", "last_ping", "<", staleTime).Return(mockQuery)
+		mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+		mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).Return(nil, errors.New("scan error"))
+
+		_, err := dynamorm.NewConnectionStore(mockDB).ListStale(ctx, staleTime, store.PageOptions{})
+		assert.ErrorContains(t, err, "failed to scan stale connections")
+	})
+}
+
+// TestConnectionStore_UpdateClaims tests the UpdateClaims method
+func TestConnectionStore_UpdateClaims(t *testing.T) {
+	ctx := context.Background()
+	metadata := map[string]string{"permissions": `["read"]`}
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		connectionID string
+		setupMock    func(*mocks.MockDB, *mocks.MockQuery, *mocks.MockUpdateBuilder)
+		wantErr      bool
+		errMsg       string
+	}{
+		{
+			name:         "successful update",
+			connectionID: "conn123",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+				mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "metadata", metadata).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "token_expires_at", expiresAt).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Execute").Return(nil)
+			},
+		},
+		{
+			name:         "empty connection ID",
+			connectionID: "",
+			setupMock:    func(*mocks.MockDB, *mocks.MockQuery, *mocks.MockUpdateBuilder) {},
+			wantErr:      true,
+			errMsg:       "cannot be empty",
+		},
+		{
+			name:         "connection not found",
+			connectionID: "conn123",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+				mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", mock.Anything, mock.Anything).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Execute").Return(errors.New("item not found"))
+			},
+			wantErr: true,
+			errMsg:  "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+			tt.setupMock(mockDB, mockQuery, mockUpdateBuilder)
+
+			err := dynamorm.NewConnectionStore(mockDB).UpdateClaims(ctx, tt.connectionID, metadata, expiresAt)
+
+			if tt.wantErr {
+				assert.ErrorContains(t, err, tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+			mockUpdateBuilder.AssertExpectations(t)
+		})
+	}
+}
+
+// TestConnectionStore_ListExpiring tests the ListExpiring method
+func TestConnectionStore_ListExpiring(t *testing.T) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(5 * time.Minute)
+
+	t.Run("returns expiring connections", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+		mockQuery.On("Where", "token_expires_at", "<", cutoff).Return(mockQuery)
+		mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+		mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).
+			Run(func(args mock.Arguments) {
+				dest := args.Get(0).(*[]dynamorm.Connection)
+				*dest = []dynamorm.Connection{
+					{ConnectionID: "conn1", UserID: "user1", TenantID: "tenant1", TokenExpiresAt: cutoff.Add(-time.Minute)},
+				}
+			}).Return(&core.PaginatedResult{}, nil)
+
+		connections, err := dynamorm.NewConnectionStore(mockDB).ListExpiring(ctx, cutoff, store.PageOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, connections.Items, 1)
+		assert.Equal(t, cutoff.Add(-time.Minute), connections.Items[0].TokenExpiresAt)
+
+		mockDB.AssertExpectations(t)
+		mockQuery.AssertExpectations(t)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", &dynamorm.Connection{}).Return(mockQuery)
+		mockQuery.On("Where", "token_expires_at", "<", cutoff).Return(mockQuery)
+		mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+		mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.Connection")).Return(nil, errors.New("scan error"))
+
+		_, err := dynamorm.NewConnectionStore(mockDB).ListExpiring(ctx, cutoff, store.PageOptions{})
+		assert.ErrorContains(t, err, "failed to scan expiring connections")
+	})
+}
+
+// dynamormPageToken encodes a DynamORM cursor that resumes after connectionID
+func dynamormPageToken(t *testing.T, connectionID string) string {
+	t.Helper()
+
+	token, err := query.EncodeCursor(map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "CONN#" + connectionID},
+		"sk": &types.AttributeValueMemberS{Value: "METADATA"},
+	}, "user-index", "")
+	require.NoError(t, err)
+	return token
+}
@@ -0,0 +1,127 @@
+package dynamorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// deliveryStore implements DeliveryStore using DynamORM
+type deliveryStore struct {
+	db core.DB
+}
+
+// NewDeliveryStore creates a new DynamORM-backed pending delivery store
+func NewDeliveryStore(db core.DB) store.DeliveryStore {
+	return &deliveryStore{
+		db: db,
+	}
+}
+
+// Save creates or replaces a pending delivery
+func (s *deliveryStore) Save(ctx context.Context, delivery *store.PendingDelivery) error {
+	if err := s.validateDelivery(delivery); err != nil {
+		return err
+	}
+
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	dynamormDelivery := &PendingDelivery{}
+	dynamormDelivery.FromStoreModel(delivery)
+
+	if err := s.db.Model(dynamormDelivery).CreateOrUpdate(); err != nil {
+		return store.NewStoreError("Save", store.DeliveriesTable, delivery.DeliveryID, fmt.Errorf("failed to save delivery: %w", err))
+	}
+
+	return nil
+}
+
+// Delete removes a pending delivery
+func (s *deliveryStore) Delete(ctx context.Context, recipient, deliveryID string) error {
+	if recipient == "" {
+		return store.NewValidationError("recipient", "cannot be empty")
+	}
+	if deliveryID == "" {
+		return store.NewValidationError("deliveryID", "cannot be empty")
+	}
+
+	delivery := &PendingDelivery{Recipient: recipient, DeliveryID: deliveryID}
+	delivery.SetKeys()
+
+	if err := s.db.Model(delivery).Delete(); err != nil {
+		return store.NewStoreError("Delete", store.DeliveriesTable, deliveryID, fmt.Errorf("failed to delete delivery: %w", err))
+	}
+
+	return nil
+}
+
+// ListByRecipient returns a recipient's pending deliveries, oldest first
+func (s *deliveryStore) ListByRecipient(ctx context.Context, recipient string) ([]*store.PendingDelivery, error) {
+	if recipient == "" {
+		return nil, store.NewValidationError("recipient", "cannot be empty")
+	}
+
+	var deliveries []PendingDelivery
+	if err := s.db.Model(&PendingDelivery{}).
+		Where("pk", "=", recipient).
+		All(&deliveries); err != nil {
+		return nil, store.NewStoreError("ListByRecipient", store.DeliveriesTable, recipient, fmt.Errorf("failed to list deliveries: %w", err))
+	}
+
+	result := toStoreDeliveries(deliveries)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// ListDue returns pending deliveries whose next attempt is due
+func (s *deliveryStore) ListDue(ctx context.Context, before time.Time, limit int) ([]*store.PendingDelivery, error) {
+	var deliveries []PendingDelivery
+
+	query := s.db.Model(&PendingDelivery{}).
+		Index("due-index").
+		Where("due_shard", "=", deliveryDueShard).
+		Where("next_attempt_at", "<=", before)
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.All(&deliveries); err != nil {
+		return nil, store.NewStoreError("ListDue", store.DeliveriesTable, "", fmt.Errorf("failed to list due deliveries: %w", err))
+	}
+
+	return toStoreDeliveries(deliveries), nil
+}
+
+// validateDelivery validates a delivery before saving
+func (s *deliveryStore) validateDelivery(delivery *store.PendingDelivery) error {
+	if delivery == nil {
+		return store.NewValidationError("delivery", "cannot be nil")
+	}
+	if delivery.Recipient == "" {
+		return store.NewValidationError("Recipient", "cannot be empty")
+	}
+	if delivery.DeliveryID == "" {
+		return store.NewValidationError("DeliveryID", "cannot be empty")
+	}
+	if len(delivery.Message) == 0 {
+		return store.NewValidationError("Message", "cannot be empty")
+	}
+	return nil
+}
+
+func toStoreDeliveries(deliveries []PendingDelivery) []*store.PendingDelivery {
+	result := make([]*store.PendingDelivery, len(deliveries))
+	for i := range deliveries {
+		result[i] = deliveries[i].ToStoreModel()
+	}
+	return result
+}
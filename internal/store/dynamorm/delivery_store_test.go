@@ -0,0 +1,194 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestDeliveryStore_Save tests the Save method
+func TestDeliveryStore_Save(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		delivery  *store.PendingDelivery
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantErr   bool
+		errMsg    string
+	}{
+		{
+			name:     "successful save",
+			delivery: &store.PendingDelivery{Recipient: "CONN#conn-123", DeliveryID: "dlv-1", Message: []byte(`{}`)},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingDelivery")).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(nil)
+			},
+		},
+		{
+			name:     "dynamodb error",
+			delivery: &store.PendingDelivery{Recipient: "CONN#conn-123", DeliveryID: "dlv-1", Message: []byte(`{}`)},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingDelivery")).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+			errMsg:  "failed to save delivery",
+		},
+		{
+			name:     "nil delivery",
+			delivery: nil,
+			wantErr:  true,
+			errMsg:   "cannot be nil",
+		},
+		{
+			name:     "missing recipient",
+			delivery: &store.PendingDelivery{DeliveryID: "dlv-1", Message: []byte(`{}`)},
+			wantErr:  true,
+			errMsg:   "Recipient",
+		},
+		{
+			name:     "missing delivery ID",
+			delivery: &store.PendingDelivery{Recipient: "CONN#conn-123", Message: []byte(`{}`)},
+			wantErr:  true,
+			errMsg:   "DeliveryID",
+		},
+		{
+			name:     "empty message",
+			delivery: &store.PendingDelivery{Recipient: "CONN#conn-123", DeliveryID: "dlv-1"},
+			wantErr:  true,
+			errMsg:   "Message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery)
+			}
+
+			err := dynamorm.NewDeliveryStore(mockDB).Save(ctx, tt.delivery)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, tt.delivery.CreatedAt.IsZero())
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
+
+// TestDeliveryStore_DeleteAndList tests the Delete and ListByRecipient methods
+func TestDeliveryStore_DeleteAndList(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mockDB := new(mocks.MockDB)
+	mockQuery := new(mocks.MockQuery)
+
+	mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingDelivery")).Return(mockQuery)
+	mockQuery.On("Delete").Return(nil)
+	mockQuery.On("Where", "pk", "=", "USER#tenant-abc#user-1").Return(mockQuery)
+	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.PendingDelivery")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*[]dynamorm.PendingDelivery)
+		*dest = []dynamorm.PendingDelivery{
+			{DeliveryID: "dlv-2", Message: `{"n":2}`, CreatedAt: now},
+			{DeliveryID: "dlv-1", Message: `{"n":1}`, CreatedAt: now.Add(-time.Minute)},
+		}
+	}).Return(nil)
+
+	deliveryStore := dynamorm.NewDeliveryStore(mockDB)
+
+	assert.NoError(t, deliveryStore.Delete(ctx, "USER#tenant-abc#user-1", "dlv-1"))
+
+	deliveries, err := deliveryStore.ListByRecipient(ctx, "USER#tenant-abc#user-1")
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, "dlv-1", deliveries[0].DeliveryID)
+	assert.Equal(t, []byte(`{"n":1}`), deliveries[0].Message)
+	assert.Equal(t, "dlv-2", deliveries[1].DeliveryID)
+
+	assert.Error(t, deliveryStore.Delete(ctx, "USER#tenant-abc#user-1", ""))
+	_, err = deliveryStore.ListByRecipient(ctx, "")
+	assert.Error(t, err)
+
+	mockDB.AssertExpectations(t)
+	mockQuery.AssertExpectations(t)
+}
+
+// TestDeliveryStore_ListDue tests the ListDue method
+func TestDeliveryStore_ListDue(t *testing.T) {
+	ctx := context.Background()
+	before := time.Now()
+
+	tests := []struct {
+		name      string
+		limit     int
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:  "due deliveries are read from the due index",
+			limit: 25,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingDelivery")).Return(mockQuery)
+				mockQuery.On("Index", "due-index").Return(mockQuery)
+				mockQuery.On("Where", "due_shard", "=", "PENDING").Return(mockQuery)
+				mockQuery.On("Where", "next_attempt_at", "<=", before).Return(mockQuery)
+				mockQuery.On("Limit", 25).Return(mockQuery)
+				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.PendingDelivery")).Run(func(args mock.Arguments) {
+					dest := args.Get(0).(*[]dynamorm.PendingDelivery)
+					*dest = []dynamorm.PendingDelivery{{DeliveryID: "dlv-1"}, {DeliveryID: "dlv-2"}}
+				}).Return(nil)
+			},
+			wantCount: 2,
+		},
+		{
+			name:  "dynamodb error",
+			limit: 0,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.PendingDelivery")).Return(mockQuery)
+				mockQuery.On("Index", "due-index").Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, mock.Anything, mock.Anything).Return(mockQuery)
+				mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.PendingDelivery")).Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			tt.setupMock(mockDB, mockQuery)
+
+			got, err := dynamorm.NewDeliveryStore(mockDB).ListDue(ctx, before, tt.limit)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to list due deliveries")
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, got, tt.wantCount)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
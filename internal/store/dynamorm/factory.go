@@ -16,6 +16,13 @@ type StoreFactory struct {
 	connectionStore   store.ConnectionStore
 	requestQueue      store.RequestQueue
 	subscriptionStore store.SubscriptionStore
+	rateLimitStore    store.RateLimitStore
+	quotaStore        store.QuotaStore
+	concurrencyStore  store.ConcurrencyStore
+	breakerStore      store.CircuitBreakerStore
+	sequenceStore     store.SequenceStore
+	deliveryStore     store.DeliveryStore
+	callbackStore     store.CallbackStore
 }
 
 // NewStoreFactory creates a new DynamORM store factory
@@ -34,9 +41,16 @@ func NewStoreFactory(config session.Config) (*StoreFactory, error) {
 
 	// Create stores
 	factory := &StoreFactory{
-		db:              dynamormDB,
-		connectionStore: NewConnectionStore(dynamormDB),
-		requestQueue:    NewRequestQueue(dynamormDB),
+		db:               dynamormDB,
+		connectionStore:  NewConnectionStore(dynamormDB),
+		requestQueue:     NewRequestQueue(dynamormDB),
+		rateLimitStore:   NewRateLimitStore(dynamormDB),
+		quotaStore:       NewQuotaStore(dynamormDB),
+		concurrencyStore: NewConcurrencyStore(dynamormDB),
+		breakerStore:     NewCircuitBreakerStore(dynamormDB),
+		sequenceStore:    NewSequenceStore(dynamormDB),
+		deliveryStore:    NewDeliveryStore(dynamormDB),
+		callbackStore:    NewCallbackStore(dynamormDB),
 		// TODO: Implement subscription store
 		// subscriptionStore: NewSubscriptionStore(dynamormDB),
 	}
@@ -71,6 +85,41 @@ func (f *StoreFactory) SubscriptionStore() store.SubscriptionStore {
 	return f.subscriptionStore
 }
 
+// RateLimitStore returns the rate limit store
+func (f *StoreFactory) RateLimitStore() store.RateLimitStore {
+	return f.rateLimitStore
+}
+
+// QuotaStore returns the tenant quota store
+func (f *StoreFactory) QuotaStore() store.QuotaStore {
+	return f.quotaStore
+}
+
+// ConcurrencyStore returns the tenant concurrency counter store
+func (f *StoreFactory) ConcurrencyStore() store.ConcurrencyStore {
+	return f.concurrencyStore
+}
+
+// CircuitBreakerStore returns the circuit breaker store
+func (f *StoreFactory) CircuitBreakerStore() store.CircuitBreakerStore {
+	return f.breakerStore
+}
+
+// SequenceStore returns the connection sequence store
+func (f *StoreFactory) SequenceStore() store.SequenceStore {
+	return f.sequenceStore
+}
+
+// DeliveryStore returns the pending delivery store
+func (f *StoreFactory) DeliveryStore() store.DeliveryStore {
+	return f.deliveryStore
+}
+
+// CallbackStore returns the pending callback store
+func (f *StoreFactory) CallbackStore() store.CallbackStore {
+	return f.callbackStore
+}
+
 // DB returns the underlying DynamORM database instance
 func (f *StoreFactory) DB() *dynamorm.DB {
 	return f.db
@@ -97,5 +146,23 @@ func (f *StoreFactory) EnsureTables(ctx context.Context) error {
 		return fmt.Errorf("failed to ensure subscriptions table: %w", err)
 	}
 
+	// Rate limit table
+	rateLimitTable := &RateLimitBucket{}
+	if err := f.db.AutoMigrate(rateLimitTable); err != nil {
+		return fmt.Errorf("failed to ensure rate limits table: %w", err)
+	}
+
+	// Tenant quota table
+	quotaTable := &TenantQuota{}
+	if err := f.db.AutoMigrate(quotaTable); err != nil {
+		return fmt.Errorf("failed to ensure tenant quotas table: %w", err)
+	}
+
+	// Pending delivery table
+	deliveryTable := &PendingDelivery{}
+	if err := f.db.AutoMigrate(deliveryTable); err != nil {
+		return fmt.Errorf("failed to ensure deliveries table: %w", err)
+	}
+
 	return nil
 }
@@ -1,6 +1,7 @@
 package dynamorm
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -24,6 +25,20 @@ type Connection struct {
 	// Metadata for storing additional information
 	Metadata map[string]string `dynamorm:"metadata,omitempty"`
 
+	// Token expiry, checked by the router and the reaper
+	TokenExpiresAt time.Time `dynamorm:"token_expires_at,omitempty"`
+
+	// Circuit breaker state, written by the circuit breaker store
+	BreakerFailures    int       `dynamorm:"breaker_failures,omitempty"`
+	BreakerLastFailure time.Time `dynamorm:"breaker_last_failure,omitempty"`
+	BreakerOpenedAt    time.Time `dynamorm:"breaker_opened_at,omitempty"`
+	BreakerProbes      int       `dynamorm:"breaker_probes,omitempty"`
+	BreakerProbeWindow time.Time `dynamorm:"breaker_probe_window,omitempty"`
+	BreakerVersion     int64     `dynamorm:"breaker_version,omitempty"`
+
+	// Last sequence number sent to the connection, written by the sequence store
+	Sequence uint64 `dynamorm:"sequence,omitempty"`
+
 	// TTL for automatic cleanup
 	TTL int64 `dynamorm:"ttl,omitempty"`
 }
@@ -33,6 +48,19 @@ func (c *Connection) TableName() string {
 	return store.ConnectionsTable
 }
 
+// ToBreakerState converts the breaker fields to the store.CircuitBreakerState model
+func (c *Connection) ToBreakerState() *store.CircuitBreakerState {
+	return &store.CircuitBreakerState{
+		ConnectionID: c.ConnectionID,
+		Failures:     c.BreakerFailures,
+		LastFailure:  c.BreakerLastFailure,
+		OpenedAt:     c.BreakerOpenedAt,
+		Probes:       c.BreakerProbes,
+		ProbeWindow:  c.BreakerProbeWindow,
+		Version:      c.BreakerVersion,
+	}
+}
+
 // SetKeys sets the composite keys for the connection
 func (c *Connection) SetKeys() {
 	c.PK = fmt.Sprintf("CONN#%s", c.ConnectionID)
@@ -50,6 +78,8 @@ func (c *Connection) ToStoreModel() *store.Connection {
 		LastPing:     c.LastPing,
 		Metadata:     c.Metadata,
 		TTL:          c.TTL,
+
+		TokenExpiresAt: c.TokenExpiresAt,
 	}
 }
 
@@ -62,6 +92,7 @@ func (c *Connection) FromStoreModel(conn *store.Connection) {
 	c.ConnectedAt = conn.ConnectedAt
 	c.LastPing = conn.LastPing
 	c.Metadata = conn.Metadata
+	c.TokenExpiresAt = conn.TokenExpiresAt
 	c.TTL = conn.TTL
 	c.SetKeys()
 }
@@ -75,8 +106,8 @@ type AsyncRequest struct {
 	// Request data
 	RequestID    string                 `dynamorm:"request_id"`
 	ConnectionID string                 `dynamorm:"connection_id" dynamorm-index:"connection-index,pk"`
-	Status       store.RequestStatus    `dynamorm:"status" dynamorm-index:"status-index,sk"`
-	CreatedAt    time.Time              `dynamorm:"created_at"`
+	Status       store.RequestStatus    `dynamorm:"status" dynamorm-index:"status-index,pk"`
+	CreatedAt    time.Time              `dynamorm:"created_at" dynamorm-index:"tenant-index,sk;user-index,sk"`
 	Action       string                 `dynamorm:"action"`
 	Payload      map[string]interface{} `dynamorm:"payload,omitempty"`
 
@@ -99,46 +130,90 @@ type AsyncRequest struct {
 	RetryAfter time.Time `dynamorm:"retry_after,omitempty"`
 
 	// User and tenant for querying
-	UserID   string `dynamorm:"user_id" dynamorm-index:"user-index,sk"`
-	TenantID string `dynamorm:"tenant_id" dynamorm-index:"tenant-index,sk"`
+	UserID   string `dynamorm:"user_id" dynamorm-index:"user-index,pk"`
+	TenantID string `dynamorm:"tenant_id" dynamorm-index:"tenant-index,pk"`
+
+	// Permissions granted to the caller when the request was submitted
+	Permissions []string `dynamorm:"permissions,omitempty"`
+
+	// Callback the request's outcome is delivered to. The secret arrives
+	// sealed, so the item and its stream records only hold ciphertext.
+	CallbackURL    string `dynamorm:"callback_url,omitempty"`
+	CallbackSecret string `dynamorm:"callback_secret,omitempty"`
+
+	// CallbackDeliveries is the JSON encoded log of delivery attempts.
+	// DynamORM marshals nested structs by their Go field names, so the log is
+	// kept as a string to give it the same shape as the other backends.
+	CallbackDeliveries string `dynamorm:"callback_deliveries,omitempty"`
+
+	// Version is incremented by every write, which is conditional on it
+	Version int64 `dynamorm:"version"`
+
+	// MigratedAt is set on requests moved from the old layout. Their INSERT
+	// repeats a request the stream processor has already been sent, so it
+	// skips them.
+	MigratedAt *time.Time `dynamorm:"migrated_at,omitempty"`
 
 	// TTL for automatic cleanup
 	TTL int64 `dynamorm:"ttl,omitempty"`
 }
 
+// requestSortKey is the sort key of every request. Status is an indexed
+// attribute, so a request is updated in place as it moves between statuses.
+const requestSortKey = "METADATA"
+
+// legacyRequestSortKeyPrefix starts the sort key of requests written before
+// the status moved out of the key
+const legacyRequestSortKeyPrefix = "STATUS#"
+
 // TableName returns the DynamoDB table name
 func (r *AsyncRequest) TableName() string {
+	return store.RequestsV2Table
+}
+
+// LegacyAsyncRequest is a request in the table requests were kept in before
+// they moved to RequestsV2Table. Its items are read, never written, while
+// requests are migrated out of it.
+type LegacyAsyncRequest AsyncRequest
+
+// TableName returns the DynamoDB table name
+func (r *LegacyAsyncRequest) TableName() string {
 	return store.RequestsTable
 }
 
 // SetKeys sets the composite keys for the request
 func (r *AsyncRequest) SetKeys() {
 	r.PK = fmt.Sprintf("REQ#%s", r.RequestID)
-	r.SK = fmt.Sprintf("STATUS#%s", r.Status)
+	r.SK = requestSortKey
 }
 
 // ToStoreModel converts to the store.AsyncRequest model
 func (r *AsyncRequest) ToStoreModel() *store.AsyncRequest {
 	return &store.AsyncRequest{
-		RequestID:         r.RequestID,
-		ConnectionID:      r.ConnectionID,
-		Status:            r.Status,
-		CreatedAt:         r.CreatedAt,
-		Action:            r.Action,
-		Payload:           r.Payload,
-		ProcessingStarted: r.ProcessingStarted,
-		ProcessingEnded:   r.ProcessingEnded,
-		Result:            r.Result,
-		Error:             r.Error,
-		Progress:          r.Progress,
-		ProgressMessage:   r.ProgressMessage,
-		ProgressDetails:   r.ProgressDetails,
-		RetryCount:        r.RetryCount,
-		MaxRetries:        r.MaxRetries,
-		RetryAfter:        r.RetryAfter,
-		UserID:            r.UserID,
-		TenantID:          r.TenantID,
-		TTL:               r.TTL,
+		RequestID:          r.RequestID,
+		ConnectionID:       r.ConnectionID,
+		Status:             r.Status,
+		CreatedAt:          r.CreatedAt,
+		Action:             r.Action,
+		Payload:            r.Payload,
+		ProcessingStarted:  r.ProcessingStarted,
+		ProcessingEnded:    r.ProcessingEnded,
+		Result:             r.Result,
+		Error:              r.Error,
+		Progress:           r.Progress,
+		ProgressMessage:    r.ProgressMessage,
+		ProgressDetails:    r.ProgressDetails,
+		RetryCount:         r.RetryCount,
+		MaxRetries:         r.MaxRetries,
+		RetryAfter:         r.RetryAfter,
+		UserID:             r.UserID,
+		TenantID:           r.TenantID,
+		Permissions:        r.Permissions,
+		CallbackURL:        r.CallbackURL,
+		CallbackSecret:     r.CallbackSecret,
+		CallbackDeliveries: decodeDeliveries(r.CallbackDeliveries),
+		Version:            r.Version,
+		TTL:                r.TTL,
 	}
 }
 
@@ -162,10 +237,40 @@ func (r *AsyncRequest) FromStoreModel(req *store.AsyncRequest) {
 	r.RetryAfter = req.RetryAfter
 	r.UserID = req.UserID
 	r.TenantID = req.TenantID
+	r.Permissions = req.Permissions
+	r.CallbackURL = req.CallbackURL
+	r.CallbackSecret = req.CallbackSecret
+	r.CallbackDeliveries, _ = encodeDeliveries(req.CallbackDeliveries)
+	r.Version = req.Version
 	r.TTL = req.TTL
 	r.SetKeys()
 }
 
+// encodeDeliveries encodes a callback delivery log, leaving an empty log empty
+func encodeDeliveries(deliveries []store.CallbackDelivery) (string, error) {
+	if len(deliveries) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(deliveries)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decodeDeliveries decodes a callback delivery log. The log is only a record
+// of past attempts, so one that cannot be decoded reads as empty.
+func decodeDeliveries(encoded string) []store.CallbackDelivery {
+	if encoded == "" {
+		return nil
+	}
+	var deliveries []store.CallbackDelivery
+	if err := json.Unmarshal([]byte(encoded), &deliveries); err != nil {
+		return nil
+	}
+	return deliveries
+}
+
 // Subscription represents a real-time update subscription with DynamORM
 type Subscription struct {
 	// DynamORM composite key pattern
@@ -217,3 +322,263 @@ func (s *Subscription) FromStoreModel(sub *store.Subscription) {
 	s.TTL = sub.TTL
 	s.SetKeys()
 }
+
+// RateLimitBucket represents a rate limit token bucket with DynamORM
+type RateLimitBucket struct {
+	// DynamORM composite key pattern
+	PK string `dynamorm:"pk"`
+	SK string `dynamorm:"sk"`
+
+	// Bucket state
+	Key       string    `dynamorm:"key"`
+	Tokens    float64   `dynamorm:"tokens"`
+	UpdatedAt time.Time `dynamorm:"updated_at"`
+	Version   int64     `dynamorm:"version"`
+
+	// TTL for automatic cleanup
+	TTL int64 `dynamorm:"ttl,omitempty"`
+}
+
+// TableName returns the DynamoDB table name
+func (b *RateLimitBucket) TableName() string {
+	return store.RateLimitsTable
+}
+
+// SetKeys sets the composite keys for the bucket
+func (b *RateLimitBucket) SetKeys() {
+	b.PK = fmt.Sprintf("BUCKET#%s", b.Key)
+	b.SK = "STATE"
+}
+
+// ToStoreModel converts to the store.RateLimitBucket model
+func (b *RateLimitBucket) ToStoreModel() *store.RateLimitBucket {
+	return &store.RateLimitBucket{
+		Key:       b.Key,
+		Tokens:    b.Tokens,
+		UpdatedAt: b.UpdatedAt,
+		Version:   b.Version,
+		TTL:       b.TTL,
+	}
+}
+
+// FromStoreModel converts from the store.RateLimitBucket model
+func (b *RateLimitBucket) FromStoreModel(bucket *store.RateLimitBucket) {
+	b.Key = bucket.Key
+	b.Tokens = bucket.Tokens
+	b.UpdatedAt = bucket.UpdatedAt
+	b.Version = bucket.Version
+	b.TTL = bucket.TTL
+	b.SetKeys()
+}
+
+// ConcurrencyCounter holds the leases on the slots of a concurrency cap.
+// Running counts the leases, and every write changes both together, so the
+// cap is checked and a slot taken in one conditional write.
+// Counters share the rate limits table with the token buckets.
+type ConcurrencyCounter struct {
+	// DynamORM composite key pattern
+	PK string `dynamorm:"pk"`
+	SK string `dynamorm:"sk"`
+
+	// Counter state, changed only by conditional updates
+	Key       string           `dynamorm:"key"`
+	Running   int              `dynamorm:"running"`
+	Leases    map[string]int64 `dynamorm:"leases"` // lease ID to expiry in Unix seconds
+	UpdatedAt time.Time        `dynamorm:"updated_at"`
+}
+
+// TableName returns the DynamoDB table name
+func (c *ConcurrencyCounter) TableName() string {
+	return store.RateLimitsTable
+}
+
+// SetKeys sets the composite keys for the counter
+func (c *ConcurrencyCounter) SetKeys() {
+	c.PK = fmt.Sprintf("COUNTER#%s", c.Key)
+	c.SK = "LEASES"
+}
+
+// TenantQuota represents a tenant's async quota with DynamORM
+type TenantQuota struct {
+	// DynamORM composite key pattern
+	PK string `dynamorm:"pk"`
+	SK string `dynamorm:"sk"`
+
+	// Quota data
+	TenantID      string    `dynamorm:"tenant_id"`
+	Action        string    `dynamorm:"action"`
+	MaxActive     int       `dynamorm:"max_active"`
+	MaxConcurrent int       `dynamorm:"max_concurrent"`
+	UpdatedAt     time.Time `dynamorm:"updated_at"`
+	UpdatedBy     string    `dynamorm:"updated_by,omitempty"`
+}
+
+// TableName returns the DynamoDB table name
+func (q *TenantQuota) TableName() string {
+	return store.TenantQuotasTable
+}
+
+// SetKeys sets the composite keys for the quota
+func (q *TenantQuota) SetKeys() {
+	q.PK = fmt.Sprintf("TENANT#%s", q.TenantID)
+	q.SK = fmt.Sprintf("QUOTA#%s", q.Action)
+}
+
+// ToStoreModel converts to the store.TenantQuota model
+func (q *TenantQuota) ToStoreModel() *store.TenantQuota {
+	return &store.TenantQuota{
+		TenantID:      q.TenantID,
+		Action:        q.Action,
+		MaxActive:     q.MaxActive,
+		MaxConcurrent: q.MaxConcurrent,
+		UpdatedAt:     q.UpdatedAt,
+		UpdatedBy:     q.UpdatedBy,
+	}
+}
+
+// FromStoreModel converts from the store.TenantQuota model
+func (q *TenantQuota) FromStoreModel(quota *store.TenantQuota) {
+	q.TenantID = quota.TenantID
+	q.Action = quota.Action
+	q.MaxActive = quota.MaxActive
+	q.MaxConcurrent = quota.MaxConcurrent
+	q.UpdatedAt = quota.UpdatedAt
+	q.UpdatedBy = quota.UpdatedBy
+	q.SetKeys()
+}
+
+// deliveryDueShard is the due-index partition shared by all pending deliveries
+const deliveryDueShard = "PENDING"
+
+// PendingDelivery represents an unacked message with DynamORM
+type PendingDelivery struct {
+	// DynamORM composite key pattern
+	PK string `dynamorm:"pk"`
+	SK string `dynamorm:"sk"`
+
+	// Delivery data
+	Recipient    string `dynamorm:"recipient"`
+	DeliveryID   string `dynamorm:"delivery_id"`
+	ConnectionID string `dynamorm:"connection_id"`
+	UserID       string `dynamorm:"user_id,omitempty"`
+	TenantID     string `dynamorm:"tenant_id,omitempty"`
+	Message      string `dynamorm:"message"`
+
+	// Redelivery schedule, queried through the due index
+	Attempts      int       `dynamorm:"attempts"`
+	CreatedAt     time.Time `dynamorm:"created_at"`
+	DueShard      string    `dynamorm:"due_shard" dynamorm-index:"due-index,pk"`
+	NextAttemptAt time.Time `dynamorm:"next_attempt_at" dynamorm-index:"due-index,sk"`
+
+	// TTL for automatic cleanup
+	TTL int64 `dynamorm:"ttl,omitempty"`
+}
+
+// TableName returns the DynamoDB table name
+func (d *PendingDelivery) TableName() string {
+	return store.DeliveriesTable
+}
+
+// SetKeys sets the composite keys for the delivery
+func (d *PendingDelivery) SetKeys() {
+	d.PK = d.Recipient
+	d.SK = fmt.Sprintf("DELIVERY#%s", d.DeliveryID)
+	d.DueShard = deliveryDueShard
+}
+
+// ToStoreModel converts to the store.PendingDelivery model
+func (d *PendingDelivery) ToStoreModel() *store.PendingDelivery {
+	return &store.PendingDelivery{
+		Recipient:     d.Recipient,
+		DeliveryID:    d.DeliveryID,
+		ConnectionID:  d.ConnectionID,
+		UserID:        d.UserID,
+		TenantID:      d.TenantID,
+		Message:       []byte(d.Message),
+		Attempts:      d.Attempts,
+		CreatedAt:     d.CreatedAt,
+		NextAttemptAt: d.NextAttemptAt,
+		TTL:           d.TTL,
+	}
+}
+
+// FromStoreModel converts from the store.PendingDelivery model
+func (d *PendingDelivery) FromStoreModel(delivery *store.PendingDelivery) {
+	d.Recipient = delivery.Recipient
+	d.DeliveryID = delivery.DeliveryID
+	d.ConnectionID = delivery.ConnectionID
+	d.UserID = delivery.UserID
+	d.TenantID = delivery.TenantID
+	d.Message = string(delivery.Message)
+	d.Attempts = delivery.Attempts
+	d.CreatedAt = delivery.CreatedAt
+	d.NextAttemptAt = delivery.NextAttemptAt
+	d.TTL = delivery.TTL
+	d.SetKeys()
+}
+
+// callbackDueShard is the due-index partition of pending callbacks. They
+// share the deliveries table with pending deliveries but not their partition,
+// so neither due list returns the other's items.
+const callbackDueShard = "CALLBACK"
+
+// PendingCallback represents a scheduled callback delivery with DynamORM
+type PendingCallback struct {
+	// DynamORM composite key pattern
+	PK string `dynamorm:"pk"`
+	SK string `dynamorm:"sk"`
+
+	// Callback data
+	RequestID string `dynamorm:"request_id"`
+
+	// Delivery schedule, queried through the due index
+	Attempts      int       `dynamorm:"attempts"`
+	CreatedAt     time.Time `dynamorm:"created_at"`
+	DueShard      string    `dynamorm:"due_shard" dynamorm-index:"due-index,pk"`
+	NextAttemptAt time.Time `dynamorm:"next_attempt_at" dynamorm-index:"due-index,sk"`
+
+	// TTL for automatic cleanup
+	TTL int64 `dynamorm:"ttl,omitempty"`
+}
+
+// TableName returns the DynamoDB table name
+func (c *PendingCallback) TableName() string {
+	return store.DeliveriesTable
+}
+
+// SetKeys sets the composite keys for the callback
+func (c *PendingCallback) SetKeys() {
+	c.PK = fmt.Sprintf("CALLBACK#%s", c.RequestID)
+	c.SK = "CALLBACK"
+	c.DueShard = callbackDueShard
+}
+
+// ToStoreModel converts to the store.PendingCallback model
+func (c *PendingCallback) ToStoreModel() *store.PendingCallback {
+	return &store.PendingCallback{
+		RequestID:     c.RequestID,
+		Attempts:      c.Attempts,
+		CreatedAt:     c.CreatedAt,
+		NextAttemptAt: c.NextAttemptAt,
+		TTL:           c.TTL,
+	}
+}
+
+// FromStoreModel converts from the store.PendingCallback model
+func (c *PendingCallback) FromStoreModel(callback *store.PendingCallback) {
+	c.RequestID = callback.RequestID
+	c.Attempts = callback.Attempts
+	c.CreatedAt = callback.CreatedAt
+	c.NextAttemptAt = callback.NextAttemptAt
+	c.TTL = callback.TTL
+	c.SetKeys()
+}
+
+// unixNanos converts a time to Unix nanoseconds for a page cursor; the zero
+// time is 0
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
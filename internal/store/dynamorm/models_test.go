@@ -63,6 +63,8 @@ func TestConnection_ToStoreModel(t *testing.T) {
 		LastPing:     now.Add(5 * time.Minute),
 		Metadata:     map[string]string{"client": "web"},
 		TTL:          now.Add(24 * time.Hour).Unix(),
+
+		TokenExpiresAt: now.Add(time.Hour),
 	}
 
 	storeModel := conn.ToStoreModel()
@@ -74,6 +76,7 @@ func TestConnection_ToStoreModel(t *testing.T) {
 	assert.Equal(t, now, storeModel.ConnectedAt)
 	assert.Equal(t, now.Add(5*time.Minute), storeModel.LastPing)
 	assert.Equal(t, map[string]string{"client": "web"}, storeModel.Metadata)
+	assert.Equal(t, now.Add(time.Hour), storeModel.TokenExpiresAt)
 	assert.Equal(t, now.Add(24*time.Hour).Unix(), storeModel.TTL)
 }
 
@@ -89,6 +92,8 @@ func TestConnection_FromStoreModel(t *testing.T) {
 		LastPing:     now.Add(5 * time.Minute),
 		Metadata:     map[string]string{"client": "web", "version": "1.0"},
 		TTL:          now.Add(24 * time.Hour).Unix(),
+
+		TokenExpiresAt: now.Add(time.Hour),
 	}
 
 	conn := &dynamorm.Connection{}
@@ -103,16 +108,21 @@ func TestConnection_FromStoreModel(t *testing.T) {
 	assert.Equal(t, now, conn.ConnectedAt)
 	assert.Equal(t, now.Add(5*time.Minute), conn.LastPing)
 	assert.Equal(t, map[string]string{"client": "web", "version": "1.0"}, conn.Metadata)
+	assert.Equal(t, now.Add(time.Hour), conn.TokenExpiresAt)
 	assert.Equal(t, now.Add(24*time.Hour).Unix(), conn.TTL)
 }
 
 // TestAsyncRequest_TableName tests the TableName method
 func TestAsyncRequest_TableName(t *testing.T) {
 	req := &dynamorm.AsyncRequest{}
-	assert.Equal(t, store.RequestsTable, req.TableName())
+	assert.Equal(t, store.RequestsV2Table, req.TableName())
+
+	legacy := &dynamorm.LegacyAsyncRequest{}
+	assert.Equal(t, store.RequestsTable, legacy.TableName())
 }
 
-// TestAsyncRequest_SetKeys tests the SetKeys method
+// TestAsyncRequest_SetKeys tests the SetKeys method. The keys do not depend on
+// the status, so a request keeps them as its status changes.
 func TestAsyncRequest_SetKeys(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -126,28 +136,28 @@ func TestAsyncRequest_SetKeys(t *testing.T) {
 			requestID:  "req123",
 			status:     store.StatusPending,
 			expectedPK: "REQ#req123",
-			expectedSK: "STATUS#PENDING",
+			expectedSK: "METADATA",
 		},
 		{
 			name:       "processing request",
 			requestID:  "req456",
 			status:     store.StatusProcessing,
 			expectedPK: "REQ#req456",
-			expectedSK: "STATUS#PROCESSING",
+			expectedSK: "METADATA",
 		},
 		{
 			name:       "completed request",
 			requestID:  "req789",
 			status:     store.StatusCompleted,
 			expectedPK: "REQ#req789",
-			expectedSK: "STATUS#COMPLETED",
+			expectedSK: "METADATA",
 		},
 		{
 			name:       "failed request",
 			requestID:  "req000",
 			status:     store.StatusFailed,
 			expectedPK: "REQ#req000",
-			expectedSK: "STATUS#FAILED",
+			expectedSK: "METADATA",
 		},
 	}
 
@@ -172,7 +182,7 @@ func TestAsyncRequest_ToStoreModel(t *testing.T) {
 
 	req := &dynamorm.AsyncRequest{
 		PK:                "REQ#req123",
-		SK:                "STATUS#PROCESSING",
+		SK:                "METADATA",
 		RequestID:         "req123",
 		ConnectionID:      "conn123",
 		Status:            store.StatusProcessing,
@@ -245,7 +255,7 @@ func TestAsyncRequest_FromStoreModel(t *testing.T) {
 	req.FromStoreModel(storeReq)
 
 	assert.Equal(t, "REQ#req123", req.PK)
-	assert.Equal(t, "STATUS#PROCESSING", req.SK)
+	assert.Equal(t, "METADATA", req.SK)
 	assert.Equal(t, "req123", req.RequestID)
 	assert.Equal(t, "conn123", req.ConnectionID)
 	assert.Equal(t, store.StatusProcessing, req.Status)
@@ -266,6 +276,38 @@ func TestAsyncRequest_FromStoreModel(t *testing.T) {
 	assert.Equal(t, now.Add(7*24*time.Hour).Unix(), req.TTL)
 }
 
+// TestAsyncRequest_CallbackRoundTrip tests that the callback fields and delivery log survive conversion
+func TestAsyncRequest_CallbackRoundTrip(t *testing.T) {
+	attemptedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	storeReq := &store.AsyncRequest{
+		RequestID:      "req123",
+		CallbackURL:    "https://example.com/hooks",
+		CallbackSecret: "s3cret",
+		CallbackDeliveries: []store.CallbackDelivery{
+			{Attempt: 1, Status: store.StatusCompleted, AttemptedAt: attemptedAt, Error: "connection refused"},
+			{Attempt: 2, Status: store.StatusCompleted, AttemptedAt: attemptedAt.Add(time.Second), StatusCode: 204, Delivered: true},
+		},
+	}
+
+	req := &dynamorm.AsyncRequest{}
+	req.FromStoreModel(storeReq)
+
+	assert.Equal(t, "https://example.com/hooks", req.CallbackURL)
+	assert.Equal(t, "s3cret", req.CallbackSecret)
+	assert.Contains(t, req.CallbackDeliveries, `"statusCode":204`)
+
+	got := req.ToStoreModel()
+	assert.Equal(t, storeReq.CallbackURL, got.CallbackURL)
+	assert.Equal(t, storeReq.CallbackSecret, got.CallbackSecret)
+	assert.Equal(t, storeReq.CallbackDeliveries, got.CallbackDeliveries)
+
+	// A request without deliveries stores no log
+	empty := &dynamorm.AsyncRequest{}
+	empty.FromStoreModel(&store.AsyncRequest{RequestID: "req456"})
+	assert.Empty(t, empty.CallbackDeliveries)
+	assert.Nil(t, empty.ToStoreModel().CallbackDeliveries)
+}
+
 // TestSubscription_TableName tests the TableName method
 func TestSubscription_TableName(t *testing.T) {
 	sub := &dynamorm.Subscription{}
@@ -370,3 +412,75 @@ func TestSubscription_FromStoreModel(t *testing.T) {
 	assert.Equal(t, now, sub.CreatedAt)
 	assert.Equal(t, now.Add(30*24*time.Hour).Unix(), sub.TTL)
 }
+
+// TestRateLimitBucket_TableName tests the TableName method
+func TestRateLimitBucket_TableName(t *testing.T) {
+	bucket := &dynamorm.RateLimitBucket{}
+	assert.Equal(t, store.RateLimitsTable, bucket.TableName())
+}
+
+// TestRateLimitBucket_StoreModelRoundTrip tests conversion to and from the store model
+func TestRateLimitBucket_StoreModelRoundTrip(t *testing.T) {
+	now := time.Now()
+	storeBucket := &store.RateLimitBucket{
+		Key:       "tenant#acme#generate_report",
+		Tokens:    2.5,
+		UpdatedAt: now,
+		Version:   7,
+		TTL:       now.Add(time.Hour).Unix(),
+	}
+
+	bucket := &dynamorm.RateLimitBucket{}
+	bucket.FromStoreModel(storeBucket)
+
+	assert.Equal(t, "BUCKET#tenant#acme#generate_report", bucket.PK)
+	assert.Equal(t, "STATE", bucket.SK)
+	assert.Equal(t, storeBucket, bucket.ToStoreModel())
+}
+
+// TestTenantQuota_StoreModelRoundTrip tests conversion to and from the store model
+func TestTenantQuota_StoreModelRoundTrip(t *testing.T) {
+	now := time.Now()
+	storeQuota := &store.TenantQuota{
+		TenantID:      "tenant-abc",
+		Action:        store.QuotaAllActions,
+		MaxActive:     50,
+		MaxConcurrent: 5,
+		UpdatedAt:     now,
+		UpdatedBy:     "admin-1",
+	}
+
+	quota := &dynamorm.TenantQuota{}
+	quota.FromStoreModel(storeQuota)
+
+	assert.Equal(t, store.TenantQuotasTable, quota.TableName())
+	assert.Equal(t, "TENANT#tenant-abc", quota.PK)
+	assert.Equal(t, "QUOTA#*", quota.SK)
+	assert.Equal(t, storeQuota, quota.ToStoreModel())
+}
+
+// TestPendingDelivery_StoreModelRoundTrip tests conversion to and from the store model
+func TestPendingDelivery_StoreModelRoundTrip(t *testing.T) {
+	now := time.Now()
+	storeDelivery := &store.PendingDelivery{
+		Recipient:     "USER#tenant-abc#user-1",
+		DeliveryID:    "dlv-123",
+		ConnectionID:  "conn-123",
+		UserID:        "user-1",
+		TenantID:      "tenant-abc",
+		Message:       []byte(`{"type":"complete"}`),
+		Attempts:      2,
+		CreatedAt:     now,
+		NextAttemptAt: now.Add(30 * time.Second),
+		TTL:           now.Add(24 * time.Hour).Unix(),
+	}
+
+	delivery := &dynamorm.PendingDelivery{}
+	delivery.FromStoreModel(storeDelivery)
+
+	assert.Equal(t, store.DeliveriesTable, delivery.TableName())
+	assert.Equal(t, "USER#tenant-abc#user-1", delivery.PK)
+	assert.Equal(t, "DELIVERY#dlv-123", delivery.SK)
+	assert.Equal(t, "PENDING", delivery.DueShard)
+	assert.Equal(t, storeDelivery, delivery.ToStoreModel())
+}
@@ -0,0 +1,37 @@
+package dynamorm
+
+import (
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormQuery "github.com/pay-theory/dynamorm/pkg/query"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// validatePageToken rejects a NextToken that is not a DynamORM cursor before
+// it is sent to DynamoDB
+func validatePageToken(opts store.PageOptions) error {
+	if opts.NextToken == "" {
+		return nil
+	}
+	cursor, err := dynamormQuery.DecodeCursor(opts.NextToken)
+	if err != nil || len(cursor.LastEvaluatedKey) == 0 {
+		return store.NewValidationError("NextToken", "is not a valid page token")
+	}
+	return nil
+}
+
+// queryPage reads the page of query's results that opts selects into dest
+// and returns the token of the next page. The page token is DynamoDB's
+// LastEvaluatedKey, so a page costs one request however long the list.
+// DynamoDB applies the limit before any filter, so a filtered page may hold
+// fewer items than the limit, or none, and still not be the last.
+func queryPage(query core.Query, opts store.PageOptions, dest any) (string, error) {
+	if opts.NextToken != "" {
+		query = query.Cursor(opts.NextToken)
+	}
+
+	result, err := query.Limit(opts.PageSize()).AllPaginated(dest)
+	if err != nil {
+		return "", err
+	}
+	return result.NextCursor, nil
+}
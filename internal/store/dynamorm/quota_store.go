@@ -0,0 +1,130 @@
+package dynamorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// quotaStore implements QuotaStore using DynamORM
+type quotaStore struct {
+	db core.DB
+}
+
+// NewQuotaStore creates a new DynamORM-backed tenant quota store
+func NewQuotaStore(db core.DB) store.QuotaStore {
+	return &quotaStore{
+		db: db,
+	}
+}
+
+// Get retrieves the quota for a tenant and action
+func (s *quotaStore) Get(ctx context.Context, tenantID, action string) (*store.TenantQuota, error) {
+	if tenantID == "" {
+		return nil, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	if action == "" {
+		return nil, store.NewValidationError("action", "cannot be empty")
+	}
+
+	quota := &TenantQuota{TenantID: tenantID, Action: action}
+	quota.SetKeys()
+
+	if err := s.db.Model(quota).
+		Where("pk", "=", quota.PK).
+		Where("sk", "=", quota.SK).
+		First(quota); err != nil {
+		if err.Error() == "item not found" {
+			return nil, store.NewStoreError("Get", quota.TableName(), quota.PK, store.ErrNotFound)
+		}
+		return nil, store.NewStoreError("Get", quota.TableName(), quota.PK, fmt.Errorf("failed to get quota: %w", err))
+	}
+
+	return quota.ToStoreModel(), nil
+}
+
+// Save creates or replaces a quota
+func (s *quotaStore) Save(ctx context.Context, quota *store.TenantQuota) error {
+	if err := s.validateQuota(quota); err != nil {
+		return err
+	}
+
+	if quota.UpdatedAt.IsZero() {
+		quota.UpdatedAt = time.Now()
+	}
+
+	dynamormQuota := &TenantQuota{}
+	dynamormQuota.FromStoreModel(quota)
+
+	if err := s.db.Model(dynamormQuota).CreateOrUpdate(); err != nil {
+		return store.NewStoreError("Save", dynamormQuota.TableName(), dynamormQuota.PK, fmt.Errorf("failed to save quota: %w", err))
+	}
+
+	return nil
+}
+
+// Delete removes a quota
+func (s *quotaStore) Delete(ctx context.Context, tenantID, action string) error {
+	if tenantID == "" {
+		return store.NewValidationError("tenantID", "cannot be empty")
+	}
+	if action == "" {
+		return store.NewValidationError("action", "cannot be empty")
+	}
+
+	quota := &TenantQuota{TenantID: tenantID, Action: action}
+	quota.SetKeys()
+
+	if err := s.db.Model(quota).Delete(); err != nil {
+		return store.NewStoreError("Delete", quota.TableName(), quota.PK, fmt.Errorf("failed to delete quota: %w", err))
+	}
+
+	return nil
+}
+
+// ListByTenant returns all quotas for a tenant
+func (s *quotaStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.TenantQuota, error) {
+	if tenantID == "" {
+		return nil, store.NewValidationError("tenantID", "cannot be empty")
+	}
+
+	key := &TenantQuota{TenantID: tenantID}
+	key.SetKeys()
+
+	var quotas []TenantQuota
+	if err := s.db.Model(&TenantQuota{}).
+		Where("pk", "=", key.PK).
+		All(&quotas); err != nil {
+		return nil, store.NewStoreError("ListByTenant", store.TenantQuotasTable, tenantID, fmt.Errorf("failed to list quotas: %w", err))
+	}
+
+	result := make([]*store.TenantQuota, len(quotas))
+	for i := range quotas {
+		result[i] = quotas[i].ToStoreModel()
+	}
+
+	return result, nil
+}
+
+// validateQuota validates a quota before saving
+func (s *quotaStore) validateQuota(quota *store.TenantQuota) error {
+	if quota == nil {
+		return store.NewValidationError("quota", "cannot be nil")
+	}
+	if quota.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	if quota.Action == "" {
+		return store.NewValidationError("Action", "cannot be empty")
+	}
+	if quota.MaxActive < 0 {
+		return store.NewValidationError("MaxActive", "cannot be negative")
+	}
+	if quota.MaxConcurrent < 0 {
+		return store.NewValidationError("MaxConcurrent", "cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestQuotaStore_Get tests the Get method
+func TestQuotaStore_Get(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		tenantID  string
+		action    string
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantErr   bool
+		notFound  bool
+		errMsg    string
+	}{
+		{
+			name:     "successful get",
+			tenantID: "tenant-abc",
+			action:   store.QuotaAllActions,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+				mockQuery.On("Where", "pk", "=", "TENANT#tenant-abc").Return(mockQuery)
+				mockQuery.On("Where", "sk", "=", "QUOTA#*").Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.TenantQuota")).Run(func(args mock.Arguments) {
+					quota := args.Get(0).(*dynamorm.TenantQuota)
+					quota.MaxActive = 10
+					quota.MaxConcurrent = 2
+				}).Return(nil)
+			},
+		},
+		{
+			name:     "not found",
+			tenantID: "tenant-abc",
+			action:   "process_data",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(errors.New("item not found"))
+			},
+			wantErr:  true,
+			notFound: true,
+		},
+		{
+			name:     "dynamodb error",
+			tenantID: "tenant-abc",
+			action:   "process_data",
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+			errMsg:  "failed to get quota",
+		},
+		{
+			name:     "empty tenant",
+			tenantID: "",
+			action:   "process_data",
+			wantErr:  true,
+			errMsg:   "cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery)
+			}
+
+			quotaStore := dynamorm.NewQuotaStore(mockDB)
+			quota, err := quotaStore.Get(ctx, tt.tenantID, tt.action)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, tt.notFound, store.IsNotFound(err))
+				if tt.errMsg != "" {
+					assert.Contains(t, err.Error(), tt.errMsg)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.tenantID, quota.TenantID)
+				assert.Equal(t, 10, quota.MaxActive)
+				assert.Equal(t, 2, quota.MaxConcurrent)
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
+
+// TestQuotaStore_Save tests the Save method
+func TestQuotaStore_Save(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		quota     *store.TenantQuota
+		setupMock func(*mocks.MockDB, *mocks.MockQuery)
+		wantErr   bool
+		errMsg    string
+	}{
+		{
+			name:  "successful save",
+			quota: &store.TenantQuota{TenantID: "tenant-abc", Action: "process_data", MaxActive: 20},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(nil)
+			},
+		},
+		{
+			name:  "dynamodb error",
+			quota: &store.TenantQuota{TenantID: "tenant-abc", Action: "process_data", MaxActive: 20},
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+				mockQuery.On("CreateOrUpdate").Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+			errMsg:  "failed to save quota",
+		},
+		{
+			name:    "nil quota",
+			quota:   nil,
+			wantErr: true,
+			errMsg:  "cannot be nil",
+		},
+		{
+			name:    "missing action",
+			quota:   &store.TenantQuota{TenantID: "tenant-abc"},
+			wantErr: true,
+			errMsg:  "Action",
+		},
+		{
+			name:    "negative limit",
+			quota:   &store.TenantQuota{TenantID: "tenant-abc", Action: "*", MaxConcurrent: -1},
+			wantErr: true,
+			errMsg:  "cannot be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery)
+			}
+
+			err := dynamorm.NewQuotaStore(mockDB).Save(ctx, tt.quota)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, tt.quota.UpdatedAt.IsZero())
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+		})
+	}
+}
+
+// TestQuotaStore_DeleteAndList tests the Delete and ListByTenant methods
+func TestQuotaStore_DeleteAndList(t *testing.T) {
+	ctx := context.Background()
+	mockDB := new(mocks.MockDB)
+	mockQuery := new(mocks.MockQuery)
+
+	mockDB.On("Model", mock.AnythingOfType("*dynamorm.TenantQuota")).Return(mockQuery)
+	mockQuery.On("Delete").Return(nil)
+	mockQuery.On("Where", "pk", "=", "TENANT#tenant-abc").Return(mockQuery)
+	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.TenantQuota")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*[]dynamorm.TenantQuota)
+		*dest = []dynamorm.TenantQuota{
+			{TenantID: "tenant-abc", Action: "*", MaxActive: 100},
+			{TenantID: "tenant-abc", Action: "process_data", MaxConcurrent: 3},
+		}
+	}).Return(nil)
+
+	quotaStore := dynamorm.NewQuotaStore(mockDB)
+
+	assert.NoError(t, quotaStore.Delete(ctx, "tenant-abc", "process_data"))
+
+	quotas, err := quotaStore.ListByTenant(ctx, "tenant-abc")
+	assert.NoError(t, err)
+	assert.Len(t, quotas, 2)
+	assert.Equal(t, 100, quotas[0].MaxActive)
+	assert.Equal(t, 3, quotas[1].MaxConcurrent)
+
+	assert.Error(t, quotaStore.Delete(ctx, "tenant-abc", ""))
+	_, err = quotaStore.ListByTenant(ctx, "")
+	assert.Error(t, err)
+
+	mockDB.AssertExpectations(t)
+	mockQuery.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+package dynamorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// maxRateLimitAttempts bounds the optimistic locking retries for a single Take
+const maxRateLimitAttempts = 3
+
+// rateLimitStore implements RateLimitStore using DynamORM
+type rateLimitStore struct {
+	db core.DB
+}
+
+// NewRateLimitStore creates a new DynamORM-backed rate limit store
+func NewRateLimitStore(db core.DB) store.RateLimitStore {
+	return &rateLimitStore{
+		db: db,
+	}
+}
+
+// Take consumes a token from the bucket
+func (s *rateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	var (
+		allowed    bool
+		retryAfter time.Duration
+	)
+	err := s.update(ctx, "Take", key, rate, burst, func(bucket *store.RateLimitBucket, now time.Time) bool {
+		// A denied take consumes nothing, so there is no state to persist
+		allowed, retryAfter = bucket.Take(now, rate, burst)
+		return allowed
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, nil
+}
+
+// Refund gives a token back to the bucket
+func (s *rateLimitStore) Refund(ctx context.Context, key string, rate float64, burst int) error {
+	return s.update(ctx, "Refund", key, rate, burst, func(bucket *store.RateLimitBucket, now time.Time) bool {
+		// A bucket that was never written is already full
+		if bucket.UpdatedAt.IsZero() {
+			return false
+		}
+		bucket.Refund(now, rate, burst)
+		return true
+	})
+}
+
+// bucketChange changes a bucket as of now and reports whether there is anything to write
+type bucketChange func(bucket *store.RateLimitBucket, now time.Time) bool
+
+// update reads a bucket, changes it and writes it back with a version
+// condition, so concurrent Lambdas cannot double spend
+func (s *rateLimitStore) update(ctx context.Context, op, key string, rate float64, burst int, change bucketChange) error {
+	if key == "" {
+		return store.NewValidationError("key", "cannot be empty")
+	}
+	if rate <= 0 {
+		return store.NewValidationError("rate", "must be positive")
+	}
+	if burst < 1 {
+		return store.NewValidationError("burst", "must be at least 1")
+	}
+
+	for attempt := 0; attempt < maxRateLimitAttempts; attempt++ {
+		bucket := &RateLimitBucket{Key: key}
+		bucket.SetKeys()
+
+		exists := true
+		if err := s.db.Model(bucket).
+			Where("pk", "=", bucket.PK).
+			Where("sk", "=", bucket.SK).
+			First(bucket); err != nil {
+			if err.Error() != "item not found" {
+				return store.NewStoreError(op, bucket.TableName(), key, fmt.Errorf("failed to get bucket: %w", err))
+			}
+			exists = false
+			bucket = &RateLimitBucket{Key: key}
+		}
+
+		state := bucket.ToStoreModel()
+		expectedVersion := state.Version
+
+		now := time.Now()
+		if !change(state, now) {
+			return nil
+		}
+
+		// Keep idle buckets around until they would have refilled completely
+		refill := time.Duration(float64(burst) / rate * float64(time.Second))
+		state.TTL = now.Add(refill + time.Hour).Unix()
+		state.Version = expectedVersion + 1
+		bucket.FromStoreModel(state)
+
+		var err error
+		if !exists {
+			// Create fails if another instance created the bucket first
+			err = s.db.Model(bucket).Create()
+		} else {
+			err = s.db.Model(bucket).
+				Where("pk", "=", bucket.PK).
+				Where("sk", "=", bucket.SK).
+				UpdateBuilder().
+				Set("tokens", bucket.Tokens).
+				Set("updated_at", bucket.UpdatedAt).
+				Set("version", bucket.Version).
+				Set("ttl", bucket.TTL).
+				Condition("version", "=", expectedVersion).
+				Execute()
+		}
+
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return store.NewStoreError(op, bucket.TableName(), key, fmt.Errorf("failed to update bucket: %w", err))
+		}
+	}
+
+	return store.NewStoreError(op, store.RateLimitsTable, key, store.ErrConcurrentModification)
+}
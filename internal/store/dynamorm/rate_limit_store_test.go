@@ -0,0 +1,252 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// existingBucket populates the bucket passed to First with the given state
+func existingBucket(tokens float64, updatedAt time.Time, version int64) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		bucket := args.Get(0).(*dynamorm.RateLimitBucket)
+		bucket.Tokens = tokens
+		bucket.UpdatedAt = updatedAt
+		bucket.Version = version
+	}
+}
+
+// TestRateLimitStore_Take tests the Take method
+func TestRateLimitStore_Take(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		key           string
+		rate          float64
+		burst         int
+		setupMock     func(*mocks.MockDB, *mocks.MockQuery, *mocks.MockUpdateBuilder)
+		expectAllowed bool
+		expectWait    bool
+		wantErr       bool
+		errMsg        string
+	}{
+		{
+			name:  "new bucket is created",
+			key:   "user#123#*",
+			rate:  1,
+			burst: 5,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", "pk", "=", "BUCKET#user#123#*").Return(mockQuery)
+				mockQuery.On("Where", "sk", "=", "STATE").Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(errors.New("item not found"))
+				mockQuery.On("Create").Return(nil)
+			},
+			expectAllowed: true,
+		},
+		{
+			name:  "existing bucket is updated with version condition",
+			key:   "user#123#*",
+			rate:  1,
+			burst: 5,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).
+					Run(existingBucket(3, time.Now(), 4)).Return(nil)
+				mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "tokens", mock.AnythingOfType("float64")).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "updated_at", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "version", int64(5)).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", "ttl", mock.AnythingOfType("int64")).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Condition", "version", "=", int64(4)).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Execute").Return(nil)
+			},
+			expectAllowed: true,
+		},
+		{
+			name:  "empty bucket is denied without a write",
+			key:   "tenant#acme#*",
+			rate:  0.5,
+			burst: 2,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).
+					Run(existingBucket(0, time.Now(), 9)).Return(nil)
+			},
+			expectAllowed: false,
+			expectWait:    true,
+		},
+		{
+			name:  "conflicting write is retried",
+			key:   "conn#abc#*",
+			rate:  10,
+			burst: 10,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(errors.New("item not found")).Once()
+				mockQuery.On("Create").Return(dynamormErrors.ErrConditionFailed).Once()
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).
+					Run(existingBucket(9, time.Now(), 1)).Return(nil).Once()
+				mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Set", mock.Anything, mock.Anything).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Condition", "version", "=", int64(1)).Return(mockUpdateBuilder)
+				mockUpdateBuilder.On("Execute").Return(nil)
+			},
+			expectAllowed: true,
+		},
+		{
+			name:  "persistent conflicts give up",
+			key:   "conn#abc#*",
+			rate:  10,
+			burst: 10,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(errors.New("item not found"))
+				mockQuery.On("Create").Return(dynamormErrors.ErrConditionFailed)
+			},
+			wantErr: true,
+			errMsg:  "modified concurrently",
+		},
+		{
+			name:  "dynamodb error",
+			key:   "user#123#*",
+			rate:  1,
+			burst: 1,
+			setupMock: func(mockDB *mocks.MockDB, mockQuery *mocks.MockQuery, mockUpdateBuilder *mocks.MockUpdateBuilder) {
+				mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+				mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+				mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(errors.New("dynamodb error"))
+			},
+			wantErr: true,
+			errMsg:  "failed to get bucket",
+		},
+		{
+			name:    "empty key",
+			key:     "",
+			rate:    1,
+			burst:   1,
+			wantErr: true,
+			errMsg:  "cannot be empty",
+		},
+		{
+			name:    "invalid rate",
+			key:     "user#123#*",
+			rate:    0,
+			burst:   1,
+			wantErr: true,
+			errMsg:  "must be positive",
+		},
+		{
+			name:    "invalid burst",
+			key:     "user#123#*",
+			rate:    1,
+			burst:   0,
+			wantErr: true,
+			errMsg:  "must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+
+			if tt.setupMock != nil {
+				tt.setupMock(mockDB, mockQuery, mockUpdateBuilder)
+			}
+
+			rateLimitStore := dynamorm.NewRateLimitStore(mockDB)
+			allowed, wait, err := rateLimitStore.Take(ctx, tt.key, tt.rate, tt.burst)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errMsg != "" {
+					assert.Contains(t, err.Error(), tt.errMsg)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectAllowed, allowed)
+				if tt.expectWait {
+					assert.Greater(t, wait, time.Duration(0))
+				} else {
+					assert.Zero(t, wait)
+				}
+			}
+
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+			mockUpdateBuilder.AssertExpectations(t)
+		})
+	}
+}
+
+// TestRateLimitStore_ConcurrentModificationError tests the error returned after retries
+func TestRateLimitStore_ConcurrentModificationError(t *testing.T) {
+	mockDB := new(mocks.MockDB)
+	mockQuery := new(mocks.MockQuery)
+	mockDB.On("Model", mock.Anything).Return(mockQuery)
+	mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+	mockQuery.On("First", mock.Anything).Return(errors.New("item not found"))
+	mockQuery.On("Create").Return(dynamormErrors.ErrConditionFailed)
+
+	_, _, err := dynamorm.NewRateLimitStore(mockDB).Take(context.Background(), "user#1#*", 1, 1)
+	assert.ErrorIs(t, err, store.ErrConcurrentModification)
+	mockQuery.AssertNumberOfCalls(t, "Create", 3)
+}
+
+// TestRateLimitStore_Refund tests the Refund method
+func TestRateLimitStore_Refund(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("existing bucket gets a token back", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+		mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+
+		mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+		mockQuery.On("Where", "pk", "=", "BUCKET#user#123#*").Return(mockQuery)
+		mockQuery.On("Where", "sk", "=", "STATE").Return(mockQuery)
+		mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Run(existingBucket(0.5, time.Now(), 4)).Return(nil)
+		mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", "tokens", mock.MatchedBy(func(tokens float64) bool {
+			return tokens >= 1.5 && tokens < 1.6
+		})).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", "updated_at", mock.Anything).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", "version", int64(5)).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Set", "ttl", mock.Anything).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Condition", "version", "=", int64(4)).Return(mockUpdateBuilder)
+		mockUpdateBuilder.On("Execute").Return(nil)
+
+		err := dynamorm.NewRateLimitStore(mockDB).Refund(ctx, "user#123#*", 1, 5)
+		assert.NoError(t, err)
+		mockUpdateBuilder.AssertExpectations(t)
+	})
+
+	t.Run("missing bucket is left alone", func(t *testing.T) {
+		mockDB := new(mocks.MockDB)
+		mockQuery := new(mocks.MockQuery)
+
+		mockDB.On("Model", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(mockQuery)
+		mockQuery.On("Where", mock.Anything, "=", mock.Anything).Return(mockQuery)
+		mockQuery.On("First", mock.AnythingOfType("*dynamorm.RateLimitBucket")).Return(errors.New("item not found"))
+
+		err := dynamorm.NewRateLimitStore(mockDB).Refund(ctx, "user#123#*", 1, 5)
+		assert.NoError(t, err)
+		mockQuery.AssertNotCalled(t, "Create")
+		mockQuery.AssertNotCalled(t, "UpdateBuilder")
+	})
+}
@@ -0,0 +1,221 @@
+package dynamorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// RequestMigrationOptions controls a MigrateRequests run
+type RequestMigrationOptions struct {
+	// DryRun counts the requests left to migrate without writing anything
+	DryRun bool
+
+	// NextToken resumes a run after the last page an earlier run finished;
+	// empty starts at the beginning of the legacy table
+	NextToken string
+
+	// PageSize is the number of legacy items scanned per page; zero or less
+	// means store.DefaultPageSize
+	PageSize int
+
+	// OnPage, if set, is called with the running totals after each page
+	OnPage func(*RequestMigrationResult)
+}
+
+// RequestMigrationResult reports what MigrateRequests found and did
+type RequestMigrationResult struct {
+	// LegacyItems is the number of items scanned in the legacy table
+	LegacyItems int
+	// Migrated is the number of requests copied to the requests table
+	Migrated int
+	// AlreadyMigrated is the number of requests already in the requests table
+	AlreadyMigrated int
+	// Remaining is the number of requests a dry run found left to migrate
+	Remaining int
+	// NextToken resumes the run after the last page it finished. It is empty
+	// once the whole legacy table has been scanned.
+	NextToken string
+}
+
+// MigrateRequests copies requests from the legacy table (store.RequestsTable)
+// to the requests table (store.RequestsV2Table), keyed by request ID alone.
+// The legacy table is only read; it is removed once a dry run finds nothing
+// left to migrate.
+//
+// The legacy table is scanned a page at a time. Each request on a page is
+// read from all of its legacy items and written unless the requests table
+// already holds it, so a page can be copied again safely. The result's
+// NextToken only moves past a page once all of its requests are copied: a run
+// that fails or is cancelled returns the token to resume it with, and
+// resuming repeats at most the page it stopped in.
+func MigrateRequests(ctx context.Context, db core.DB, opts RequestMigrationOptions) (*RequestMigrationResult, error) {
+	page := store.PageOptions{Limit: opts.PageSize, NextToken: opts.NextToken}
+	if err := validatePageToken(page); err != nil {
+		return nil, err
+	}
+
+	result := &RequestMigrationResult{NextToken: opts.NextToken}
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var items []LegacyAsyncRequest
+		next, err := queryPage(db.Model(&LegacyAsyncRequest{}), page, &items)
+		if err != nil {
+			return result, store.NewStoreError("MigrateRequests", store.RequestsTable, "", fmt.Errorf("failed to scan requests: %w", err))
+		}
+		result.LegacyItems += len(items)
+
+		for _, requestID := range legacyRequestIDs(items) {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if err := migrateLegacyRequest(db, requestID, opts.DryRun, result); err != nil {
+				return result, store.NewStoreError("MigrateRequests", store.RequestsV2Table, requestID, err)
+			}
+		}
+
+		result.NextToken = next
+		page.NextToken = next
+		if opts.OnPage != nil {
+			opts.OnPage(result)
+		}
+		if next == "" {
+			return result, nil
+		}
+	}
+}
+
+// legacyRequestIDs returns the IDs of the requests items belong to, in the
+// order they were scanned
+func legacyRequestIDs(items []LegacyAsyncRequest) []string {
+	seen := make(map[string]bool, len(items))
+	var requestIDs []string
+	for _, item := range items {
+		if item.RequestID == "" || seen[item.RequestID] {
+			continue
+		}
+		seen[item.RequestID] = true
+		requestIDs = append(requestIDs, item.RequestID)
+	}
+	return requestIDs
+}
+
+// migrateLegacyRequest copies one request and adds it to result. A dry run
+// only checks whether the requests table holds it.
+func migrateLegacyRequest(db core.DB, requestID string, dryRun bool, result *RequestMigrationResult) error {
+	if dryRun {
+		_, err := readRequest(db, requestID)
+		switch {
+		case err == nil:
+			result.AlreadyMigrated++
+		case errors.Is(err, store.ErrNotFound):
+			result.Remaining++
+		default:
+			return err
+		}
+		return nil
+	}
+
+	// A request's items may span pages, so all of them are read
+	legacy, err := legacyRequestItems(db, requestID)
+	if err != nil {
+		return fmt.Errorf("failed to read legacy request: %w", err)
+	}
+	if len(legacy) == 0 {
+		// Expired since the page was scanned
+		return nil
+	}
+
+	_, created, err := migrateRequest(db, legacy, time.Now())
+	if err != nil {
+		return err
+	}
+	if created {
+		result.Migrated++
+	} else {
+		result.AlreadyMigrated++
+	}
+	return nil
+}
+
+// legacyRequestItems reads the items of one request in the legacy table
+func legacyRequestItems(db core.DB, requestID string) ([]LegacyAsyncRequest, error) {
+	key := &AsyncRequest{RequestID: requestID}
+	key.SetKeys()
+
+	var items []LegacyAsyncRequest
+	if err := db.Model(&LegacyAsyncRequest{}).
+		Where("pk", "=", key.PK).
+		All(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// migrateRequest writes a request to the requests table from its legacy
+// items. It returns the request as written and whether this call wrote it; a
+// request already in the requests table is left as it is. The legacy items
+// are kept.
+func migrateRequest(db core.DB, legacy []LegacyAsyncRequest, now time.Time) (AsyncRequest, bool, error) {
+	migrated := AsyncRequest(latestLegacyRequest(legacy))
+	migrated.SetKeys()
+	migrated.Version = 1
+	migrated.MigratedAt = &now
+
+	if err := db.Model(&migrated).Create(); err != nil {
+		if !errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return migrated, false, fmt.Errorf("failed to write request: %w", err)
+		}
+		return migrated, false, nil
+	}
+	return migrated, true, nil
+}
+
+// legacyStatusOrder ranks statuses by how far through its lifecycle a request is
+var legacyStatusOrder = map[store.RequestStatus]int{
+	store.StatusPending:    0,
+	store.StatusRetrying:   1,
+	store.StatusProcessing: 2,
+	store.StatusCompleted:  3,
+	store.StatusFailed:     3,
+	store.StatusCancelled:  3,
+}
+
+// latestLegacyRequest returns the legacy item that holds the request's
+// current state. An item already keyed by request ID alone was updated in
+// place and is current. Otherwise the STATUS# item furthest through its
+// lifecycle wins and, of two finished items, such as a failure retried to
+// completion, the one that ended last.
+func latestLegacyRequest(items []LegacyAsyncRequest) LegacyAsyncRequest {
+	for _, item := range items {
+		if item.SK == requestSortKey {
+			return item
+		}
+	}
+
+	latest := items[0]
+	for _, item := range items[1:] {
+		rank, latestRank := legacyStatusOrder[item.Status], legacyStatusOrder[latest.Status]
+		if rank > latestRank || (rank == latestRank && endedAfter(item, latest)) {
+			latest = item
+		}
+	}
+	return latest
+}
+
+// endedAfter reports whether a finished processing after b. An item without
+// an end time ends before any item with one.
+func endedAfter(a, b LegacyAsyncRequest) bool {
+	if a.ProcessingEnded == nil {
+		return false
+	}
+	return b.ProcessingEnded == nil || a.ProcessingEnded.After(*b.ProcessingEnded)
+}
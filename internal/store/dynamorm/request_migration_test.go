@@ -0,0 +1,256 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	dynamocks "github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/dynamorm/pkg/query"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+)
+
+// legacyRequests are requests in the legacy table; req-1's completion left
+// its pending item behind
+var legacyRequests = []dynamorm.LegacyAsyncRequest{
+	{PK: "REQ#req-1", SK: "STATUS#PENDING", RequestID: "req-1", Status: store.StatusPending},
+	{PK: "REQ#req-1", SK: "STATUS#COMPLETED", RequestID: "req-1", Status: store.StatusCompleted, Progress: 100},
+	{PK: "REQ#req-2", SK: "STATUS#PROCESSING", RequestID: "req-2", Status: store.StatusProcessing},
+}
+
+// migrationMocks holds a query on the legacy table and one on the requests
+// table, with the requests written to the latter
+type migrationMocks struct {
+	db      *dynamocks.MockDB
+	legacy  *dynamocks.MockQuery
+	current *dynamocks.MockQuery
+	written *[]dynamorm.AsyncRequest
+}
+
+// newMigrationMocks mocks reads of the legacy table's items from items
+func newMigrationMocks(items []dynamorm.LegacyAsyncRequest) *migrationMocks {
+	m := &migrationMocks{
+		db:      new(dynamocks.MockDB),
+		legacy:  new(dynamocks.MockQuery),
+		current: new(dynamocks.MockQuery),
+		written: &[]dynamorm.AsyncRequest{},
+	}
+	m.db.On("Model", mock.AnythingOfType("*dynamorm.LegacyAsyncRequest")).Return(m.legacy)
+	m.db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		*m.written = append(*m.written, *args.Get(0).(*dynamorm.AsyncRequest))
+	}).Return(m.current)
+
+	var pk string
+	m.legacy.On("Where", "pk", "=", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		pk = args.String(2)
+	}).Return(m.legacy)
+	m.legacy.On("All", mock.AnythingOfType("*[]dynamorm.LegacyAsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*[]dynamorm.LegacyAsyncRequest)
+		for _, item := range items {
+			if item.PK == pk {
+				*dest = append(*dest, item)
+			}
+		}
+	}).Return(nil).Maybe()
+	return m
+}
+
+// expectPage mocks one page of the legacy table's scan
+func (m *migrationMocks) expectPage(limit int, items []dynamorm.LegacyAsyncRequest, next string) {
+	m.legacy.On("Limit", limit).Return(m.legacy).Once()
+	m.legacy.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.LegacyAsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*[]dynamorm.LegacyAsyncRequest)
+		*dest = append([]dynamorm.LegacyAsyncRequest(nil), items...)
+	}).Return(&core.PaginatedResult{NextCursor: next}, nil).Once()
+}
+
+// legacyPageToken encodes a DynamORM cursor that resumes the legacy scan
+// after requestID
+func legacyPageToken(t *testing.T, requestID string) string {
+	t.Helper()
+
+	token, err := query.EncodeCursor(map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "REQ#" + requestID},
+		"sk": &types.AttributeValueMemberS{Value: "STATUS#COMPLETED"},
+	}, "", "")
+	require.NoError(t, err)
+	return token
+}
+
+func TestMigrateRequests(t *testing.T) {
+	m := newMigrationMocks(legacyRequests)
+	m.expectPage(store.DefaultPageSize, legacyRequests, "")
+
+	// req-2 was copied by an earlier, interrupted run
+	m.current.On("Create").Return(nil).Once()
+	m.current.On("Create").Return(dynamormErrors.ErrConditionFailed).Once()
+
+	result, err := dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &dynamorm.RequestMigrationResult{
+		LegacyItems:     3,
+		Migrated:        1,
+		AlreadyMigrated: 1,
+	}, result)
+
+	written := *m.written
+	require.Len(t, written, 2)
+	assert.Equal(t, "REQ#req-1", written[0].PK)
+	assert.Equal(t, "METADATA", written[0].SK)
+	assert.Equal(t, store.StatusCompleted, written[0].Status)
+	assert.Equal(t, float64(100), written[0].Progress)
+	assert.Equal(t, int64(1), written[0].Version)
+	assert.NotNil(t, written[0].MigratedAt)
+	assert.Equal(t, store.StatusProcessing, written[1].Status)
+
+	// The legacy table is only read
+	m.legacy.AssertNotCalled(t, "Delete")
+	m.db.AssertExpectations(t)
+	m.legacy.AssertExpectations(t)
+	m.current.AssertExpectations(t)
+}
+
+func TestMigrateRequests_CurrentItem(t *testing.T) {
+	failedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	completedAt := failedAt.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		items  []dynamorm.LegacyAsyncRequest
+		status store.RequestStatus
+	}{
+		{
+			// The request failed, was retried and completed; both items were left
+			name: "last finished",
+			items: []dynamorm.LegacyAsyncRequest{
+				{PK: "REQ#req-1", SK: "STATUS#FAILED", RequestID: "req-1", Status: store.StatusFailed, ProcessingEnded: &failedAt},
+				{PK: "REQ#req-1", SK: "STATUS#COMPLETED", RequestID: "req-1", Status: store.StatusCompleted, ProcessingEnded: &completedAt},
+			},
+			status: store.StatusCompleted,
+		},
+		{
+			// A release that updated requests in place wrote to the legacy table
+			name: "updated in place",
+			items: []dynamorm.LegacyAsyncRequest{
+				{PK: "REQ#req-1", SK: "STATUS#COMPLETED", RequestID: "req-1", Status: store.StatusCompleted, ProcessingEnded: &completedAt},
+				{PK: "REQ#req-1", SK: "METADATA", RequestID: "req-1", Status: store.StatusCancelled},
+			},
+			status: store.StatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMigrationMocks(tt.items)
+			m.expectPage(store.DefaultPageSize, tt.items, "")
+			m.current.On("Create").Return(nil).Once()
+
+			_, err := dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{})
+			require.NoError(t, err)
+			require.Len(t, *m.written, 1)
+			assert.Equal(t, tt.status, (*m.written)[0].Status)
+			assert.Equal(t, "METADATA", (*m.written)[0].SK)
+		})
+	}
+}
+
+func TestMigrateRequests_Pages(t *testing.T) {
+	token := legacyPageToken(t, "req-1")
+	firstPage, secondPage := legacyRequests[:2], legacyRequests[2:]
+
+	// The second page fails to write, after the first was copied
+	m := newMigrationMocks(legacyRequests)
+	m.expectPage(2, firstPage, token)
+	m.legacy.On("Cursor", token).Return(m.legacy).Once()
+	m.expectPage(2, secondPage, "")
+	m.current.On("Create").Return(nil).Once()
+	m.current.On("Create").Return(errors.New("DynamoDB service unavailable")).Once()
+
+	var pages []string
+	result, err := dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{
+		PageSize: 2,
+		OnPage: func(result *dynamorm.RequestMigrationResult) {
+			pages = append(pages, result.NextToken)
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write request")
+	assert.Equal(t, []string{token}, pages)
+	assert.Equal(t, 1, result.Migrated)
+	assert.Equal(t, token, result.NextToken, "the run resumes at the failed page")
+	m.legacy.AssertExpectations(t)
+
+	// Resuming scans from the failed page
+	m = newMigrationMocks(legacyRequests)
+	m.legacy.On("Cursor", token).Return(m.legacy).Once()
+	m.expectPage(2, secondPage, "")
+	m.current.On("Create").Return(nil).Once()
+
+	result, err = dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{
+		PageSize:  2,
+		NextToken: token,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &dynamorm.RequestMigrationResult{LegacyItems: 1, Migrated: 1}, result)
+	require.Len(t, *m.written, 1)
+	assert.Equal(t, "req-2", (*m.written)[0].RequestID)
+	m.legacy.AssertExpectations(t)
+	m.current.AssertExpectations(t)
+}
+
+func TestMigrateRequests_DryRun(t *testing.T) {
+	m := newMigrationMocks(legacyRequests)
+	m.expectPage(store.DefaultPageSize, legacyRequests, "")
+
+	// req-1 has been copied; req-2 has not
+	m.current.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(m.current)
+	m.current.On("Where", "sk", "=", "METADATA").Return(m.current)
+	m.current.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(nil).Once()
+	m.current.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(errors.New("item not found")).Once()
+
+	result, err := dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, &dynamorm.RequestMigrationResult{LegacyItems: 3, AlreadyMigrated: 1, Remaining: 1}, result)
+
+	m.current.AssertNotCalled(t, "Create")
+	m.legacy.AssertNotCalled(t, "All", mock.Anything)
+}
+
+func TestMigrateRequests_Errors(t *testing.T) {
+	t.Run("scan fails", func(t *testing.T) {
+		m := newMigrationMocks(nil)
+		m.legacy.On("Limit", store.DefaultPageSize).Return(m.legacy)
+		m.legacy.On("AllPaginated", mock.Anything).Return(nil, errors.New("DynamoDB service unavailable"))
+
+		result, err := dynamorm.MigrateRequests(context.Background(), m.db, dynamorm.RequestMigrationOptions{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to scan requests")
+		assert.Empty(t, result.NextToken)
+	})
+
+	t.Run("invalid resume token", func(t *testing.T) {
+		_, err := dynamorm.MigrateRequests(context.Background(), new(dynamocks.MockDB), dynamorm.RequestMigrationOptions{NextToken: "not-a-token"})
+
+		var validationErr *store.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := dynamorm.MigrateRequests(ctx, new(dynamocks.MockDB), dynamorm.RequestMigrationOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, result.NextToken)
+	})
+}
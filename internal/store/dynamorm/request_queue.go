@@ -2,15 +2,23 @@ package dynamorm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
 	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
 )
 
+// maxRequestUpdateAttempts bounds the optimistic locking retries for a single update
+const maxRequestUpdateAttempts = 3
+
 // requestQueue implements RequestQueue using DynamORM
 type requestQueue struct {
+	lifecycle.Hooks
+
 	db core.DB
 }
 
@@ -39,11 +47,15 @@ func (q *requestQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) err
 	}
 
 	// Convert to DynamORM model
+	req.Version = 1
 	dynamormReq := &AsyncRequest{}
 	dynamormReq.FromStoreModel(req)
 
 	// Create the request
 	if err := q.db.Model(dynamormReq).Create(); err != nil {
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return store.NewStoreError("Enqueue", dynamormReq.TableName(), req.RequestID, store.ErrAlreadyExists)
+		}
 		return store.NewStoreError("Enqueue", dynamormReq.TableName(), req.RequestID, fmt.Errorf("failed to enqueue request: %w", err))
 	}
 
@@ -52,203 +64,282 @@ func (q *requestQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) err
 
 // Get retrieves a specific request
 func (q *requestQueue) Get(ctx context.Context, requestID string) (*store.AsyncRequest, error) {
-	if requestID == "" {
-		return nil, store.NewValidationError("requestID", "cannot be empty")
+	req, err := q.get(ctx, "Get", requestID)
+	if err != nil {
+		return nil, err
 	}
+	return req.ToStoreModel(), nil
+}
 
-	// Create model with keys
-	req := &AsyncRequest{RequestID: requestID}
-	req.SetKeys()
+// UpdateStatus updates the status of a request
+func (q *requestQueue) UpdateStatus(ctx context.Context, requestID string, status store.RequestStatus, message string) error {
+	return q.update(ctx, "UpdateStatus", requestID, status, func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		switch status {
+		case store.StatusProcessing:
+			if current.ProcessingStarted == nil {
+				builder = builder.Set("processing_started", now)
+			}
+		case store.StatusCompleted, store.StatusFailed, store.StatusCancelled:
+			builder = builder.Set("processing_ended", now)
+		}
+		return builder, nil
+	})
+}
 
-	// Query for the request (need to handle multiple statuses)
-	var requests []AsyncRequest
-	if err := q.db.Model(&AsyncRequest{}).
-		Where("pk", "=", req.PK).
-		All(&requests); err != nil {
-		return nil, store.NewStoreError("Get", req.TableName(), requestID, fmt.Errorf("failed to get request: %w", err))
-	}
+// UpdateProgress updates the progress of a request
+func (q *requestQueue) UpdateProgress(ctx context.Context, requestID string, progress float64, message string, details map[string]interface{}) error {
+	return q.update(ctx, "UpdateProgress", requestID, "", func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		if err := lifecycle.CheckProgress(current.Status); err != nil {
+			return builder, err
+		}
+		return builder.
+			Set("progress", progress).
+			Set("progress_message", message).
+			Set("progress_details", details), nil
+	})
+}
 
-	if len(requests) == 0 {
-		return nil, store.NewStoreError("Get", req.TableName(), requestID, store.ErrNotFound)
-	}
+// CompleteRequest marks a request as completed with results
+func (q *requestQueue) CompleteRequest(ctx context.Context, requestID string, result map[string]interface{}) error {
+	return q.update(ctx, "CompleteRequest", requestID, store.StatusCompleted, func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		return builder.
+			Set("processing_ended", now).
+			Set("result", result).
+			Set("progress", float64(100)), nil
+	})
+}
 
-	// Return the first (should be only) result
-	return requests[0].ToStoreModel(), nil
+// FailRequest marks a request as failed with an error
+func (q *requestQueue) FailRequest(ctx context.Context, requestID string, errMsg string) error {
+	return q.update(ctx, "FailRequest", requestID, store.StatusFailed, func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		return builder.
+			Set("processing_ended", now).
+			Set("error", errMsg), nil
+	})
 }
 
-// UpdateStatus updates the status of a request
-func (q *requestQueue) UpdateStatus(ctx context.Context, requestID string, status store.RequestStatus, message string) error {
-	if requestID == "" {
-		return store.NewValidationError("requestID", "cannot be empty")
-	}
+// RecordCallbackDelivery appends an attempt to deliver the request's callback to its delivery log
+func (q *requestQueue) RecordCallbackDelivery(ctx context.Context, requestID string, delivery store.CallbackDelivery) error {
+	return q.update(ctx, "RecordCallbackDelivery", requestID, "", func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		encoded, err := encodeDeliveries(append(decodeDeliveries(current.CallbackDeliveries), delivery))
+		if err != nil {
+			return builder, err
+		}
+		return builder.Set("callback_deliveries", encoded), nil
+	})
+}
 
-	// Get the current request to find its current status
-	current, err := q.Get(ctx, requestID)
-	if err != nil {
-		return err
+// GetByConnection retrieves a page of a connection's requests
+func (q *requestQueue) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
 	}
 
-	// Delete old status entry
-	oldReq := &AsyncRequest{RequestID: requestID, Status: current.Status}
-	oldReq.SetKeys()
-	if err := q.db.Model(oldReq).Delete(); err != nil {
-		// Log but don't fail
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
 	}
 
-	// Create new status entry
-	newReq := &AsyncRequest{}
-	newReq.FromStoreModel(current)
-	newReq.Status = status
-	newReq.SetKeys()
+	var requests []AsyncRequest
 
-	if err := q.db.Model(newReq).Create(); err != nil {
-		return store.NewStoreError("UpdateStatus", newReq.TableName(), requestID, fmt.Errorf("failed to update status: %w", err))
+	// Query a page of the connection index
+	next, err := queryPage(q.db.Model(&AsyncRequest{}).
+		Index("connection-index").
+		Where("connection_id", "=", connectionID), opts, &requests)
+	if err != nil {
+		return nil, store.NewStoreError("GetByConnection", store.RequestsV2Table, connectionID, fmt.Errorf("failed to get requests by connection: %w", err))
 	}
 
-	return nil
+	return pageRequests(requests, next), nil
 }
 
-// UpdateProgress updates the progress of a request
-func (q *requestQueue) UpdateProgress(ctx context.Context, requestID string, progress float64, message string, details map[string]interface{}) error {
-	if requestID == "" {
-		return store.NewValidationError("requestID", "cannot be empty")
-	}
-
-	// Get current request
-	current, err := q.Get(ctx, requestID)
-	if err != nil {
-		return err
+// GetByStatus retrieves a page of requests in a status
+func (q *requestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
 	}
 
-	req := &AsyncRequest{RequestID: requestID, Status: current.Status}
-	req.SetKeys()
-
-	// Update progress fields
-	err = q.db.Model(req).
-		UpdateBuilder().
-		Set("progress", progress).
-		Set("progress_message", message).
-		Set("progress_details", details).
-		Execute()
+	var requests []AsyncRequest
 
+	// Query a page of the status index
+	next, err := queryPage(q.db.Model(&AsyncRequest{}).
+		Index("status-index").
+		Where("status", "=", status), opts, &requests)
 	if err != nil {
-		return store.NewStoreError("UpdateProgress", req.TableName(), requestID, fmt.Errorf("failed to update progress: %w", err))
+		return nil, store.NewStoreError("GetByStatus", store.RequestsV2Table, string(status), fmt.Errorf("failed to get requests by status: %w", err))
 	}
 
-	return nil
+	return pageRequests(requests, next), nil
 }
 
-// CompleteRequest marks a request as completed with results
-func (q *requestQueue) CompleteRequest(ctx context.Context, requestID string, result map[string]interface{}) error {
-	now := time.Now()
+// GetByUser retrieves a page of a user's requests that match filter, newest
+// first. The creation time bounds are the user index's sort key condition and
+// the rest of the filter is applied by DynamoDB after the limit, so a page may
+// come back short.
+func (q *requestQueue) GetByUser(ctx context.Context, userID string, filter store.RequestFilter, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
+	}
 
-	// Get current request
-	current, err := q.Get(ctx, requestID)
-	if err != nil {
-		return err
+	after, before := filter.CreatedAfter, filter.CreatedBefore
+	if !after.IsZero() && !before.IsZero() && !before.After(after) {
+		return &store.Page[*store.AsyncRequest]{Items: make([]*store.AsyncRequest, 0)}, nil
 	}
 
-	// Update status to completed
-	current.Status = store.StatusCompleted
-	current.ProcessingEnded = &now
-	current.Result = result
-	current.Progress = 100
+	query := q.db.Model(&AsyncRequest{}).
+		Index("user-index").
+		Where("user_id", "=", userID)
 
-	// Delete old entry and create new one with updated status
-	return q.UpdateStatus(ctx, requestID, store.StatusCompleted, "Request completed successfully")
-}
+	// CreatedBefore is exclusive and BETWEEN is not
+	switch {
+	case !after.IsZero() && !before.IsZero():
+		query = query.Where("created_at", "BETWEEN", []any{after, before.Add(-time.Nanosecond)})
+	case !after.IsZero():
+		query = query.Where("created_at", ">=", after)
+	case !before.IsZero():
+		query = query.Where("created_at", "<", before)
+	}
 
-// FailRequest marks a request as failed with an error
-func (q *requestQueue) FailRequest(ctx context.Context, requestID string, errMsg string) error {
-	now := time.Now()
+	if filter.TenantID != "" {
+		query = query.Filter("tenant_id", "=", filter.TenantID)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Filter("status", "IN", statusValues(filter.Statuses))
+	}
+	if filter.Action != "" {
+		query = query.Filter("action", "=", filter.Action)
+	}
+
+	var requests []AsyncRequest
 
-	// Get current request
-	current, err := q.Get(ctx, requestID)
+	// Query a page of the user index, newest first
+	next, err := queryPage(query.OrderBy("created_at", "DESC"), opts, &requests)
 	if err != nil {
-		return err
+		return nil, store.NewStoreError("GetByUser", store.RequestsV2Table, userID, fmt.Errorf("failed to get requests by user: %w", err))
 	}
 
-	// Update status to failed
-	current.Status = store.StatusFailed
-	current.ProcessingEnded = &now
-	current.Error = errMsg
-
-	// Delete old entry and create new one with updated status
-	return q.UpdateStatus(ctx, requestID, store.StatusFailed, errMsg)
+	return pageRequests(requests, next), nil
 }
 
-// GetByConnection retrieves all requests for a connection
-func (q *requestQueue) GetByConnection(ctx context.Context, connectionID string, limit int) ([]*store.AsyncRequest, error) {
-	if connectionID == "" {
-		return nil, store.NewValidationError("connectionID", "cannot be empty")
+// CountByTenant counts a tenant's requests in any of the given statuses
+func (q *requestQueue) CountByTenant(ctx context.Context, tenantID, action string, statuses ...store.RequestStatus) (int, error) {
+	if tenantID == "" {
+		return 0, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	if len(statuses) == 0 {
+		return 0, store.NewValidationError("statuses", "at least one status is required")
 	}
 
-	var requests []AsyncRequest
-
-	// Query using the connection index with v1.0.9 API
+	// Query using the tenant index, filtering on status and action
 	query := q.db.Model(&AsyncRequest{}).
-		Index("connection-index").
-		Where("connection_id", "=", connectionID)
-
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
+		Index("tenant-index").
+		Where("tenant_id", "=", tenantID).
+		Filter("status", "IN", statusValues(statuses))
 
-	if err := query.All(&requests); err != nil {
-		return nil, store.NewStoreError("GetByConnection", store.RequestsTable, connectionID, fmt.Errorf("failed to get requests by connection: %w", err))
+	if action != "" {
+		query = query.Filter("action", "=", action)
 	}
 
-	// Convert to store models
-	result := make([]*store.AsyncRequest, len(requests))
-	for i := range requests {
-		result[i] = requests[i].ToStoreModel()
+	count, err := query.Count()
+	if err != nil {
+		return 0, store.NewStoreError("CountByTenant", store.RequestsV2Table, tenantID, fmt.Errorf("failed to count requests by tenant: %w", err))
 	}
 
-	return result, nil
+	return int(count), nil
 }
 
-// GetByStatus retrieves requests by status
-func (q *requestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, limit int) ([]*store.AsyncRequest, error) {
-	var requests []AsyncRequest
+// Defer holds a pending request back until the given time
+func (q *requestQueue) Defer(ctx context.Context, requestID string, until time.Time) error {
+	return q.update(ctx, "Defer", requestID, "", func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		if current.Status != store.StatusPending {
+			return builder, store.ErrRequestNotPending
+		}
+		return builder.Set("retry_after", until), nil
+	})
+}
 
-	// Query using the status index with v1.0.9 API
-	query := q.db.Model(&AsyncRequest{}).
-		Index("status-index").
-		Where("status", "=", status)
+// Resume clears a pending request's RetryAfter so the stream delivers it again
+func (q *requestQueue) Resume(ctx context.Context, requestID string) error {
+	return q.update(ctx, "Resume", requestID, "", func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+		if current.Status != store.StatusPending {
+			return builder, store.ErrRequestNotPending
+		}
+		return builder.Remove("retry_after"), nil
+	})
+}
 
-	if limit > 0 {
-		query = query.Limit(limit)
+// ListDeferred retrieves a page of pending requests deferred until due or earlier
+func (q *requestQueue) ListDeferred(ctx context.Context, due time.Time, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if err := validatePageToken(opts); err != nil {
+		return nil, err
 	}
 
-	if err := query.All(&requests); err != nil {
-		return nil, store.NewStoreError("GetByStatus", store.RequestsTable, string(status), fmt.Errorf("failed to get requests by status: %w", err))
-	}
+	var requests []AsyncRequest
 
-	// Convert to store models
-	result := make([]*store.AsyncRequest, len(requests))
-	for i := range requests {
-		result[i] = requests[i].ToStoreModel()
+	// Query a page of the pending requests; those never deferred have no
+	// retry_after and fail the filter
+	next, err := queryPage(q.db.Model(&AsyncRequest{}).
+		Index("status-index").
+		Where("status", "=", store.StatusPending).
+		Filter("retry_after", "<=", due), opts, &requests)
+	if err != nil {
+		return nil, store.NewStoreError("ListDeferred", store.RequestsV2Table, string(store.StatusPending), fmt.Errorf("failed to list deferred requests: %w", err))
 	}
 
-	return result, nil
+	return pageRequests(requests, next), nil
 }
 
-// Dequeue retrieves and marks requests for processing
+// Dequeue retrieves and marks requests for processing. It reads the status
+// index a page at a time, claiming each page before reading the next, until
+// limit requests are claimed; a limit of zero or less claims every pending
+// request.
 func (q *requestQueue) Dequeue(ctx context.Context, limit int) ([]*store.AsyncRequest, error) {
-	// Get pending requests
-	requests, err := q.GetByStatus(ctx, store.StatusPending, limit)
-	if err != nil {
-		return nil, err
+	claimed := make([]*store.AsyncRequest, 0)
+	opts := store.PageOptions{}
+	for {
+		if limit > 0 {
+			opts.Limit = limit - len(claimed)
+		}
+		page, err := q.GetByStatus(ctx, store.StatusPending, opts)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, q.claim(ctx, page.Items)...)
+
+		if page.NextToken == "" || page.NextToken == opts.NextToken || (limit > 0 && len(claimed) >= limit) {
+			return claimed, nil
+		}
+		opts.NextToken = page.NextToken
 	}
+}
 
-	// Mark each as processing
+// claim moves each request that is still pending to processing and returns
+// those it moved. The index is eventually consistent and other workers
+// dequeue too, so a request that has moved on by the time it is written is
+// left to whoever moved it.
+func (q *requestQueue) claim(ctx context.Context, requests []*store.AsyncRequest) []*store.AsyncRequest {
+	claimed := make([]*store.AsyncRequest, 0, len(requests))
 	for _, req := range requests {
-		if err := q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, "Dequeued for processing"); err != nil {
+		var started time.Time
+		err := q.update(ctx, "Dequeue", req.RequestID, store.StatusProcessing, func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error) {
+			if current.Status != store.StatusPending {
+				return nil, errNotPending
+			}
+			started = now
+			return builder.Set("processing_started", now), nil
+		})
+		if err != nil {
 			// Log error but continue
+			continue
 		}
-	}
 
-	return requests, nil
+		req.Status = store.StatusProcessing
+		req.ProcessingStarted = &started
+		claimed = append(claimed, req)
+	}
+	return claimed
 }
 
 // Delete removes a request
@@ -257,22 +348,168 @@ func (q *requestQueue) Delete(ctx context.Context, requestID string) error {
 		return store.NewValidationError("requestID", "cannot be empty")
 	}
 
-	// Get the request to find its status
-	current, err := q.Get(ctx, requestID)
+	// Create model with keys
+	req := &AsyncRequest{RequestID: requestID}
+	req.SetKeys()
+
+	// The request ID condition fails on a missing item, so a missing request
+	// is reported without reading it first
+	if err := q.db.Model(req).
+		Where("pk", "=", req.PK).
+		Where("sk", "=", req.SK).
+		Where("request_id", "=", requestID).
+		Delete(); err != nil {
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return store.NewStoreError("Delete", req.TableName(), requestID, store.ErrNotFound)
+		}
+		return store.NewStoreError("Delete", req.TableName(), requestID, fmt.Errorf("failed to delete request: %w", err))
+	}
+
+	return nil
+}
+
+// get reads a request with the version its next write is conditional on
+func (q *requestQueue) get(ctx context.Context, op, requestID string) (*AsyncRequest, error) {
+	if requestID == "" {
+		return nil, store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	req, err := readRequest(q.db, requestID)
+	if errors.Is(err, store.ErrNotFound) {
+		req, err = q.readLegacy(requestID)
+	}
 	if err != nil {
-		return err
+		return nil, store.NewStoreError(op, store.RequestsV2Table, requestID, err)
 	}
+	return req, nil
+}
 
+// readRequest reads a request from the requests table
+func readRequest(db core.DB, requestID string) (*AsyncRequest, error) {
 	// Create model with keys
-	req := &AsyncRequest{RequestID: requestID, Status: current.Status}
+	req := &AsyncRequest{RequestID: requestID}
 	req.SetKeys()
 
-	// Delete the request
-	if err := q.db.Model(req).Delete(); err != nil {
-		return store.NewStoreError("Delete", req.TableName(), requestID, fmt.Errorf("failed to delete request: %w", err))
+	if err := db.Model(req).
+		Where("pk", "=", req.PK).
+		Where("sk", "=", req.SK).
+		First(req); err != nil {
+		if err.Error() == "item not found" {
+			return nil, store.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get request: %w", err)
 	}
 
-	return nil
+	return req, nil
+}
+
+// readLegacy copies a request not yet migrated out of the legacy table and
+// returns it, so requests are found while cmd/streamer-migrate-requests has
+// yet to reach them
+func (q *requestQueue) readLegacy(requestID string) (*AsyncRequest, error) {
+	legacy, err := legacyRequestItems(q.db, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	if len(legacy) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	migrated, created, err := migrateRequest(q.db, legacy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// Another reader or the migration copied it first
+		return readRequest(q.db, requestID)
+	}
+	return &migrated, nil
+}
+
+// requestChange adds the fields a write changes to builder, given the
+// request as last read. Returning an error abandons the write.
+type requestChange func(current *AsyncRequest, builder core.UpdateBuilder, now time.Time) (core.UpdateBuilder, error)
+
+// errNotPending abandons claiming a request another worker got to first
+var errNotPending = errors.New("request is no longer pending")
+
+// update moves a request to status to and writes a change to it in place. An
+// empty to leaves the status alone. The write only lands if the request's
+// version is the one the change was built from; a write that loses a race is
+// rebuilt from a fresh read, and ErrConflict is returned once the attempts
+// run out. Hooks are passed the request as read back after the write.
+func (q *requestQueue) update(ctx context.Context, op, requestID string, to store.RequestStatus, change requestChange) error {
+	for attempt := 0; attempt < maxRequestUpdateAttempts; attempt++ {
+		current, err := q.get(ctx, op, requestID)
+		if err != nil {
+			return err
+		}
+		if to != "" {
+			if err := lifecycle.Check(current.Status, to); err != nil {
+				return store.NewStoreError(op, current.TableName(), requestID, err)
+			}
+		}
+
+		builder := q.db.Model(current).
+			Where("pk", "=", current.PK).
+			Where("sk", "=", current.SK).
+			UpdateBuilder()
+		if builder, err = change(current, builder, time.Now()); err != nil {
+			return store.NewStoreError(op, current.TableName(), requestID, err)
+		}
+		if to != "" {
+			builder = builder.Set("status", to)
+		}
+
+		err = builder.
+			Set("version", current.Version+1).
+			Condition("version", "=", current.Version).
+			Execute()
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			continue
+		}
+		if err != nil {
+			return store.NewStoreError(op, current.TableName(), requestID, fmt.Errorf("failed to update request: %w", err))
+		}
+
+		if to != "" && q.HasHooks() {
+			q.notify(ctx, op, requestID, current.Status, to)
+		}
+		return nil
+	}
+
+	return store.NewStoreError(op, store.RequestsV2Table, requestID, store.ErrConflict)
+}
+
+// notify reads a request back after it moved between statuses and passes it
+// to the hooks. The write has landed, so a failed read is not returned.
+func (q *requestQueue) notify(ctx context.Context, op, requestID string, from, to store.RequestStatus) {
+	updated, err := q.get(ctx, op, requestID)
+	if err != nil {
+		return
+	}
+	q.Notify(ctx, from, to, updated.ToStoreModel())
+}
+
+// pageRequests converts the requests a query returned to a page of store models
+func pageRequests(requests []AsyncRequest, next string) *store.Page[*store.AsyncRequest] {
+	page := &store.Page[*store.AsyncRequest]{
+		Items:     make([]*store.AsyncRequest, len(requests)),
+		NextToken: next,
+	}
+	for i := range requests {
+		page.Items[i] = requests[i].ToStoreModel()
+	}
+	return page
+}
+
+// statusValues converts statuses to the strings they are stored as
+func statusValues(statuses []store.RequestStatus) []string {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = string(status)
+	}
+	return values
 }
 
 // validateRequest validates a request before saving
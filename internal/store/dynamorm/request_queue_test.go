@@ -4,15 +4,22 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	// DynamORM mocks
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
 	dynamocks "github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/dynamorm/pkg/query"
 
 	"github.com/pay-theory/streamer/internal/store"
 	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
 )
 
 // Test using DynamORM mocks for detailed behavior verification
@@ -56,6 +63,22 @@ func TestRequestQueue_Enqueue_WithDynamORMMocks(t *testing.T) {
 			expectError: true,
 			errorMsg:    "failed to enqueue request",
 		},
+		{
+			name: "duplicate request",
+			request: &store.AsyncRequest{
+				RequestID:    "req-123",
+				ConnectionID: "conn-456",
+				UserID:       "user-789",
+				TenantID:     "tenant-abc",
+				Action:       "test-action",
+			},
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
+				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
+				q.On("Create").Return(dynamormErrors.ErrConditionFailed)
+			},
+			expectError: true,
+			errorMsg:    store.ErrAlreadyExists.Error(),
+		},
 		{
 			name:        "nil request",
 			request:     nil,
@@ -106,6 +129,26 @@ func TestRequestQueue_Enqueue_WithDynamORMMocks(t *testing.T) {
 	}
 }
 
+// expectGet mocks reading a request by its key
+func expectGet(db *dynamocks.MockDB, q *dynamocks.MockQuery, stored dynamorm.AsyncRequest) {
+	db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
+	q.On("Where", "pk", "=", "REQ#"+stored.RequestID).Return(q)
+	q.On("Where", "sk", "=", "METADATA").Return(q)
+	q.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*dynamorm.AsyncRequest)
+		*dest = stored
+		dest.SetKeys()
+	}).Return(nil)
+}
+
+// expectUpdate mocks an in-place update conditional on the stored version
+func expectUpdate(q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder, version int64, err error) {
+	q.On("UpdateBuilder").Return(ub)
+	ub.On("Set", "version", version+1).Return(ub)
+	ub.On("Condition", "version", "=", version).Return(ub)
+	ub.On("Execute").Return(err)
+}
+
 func TestRequestQueue_Get_WithDynamORMMocks(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -119,19 +162,12 @@ func TestRequestQueue_Get_WithDynamORMMocks(t *testing.T) {
 			name:      "successful get",
 			requestID: "req-123",
 			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
-				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
-				q.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(q)
-				q.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
-					dest := args.Get(0).(*[]dynamorm.AsyncRequest)
-					*dest = []dynamorm.AsyncRequest{
-						{
-							RequestID:    "req-123",
-							ConnectionID: "conn-456",
-							Action:       "test-action",
-							Status:       store.StatusPending,
-						},
-					}
-				}).Return(nil)
+				expectGet(db, q, dynamorm.AsyncRequest{
+					RequestID:    "req-123",
+					ConnectionID: "conn-456",
+					Action:       "test-action",
+					Status:       store.StatusPending,
+				})
 			},
 			expectError: false,
 			expected: &store.AsyncRequest{
@@ -153,15 +189,53 @@ func TestRequestQueue_Get_WithDynamORMMocks(t *testing.T) {
 			requestID: "req-nonexistent",
 			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
 				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
-				q.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(q)
-				q.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
-					dest := args.Get(0).(*[]dynamorm.AsyncRequest)
-					*dest = []dynamorm.AsyncRequest{} // Empty slice
-				}).Return(nil)
+				q.On("Where", "pk", "=", "REQ#req-nonexistent").Return(q)
+				q.On("Where", "sk", "=", "METADATA").Return(q)
+				q.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(errors.New("item not found"))
+				db.On("Model", mock.AnythingOfType("*dynamorm.LegacyAsyncRequest")).Return(q)
+				q.On("All", mock.AnythingOfType("*[]dynamorm.LegacyAsyncRequest")).Return(nil)
 			},
 			expectError: true,
 			errorMsg:    "item not found",
 		},
+		{
+			name:      "request in the legacy table",
+			requestID: "req-123",
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
+				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
+				q.On("Where", "pk", "=", "REQ#req-123").Return(q)
+				q.On("Where", "sk", "=", "METADATA").Return(q)
+				q.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(errors.New("item not found"))
+				db.On("Model", mock.AnythingOfType("*dynamorm.LegacyAsyncRequest")).Return(q)
+				q.On("All", mock.AnythingOfType("*[]dynamorm.LegacyAsyncRequest")).Run(func(args mock.Arguments) {
+					dest := args.Get(0).(*[]dynamorm.LegacyAsyncRequest)
+					*dest = []dynamorm.LegacyAsyncRequest{
+						{PK: "REQ#req-123", SK: "STATUS#PENDING", RequestID: "req-123", Action: "test-action", Status: store.StatusPending},
+					}
+				}).Return(nil)
+
+				// It is copied to the requests table before it is returned;
+				// the legacy item is kept
+				q.On("Create").Return(nil).Once()
+			},
+			expected: &store.AsyncRequest{
+				RequestID: "req-123",
+				Action:    "test-action",
+				Status:    store.StatusPending,
+			},
+		},
+		{
+			name:      "database error",
+			requestID: "req-123",
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
+				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
+				q.On("Where", "pk", "=", "REQ#req-123").Return(q)
+				q.On("Where", "sk", "=", "METADATA").Return(q)
+				q.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(errors.New("DynamoDB service unavailable"))
+			},
+			expectError: true,
+			errorMsg:    "failed to get request",
+		},
 	}
 
 	for _, tt := range tests {
@@ -194,50 +268,94 @@ func TestRequestQueue_Get_WithDynamORMMocks(t *testing.T) {
 }
 
 func TestRequestQueue_UpdateStatus_WithDynamORMMocks(t *testing.T) {
+	stored := dynamorm.AsyncRequest{
+		RequestID:    "req-123",
+		ConnectionID: "conn-456",
+		Action:       "test-action",
+		Status:       store.StatusPending,
+		Version:      3,
+	}
+
 	tests := []struct {
 		name        string
 		requestID   string
 		newStatus   store.RequestStatus
 		message     string
-		setupMock   func(*dynamocks.MockDB, *dynamocks.MockQuery)
+		setupMock   func(*dynamocks.MockDB, *dynamocks.MockQuery, *dynamocks.MockUpdateBuilder)
 		expectError bool
 		errorMsg    string
 	}{
 		{
-			name:      "successful status update",
+			name:      "status updated in place",
 			requestID: "req-123",
 			newStatus: store.StatusProcessing,
 			message:   "Processing started",
-			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {
-				// Mock Get call
-				db.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(q)
-				q.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(q)
-				q.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
-					dest := args.Get(0).(*[]dynamorm.AsyncRequest)
-					*dest = []dynamorm.AsyncRequest{
-						{
-							RequestID:    "req-123",
-							ConnectionID: "conn-456",
-							Action:       "test-action",
-							Status:       store.StatusPending,
-						},
-					}
-				}).Return(nil)
-
-				// Mock Delete old entry
-				q.On("Delete").Return(nil)
-
-				// Mock Create new entry
-				q.On("Create").Return(nil)
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {
+				expectGet(db, q, stored)
+				ub.On("Set", "status", store.StatusProcessing).Return(ub)
+				ub.On("Set", "processing_started", mock.AnythingOfType("time.Time")).Return(ub)
+				expectUpdate(q, ub, 3, nil)
 			},
 			expectError: false,
 		},
+		{
+			name:      "retried after a concurrent write",
+			requestID: "req-123",
+			newStatus: store.StatusCancelled,
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {
+				expectGet(db, q, stored)
+				ub.On("Set", "status", store.StatusCancelled).Return(ub)
+				ub.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(ub)
+				q.On("UpdateBuilder").Return(ub)
+				ub.On("Set", "version", int64(4)).Return(ub)
+				ub.On("Condition", "version", "=", int64(3)).Return(ub)
+				ub.On("Execute").Return(dynamormErrors.ErrConditionFailed).Once()
+				ub.On("Execute").Return(nil).Once()
+			},
+			expectError: false,
+		},
+		{
+			name:      "concurrent writes win every attempt",
+			requestID: "req-123",
+			newStatus: store.StatusCancelled,
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {
+				expectGet(db, q, stored)
+				ub.On("Set", "status", store.StatusCancelled).Return(ub)
+				ub.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(ub)
+				expectUpdate(q, ub, 3, dynamormErrors.ErrConditionFailed)
+			},
+			expectError: true,
+			errorMsg:    store.ErrConflict.Error(),
+		},
+		{
+			name:      "update fails",
+			requestID: "req-123",
+			newStatus: store.StatusFailed,
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {
+				expectGet(db, q, stored)
+				ub.On("Set", "status", store.StatusFailed).Return(ub)
+				ub.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(ub)
+				expectUpdate(q, ub, 3, errors.New("DynamoDB service unavailable"))
+			},
+			expectError: true,
+			errorMsg:    "failed to update request",
+		},
+		{
+			name:      "status does not allow the transition",
+			requestID: "req-123",
+			newStatus: store.StatusCompleted,
+			setupMock: func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {
+				expectGet(db, q, stored)
+			},
+			expectError: true,
+			errorMsg:    "request cannot move from PENDING to COMPLETED",
+		},
 		{
 			name:        "empty request ID",
 			requestID:   "",
 			newStatus:   store.StatusProcessing,
 			message:     "Processing started",
-			setupMock:   func(db *dynamocks.MockDB, q *dynamocks.MockQuery) {},
+			setupMock:   func(db *dynamocks.MockDB, q *dynamocks.MockQuery, ub *dynamocks.MockUpdateBuilder) {},
 			expectError: true,
 			errorMsg:    "cannot be empty",
 		},
@@ -247,8 +365,9 @@ func TestRequestQueue_UpdateStatus_WithDynamORMMocks(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockDB := new(dynamocks.MockDB)
 			mockQuery := new(dynamocks.MockQuery)
+			mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
 
-			tt.setupMock(mockDB, mockQuery)
+			tt.setupMock(mockDB, mockQuery, mockUpdateBuilder)
 
 			queue := dynamorm.NewRequestQueue(mockDB)
 			err := queue.UpdateStatus(context.Background(), tt.requestID, tt.newStatus, tt.message)
@@ -264,6 +383,9 @@ func TestRequestQueue_UpdateStatus_WithDynamORMMocks(t *testing.T) {
 
 			mockDB.AssertExpectations(t)
 			mockQuery.AssertExpectations(t)
+			mockUpdateBuilder.AssertExpectations(t)
+			mockQuery.AssertNotCalled(t, "Delete")
+			mockQuery.AssertNotCalled(t, "Create")
 		})
 	}
 }
@@ -277,7 +399,7 @@ func TestRequestQueue_GetByConnection_WithDynamORMMocks(t *testing.T) {
 	mockQuery.On("Index", "connection-index").Return(mockQuery)
 	mockQuery.On("Where", "connection_id", "=", "conn-456").Return(mockQuery)
 	mockQuery.On("Limit", 10).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
 		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
 		*dest = []dynamorm.AsyncRequest{
 			{
@@ -293,15 +415,16 @@ func TestRequestQueue_GetByConnection_WithDynamORMMocks(t *testing.T) {
 				Status:       store.StatusProcessing,
 			},
 		}
-	}).Return(nil)
+	}).Return(&core.PaginatedResult{}, nil)
 
 	queue := dynamorm.NewRequestQueue(mockDB)
-	result, err := queue.GetByConnection(context.Background(), "conn-456", 10)
+	result, err := queue.GetByConnection(context.Background(), "conn-456", store.PageOptions{Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 2)
-	assert.Equal(t, "req-1", result[0].RequestID)
-	assert.Equal(t, "req-2", result[1].RequestID)
+	assert.Len(t, result.Items, 2)
+	assert.Equal(t, "req-1", result.Items[0].RequestID)
+	assert.Equal(t, "req-2", result.Items[1].RequestID)
+	assert.Empty(t, result.NextToken)
 
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
@@ -316,7 +439,7 @@ func TestRequestQueue_GetByStatus_WithDynamORMMocks(t *testing.T) {
 	mockQuery.On("Index", "status-index").Return(mockQuery)
 	mockQuery.On("Where", "status", "=", store.StatusPending).Return(mockQuery)
 	mockQuery.On("Limit", 5).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
 		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
 		*dest = []dynamorm.AsyncRequest{
 			{
@@ -326,76 +449,174 @@ func TestRequestQueue_GetByStatus_WithDynamORMMocks(t *testing.T) {
 				Status:       store.StatusPending,
 			},
 		}
-	}).Return(nil)
+	}).Return(&core.PaginatedResult{}, nil)
 
 	queue := dynamorm.NewRequestQueue(mockDB)
-	result, err := queue.GetByStatus(context.Background(), store.StatusPending, 5)
+	result, err := queue.GetByStatus(context.Background(), store.StatusPending, store.PageOptions{Limit: 5})
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
-	assert.Equal(t, "req-1", result[0].RequestID)
-	assert.Equal(t, store.StatusPending, result[0].Status)
+	assert.Len(t, result.Items, 1)
+	assert.Equal(t, "req-1", result.Items[0].RequestID)
+	assert.Equal(t, store.StatusPending, result.Items[0].Status)
 
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
 }
 
-func TestRequestQueue_Delete_WithDynamORMMocks(t *testing.T) {
+func TestRequestQueue_GetByUser_WithDynamORMMocks(t *testing.T) {
 	mockDB := new(dynamocks.MockDB)
 	mockQuery := new(dynamocks.MockQuery)
+	now := time.Now()
 
-	// Setup mock for Get call
+	// The time range is the sort key condition and the rest are filters
 	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
-	mockQuery.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+	mockQuery.On("Index", "user-index").Return(mockQuery)
+	mockQuery.On("Where", "user_id", "=", "user-789").Return(mockQuery)
+	mockQuery.On("Where", "created_at", "BETWEEN", []any{now.Add(-2 * time.Hour), now.Add(-time.Nanosecond)}).Return(mockQuery)
+	mockQuery.On("Filter", "tenant_id", "=", "tenant-abc").Return(mockQuery)
+	mockQuery.On("Filter", "status", "IN", []string{"PENDING", "FAILED"}).Return(mockQuery)
+	mockQuery.On("Filter", "action", "=", "generate_report").Return(mockQuery)
+	mockQuery.On("OrderBy", "created_at", "DESC").Return(mockQuery)
+	mockQuery.On("Limit", 10).Return(mockQuery)
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
 		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
 		*dest = []dynamorm.AsyncRequest{
-			{
-				RequestID:    "req-123",
-				ConnectionID: "conn-456",
-				Action:       "test-action",
-				Status:       store.StatusCompleted,
-			},
+			{RequestID: "req-2", UserID: "user-789", TenantID: "tenant-abc", Status: store.StatusPending, CreatedAt: now.Add(-time.Minute)},
+			{RequestID: "req-4", UserID: "user-789", TenantID: "tenant-abc", Status: store.StatusFailed, CreatedAt: now.Add(-time.Hour)},
 		}
-	}).Return(nil)
+	}).Return(&core.PaginatedResult{NextCursor: "next"}, nil)
+
+	queue := dynamorm.NewRequestQueue(mockDB)
+	filter := store.RequestFilter{
+		TenantID:      "tenant-abc",
+		Statuses:      []store.RequestStatus{store.StatusPending, store.StatusFailed},
+		Action:        "generate_report",
+		CreatedAfter:  now.Add(-2 * time.Hour),
+		CreatedBefore: now,
+	}
+	result, err := queue.GetByUser(context.Background(), "user-789", filter, store.PageOptions{Limit: 10})
+
+	assert.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "req-2", result.Items[0].RequestID)
+	assert.Equal(t, "req-4", result.Items[1].RequestID)
+	assert.Equal(t, "next", result.NextToken)
+
+	_, err = queue.GetByUser(context.Background(), "", filter, store.PageOptions{})
+	assert.Error(t, err)
+
+	// An empty time range is not queried
+	empty, err := queue.GetByUser(context.Background(), "user-789", store.RequestFilter{CreatedAfter: now, CreatedBefore: now}, store.PageOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Items)
+
+	mockDB.AssertExpectations(t)
+	mockQuery.AssertExpectations(t)
+}
+
+func TestRequestQueue_CountByTenant_WithDynamORMMocks(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
 
-	// Setup mock for Delete call
-	mockQuery.On("Delete").Return(nil)
+	// Setup mock for tenant index count
+	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
+	mockQuery.On("Index", "tenant-index").Return(mockQuery)
+	mockQuery.On("Where", "tenant_id", "=", "tenant-abc").Return(mockQuery)
+	mockQuery.On("Filter", "status", "IN", []string{"PENDING", "PROCESSING"}).Return(mockQuery)
+	mockQuery.On("Filter", "action", "=", "process_data").Return(mockQuery)
+	mockQuery.On("Count").Return(int64(4), nil)
 
 	queue := dynamorm.NewRequestQueue(mockDB)
-	err := queue.Delete(context.Background(), "req-123")
+	count, err := queue.CountByTenant(context.Background(), "tenant-abc", "process_data", store.StatusPending, store.StatusProcessing)
 
 	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
 }
 
+func TestRequestQueue_CountByTenant_Errors(t *testing.T) {
+	queue := dynamorm.NewRequestQueue(new(dynamocks.MockDB))
+
+	_, err := queue.CountByTenant(context.Background(), "", "", store.StatusPending)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tenantID")
+
+	_, err = queue.CountByTenant(context.Background(), "tenant-abc", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "at least one status")
+
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
+	mockQuery.On("Index", "tenant-index").Return(mockQuery)
+	mockQuery.On("Where", "tenant_id", "=", "tenant-abc").Return(mockQuery)
+	mockQuery.On("Filter", "status", "IN", []string{"PROCESSING"}).Return(mockQuery)
+	mockQuery.On("Count").Return(int64(0), errors.New("DynamoDB service unavailable"))
+
+	_, err = dynamorm.NewRequestQueue(mockDB).CountByTenant(context.Background(), "tenant-abc", "", store.StatusProcessing)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to count requests by tenant")
+}
+
+func TestRequestQueue_Delete_WithDynamORMMocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		deleteErr error
+		errorMsg  string
+	}{
+		{name: "successful delete"},
+		{name: "request not found", deleteErr: dynamormErrors.ErrConditionFailed, errorMsg: "item not found"},
+		{name: "database error", deleteErr: errors.New("DynamoDB service unavailable"), errorMsg: "failed to delete request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(dynamocks.MockDB)
+			mockQuery := new(dynamocks.MockQuery)
+
+			// The request is deleted by key without reading it first
+			mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
+			mockQuery.On("Where", "pk", "=", "REQ#req-123").Return(mockQuery)
+			mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+			mockQuery.On("Where", "request_id", "=", "req-123").Return(mockQuery)
+			mockQuery.On("Delete").Return(tt.deleteErr)
+
+			queue := dynamorm.NewRequestQueue(mockDB)
+			err := queue.Delete(context.Background(), "req-123")
+
+			if tt.errorMsg != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockDB.AssertExpectations(t)
+			mockQuery.AssertExpectations(t)
+			mockQuery.AssertNotCalled(t, "First", mock.Anything)
+		})
+	}
+}
+
 func TestRequestQueue_UpdateProgress_WithDynamORMMocks(t *testing.T) {
 	mockDB := new(dynamocks.MockDB)
 	mockQuery := new(dynamocks.MockQuery)
 	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
 
-	// Setup mock for Get call
-	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
-	mockQuery.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
-		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
-		*dest = []dynamorm.AsyncRequest{
-			{
-				RequestID:    "req-123",
-				ConnectionID: "conn-456",
-				Action:       "test-action",
-				Status:       store.StatusProcessing,
-			},
-		}
-	}).Return(nil)
+	expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{
+		RequestID:    "req-123",
+		ConnectionID: "conn-456",
+		Action:       "test-action",
+		Status:       store.StatusProcessing,
+		Version:      2,
+	})
 
 	// Setup mock for UpdateBuilder chain
-	mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
 	mockUpdateBuilder.On("Set", "progress", 50.0).Return(mockUpdateBuilder)
 	mockUpdateBuilder.On("Set", "progress_message", "Half complete").Return(mockUpdateBuilder)
 	mockUpdateBuilder.On("Set", "progress_details", mock.AnythingOfType("map[string]interface {}")).Return(mockUpdateBuilder)
-	mockUpdateBuilder.On("Execute").Return(nil)
+	expectUpdate(mockQuery, mockUpdateBuilder, 2, nil)
 
 	queue := dynamorm.NewRequestQueue(mockDB)
 	err := queue.UpdateProgress(context.Background(), "req-123", 50.0, "Half complete", map[string]interface{}{"step": 2})
@@ -406,60 +627,224 @@ func TestRequestQueue_UpdateProgress_WithDynamORMMocks(t *testing.T) {
 	mockUpdateBuilder.AssertExpectations(t)
 }
 
-func TestRequestQueue_CompleteRequest_WithDynamORMMocks(t *testing.T) {
+func TestRequestQueue_UpdateProgress_AfterCompletion(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+	expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{
+		RequestID: "req-123",
+		Status:    store.StatusCompleted,
+		Version:   4,
+	})
+	mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+
+	queue := dynamorm.NewRequestQueue(mockDB)
+	err := queue.UpdateProgress(context.Background(), "req-123", 50.0, "Half complete", nil)
+
+	assert.True(t, store.IsConflict(err))
+	mockUpdateBuilder.AssertNotCalled(t, "Execute")
+}
+
+func TestRequestQueue_RecordCallbackDelivery_WithDynamORMMocks(t *testing.T) {
 	mockDB := new(dynamocks.MockDB)
 	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+	attemptedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := store.CallbackDelivery{Attempt: 1, Status: store.StatusCompleted, AttemptedAt: attemptedAt, StatusCode: 503}
+	second := store.CallbackDelivery{Attempt: 2, Status: store.StatusCompleted, AttemptedAt: attemptedAt.Add(time.Second), StatusCode: 200, Delivered: true}
+
+	// A finished request still records deliveries, appended to the stored log
+	stored := &dynamorm.AsyncRequest{}
+	stored.FromStoreModel(&store.AsyncRequest{
+		RequestID:          "req-123",
+		Status:             store.StatusCompleted,
+		CallbackDeliveries: []store.CallbackDelivery{first},
+		Version:            6,
+	})
+	expectGet(mockDB, mockQuery, *stored)
+
+	want := &dynamorm.AsyncRequest{}
+	want.FromStoreModel(&store.AsyncRequest{CallbackDeliveries: []store.CallbackDelivery{first, second}})
+	mockUpdateBuilder.On("Set", "callback_deliveries", want.CallbackDeliveries).Return(mockUpdateBuilder)
+	expectUpdate(mockQuery, mockUpdateBuilder, 6, nil)
+
+	queue := dynamorm.NewRequestQueue(mockDB)
+	err := queue.RecordCallbackDelivery(context.Background(), "req-123", second)
+
+	assert.NoError(t, err)
+	mockUpdateBuilder.AssertExpectations(t)
+	mockUpdateBuilder.AssertNotCalled(t, "Set", "status", mock.Anything)
+}
+
+func TestRequestQueue_Defer_WithDynamORMMocks(t *testing.T) {
+	until := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	t.Run("pending request is deferred", func(t *testing.T) {
+		mockDB := new(dynamocks.MockDB)
+		mockQuery := new(dynamocks.MockQuery)
+		mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+		expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{RequestID: "req-123", Status: store.StatusPending, Version: 2})
+		mockUpdateBuilder.On("Set", "retry_after", until).Return(mockUpdateBuilder)
+		expectUpdate(mockQuery, mockUpdateBuilder, 2, nil)
+
+		err := dynamorm.NewRequestQueue(mockDB).Defer(context.Background(), "req-123", until)
+
+		assert.NoError(t, err)
+		mockUpdateBuilder.AssertExpectations(t)
+		mockUpdateBuilder.AssertNotCalled(t, "Set", "status", mock.Anything)
+	})
+
+	t.Run("resume clears retry after", func(t *testing.T) {
+		mockDB := new(dynamocks.MockDB)
+		mockQuery := new(dynamocks.MockQuery)
+		mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+		expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{RequestID: "req-123", Status: store.StatusPending, RetryAfter: until, Version: 3})
+		mockUpdateBuilder.On("Remove", "retry_after").Return(mockUpdateBuilder)
+		expectUpdate(mockQuery, mockUpdateBuilder, 3, nil)
+
+		err := dynamorm.NewRequestQueue(mockDB).Resume(context.Background(), "req-123")
+
+		assert.NoError(t, err)
+		mockUpdateBuilder.AssertExpectations(t)
+	})
+
+	t.Run("running request is not deferred", func(t *testing.T) {
+		mockDB := new(dynamocks.MockDB)
+		mockQuery := new(dynamocks.MockQuery)
+		mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+		expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{RequestID: "req-123", Status: store.StatusProcessing, Version: 2})
+		mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+
+		err := dynamorm.NewRequestQueue(mockDB).Defer(context.Background(), "req-123", until)
+
+		assert.ErrorIs(t, err, store.ErrRequestNotPending)
+		mockUpdateBuilder.AssertNotCalled(t, "Execute")
+	})
+}
+
+func TestRequestQueue_ListDeferred_WithDynamORMMocks(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	due := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 
-	// Setup mock for Get call
 	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
-	mockQuery.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+	mockQuery.On("Index", "status-index").Return(mockQuery)
+	mockQuery.On("Where", "status", "=", store.StatusPending).Return(mockQuery)
+	mockQuery.On("Filter", "retry_after", "<=", due).Return(mockQuery)
+	mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
 		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
 		*dest = []dynamorm.AsyncRequest{
-			{
-				RequestID:    "req-123",
-				ConnectionID: "conn-456",
-				Action:       "test-action",
-				Status:       store.StatusProcessing,
-			},
+			{RequestID: "req-1", Status: store.StatusPending, RetryAfter: due.Add(-time.Minute)},
 		}
-	}).Return(nil)
+	}).Return(&core.PaginatedResult{}, nil)
 
-	// Setup mock for UpdateStatus calls (Delete + Create)
-	mockQuery.On("Delete").Return(nil)
-	mockQuery.On("Create").Return(nil)
+	result, err := dynamorm.NewRequestQueue(mockDB).ListDeferred(context.Background(), due, store.PageOptions{})
 
-	queue := dynamorm.NewRequestQueue(mockDB)
+	assert.NoError(t, err)
+	assert.Len(t, result.Items, 1)
+	assert.Equal(t, "req-1", result.Items[0].RequestID)
+	mockQuery.AssertExpectations(t)
+}
+
+func TestRequestQueue_CompleteRequest_WithDynamORMMocks(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+	expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{
+		RequestID:    "req-123",
+		ConnectionID: "conn-456",
+		Action:       "test-action",
+		Status:       store.StatusProcessing,
+		Version:      5,
+	})
+
+	// The result is written along with the status
 	result := map[string]interface{}{"success": true, "data": "completed"}
+	mockUpdateBuilder.On("Set", "status", store.StatusCompleted).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "result", result).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "progress", float64(100)).Return(mockUpdateBuilder)
+	expectUpdate(mockQuery, mockUpdateBuilder, 5, nil)
+
+	queue := dynamorm.NewRequestQueue(mockDB)
 	err := queue.CompleteRequest(context.Background(), "req-123", result)
 
 	assert.NoError(t, err)
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
+	mockUpdateBuilder.AssertExpectations(t)
 }
 
-func TestRequestQueue_FailRequest_WithDynamORMMocks(t *testing.T) {
+func TestRequestQueue_TransitionHooks_WithDynamORMMocks(t *testing.T) {
 	mockDB := new(dynamocks.MockDB)
 	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
 
-	// Setup mock for Get call
+	// The request is read before the write and again for the hooks
 	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
-	mockQuery.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
-		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
-		*dest = []dynamorm.AsyncRequest{
-			{
-				RequestID:    "req-123",
-				ConnectionID: "conn-456",
-				Action:       "test-action",
-				Status:       store.StatusProcessing,
-			},
-		}
-	}).Return(nil)
+	mockQuery.On("Where", "pk", "=", "REQ#req-123").Return(mockQuery)
+	mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+	mockQuery.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*dynamorm.AsyncRequest)
+		dest.Status = store.StatusProcessing
+		dest.Version = 5
+	}).Return(nil).Once()
+	mockQuery.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*dynamorm.AsyncRequest)
+		dest.Status = store.StatusFailed
+		dest.Error = "timed out"
+		dest.Version = 6
+	}).Return(nil).Once()
+
+	mockUpdateBuilder.On("Set", "status", store.StatusFailed).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "error", "timed out").Return(mockUpdateBuilder)
+	expectUpdate(mockQuery, mockUpdateBuilder, 5, nil)
+
+	queue := dynamorm.NewRequestQueue(mockDB)
+	var notified []*store.AsyncRequest
+	require.NoError(t, lifecycle.Observe(queue, lifecycle.HookFunc(func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+		assert.Equal(t, store.StatusProcessing, from)
+		assert.Equal(t, store.StatusFailed, to)
+		notified = append(notified, req)
+	})))
+
+	require.NoError(t, queue.FailRequest(context.Background(), "req-123", "timed out"))
+	require.Len(t, notified, 1)
+	assert.Equal(t, "req-123", notified[0].RequestID)
+	assert.Equal(t, "timed out", notified[0].Error)
+	assert.Equal(t, int64(6), notified[0].Version)
+
+	mockDB.AssertExpectations(t)
+	mockQuery.AssertExpectations(t)
+	mockUpdateBuilder.AssertExpectations(t)
+}
+
+func TestRequestQueue_FailRequest_WithDynamORMMocks(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
+
+	expectGet(mockDB, mockQuery, dynamorm.AsyncRequest{
+		RequestID:    "req-123",
+		ConnectionID: "conn-456",
+		Action:       "test-action",
+		Status:       store.StatusProcessing,
+		Version:      5,
+	})
 
-	// Setup mock for UpdateStatus calls (Delete + Create)
-	mockQuery.On("Delete").Return(nil)
-	mockQuery.On("Create").Return(nil)
+	// The error is written along with the status
+	mockUpdateBuilder.On("Set", "status", store.StatusFailed).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "processing_ended", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder)
+	mockUpdateBuilder.On("Set", "error", "Processing failed due to timeout").Return(mockUpdateBuilder)
+	expectUpdate(mockQuery, mockUpdateBuilder, 5, nil)
 
 	queue := dynamorm.NewRequestQueue(mockDB)
 	err := queue.FailRequest(context.Background(), "req-123", "Processing failed due to timeout")
@@ -467,18 +852,20 @@ func TestRequestQueue_FailRequest_WithDynamORMMocks(t *testing.T) {
 	assert.NoError(t, err)
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
+	mockUpdateBuilder.AssertExpectations(t)
 }
 
 func TestRequestQueue_Dequeue_WithDynamORMMocks(t *testing.T) {
 	mockDB := new(dynamocks.MockDB)
 	mockQuery := new(dynamocks.MockQuery)
+	mockUpdateBuilder := new(dynamocks.MockUpdateBuilder)
 
 	// Setup mock for GetByStatus call
 	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
 	mockQuery.On("Index", "status-index").Return(mockQuery)
 	mockQuery.On("Where", "status", "=", store.StatusPending).Return(mockQuery)
 	mockQuery.On("Limit", 5).Return(mockQuery)
-	mockQuery.On("All", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
 		dest := args.Get(0).(*[]dynamorm.AsyncRequest)
 		*dest = []dynamorm.AsyncRequest{
 			{
@@ -494,24 +881,68 @@ func TestRequestQueue_Dequeue_WithDynamORMMocks(t *testing.T) {
 				Status:       store.StatusPending,
 			},
 		}
-	}).Return(nil)
+	}).Return(&core.PaginatedResult{}, nil)
 
-	// Setup mocks for UpdateStatus calls for each request
-	// For each request, we need Get + Delete + Create
+	// req-2 was claimed by another worker after the index was read
 	mockQuery.On("Where", "pk", "=", mock.AnythingOfType("string")).Return(mockQuery)
-	mockQuery.On("Delete").Return(nil)
-	mockQuery.On("Create").Return(nil)
+	mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+	mockQuery.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*dynamorm.AsyncRequest)
+		dest.Status = store.StatusPending
+		dest.Version = 1
+	}).Return(nil).Once()
+	mockQuery.On("First", mock.AnythingOfType("*dynamorm.AsyncRequest")).Run(func(args mock.Arguments) {
+		dest := args.Get(0).(*dynamorm.AsyncRequest)
+		dest.Status = store.StatusProcessing
+		dest.Version = 2
+	}).Return(nil).Once()
+
+	// Only req-1 is written
+	mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder).Twice()
+	mockUpdateBuilder.On("Set", "status", store.StatusProcessing).Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Set", "processing_started", mock.AnythingOfType("time.Time")).Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Set", "version", int64(2)).Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Condition", "version", "=", int64(1)).Return(mockUpdateBuilder).Once()
+	mockUpdateBuilder.On("Execute").Return(nil).Once()
 
 	queue := dynamorm.NewRequestQueue(mockDB)
 	result, err := queue.Dequeue(context.Background(), 5)
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 2)
+	require.Len(t, result, 1)
 	assert.Equal(t, "req-1", result[0].RequestID)
-	assert.Equal(t, "req-2", result[1].RequestID)
+	assert.Equal(t, store.StatusProcessing, result[0].Status)
+	assert.NotNil(t, result[0].ProcessingStarted)
 
 	mockDB.AssertExpectations(t)
 	mockQuery.AssertExpectations(t)
+	mockUpdateBuilder.AssertExpectations(t)
+}
+
+func TestRequestQueue_Dequeue_ReadsPages(t *testing.T) {
+	mockDB := new(dynamocks.MockDB)
+	mockQuery := new(dynamocks.MockQuery)
+	token, err := query.EncodeCursor(map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "REQ#req-1"},
+		"sk": &types.AttributeValueMemberS{Value: "METADATA"},
+	}, "status-index", "")
+	require.NoError(t, err)
+
+	// Both pages come back empty, as when every request read was claimed
+	// by another worker, and the second is the last
+	mockDB.On("Model", mock.AnythingOfType("*dynamorm.AsyncRequest")).Return(mockQuery)
+	mockQuery.On("Index", "status-index").Return(mockQuery)
+	mockQuery.On("Where", "status", "=", store.StatusPending).Return(mockQuery)
+	mockQuery.On("Limit", store.DefaultPageSize).Return(mockQuery)
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Return(&core.PaginatedResult{NextCursor: token}, nil).Once()
+	mockQuery.On("Cursor", token).Return(mockQuery).Once()
+	mockQuery.On("AllPaginated", mock.AnythingOfType("*[]dynamorm.AsyncRequest")).Return(&core.PaginatedResult{}, nil).Once()
+
+	result, err := dynamorm.NewRequestQueue(mockDB).Dequeue(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+	mockQuery.AssertExpectations(t)
 }
 
 // Edge case and error handling tests
@@ -0,0 +1,57 @@
+package dynamorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pay-theory/dynamorm/pkg/core"
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// sequenceStore implements SequenceStore with an atomic counter on the
+// connection record, so the count is removed along with the connection
+type sequenceStore struct {
+	db core.DB
+}
+
+// NewSequenceStore creates a new DynamORM-backed sequence store
+func NewSequenceStore(db core.DB) store.SequenceStore {
+	return &sequenceStore{
+		db: db,
+	}
+}
+
+// Reserve adds count to the connection's sequence counter and returns the
+// first number reserved. It never creates a connection record.
+func (s *sequenceStore) Reserve(ctx context.Context, connectionID string, count int) (uint64, error) {
+	if connectionID == "" {
+		return 0, store.NewValidationError("connectionID", "cannot be empty")
+	}
+	if count < 1 {
+		return 0, store.NewValidationError("count", "must be positive")
+	}
+
+	conn := &Connection{ConnectionID: connectionID}
+	conn.SetKeys()
+
+	var updated Connection
+	err := s.db.Model(conn).
+		Where("pk", "=", conn.PK).
+		Where("sk", "=", conn.SK).
+		UpdateBuilder().
+		Add("sequence", count).
+		ConditionExists("connection_id").
+		ReturnValues("UPDATED_NEW").
+		ExecuteWithResult(&updated)
+	if err != nil {
+		if errors.Is(err, dynamormErrors.ErrConditionFailed) {
+			return 0, store.NewStoreError("Reserve", conn.TableName(), connectionID, store.ErrNotFound)
+		}
+		return 0, store.NewStoreError("Reserve", conn.TableName(), connectionID, fmt.Errorf("failed to reserve sequence: %w", err))
+	}
+
+	// The counter holds the last number reserved
+	return updated.Sequence - uint64(count) + 1, nil
+}
@@ -0,0 +1,93 @@
+package dynamorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dynamormErrors "github.com/pay-theory/dynamorm/pkg/errors"
+	"github.com/pay-theory/dynamorm/pkg/mocks"
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestSequenceStore_Reserve tests the Reserve method
+func TestSequenceStore_Reserve(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		count      int
+		counter    uint64
+		executeErr error
+		want       uint64
+		wantErr    error
+	}{
+		{
+			name:    "first frame",
+			count:   1,
+			counter: 1,
+			want:    1,
+		},
+		{
+			name:    "several frames",
+			count:   3,
+			counter: 10,
+			want:    8,
+		},
+		{
+			name:       "connection gone",
+			count:      1,
+			executeErr: dynamormErrors.ErrConditionFailed,
+			wantErr:    store.ErrNotFound,
+		},
+		{
+			name:       "update error",
+			count:      1,
+			executeErr: errors.New("throttled"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockQuery := new(mocks.MockQuery)
+			mockUpdateBuilder := new(mocks.MockUpdateBuilder)
+
+			mockDB.On("Model", mock.AnythingOfType("*dynamorm.Connection")).Return(mockQuery)
+			mockQuery.On("Where", "pk", "=", "CONN#conn-123").Return(mockQuery)
+			mockQuery.On("Where", "sk", "=", "METADATA").Return(mockQuery)
+			mockQuery.On("UpdateBuilder").Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("Add", "sequence", tt.count).Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("ConditionExists", "connection_id").Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("ReturnValues", "UPDATED_NEW").Return(mockUpdateBuilder)
+			mockUpdateBuilder.On("ExecuteWithResult", mock.AnythingOfType("*dynamorm.Connection")).Run(func(args mock.Arguments) {
+				args.Get(0).(*dynamorm.Connection).Sequence = tt.counter
+			}).Return(tt.executeErr)
+
+			first, err := dynamorm.NewSequenceStore(mockDB).Reserve(ctx, "conn-123", tt.count)
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.executeErr != nil:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, first)
+			}
+			mockUpdateBuilder.AssertExpectations(t)
+		})
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		s := dynamorm.NewSequenceStore(new(mocks.MockDB))
+		var validationErr *store.ValidationError
+		_, err := s.Reserve(ctx, "", 1)
+		assert.ErrorAs(t, err, &validationErr)
+		_, err = s.Reserve(ctx, "conn-123", 0)
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
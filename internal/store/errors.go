@@ -24,6 +24,11 @@ var (
 
 	// ErrConcurrentModification is returned when an item was modified concurrently
 	ErrConcurrentModification = errors.New("item was modified concurrently")
+
+	// ErrConflict is returned when a request update conflicts with the stored
+	// request: its status does not allow the update, or other writers kept
+	// changing it
+	ErrConflict = errors.New("conflicting update")
 )
 
 // StoreError wraps storage-related errors with additional context
@@ -93,6 +98,27 @@ func IsAlreadyExists(err error) bool {
 	return errors.Is(err, ErrAlreadyExists)
 }
 
+// IsConflict checks if an error is a conflict error
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// TransitionError is returned when a request cannot move from its stored
+// status to the one an update asks for. It matches ErrConflict.
+type TransitionError struct {
+	From RequestStatus
+	To   RequestStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: request cannot move from %s to %s", ErrConflict, e.From, e.To)
+}
+
+// Is reports whether target is ErrConflict
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // ValidationError represents input validation errors
 type ValidationError struct {
 	Field   string
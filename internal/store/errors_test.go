@@ -237,6 +237,67 @@ func TestIsAlreadyExists(t *testing.T) {
 	}
 }
 
+// TestIsConflict tests the IsConflict function
+func TestIsConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "direct ErrConflict",
+			err:  ErrConflict,
+			want: true,
+		},
+		{
+			name: "StoreError wrapping ErrConflict",
+			err: &StoreError{
+				Op:  "UpdateStatus",
+				Err: ErrConflict,
+			},
+			want: true,
+		},
+		{
+			name: "StoreError wrapping TransitionError",
+			err: &StoreError{
+				Op:  "UpdateStatus",
+				Err: &TransitionError{From: StatusCompleted, To: StatusProcessing},
+			},
+			want: true,
+		},
+		{
+			name: "different error",
+			err:  ErrConcurrentModification,
+			want: false,
+		},
+		{
+			name: "nil error",
+			err:  nil,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IsConflict(tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestTransitionError tests the TransitionError type
+func TestTransitionError(t *testing.T) {
+	err := &TransitionError{From: StatusCompleted, To: StatusProcessing}
+	assert.Equal(t, "conflicting update: request cannot move from COMPLETED to PROCESSING", err.Error())
+
+	wrapped := NewStoreError("UpdateStatus", "requests", "req-1", err)
+	var transitionErr *TransitionError
+	assert.True(t, errors.As(wrapped, &transitionErr))
+	assert.Equal(t, StatusCompleted, transitionErr.From)
+	assert.Equal(t, StatusProcessing, transitionErr.To)
+	assert.False(t, IsNotFound(wrapped))
+}
+
 // TestValidationError tests the ValidationError type
 func TestValidationError(t *testing.T) {
 	ve := &ValidationError{
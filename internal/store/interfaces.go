@@ -16,15 +16,27 @@ type ConnectionStore interface {
 	// Delete removes a connection
 	Delete(ctx context.Context, connectionID string) error
 
-	// ListByUser returns all connections for a user
-	ListByUser(ctx context.Context, userID string) ([]*Connection, error)
+	// ListByUser returns a page of a user's connections
+	ListByUser(ctx context.Context, userID string, opts PageOptions) (*Page[*Connection], error)
 
-	// ListByTenant returns all connections for a tenant
-	ListByTenant(ctx context.Context, tenantID string) ([]*Connection, error)
+	// ListByTenant returns a page of a tenant's connections
+	ListByTenant(ctx context.Context, tenantID string, opts PageOptions) (*Page[*Connection], error)
 
 	// UpdateLastPing updates the last ping timestamp
 	UpdateLastPing(ctx context.Context, connectionID string) error
 
+	// UpdateClaims replaces a connection's metadata and token expiry after
+	// its token is refreshed
+	UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error
+
+	// ListExpiring returns a page of connections whose token expires before
+	// the specified time
+	ListExpiring(ctx context.Context, before time.Time, opts PageOptions) (*Page[*Connection], error)
+
+	// ListStale returns a page of connections whose last ping is older than
+	// the specified time
+	ListStale(ctx context.Context, before time.Time, opts PageOptions) (*Page[*Connection], error)
+
 	// DeleteStale removes connections older than the specified time
 	DeleteStale(ctx context.Context, before time.Time) error
 }
@@ -50,11 +62,36 @@ type RequestQueue interface {
 	// FailRequest marks a request as failed with an error
 	FailRequest(ctx context.Context, requestID string, errMsg string) error
 
-	// GetByConnection retrieves all requests for a connection
-	GetByConnection(ctx context.Context, connectionID string, limit int) ([]*AsyncRequest, error)
+	// RecordCallbackDelivery appends an attempt to deliver the request's
+	// callback to its delivery log. It is allowed in any status.
+	RecordCallbackDelivery(ctx context.Context, requestID string, delivery CallbackDelivery) error
+
+	// GetByConnection retrieves a page of a connection's requests
+	GetByConnection(ctx context.Context, connectionID string, opts PageOptions) (*Page[*AsyncRequest], error)
+
+	// GetByStatus retrieves a page of requests in a status
+	GetByStatus(ctx context.Context, status RequestStatus, opts PageOptions) (*Page[*AsyncRequest], error)
 
-	// GetByStatus retrieves requests by status
-	GetByStatus(ctx context.Context, status RequestStatus, limit int) ([]*AsyncRequest, error)
+	// GetByUser retrieves a page of a user's requests that match filter,
+	// newest first
+	GetByUser(ctx context.Context, userID string, filter RequestFilter, opts PageOptions) (*Page[*AsyncRequest], error)
+
+	// CountByTenant counts a tenant's requests in any of the given statuses.
+	// An empty action counts requests for every action.
+	CountByTenant(ctx context.Context, tenantID, action string, statuses ...RequestStatus) (int, error)
+
+	// Defer holds a pending request back until the given time, when
+	// ListDeferred returns it. Consumers of the requests stream skip a
+	// pending request whose RetryAfter is still in the future.
+	Defer(ctx context.Context, requestID string, until time.Time) error
+
+	// Resume clears a pending request's RetryAfter so it is processed again.
+	// The write is seen on the requests stream like an insert.
+	Resume(ctx context.Context, requestID string) error
+
+	// ListDeferred retrieves a page of pending requests deferred until due
+	// or earlier, oldest first
+	ListDeferred(ctx context.Context, due time.Time, opts PageOptions) (*Page[*AsyncRequest], error)
 
 	// Get retrieves a specific request
 	Get(ctx context.Context, requestID string) (*AsyncRequest, error)
@@ -71,12 +108,118 @@ type SubscriptionStore interface {
 	// Unsubscribe removes a subscription
 	Unsubscribe(ctx context.Context, connectionID, requestID string) error
 
-	// GetByConnection returns all subscriptions for a connection
-	GetByConnection(ctx context.Context, connectionID string) ([]*Subscription, error)
+	// GetByConnection returns a page of a connection's subscriptions
+	GetByConnection(ctx context.Context, connectionID string, opts PageOptions) (*Page[*Subscription], error)
 
-	// GetByRequest returns all subscriptions for a request
-	GetByRequest(ctx context.Context, requestID string) ([]*Subscription, error)
+	// GetByRequest returns a page of a request's subscriptions
+	GetByRequest(ctx context.Context, requestID string, opts PageOptions) (*Page[*Subscription], error)
 
 	// DeleteByConnection removes all subscriptions for a connection
 	DeleteByConnection(ctx context.Context, connectionID string) error
 }
+
+// RateLimitStore manages token buckets for request rate limiting
+type RateLimitStore interface {
+	// Take consumes one token from the bucket identified by key. The bucket
+	// refills at rate tokens per second up to burst tokens. When no token is
+	// available it returns false and the time until one will be. It returns
+	// ErrConcurrentModification if the bucket is too contended to update.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+
+	// Refund gives back a token taken from the bucket identified by key, for
+	// callers that take from several buckets and are denied by a later one
+	Refund(ctx context.Context, key string, rate float64, burst int) error
+}
+
+// QuotaStore manages per-tenant async quotas
+type QuotaStore interface {
+	// Get retrieves the quota for a tenant and action
+	Get(ctx context.Context, tenantID, action string) (*TenantQuota, error)
+
+	// Save creates or replaces a quota
+	Save(ctx context.Context, quota *TenantQuota) error
+
+	// Delete removes a quota
+	Delete(ctx context.Context, tenantID, action string) error
+
+	// ListByTenant returns all quotas for a tenant
+	ListByTenant(ctx context.Context, tenantID string) ([]*TenantQuota, error)
+}
+
+// ConcurrencyStore keeps leases on the slots of each tenant's concurrency cap,
+// so that every processor instance enforces the same cap. Only unexpired
+// leases hold a slot, so a processor that dies without releasing its lease
+// gives the slot back once the lease expires.
+type ConcurrencyStore interface {
+	// Acquire takes a lease under the cap identified by key if fewer than limit
+	// unexpired leases are held, returning the lease ID, or an empty ID when the
+	// cap is reached. The lease expires at expiresAt.
+	Acquire(ctx context.Context, key string, limit int, expiresAt time.Time) (string, error)
+
+	// Release gives back a lease. Releasing a lease that has expired or was
+	// already released does nothing.
+	Release(ctx context.Context, key, lease string) error
+}
+
+// ResultStore holds async results too large to keep on the request item
+type ResultStore interface {
+	// Put writes a result; a key that is already taken is reported as ErrAlreadyExists
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get reads a result; a missing key is reported as ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Delete removes a result
+	Delete(ctx context.Context, key string) error
+}
+
+// CircuitBreakerStore persists per-connection circuit breaker state so every
+// Lambda instance sees the same breakers
+type CircuitBreakerStore interface {
+	// Get retrieves the breaker state for a connection; a closed breaker with
+	// no recorded failures is reported as ErrNotFound
+	Get(ctx context.Context, connectionID string) (*CircuitBreakerState, error)
+
+	// Save writes state if its Version still matches the stored version, then
+	// increments Version. Otherwise it returns ErrConcurrentModification.
+	Save(ctx context.Context, state *CircuitBreakerState) error
+
+	// Delete clears the breaker state for a connection
+	Delete(ctx context.Context, connectionID string) error
+}
+
+// SequenceStore counts the frames sent to each connection, so every Lambda
+// instance numbers a connection's messages from the same sequence
+type SequenceStore interface {
+	// Reserve atomically reserves count sequence numbers for a connection
+	// and returns the first. Numbers start at 1. It returns ErrNotFound when
+	// the connection does not exist.
+	Reserve(ctx context.Context, connectionID string, count int) (uint64, error)
+}
+
+// DeliveryStore persists messages awaiting a client ack
+type DeliveryStore interface {
+	// Save creates or replaces a pending delivery
+	Save(ctx context.Context, delivery *PendingDelivery) error
+
+	// Delete removes a pending delivery; deleting one that does not exist is not an error
+	Delete(ctx context.Context, recipient, deliveryID string) error
+
+	// ListByRecipient returns a recipient's pending deliveries, oldest first
+	ListByRecipient(ctx context.Context, recipient string) ([]*PendingDelivery, error)
+
+	// ListDue returns up to limit pending deliveries whose NextAttemptAt is not after before
+	ListDue(ctx context.Context, before time.Time, limit int) ([]*PendingDelivery, error)
+}
+
+// CallbackStore schedules callback deliveries for finished requests
+type CallbackStore interface {
+	// Save creates or replaces the pending callback for a request
+	Save(ctx context.Context, callback *PendingCallback) error
+
+	// Delete removes a request's pending callback; deleting one that does not exist is not an error
+	Delete(ctx context.Context, requestID string) error
+
+	// ListDue returns up to limit pending callbacks whose NextAttemptAt is not after before
+	ListDue(ctx context.Context, before time.Time, limit int) ([]*PendingCallback, error)
+}
@@ -0,0 +1,88 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Hook is told about each status change a request queue makes
+type Hook interface {
+	// OnTransition is called after req has moved from one status to another.
+	// req is the request as written; hooks share it and must not modify it.
+	// Hooks run in the caller's goroutine, so slow work such as an HTTP call
+	// should be handed off.
+	OnTransition(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest)
+}
+
+// HookFunc adapts a function to a Hook
+type HookFunc func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest)
+
+// OnTransition calls f
+func (f HookFunc) OnTransition(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+	f(ctx, from, to, req)
+}
+
+// Hooks is the set of hooks a request queue notifies. Request queues embed it;
+// the zero value notifies nobody. It is safe for concurrent use.
+type Hooks struct {
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+// AddHook registers a hook. Hooks are notified in the order they were added.
+func (h *Hooks) AddHook(hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, hook)
+}
+
+// HasHooks reports whether any hook is registered, so a queue can skip the
+// work of building the request it would pass to them
+func (h *Hooks) HasHooks() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.hooks) > 0
+}
+
+// Notify tells every registered hook that req moved from one status to another
+func (h *Hooks) Notify(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+	h.mu.RLock()
+	hooks := h.hooks
+	h.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook.OnTransition(ctx, from, to, req)
+	}
+}
+
+// Observable is implemented by request queues that notify hooks
+type Observable interface {
+	AddHook(hook Hook)
+}
+
+// ErrNotObservable is returned by Observe for a queue that does not notify hooks
+var ErrNotObservable = errors.New("request queue does not support transition hooks")
+
+// Observe registers hooks with a request queue
+func Observe(queue store.RequestQueue, hooks ...Hook) error {
+	observable, ok := queue.(Observable)
+	if !ok {
+		return ErrNotObservable
+	}
+	for _, hook := range hooks {
+		observable.AddHook(hook)
+	}
+	return nil
+}
+
+// NewAuditLog returns a hook that logs every status change
+func NewAuditLog(logger *log.Logger) Hook {
+	return HookFunc(func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+		logger.Printf("Request %s (action=%s, tenant=%s, user=%s) moved from %s to %s, version %d",
+			req.RequestID, req.Action, req.TenantID, req.UserID, from, to, req.Version)
+	})
+}
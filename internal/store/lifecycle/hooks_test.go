@@ -0,0 +1,71 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+	"github.com/pay-theory/streamer/internal/store/memory"
+)
+
+func TestHooks_Notify(t *testing.T) {
+	var hooks lifecycle.Hooks
+	assert.False(t, hooks.HasHooks())
+
+	// The zero value notifies nobody
+	req := &store.AsyncRequest{RequestID: "req-1"}
+	hooks.Notify(context.Background(), store.StatusPending, store.StatusProcessing, req)
+
+	var calls []string
+	for _, name := range []string{"first", "second"} {
+		name := name
+		hooks.AddHook(lifecycle.HookFunc(func(ctx context.Context, from, to store.RequestStatus, got *store.AsyncRequest) {
+			assert.Same(t, req, got)
+			calls = append(calls, name+":"+string(from)+"->"+string(to))
+		}))
+	}
+	assert.True(t, hooks.HasHooks())
+
+	hooks.Notify(context.Background(), store.StatusPending, store.StatusProcessing, req)
+	assert.Equal(t, []string{"first:PENDING->PROCESSING", "second:PENDING->PROCESSING"}, calls)
+}
+
+// fixedQueue is a request queue that does not notify hooks
+type fixedQueue struct {
+	store.RequestQueue
+}
+
+func TestObserve(t *testing.T) {
+	queue := memory.NewRequestQueue()
+	var moves []store.RequestStatus
+	require.NoError(t, lifecycle.Observe(queue, lifecycle.HookFunc(func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+		moves = append(moves, to)
+	})))
+
+	ctx := context.Background()
+	require.NoError(t, queue.Enqueue(ctx, &store.AsyncRequest{RequestID: "req-1", ConnectionID: "conn-1", Action: "generate_report", UserID: "user-1", TenantID: "tenant-1"}))
+	require.NoError(t, queue.UpdateStatus(ctx, "req-1", store.StatusProcessing, ""))
+	assert.Equal(t, []store.RequestStatus{store.StatusProcessing}, moves)
+
+	assert.ErrorIs(t, lifecycle.Observe(fixedQueue{queue}), lifecycle.ErrNotObservable)
+}
+
+func TestNewAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	hook := lifecycle.NewAuditLog(log.New(&buf, "", 0))
+
+	hook.OnTransition(context.Background(), store.StatusProcessing, store.StatusCompleted, &store.AsyncRequest{
+		RequestID: "req-1",
+		Action:    "generate_report",
+		TenantID:  "tenant-1",
+		UserID:    "user-1",
+		Version:   3,
+	})
+	assert.Equal(t, "Request req-1 (action=generate_report, tenant=tenant-1, user=user-1) moved from PROCESSING to COMPLETED, version 3\n", buf.String())
+}
@@ -0,0 +1,62 @@
+// Package lifecycle defines how an async request moves between statuses.
+//
+// The request queues in the store backends check every status change against
+// the transitions allowed here and refuse the rest with a store.TransitionError.
+// Hooks registered with a queue are told about each change it makes, so
+// notifications, metrics, audit logging and webhooks hang off one place
+// rather than every caller that changes a status.
+package lifecycle
+
+import (
+	"fmt"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// transitions lists the statuses a request may move to from each status.
+// COMPLETED and CANCELLED are final. A FAILED request may only be retried.
+var transitions = map[store.RequestStatus][]store.RequestStatus{
+	store.StatusPending:    {store.StatusPending, store.StatusProcessing, store.StatusFailed, store.StatusCancelled},
+	store.StatusProcessing: {store.StatusPending, store.StatusProcessing, store.StatusCompleted, store.StatusFailed, store.StatusCancelled, store.StatusRetrying},
+	store.StatusRetrying:   {store.StatusPending, store.StatusProcessing, store.StatusRetrying, store.StatusFailed, store.StatusCancelled},
+	store.StatusFailed:     {store.StatusRetrying},
+}
+
+// IsTerminal reports whether a request in the status is finished. Progress
+// is no longer recorded for a finished request.
+func IsTerminal(status store.RequestStatus) bool {
+	switch status {
+	case store.StatusCompleted, store.StatusFailed, store.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a request may move from one status to
+// another. Moving to the same status rewrites the request.
+func CanTransition(from, to store.RequestStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a store.TransitionError if a request may not move from one
+// status to another
+func Check(from, to store.RequestStatus) error {
+	if !CanTransition(from, to) {
+		return &store.TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// CheckProgress returns an error matching store.ErrConflict if progress may
+// not be recorded for a request in the status
+func CheckProgress(status store.RequestStatus) error {
+	if IsTerminal(status) {
+		return fmt.Errorf("%w: request is already %s", store.ErrConflict, status)
+	}
+	return nil
+}
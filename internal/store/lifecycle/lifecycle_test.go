@@ -0,0 +1,60 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// TestCheck tests the request status state machine
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		from    store.RequestStatus
+		to      store.RequestStatus
+		allowed bool
+	}{
+		{store.StatusPending, store.StatusProcessing, true},
+		{store.StatusPending, store.StatusPending, true},
+		{store.StatusPending, store.StatusCancelled, true},
+		{store.StatusPending, store.StatusCompleted, false},
+		{store.StatusProcessing, store.StatusCompleted, true},
+		{store.StatusProcessing, store.StatusFailed, true},
+		{store.StatusProcessing, store.StatusRetrying, true},
+		{store.StatusRetrying, store.StatusProcessing, true},
+		{store.StatusRetrying, store.StatusCompleted, false},
+		{store.StatusFailed, store.StatusRetrying, true},
+		{store.StatusFailed, store.StatusProcessing, false},
+		{store.StatusCompleted, store.StatusProcessing, false},
+		{store.StatusCompleted, store.StatusCompleted, false},
+		{store.StatusCancelled, store.StatusPending, false},
+		{"", store.StatusPending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.allowed, CanTransition(tt.from, tt.to))
+
+			err := Check(tt.from, tt.to)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, store.IsConflict(err))
+			assert.Equal(t, &store.TransitionError{From: tt.from, To: tt.to}, err)
+		})
+	}
+}
+
+// TestCheckProgress tests which statuses accept progress updates
+func TestCheckProgress(t *testing.T) {
+	for _, status := range []store.RequestStatus{store.StatusPending, store.StatusProcessing, store.StatusRetrying} {
+		assert.False(t, IsTerminal(status), status)
+		assert.NoError(t, CheckProgress(status), status)
+	}
+	for _, status := range []store.RequestStatus{store.StatusCompleted, store.StatusFailed, store.StatusCancelled} {
+		assert.True(t, IsTerminal(status), status)
+		assert.True(t, store.IsConflict(CheckProgress(status)), status)
+	}
+}
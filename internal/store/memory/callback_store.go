@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// callbackStore implements CallbackStore in memory
+type callbackStore struct {
+	mu        sync.Mutex
+	callbacks map[string]store.PendingCallback
+}
+
+// NewCallbackStore creates a new in-memory pending callback store
+func NewCallbackStore() store.CallbackStore {
+	return &callbackStore{
+		callbacks: make(map[string]store.PendingCallback),
+	}
+}
+
+// Save creates or replaces the pending callback for a request
+func (s *callbackStore) Save(ctx context.Context, callback *store.PendingCallback) error {
+	if callback == nil {
+		return store.NewValidationError("callback", "cannot be nil")
+	}
+	if callback.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+
+	if callback.CreatedAt.IsZero() {
+		callback.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks[callback.RequestID] = *callback
+	return nil
+}
+
+// Delete removes a request's pending callback
+func (s *callbackStore) Delete(ctx context.Context, requestID string) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.callbacks, requestID)
+	return nil
+}
+
+// ListDue returns pending callbacks whose next attempt is due, earliest first
+func (s *callbackStore) ListDue(ctx context.Context, before time.Time, limit int) ([]*store.PendingCallback, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	due := make([]*store.PendingCallback, 0)
+	for _, callback := range s.callbacks {
+		if expired(callback.TTL, now) || callback.NextAttemptAt.After(before) {
+			continue
+		}
+		c := callback
+		due = append(due, &c)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+func TestCallbackStore(t *testing.T) {
+	ctx := context.Background()
+	callbacks := NewCallbackStore()
+	now := time.Now()
+
+	require.NoError(t, callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-late", NextAttemptAt: now.Add(time.Minute)}))
+	require.NoError(t, callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-2", NextAttemptAt: now}))
+	require.NoError(t, callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-1", NextAttemptAt: now.Add(-time.Second)}))
+	require.NoError(t, callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-expired", TTL: now.Add(-time.Hour).Unix()}))
+
+	due, err := callbacks.ListDue(ctx, now, 0)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "req-1", due[0].RequestID)
+	assert.Equal(t, "req-2", due[1].RequestID)
+	assert.False(t, due[0].CreatedAt.IsZero())
+
+	// Saving again replaces the request's callback
+	require.NoError(t, callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-1", Attempts: 1, NextAttemptAt: now.Add(time.Hour)}))
+	due, err = callbacks.ListDue(ctx, now, 1)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "req-2", due[0].RequestID)
+
+	require.NoError(t, callbacks.Delete(ctx, "req-2"))
+	require.NoError(t, callbacks.Delete(ctx, "req-missing"))
+	due, err = callbacks.ListDue(ctx, now, 0)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	assert.Error(t, callbacks.Save(ctx, nil))
+	assert.Error(t, callbacks.Save(ctx, &store.PendingCallback{}))
+	assert.Error(t, callbacks.Delete(ctx, ""))
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// concurrencyStore implements ConcurrencyStore in memory
+type concurrencyStore struct {
+	mu     sync.Mutex
+	leases map[string]map[string]time.Time
+	next   int
+}
+
+// NewConcurrencyStore creates a new in-memory concurrency lease store
+func NewConcurrencyStore() store.ConcurrencyStore {
+	return &concurrencyStore{
+		leases: make(map[string]map[string]time.Time),
+	}
+}
+
+// Acquire takes a lease if fewer than limit unexpired leases are held,
+// dropping the expired ones
+func (s *concurrencyStore) Acquire(ctx context.Context, key string, limit int, expiresAt time.Time) (string, error) {
+	if key == "" {
+		return "", store.NewValidationError("key", "cannot be empty")
+	}
+	if limit <= 0 {
+		return "", store.NewValidationError("limit", "must be positive")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	leases := s.leases[key]
+	if leases == nil {
+		leases = make(map[string]time.Time)
+		s.leases[key] = leases
+	}
+
+	now := time.Now()
+	for lease, expiry := range leases {
+		if !expiry.After(now) {
+			delete(leases, lease)
+		}
+	}
+	if len(leases) >= limit {
+		return "", nil
+	}
+
+	s.next++
+	lease := fmt.Sprintf("lease-%d", s.next)
+	leases[lease] = expiresAt
+	return lease, nil
+}
+
+// Release gives back a lease
+func (s *concurrencyStore) Release(ctx context.Context, key, lease string) error {
+	if key == "" {
+		return store.NewValidationError("key", "cannot be empty")
+	}
+	if lease == "" {
+		return store.NewValidationError("lease", "cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.leases[key], lease)
+	return nil
+}
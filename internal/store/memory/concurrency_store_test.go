@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestConcurrencyStore_Conformance(t *testing.T) {
+	storetest.RunConcurrencyStoreTests(t, func(t *testing.T) store.ConcurrencyStore {
+		return NewConcurrencyStore()
+	})
+}
@@ -0,0 +1,208 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// connectionStore implements ConnectionStore in memory
+type connectionStore struct {
+	mu          sync.RWMutex
+	connections map[string]*store.Connection
+	now         func() time.Time
+}
+
+// NewConnectionStore creates a new in-memory connection store
+func NewConnectionStore() store.ConnectionStore {
+	return &connectionStore{
+		connections: make(map[string]*store.Connection),
+		now:         time.Now,
+	}
+}
+
+// Save creates or updates a connection
+func (s *connectionStore) Save(ctx context.Context, conn *store.Connection) error {
+	if err := validateConnection(conn); err != nil {
+		return err
+	}
+
+	// Set TTL to 24 hours from now if not set
+	if conn.TTL == 0 {
+		conn.TTL = s.now().Add(24 * time.Hour).Unix()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connections[conn.ConnectionID] = copyConnection(conn)
+	return nil
+}
+
+// Get retrieves a connection by ID
+func (s *connectionStore) Get(ctx context.Context, connectionID string) (*store.Connection, error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conn, ok := s.lookup(connectionID)
+	if !ok {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, store.ErrNotFound)
+	}
+	return copyConnection(conn), nil
+}
+
+// Delete removes a connection; deleting one that does not exist is not an error
+func (s *connectionStore) Delete(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.connections, connectionID)
+	return nil
+}
+
+// ListByUser returns a page of a user's connections
+func (s *connectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+	return store.PageItems(s.list(func(conn *store.Connection) bool { return conn.UserID == userID }), opts, connectionKey)
+}
+
+// ListByTenant returns a page of a tenant's connections
+func (s *connectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if tenantID == "" {
+		return nil, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	return store.PageItems(s.list(func(conn *store.Connection) bool { return conn.TenantID == tenantID }), opts, connectionKey)
+}
+
+// UpdateLastPing updates the last ping timestamp
+func (s *connectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
+	return s.update("UpdateLastPing", connectionID, func(conn *store.Connection) {
+		now := s.now()
+		conn.LastPing = now
+		conn.TTL = now.Add(24 * time.Hour).Unix()
+	})
+}
+
+// UpdateClaims replaces a connection's metadata and token expiry
+func (s *connectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	return s.update("UpdateClaims", connectionID, func(conn *store.Connection) {
+		conn.Metadata = copyStrings(metadata)
+		conn.TokenExpiresAt = tokenExpiresAt
+	})
+}
+
+// ListExpiring returns a page of connections whose token expires before the specified time
+func (s *connectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return store.PageItems(s.list(func(conn *store.Connection) bool {
+		return !conn.TokenExpiresAt.IsZero() && conn.TokenExpiresAt.Before(before)
+	}), opts, connectionKey)
+}
+
+// ListStale returns a page of connections whose last ping is older than the specified time
+func (s *connectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return store.PageItems(s.list(func(conn *store.Connection) bool { return conn.LastPing.Before(before) }), opts, connectionKey)
+}
+
+// DeleteStale removes connections whose last ping is older than the specified time
+func (s *connectionStore) DeleteStale(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, conn := range s.connections {
+		if conn.LastPing.Before(before) {
+			delete(s.connections, id)
+		}
+	}
+	return nil
+}
+
+// lookup returns a live connection; callers must hold s.mu
+func (s *connectionStore) lookup(connectionID string) (*store.Connection, bool) {
+	conn, ok := s.connections[connectionID]
+	if !ok || expired(conn.TTL, s.now()) {
+		return nil, false
+	}
+	return conn, true
+}
+
+// update applies fn to a stored connection
+func (s *connectionStore) update(op, connectionID string, fn func(*store.Connection)) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn, ok := s.lookup(connectionID)
+	if !ok {
+		return store.NewStoreError(op, store.ConnectionsTable, connectionID, store.ErrNotFound)
+	}
+	fn(conn)
+	return nil
+}
+
+// list returns copies of the live connections matching fn, oldest first
+func (s *connectionStore) list(fn func(*store.Connection) bool) []*store.Connection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	result := make([]*store.Connection, 0)
+	for _, conn := range s.connections {
+		if !expired(conn.TTL, now) && fn(conn) {
+			result = append(result, copyConnection(conn))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].ConnectedAt.Equal(result[j].ConnectedAt) {
+			return result[i].ConnectedAt.Before(result[j].ConnectedAt)
+		}
+		return result[i].ConnectionID < result[j].ConnectionID
+	})
+	return result
+}
+
+// connectionKey orders connections by connection time, then ID
+func connectionKey(conn *store.Connection) store.Cursor {
+	return store.Cursor{At: unixNanos(conn.ConnectedAt), ID: conn.ConnectionID}
+}
+
+// copyConnection returns a copy of a connection that shares no maps with it
+func copyConnection(conn *store.Connection) *store.Connection {
+	c := *conn
+	c.Metadata = copyStrings(conn.Metadata)
+	return &c
+}
+
+// validateConnection validates a connection before saving
+func validateConnection(conn *store.Connection) error {
+	if conn == nil {
+		return store.NewValidationError("connection", "cannot be nil")
+	}
+	if conn.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if conn.UserID == "" {
+		return store.NewValidationError("UserID", "cannot be empty")
+	}
+	if conn.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	if conn.Endpoint == "" {
+		return store.NewValidationError("Endpoint", "cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestConnectionStore_Conformance(t *testing.T) {
+	storetest.RunConnectionStoreTests(t, func(t *testing.T) store.ConnectionStore {
+		return NewConnectionStore()
+	})
+}
+
+func TestConnectionStore_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewConnectionStore().(*connectionStore)
+	s.now = func() time.Time { return now }
+
+	conn := &store.Connection{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Endpoint:     "wss://example.com",
+		LastPing:     now,
+		TTL:          now.Add(time.Minute).Unix(),
+	}
+	require.NoError(t, s.Save(ctx, conn))
+
+	_, err := s.Get(ctx, "conn-1")
+	require.NoError(t, err)
+
+	s.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+	_, err = s.Get(ctx, "conn-1")
+	assert.True(t, store.IsNotFound(err))
+	assert.True(t, store.IsNotFound(s.UpdateLastPing(ctx, "conn-1")))
+
+	conns, err := s.ListByUser(ctx, "user-1", store.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, conns.Items)
+}
+
+func TestConnectionStore_UpdateLastPingExtendsTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewConnectionStore().(*connectionStore)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Save(ctx, &store.Connection{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Endpoint:     "wss://example.com",
+		TTL:          now.Add(time.Minute).Unix(),
+	}))
+
+	s.now = func() time.Time { return now.Add(30 * time.Second) }
+	require.NoError(t, s.UpdateLastPing(ctx, "conn-1"))
+
+	conn, err := s.Get(ctx, "conn-1")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Second+24*time.Hour).Unix(), conn.TTL)
+}
+
+func TestConnectionStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewConnectionStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("conn-%d", i)
+			assert.NoError(t, s.Save(ctx, &store.Connection{
+				ConnectionID: id,
+				UserID:       "user-1",
+				TenantID:     "tenant-1",
+				Endpoint:     "wss://example.com",
+			}))
+			assert.NoError(t, s.UpdateLastPing(ctx, id))
+			_, err := s.ListByTenant(ctx, "tenant-1", store.PageOptions{})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	conns, err := s.ListByTenant(ctx, "tenant-1", store.PageOptions{})
+	require.NoError(t, err)
+	assert.Len(t, conns.Items, 50)
+}
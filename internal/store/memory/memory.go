@@ -0,0 +1,59 @@
+// Package memory implements the store interfaces in process memory.
+//
+// The stores behave like their DynamORM counterparts: records are copied on
+// the way in and out, index queries return the same records, expired TTLs hide
+// records and missing records are reported as store.ErrNotFound. They are safe
+// for concurrent use and intended for tests and local development.
+package memory
+
+import (
+	"time"
+)
+
+// expired reports whether a record with the given TTL (Unix seconds) has
+// expired. A zero TTL never expires.
+func expired(ttl int64, now time.Time) bool {
+	return ttl != 0 && ttl <= now.Unix()
+}
+
+// unixNanos converts a time to Unix nanoseconds for a page cursor; the zero
+// time is 0
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// copyStrings returns a copy of a string map
+func copyStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// copyValues returns a shallow copy of a value map
+func copyValues(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// copyTime returns a copy of a time pointer
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
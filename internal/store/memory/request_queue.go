@@ -0,0 +1,387 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+)
+
+// requestQueue implements RequestQueue in memory
+type requestQueue struct {
+	lifecycle.Hooks
+
+	mu       sync.RWMutex
+	requests map[string]*store.AsyncRequest
+	now      func() time.Time
+}
+
+// NewRequestQueue creates a new in-memory request queue
+func NewRequestQueue() store.RequestQueue {
+	return &requestQueue{
+		requests: make(map[string]*store.AsyncRequest),
+		now:      time.Now,
+	}
+}
+
+// Enqueue adds a new request to the queue
+func (q *requestQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) error {
+	if err := validateRequest(req); err != nil {
+		return err
+	}
+
+	// Set default values
+	now := q.now()
+	if req.Status == "" {
+		req.Status = store.StatusPending
+	}
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = now
+	}
+	if req.TTL == 0 {
+		req.TTL = now.Add(7 * 24 * time.Hour).Unix() // 7 days TTL
+	}
+	req.Version = 1
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.lookup(req.RequestID); ok {
+		return store.NewStoreError("Enqueue", store.RequestsTable, req.RequestID, store.ErrAlreadyExists)
+	}
+	q.requests[req.RequestID] = copyRequest(req)
+	return nil
+}
+
+// Dequeue marks up to limit pending requests, oldest first, as processing and returns them
+func (q *requestQueue) Dequeue(ctx context.Context, limit int) ([]*store.AsyncRequest, error) {
+	q.mu.Lock()
+	pending := q.list(func(req *store.AsyncRequest) bool { return req.Status == store.StatusPending }, limit)
+	now := q.now()
+	result := make([]*store.AsyncRequest, len(pending))
+	for i, req := range pending {
+		req.Status = store.StatusProcessing
+		req.ProcessingStarted = &now
+		req.Version++
+		result[i] = copyRequest(req)
+	}
+	q.mu.Unlock()
+
+	for _, req := range result {
+		q.Notify(ctx, store.StatusPending, store.StatusProcessing, copyRequest(req))
+	}
+	return result, nil
+}
+
+// UpdateStatus updates the status of a request
+func (q *requestQueue) UpdateStatus(ctx context.Context, requestID string, status store.RequestStatus, message string) error {
+	return q.update(ctx, "UpdateStatus", requestID, status, func(req *store.AsyncRequest, now time.Time) error {
+		switch status {
+		case store.StatusProcessing:
+			if req.ProcessingStarted == nil {
+				req.ProcessingStarted = &now
+			}
+		case store.StatusCompleted, store.StatusFailed, store.StatusCancelled:
+			req.ProcessingEnded = &now
+		}
+		return nil
+	})
+}
+
+// UpdateProgress updates the progress of a request
+func (q *requestQueue) UpdateProgress(ctx context.Context, requestID string, progress float64, message string, details map[string]interface{}) error {
+	return q.update(ctx, "UpdateProgress", requestID, "", func(req *store.AsyncRequest, now time.Time) error {
+		if err := lifecycle.CheckProgress(req.Status); err != nil {
+			return err
+		}
+		req.Progress = progress
+		req.ProgressMessage = message
+		req.ProgressDetails = copyValues(details)
+		return nil
+	})
+}
+
+// CompleteRequest marks a request as completed with results
+func (q *requestQueue) CompleteRequest(ctx context.Context, requestID string, result map[string]interface{}) error {
+	return q.update(ctx, "CompleteRequest", requestID, store.StatusCompleted, func(req *store.AsyncRequest, now time.Time) error {
+		req.ProcessingEnded = &now
+		req.Result = copyValues(result)
+		req.Progress = 100
+		return nil
+	})
+}
+
+// FailRequest marks a request as failed with an error
+func (q *requestQueue) FailRequest(ctx context.Context, requestID string, errMsg string) error {
+	return q.update(ctx, "FailRequest", requestID, store.StatusFailed, func(req *store.AsyncRequest, now time.Time) error {
+		req.ProcessingEnded = &now
+		req.Error = errMsg
+		return nil
+	})
+}
+
+// RecordCallbackDelivery appends an attempt to deliver the request's callback to its delivery log
+func (q *requestQueue) RecordCallbackDelivery(ctx context.Context, requestID string, delivery store.CallbackDelivery) error {
+	return q.update(ctx, "RecordCallbackDelivery", requestID, "", func(req *store.AsyncRequest, now time.Time) error {
+		req.CallbackDeliveries = append(req.CallbackDeliveries, delivery)
+		return nil
+	})
+}
+
+// GetByConnection retrieves a page of a connection's requests
+func (q *requestQueue) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+	return q.page(func(req *store.AsyncRequest) bool { return req.ConnectionID == connectionID }, opts, requestKey)
+}
+
+// GetByStatus retrieves a page of requests in a status
+func (q *requestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return q.page(func(req *store.AsyncRequest) bool { return req.Status == status }, opts, requestKey)
+}
+
+// GetByUser retrieves a page of a user's requests that match filter, newest first
+func (q *requestQueue) GetByUser(ctx context.Context, userID string, filter store.RequestFilter, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+	return q.page(func(req *store.AsyncRequest) bool { return req.UserID == userID && filter.Matches(req) }, opts, newestRequestKey)
+}
+
+// CountByTenant counts a tenant's requests in any of the given statuses
+func (q *requestQueue) CountByTenant(ctx context.Context, tenantID, action string, statuses ...store.RequestStatus) (int, error) {
+	if tenantID == "" {
+		return 0, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	if len(statuses) == 0 {
+		return 0, store.NewValidationError("statuses", "at least one status is required")
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	matches := q.list(func(req *store.AsyncRequest) bool {
+		if req.TenantID != tenantID || (action != "" && req.Action != action) {
+			return false
+		}
+		for _, status := range statuses {
+			if req.Status == status {
+				return true
+			}
+		}
+		return false
+	}, 0)
+	return len(matches), nil
+}
+
+// Defer holds a pending request back until the given time
+func (q *requestQueue) Defer(ctx context.Context, requestID string, until time.Time) error {
+	return q.update(ctx, "Defer", requestID, "", func(req *store.AsyncRequest, now time.Time) error {
+		if req.Status != store.StatusPending {
+			return store.ErrRequestNotPending
+		}
+		req.RetryAfter = until
+		return nil
+	})
+}
+
+// Resume clears a pending request's RetryAfter so it is processed again
+func (q *requestQueue) Resume(ctx context.Context, requestID string) error {
+	return q.update(ctx, "Resume", requestID, "", func(req *store.AsyncRequest, now time.Time) error {
+		if req.Status != store.StatusPending {
+			return store.ErrRequestNotPending
+		}
+		req.RetryAfter = time.Time{}
+		return nil
+	})
+}
+
+// ListDeferred retrieves a page of pending requests deferred until due or earlier
+func (q *requestQueue) ListDeferred(ctx context.Context, due time.Time, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return q.page(func(req *store.AsyncRequest) bool {
+		return req.Status == store.StatusPending && !req.RetryAfter.IsZero() && !req.RetryAfter.After(due)
+	}, opts, requestKey)
+}
+
+// Get retrieves a specific request
+func (q *requestQueue) Get(ctx context.Context, requestID string) (*store.AsyncRequest, error) {
+	if requestID == "" {
+		return nil, store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	req, ok := q.lookup(requestID)
+	if !ok {
+		return nil, store.NewStoreError("Get", store.RequestsTable, requestID, store.ErrNotFound)
+	}
+	return copyRequest(req), nil
+}
+
+// Delete removes a request
+func (q *requestQueue) Delete(ctx context.Context, requestID string) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.lookup(requestID); !ok {
+		return store.NewStoreError("Delete", store.RequestsTable, requestID, store.ErrNotFound)
+	}
+	delete(q.requests, requestID)
+	return nil
+}
+
+// lookup returns a live request; callers must hold q.mu
+func (q *requestQueue) lookup(requestID string) (*store.AsyncRequest, bool) {
+	req, ok := q.requests[requestID]
+	if !ok || expired(req.TTL, q.now()) {
+		return nil, false
+	}
+	return req, true
+}
+
+// update moves a stored request to status to, applies fn to it and
+// increments its version. An empty to leaves the status alone. fn refuses the
+// update by returning an error before changing the request. Hooks are
+// notified once the lock is released.
+func (q *requestQueue) update(ctx context.Context, op, requestID string, to store.RequestStatus, fn func(*store.AsyncRequest, time.Time) error) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	q.mu.Lock()
+	req, ok := q.lookup(requestID)
+	if !ok {
+		q.mu.Unlock()
+		return store.NewStoreError(op, store.RequestsTable, requestID, store.ErrNotFound)
+	}
+
+	from := req.Status
+	if to != "" {
+		if err := lifecycle.Check(from, to); err != nil {
+			q.mu.Unlock()
+			return store.NewStoreError(op, store.RequestsTable, requestID, err)
+		}
+	}
+	if err := fn(req, q.now()); err != nil {
+		q.mu.Unlock()
+		return store.NewStoreError(op, store.RequestsTable, requestID, err)
+	}
+	if to != "" {
+		req.Status = to
+	}
+	req.Version++
+	updated := copyRequest(req)
+	q.mu.Unlock()
+
+	if to != "" {
+		q.Notify(ctx, from, to, updated)
+	}
+	return nil
+}
+
+// list returns up to limit live requests matching fn, oldest first. A limit
+// of zero or less returns every match. Callers must hold q.mu.
+func (q *requestQueue) list(fn func(*store.AsyncRequest) bool, limit int) []*store.AsyncRequest {
+	now := q.now()
+	result := make([]*store.AsyncRequest, 0)
+	for _, req := range q.requests {
+		if !expired(req.TTL, now) && fn(req) {
+			result = append(result, req)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].RequestID < result[j].RequestID
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// page returns copies of the page of live requests matching fn that opts
+// selects, in key order
+func (q *requestQueue) page(fn func(*store.AsyncRequest) bool, opts store.PageOptions, key func(*store.AsyncRequest) store.Cursor) (*store.Page[*store.AsyncRequest], error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	result, err := store.PageItems(q.list(fn, 0), opts, key)
+	if err != nil {
+		return nil, err
+	}
+	result.Items = copyRequests(result.Items)
+	return result, nil
+}
+
+// requestKey orders requests by creation time, then ID
+func requestKey(req *store.AsyncRequest) store.Cursor {
+	return store.Cursor{At: unixNanos(req.CreatedAt), ID: req.RequestID}
+}
+
+// newestRequestKey orders requests newest first, then by ID
+func newestRequestKey(req *store.AsyncRequest) store.Cursor {
+	return store.Cursor{At: -unixNanos(req.CreatedAt), ID: req.RequestID}
+}
+
+// copyRequests copies each request in a list
+func copyRequests(requests []*store.AsyncRequest) []*store.AsyncRequest {
+	result := make([]*store.AsyncRequest, len(requests))
+	for i, req := range requests {
+		result[i] = copyRequest(req)
+	}
+	return result
+}
+
+// copyRequest returns a copy of a request that shares no maps, slices or
+// pointers with it
+func copyRequest(req *store.AsyncRequest) *store.AsyncRequest {
+	c := *req
+	c.Payload = copyValues(req.Payload)
+	c.Result = copyValues(req.Result)
+	c.ProgressDetails = copyValues(req.ProgressDetails)
+	c.ProcessingStarted = copyTime(req.ProcessingStarted)
+	c.ProcessingEnded = copyTime(req.ProcessingEnded)
+	if req.Permissions != nil {
+		c.Permissions = append([]string(nil), req.Permissions...)
+	}
+	if req.CallbackDeliveries != nil {
+		c.CallbackDeliveries = append([]store.CallbackDelivery(nil), req.CallbackDeliveries...)
+	}
+	return &c
+}
+
+// validateRequest validates a request before saving
+func validateRequest(req *store.AsyncRequest) error {
+	if req == nil {
+		return store.NewValidationError("request", "cannot be nil")
+	}
+	if req.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+	if req.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if req.Action == "" {
+		return store.NewValidationError("Action", "cannot be empty")
+	}
+	if req.UserID == "" {
+		return store.NewValidationError("UserID", "cannot be empty")
+	}
+	if req.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestRequestQueue_Conformance(t *testing.T) {
+	storetest.RunRequestQueueTests(t, func(t *testing.T) store.RequestQueue {
+		return NewRequestQueue()
+	})
+}
+
+func newTestRequest(id string, createdAt time.Time) *store.AsyncRequest {
+	return &store.AsyncRequest{
+		RequestID:    id,
+		ConnectionID: "conn-1",
+		Action:       "generate_report",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		CreatedAt:    createdAt,
+	}
+}
+
+func TestRequestQueue_DequeueOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewRequestQueue()
+
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-3", now)))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", now.Add(-2*time.Minute))))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-2", now.Add(-time.Minute))))
+
+	dequeued, err := q.Dequeue(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, dequeued, 2)
+	assert.Equal(t, "req-1", dequeued[0].RequestID)
+	assert.Equal(t, "req-2", dequeued[1].RequestID)
+	for _, req := range dequeued {
+		assert.Equal(t, store.StatusProcessing, req.Status)
+		assert.NotNil(t, req.ProcessingStarted)
+	}
+
+	pending, err := q.GetByStatus(ctx, store.StatusPending, store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, pending.Items, 1)
+	assert.Equal(t, "req-3", pending.Items[0].RequestID)
+}
+
+func TestRequestQueue_CompleteAndFail(t *testing.T) {
+	ctx := context.Background()
+	q := NewRequestQueue()
+
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", time.Time{})))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-2", time.Time{})))
+
+	require.NoError(t, q.UpdateStatus(ctx, "req-1", store.StatusProcessing, ""))
+	require.NoError(t, q.CompleteRequest(ctx, "req-1", map[string]interface{}{"rows": 10}))
+	completed, err := q.Get(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusCompleted, completed.Status)
+	assert.Equal(t, 10, completed.Result["rows"])
+	assert.Equal(t, float64(100), completed.Progress)
+	assert.NotNil(t, completed.ProcessingEnded)
+
+	require.NoError(t, q.FailRequest(ctx, "req-2", "timed out"))
+	failed, err := q.Get(ctx, "req-2")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusFailed, failed.Status)
+	assert.Equal(t, "timed out", failed.Error)
+	assert.NotNil(t, failed.ProcessingEnded)
+}
+
+func TestRequestQueue_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewRequestQueue().(*requestQueue)
+	q.now = func() time.Time { return now }
+
+	req := newTestRequest("req-1", now)
+	req.TTL = now.Add(time.Hour).Unix()
+	require.NoError(t, q.Enqueue(ctx, req))
+
+	q.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	_, err := q.Get(ctx, "req-1")
+	assert.True(t, store.IsNotFound(err))
+
+	count, err := q.CountByTenant(ctx, "tenant-1", "", store.StatusPending)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// An expired request no longer blocks its ID
+	assert.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", time.Time{})))
+}
+
+func TestRequestQueue_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	q := NewRequestQueue()
+
+	req := newTestRequest("req-1", time.Time{})
+	req.Payload = map[string]interface{}{"report": "sales"}
+	require.NoError(t, q.Enqueue(ctx, req))
+
+	// Changing the enqueued request or a returned copy does not change the stored request
+	req.Payload["report"] = "changed"
+	got, err := q.Get(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, "sales", got.Payload["report"])
+
+	got.Payload["report"] = "changed"
+	got.Status = store.StatusFailed
+	again, err := q.Get(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, "sales", again.Payload["report"])
+	assert.Equal(t, store.StatusPending, again.Status)
+}
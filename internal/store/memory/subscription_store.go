@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// subscriptionStore implements SubscriptionStore in memory
+type subscriptionStore struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*store.Subscription
+	now           func() time.Time
+}
+
+// NewSubscriptionStore creates a new in-memory subscription store
+func NewSubscriptionStore() store.SubscriptionStore {
+	return &subscriptionStore{
+		subscriptions: make(map[string]*store.Subscription),
+		now:           time.Now,
+	}
+}
+
+// Subscribe creates a subscription for progress updates
+func (s *subscriptionStore) Subscribe(ctx context.Context, sub *store.Subscription) error {
+	if sub == nil {
+		return store.NewValidationError("subscription", "cannot be nil")
+	}
+	if sub.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if sub.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+
+	// The ID is always derived from the connection and request
+	sub.SubscriptionID = subscriptionID(sub.ConnectionID, sub.RequestID)
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = s.now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(sub.SubscriptionID); ok {
+		return store.NewStoreError("Subscribe", store.SubscriptionsTable, sub.SubscriptionID, store.ErrAlreadyExists)
+	}
+	s.subscriptions[sub.SubscriptionID] = copySubscription(sub)
+	return nil
+}
+
+// Unsubscribe removes a subscription
+func (s *subscriptionStore) Unsubscribe(ctx context.Context, connectionID, requestID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	id := subscriptionID(connectionID, requestID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lookup(id); !ok {
+		return store.NewStoreError("Unsubscribe", store.SubscriptionsTable, id, store.ErrNotFound)
+	}
+	delete(s.subscriptions, id)
+	return nil
+}
+
+// GetByConnection returns a page of a connection's subscriptions
+func (s *subscriptionStore) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.Subscription], error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+	return store.PageItems(s.list(func(sub *store.Subscription) bool { return sub.ConnectionID == connectionID }), opts, subscriptionKey)
+}
+
+// GetByRequest returns a page of a request's subscriptions
+func (s *subscriptionStore) GetByRequest(ctx context.Context, requestID string, opts store.PageOptions) (*store.Page[*store.Subscription], error) {
+	if requestID == "" {
+		return nil, store.NewValidationError("requestID", "cannot be empty")
+	}
+	return store.PageItems(s.list(func(sub *store.Subscription) bool { return sub.RequestID == requestID }), opts, subscriptionKey)
+}
+
+// DeleteByConnection removes all subscriptions for a connection
+func (s *subscriptionStore) DeleteByConnection(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sub := range s.subscriptions {
+		if sub.ConnectionID == connectionID {
+			delete(s.subscriptions, id)
+		}
+	}
+	return nil
+}
+
+// lookup returns a live subscription; callers must hold s.mu
+func (s *subscriptionStore) lookup(id string) (*store.Subscription, bool) {
+	sub, ok := s.subscriptions[id]
+	if !ok || expired(sub.TTL, s.now()) {
+		return nil, false
+	}
+	return sub, true
+}
+
+// list returns copies of the live subscriptions matching fn, oldest first
+func (s *subscriptionStore) list(fn func(*store.Subscription) bool) []*store.Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	result := make([]*store.Subscription, 0)
+	for _, sub := range s.subscriptions {
+		if !expired(sub.TTL, now) && fn(sub) {
+			result = append(result, copySubscription(sub))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].SubscriptionID < result[j].SubscriptionID
+	})
+	return result
+}
+
+// subscriptionKey orders subscriptions by creation time, then ID
+func subscriptionKey(sub *store.Subscription) store.Cursor {
+	return store.Cursor{At: unixNanos(sub.CreatedAt), ID: sub.SubscriptionID}
+}
+
+// subscriptionID returns the composite subscription ID, matching the DynamORM model
+func subscriptionID(connectionID, requestID string) string {
+	return connectionID + "#" + requestID
+}
+
+// copySubscription returns a copy of a subscription that shares no slices with it
+func copySubscription(sub *store.Subscription) *store.Subscription {
+	c := *sub
+	if sub.EventTypes != nil {
+		c.EventTypes = append([]string(nil), sub.EventTypes...)
+	}
+	return &c
+}
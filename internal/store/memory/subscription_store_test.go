@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestSubscriptionStore_Conformance(t *testing.T) {
+	storetest.RunSubscriptionStoreTests(t, func(t *testing.T) store.SubscriptionStore {
+		return NewSubscriptionStore()
+	})
+}
+
+func TestSubscriptionStore_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewSubscriptionStore().(*subscriptionStore)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Subscribe(ctx, &store.Subscription{
+		ConnectionID: "conn-1",
+		RequestID:    "req-1",
+		TTL:          now.Add(time.Minute).Unix(),
+	}))
+
+	s.now = func() time.Time { return now.Add(time.Hour) }
+
+	subs, err := s.GetByRequest(ctx, "req-1", store.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, subs.Items)
+	assert.True(t, store.IsNotFound(s.Unsubscribe(ctx, "conn-1", "req-1")))
+
+	// The expired subscription can be replaced
+	assert.NoError(t, s.Subscribe(ctx, &store.Subscription{ConnectionID: "conn-1", RequestID: "req-1"}))
+}
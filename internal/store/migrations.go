@@ -25,6 +25,9 @@ func GetTableDefinitions() []TableDefinition {
 		getConnectionsTableDefinition(),
 		getRequestsTableDefinition(),
 		getSubscriptionsTableDefinition(),
+		getRateLimitsTableDefinition(),
+		getTenantQuotasTableDefinition(),
+		getDeliveriesTableDefinition(),
 	}
 }
 
@@ -103,6 +106,14 @@ func getRequestsTableDefinition() TableDefinition {
 				AttributeName: aws.String("CreatedAt"),
 				AttributeType: types.ScalarAttributeTypeS,
 			},
+			{
+				AttributeName: aws.String("UserID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("TenantID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
 		},
 		KeySchema: []types.KeySchemaElement{
 			{
@@ -143,6 +154,38 @@ func getRequestsTableDefinition() TableDefinition {
 					ProjectionType: types.ProjectionTypeAll,
 				},
 			},
+			{
+				IndexName: aws.String("UserIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       types.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("CreatedAt"),
+						KeyType:       types.KeyTypeRange,
+					},
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+			{
+				IndexName: aws.String("TenantIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("TenantID"),
+						KeyType:       types.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("CreatedAt"),
+						KeyType:       types.KeyTypeRange,
+					},
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
 		},
 		BillingMode: types.BillingModePayPerRequest,
 	}
@@ -202,6 +245,108 @@ func getSubscriptionsTableDefinition() TableDefinition {
 	}
 }
 
+// getRateLimitsTableDefinition returns the definition for the rate limits table
+func getRateLimitsTableDefinition() TableDefinition {
+	return TableDefinition{
+		TableName: RateLimitsTable,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("Key"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("Key"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+}
+
+// getTenantQuotasTableDefinition returns the definition for the tenant quotas table
+func getTenantQuotasTableDefinition() TableDefinition {
+	return TableDefinition{
+		TableName: TenantQuotasTable,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("TenantID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("Action"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("TenantID"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("Action"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+}
+
+// getDeliveriesTableDefinition returns the definition for the pending deliveries table
+func getDeliveriesTableDefinition() TableDefinition {
+	return TableDefinition{
+		TableName: DeliveriesTable,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("Recipient"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("DeliveryID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("DueShard"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("NextAttemptAt"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("Recipient"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("DeliveryID"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("DueIndex"),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("DueShard"),
+						KeyType:       types.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("NextAttemptAt"),
+						KeyType:       types.KeyTypeRange,
+					},
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+}
+
 // CreateTables creates all required DynamoDB tables
 func CreateTables(ctx context.Context, client *dynamodb.Client) error {
 	definitions := GetTableDefinitions()
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"math"
 	"time"
 )
 
@@ -23,6 +24,10 @@ type Connection struct {
 	// Metadata for storing additional information
 	Metadata map[string]string `dynamodbav:"Metadata,omitempty" json:"metadata,omitempty"`
 
+	// TokenExpiresAt is the exp claim of the connection's token; zero if
+	// the token does not expire
+	TokenExpiresAt time.Time `dynamodbav:"TokenExpiresAt,omitempty" json:"tokenExpiresAt,omitempty"`
+
 	// TTL for automatic cleanup
 	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
 }
@@ -65,10 +70,35 @@ type AsyncRequest struct {
 	UserID   string `dynamodbav:"UserID" json:"userId"`
 	TenantID string `dynamodbav:"TenantID" json:"tenantId"`
 
+	// Permissions granted to the caller when the request was submitted
+	Permissions []string `dynamodbav:"Permissions,omitempty" json:"permissions,omitempty"`
+
+	// Callback the outcome is POSTed to once the request completes or fails,
+	// signed with CallbackSecret. The router seals the secret with a
+	// streamer.SecretBox, so stores only hold its ciphertext.
+	CallbackURL    string `dynamodbav:"CallbackURL,omitempty" json:"callbackUrl,omitempty"`
+	CallbackSecret string `dynamodbav:"CallbackSecret,omitempty" json:"callbackSecret,omitempty"`
+
+	// Attempts to deliver the callback, oldest first
+	CallbackDeliveries []CallbackDelivery `dynamodbav:"CallbackDeliveries,omitempty" json:"callbackDeliveries,omitempty"`
+
+	// Version starts at 1 and is incremented by every write
+	Version int64 `dynamodbav:"Version" json:"version"`
+
 	// TTL for automatic cleanup
 	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
 }
 
+// CallbackDelivery records one attempt to POST a request's outcome to its callback URL
+type CallbackDelivery struct {
+	Attempt     int           `dynamodbav:"Attempt" json:"attempt"`
+	Status      RequestStatus `dynamodbav:"Status" json:"status"`
+	AttemptedAt time.Time     `dynamodbav:"AttemptedAt" json:"attemptedAt"`
+	StatusCode  int           `dynamodbav:"StatusCode,omitempty" json:"statusCode,omitempty"`
+	Error       string        `dynamodbav:"Error,omitempty" json:"error,omitempty"`
+	Delivered   bool          `dynamodbav:"Delivered" json:"delivered"`
+}
+
 // RequestStatus represents the status of an async request
 type RequestStatus string
 
@@ -81,6 +111,48 @@ const (
 	StatusRetrying   RequestStatus = "RETRYING"
 )
 
+// RequestFilter narrows a list of requests. Zero fields match every request.
+type RequestFilter struct {
+	// TenantID keeps the requests of one tenant
+	TenantID string
+
+	// Statuses keeps the requests in any of the statuses
+	Statuses []RequestStatus
+
+	// Action keeps the requests for one action
+	Action string
+
+	// CreatedAfter and CreatedBefore keep the requests created at or after
+	// CreatedAfter and before CreatedBefore
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Matches reports whether a request passes the filter
+func (f RequestFilter) Matches(req *AsyncRequest) bool {
+	if f.TenantID != "" && req.TenantID != f.TenantID {
+		return false
+	}
+	if f.Action != "" && req.Action != f.Action {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && req.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !req.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if req.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
 // Subscription represents a real-time update subscription
 type Subscription struct {
 	// Composite key: ConnectionID#RequestID
@@ -98,9 +170,157 @@ type Subscription struct {
 	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
 }
 
+// RateLimitBucket represents a token bucket shared across Lambda instances
+type RateLimitBucket struct {
+	// Primary key, e.g. "user#123#generate_report"
+	Key string `dynamodbav:"Key" json:"key"`
+
+	// Bucket state
+	Tokens    float64   `dynamodbav:"Tokens" json:"tokens"`
+	UpdatedAt time.Time `dynamodbav:"UpdatedAt" json:"updatedAt"`
+
+	// Version for optimistic locking
+	Version int64 `dynamodbav:"Version" json:"version"`
+
+	// TTL for automatic cleanup of idle buckets
+	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
+}
+
+// Take refills the bucket for the time elapsed since UpdatedAt and consumes one token.
+// When the bucket is empty it returns false and the time until a token is available.
+func (b *RateLimitBucket) Take(now time.Time, rate float64, burst int) (bool, time.Duration) {
+	b.refill(now, rate, burst)
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// Refund refills the bucket for the time elapsed since UpdatedAt and gives
+// back one token taken earlier, never filling it past burst
+func (b *RateLimitBucket) Refund(now time.Time, rate float64, burst int) {
+	b.refill(now, rate, burst)
+	b.Tokens = math.Min(float64(burst), b.Tokens+1)
+}
+
+// refill adds the tokens earned since UpdatedAt, up to burst. A bucket that
+// has never been used starts full.
+func (b *RateLimitBucket) refill(now time.Time, rate float64, burst int) {
+	capacity := float64(burst)
+
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+	b.UpdatedAt = now
+}
+
+// CircuitBreakerState is the circuit breaker state for one connection,
+// shared by every instance sending to it
+type CircuitBreakerState struct {
+	ConnectionID string `dynamodbav:"ConnectionID" json:"connectionId"`
+
+	// Consecutive failed sends and when the last one happened
+	Failures    int       `dynamodbav:"Failures" json:"failures"`
+	LastFailure time.Time `dynamodbav:"LastFailure" json:"lastFailure"`
+
+	// OpenedAt is when the breaker last opened; zero while closed
+	OpenedAt time.Time `dynamodbav:"OpenedAt,omitempty" json:"openedAt,omitempty"`
+
+	// Probes admitted since the breaker went half-open, and when the first was
+	Probes      int       `dynamodbav:"Probes" json:"probes"`
+	ProbeWindow time.Time `dynamodbav:"ProbeWindow,omitempty" json:"probeWindow,omitempty"`
+
+	// Version for optimistic locking; zero for state that has never been saved
+	Version int64 `dynamodbav:"Version" json:"version"`
+}
+
+// QuotaAllActions is the TenantQuota action that applies to all of a tenant's requests
+const QuotaAllActions = "*"
+
+// TenantQuota caps the async work a tenant may have outstanding.
+// A zero limit means the limit is not enforced.
+type TenantQuota struct {
+	// Composite key: tenant and action, with QuotaAllActions for tenant-wide limits
+	TenantID string `dynamodbav:"TenantID" json:"tenantId"`
+	Action   string `dynamodbav:"Action" json:"action"`
+
+	// MaxActive limits PENDING plus PROCESSING requests, checked at enqueue time
+	MaxActive int `dynamodbav:"MaxActive" json:"maxActive"`
+
+	// MaxConcurrent limits PROCESSING requests, checked by the processor
+	MaxConcurrent int `dynamodbav:"MaxConcurrent" json:"maxConcurrent"`
+
+	// Audit fields
+	UpdatedAt time.Time `dynamodbav:"UpdatedAt" json:"updatedAt"`
+	UpdatedBy string    `dynamodbav:"UpdatedBy,omitempty" json:"updatedBy,omitempty"`
+}
+
+// PendingDelivery is a message sent with at-least-once delivery that the
+// client has not acked yet
+type PendingDelivery struct {
+	// Composite key: who the message is for (see DeliveryRecipient) and its delivery ID
+	Recipient  string `dynamodbav:"Recipient" json:"recipient"`
+	DeliveryID string `dynamodbav:"DeliveryID" json:"deliveryId"`
+
+	// ConnectionID is the connection the message was last sent to
+	ConnectionID string `dynamodbav:"ConnectionID" json:"connectionId"`
+	UserID       string `dynamodbav:"UserID,omitempty" json:"userId,omitempty"`
+	TenantID     string `dynamodbav:"TenantID,omitempty" json:"tenantId,omitempty"`
+
+	// Message is the JSON-encoded message, including its delivery ID
+	Message []byte `dynamodbav:"Message" json:"message"`
+
+	// Attempts counts sends so far; the message is sent again at NextAttemptAt if not acked
+	Attempts      int       `dynamodbav:"Attempts" json:"attempts"`
+	CreatedAt     time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+	NextAttemptAt time.Time `dynamodbav:"NextAttemptAt" json:"nextAttemptAt"`
+
+	// TTL for automatic cleanup of messages that are never acked
+	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
+}
+
+// PendingCallback schedules the delivery of a finished request's outcome to
+// its callback URL. It holds no copy of the outcome; the request is read
+// back when the callback is sent.
+type PendingCallback struct {
+	RequestID string `dynamodbav:"RequestID" json:"requestId"`
+
+	// Attempts counts deliveries so far; the next one is made at NextAttemptAt
+	Attempts      int       `dynamodbav:"Attempts" json:"attempts"`
+	CreatedAt     time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+	NextAttemptAt time.Time `dynamodbav:"NextAttemptAt" json:"nextAttemptAt"`
+
+	// TTL for automatic cleanup of callbacks that are never delivered
+	TTL int64 `dynamodbav:"TTL,omitempty" json:"ttl,omitempty"`
+}
+
+// DeliveryRecipient returns the recipient key for messages sent to a
+// connection. Messages for a known user follow them to new connections;
+// anonymous connections only receive their own.
+func DeliveryRecipient(conn *Connection) string {
+	if conn.UserID != "" {
+		return "USER#" + conn.TenantID + "#" + conn.UserID
+	}
+	return "CONN#" + conn.ConnectionID
+}
+
 // TableNames defines the DynamoDB table names
 const (
 	ConnectionsTable   = "streamer_connections"
 	RequestsTable      = "streamer_requests"
 	SubscriptionsTable = "streamer_subscriptions"
+	RateLimitsTable    = "streamer_rate_limits"
+	TenantQuotasTable  = "streamer_tenant_quotas"
+	DeliveriesTable    = "streamer_deliveries"
+
+	// RequestsV2Table holds DynamoDB requests keyed by request ID alone.
+	// Requests are migrated into it from RequestsTable, which is kept until
+	// cmd/streamer-migrate-requests finds nothing left to move.
+	RequestsV2Table = "streamer_requests_v2"
 )
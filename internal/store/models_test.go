@@ -41,6 +41,10 @@ func TestTableNameConstants(t *testing.T) {
 	assert.Equal(t, "streamer_connections", ConnectionsTable)
 	assert.Equal(t, "streamer_requests", RequestsTable)
 	assert.Equal(t, "streamer_subscriptions", SubscriptionsTable)
+	assert.Equal(t, "streamer_rate_limits", RateLimitsTable)
+	assert.Equal(t, "streamer_tenant_quotas", TenantQuotasTable)
+	assert.Equal(t, "streamer_deliveries", DeliveriesTable)
+	assert.Equal(t, "streamer_requests_v2", RequestsV2Table)
 }
 
 // TestConnectionStruct tests the Connection struct
@@ -171,14 +175,15 @@ func TestStructTags(t *testing.T) {
 		dynamodb string
 		json     string
 	}{
-		"ConnectionID": {dynamodb: "ConnectionID", json: "connectionId"},
-		"UserID":       {dynamodb: "UserID", json: "userId"},
-		"TenantID":     {dynamodb: "TenantID", json: "tenantId"},
-		"Endpoint":     {dynamodb: "Endpoint", json: "endpoint"},
-		"ConnectedAt":  {dynamodb: "ConnectedAt", json: "connectedAt"},
-		"LastPing":     {dynamodb: "LastPing", json: "lastPing"},
-		"Metadata":     {dynamodb: "Metadata,omitempty", json: "metadata,omitempty"},
-		"TTL":          {dynamodb: "TTL,omitempty", json: "ttl,omitempty"},
+		"ConnectionID":   {dynamodb: "ConnectionID", json: "connectionId"},
+		"UserID":         {dynamodb: "UserID", json: "userId"},
+		"TenantID":       {dynamodb: "TenantID", json: "tenantId"},
+		"Endpoint":       {dynamodb: "Endpoint", json: "endpoint"},
+		"ConnectedAt":    {dynamodb: "ConnectedAt", json: "connectedAt"},
+		"LastPing":       {dynamodb: "LastPing", json: "lastPing"},
+		"Metadata":       {dynamodb: "Metadata,omitempty", json: "metadata,omitempty"},
+		"TokenExpiresAt": {dynamodb: "TokenExpiresAt,omitempty", json: "tokenExpiresAt,omitempty"},
+		"TTL":            {dynamodb: "TTL,omitempty", json: "ttl,omitempty"},
 	})
 
 	// Test AsyncRequest tags
@@ -187,25 +192,30 @@ func TestStructTags(t *testing.T) {
 		dynamodb string
 		json     string
 	}{
-		"RequestID":         {dynamodb: "RequestID", json: "requestId"},
-		"ConnectionID":      {dynamodb: "ConnectionID", json: "connectionId"},
-		"Status":            {dynamodb: "Status", json: "status"},
-		"CreatedAt":         {dynamodb: "CreatedAt", json: "createdAt"},
-		"Action":            {dynamodb: "Action", json: "action"},
-		"Payload":           {dynamodb: "Payload,omitempty", json: "payload,omitempty"},
-		"ProcessingStarted": {dynamodb: "ProcessingStarted,omitempty", json: "processingStarted,omitempty"},
-		"ProcessingEnded":   {dynamodb: "ProcessingEnded,omitempty", json: "processingEnded,omitempty"},
-		"Result":            {dynamodb: "Result,omitempty", json: "result,omitempty"},
-		"Error":             {dynamodb: "Error,omitempty", json: "error,omitempty"},
-		"Progress":          {dynamodb: "Progress", json: "progress"},
-		"ProgressMessage":   {dynamodb: "ProgressMessage,omitempty", json: "progressMessage,omitempty"},
-		"ProgressDetails":   {dynamodb: "ProgressDetails,omitempty", json: "progressDetails,omitempty"},
-		"RetryCount":        {dynamodb: "RetryCount", json: "retryCount"},
-		"MaxRetries":        {dynamodb: "MaxRetries", json: "maxRetries"},
-		"RetryAfter":        {dynamodb: "RetryAfter,omitempty", json: "retryAfter,omitempty"},
-		"UserID":            {dynamodb: "UserID", json: "userId"},
-		"TenantID":          {dynamodb: "TenantID", json: "tenantId"},
-		"TTL":               {dynamodb: "TTL,omitempty", json: "ttl,omitempty"},
+		"RequestID":          {dynamodb: "RequestID", json: "requestId"},
+		"ConnectionID":       {dynamodb: "ConnectionID", json: "connectionId"},
+		"Status":             {dynamodb: "Status", json: "status"},
+		"CreatedAt":          {dynamodb: "CreatedAt", json: "createdAt"},
+		"Action":             {dynamodb: "Action", json: "action"},
+		"Payload":            {dynamodb: "Payload,omitempty", json: "payload,omitempty"},
+		"ProcessingStarted":  {dynamodb: "ProcessingStarted,omitempty", json: "processingStarted,omitempty"},
+		"ProcessingEnded":    {dynamodb: "ProcessingEnded,omitempty", json: "processingEnded,omitempty"},
+		"Result":             {dynamodb: "Result,omitempty", json: "result,omitempty"},
+		"Error":              {dynamodb: "Error,omitempty", json: "error,omitempty"},
+		"Progress":           {dynamodb: "Progress", json: "progress"},
+		"ProgressMessage":    {dynamodb: "ProgressMessage,omitempty", json: "progressMessage,omitempty"},
+		"ProgressDetails":    {dynamodb: "ProgressDetails,omitempty", json: "progressDetails,omitempty"},
+		"RetryCount":         {dynamodb: "RetryCount", json: "retryCount"},
+		"MaxRetries":         {dynamodb: "MaxRetries", json: "maxRetries"},
+		"RetryAfter":         {dynamodb: "RetryAfter,omitempty", json: "retryAfter,omitempty"},
+		"UserID":             {dynamodb: "UserID", json: "userId"},
+		"TenantID":           {dynamodb: "TenantID", json: "tenantId"},
+		"Permissions":        {dynamodb: "Permissions,omitempty", json: "permissions,omitempty"},
+		"CallbackURL":        {dynamodb: "CallbackURL,omitempty", json: "callbackUrl,omitempty"},
+		"CallbackSecret":     {dynamodb: "CallbackSecret,omitempty", json: "callbackSecret,omitempty"},
+		"CallbackDeliveries": {dynamodb: "CallbackDeliveries,omitempty", json: "callbackDeliveries,omitempty"},
+		"Version":            {dynamodb: "Version", json: "version"},
+		"TTL":                {dynamodb: "TTL,omitempty", json: "ttl,omitempty"},
 	})
 
 	// Test Subscription tags
@@ -315,3 +325,133 @@ func TestRequestStatusString(t *testing.T) {
 	assert.Equal(t, "retrying", testStatus(StatusRetrying))
 	assert.Equal(t, "unknown", testStatus("UNKNOWN"))
 }
+
+// TestRateLimitBucketTake tests token bucket refill and consumption
+func TestRateLimitBucketTake(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name          string
+		bucket        RateLimitBucket
+		now           time.Time
+		rate          float64
+		burst         int
+		expectAllowed bool
+		expectTokens  float64
+		expectWait    time.Duration
+	}{
+		{
+			name:          "new bucket starts full",
+			bucket:        RateLimitBucket{Key: "user#1#*"},
+			now:           start,
+			rate:          1,
+			burst:         5,
+			expectAllowed: true,
+			expectTokens:  4,
+		},
+		{
+			name:          "empty bucket is denied",
+			bucket:        RateLimitBucket{Key: "user#1#*", Tokens: 0, UpdatedAt: start},
+			now:           start,
+			rate:          2,
+			burst:         5,
+			expectAllowed: false,
+			expectTokens:  0,
+			expectWait:    500 * time.Millisecond,
+		},
+		{
+			name:          "bucket refills over time",
+			bucket:        RateLimitBucket{Key: "user#1#*", Tokens: 0, UpdatedAt: start},
+			now:           start.Add(2 * time.Second),
+			rate:          1,
+			burst:         5,
+			expectAllowed: true,
+			expectTokens:  1,
+		},
+		{
+			name:          "refill is capped at burst",
+			bucket:        RateLimitBucket{Key: "user#1#*", Tokens: 1, UpdatedAt: start},
+			now:           start.Add(time.Hour),
+			rate:          1,
+			burst:         3,
+			expectAllowed: true,
+			expectTokens:  2,
+		},
+		{
+			name:          "partial token waits for remainder",
+			bucket:        RateLimitBucket{Key: "user#1#*", Tokens: 0.75, UpdatedAt: start},
+			now:           start,
+			rate:          1,
+			burst:         3,
+			expectAllowed: false,
+			expectTokens:  0.75,
+			expectWait:    250 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := tt.bucket
+			allowed, wait := bucket.Take(tt.now, tt.rate, tt.burst)
+			assert.Equal(t, tt.expectAllowed, allowed)
+			assert.InDelta(t, tt.expectTokens, bucket.Tokens, 0.0001)
+			assert.Equal(t, tt.expectWait, wait)
+			assert.Equal(t, tt.now, bucket.UpdatedAt)
+		})
+	}
+}
+
+func TestRateLimitBucketRefund(t *testing.T) {
+	start := time.Now()
+
+	// A refund gives back a token taken earlier
+	bucket := RateLimitBucket{Key: "user#1#*", Tokens: 0.5, UpdatedAt: start}
+	bucket.Refund(start, 1, 3)
+	assert.InDelta(t, 1.5, bucket.Tokens, 0.0001)
+
+	// Refunds never fill a bucket past its burst
+	bucket = RateLimitBucket{Key: "user#1#*", Tokens: 2, UpdatedAt: start}
+	bucket.Refund(start.Add(time.Hour), 1, 3)
+	assert.InDelta(t, 3, bucket.Tokens, 0.0001)
+	assert.Equal(t, start.Add(time.Hour), bucket.UpdatedAt)
+}
+
+// TestDeliveryRecipient tests recipient keys for pending deliveries
+func TestDeliveryRecipient(t *testing.T) {
+	assert.Equal(t, "USER#tenant-1#user-1", DeliveryRecipient(&Connection{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"}))
+	assert.Equal(t, "CONN#conn-1", DeliveryRecipient(&Connection{ConnectionID: "conn-1"}))
+}
+
+// TestRequestFilter_Matches tests filtering requests
+func TestRequestFilter_Matches(t *testing.T) {
+	now := time.Now()
+	req := &AsyncRequest{
+		RequestID: "req-1",
+		Status:    StatusCompleted,
+		Action:    "generate_report",
+		TenantID:  "tenant-1",
+		CreatedAt: now,
+	}
+
+	tests := []struct {
+		name   string
+		filter RequestFilter
+		want   bool
+	}{
+		{"empty filter", RequestFilter{}, true},
+		{"tenant", RequestFilter{TenantID: "tenant-1"}, true},
+		{"other tenant", RequestFilter{TenantID: "tenant-2"}, false},
+		{"any of the statuses", RequestFilter{Statuses: []RequestStatus{StatusFailed, StatusCompleted}}, true},
+		{"other statuses", RequestFilter{Statuses: []RequestStatus{StatusPending}}, false},
+		{"other action", RequestFilter{Action: "export_data"}, false},
+		{"created after is inclusive", RequestFilter{CreatedAfter: now}, true},
+		{"created before is exclusive", RequestFilter{CreatedBefore: now}, false},
+		{"in time range", RequestFilter{CreatedAfter: now.Add(-time.Hour), CreatedBefore: now.Add(time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(req))
+		})
+	}
+}
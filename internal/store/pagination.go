@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// DefaultPageSize is the page size used when PageOptions.Limit is not set
+const DefaultPageSize = 100
+
+// ErrNoMorePages is returned when a Paginator is asked for a page after the last
+var ErrNoMorePages = errors.New("no more pages")
+
+// ErrRepeatedPageToken is returned when a list hands back the NextToken its
+// page was fetched with, which would otherwise fetch the same page forever
+var ErrRepeatedPageToken = errors.New("page token did not advance")
+
+// PageOptions selects a page of a list
+type PageOptions struct {
+	// Limit is the most items to return; zero or less means DefaultPageSize
+	Limit int
+
+	// NextToken continues a list from the page that returned it; empty
+	// starts at the first page
+	NextToken string
+}
+
+// PageSize returns the number of items to request for a page
+func (o PageOptions) PageSize() int {
+	if o.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return o.Limit
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	// Items are the page's items. A page may hold fewer than the limit, or
+	// none, and still not be the last.
+	Items []T
+
+	// NextToken continues the list after this page; it is empty on the last page
+	NextToken string
+}
+
+// PageFunc fetches one page of a list, such as a store's ListByTenant bound
+// to a tenant ID
+type PageFunc[T any] func(ctx context.Context, opts PageOptions) (*Page[T], error)
+
+// Paginator walks the pages of a list, one request per page
+type Paginator[T any] struct {
+	fetch   PageFunc[T]
+	opts    PageOptions
+	started bool
+}
+
+// NewPaginator creates a paginator that requests limit items per page
+func NewPaginator[T any](limit int, fetch PageFunc[T]) *Paginator[T] {
+	return &Paginator[T]{
+		fetch: fetch,
+		opts:  PageOptions{Limit: limit},
+	}
+}
+
+// HasMorePages reports whether NextPage has another page to fetch
+func (p *Paginator[T]) HasMorePages() bool {
+	return !p.started || p.opts.NextToken != ""
+}
+
+// NextPage fetches the next page. It returns ctx's error without fetching
+// once ctx is done, so a walk over a long list stops between pages.
+func (p *Paginator[T]) NextPage(ctx context.Context) (*Page[T], error) {
+	if !p.HasMorePages() {
+		return nil, ErrNoMorePages
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	page, err := p.fetch(ctx, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	if page.NextToken != "" && page.NextToken == p.opts.NextToken {
+		return nil, ErrRepeatedPageToken
+	}
+	p.started = true
+	p.opts.NextToken = page.NextToken
+	return page, nil
+}
+
+// Collect fetches every page of a list and returns all of their items
+func Collect[T any](ctx context.Context, fetch PageFunc[T]) ([]T, error) {
+	items := make([]T, 0)
+	pages := NewPaginator(0, fetch)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// Cursor is the position of the last item of a page in a list ordered by a
+// timestamp and then an ID. Backends that page by key return it as the
+// NextToken. Lists ordered newest first negate the timestamp.
+type Cursor struct {
+	At int64  `json:"at,omitempty"`
+	ID string `json:"id"`
+}
+
+// Token encodes the cursor as an opaque NextToken
+func (c Cursor) Token() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Before reports whether the cursor comes before the item at (at, id), so
+// that the item belongs on a later page
+func (c Cursor) Before(at int64, id string) bool {
+	if at != c.At {
+		return c.At < at
+	}
+	return c.ID < id
+}
+
+// ParseCursor decodes a NextToken written by Cursor.Token. An empty token
+// returns nil.
+func ParseCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, NewValidationError("NextToken", "is not a valid page token")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, NewValidationError("NextToken", "is not a valid page token")
+	}
+	return &c, nil
+}
+
+// PageItems sorts items by key and returns the page of them that opts
+// selects, for backends that read a whole list and page it in process. The
+// NextToken is the cursor of the page's last item.
+func PageItems[T any](items []T, opts PageOptions, key func(T) Cursor) (*Page[T], error) {
+	after, err := ParseCursor(opts.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		next := key(items[j])
+		return key(items[i]).Before(next.At, next.ID)
+	})
+
+	page := &Page[T]{Items: make([]T, 0)}
+	for _, item := range items {
+		k := key(item)
+		if after != nil && !after.Before(k.At, k.ID) {
+			continue
+		}
+		if len(page.Items) == opts.PageSize() {
+			page.NextToken = key(page.Items[len(page.Items)-1]).Token()
+			break
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page, nil
+}
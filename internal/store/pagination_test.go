@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// item is a list entry for the pagination tests
+type item struct {
+	at int64
+	id string
+}
+
+func itemKey(i item) Cursor {
+	return Cursor{At: i.at, ID: i.id}
+}
+
+func itemIDs(items []item) []string {
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.id
+	}
+	return ids
+}
+
+// listOf returns a PageFunc over items that counts its calls
+func listOf(items []item, calls *int) PageFunc[item] {
+	return func(ctx context.Context, opts PageOptions) (*Page[item], error) {
+		*calls++
+		return PageItems(append([]item(nil), items...), opts, itemKey)
+	}
+}
+
+// TestPageOptions_PageSize tests the default page size
+func TestPageOptions_PageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageOptions{}.PageSize())
+	assert.Equal(t, DefaultPageSize, PageOptions{Limit: -1}.PageSize())
+	assert.Equal(t, 5, PageOptions{Limit: 5}.PageSize())
+}
+
+// TestCursor tests encoding, decoding and ordering cursors
+func TestCursor(t *testing.T) {
+	c := Cursor{At: 42, ID: "item-1"}
+
+	parsed, err := ParseCursor(c.Token())
+	require.NoError(t, err)
+	assert.Equal(t, c, *parsed)
+
+	parsed, err = ParseCursor("")
+	require.NoError(t, err)
+	assert.Nil(t, parsed)
+
+	for _, token := range []string{"not a token", Cursor{At: 1}.Token()} {
+		_, err := ParseCursor(token)
+		var validation *ValidationError
+		assert.True(t, errors.As(err, &validation), "token %q", token)
+	}
+
+	assert.True(t, c.Before(43, "item-0"))
+	assert.True(t, c.Before(42, "item-2"))
+	assert.False(t, c.Before(42, "item-1"))
+	assert.False(t, c.Before(41, "item-9"))
+}
+
+// TestPageItems tests paging a list in process
+func TestPageItems(t *testing.T) {
+	items := []item{{2, "b"}, {1, "z"}, {2, "a"}, {3, "c"}, {1, "y"}}
+
+	tests := []struct {
+		name     string
+		opts     PageOptions
+		wantIDs  []string
+		wantNext bool
+	}{
+		{
+			name:    "all items in order",
+			wantIDs: []string{"y", "z", "a", "b", "c"},
+		},
+		{
+			name:     "first page",
+			opts:     PageOptions{Limit: 2},
+			wantIDs:  []string{"y", "z"},
+			wantNext: true,
+		},
+		{
+			name:     "after cursor",
+			opts:     PageOptions{Limit: 2, NextToken: Cursor{At: 1, ID: "z"}.Token()},
+			wantIDs:  []string{"a", "b"},
+			wantNext: true,
+		},
+		{
+			name:    "exactly the last page",
+			opts:    PageOptions{Limit: 3, NextToken: Cursor{At: 1, ID: "z"}.Token()},
+			wantIDs: []string{"a", "b", "c"},
+		},
+		{
+			name:    "past the end",
+			opts:    PageOptions{NextToken: Cursor{At: 3, ID: "c"}.Token()},
+			wantIDs: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := PageItems(append([]item(nil), items...), tt.opts, itemKey)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIDs, itemIDs(page.Items))
+			assert.Equal(t, tt.wantNext, page.NextToken != "")
+		})
+	}
+
+	_, err := PageItems(items, PageOptions{NextToken: "not a token"}, itemKey)
+	assert.Error(t, err)
+}
+
+// TestPaginator tests walking the pages of a list
+func TestPaginator(t *testing.T) {
+	ctx := context.Background()
+	items := []item{{1, "a"}, {2, "b"}, {3, "c"}, {4, "d"}, {5, "e"}}
+
+	calls := 0
+	pages := NewPaginator(2, listOf(items, &calls))
+
+	var got [][]string
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		require.NoError(t, err)
+		got = append(got, itemIDs(page.Items))
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, got)
+	assert.Equal(t, 3, calls)
+
+	_, err := pages.NextPage(ctx)
+	assert.ErrorIs(t, err, ErrNoMorePages)
+	assert.Equal(t, 3, calls)
+
+	t.Run("empty list", func(t *testing.T) {
+		calls := 0
+		pages := NewPaginator(2, listOf(nil, &calls))
+
+		page, err := pages.NextPage(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+		assert.False(t, pages.HasMorePages())
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		calls := 0
+		_, err := NewPaginator(2, listOf(items, &calls)).NextPage(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, calls)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		pages := NewPaginator(2, func(ctx context.Context, opts PageOptions) (*Page[item], error) {
+			return nil, errors.New("throttled")
+		})
+
+		_, err := pages.NextPage(ctx)
+		assert.EqualError(t, err, "throttled")
+		assert.True(t, pages.HasMorePages())
+	})
+
+	t.Run("repeated token", func(t *testing.T) {
+		pages := NewPaginator(2, func(ctx context.Context, opts PageOptions) (*Page[item], error) {
+			return &Page[item]{Items: []item{{1, "a"}}, NextToken: "same"}, nil
+		})
+
+		_, err := pages.NextPage(ctx)
+		require.NoError(t, err)
+		_, err = pages.NextPage(ctx)
+		assert.ErrorIs(t, err, ErrRepeatedPageToken)
+	})
+}
+
+// TestCollect tests fetching every page of a list
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	items := make([]item, 0, 2*DefaultPageSize+1)
+	for i := 0; i < cap(items); i++ {
+		items = append(items, item{at: int64(i), id: "item"})
+	}
+
+	calls := 0
+	all, err := Collect(ctx, listOf(items, &calls))
+	require.NoError(t, err)
+	assert.Len(t, all, len(items))
+	assert.Equal(t, 3, calls)
+
+	none, err := Collect(ctx, listOf(nil, &calls))
+	require.NoError(t, err)
+	assert.NotNil(t, none)
+	assert.Empty(t, none)
+
+	_, err = Collect(ctx, func(ctx context.Context, opts PageOptions) (*Page[item], error) {
+		return nil, errors.New("throttled")
+	})
+	assert.EqualError(t, err, "throttled")
+}
@@ -0,0 +1,509 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// maxWatchRetries bounds the optimistic transaction retries of a write
+const maxWatchRetries = 10
+
+// connectionStore implements ConnectionStore on Redis
+type connectionStore struct {
+	client redis.UniversalClient
+	keys   keys
+	now    func() time.Time
+}
+
+// NewConnectionStore creates a new Redis connection store. Keys are prefixed
+// with prefix, or DefaultPrefix when it is empty.
+func NewConnectionStore(client redis.UniversalClient, prefix string) store.ConnectionStore {
+	return &connectionStore{
+		client: client,
+		keys:   newKeys(prefix),
+		now:    time.Now,
+	}
+}
+
+// Save creates or updates a connection
+func (s *connectionStore) Save(ctx context.Context, conn *store.Connection) error {
+	if err := validateConnection(conn); err != nil {
+		return err
+	}
+
+	// Set TTL to 24 hours from now if not set
+	if conn.TTL == 0 {
+		conn.TTL = s.now().Add(24 * time.Hour).Unix()
+	}
+
+	return s.write(ctx, "Save", conn.ConnectionID, func(*store.Connection) (*store.Connection, error) {
+		return conn, nil
+	})
+}
+
+// Get retrieves a connection by ID
+func (s *connectionStore) Get(ctx context.Context, connectionID string) (*store.Connection, error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	fields, err := s.client.HGetAll(ctx, s.keys.connection(connectionID)).Result()
+	if err != nil {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, err)
+	}
+	if len(fields) == 0 {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, store.ErrNotFound)
+	}
+
+	conn, err := decodeConnection(fields)
+	if err != nil {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, err)
+	}
+	return conn, nil
+}
+
+// Delete removes a connection; deleting one that does not exist is not an error
+func (s *connectionStore) Delete(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	owners, err := s.client.HMGet(ctx, s.keys.connection(connectionID), "userId", "tenantId").Result()
+	if err != nil {
+		return store.NewStoreError("Delete", store.ConnectionsTable, connectionID, err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.remove(ctx, pipe, connectionID, owners)
+		return nil
+	})
+	if err != nil {
+		return store.NewStoreError("Delete", store.ConnectionsTable, connectionID, err)
+	}
+	return nil
+}
+
+// ListByUser returns a page of a user's connections, in no particular order
+func (s *connectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+	return s.listMembers(ctx, "ListByUser", userID, s.keys.user(userID), opts)
+}
+
+// ListByTenant returns a page of a tenant's connections, in no particular order
+func (s *connectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if tenantID == "" {
+		return nil, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	return s.listMembers(ctx, "ListByTenant", tenantID, s.keys.tenant(tenantID), opts)
+}
+
+// UpdateLastPing updates the last ping timestamp and extends the TTL
+func (s *connectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
+	return s.update(ctx, "UpdateLastPing", connectionID, func(conn *store.Connection) {
+		now := s.now()
+		conn.LastPing = now
+		conn.TTL = now.Add(24 * time.Hour).Unix()
+	})
+}
+
+// UpdateClaims replaces a connection's metadata and token expiry
+func (s *connectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	return s.update(ctx, "UpdateClaims", connectionID, func(conn *store.Connection) {
+		conn.Metadata = metadata
+		conn.TokenExpiresAt = tokenExpiresAt
+	})
+}
+
+// ListExpiring returns a page of connections whose token expires before the
+// specified time, soonest first
+func (s *connectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return s.listBefore(ctx, "ListExpiring", s.keys.tokenIndex(), before, opts)
+}
+
+// ListStale returns a page of connections whose last ping is older than the
+// specified time, oldest first
+func (s *connectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return s.listBefore(ctx, "ListStale", s.keys.pingIndex(), before, opts)
+}
+
+// DeleteStale removes connections whose last ping is older than the specified time
+func (s *connectionStore) DeleteStale(ctx context.Context, before time.Time) error {
+	stale, err := store.Collect(ctx, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return s.ListStale(ctx, before, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, conn := range stale {
+		if err := s.Delete(ctx, conn.ConnectionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update applies fn to a stored connection, reporting ErrNotFound when there is none
+func (s *connectionStore) update(ctx context.Context, op, connectionID string, fn func(*store.Connection)) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	return s.write(ctx, op, connectionID, func(current *store.Connection) (*store.Connection, error) {
+		if current == nil {
+			return nil, store.ErrNotFound
+		}
+		fn(current)
+		return current, nil
+	})
+}
+
+// write replaces a connection with the one fn derives from the current
+// record, which is nil when there is none. The write is retried if the
+// record changes in between, so concurrent updates are not lost.
+func (s *connectionStore) write(ctx context.Context, op, connectionID string, fn func(current *store.Connection) (*store.Connection, error)) error {
+	key := s.keys.connection(connectionID)
+
+	txn := func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		var current *store.Connection
+		if len(fields) > 0 {
+			if current, err = decodeConnection(fields); err != nil {
+				return err
+			}
+		}
+
+		conn, err := fn(current)
+		if err != nil {
+			return err
+		}
+		encoded, err := encodeConnection(conn)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// A connection that moved to another user or tenant leaves its old sets
+			if current != nil && current.UserID != conn.UserID {
+				pipe.SRem(ctx, s.keys.user(current.UserID), connectionID)
+			}
+			if current != nil && current.TenantID != conn.TenantID {
+				pipe.SRem(ctx, s.keys.tenant(current.TenantID), connectionID)
+			}
+
+			pipe.Del(ctx, key)
+			pipe.HSet(ctx, key, encoded)
+			pipe.SAdd(ctx, s.keys.user(conn.UserID), connectionID)
+			pipe.SAdd(ctx, s.keys.tenant(conn.TenantID), connectionID)
+			if conn.TTL != 0 {
+				// The sets live as long as their longest-lived connection
+				pipe.ExpireAt(ctx, key, time.Unix(conn.TTL, 0))
+				extendExpiry(ctx, pipe, s.keys.user(conn.UserID), conn.TTL)
+				extendExpiry(ctx, pipe, s.keys.tenant(conn.TenantID), conn.TTL)
+			}
+
+			pipe.ZAdd(ctx, s.keys.pingIndex(), redis.Z{Score: score(conn.LastPing), Member: connectionID})
+			if conn.TokenExpiresAt.IsZero() {
+				pipe.ZRem(ctx, s.keys.tokenIndex(), connectionID)
+			} else {
+				pipe.ZAdd(ctx, s.keys.tokenIndex(), redis.Z{Score: score(conn.TokenExpiresAt), Member: connectionID})
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxWatchRetries; i++ {
+		err := s.client.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return store.NewStoreError(op, store.ConnectionsTable, connectionID, err)
+		}
+		return nil
+	}
+	return store.NewStoreError(op, store.ConnectionsTable, connectionID, store.ErrConcurrentModification)
+}
+
+// remove queues the deletion of a connection and its index entries. owners
+// holds the connection's user and tenant IDs, or nils if it has expired.
+func (s *connectionStore) remove(ctx context.Context, pipe redis.Pipeliner, connectionID string, owners []interface{}) {
+	pipe.Del(ctx, s.keys.connection(connectionID))
+	if userID, ok := owners[0].(string); ok {
+		pipe.SRem(ctx, s.keys.user(userID), connectionID)
+	}
+	if tenantID, ok := owners[1].(string); ok {
+		pipe.SRem(ctx, s.keys.tenant(tenantID), connectionID)
+	}
+	pipe.ZRem(ctx, s.keys.pingIndex(), connectionID)
+	pipe.ZRem(ctx, s.keys.tokenIndex(), connectionID)
+}
+
+// listMembers returns a page of the connections in an index set, walking it
+// with SSCAN so a large set is never read whole. The SSCAN cursor is the
+// NextToken. SSCAN may return more members than asked for; the rest of such a
+// batch is resumed from the same cursor after the page's last ID.
+func (s *connectionStore) listMembers(ctx context.Context, op, owner, key string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	cursor, after, err := parseScanToken(opts.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := opts.PageSize()
+	ids, next, err := s.client.SScan(ctx, key, cursor, "", int64(max(limit, minScanCount))).Result()
+	if err != nil {
+		return nil, store.NewStoreError(op, store.ConnectionsTable, owner, err)
+	}
+
+	// Order the batch so a split batch resumes after the last ID returned
+	sort.Strings(ids)
+	if after != "" {
+		ids = ids[sort.SearchStrings(ids, after+"\x00"):]
+	}
+
+	token := ""
+	if next != 0 {
+		token = strconv.FormatUint(next, 10)
+	}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		token = strconv.FormatUint(cursor, 10) + scanTokenSeparator + ids[limit-1]
+	}
+
+	conns, err := s.load(ctx, op, ids, func(pipe redis.Pipeliner, expired []interface{}) {
+		pipe.SRem(ctx, key, expired...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &store.Page[*store.Connection]{Items: conns, NextToken: token}, nil
+}
+
+// minScanCount is the least COUNT hint passed to SSCAN. Small sets come back
+// whole whatever the hint, and on large ones a bigger batch saves round trips
+// when pages are small.
+const minScanCount = 100
+
+// scanTokenSeparator separates the SSCAN cursor from the last ID returned
+// when a page ends part way through a batch
+const scanTokenSeparator = "/"
+
+// parseScanToken decodes a NextToken written by listMembers. An empty token
+// starts a new scan.
+func parseScanToken(token string) (cursor uint64, after string, err error) {
+	if token == "" {
+		return 0, "", nil
+	}
+
+	raw, after, _ := strings.Cut(token, scanTokenSeparator)
+	cursor, err = strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, "", store.NewValidationError("NextToken", "is not a valid page token")
+	}
+	return cursor, after, nil
+}
+
+// listBefore returns a page of the connections scored before the specified
+// time in a sorted set index. A page starts at its cursor's score, so earlier
+// entries are not read again.
+func (s *connectionStore) listBefore(ctx context.Context, op, index string, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	after, err := store.ParseCursor(opts.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	from := "-inf"
+	if after != nil {
+		from = strconv.FormatInt(after.At, 10)
+	}
+	members, err := s.client.ZRangeByScoreWithScores(ctx, index, &redis.ZRangeBy{
+		Min: from,
+		Max: "(" + strconv.FormatFloat(score(before), 'f', -1, 64),
+	}).Result()
+	if err != nil {
+		return nil, store.NewStoreError(op, store.ConnectionsTable, "", err)
+	}
+
+	page, err := store.PageItems(members, opts, func(member redis.Z) store.Cursor {
+		return store.Cursor{At: int64(member.Score), ID: member.Member.(string)}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(page.Items))
+	for i, member := range page.Items {
+		ids[i] = member.Member.(string)
+	}
+	conns, err := s.load(ctx, op, ids, func(pipe redis.Pipeliner, expired []interface{}) {
+		pipe.ZRem(ctx, s.keys.pingIndex(), expired...)
+		pipe.ZRem(ctx, s.keys.tokenIndex(), expired...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &store.Page[*store.Connection]{Items: conns, NextToken: page.NextToken}, nil
+}
+
+// load fetches the connections with the given IDs, in the same order. Redis
+// expires connection hashes but not the index entries pointing at them, so
+// entries for connections that have expired are passed to prune.
+func (s *connectionStore) load(ctx context.Context, op string, ids []string, prune func(pipe redis.Pipeliner, expired []interface{})) ([]*store.Connection, error) {
+	result := make([]*store.Connection, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, s.keys.connection(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, store.NewStoreError(op, store.ConnectionsTable, "", err)
+	}
+
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			expired = append(expired, ids[i])
+			continue
+		}
+		conn, err := decodeConnection(fields)
+		if err != nil {
+			return nil, store.NewStoreError(op, store.ConnectionsTable, ids[i], err)
+		}
+		result = append(result, conn)
+	}
+
+	// Pruning is best effort; the next read retries it
+	if len(expired) > 0 {
+		s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			prune(pipe, expired)
+			return nil
+		})
+	}
+	return result, nil
+}
+
+// extendExpiry sets a key to expire at ttl (Unix seconds) unless it already
+// expires later. Requires Redis 7.0 or later.
+func extendExpiry(ctx context.Context, pipe redis.Pipeliner, key string, ttl int64) {
+	pipe.Do(ctx, "EXPIREAT", key, ttl, "NX")
+	pipe.Do(ctx, "EXPIREAT", key, ttl, "GT")
+}
+
+// score converts a time to a sorted set score in Unix microseconds, which
+// float64 represents exactly
+func score(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// encodeConnection converts a connection to hash fields
+func encodeConnection(conn *store.Connection) (map[string]interface{}, error) {
+	fields := map[string]interface{}{
+		"connectionId":   conn.ConnectionID,
+		"userId":         conn.UserID,
+		"tenantId":       conn.TenantID,
+		"endpoint":       conn.Endpoint,
+		"connectedAt":    formatTime(conn.ConnectedAt),
+		"lastPing":       formatTime(conn.LastPing),
+		"tokenExpiresAt": formatTime(conn.TokenExpiresAt),
+		"ttl":            conn.TTL,
+	}
+	if conn.Metadata != nil {
+		metadata, err := json.Marshal(conn.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		fields["metadata"] = string(metadata)
+	}
+	return fields, nil
+}
+
+// decodeConnection converts hash fields written by encodeConnection back to a connection
+func decodeConnection(fields map[string]string) (*store.Connection, error) {
+	conn := &store.Connection{
+		ConnectionID: fields["connectionId"],
+		UserID:       fields["userId"],
+		TenantID:     fields["tenantId"],
+		Endpoint:     fields["endpoint"],
+	}
+
+	var err error
+	if conn.ConnectedAt, err = parseTime(fields["connectedAt"]); err != nil {
+		return nil, err
+	}
+	if conn.LastPing, err = parseTime(fields["lastPing"]); err != nil {
+		return nil, err
+	}
+	if conn.TokenExpiresAt, err = parseTime(fields["tokenExpiresAt"]); err != nil {
+		return nil, err
+	}
+	if ttl := fields["ttl"]; ttl != "" {
+		if conn.TTL, err = strconv.ParseInt(ttl, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if metadata, ok := fields["metadata"]; ok {
+		if err := json.Unmarshal([]byte(metadata), &conn.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// formatTime formats a time for a hash field; the zero time is stored as ""
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseTime parses a hash field written by formatTime
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// validateConnection validates a connection before saving
+func validateConnection(conn *store.Connection) error {
+	if conn == nil {
+		return store.NewValidationError("connection", "cannot be nil")
+	}
+	if conn.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if conn.UserID == "" {
+		return store.NewValidationError("UserID", "cannot be empty")
+	}
+	if conn.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	if conn.Endpoint == "" {
+		return store.NewValidationError("Endpoint", "cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+// newTestClient starts an in-process Redis server and connects to it
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func newTestConnection(id, userID, tenantID string) *store.Connection {
+	now := time.Now()
+	return &store.Connection{
+		ConnectionID: id,
+		UserID:       userID,
+		TenantID:     tenantID,
+		Endpoint:     "wss://example.com",
+		ConnectedAt:  now,
+		LastPing:     now,
+	}
+}
+
+func TestConnectionStore_Conformance(t *testing.T) {
+	storetest.RunConnectionStoreTests(t, func(t *testing.T) store.ConnectionStore {
+		_, client := newTestClient(t)
+		return NewConnectionStore(client, "")
+	})
+}
+
+func TestConnectionStore_Keys(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	s := NewConnectionStore(client, "test:")
+
+	conn := newTestConnection("conn-1", "user-1", "tenant-1")
+	conn.TokenExpiresAt = time.Now().Add(time.Hour)
+	require.NoError(t, s.Save(ctx, conn))
+
+	assert.Equal(t, "user-1", server.HGet("test:conn:conn-1", "userId"))
+	members, err := server.Members("test:user:user-1:conns")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"conn-1"}, members)
+	members, err = server.Members("test:tenant:tenant-1:conns")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"conn-1"}, members)
+	assert.True(t, server.Exists("test:conns:last-ping"))
+	assert.True(t, server.Exists("test:conns:token-expiry"))
+
+	// The hash and index sets expire with the connection
+	assert.InDelta(t, time.Until(time.Unix(conn.TTL, 0)).Seconds(), server.TTL("test:conn:conn-1").Seconds(), 2)
+	assert.InDelta(t, server.TTL("test:conn:conn-1").Seconds(), server.TTL("test:user:user-1:conns").Seconds(), 1)
+
+	require.NoError(t, s.Delete(ctx, "conn-1"))
+	for _, key := range []string{"test:conn:conn-1", "test:user:user-1:conns", "test:tenant:tenant-1:conns", "test:conns:last-ping", "test:conns:token-expiry"} {
+		assert.False(t, server.Exists(key), key)
+	}
+}
+
+func TestConnectionStore_ListScansSet(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	s := NewConnectionStore(client, "")
+
+	for i := 0; i < 250; i++ {
+		require.NoError(t, s.Save(ctx, newTestConnection(fmt.Sprintf("conn-%03d", i), "user-1", "tenant-1")))
+	}
+
+	// Pages follow the SSCAN cursor, splitting batches larger than the page
+	seen := make(map[string]bool)
+	pages := store.NewPaginator(40, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return s.ListByTenant(ctx, "tenant-1", opts)
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Items), 40)
+		for _, conn := range page.Items {
+			assert.False(t, seen[conn.ConnectionID], "%s appears on two pages", conn.ConnectionID)
+			seen[conn.ConnectionID] = true
+		}
+	}
+	assert.Len(t, seen, 250)
+
+	_, err := s.ListByTenant(ctx, "tenant-1", store.PageOptions{NextToken: "cursor"})
+	var validationErr *store.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestConnectionStore_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+	s := NewConnectionStore(client, "")
+
+	short := newTestConnection("conn-short", "user-1", "tenant-1")
+	short.TTL = time.Now().Add(time.Minute).Unix()
+	long := newTestConnection("conn-long", "user-1", "tenant-1")
+	long.TTL = time.Now().Add(time.Hour).Unix()
+	require.NoError(t, s.Save(ctx, short))
+	require.NoError(t, s.Save(ctx, long))
+
+	server.FastForward(2 * time.Minute)
+
+	_, err := s.Get(ctx, "conn-short")
+	assert.True(t, store.IsNotFound(err))
+	assert.True(t, store.IsNotFound(s.UpdateLastPing(ctx, "conn-short")))
+
+	conns, err := s.ListByUser(ctx, "user-1", store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, conns.Items, 1)
+	assert.Equal(t, "conn-long", conns.Items[0].ConnectionID)
+
+	// Reading the index pruned the expired entry
+	members, err := server.Members(DefaultPrefix + "user:user-1:conns")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"conn-long"}, members)
+
+	// The sets outlive the short connection
+	server.FastForward(2 * time.Hour)
+	assert.False(t, server.Exists(DefaultPrefix+"tenant:tenant-1:conns"))
+}
+
+func TestConnectionStore_SaveMovesIndexes(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	s := NewConnectionStore(client, "")
+
+	require.NoError(t, s.Save(ctx, newTestConnection("conn-1", "user-1", "tenant-1")))
+	require.NoError(t, s.Save(ctx, newTestConnection("conn-1", "user-2", "tenant-2")))
+
+	for _, list := range []func(context.Context, string, store.PageOptions) (*store.Page[*store.Connection], error){s.ListByUser, s.ListByTenant} {
+		conns, err := list(ctx, "user-1", store.PageOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, conns.Items)
+	}
+	old, err := s.ListByTenant(ctx, "tenant-1", store.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, old.Items)
+
+	moved, err := s.ListByTenant(ctx, "tenant-2", store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, moved.Items, 1)
+	assert.Equal(t, "user-2", moved.Items[0].UserID)
+}
+
+func TestConnectionStore_UpdateClaimsClearsTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	s := NewConnectionStore(client, "")
+
+	conn := newTestConnection("conn-1", "user-1", "tenant-1")
+	conn.TokenExpiresAt = time.Now().Add(time.Minute)
+	require.NoError(t, s.Save(ctx, conn))
+
+	require.NoError(t, s.UpdateClaims(ctx, "conn-1", nil, time.Time{}))
+
+	expiring, err := s.ListExpiring(ctx, time.Now().Add(time.Hour), store.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, expiring.Items)
+
+	got, err := s.Get(ctx, "conn-1")
+	require.NoError(t, err)
+	assert.Nil(t, got.Metadata)
+	assert.True(t, got.TokenExpiresAt.IsZero())
+}
@@ -0,0 +1,70 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PubSub carries topic broadcasts between processes over Redis pub/sub. It
+// implements connection.PubSub.
+type PubSub struct {
+	client redis.UniversalClient
+	keys   keys
+}
+
+// NewPubSub creates a Redis pub/sub backend. Channels are prefixed with
+// prefix, or DefaultPrefix when it is empty.
+func NewPubSub(client redis.UniversalClient, prefix string) *PubSub {
+	return &PubSub{
+		client: client,
+		keys:   newKeys(prefix),
+	}
+}
+
+// Publish sends payload to every current subscriber of topic. Like all Redis
+// pub/sub messages, it is lost if nobody is subscribed.
+func (p *PubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	if err := p.client.Publish(ctx, p.keys.channel(topic), payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish to topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe calls handler, one message at a time, for messages published to
+// topics matching any of the glob patterns, until ctx is done. It returns once
+// the subscription is active, so every message published afterwards is
+// delivered.
+func (p *PubSub) Subscribe(ctx context.Context, handler func(topic string, payload []byte), patterns ...string) error {
+	channels := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		channels[i] = p.keys.channel(pattern)
+	}
+
+	sub := p.client.PSubscribe(ctx, channels...)
+	for range channels {
+		if _, err := sub.Receive(ctx); err != nil {
+			sub.Close()
+			return fmt.Errorf("failed to subscribe to %v: %w", patterns, err)
+		}
+	}
+
+	messages := sub.Channel()
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handler(strings.TrimPrefix(msg.Channel, p.keys.channel("")), []byte(msg.Payload))
+			}
+		}
+	}()
+	return nil
+}
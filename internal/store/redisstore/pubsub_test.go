@@ -0,0 +1,51 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+var _ connection.PubSub = (*PubSub)(nil)
+
+type published struct {
+	topic   string
+	payload string
+}
+
+func TestPubSub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, client := newTestClient(t)
+	pubsub := NewPubSub(client, "")
+
+	messages := make(chan published, 10)
+	require.NoError(t, pubsub.Subscribe(ctx, func(topic string, payload []byte) {
+		messages <- published{topic, string(payload)}
+	}, "tenant:*"))
+
+	// The subscription is active once Subscribe returns
+	require.NoError(t, pubsub.Publish(ctx, "user:user-1", []byte(`{"skipped":true}`)))
+	require.NoError(t, pubsub.Publish(ctx, "tenant:tenant-1", []byte(`{"type":"notice"}`)))
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, published{"tenant:tenant-1", `{"type":"notice"}`}, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	assert.Equal(t, 1, server.PubSubNumPat())
+
+	// Cancelling the context ends the subscription
+	cancel()
+	assert.Eventually(t, func() bool {
+		return server.PubSubNumPat() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, messages)
+}
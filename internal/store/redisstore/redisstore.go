@@ -0,0 +1,58 @@
+// Package redisstore implements the connection registry and topic pub/sub on
+// Redis, for tenants whose broadcasts fan out to many connections.
+//
+// Each connection is a hash that Redis expires at the connection's TTL. Sets
+// per user and per tenant index the hashes, so a page of ListByTenant costs
+// one SMEMBERS and one pipelined round trip instead of an index query. Index
+// entries for connections that have expired are pruned when they are next read.
+//
+// Writes use MULTI across a connection's keys, so on Redis Cluster every key
+// must hash to the same slot: give the prefix a hash tag such as
+// "{streamer}:". Redis 7.0 or later is required.
+package redisstore
+
+// DefaultPrefix is prepended to every key and channel when no prefix is given
+const DefaultPrefix = "streamer:"
+
+// keys builds the Redis key names under a prefix
+type keys struct {
+	prefix string
+}
+
+// newKeys returns the key names under prefix, or DefaultPrefix when it is empty
+func newKeys(prefix string) keys {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return keys{prefix: prefix}
+}
+
+// connection is the hash holding a connection
+func (k keys) connection(connectionID string) string {
+	return k.prefix + "conn:" + connectionID
+}
+
+// user is the set of a user's connection IDs
+func (k keys) user(userID string) string {
+	return k.prefix + "user:" + userID + ":conns"
+}
+
+// tenant is the set of a tenant's connection IDs
+func (k keys) tenant(tenantID string) string {
+	return k.prefix + "tenant:" + tenantID + ":conns"
+}
+
+// pingIndex is the sorted set of connection IDs scored by last ping
+func (k keys) pingIndex() string {
+	return k.prefix + "conns:last-ping"
+}
+
+// tokenIndex is the sorted set of connection IDs scored by token expiry
+func (k keys) tokenIndex() string {
+	return k.prefix + "conns:token-expiry"
+}
+
+// channel is the pub/sub channel for a topic
+func (k keys) channel(topic string) string {
+	return k.prefix + "topic:" + topic
+}
@@ -0,0 +1,115 @@
+// Package results provides ResultStore implementations for offloaded async results
+package results
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// storeName identifies result stores in StoreError messages
+const storeName = "results"
+
+// FileStore keeps results on the local filesystem. It is meant for local
+// development and tests; Lambda functions should use S3Store.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file store rooted at dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, store.NewValidationError("dir", "cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create result directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes a result. An existing result is never replaced.
+func (s *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return store.NewStoreError("Put", storeName, key, err)
+	}
+
+	// Write to a temporary file first so readers never see a partial result,
+	// then link it into place, which fails if the result already exists
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return store.NewStoreError("Put", storeName, key, err)
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return store.NewStoreError("Put", storeName, key, store.ErrAlreadyExists)
+		}
+		return store.NewStoreError("Put", storeName, key, err)
+	}
+	return nil
+}
+
+// Get reads a result
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, store.NewStoreError("Get", storeName, key, store.ErrNotFound)
+		}
+		return nil, store.NewStoreError("Get", storeName, key, err)
+	}
+	return data, nil
+}
+
+// Delete removes a result. Deleting a missing result is not an error.
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return store.NewStoreError("Delete", storeName, key, err)
+	}
+	return nil
+}
+
+// path maps a key to a file under the store directory, rejecting keys that escape it
+func (s *FileStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// validateKey checks a key is a relative slash-separated path without dot segments
+func validateKey(key string) error {
+	if key == "" {
+		return store.NewValidationError("key", "cannot be empty")
+	}
+	if strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return store.NewValidationError("key", "must be a relative path")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return store.NewValidationError("key", "contains an invalid path segment")
+		}
+	}
+	return nil
+}
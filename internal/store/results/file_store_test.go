@@ -0,0 +1,53 @@
+package results
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, fileStore.Put(ctx, "tenant-1/req-1.json", []byte(`{"rows":1}`)))
+
+	data, err := fileStore.Get(ctx, "tenant-1/req-1.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"rows":1}`, string(data))
+
+	// An existing result is never replaced
+	err = fileStore.Put(ctx, "tenant-1/req-1.json", []byte(`{"rows":2}`))
+	assert.True(t, store.IsAlreadyExists(err))
+	data, err = fileStore.Get(ctx, "tenant-1/req-1.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"rows":1}`, string(data))
+
+	require.NoError(t, fileStore.Delete(ctx, "tenant-1/req-1.json"))
+	require.NoError(t, fileStore.Delete(ctx, "tenant-1/req-1.json"))
+
+	_, err = fileStore.Get(ctx, "tenant-1/req-1.json")
+	assert.True(t, store.IsNotFound(err))
+}
+
+func TestFileStore_InvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a//b", "a/./b", `a\b`} {
+		t.Run(key, func(t *testing.T) {
+			err := fileStore.Put(ctx, key, []byte("x"))
+			var validationErr *store.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+
+	_, err = NewFileStore("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// S3Store keeps results in an S3 bucket
+type S3Store struct {
+	bucket     string
+	prefix     string
+	endpoint   string
+	httpClient *http.Client
+	client     *s3.Client
+}
+
+// S3Option configures an S3Store
+type S3Option func(*S3Store)
+
+// WithPrefix stores every result under prefix, e.g. "results/"
+func WithPrefix(prefix string) S3Option {
+	return func(s *S3Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithEndpoint overrides the S3 endpoint, e.g. for LocalStack or tests.
+// Buckets are addressed path-style: <endpoint>/<bucket>/<key>.
+func WithEndpoint(endpoint string) S3Option {
+	return func(s *S3Store) {
+		s.endpoint = strings.TrimRight(endpoint, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for S3 requests
+func WithHTTPClient(client *http.Client) S3Option {
+	return func(s *S3Store) {
+		s.httpClient = client
+	}
+}
+
+// NewS3Store creates a result store for bucket using the region and credentials in cfg
+func NewS3Store(cfg aws.Config, bucket string, opts ...S3Option) (*S3Store, error) {
+	if bucket == "" {
+		return nil, store.NewValidationError("bucket", "cannot be empty")
+	}
+	if cfg.Region == "" {
+		return nil, store.NewValidationError("region", "cannot be empty")
+	}
+	if cfg.Credentials == nil {
+		return nil, store.NewValidationError("credentials", "cannot be nil")
+	}
+
+	s := &S3Store{
+		bucket:     bucket,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.HTTPClient = s.httpClient
+		if s.endpoint != "" {
+			o.BaseEndpoint = aws.String(s.endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return s, nil
+}
+
+// Put uploads a result. The write is conditional on the key being unused,
+// so an existing result is never replaced.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.prefix + key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if err != nil {
+		if statusCode(err) == http.StatusPreconditionFailed {
+			return store.NewStoreError("Put", storeName, key, store.ErrAlreadyExists)
+		}
+		return store.NewStoreError("Put", storeName, key, err)
+	}
+	return nil
+}
+
+// Get downloads a result
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		if statusCode(err) == http.StatusNotFound {
+			return nil, store.NewStoreError("Get", storeName, key, store.ErrNotFound)
+		}
+		return nil, store.NewStoreError("Get", storeName, key, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, store.NewStoreError("Get", storeName, key, err)
+	}
+	return data, nil
+}
+
+// Delete removes a result. S3 reports success for missing keys.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return store.NewStoreError("Delete", storeName, key, err)
+	}
+	return nil
+}
+
+// statusCode returns the HTTP status of a failed S3 call, or 0 if it never got a response
+func statusCode(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
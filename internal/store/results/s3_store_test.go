@@ -0,0 +1,114 @@
+package results
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// fakeS3 is a minimal path-style S3 object API
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	authz   []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authz = append(f.authz, r.Header.Get("Authorization"))
+
+	switch r.Method {
+	case http.MethodPut:
+		if _, exists := f.objects[r.URL.Path]; exists && r.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte("<Error><Code>PreconditionFailed</Code></Error>"))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newTestS3Store(t *testing.T, handler http.Handler) *S3Store {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	}
+	s3Store, err := NewS3Store(cfg, "results-bucket", WithEndpoint(server.URL), WithPrefix("results/"))
+	require.NoError(t, err)
+	return s3Store
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	s3Store := newTestS3Store(t, fake)
+
+	require.NoError(t, s3Store.Put(ctx, "tenant-1/req-1.json", []byte(`{"rows":1}`)))
+	assert.Contains(t, fake.objects, "/results-bucket/results/tenant-1/req-1.json")
+
+	data, err := s3Store.Get(ctx, "tenant-1/req-1.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"rows":1}`, string(data))
+
+	// An existing result is never replaced
+	err = s3Store.Put(ctx, "tenant-1/req-1.json", []byte(`{"rows":2}`))
+	assert.True(t, store.IsAlreadyExists(err))
+	assert.Equal(t, `{"rows":1}`, string(fake.objects["/results-bucket/results/tenant-1/req-1.json"]))
+
+	require.NoError(t, s3Store.Delete(ctx, "tenant-1/req-1.json"))
+	_, err = s3Store.Get(ctx, "tenant-1/req-1.json")
+	assert.True(t, store.IsNotFound(err))
+
+	for _, authz := range fake.authz {
+		assert.True(t, strings.HasPrefix(authz, "AWS4-HMAC-SHA256 Credential=AKID/"), authz)
+	}
+}
+
+func TestS3Store_Errors(t *testing.T) {
+	ctx := context.Background()
+	s3Store := newTestS3Store(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("AccessDenied"))
+	}))
+
+	err := s3Store.Put(ctx, "tenant-1/req-1.json", []byte("{}"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+
+	_, err = s3Store.Get(ctx, "tenant-1/req-1.json")
+	assert.False(t, store.IsNotFound(err))
+
+	assert.Error(t, s3Store.Put(ctx, "../escape", []byte("{}")))
+
+	_, err = NewS3Store(aws.Config{Region: "us-east-1"}, "bucket")
+	assert.Error(t, err)
+	_, err = NewS3Store(aws.Config{}, "")
+	assert.Error(t, err)
+}
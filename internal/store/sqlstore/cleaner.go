@@ -0,0 +1,73 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Cleaner deletes rows whose TTL has passed, standing in for DynamoDB TTL.
+// The stores already hide expired rows, so cleanup only reclaims space and
+// can run as rarely as suits the deployment.
+type Cleaner struct {
+	db      *sql.DB
+	dialect Dialect
+	logger  *log.Logger
+	now     func() time.Time
+}
+
+// NewCleaner creates a cleaner for the streamer tables
+func NewCleaner(db *sql.DB, dialect Dialect, logger *log.Logger) *Cleaner {
+	return &Cleaner{
+		db:      db,
+		dialect: dialect,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Cleanup deletes every expired connection, request and subscription and
+// returns how many rows were deleted
+func (c *Cleaner) Cleanup(ctx context.Context) (int64, error) {
+	now := c.now().Unix()
+
+	var deleted int64
+	for _, table := range []string{store.ConnectionsTable, store.RequestsTable, store.SubscriptionsTable} {
+		query := `DELETE FROM ` + table + ` WHERE ttl <> 0 AND ttl <= ?`
+		result, err := c.db.ExecContext(ctx, c.dialect.rebind(query), now)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to clean up %s: %w", table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to clean up %s: %w", table, err)
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// Run calls Cleanup every interval until ctx is done. Failures are logged and
+// retried at the next interval.
+func (c *Cleaner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := c.Cleanup(ctx)
+			if err != nil && ctx.Err() == nil {
+				c.logger.Printf("TTL cleanup failed: %v", err)
+			} else if deleted > 0 {
+				c.logger.Printf("TTL cleanup deleted %d expired rows", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// countRows returns the rows in a table, including expired ones
+func countRows(t *testing.T, c *Cleaner, table string) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, c.db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&n))
+	return n
+}
+
+func TestCleaner_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	now := time.Now()
+	expiredTTL := now.Add(-time.Minute).Unix()
+
+	conns := NewConnectionStore(db, SQLite)
+	queue := NewRequestQueue(db, SQLite)
+	subs := NewSubscriptionStore(db, SQLite)
+
+	for _, conn := range []*store.Connection{
+		{ConnectionID: "conn-live", UserID: "user-1", TenantID: "tenant-1", Endpoint: "wss://example.com"},
+		{ConnectionID: "conn-expired", UserID: "user-1", TenantID: "tenant-1", Endpoint: "wss://example.com", TTL: expiredTTL},
+	} {
+		require.NoError(t, conns.Save(ctx, conn))
+	}
+
+	live := newTestRequest("req-live", time.Time{})
+	expired := newTestRequest("req-expired", time.Time{})
+	expired.TTL = expiredTTL
+	require.NoError(t, queue.Enqueue(ctx, live))
+	require.NoError(t, queue.Enqueue(ctx, expired))
+
+	require.NoError(t, subs.Subscribe(ctx, &store.Subscription{ConnectionID: "conn-live", RequestID: "req-live"}))
+	require.NoError(t, subs.Subscribe(ctx, &store.Subscription{ConnectionID: "conn-live", RequestID: "req-expired", TTL: expiredTTL}))
+
+	cleaner := NewCleaner(db, SQLite, log.New(io.Discard, "", 0))
+	cleaner.now = func() time.Time { return now }
+
+	deleted, err := cleaner.Cleanup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	for _, table := range []string{store.ConnectionsTable, store.RequestsTable, store.SubscriptionsTable} {
+		assert.Equal(t, 1, countRows(t, cleaner, table), table)
+	}
+
+	// Nothing is left to delete
+	deleted, err = cleaner.Cleanup(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestCleaner_Run(t *testing.T) {
+	db := openTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, NewConnectionStore(db, SQLite).Save(ctx, &store.Connection{
+		ConnectionID: "conn-expired",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Endpoint:     "wss://example.com",
+		TTL:          time.Now().Add(-time.Minute).Unix(),
+	}))
+
+	cleaner := NewCleaner(db, SQLite, log.New(io.Discard, "", 0))
+	done := make(chan struct{})
+	go func() {
+		cleaner.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return countRows(t, cleaner, store.ConnectionsTable) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,247 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// connectionColumns are the streamer_connections columns, in scan order
+var connectionColumns = []string{
+	"connection_id", "user_id", "tenant_id", "endpoint", "connected_at",
+	"last_ping", "metadata", "token_expires_at", "ttl",
+}
+
+// connectionStore implements ConnectionStore on database/sql
+type connectionStore struct {
+	db      *sql.DB
+	dialect Dialect
+	now     func() time.Time
+}
+
+// NewConnectionStore creates a new SQL connection store
+func NewConnectionStore(db *sql.DB, dialect Dialect) store.ConnectionStore {
+	return &connectionStore{
+		db:      db,
+		dialect: dialect,
+		now:     time.Now,
+	}
+}
+
+// Save creates or updates a connection
+func (s *connectionStore) Save(ctx context.Context, conn *store.Connection) error {
+	if err := validateConnection(conn); err != nil {
+		return err
+	}
+
+	// Set TTL to 24 hours from now if not set
+	if conn.TTL == 0 {
+		conn.TTL = s.now().Add(24 * time.Hour).Unix()
+	}
+
+	metadata, err := toJSON(conn.Metadata)
+	if err != nil {
+		return store.NewStoreError("Save", store.ConnectionsTable, conn.ConnectionID, err)
+	}
+
+	query := `INSERT INTO ` + store.ConnectionsTable + ` (` + strings.Join(connectionColumns, ", ") + `)
+		VALUES (` + placeholders(len(connectionColumns)) + `)
+		ON CONFLICT (connection_id) DO UPDATE SET ` + upsertSet(connectionColumns[1:])
+
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(query),
+		conn.ConnectionID, conn.UserID, conn.TenantID, conn.Endpoint, toNanos(conn.ConnectedAt),
+		toNanos(conn.LastPing), metadata, toNanos(conn.TokenExpiresAt), conn.TTL)
+	if err != nil {
+		return store.NewStoreError("Save", store.ConnectionsTable, conn.ConnectionID, err)
+	}
+	return nil
+}
+
+// Get retrieves a connection by ID
+func (s *connectionStore) Get(ctx context.Context, connectionID string) (*store.Connection, error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	query := `SELECT ` + strings.Join(connectionColumns, ", ") + ` FROM ` + store.ConnectionsTable + `
+		WHERE connection_id = ? AND ` + live
+
+	conn, err := scanConnection(s.db.QueryRowContext(ctx, s.dialect.rebind(query), connectionID, s.now().Unix()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, store.ErrNotFound)
+	}
+	if err != nil {
+		return nil, store.NewStoreError("Get", store.ConnectionsTable, connectionID, err)
+	}
+	return conn, nil
+}
+
+// Delete removes a connection; deleting one that does not exist is not an error
+func (s *connectionStore) Delete(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	query := `DELETE FROM ` + store.ConnectionsTable + ` WHERE connection_id = ?`
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(query), connectionID); err != nil {
+		return store.NewStoreError("Delete", store.ConnectionsTable, connectionID, err)
+	}
+	return nil
+}
+
+// ListByUser returns a page of a user's connections
+func (s *connectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+	return s.list(ctx, "ListByUser", opts, "user_id = ?", userID)
+}
+
+// ListByTenant returns a page of a tenant's connections
+func (s *connectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	if tenantID == "" {
+		return nil, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	return s.list(ctx, "ListByTenant", opts, "tenant_id = ?", tenantID)
+}
+
+// UpdateLastPing updates the last ping timestamp
+func (s *connectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
+	now := s.now()
+	return s.update(ctx, "UpdateLastPing", connectionID, "last_ping = ?, ttl = ?",
+		toNanos(now), now.Add(24*time.Hour).Unix())
+}
+
+// UpdateClaims replaces a connection's metadata and token expiry
+func (s *connectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	encoded, err := toJSON(metadata)
+	if err != nil {
+		return store.NewStoreError("UpdateClaims", store.ConnectionsTable, connectionID, err)
+	}
+	return s.update(ctx, "UpdateClaims", connectionID, "metadata = ?, token_expires_at = ?",
+		encoded, toNanos(tokenExpiresAt))
+}
+
+// ListExpiring returns a page of connections whose token expires before the specified time
+func (s *connectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return s.list(ctx, "ListExpiring", opts, "token_expires_at <> 0 AND token_expires_at < ?", toNanos(before))
+}
+
+// ListStale returns a page of connections whose last ping is older than the specified time
+func (s *connectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	return s.list(ctx, "ListStale", opts, "last_ping < ?", toNanos(before))
+}
+
+// DeleteStale removes connections whose last ping is older than the specified time
+func (s *connectionStore) DeleteStale(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM ` + store.ConnectionsTable + ` WHERE last_ping < ?`
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(query), toNanos(before)); err != nil {
+		return store.NewStoreError("DeleteStale", store.ConnectionsTable, "", err)
+	}
+	return nil
+}
+
+// update sets columns on a live connection, reporting ErrNotFound when there is none
+func (s *connectionStore) update(ctx context.Context, op, connectionID, set string, args ...interface{}) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	query := `UPDATE ` + store.ConnectionsTable + ` SET ` + set + ` WHERE connection_id = ? AND ` + live
+	args = append(args, connectionID, s.now().Unix())
+
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return store.NewStoreError(op, store.ConnectionsTable, connectionID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return store.NewStoreError(op, store.ConnectionsTable, connectionID, err)
+	} else if n == 0 {
+		return store.NewStoreError(op, store.ConnectionsTable, connectionID, store.ErrNotFound)
+	}
+	return nil
+}
+
+// list returns the page of live connections matching where that opts
+// selects, oldest first
+func (s *connectionStore) list(ctx context.Context, op string, opts store.PageOptions, where string, args ...interface{}) (*store.Page[*store.Connection], error) {
+	after, afterArgs, err := afterCursor(opts, "connected_at", "connection_id")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + strings.Join(connectionColumns, ", ") + ` FROM ` + store.ConnectionsTable + `
+		WHERE ` + where + ` AND ` + live + after + `
+		ORDER BY connected_at, connection_id` + limitClause(opts.PageSize()+1)
+	args = append(append(args, s.now().Unix()), afterArgs...)
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, store.NewStoreError(op, store.ConnectionsTable, "", err)
+	}
+	defer rows.Close()
+
+	result := make([]*store.Connection, 0)
+	for rows.Next() {
+		conn, err := scanConnection(rows)
+		if err != nil {
+			return nil, store.NewStoreError(op, store.ConnectionsTable, "", err)
+		}
+		result = append(result, conn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, store.NewStoreError(op, store.ConnectionsTable, "", err)
+	}
+	return toPage(result, opts, connectionKey), nil
+}
+
+// connectionKey is the cursor key of a connection in list order
+func connectionKey(conn *store.Connection) store.Cursor {
+	return store.Cursor{At: toNanos(conn.ConnectedAt), ID: conn.ConnectionID}
+}
+
+// scanConnection reads a row selected with connectionColumns
+func scanConnection(row scanner) (*store.Connection, error) {
+	var (
+		conn                                  store.Connection
+		connectedAt, lastPing, tokenExpiresAt int64
+		metadata                              sql.NullString
+	)
+	err := row.Scan(&conn.ConnectionID, &conn.UserID, &conn.TenantID, &conn.Endpoint, &connectedAt,
+		&lastPing, &metadata, &tokenExpiresAt, &conn.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.ConnectedAt = fromNanos(connectedAt)
+	conn.LastPing = fromNanos(lastPing)
+	conn.TokenExpiresAt = fromNanos(tokenExpiresAt)
+	if err := fromJSON(metadata, &conn.Metadata); err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// validateConnection validates a connection before saving
+func validateConnection(conn *store.Connection) error {
+	if conn == nil {
+		return store.NewValidationError("connection", "cannot be nil")
+	}
+	if conn.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if conn.UserID == "" {
+		return store.NewValidationError("UserID", "cannot be empty")
+	}
+	if conn.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	if conn.Endpoint == "" {
+		return store.NewValidationError("Endpoint", "cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package sqlstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestConnectionStore_Conformance(t *testing.T) {
+	storetest.RunConnectionStoreTests(t, func(t *testing.T) store.ConnectionStore {
+		return NewConnectionStore(openTestDB(t), SQLite)
+	})
+}
+
+func TestConnectionStore_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewConnectionStore(openTestDB(t), SQLite).(*connectionStore)
+	s.now = func() time.Time { return now }
+
+	conn := &store.Connection{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Endpoint:     "wss://example.com",
+		LastPing:     now,
+		Metadata:     map[string]string{"role": "admin"},
+		TTL:          now.Add(time.Minute).Unix(),
+	}
+	require.NoError(t, s.Save(ctx, conn))
+
+	got, err := s.Get(ctx, "conn-1")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", got.Metadata["role"])
+	assert.True(t, now.Equal(got.LastPing))
+
+	s.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+	_, err = s.Get(ctx, "conn-1")
+	assert.True(t, store.IsNotFound(err))
+	assert.True(t, store.IsNotFound(s.UpdateLastPing(ctx, "conn-1")))
+
+	conns, err := s.ListByUser(ctx, "user-1", store.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, conns.Items)
+}
+
+func TestConnectionStore_UpdateLastPingExtendsTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewConnectionStore(openTestDB(t), SQLite).(*connectionStore)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Save(ctx, &store.Connection{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Endpoint:     "wss://example.com",
+		TTL:          now.Add(time.Minute).Unix(),
+	}))
+
+	s.now = func() time.Time { return now.Add(30 * time.Second) }
+	require.NoError(t, s.UpdateLastPing(ctx, "conn-1"))
+
+	conn, err := s.Get(ctx, "conn-1")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Second+24*time.Hour).Unix(), conn.TTL)
+}
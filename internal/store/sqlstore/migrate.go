@@ -0,0 +1,150 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MigrationsTable records which migrations have been applied
+const MigrationsTable = "streamer_schema_migrations"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change, read from migrations/NNNN_name.sql
+type Migration struct {
+	Version int
+	Name    string
+
+	statements []string
+}
+
+// Migrations returns the embedded migrations in version order
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		version, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		n, err := strconv.Atoi(version)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.sql", entry.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: n, Name: name, statements: splitStatements(string(data))})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies the migrations that have not been applied to db yet. All
+// pending migrations run in one transaction, so a failure leaves the schema
+// unchanged. On PostgreSQL concurrent callers wait for each other; SQLite
+// callers are serialized by its write lock.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	if err := dialect.validate(); err != nil {
+		return err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	create := `CREATE TABLE IF NOT EXISTS ` + MigrationsTable + ` (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create %s: %w", MigrationsTable, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dialect == Postgres {
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE `+MigrationsTable+` IN EXCLUSIVE MODE`); err != nil {
+			return fmt.Errorf("failed to lock %s: %w", MigrationsTable, err)
+		}
+	}
+
+	applied, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		for _, stmt := range m.statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+		}
+
+		record := dialect.rebind(`INSERT INTO ` + MigrationsTable + ` (version, name, applied_at) VALUES (?, ?, ?)`)
+		if _, err := tx.ExecContext(ctx, record, m.Version, m.Name, time.Now().UnixNano()); err != nil {
+			return fmt.Errorf("failed to record migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions returns the versions recorded in the migrations table
+func appliedVersions(ctx context.Context, tx *sql.Tx) (map[int]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version FROM `+MigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", MigrationsTable, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// splitStatements splits a migration script into statements, dropping
+// comment lines. Statements end with a semicolon.
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
@@ -0,0 +1,60 @@
+-- Times are Unix nanoseconds, with 0 for unset times, and TTLs are Unix
+-- seconds, with 0 for rows that never expire. Maps and lists are JSON.
+
+CREATE TABLE IF NOT EXISTS streamer_connections (
+    connection_id    TEXT PRIMARY KEY,
+    user_id          TEXT NOT NULL,
+    tenant_id        TEXT NOT NULL,
+    endpoint         TEXT NOT NULL,
+    connected_at     BIGINT NOT NULL DEFAULT 0,
+    last_ping        BIGINT NOT NULL DEFAULT 0,
+    metadata         TEXT,
+    token_expires_at BIGINT NOT NULL DEFAULT 0,
+    ttl              BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS streamer_connections_user_idx ON streamer_connections (user_id);
+CREATE INDEX IF NOT EXISTS streamer_connections_tenant_idx ON streamer_connections (tenant_id);
+CREATE INDEX IF NOT EXISTS streamer_connections_last_ping_idx ON streamer_connections (last_ping);
+CREATE INDEX IF NOT EXISTS streamer_connections_ttl_idx ON streamer_connections (ttl);
+
+CREATE TABLE IF NOT EXISTS streamer_requests (
+    request_id         TEXT PRIMARY KEY,
+    connection_id      TEXT NOT NULL,
+    status             TEXT NOT NULL,
+    created_at         BIGINT NOT NULL DEFAULT 0,
+    action             TEXT NOT NULL,
+    payload            TEXT,
+    processing_started BIGINT,
+    processing_ended   BIGINT,
+    result             TEXT,
+    error              TEXT NOT NULL DEFAULT '',
+    progress           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    progress_message   TEXT NOT NULL DEFAULT '',
+    progress_details   TEXT,
+    retry_count        INTEGER NOT NULL DEFAULT 0,
+    max_retries        INTEGER NOT NULL DEFAULT 0,
+    retry_after        BIGINT NOT NULL DEFAULT 0,
+    user_id            TEXT NOT NULL,
+    tenant_id          TEXT NOT NULL,
+    permissions        TEXT,
+    ttl                BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS streamer_requests_connection_idx ON streamer_requests (connection_id, created_at);
+CREATE INDEX IF NOT EXISTS streamer_requests_status_idx ON streamer_requests (status, created_at);
+CREATE INDEX IF NOT EXISTS streamer_requests_tenant_idx ON streamer_requests (tenant_id, status);
+CREATE INDEX IF NOT EXISTS streamer_requests_ttl_idx ON streamer_requests (ttl);
+
+CREATE TABLE IF NOT EXISTS streamer_subscriptions (
+    subscription_id TEXT PRIMARY KEY,
+    connection_id   TEXT NOT NULL,
+    request_id      TEXT NOT NULL,
+    event_types     TEXT,
+    created_at      BIGINT NOT NULL DEFAULT 0,
+    ttl             BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS streamer_subscriptions_connection_idx ON streamer_subscriptions (connection_id);
+CREATE INDEX IF NOT EXISTS streamer_subscriptions_request_idx ON streamer_subscriptions (request_id);
+CREATE INDEX IF NOT EXISTS streamer_subscriptions_ttl_idx ON streamer_subscriptions (ttl);
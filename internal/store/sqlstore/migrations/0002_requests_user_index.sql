@@ -0,0 +1,3 @@
+-- Serves a user's request history, newest first
+
+CREATE INDEX IF NOT EXISTS streamer_requests_user_idx ON streamer_requests (user_id, created_at);
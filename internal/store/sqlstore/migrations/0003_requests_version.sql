@@ -0,0 +1,4 @@
+-- Every request update increments version, so concurrent writers can tell
+-- whether a request changed since they read it
+
+ALTER TABLE streamer_requests ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
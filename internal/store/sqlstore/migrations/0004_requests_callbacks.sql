@@ -0,0 +1,6 @@
+-- Callback a request's outcome is POSTed to, and the log of delivery attempts
+-- as a JSON list
+
+ALTER TABLE streamer_requests ADD COLUMN callback_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE streamer_requests ADD COLUMN callback_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE streamer_requests ADD COLUMN callback_deliveries TEXT;
@@ -0,0 +1,545 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+)
+
+// maxRequestUpdateAttempts bounds the optimistic locking retries for a single update
+const maxRequestUpdateAttempts = 3
+
+// requestColumns are the streamer_requests columns, in scan order
+var requestColumns = []string{
+	"request_id", "connection_id", "status", "created_at", "action", "payload",
+	"processing_started", "processing_ended", "result", "error", "progress",
+	"progress_message", "progress_details", "retry_count", "max_retries",
+	"retry_after", "user_id", "tenant_id", "permissions", "ttl", "version",
+	"callback_url", "callback_secret", "callback_deliveries",
+}
+
+// requestQueue implements RequestQueue on database/sql
+type requestQueue struct {
+	lifecycle.Hooks
+
+	db      *sql.DB
+	dialect Dialect
+	now     func() time.Time
+}
+
+// NewRequestQueue creates a new SQL request queue
+func NewRequestQueue(db *sql.DB, dialect Dialect) store.RequestQueue {
+	return &requestQueue{
+		db:      db,
+		dialect: dialect,
+		now:     time.Now,
+	}
+}
+
+// Enqueue adds a new request to the queue. A request whose TTL has passed but
+// that has not been cleaned up yet is replaced.
+func (q *requestQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) error {
+	if err := validateRequest(req); err != nil {
+		return err
+	}
+
+	// Set default values
+	now := q.now()
+	if req.Status == "" {
+		req.Status = store.StatusPending
+	}
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = now
+	}
+	if req.TTL == 0 {
+		req.TTL = now.Add(7 * 24 * time.Hour).Unix() // 7 days TTL
+	}
+	req.Version = 1
+
+	args, err := requestArgs(req)
+	if err != nil {
+		return store.NewStoreError("Enqueue", store.RequestsTable, req.RequestID, err)
+	}
+
+	query := `INSERT INTO ` + store.RequestsTable + ` (` + strings.Join(requestColumns, ", ") + `)
+		VALUES (` + placeholders(len(requestColumns)) + `)
+		ON CONFLICT (request_id) DO UPDATE SET ` + upsertSet(requestColumns[1:]) + `
+		WHERE ` + store.RequestsTable + `.ttl <> 0 AND ` + store.RequestsTable + `.ttl <= ?`
+	args = append(args, now.Unix())
+
+	result, err := q.db.ExecContext(ctx, q.dialect.rebind(query), args...)
+	if err != nil {
+		return store.NewStoreError("Enqueue", store.RequestsTable, req.RequestID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return store.NewStoreError("Enqueue", store.RequestsTable, req.RequestID, err)
+	} else if n == 0 {
+		return store.NewStoreError("Enqueue", store.RequestsTable, req.RequestID, store.ErrAlreadyExists)
+	}
+	return nil
+}
+
+// Dequeue marks up to limit pending requests, oldest first, as processing and
+// returns them. On PostgreSQL rows claimed by a concurrent Dequeue are skipped
+// rather than waited for.
+func (q *requestQueue) Dequeue(ctx context.Context, limit int) ([]*store.AsyncRequest, error) {
+	now := q.now()
+
+	query := `UPDATE ` + store.RequestsTable + ` SET status = ?, processing_started = ?, version = version + 1
+		WHERE request_id IN (
+			SELECT request_id FROM ` + store.RequestsTable + `
+			WHERE status = ? AND ` + live + `
+			ORDER BY created_at, request_id` + limitClause(limit) + q.dialect.skipLocked() + `
+		)
+		RETURNING ` + strings.Join(requestColumns, ", ")
+
+	rows, err := q.db.QueryContext(ctx, q.dialect.rebind(query),
+		store.StatusProcessing, now.UnixNano(), store.StatusPending, now.Unix())
+	if err != nil {
+		return nil, store.NewStoreError("Dequeue", store.RequestsTable, "", err)
+	}
+	result, err := scanRequests(rows)
+	if err != nil {
+		return nil, store.NewStoreError("Dequeue", store.RequestsTable, "", err)
+	}
+
+	// RETURNING does not preserve the subquery's order
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].RequestID < result[j].RequestID
+	})
+
+	for _, req := range result {
+		q.Notify(ctx, store.StatusPending, store.StatusProcessing, req)
+	}
+	return result, nil
+}
+
+// UpdateStatus updates the status of a request
+func (q *requestQueue) UpdateStatus(ctx context.Context, requestID string, status store.RequestStatus, message string) error {
+	now := q.now().UnixNano()
+	switch status {
+	case store.StatusProcessing:
+		return q.update(ctx, "UpdateStatus", requestID, status, assign("processing_started = COALESCE(processing_started, ?)", now))
+	case store.StatusCompleted, store.StatusFailed, store.StatusCancelled:
+		return q.update(ctx, "UpdateStatus", requestID, status, assign("processing_ended = ?", now))
+	default:
+		return q.update(ctx, "UpdateStatus", requestID, status, assign(""))
+	}
+}
+
+// UpdateProgress updates the progress of a request
+func (q *requestQueue) UpdateProgress(ctx context.Context, requestID string, progress float64, message string, details map[string]interface{}) error {
+	encoded, err := toJSON(details)
+	if err != nil {
+		return store.NewStoreError("UpdateProgress", store.RequestsTable, requestID, err)
+	}
+	return q.update(ctx, "UpdateProgress", requestID, "", func(current *store.AsyncRequest) (string, []interface{}, error) {
+		if err := lifecycle.CheckProgress(current.Status); err != nil {
+			return "", nil, err
+		}
+		return "progress = ?, progress_message = ?, progress_details = ?", []interface{}{progress, message, encoded}, nil
+	})
+}
+
+// CompleteRequest marks a request as completed with results
+func (q *requestQueue) CompleteRequest(ctx context.Context, requestID string, result map[string]interface{}) error {
+	encoded, err := toJSON(result)
+	if err != nil {
+		return store.NewStoreError("CompleteRequest", store.RequestsTable, requestID, err)
+	}
+	return q.update(ctx, "CompleteRequest", requestID, store.StatusCompleted,
+		assign("processing_ended = ?, result = ?, progress = 100", q.now().UnixNano(), encoded))
+}
+
+// FailRequest marks a request as failed with an error
+func (q *requestQueue) FailRequest(ctx context.Context, requestID string, errMsg string) error {
+	return q.update(ctx, "FailRequest", requestID, store.StatusFailed,
+		assign("processing_ended = ?, error = ?", q.now().UnixNano(), errMsg))
+}
+
+// RecordCallbackDelivery appends an attempt to deliver the request's callback to its delivery log
+func (q *requestQueue) RecordCallbackDelivery(ctx context.Context, requestID string, delivery store.CallbackDelivery) error {
+	return q.update(ctx, "RecordCallbackDelivery", requestID, "", func(current *store.AsyncRequest) (string, []interface{}, error) {
+		encoded, err := toJSON(append(current.CallbackDeliveries, delivery))
+		if err != nil {
+			return "", nil, err
+		}
+		return "callback_deliveries = ?", []interface{}{encoded}, nil
+	})
+}
+
+// GetByConnection retrieves a page of a connection's requests
+func (q *requestQueue) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+	return q.list(ctx, "GetByConnection", opts, false, "connection_id = ?", connectionID)
+}
+
+// GetByStatus retrieves a page of requests in a status
+func (q *requestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return q.list(ctx, "GetByStatus", opts, false, "status = ?", status)
+}
+
+// GetByUser retrieves a page of a user's requests that match filter, newest first
+func (q *requestQueue) GetByUser(ctx context.Context, userID string, filter store.RequestFilter, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	if userID == "" {
+		return nil, store.NewValidationError("userID", "cannot be empty")
+	}
+
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if filter.TenantID != "" {
+		where += " AND tenant_id = ?"
+		args = append(args, filter.TenantID)
+	}
+	if len(filter.Statuses) > 0 {
+		where += " AND status IN (" + placeholders(len(filter.Statuses)) + ")"
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.Action != "" {
+		where += " AND action = ?"
+		args = append(args, filter.Action)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, filter.CreatedAfter.UnixNano())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, filter.CreatedBefore.UnixNano())
+	}
+	return q.list(ctx, "GetByUser", opts, true, where, args...)
+}
+
+// CountByTenant counts a tenant's requests in any of the given statuses
+func (q *requestQueue) CountByTenant(ctx context.Context, tenantID, action string, statuses ...store.RequestStatus) (int, error) {
+	if tenantID == "" {
+		return 0, store.NewValidationError("tenantID", "cannot be empty")
+	}
+	if len(statuses) == 0 {
+		return 0, store.NewValidationError("statuses", "at least one status is required")
+	}
+
+	query := `SELECT COUNT(*) FROM ` + store.RequestsTable + ` WHERE tenant_id = ?`
+	args := []interface{}{tenantID}
+	if action != "" {
+		query += ` AND action = ?`
+		args = append(args, action)
+	}
+	query += ` AND status IN (` + placeholders(len(statuses)) + `) AND ` + live
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	args = append(args, q.now().Unix())
+
+	var count int
+	if err := q.db.QueryRowContext(ctx, q.dialect.rebind(query), args...).Scan(&count); err != nil {
+		return 0, store.NewStoreError("CountByTenant", store.RequestsTable, tenantID, err)
+	}
+	return count, nil
+}
+
+// Defer holds a pending request back until the given time
+func (q *requestQueue) Defer(ctx context.Context, requestID string, until time.Time) error {
+	return q.update(ctx, "Defer", requestID, "", pendingOnly(assign("retry_after = ?", toNanos(until))))
+}
+
+// Resume clears a pending request's RetryAfter so it is processed again
+func (q *requestQueue) Resume(ctx context.Context, requestID string) error {
+	return q.update(ctx, "Resume", requestID, "", pendingOnly(assign("retry_after = 0")))
+}
+
+// ListDeferred retrieves a page of pending requests deferred until due or earlier
+func (q *requestQueue) ListDeferred(ctx context.Context, due time.Time, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return q.list(ctx, "ListDeferred", opts, false, "status = ? AND retry_after > 0 AND retry_after <= ?", store.StatusPending, due.UnixNano())
+}
+
+// Get retrieves a specific request
+func (q *requestQueue) Get(ctx context.Context, requestID string) (*store.AsyncRequest, error) {
+	if requestID == "" {
+		return nil, store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	query := `SELECT ` + strings.Join(requestColumns, ", ") + ` FROM ` + store.RequestsTable + `
+		WHERE request_id = ? AND ` + live
+
+	req, err := scanRequest(q.db.QueryRowContext(ctx, q.dialect.rebind(query), requestID, q.now().Unix()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.NewStoreError("Get", store.RequestsTable, requestID, store.ErrNotFound)
+	}
+	if err != nil {
+		return nil, store.NewStoreError("Get", store.RequestsTable, requestID, err)
+	}
+	return req, nil
+}
+
+// Delete removes a request
+func (q *requestQueue) Delete(ctx context.Context, requestID string) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	query := `DELETE FROM ` + store.RequestsTable + ` WHERE request_id = ? AND ` + live
+	result, err := q.db.ExecContext(ctx, q.dialect.rebind(query), requestID, q.now().Unix())
+	if err != nil {
+		return store.NewStoreError("Delete", store.RequestsTable, requestID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return store.NewStoreError("Delete", store.RequestsTable, requestID, err)
+	} else if n == 0 {
+		return store.NewStoreError("Delete", store.RequestsTable, requestID, store.ErrNotFound)
+	}
+	return nil
+}
+
+// requestChange returns the assignments an update makes and their
+// arguments, given the request as last read. Returning an error refuses the
+// update.
+type requestChange func(current *store.AsyncRequest) (string, []interface{}, error)
+
+// assign returns a change that always makes the same assignments
+func assign(set string, args ...interface{}) requestChange {
+	return func(*store.AsyncRequest) (string, []interface{}, error) {
+		return set, args, nil
+	}
+}
+
+// pendingOnly refuses a change to a request that is no longer pending
+func pendingOnly(change requestChange) requestChange {
+	return func(current *store.AsyncRequest) (string, []interface{}, error) {
+		if current.Status != store.StatusPending {
+			return "", nil, store.ErrRequestNotPending
+		}
+		return change(current)
+	}
+}
+
+// update moves a live request to status to, makes a change to it and
+// increments its version. An empty to leaves the status alone. The write is
+// conditional on the version the change was built from; a write that loses a
+// race is rebuilt from a fresh read, and ErrConflict is returned once the
+// attempts run out.
+func (q *requestQueue) update(ctx context.Context, op, requestID string, to store.RequestStatus, change requestChange) error {
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	for attempt := 0; attempt < maxRequestUpdateAttempts; attempt++ {
+		current, err := q.Get(ctx, requestID)
+		if err != nil {
+			if store.IsNotFound(err) {
+				return store.NewStoreError(op, store.RequestsTable, requestID, store.ErrNotFound)
+			}
+			return err
+		}
+		if to != "" {
+			if err := lifecycle.Check(current.Status, to); err != nil {
+				return store.NewStoreError(op, store.RequestsTable, requestID, err)
+			}
+		}
+		set, args, err := change(current)
+		if err != nil {
+			return store.NewStoreError(op, store.RequestsTable, requestID, err)
+		}
+
+		assignments := []string{"version = version + 1"}
+		if to != "" {
+			assignments = append(assignments, "status = ?")
+			args = append([]interface{}{to}, args...)
+		}
+		if set != "" {
+			assignments = append(assignments, set)
+		}
+		query := `UPDATE ` + store.RequestsTable + ` SET ` + strings.Join(assignments, ", ") + `
+			WHERE request_id = ? AND version = ? AND ` + live + `
+			RETURNING ` + strings.Join(requestColumns, ", ")
+		args = append(args, requestID, current.Version, q.now().Unix())
+
+		rows, err := q.db.QueryContext(ctx, q.dialect.rebind(query), args...)
+		if err != nil {
+			return store.NewStoreError(op, store.RequestsTable, requestID, err)
+		}
+		updated, err := scanRequests(rows)
+		if err != nil {
+			return store.NewStoreError(op, store.RequestsTable, requestID, err)
+		}
+		if len(updated) == 0 {
+			continue
+		}
+
+		if to != "" {
+			q.Notify(ctx, current.Status, to, updated[0])
+		}
+		return nil
+	}
+
+	return store.NewStoreError(op, store.RequestsTable, requestID, store.ErrConflict)
+}
+
+// list returns the page of live requests matching where that opts selects,
+// oldest first or, when newest is set, newest first
+func (q *requestQueue) list(ctx context.Context, op string, opts store.PageOptions, newest bool, where string, args ...interface{}) (*store.Page[*store.AsyncRequest], error) {
+	cursor, orderBy, key := afterCursor, "created_at, request_id", requestKey
+	if newest {
+		cursor, orderBy, key = newestCursor, "created_at DESC, request_id", newestRequestKey
+	}
+
+	after, afterArgs, err := cursor(opts, "created_at", "request_id")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + strings.Join(requestColumns, ", ") + ` FROM ` + store.RequestsTable + `
+		WHERE ` + where + ` AND ` + live + after + `
+		ORDER BY ` + orderBy + limitClause(opts.PageSize()+1)
+	args = append(append(args, q.now().Unix()), afterArgs...)
+
+	rows, err := q.db.QueryContext(ctx, q.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, store.NewStoreError(op, store.RequestsTable, "", err)
+	}
+	result, err := scanRequests(rows)
+	if err != nil {
+		return nil, store.NewStoreError(op, store.RequestsTable, "", err)
+	}
+	return toPage(result, opts, key), nil
+}
+
+// requestKey is the cursor key of a request in list order
+func requestKey(req *store.AsyncRequest) store.Cursor {
+	return store.Cursor{At: toNanos(req.CreatedAt), ID: req.RequestID}
+}
+
+// newestRequestKey is the cursor key of a request in newest-first order
+func newestRequestKey(req *store.AsyncRequest) store.Cursor {
+	return store.Cursor{At: -toNanos(req.CreatedAt), ID: req.RequestID}
+}
+
+// limitClause returns a LIMIT clause, or nothing for a limit of zero or less
+func limitClause(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return " LIMIT " + strconv.Itoa(limit)
+}
+
+// requestArgs returns a request's values in requestColumns order
+func requestArgs(req *store.AsyncRequest) ([]interface{}, error) {
+	payload, err := toJSON(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	result, err := toJSON(req.Result)
+	if err != nil {
+		return nil, err
+	}
+	details, err := toJSON(req.ProgressDetails)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := toJSON(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := toJSON(req.CallbackDeliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		req.RequestID, req.ConnectionID, req.Status, toNanos(req.CreatedAt), req.Action, payload,
+		toNullNanos(req.ProcessingStarted), toNullNanos(req.ProcessingEnded), result, req.Error, req.Progress,
+		req.ProgressMessage, details, req.RetryCount, req.MaxRetries,
+		toNanos(req.RetryAfter), req.UserID, req.TenantID, permissions, req.TTL, req.Version,
+		req.CallbackURL, req.CallbackSecret, deliveries,
+	}, nil
+}
+
+// scanRequests reads and closes rows selected with requestColumns
+func scanRequests(rows *sql.Rows) ([]*store.AsyncRequest, error) {
+	defer rows.Close()
+
+	result := make([]*store.AsyncRequest, 0)
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+	return result, rows.Err()
+}
+
+// scanRequest reads a row selected with requestColumns
+func scanRequest(row scanner) (*store.AsyncRequest, error) {
+	var (
+		req                                               store.AsyncRequest
+		createdAt, retryAfter                             int64
+		processingStarted, processingEnded                sql.NullInt64
+		payload, result, details, permissions, deliveries sql.NullString
+	)
+	err := row.Scan(&req.RequestID, &req.ConnectionID, &req.Status, &createdAt, &req.Action, &payload,
+		&processingStarted, &processingEnded, &result, &req.Error, &req.Progress,
+		&req.ProgressMessage, &details, &req.RetryCount, &req.MaxRetries,
+		&retryAfter, &req.UserID, &req.TenantID, &permissions, &req.TTL, &req.Version,
+		&req.CallbackURL, &req.CallbackSecret, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	req.CreatedAt = fromNanos(createdAt)
+	req.RetryAfter = fromNanos(retryAfter)
+	req.ProcessingStarted = fromNullNanos(processingStarted)
+	req.ProcessingEnded = fromNullNanos(processingEnded)
+	for _, column := range []struct {
+		value sql.NullString
+		dest  interface{}
+	}{
+		{payload, &req.Payload},
+		{result, &req.Result},
+		{details, &req.ProgressDetails},
+		{permissions, &req.Permissions},
+		{deliveries, &req.CallbackDeliveries},
+	} {
+		if err := fromJSON(column.value, column.dest); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
+
+// validateRequest validates a request before saving
+func validateRequest(req *store.AsyncRequest) error {
+	if req == nil {
+		return store.NewValidationError("request", "cannot be nil")
+	}
+	if req.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+	if req.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if req.Action == "" {
+		return store.NewValidationError("Action", "cannot be empty")
+	}
+	if req.UserID == "" {
+		return store.NewValidationError("UserID", "cannot be empty")
+	}
+	if req.TenantID == "" {
+		return store.NewValidationError("TenantID", "cannot be empty")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/storetest"
+)
+
+func TestRequestQueue_Conformance(t *testing.T) {
+	storetest.RunRequestQueueTests(t, func(t *testing.T) store.RequestQueue {
+		return NewRequestQueue(openTestDB(t), SQLite)
+	})
+}
+
+func newTestRequest(id string, createdAt time.Time) *store.AsyncRequest {
+	return &store.AsyncRequest{
+		RequestID:    id,
+		ConnectionID: "conn-1",
+		Action:       "generate_report",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		CreatedAt:    createdAt,
+	}
+}
+
+func TestRequestQueue_DequeueOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewRequestQueue(openTestDB(t), SQLite)
+
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-3", now)))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", now.Add(-2*time.Minute))))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-2", now.Add(-time.Minute))))
+
+	dequeued, err := q.Dequeue(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, dequeued, 2)
+	assert.Equal(t, "req-1", dequeued[0].RequestID)
+	assert.Equal(t, "req-2", dequeued[1].RequestID)
+	for _, req := range dequeued {
+		assert.Equal(t, store.StatusProcessing, req.Status)
+		assert.NotNil(t, req.ProcessingStarted)
+	}
+
+	pending, err := q.GetByStatus(ctx, store.StatusPending, store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, pending.Items, 1)
+	assert.Equal(t, "req-3", pending.Items[0].RequestID)
+}
+
+func TestRequestQueue_DequeueConcurrent(t *testing.T) {
+	ctx := context.Background()
+	q := NewRequestQueue(openTestDB(t), SQLite)
+
+	const total = 40
+	for i := 0; i < total; i++ {
+		require.NoError(t, q.Enqueue(ctx, newTestRequest(fmt.Sprintf("req-%02d", i), time.Time{})))
+	}
+
+	// Every request is handed to exactly one worker
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[string]int)
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				batch, err := q.Dequeue(ctx, 3)
+				if !assert.NoError(t, err) || len(batch) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, req := range batch {
+					seen[req.RequestID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, total)
+	for id, n := range seen {
+		assert.Equal(t, 1, n, id)
+	}
+}
+
+func TestRequestQueue_CompleteAndFail(t *testing.T) {
+	ctx := context.Background()
+	q := NewRequestQueue(openTestDB(t), SQLite)
+
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", time.Time{})))
+	require.NoError(t, q.Enqueue(ctx, newTestRequest("req-2", time.Time{})))
+
+	require.NoError(t, q.UpdateStatus(ctx, "req-1", store.StatusProcessing, ""))
+	require.NoError(t, q.CompleteRequest(ctx, "req-1", map[string]interface{}{"rows": 10}))
+	completed, err := q.Get(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusCompleted, completed.Status)
+	assert.Equal(t, float64(10), completed.Result["rows"])
+	assert.Equal(t, float64(100), completed.Progress)
+	assert.NotNil(t, completed.ProcessingEnded)
+
+	require.NoError(t, q.FailRequest(ctx, "req-2", "timed out"))
+	failed, err := q.Get(ctx, "req-2")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusFailed, failed.Status)
+	assert.Equal(t, "timed out", failed.Error)
+	assert.NotNil(t, failed.ProcessingEnded)
+}
+
+func TestRequestQueue_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewRequestQueue(openTestDB(t), SQLite).(*requestQueue)
+	q.now = func() time.Time { return now }
+
+	req := newTestRequest("req-1", now)
+	req.TTL = now.Add(time.Hour).Unix()
+	require.NoError(t, q.Enqueue(ctx, req))
+
+	q.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	_, err := q.Get(ctx, "req-1")
+	assert.True(t, store.IsNotFound(err))
+
+	count, err := q.CountByTenant(ctx, "tenant-1", "", store.StatusPending)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	dequeued, err := q.Dequeue(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, dequeued)
+
+	// An expired request no longer blocks its ID
+	assert.NoError(t, q.Enqueue(ctx, newTestRequest("req-1", time.Time{})))
+}
+
+func TestRequestQueue_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	q := NewRequestQueue(openTestDB(t), SQLite)
+
+	started := time.Now().Add(-time.Minute)
+	req := newTestRequest("req-1", time.Now())
+	req.Status = store.StatusRetrying
+	req.Payload = map[string]interface{}{"report": "sales", "filters": []interface{}{"q1"}}
+	req.ProcessingStarted = &started
+	req.RetryCount = 2
+	req.MaxRetries = 3
+	req.RetryAfter = time.Now().Add(time.Minute)
+	req.Permissions = []string{"read", "write"}
+	require.NoError(t, q.Enqueue(ctx, req))
+
+	got, err := q.Get(ctx, "req-1")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusRetrying, got.Status)
+	assert.Equal(t, req.Payload, got.Payload)
+	require.NotNil(t, got.ProcessingStarted)
+	assert.True(t, started.Equal(*got.ProcessingStarted))
+	assert.Nil(t, got.ProcessingEnded)
+	assert.Nil(t, got.Result)
+	assert.Equal(t, 2, got.RetryCount)
+	assert.Equal(t, 3, got.MaxRetries)
+	assert.True(t, req.RetryAfter.Equal(got.RetryAfter))
+	assert.Equal(t, []string{"read", "write"}, got.Permissions)
+	assert.Equal(t, req.TTL, got.TTL)
+}
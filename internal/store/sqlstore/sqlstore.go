@@ -0,0 +1,208 @@
+// Package sqlstore implements the store interfaces on database/sql, for
+// deployments that run outside AWS.
+//
+// PostgreSQL and SQLite are supported. The caller opens the *sql.DB with a
+// driver of their choice (for example pgx's stdlib driver or mattn/go-sqlite3)
+// and runs Migrate before creating the stores:
+//
+//	db, err := sql.Open("pgx", dsn)
+//	...
+//	if err := sqlstore.Migrate(ctx, db, sqlstore.Postgres); err != nil {
+//		...
+//	}
+//	connStore := sqlstore.NewConnectionStore(db, sqlstore.Postgres)
+//
+// The stores behave like their DynamORM counterparts: expired TTLs hide
+// records and missing records are reported as store.ErrNotFound. Expired rows
+// stay in the tables until a Cleaner deletes them, in place of DynamoDB TTL.
+//
+// SQLite allows one writer at a time, so open SQLite databases with a busy
+// timeout (for example "file:streamer.db?_busy_timeout=5000") when they are
+// shared by several goroutines or processes.
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Dialect selects the SQL flavour of the database
+type Dialect string
+
+const (
+	// Postgres is PostgreSQL 9.5 or later
+	Postgres Dialect = "postgres"
+
+	// SQLite is SQLite 3.35 or later
+	SQLite Dialect = "sqlite"
+)
+
+// rebind rewrites the ? placeholders in a query for the dialect
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// skipLocked returns the locking clause that lets concurrent dequeuers skip
+// each other's rows. SQLite serializes writes, so it needs none.
+func (d Dialect) skipLocked() string {
+	if d == Postgres {
+		return " FOR UPDATE SKIP LOCKED"
+	}
+	return ""
+}
+
+// validate reports whether the dialect is supported
+func (d Dialect) validate() error {
+	switch d {
+	case Postgres, SQLite:
+		return nil
+	default:
+		return fmt.Errorf("unsupported SQL dialect %q", d)
+	}
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// live is the condition that hides rows whose TTL (Unix seconds) has passed.
+// It takes the current Unix time as its argument.
+const live = "(ttl = 0 OR ttl > ?)"
+
+// afterCursor returns the condition, and its arguments, that starts a list
+// ordered by timeColumn then idColumn after the page that returned
+// opts.NextToken. It returns no condition for the first page.
+func afterCursor(opts store.PageOptions, timeColumn, idColumn string) (string, []interface{}, error) {
+	after, err := store.ParseCursor(opts.NextToken)
+	if err != nil || after == nil {
+		return "", nil, err
+	}
+	return ` AND (` + timeColumn + ` > ? OR (` + timeColumn + ` = ? AND ` + idColumn + ` > ?))`,
+		[]interface{}{after.At, after.At, after.ID}, nil
+}
+
+// newestCursor is afterCursor for lists ordered newest first by timeColumn,
+// then by idColumn. Their cursors hold the negated time.
+func newestCursor(opts store.PageOptions, timeColumn, idColumn string) (string, []interface{}, error) {
+	after, err := store.ParseCursor(opts.NextToken)
+	if err != nil || after == nil {
+		return "", nil, err
+	}
+	return ` AND (` + timeColumn + ` < ? OR (` + timeColumn + ` = ? AND ` + idColumn + ` > ?))`,
+		[]interface{}{-after.At, -after.At, after.ID}, nil
+}
+
+// toPage builds a page from rows queried with a limit of one more than the
+// page size, so that a row past the page means another page follows
+func toPage[T any](items []T, opts store.PageOptions, key func(T) store.Cursor) *store.Page[T] {
+	page := &store.Page[T]{Items: items}
+	if len(items) > opts.PageSize() {
+		page.Items = items[:opts.PageSize()]
+		page.NextToken = key(page.Items[len(page.Items)-1]).Token()
+	}
+	return page
+}
+
+// placeholders returns n comma-separated placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// upsertSet returns the ON CONFLICT assignments that overwrite every column
+func upsertSet(columns []string) string {
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		set[i] = column + " = excluded." + column
+	}
+	return strings.Join(set, ", ")
+}
+
+// toNanos converts a time to Unix nanoseconds; the zero time is stored as 0
+func toNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromNanos converts Unix nanoseconds written by toNanos back to a time
+func fromNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// toNullNanos converts an optional time to nullable Unix nanoseconds
+func toNullNanos(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// fromNullNanos converts nullable Unix nanoseconds back to an optional time
+func fromNullNanos(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64)
+	return &t
+}
+
+// toJSON encodes a map or slice column. Nil maps and slices are stored as NULL.
+func toJSON(v interface{}) (sql.NullString, error) {
+	switch v := v.(type) {
+	case map[string]string:
+		if v == nil {
+			return sql.NullString{}, nil
+		}
+	case map[string]interface{}:
+		if v == nil {
+			return sql.NullString{}, nil
+		}
+	case []string:
+		if v == nil {
+			return sql.NullString{}, nil
+		}
+	case []store.CallbackDelivery:
+		if v == nil {
+			return sql.NullString{}, nil
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// fromJSON decodes a column written by toJSON into v; NULL leaves v unchanged
+func fromJSON(s sql.NullString, v interface{}) error {
+	if !s.Valid {
+		return nil
+	}
+	return json.Unmarshal([]byte(s.String), v)
+}
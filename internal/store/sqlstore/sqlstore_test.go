@@ -0,0 +1,84 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB opens a migrated SQLite database in a temporary directory. A
+// file is used rather than :memory: so every pooled connection shares it.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "streamer.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, Migrate(context.Background(), db, SQLite))
+	return db
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"sqlite unchanged", SQLite, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"postgres numbered", Postgres, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"postgres no placeholders", Postgres, "SELECT 1", "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dialect.rebind(tt.query))
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.statements)
+	}
+
+	// Running again is a no-op
+	require.NoError(t, Migrate(ctx, db, SQLite))
+
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+MigrationsTable).Scan(&count))
+	assert.Equal(t, len(migrations), count)
+
+	for _, table := range []string{"streamer_connections", "streamer_requests", "streamer_subscriptions"} {
+		_, err := db.ExecContext(ctx, "SELECT COUNT(*) FROM "+table)
+		assert.NoError(t, err, table)
+	}
+
+	t.Run("unsupported dialect", func(t *testing.T) {
+		assert.Error(t, Migrate(ctx, db, Dialect("oracle")))
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment; with a semicolon
+CREATE TABLE a (id TEXT);
+
+CREATE INDEX a_idx ON a (id);
+`
+	assert.Equal(t, []string{"CREATE TABLE a (id TEXT)", "CREATE INDEX a_idx ON a (id)"}, splitStatements(script))
+}
@@ -0,0 +1,178 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// subscriptionColumns are the streamer_subscriptions columns, in scan order
+var subscriptionColumns = []string{
+	"subscription_id", "connection_id", "request_id", "event_types", "created_at", "ttl",
+}
+
+// subscriptionStore implements SubscriptionStore on database/sql
+type subscriptionStore struct {
+	db      *sql.DB
+	dialect Dialect
+	now     func() time.Time
+}
+
+// NewSubscriptionStore creates a new SQL subscription store
+func NewSubscriptionStore(db *sql.DB, dialect Dialect) store.SubscriptionStore {
+	return &subscriptionStore{
+		db:      db,
+		dialect: dialect,
+		now:     time.Now,
+	}
+}
+
+// Subscribe creates a subscription for progress updates. A subscription whose
+// TTL has passed but that has not been cleaned up yet is replaced.
+func (s *subscriptionStore) Subscribe(ctx context.Context, sub *store.Subscription) error {
+	if sub == nil {
+		return store.NewValidationError("subscription", "cannot be nil")
+	}
+	if sub.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+	if sub.RequestID == "" {
+		return store.NewValidationError("RequestID", "cannot be empty")
+	}
+
+	// The ID is always derived from the connection and request
+	now := s.now()
+	sub.SubscriptionID = subscriptionID(sub.ConnectionID, sub.RequestID)
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = now
+	}
+
+	eventTypes, err := toJSON(sub.EventTypes)
+	if err != nil {
+		return store.NewStoreError("Subscribe", store.SubscriptionsTable, sub.SubscriptionID, err)
+	}
+
+	query := `INSERT INTO ` + store.SubscriptionsTable + ` (` + strings.Join(subscriptionColumns, ", ") + `)
+		VALUES (` + placeholders(len(subscriptionColumns)) + `)
+		ON CONFLICT (subscription_id) DO UPDATE SET ` + upsertSet(subscriptionColumns[1:]) + `
+		WHERE ` + store.SubscriptionsTable + `.ttl <> 0 AND ` + store.SubscriptionsTable + `.ttl <= ?`
+
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(query),
+		sub.SubscriptionID, sub.ConnectionID, sub.RequestID, eventTypes, toNanos(sub.CreatedAt), sub.TTL, now.Unix())
+	if err != nil {
+		return store.NewStoreError("Subscribe", store.SubscriptionsTable, sub.SubscriptionID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return store.NewStoreError("Subscribe", store.SubscriptionsTable, sub.SubscriptionID, err)
+	} else if n == 0 {
+		return store.NewStoreError("Subscribe", store.SubscriptionsTable, sub.SubscriptionID, store.ErrAlreadyExists)
+	}
+	return nil
+}
+
+// Unsubscribe removes a subscription
+func (s *subscriptionStore) Unsubscribe(ctx context.Context, connectionID, requestID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+	if requestID == "" {
+		return store.NewValidationError("requestID", "cannot be empty")
+	}
+
+	id := subscriptionID(connectionID, requestID)
+	query := `DELETE FROM ` + store.SubscriptionsTable + ` WHERE subscription_id = ? AND ` + live
+
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(query), id, s.now().Unix())
+	if err != nil {
+		return store.NewStoreError("Unsubscribe", store.SubscriptionsTable, id, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return store.NewStoreError("Unsubscribe", store.SubscriptionsTable, id, err)
+	} else if n == 0 {
+		return store.NewStoreError("Unsubscribe", store.SubscriptionsTable, id, store.ErrNotFound)
+	}
+	return nil
+}
+
+// GetByConnection returns a page of a connection's subscriptions
+func (s *subscriptionStore) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.Subscription], error) {
+	if connectionID == "" {
+		return nil, store.NewValidationError("connectionID", "cannot be empty")
+	}
+	return s.list(ctx, "GetByConnection", opts, "connection_id = ?", connectionID)
+}
+
+// GetByRequest returns a page of a request's subscriptions
+func (s *subscriptionStore) GetByRequest(ctx context.Context, requestID string, opts store.PageOptions) (*store.Page[*store.Subscription], error) {
+	if requestID == "" {
+		return nil, store.NewValidationError("requestID", "cannot be empty")
+	}
+	return s.list(ctx, "GetByRequest", opts, "request_id = ?", requestID)
+}
+
+// DeleteByConnection removes all subscriptions for a connection
+func (s *subscriptionStore) DeleteByConnection(ctx context.Context, connectionID string) error {
+	if connectionID == "" {
+		return store.NewValidationError("connectionID", "cannot be empty")
+	}
+
+	query := `DELETE FROM ` + store.SubscriptionsTable + ` WHERE connection_id = ?`
+	if _, err := s.db.ExecContext(ctx, s.dialect.rebind(query), connectionID); err != nil {
+		return store.NewStoreError("DeleteByConnection", store.SubscriptionsTable, connectionID, err)
+	}
+	return nil
+}
+
+// list returns the page of live subscriptions matching where that opts
+// selects, oldest first
+func (s *subscriptionStore) list(ctx context.Context, op string, opts store.PageOptions, where string, args ...interface{}) (*store.Page[*store.Subscription], error) {
+	after, afterArgs, err := afterCursor(opts, "created_at", "subscription_id")
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + strings.Join(subscriptionColumns, ", ") + ` FROM ` + store.SubscriptionsTable + `
+		WHERE ` + where + ` AND ` + live + after + `
+		ORDER BY created_at, subscription_id` + limitClause(opts.PageSize()+1)
+	args = append(append(args, s.now().Unix()), afterArgs...)
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, store.NewStoreError(op, store.SubscriptionsTable, "", err)
+	}
+	defer rows.Close()
+
+	result := make([]*store.Subscription, 0)
+	for rows.Next() {
+		var (
+			sub        store.Subscription
+			createdAt  int64
+			eventTypes sql.NullString
+		)
+		if err := rows.Scan(&sub.SubscriptionID, &sub.ConnectionID, &sub.RequestID, &eventTypes, &createdAt, &sub.TTL); err != nil {
+			return nil, store.NewStoreError(op, store.SubscriptionsTable, "", err)
+		}
+		sub.CreatedAt = fromNanos(createdAt)
+		if err := fromJSON(eventTypes, &sub.EventTypes); err != nil {
+			return nil, store.NewStoreError(op, store.SubscriptionsTable, sub.SubscriptionID, err)
+		}
+		result = append(result, &sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, store.NewStoreError(op, store.SubscriptionsTable, "", err)
+	}
+	return toPage(result, opts, subscriptionKey), nil
+}
+
+// subscriptionKey is the cursor key of a subscription in list order
+func subscriptionKey(sub *store.Subscription) store.Cursor {
+	return store.Cursor{At: toNanos(sub.CreatedAt), ID: sub.SubscriptionID}
+}
+
+// subscriptionID returns the composite subscription ID, matching the DynamORM model
+func subscriptionID(connectionID, requestID string) string {
+	return connectionID + "#" + requestID
+}
@@ -0,0 +1,131 @@
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// RunConcurrencyStoreTests runs the ConcurrencyStore conformance tests.
+// newStore is called once per test.
+func RunConcurrencyStoreTests(t *testing.T, newStore func(t *testing.T) store.ConcurrencyStore) {
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+
+	t.Run("AcquireUpToLimit", func(t *testing.T) {
+		s := newStore(t)
+		key := uniqueID("tenant") + "#*"
+
+		leases := make(map[string]bool)
+		for i := 0; i < 2; i++ {
+			lease, err := s.Acquire(ctx, key, 2, later)
+			require.NoError(t, err)
+			assert.NotEmpty(t, lease, "slot %d should be acquired", i+1)
+			leases[lease] = true
+		}
+		assert.Len(t, leases, 2, "leases should be distinct")
+
+		lease, err := s.Acquire(ctx, key, 2, later)
+		require.NoError(t, err)
+		assert.Empty(t, lease, "a full counter should refuse")
+
+		// Counters are independent
+		lease, err = s.Acquire(ctx, uniqueID("tenant")+"#*", 2, later)
+		require.NoError(t, err)
+		assert.NotEmpty(t, lease)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		s := newStore(t)
+		key := uniqueID("tenant") + "#*"
+
+		lease, err := s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		require.NotEmpty(t, lease)
+
+		require.NoError(t, s.Release(ctx, key, lease))
+		lease, err = s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.NotEmpty(t, lease, "a released slot should be acquired again")
+
+		// Releasing a lease twice, or one never taken, frees nothing more
+		other, err := s.Acquire(ctx, uniqueID("tenant")+"#*", 1, later)
+		require.NoError(t, err)
+		require.NoError(t, s.Release(ctx, key, other))
+		require.NoError(t, s.Release(ctx, uniqueID("tenant")+"#*", lease))
+		refused, err := s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.Empty(t, refused)
+
+		require.NoError(t, s.Release(ctx, key, lease))
+		require.NoError(t, s.Release(ctx, key, lease))
+		lease, err = s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.NotEmpty(t, lease)
+		refused, err = s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.Empty(t, refused)
+	})
+
+	t.Run("ExpiredLeaseFreesSlot", func(t *testing.T) {
+		s := newStore(t)
+		key := uniqueID("tenant") + "#*"
+
+		// A holder that never releases its lease
+		lease, err := s.Acquire(ctx, key, 1, time.Now().Add(-time.Second))
+		require.NoError(t, err)
+		require.NotEmpty(t, lease)
+
+		next, err := s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.NotEmpty(t, next, "an expired lease should not hold the slot")
+
+		// The late release of the expired lease does not free the new one
+		require.NoError(t, s.Release(ctx, key, lease))
+		refused, err := s.Acquire(ctx, key, 1, later)
+		require.NoError(t, err)
+		assert.Empty(t, refused)
+	})
+
+	t.Run("ConcurrentAcquire", func(t *testing.T) {
+		s := newStore(t)
+		key := uniqueID("tenant") + "#*"
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			acquired int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				lease, err := s.Acquire(ctx, key, 3, later)
+				assert.NoError(t, err)
+				if lease != "" {
+					mu.Lock()
+					acquired++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 3, acquired)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		s := newStore(t)
+		_, err := s.Acquire(ctx, "", 1, later)
+		assertValidationError(t, err)
+		_, err = s.Acquire(ctx, uniqueID("tenant"), 0, later)
+		assertValidationError(t, err)
+		assertValidationError(t, s.Release(ctx, "", "lease"))
+		assertValidationError(t, s.Release(ctx, uniqueID("tenant"), ""))
+	})
+}
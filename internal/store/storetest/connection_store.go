@@ -0,0 +1,241 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// RunConnectionStoreTests runs the ConnectionStore conformance tests.
+// newStore is called once per test.
+func RunConnectionStoreTests(t *testing.T, newStore func(t *testing.T) store.ConnectionStore) {
+	ctx := context.Background()
+
+	newConnection := func(userID, tenantID string) *store.Connection {
+		now := time.Now()
+		return &store.Connection{
+			ConnectionID: uniqueID("conn"),
+			UserID:       userID,
+			TenantID:     tenantID,
+			Endpoint:     "wss://example.com",
+			ConnectedAt:  now,
+			LastPing:     now,
+			Metadata:     map[string]string{"codec": "json"},
+		}
+	}
+
+	connectionID := func(conn *store.Connection) string { return conn.ConnectionID }
+
+	// listAll returns the IDs of every connection a list method returns
+	listAll := func(t *testing.T, list func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error)) []string {
+		return collectIDs(t, 0, list, connectionID)
+	}
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		s := newStore(t)
+		conn := newConnection(uniqueID("user"), uniqueID("tenant"))
+		require.NoError(t, s.Save(ctx, conn))
+		assert.NotZero(t, conn.TTL, "Save should default the TTL")
+
+		got, err := s.Get(ctx, conn.ConnectionID)
+		require.NoError(t, err)
+		assert.Equal(t, conn.ConnectionID, got.ConnectionID)
+		assert.Equal(t, conn.UserID, got.UserID)
+		assert.Equal(t, conn.TenantID, got.TenantID)
+		assert.Equal(t, conn.Endpoint, got.Endpoint)
+		assert.Equal(t, conn.Metadata, got.Metadata)
+		assert.Equal(t, conn.TTL, got.TTL)
+		assert.WithinDuration(t, conn.ConnectedAt, got.ConnectedAt, time.Second)
+		assert.WithinDuration(t, conn.LastPing, got.LastPing, time.Second)
+
+		// Changing the returned copy does not change the stored connection
+		got.Metadata["codec"] = "msgpack"
+		again, err := s.Get(ctx, conn.ConnectionID)
+		require.NoError(t, err)
+		assert.Equal(t, "json", again.Metadata["codec"])
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		_, err := newStore(t).Get(ctx, uniqueID("missing"))
+		assertNotFound(t, err)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		s := newStore(t)
+		valid := newConnection("user", "tenant")
+
+		for name, conn := range map[string]*store.Connection{
+			"nil":              nil,
+			"missing id":       {UserID: valid.UserID, TenantID: valid.TenantID, Endpoint: valid.Endpoint},
+			"missing user":     {ConnectionID: valid.ConnectionID, TenantID: valid.TenantID, Endpoint: valid.Endpoint},
+			"missing tenant":   {ConnectionID: valid.ConnectionID, UserID: valid.UserID, Endpoint: valid.Endpoint},
+			"missing endpoint": {ConnectionID: valid.ConnectionID, UserID: valid.UserID, TenantID: valid.TenantID},
+		} {
+			t.Run(name, func(t *testing.T) {
+				assertValidationError(t, s.Save(ctx, conn))
+			})
+		}
+
+		_, err := s.Get(ctx, "")
+		assertValidationError(t, err)
+		assertValidationError(t, s.Delete(ctx, ""))
+		assertValidationError(t, s.UpdateLastPing(ctx, ""))
+		_, err = s.ListByUser(ctx, "", store.PageOptions{})
+		assertValidationError(t, err)
+		_, err = s.ListByTenant(ctx, "", store.PageOptions{})
+		assertValidationError(t, err)
+		_, err = s.ListByUser(ctx, "user", store.PageOptions{NextToken: "not a token"})
+		assertValidationError(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStore(t)
+		conn := newConnection(uniqueID("user"), uniqueID("tenant"))
+		require.NoError(t, s.Save(ctx, conn))
+
+		require.NoError(t, s.Delete(ctx, conn.ConnectionID))
+		_, err := s.Get(ctx, conn.ConnectionID)
+		assertNotFound(t, err)
+	})
+
+	t.Run("ListByUserAndTenant", func(t *testing.T) {
+		s := newStore(t)
+		tenantID := uniqueID("tenant")
+		userID := uniqueID("user")
+
+		first := newConnection(userID, tenantID)
+		second := newConnection(userID, tenantID)
+		other := newConnection(uniqueID("user"), tenantID)
+		elsewhere := newConnection(uniqueID("user"), uniqueID("tenant"))
+		for _, conn := range []*store.Connection{first, second, other, elsewhere} {
+			require.NoError(t, s.Save(ctx, conn))
+		}
+
+		byUser, err := s.ListByUser(ctx, userID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{first.ConnectionID, second.ConnectionID}, listIDs(byUser.Items, connectionID))
+		assert.Empty(t, byUser.NextToken)
+
+		byTenant, err := s.ListByTenant(ctx, tenantID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{first.ConnectionID, second.ConnectionID, other.ConnectionID}, listIDs(byTenant.Items, connectionID))
+
+		none, err := s.ListByUser(ctx, uniqueID("user"), store.PageOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, none.Items)
+		assert.Empty(t, none.NextToken)
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		s := newStore(t)
+		tenantID := uniqueID("tenant")
+
+		var ids []string
+		for i := 0; i < 5; i++ {
+			conn := newConnection(uniqueID("user"), tenantID)
+			require.NoError(t, s.Save(ctx, conn))
+			ids = append(ids, conn.ConnectionID)
+		}
+
+		listByTenant := func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+			return s.ListByTenant(ctx, tenantID, opts)
+		}
+
+		first, err := listByTenant(ctx, store.PageOptions{Limit: 2})
+		require.NoError(t, err)
+		assert.Len(t, first.Items, 2)
+		assert.NotEmpty(t, first.NextToken)
+
+		assert.ElementsMatch(t, ids, collectIDs(t, 2, listByTenant, connectionID))
+		assert.ElementsMatch(t, ids, collectIDs(t, 5, listByTenant, connectionID))
+
+		// A page continues after its token even once earlier items are deleted
+		require.NoError(t, s.Delete(ctx, first.Items[0].ConnectionID))
+		rest, err := listByTenant(ctx, store.PageOptions{Limit: 10, NextToken: first.NextToken})
+		require.NoError(t, err)
+		assert.Len(t, rest.Items, 3)
+		assert.NotContains(t, listIDs(rest.Items, connectionID), first.Items[1].ConnectionID)
+	})
+
+	t.Run("UpdateLastPing", func(t *testing.T) {
+		s := newStore(t)
+		conn := newConnection(uniqueID("user"), uniqueID("tenant"))
+		conn.LastPing = time.Now().Add(-time.Hour)
+		require.NoError(t, s.Save(ctx, conn))
+
+		require.NoError(t, s.UpdateLastPing(ctx, conn.ConnectionID))
+		got, err := s.Get(ctx, conn.ConnectionID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), got.LastPing, time.Minute)
+
+		assertNotFound(t, s.UpdateLastPing(ctx, uniqueID("missing")))
+	})
+
+	t.Run("UpdateClaims", func(t *testing.T) {
+		s := newStore(t)
+		conn := newConnection(uniqueID("user"), uniqueID("tenant"))
+		require.NoError(t, s.Save(ctx, conn))
+
+		expiresAt := time.Now().Add(time.Hour)
+		metadata := map[string]string{"codec": "json", "permissions": `["read"]`}
+		require.NoError(t, s.UpdateClaims(ctx, conn.ConnectionID, metadata, expiresAt))
+
+		got, err := s.Get(ctx, conn.ConnectionID)
+		require.NoError(t, err)
+		assert.Equal(t, metadata, got.Metadata)
+		assert.WithinDuration(t, expiresAt, got.TokenExpiresAt, time.Second)
+
+		assertNotFound(t, s.UpdateClaims(ctx, uniqueID("missing"), metadata, expiresAt))
+	})
+
+	t.Run("ListExpiring", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now()
+
+		expiring := newConnection(uniqueID("user"), uniqueID("tenant"))
+		expiring.TokenExpiresAt = now.Add(time.Minute)
+		later := newConnection(uniqueID("user"), uniqueID("tenant"))
+		later.TokenExpiresAt = now.Add(time.Hour)
+		never := newConnection(uniqueID("user"), uniqueID("tenant"))
+		for _, conn := range []*store.Connection{expiring, later, never} {
+			require.NoError(t, s.Save(ctx, conn))
+		}
+
+		ids := listAll(t, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+			return s.ListExpiring(ctx, now.Add(5*time.Minute), opts)
+		})
+		assert.Contains(t, ids, expiring.ConnectionID)
+		assert.NotContains(t, ids, later.ConnectionID)
+		assert.NotContains(t, ids, never.ConnectionID)
+	})
+
+	t.Run("ListAndDeleteStale", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now()
+
+		stale := newConnection(uniqueID("user"), uniqueID("tenant"))
+		stale.LastPing = now.Add(-time.Hour)
+		active := newConnection(uniqueID("user"), uniqueID("tenant"))
+		for _, conn := range []*store.Connection{stale, active} {
+			require.NoError(t, s.Save(ctx, conn))
+		}
+
+		before := now.Add(-10 * time.Minute)
+		ids := listAll(t, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+			return s.ListStale(ctx, before, opts)
+		})
+		assert.Contains(t, ids, stale.ConnectionID)
+		assert.NotContains(t, ids, active.ConnectionID)
+
+		require.NoError(t, s.DeleteStale(ctx, before))
+		_, err := s.Get(ctx, stale.ConnectionID)
+		assertNotFound(t, err)
+		_, err = s.Get(ctx, active.ConnectionID)
+		assert.NoError(t, err)
+	})
+}
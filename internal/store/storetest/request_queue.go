@@ -0,0 +1,514 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+)
+
+// RunRequestQueueTests runs the RequestQueue conformance tests.
+// newQueue is called once per test.
+func RunRequestQueueTests(t *testing.T, newQueue func(t *testing.T) store.RequestQueue) {
+	ctx := context.Background()
+
+	newRequest := func(connectionID, tenantID, action string) *store.AsyncRequest {
+		return &store.AsyncRequest{
+			RequestID:    uniqueID("req"),
+			ConnectionID: connectionID,
+			Action:       action,
+			UserID:       "user-1",
+			TenantID:     tenantID,
+			Payload:      map[string]interface{}{"report": "sales"},
+			Permissions:  []string{"read"},
+			MaxRetries:   3,
+		}
+	}
+
+	requestID := func(req *store.AsyncRequest) string { return req.RequestID }
+
+	t.Run("EnqueueAndGet", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+		assert.Equal(t, store.StatusPending, req.Status, "Enqueue should default the status")
+		assert.False(t, req.CreatedAt.IsZero(), "Enqueue should default CreatedAt")
+		assert.NotZero(t, req.TTL, "Enqueue should default the TTL")
+
+		got, err := q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, req.RequestID, got.RequestID)
+		assert.Equal(t, req.ConnectionID, got.ConnectionID)
+		assert.Equal(t, req.Action, got.Action)
+		assert.Equal(t, req.UserID, got.UserID)
+		assert.Equal(t, req.TenantID, got.TenantID)
+		assert.Equal(t, store.StatusPending, got.Status)
+		assert.Equal(t, "sales", got.Payload["report"])
+		assert.Equal(t, req.Permissions, got.Permissions)
+		assert.Equal(t, req.MaxRetries, got.MaxRetries)
+		assert.WithinDuration(t, req.CreatedAt, got.CreatedAt, time.Second)
+	})
+
+	t.Run("EnqueueDuplicate", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		duplicate := *req
+		assertAlreadyExists(t, q.Enqueue(ctx, &duplicate))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		q := newQueue(t)
+		missing := uniqueID("missing")
+
+		_, err := q.Get(ctx, missing)
+		assertNotFound(t, err)
+		assertNotFound(t, q.Delete(ctx, missing))
+		assertNotFound(t, q.UpdateStatus(ctx, missing, store.StatusProcessing, ""))
+		assertNotFound(t, q.UpdateProgress(ctx, missing, 50, "", nil))
+		assertNotFound(t, q.CompleteRequest(ctx, missing, nil))
+		assertNotFound(t, q.FailRequest(ctx, missing, "failed"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		q := newQueue(t)
+		valid := newRequest("conn", "tenant", "generate_report")
+
+		for name, req := range map[string]*store.AsyncRequest{
+			"nil":                nil,
+			"missing id":         {ConnectionID: valid.ConnectionID, Action: valid.Action, UserID: valid.UserID, TenantID: valid.TenantID},
+			"missing connection": {RequestID: valid.RequestID, Action: valid.Action, UserID: valid.UserID, TenantID: valid.TenantID},
+			"missing action":     {RequestID: valid.RequestID, ConnectionID: valid.ConnectionID, UserID: valid.UserID, TenantID: valid.TenantID},
+			"missing user":       {RequestID: valid.RequestID, ConnectionID: valid.ConnectionID, Action: valid.Action, TenantID: valid.TenantID},
+			"missing tenant":     {RequestID: valid.RequestID, ConnectionID: valid.ConnectionID, Action: valid.Action, UserID: valid.UserID},
+		} {
+			t.Run(name, func(t *testing.T) {
+				assertValidationError(t, q.Enqueue(ctx, req))
+			})
+		}
+
+		_, err := q.Get(ctx, "")
+		assertValidationError(t, err)
+		assertValidationError(t, q.Delete(ctx, ""))
+		assertValidationError(t, q.UpdateStatus(ctx, "", store.StatusProcessing, ""))
+		_, err = q.GetByConnection(ctx, "", store.PageOptions{})
+		assertValidationError(t, err)
+		_, err = q.GetByStatus(ctx, store.StatusPending, store.PageOptions{NextToken: "not a token"})
+		assertValidationError(t, err)
+		_, err = q.CountByTenant(ctx, "", "", store.StatusPending)
+		assertValidationError(t, err)
+		_, err = q.CountByTenant(ctx, "tenant", "")
+		assertValidationError(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		require.NoError(t, q.Delete(ctx, req.RequestID))
+		_, err := q.Get(ctx, req.RequestID)
+		assertNotFound(t, err)
+	})
+
+	t.Run("StatusTransitions", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		assertStatus := func(t *testing.T, want store.RequestStatus) {
+			t.Helper()
+			got, err := q.Get(ctx, req.RequestID)
+			require.NoError(t, err)
+			assert.Equal(t, want, got.Status)
+
+			// The status index follows the request
+			for _, status := range []store.RequestStatus{store.StatusPending, store.StatusProcessing, store.StatusCompleted} {
+				byStatus := collectIDs(t, 0, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+					return q.GetByStatus(ctx, status, opts)
+				}, requestID)
+				if status == want {
+					assert.Contains(t, byStatus, req.RequestID)
+				} else {
+					assert.NotContains(t, byStatus, req.RequestID)
+				}
+			}
+		}
+
+		assertStatus(t, store.StatusPending)
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, "started"))
+		assertStatus(t, store.StatusProcessing)
+		require.NoError(t, q.CompleteRequest(ctx, req.RequestID, map[string]interface{}{"rows": 10}))
+		assertStatus(t, store.StatusCompleted)
+	})
+
+	t.Run("FailRequest", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		require.NoError(t, q.FailRequest(ctx, req.RequestID, "timed out"))
+		got, err := q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusFailed, got.Status)
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		assertVersion := func(t *testing.T, want int64) {
+			t.Helper()
+			got, err := q.Get(ctx, req.RequestID)
+			require.NoError(t, err)
+			assert.Equal(t, want, got.Version)
+		}
+
+		assertVersion(t, 1)
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, "started"))
+		assertVersion(t, 2)
+		require.NoError(t, q.UpdateProgress(ctx, req.RequestID, 50, "Halfway there", nil))
+		assertVersion(t, 3)
+		require.NoError(t, q.CompleteRequest(ctx, req.RequestID, nil))
+		assertVersion(t, 4)
+	})
+
+	t.Run("Transitions", func(t *testing.T) {
+		q := newQueue(t)
+		completed := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		failed := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, completed))
+		require.NoError(t, q.Enqueue(ctx, failed))
+
+		// A pending request has not been picked up, so it cannot complete
+		err := q.CompleteRequest(ctx, completed.RequestID, nil)
+		assert.True(t, store.IsConflict(err), "expected a conflict, got %v", err)
+
+		require.NoError(t, q.UpdateStatus(ctx, completed.RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.CompleteRequest(ctx, completed.RequestID, nil))
+
+		// A completed request is final
+		err = q.UpdateStatus(ctx, completed.RequestID, store.StatusProcessing, "")
+		assert.True(t, store.IsConflict(err), "expected a conflict, got %v", err)
+		var transitionErr *store.TransitionError
+		require.ErrorAs(t, err, &transitionErr)
+		assert.Equal(t, store.StatusCompleted, transitionErr.From)
+		assert.Equal(t, store.StatusProcessing, transitionErr.To)
+
+		err = q.UpdateProgress(ctx, completed.RequestID, 50, "", nil)
+		assert.True(t, store.IsConflict(err), "expected a conflict, got %v", err)
+		assert.True(t, store.IsConflict(q.FailRequest(ctx, completed.RequestID, "failed")))
+
+		got, err := q.Get(ctx, completed.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusCompleted, got.Status)
+		assert.Empty(t, got.Error)
+
+		// A failed request may be retried
+		require.NoError(t, q.FailRequest(ctx, failed.RequestID, "timed out"))
+		require.NoError(t, q.UpdateStatus(ctx, failed.RequestID, store.StatusRetrying, ""))
+		require.NoError(t, q.UpdateStatus(ctx, failed.RequestID, store.StatusProcessing, ""))
+	})
+
+	t.Run("TransitionHooks", func(t *testing.T) {
+		q := newQueue(t)
+		type move struct {
+			from, to store.RequestStatus
+			version  int64
+		}
+		var moves []move
+		require.NoError(t, lifecycle.Observe(q, lifecycle.HookFunc(func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+			moves = append(moves, move{from, to, req.Version})
+			assert.Equal(t, to, req.Status)
+		})))
+
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, "started"))
+		require.NoError(t, q.UpdateProgress(ctx, req.RequestID, 50, "Halfway there", nil))
+		require.NoError(t, q.FailRequest(ctx, req.RequestID, "timed out"))
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusRetrying, ""))
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.CompleteRequest(ctx, req.RequestID, nil))
+
+		// Refused updates are not reported
+		assert.Error(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, ""))
+
+		assert.Equal(t, []move{
+			{store.StatusPending, store.StatusProcessing, 2},
+			{store.StatusProcessing, store.StatusFailed, 4},
+			{store.StatusFailed, store.StatusRetrying, 5},
+			{store.StatusRetrying, store.StatusProcessing, 6},
+			{store.StatusProcessing, store.StatusCompleted, 7},
+		}, moves)
+	})
+
+	t.Run("UpdateProgress", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		require.NoError(t, q.UpdateProgress(ctx, req.RequestID, 40, "Halfway there", map[string]interface{}{"step": "query"}))
+		got, err := q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, float64(40), got.Progress)
+		assert.Equal(t, "Halfway there", got.ProgressMessage)
+		assert.Equal(t, "query", got.ProgressDetails["step"])
+		assert.Equal(t, store.StatusPending, got.Status)
+	})
+
+	t.Run("CallbackDeliveries", func(t *testing.T) {
+		q := newQueue(t)
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		req.CallbackURL = "https://example.com/hooks"
+		req.CallbackSecret = "s3cret"
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		got, err := q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/hooks", got.CallbackURL)
+		assert.Equal(t, "s3cret", got.CallbackSecret)
+		assert.Empty(t, got.CallbackDeliveries)
+
+		require.NoError(t, q.UpdateStatus(ctx, req.RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.CompleteRequest(ctx, req.RequestID, nil))
+
+		// Deliveries are recorded after the request has finished
+		attemptedAt := time.Now().UTC().Truncate(time.Millisecond)
+		deliveries := []store.CallbackDelivery{
+			{Attempt: 1, Status: store.StatusCompleted, AttemptedAt: attemptedAt, StatusCode: 503},
+			{Attempt: 2, Status: store.StatusCompleted, AttemptedAt: attemptedAt.Add(time.Second), StatusCode: 200, Delivered: true},
+		}
+		for _, delivery := range deliveries {
+			require.NoError(t, q.RecordCallbackDelivery(ctx, req.RequestID, delivery))
+		}
+
+		got, err = q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusCompleted, got.Status)
+		require.Len(t, got.CallbackDeliveries, 2)
+		for i, delivery := range deliveries {
+			assert.Equal(t, delivery.Attempt, got.CallbackDeliveries[i].Attempt)
+			assert.Equal(t, delivery.StatusCode, got.CallbackDeliveries[i].StatusCode)
+			assert.Equal(t, delivery.Delivered, got.CallbackDeliveries[i].Delivered)
+			assert.True(t, delivery.AttemptedAt.Equal(got.CallbackDeliveries[i].AttemptedAt))
+		}
+
+		err = q.RecordCallbackDelivery(ctx, uniqueID("req"), deliveries[0])
+		assert.True(t, store.IsNotFound(err), "expected not found, got %v", err)
+	})
+
+	t.Run("GetByConnection", func(t *testing.T) {
+		q := newQueue(t)
+		connectionID := uniqueID("conn")
+		tenantID := uniqueID("tenant")
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			req := newRequest(connectionID, tenantID, "generate_report")
+			require.NoError(t, q.Enqueue(ctx, req))
+			ids = append(ids, req.RequestID)
+		}
+		require.NoError(t, q.Enqueue(ctx, newRequest(uniqueID("conn"), tenantID, "generate_report")))
+
+		all, err := q.GetByConnection(ctx, connectionID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, ids, listIDs(all.Items, requestID))
+		assert.Empty(t, all.NextToken)
+
+		limited, err := q.GetByConnection(ctx, connectionID, store.PageOptions{Limit: 2})
+		require.NoError(t, err)
+		assert.Len(t, limited.Items, 2)
+		assert.NotEmpty(t, limited.NextToken)
+		for _, req := range limited.Items {
+			assert.Equal(t, connectionID, req.ConnectionID)
+		}
+
+		rest, err := q.GetByConnection(ctx, connectionID, store.PageOptions{Limit: 2, NextToken: limited.NextToken})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, ids, append(listIDs(limited.Items, requestID), listIDs(rest.Items, requestID)...))
+		assert.Empty(t, rest.NextToken)
+
+		assert.ElementsMatch(t, ids, collectIDs(t, 1, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+			return q.GetByConnection(ctx, connectionID, opts)
+		}, requestID))
+	})
+
+	t.Run("GetByUser", func(t *testing.T) {
+		q := newQueue(t)
+		userID := uniqueID("user")
+		tenantID := uniqueID("tenant")
+		connectionID := uniqueID("conn")
+		now := time.Now()
+
+		// Requests from the same user on other connections are included
+		newUserRequest := func(tenantID, action string, age time.Duration) *store.AsyncRequest {
+			req := newRequest(uniqueID("conn"), tenantID, action)
+			req.UserID = userID
+			req.CreatedAt = now.Add(-age)
+			return req
+		}
+		oldest := newUserRequest(tenantID, "generate_report", 3*time.Hour)
+		older := newUserRequest(tenantID, "export_data", 2*time.Hour)
+		newer := newUserRequest(tenantID, "generate_report", time.Hour)
+		newest := newUserRequest(tenantID, "generate_report", time.Minute)
+		otherTenant := newUserRequest(uniqueID("tenant"), "generate_report", 30*time.Minute)
+		otherUser := newRequest(connectionID, tenantID, "generate_report")
+		for _, req := range []*store.AsyncRequest{oldest, older, newer, newest, otherTenant, otherUser} {
+			require.NoError(t, q.Enqueue(ctx, req))
+		}
+		require.NoError(t, q.UpdateStatus(ctx, newer.RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.CompleteRequest(ctx, newer.RequestID, map[string]interface{}{"rows": 10}))
+		require.NoError(t, q.FailRequest(ctx, oldest.RequestID, "timed out"))
+
+		tests := []struct {
+			name   string
+			filter store.RequestFilter
+			want   []*store.AsyncRequest
+		}{
+			{"all tenants", store.RequestFilter{}, []*store.AsyncRequest{newest, otherTenant, newer, older, oldest}},
+			{"tenant", store.RequestFilter{TenantID: tenantID}, []*store.AsyncRequest{newest, newer, older, oldest}},
+			{"statuses", store.RequestFilter{TenantID: tenantID, Statuses: []store.RequestStatus{store.StatusCompleted, store.StatusFailed}}, []*store.AsyncRequest{newer, oldest}},
+			{"action", store.RequestFilter{TenantID: tenantID, Action: "export_data"}, []*store.AsyncRequest{older}},
+			{"created after", store.RequestFilter{TenantID: tenantID, CreatedAfter: now.Add(-90 * time.Minute)}, []*store.AsyncRequest{newest, newer}},
+			{"created before", store.RequestFilter{TenantID: tenantID, CreatedBefore: now.Add(-90 * time.Minute)}, []*store.AsyncRequest{older, oldest}},
+			{"no match", store.RequestFilter{TenantID: tenantID, Action: "missing"}, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				want := listIDs(tt.want, requestID)
+
+				page, err := q.GetByUser(ctx, userID, tt.filter, store.PageOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, want, listIDs(page.Items, requestID), "requests should be newest first")
+				assert.Empty(t, page.NextToken)
+
+				assert.Equal(t, want, collectIDs(t, 1, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+					return q.GetByUser(ctx, userID, tt.filter, opts)
+				}, requestID))
+			})
+		}
+
+		completed, err := q.GetByUser(ctx, userID, store.RequestFilter{Statuses: []store.RequestStatus{store.StatusCompleted}}, store.PageOptions{})
+		require.NoError(t, err)
+		require.Len(t, completed.Items, 1)
+		assert.EqualValues(t, 10, completed.Items[0].Result["rows"])
+
+		_, err = q.GetByUser(ctx, "", store.RequestFilter{}, store.PageOptions{})
+		assertValidationError(t, err)
+	})
+
+	t.Run("CountByTenant", func(t *testing.T) {
+		q := newQueue(t)
+		tenantID := uniqueID("tenant")
+		connectionID := uniqueID("conn")
+
+		reports := []*store.AsyncRequest{
+			newRequest(connectionID, tenantID, "generate_report"),
+			newRequest(connectionID, tenantID, "generate_report"),
+			newRequest(connectionID, tenantID, "generate_report"),
+		}
+		export := newRequest(connectionID, tenantID, "export_data")
+		other := newRequest(connectionID, uniqueID("tenant"), "generate_report")
+		for _, req := range append(reports, export, other) {
+			require.NoError(t, q.Enqueue(ctx, req))
+		}
+		require.NoError(t, q.UpdateStatus(ctx, reports[0].RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.UpdateStatus(ctx, reports[1].RequestID, store.StatusProcessing, ""))
+		require.NoError(t, q.CompleteRequest(ctx, reports[1].RequestID, nil))
+
+		tests := []struct {
+			name     string
+			action   string
+			statuses []store.RequestStatus
+			want     int
+		}{
+			{"pending", "", []store.RequestStatus{store.StatusPending}, 2},
+			{"active", "", []store.RequestStatus{store.StatusPending, store.StatusProcessing}, 3},
+			{"active reports", "generate_report", []store.RequestStatus{store.StatusPending, store.StatusProcessing}, 2},
+			{"completed exports", "export_data", []store.RequestStatus{store.StatusCompleted}, 0},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				count, err := q.CountByTenant(ctx, tenantID, tt.action, tt.statuses...)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, count)
+			})
+		}
+	})
+
+	t.Run("DeferAndResume", func(t *testing.T) {
+		q := newQueue(t)
+		now := time.Now()
+		due := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		later := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		ready := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		for _, req := range []*store.AsyncRequest{due, later, ready} {
+			require.NoError(t, q.Enqueue(ctx, req))
+		}
+
+		require.NoError(t, q.Defer(ctx, due.RequestID, now.Add(-time.Second)))
+		require.NoError(t, q.Defer(ctx, later.RequestID, now.Add(time.Hour)))
+
+		got, err := q.Get(ctx, later.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusPending, got.Status)
+		assert.WithinDuration(t, now.Add(time.Hour), got.RetryAfter, time.Second)
+
+		deferred, err := q.ListDeferred(ctx, now, store.PageOptions{})
+		require.NoError(t, err)
+		ids := listIDs(deferred.Items, requestID)
+		assert.Contains(t, ids, due.RequestID)
+		assert.NotContains(t, ids, later.RequestID, "a request deferred into the future is not due")
+		assert.NotContains(t, ids, ready.RequestID, "a request that was never deferred is not listed")
+
+		require.NoError(t, q.Resume(ctx, due.RequestID))
+		got, err = q.Get(ctx, due.RequestID)
+		require.NoError(t, err)
+		assert.True(t, got.RetryAfter.IsZero())
+		deferred, err = q.ListDeferred(ctx, now, store.PageOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, listIDs(deferred.Items, requestID), due.RequestID)
+
+		// Only pending requests are deferred or resumed
+		require.NoError(t, q.UpdateStatus(ctx, ready.RequestID, store.StatusProcessing, ""))
+		assert.ErrorIs(t, q.Defer(ctx, ready.RequestID, now), store.ErrRequestNotPending)
+		assert.ErrorIs(t, q.Resume(ctx, ready.RequestID), store.ErrRequestNotPending)
+		assertNotFound(t, q.Defer(ctx, uniqueID("req"), now))
+	})
+
+	t.Run("Dequeue", func(t *testing.T) {
+		q := newQueue(t)
+		var claimed []string
+		require.NoError(t, lifecycle.Observe(q, lifecycle.HookFunc(func(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+			if from == store.StatusPending && to == store.StatusProcessing {
+				claimed = append(claimed, req.RequestID)
+			}
+		})))
+		req := newRequest(uniqueID("conn"), uniqueID("tenant"), "generate_report")
+		require.NoError(t, q.Enqueue(ctx, req))
+
+		dequeued, err := q.Dequeue(ctx, 0)
+		require.NoError(t, err)
+		assert.Contains(t, listIDs(dequeued, requestID), req.RequestID)
+		assert.Contains(t, claimed, req.RequestID)
+
+		got, err := q.Get(ctx, req.RequestID)
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusProcessing, got.Status)
+
+		// A request is only handed out once
+		again, err := q.Dequeue(ctx, 0)
+		require.NoError(t, err)
+		assert.NotContains(t, listIDs(again, requestID), req.RequestID)
+	})
+}
@@ -0,0 +1,75 @@
+// Package storetest is a conformance suite for implementations of the store
+// interfaces. Each backend runs the same tests so they stay interchangeable.
+//
+// The tests use unique IDs and only assert on records they create, so a
+// backend may share tables between tests.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// uniqueID returns an ID that no other test uses
+func uniqueID(prefix string) string {
+	return prefix + "-" + uuid.NewString()
+}
+
+// assertValidationError asserts that err is a store.ValidationError
+func assertValidationError(t *testing.T, err error) {
+	t.Helper()
+	var validationErr *store.ValidationError
+	assert.True(t, errors.As(err, &validationErr), "expected validation error, got %v", err)
+}
+
+// assertNotFound asserts that err is store.ErrNotFound
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	assert.True(t, store.IsNotFound(err), "expected not found error, got %v", err)
+}
+
+// assertAlreadyExists asserts that err is store.ErrAlreadyExists
+func assertAlreadyExists(t *testing.T, err error) {
+	t.Helper()
+	assert.True(t, store.IsAlreadyExists(err), "expected already exists error, got %v", err)
+}
+
+// listIDs returns the IDs of items
+func listIDs[T any](items []T, id func(T) string) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = id(item)
+	}
+	return ids
+}
+
+// collectIDs walks every page of a list, limit items at a time, and returns
+// the IDs of the items. It fails the test if a page holds more than limit
+// items or an item appears on two pages.
+func collectIDs[T any](t *testing.T, limit int, fetch store.PageFunc[T], id func(T) string) []string {
+	t.Helper()
+
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	pages := store.NewPaginator(limit, fetch)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background())
+		require.NoError(t, err)
+		if limit > 0 {
+			assert.LessOrEqual(t, len(page.Items), limit)
+		}
+		for _, item := range page.Items {
+			assert.False(t, seen[id(item)], "%s appears on two pages", id(item))
+			seen[id(item)] = true
+			ids = append(ids, id(item))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,125 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// RunSubscriptionStoreTests runs the SubscriptionStore conformance tests.
+// newStore is called once per test.
+func RunSubscriptionStoreTests(t *testing.T, newStore func(t *testing.T) store.SubscriptionStore) {
+	ctx := context.Background()
+
+	subscriptionID := func(sub *store.Subscription) string { return sub.SubscriptionID }
+
+	t.Run("SubscribeAndGet", func(t *testing.T) {
+		s := newStore(t)
+		connectionID := uniqueID("conn")
+		requestID := uniqueID("req")
+
+		sub := &store.Subscription{
+			ConnectionID: connectionID,
+			RequestID:    requestID,
+			EventTypes:   []string{"progress", "complete"},
+		}
+		require.NoError(t, s.Subscribe(ctx, sub))
+		assert.Equal(t, connectionID+"#"+requestID, sub.SubscriptionID)
+		assert.False(t, sub.CreatedAt.IsZero(), "Subscribe should default CreatedAt")
+
+		byConnection, err := s.GetByConnection(ctx, connectionID, store.PageOptions{})
+		require.NoError(t, err)
+		require.Len(t, byConnection.Items, 1)
+		assert.Equal(t, sub.SubscriptionID, byConnection.Items[0].SubscriptionID)
+		assert.Equal(t, requestID, byConnection.Items[0].RequestID)
+		assert.ElementsMatch(t, sub.EventTypes, byConnection.Items[0].EventTypes)
+		assert.Empty(t, byConnection.NextToken)
+
+		byRequest, err := s.GetByRequest(ctx, requestID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{sub.SubscriptionID}, listIDs(byRequest.Items, subscriptionID))
+	})
+
+	t.Run("SubscribeDuplicate", func(t *testing.T) {
+		s := newStore(t)
+		sub := &store.Subscription{ConnectionID: uniqueID("conn"), RequestID: uniqueID("req")}
+		require.NoError(t, s.Subscribe(ctx, sub))
+
+		duplicate := *sub
+		assertAlreadyExists(t, s.Subscribe(ctx, &duplicate))
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		s := newStore(t)
+		sub := &store.Subscription{ConnectionID: uniqueID("conn"), RequestID: uniqueID("req")}
+		require.NoError(t, s.Subscribe(ctx, sub))
+
+		require.NoError(t, s.Unsubscribe(ctx, sub.ConnectionID, sub.RequestID))
+		subs, err := s.GetByRequest(ctx, sub.RequestID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, subs.Items)
+
+		assertNotFound(t, s.Unsubscribe(ctx, sub.ConnectionID, sub.RequestID))
+	})
+
+	t.Run("DeleteByConnection", func(t *testing.T) {
+		s := newStore(t)
+		connectionID := uniqueID("conn")
+		requestID := uniqueID("req")
+
+		for _, sub := range []*store.Subscription{
+			{ConnectionID: connectionID, RequestID: requestID},
+			{ConnectionID: connectionID, RequestID: uniqueID("req")},
+			{ConnectionID: uniqueID("conn"), RequestID: requestID},
+		} {
+			require.NoError(t, s.Subscribe(ctx, sub))
+		}
+
+		require.NoError(t, s.DeleteByConnection(ctx, connectionID))
+
+		byConnection, err := s.GetByConnection(ctx, connectionID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, byConnection.Items)
+
+		// Other connections' subscriptions to the same request remain
+		byRequest, err := s.GetByRequest(ctx, requestID, store.PageOptions{})
+		require.NoError(t, err)
+		assert.Len(t, byRequest.Items, 1)
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		s := newStore(t)
+		requestID := uniqueID("req")
+
+		var ids []string
+		for i := 0; i < 5; i++ {
+			sub := &store.Subscription{ConnectionID: uniqueID("conn"), RequestID: requestID}
+			require.NoError(t, s.Subscribe(ctx, sub))
+			ids = append(ids, sub.SubscriptionID)
+		}
+
+		byRequest := func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Subscription], error) {
+			return s.GetByRequest(ctx, requestID, opts)
+		}
+		assert.ElementsMatch(t, ids, collectIDs(t, 2, byRequest, subscriptionID))
+		assert.ElementsMatch(t, ids, collectIDs(t, 0, byRequest, subscriptionID))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		s := newStore(t)
+
+		assertValidationError(t, s.Subscribe(ctx, nil))
+		assertValidationError(t, s.Subscribe(ctx, &store.Subscription{RequestID: "req"}))
+		assertValidationError(t, s.Subscribe(ctx, &store.Subscription{ConnectionID: "conn"}))
+		assertValidationError(t, s.Unsubscribe(ctx, "", "req"))
+		assertValidationError(t, s.DeleteByConnection(ctx, ""))
+		_, err := s.GetByConnection(ctx, "", store.PageOptions{})
+		assertValidationError(t, err)
+		_, err = s.GetByRequest(ctx, "", store.PageOptions{})
+		assertValidationError(t, err)
+	})
+}
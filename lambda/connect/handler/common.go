@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+// HandlerConfig holds configuration for the handler
+type HandlerConfig struct {
+	TableName      string
+	JWTPublicKey   string
+	JWTIssuer      string
+	AllowedTenants []string
+	LogLevel       string
+
+	// JWKS settings; any one source takes precedence over JWTPublicKey
+	JWKSURL             string
+	JWKSFile            string
+	JWKS                string
+	JWKSRefreshInterval time.Duration
+	JWKSKeyOverlap      time.Duration
+}
+
+// VerifierConfig returns the settings for the shared JWT verifier
+func (c *HandlerConfig) VerifierConfig() shared.VerifierConfig {
+	return shared.VerifierConfig{
+		PublicKey:           c.JWTPublicKey,
+		Issuer:              c.JWTIssuer,
+		JWKSURL:             c.JWKSURL,
+		JWKSFile:            c.JWKSFile,
+		JWKS:                c.JWKS,
+		JWKSRefreshInterval: c.JWKSRefreshInterval,
+		JWKSKeyOverlap:      c.JWKSKeyOverlap,
+	}
+}
+
+// messageFormat is the codec and compression negotiated for a connection
+type messageFormat struct {
+	Codec    string
+	Encoding string
+
+	// Subprotocol is echoed in Sec-WebSocket-Protocol; API Gateway requires
+	// one whenever the client offered any
+	Subprotocol string
+}
+
+// negotiateFormat picks the message codec and compression encoding for a
+// connection from the comma-separated "codec" and "encoding" query parameters
+// and the Sec-WebSocket-Protocol header.
+func negotiateFormat(codecs, encodings, protocols string) messageFormat {
+	offeredProtocols := splitList(protocols)
+	format := messageFormat{
+		Codec:    codec.Negotiate(append(splitList(codecs), offeredProtocols...)),
+		Encoding: connection.NegotiateEncoding(append(splitList(encodings), offeredProtocols...)),
+	}
+
+	if len(offeredProtocols) == 0 {
+		return format
+	}
+
+	// A handshake selects a single subprotocol; prefer the codec's since a
+	// client cannot read any message without it
+	format.Subprotocol = offeredProtocols[0]
+	for _, selected := range []string{format.Codec, format.Encoding} {
+		if selected == "" {
+			continue
+		}
+		for _, protocol := range offeredProtocols {
+			if strings.EqualFold(protocol, "streamer."+selected) {
+				format.Subprotocol = protocol
+				return format
+			}
+		}
+	}
+	return format
+}
+
+// splitList splits a comma-separated header or query value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// jsonStringify converts a value to JSON string
+func jsonStringify(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// wantsAcks reports whether the ack query parameter opts in to reliable delivery
+func wantsAcks(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
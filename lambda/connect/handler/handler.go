@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package handler
 
 import (
 	"context"
@@ -15,11 +12,19 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/pay-theory/streamer/internal/store"
 	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/codec"
+	streamconn "github.com/pay-theory/streamer/pkg/connection"
 )
 
 // JWTVerifierInterface defines the interface for JWT verification
 type JWTVerifierInterface interface {
-	Verify(token string) (*Claims, error)
+	Verify(token string) (*shared.Claims, error)
+}
+
+// DeliveryReplayer reschedules a recipient's unacked messages for a new
+// connection; *connection.DeliveryTracker implements it
+type DeliveryReplayer interface {
+	Replay(ctx context.Context, conn *store.Connection) (int, error)
 }
 
 // HandlerConfig is defined in common.go
@@ -29,13 +34,14 @@ type Handler struct {
 	store       store.ConnectionStore
 	config      *HandlerConfig
 	jwtVerifier JWTVerifierInterface
+	deliveries  DeliveryReplayer
 	logger      *shared.Logger
 	metrics     shared.MetricsPublisher
 }
 
 // NewHandler creates a new connect handler
 func NewHandler(store store.ConnectionStore, config *HandlerConfig, metrics shared.MetricsPublisher) *Handler {
-	verifier, err := NewJWTVerifier(config.JWTPublicKey, config.JWTIssuer)
+	verifier, err := shared.NewVerifier(config.VerifierConfig())
 	if err != nil {
 		log.Fatalf("Failed to create JWT verifier: %v", err)
 	}
@@ -60,6 +66,11 @@ func NewHandlerWithVerifier(store store.ConnectionStore, config *HandlerConfig,
 	}
 }
 
+// SetDeliveryReplayer replays unacked messages to clients that reconnect with acks enabled
+func (h *Handler) SetDeliveryReplayer(deliveries DeliveryReplayer) {
+	h.deliveries = deliveries
+}
+
 // Handle processes the WebSocket $connect event
 func (h *Handler) Handle(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
 	start := time.Now()
@@ -152,6 +163,13 @@ func (h *Handler) Handle(ctx context.Context, event events.APIGatewayWebsocketPr
 		}
 	}
 
+	// Pick a codec and compression encoding from what the client advertised
+	protocols := event.Headers["Sec-WebSocket-Protocol"]
+	if protocols == "" {
+		protocols = event.Headers["sec-websocket-protocol"]
+	}
+	format := negotiateFormat(event.QueryStringParameters["codec"], event.QueryStringParameters["encoding"], protocols)
+
 	// Create connection record
 	connection := &store.Connection{
 		ConnectionID: event.RequestContext.ConnectionID,
@@ -167,6 +185,23 @@ func (h *Handler) Handle(ctx context.Context, event events.APIGatewayWebsocketPr
 		},
 		TTL: time.Now().Add(24 * time.Hour).Unix(),
 	}
+	if format.Codec != "" {
+		connection.Metadata[codec.MetadataKey] = format.Codec
+	}
+	if format.Encoding != "" {
+		connection.Metadata[streamconn.MetadataEncodingKey] = format.Encoding
+	}
+
+	// The router rejects messages once the token expires, and the reaper
+	// closes the connection unless the client refreshes it first
+	if claims.ExpiresAt != nil {
+		connection.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+
+	// Clients that ack terminal messages get them redelivered until they do
+	if wantsAcks(event.QueryStringParameters["ack"]) {
+		connection.Metadata[streamconn.MetadataAckKey] = "true"
+	}
 
 	// Save connection to store
 	ctx3, saveSeg := shared.StartSubsegment(ctx, "SaveConnection", shared.TraceSegment{})
@@ -181,11 +216,30 @@ func (h *Handler) Handle(ctx context.Context, event events.APIGatewayWebsocketPr
 		return internalErrorResponse("Failed to establish connection")
 	}
 
+	// Messages still waiting for an ack go to the new connection on the next
+	// redelivery pass; a failure only delays them until their ack timeout
+	if h.deliveries != nil {
+		if replayed, err := h.deliveries.Replay(ctx, connection); err != nil {
+			h.logger.Warn(ctx, "Failed to replay pending deliveries", map[string]interface{}{
+				"connection_id": connectionID,
+				"error":         err.Error(),
+			})
+		} else if replayed > 0 {
+			h.logger.Info(ctx, "Replaying pending deliveries", map[string]interface{}{
+				"connection_id": connectionID,
+				"deliveries":    replayed,
+			})
+		}
+	}
+
 	// Log successful connection
 	h.logger.Info(ctx, "Connection established", map[string]interface{}{
 		"connection_id": connectionID,
 		"user_id":       connection.UserID,
 		"tenant_id":     connection.TenantID,
+		"codec":         format.Codec,
+		"encoding":      format.Encoding,
+		"ack":           connection.Metadata[streamconn.MetadataAckKey] == "true",
 	})
 
 	// Publish success metrics
@@ -200,12 +254,16 @@ func (h *Handler) Handle(ctx context.Context, event events.APIGatewayWebsocketPr
 		shared.MetricsDimensions{}.Action("connect"))
 
 	// Return success response
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	if format.Subprotocol != "" {
+		headers["Sec-WebSocket-Protocol"] = format.Subprotocol
+	}
 	return events.APIGatewayProxyResponse{
 		StatusCode: 200,
 		Body:       `{"message":"Connected successfully"}`,
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
+		Headers:    headers,
 	}, nil
 }
 
@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package handler
 
 import (
 	"context"
@@ -41,20 +38,20 @@ func (m *mockConnectionStore) Delete(ctx context.Context, connectionID string) e
 	return args.Error(0)
 }
 
-func (m *mockConnectionStore) ListByUser(ctx context.Context, userID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, userID)
+func (m *mockConnectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, userID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
-func (m *mockConnectionStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, tenantID)
+func (m *mockConnectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, tenantID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
 func (m *mockConnectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
@@ -62,6 +59,27 @@ func (m *mockConnectionStore) UpdateLastPing(ctx context.Context, connectionID s
 	return args.Error(0)
 }
 
+func (m *mockConnectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	args := m.Called(ctx, connectionID, metadata, tokenExpiresAt)
+	return args.Error(0)
+}
+
+func (m *mockConnectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
+func (m *mockConnectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
 func (m *mockConnectionStore) DeleteStale(ctx context.Context, before time.Time) error {
 	args := m.Called(ctx, before)
 	return args.Error(0)
@@ -72,12 +90,12 @@ type mockJWTVerifier struct {
 	mock.Mock
 }
 
-func (m *mockJWTVerifier) Verify(token string) (*Claims, error) {
+func (m *mockJWTVerifier) Verify(token string) (*shared.Claims, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*Claims), args.Error(1)
+	return args.Get(0).(*shared.Claims), args.Error(1)
 }
 
 // Mock metrics publisher
@@ -95,6 +113,16 @@ func (m *mockMetricsPublisher) PublishLatency(ctx context.Context, namespace, me
 	return args.Error(0)
 }
 
+// Mock delivery replayer
+type mockDeliveryReplayer struct {
+	mock.Mock
+}
+
+func (m *mockDeliveryReplayer) Replay(ctx context.Context, conn *store.Connection) (int, error) {
+	args := m.Called(ctx, conn)
+	return args.Int(0), args.Error(1)
+}
+
 func TestHandler_Handle_Success(t *testing.T) {
 	// Setup mocks
 	mockStore := new(mockConnectionStore)
@@ -127,7 +155,7 @@ func TestHandler_Handle_Success(t *testing.T) {
 	}
 
 	// Setup mock expectations
-	claims := &Claims{
+	claims := &shared.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "user123",
 		},
@@ -258,7 +286,7 @@ func TestHandler_Handle_TenantNotAllowed(t *testing.T) {
 		Headers: map[string]string{},
 	}
 
-	claims := &Claims{
+	claims := &shared.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "user123",
 		},
@@ -306,7 +334,7 @@ func TestHandler_Handle_StorageError(t *testing.T) {
 		Headers: map[string]string{},
 	}
 
-	claims := &Claims{
+	claims := &shared.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "user123",
 		},
@@ -353,7 +381,7 @@ func TestHandler_Handle_TokenFromHeader(t *testing.T) {
 		},
 	}
 
-	claims := &Claims{
+	claims := &shared.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "user123",
 		},
@@ -377,6 +405,282 @@ func TestHandler_Handle_TokenFromHeader(t *testing.T) {
 	mockMetrics.AssertExpectations(t)
 }
 
+func TestHandler_Handle_NegotiatesFormat(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            map[string]string
+		headers          map[string]string
+		expectedCodec    string
+		expectedEncoding string
+		expectedProtocol string
+	}{
+		{
+			name:             "query parameter",
+			query:            map[string]string{"Authorization": "valid-token", "encoding": "gzip, br"},
+			expectedEncoding: "br",
+		},
+		{
+			name:             "subprotocol is echoed",
+			query:            map[string]string{"Authorization": "valid-token"},
+			headers:          map[string]string{"Sec-WebSocket-Protocol": "streamer.json, streamer.gzip"},
+			expectedEncoding: "gzip",
+			expectedProtocol: "streamer.gzip",
+		},
+		{
+			name:             "lowercase header",
+			query:            map[string]string{"Authorization": "valid-token"},
+			headers:          map[string]string{"sec-websocket-protocol": "streamer.br"},
+			expectedEncoding: "br",
+			expectedProtocol: "streamer.br",
+		},
+		{
+			name:             "codec query parameter",
+			query:            map[string]string{"Authorization": "valid-token", "codec": "cbor", "encoding": "gzip"},
+			expectedCodec:    "cbor",
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "codec subprotocol is preferred",
+			query:            map[string]string{"Authorization": "valid-token"},
+			headers:          map[string]string{"Sec-WebSocket-Protocol": "streamer.br, streamer.msgpack"},
+			expectedCodec:    "msgpack",
+			expectedEncoding: "br",
+			expectedProtocol: "streamer.msgpack",
+		},
+		{
+			name:             "unsupported encodings",
+			query:            map[string]string{"Authorization": "valid-token", "encoding": "zstd"},
+			headers:          map[string]string{"Sec-WebSocket-Protocol": "streamer.json"},
+			expectedEncoding: "",
+			expectedProtocol: "streamer.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(mockConnectionStore)
+			mockMetrics := new(mockMetricsPublisher)
+			mockVerifier := new(mockJWTVerifier)
+
+			config := &HandlerConfig{
+				JWTPublicKey: "test-key",
+				JWTIssuer:    "test-issuer",
+			}
+
+			handler := NewHandlerWithVerifier(mockStore, config, mockMetrics, mockVerifier)
+
+			event := events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "test-connection-123",
+					DomainName:   "api.example.com",
+					Stage:        "prod",
+				},
+				QueryStringParameters: tt.query,
+				Headers:               tt.headers,
+			}
+
+			claims := &shared.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "user123",
+				},
+				TenantID: "tenant456",
+			}
+			mockVerifier.On("Verify", "valid-token").Return(claims, nil)
+			mockStore.On("Save", mock.Anything, mock.MatchedBy(func(conn *store.Connection) bool {
+				return metadataMatches(conn, "codec", tt.expectedCodec) &&
+					metadataMatches(conn, "encoding", tt.expectedEncoding)
+			})).Return(nil)
+			mockMetrics.On("PublishMetric", mock.Anything, "", shared.CommonMetrics.ConnectionEstablished,
+				float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+			mockMetrics.On("PublishLatency", mock.Anything, "", "ProcessingLatency",
+				mock.AnythingOfType("time.Duration"), mock.Anything).Return(nil)
+
+			response, err := handler.Handle(context.Background(), event)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 200, response.StatusCode)
+			assert.Equal(t, tt.expectedProtocol, response.Headers["Sec-WebSocket-Protocol"])
+
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_Handle_AckOptIn(t *testing.T) {
+	tests := []struct {
+		name        string
+		ack         string
+		expectedAck string
+	}{
+		{name: "ack=1", ack: "1", expectedAck: "true"},
+		{name: "ack=true", ack: "TRUE", expectedAck: "true"},
+		{name: "ack=0", ack: "0"},
+		{name: "not requested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(mockConnectionStore)
+			mockMetrics := new(mockMetricsPublisher)
+			mockVerifier := new(mockJWTVerifier)
+
+			handler := NewHandlerWithVerifier(mockStore, &HandlerConfig{JWTPublicKey: "test-key"}, mockMetrics, mockVerifier)
+
+			query := map[string]string{"Authorization": "valid-token"}
+			if tt.ack != "" {
+				query["ack"] = tt.ack
+			}
+			event := events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "test-connection-123",
+					DomainName:   "api.example.com",
+					Stage:        "prod",
+				},
+				QueryStringParameters: query,
+			}
+
+			claims := &shared.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "user123",
+				},
+				TenantID: "tenant456",
+			}
+			mockVerifier.On("Verify", "valid-token").Return(claims, nil)
+			mockStore.On("Save", mock.Anything, mock.MatchedBy(func(conn *store.Connection) bool {
+				return metadataMatches(conn, "ack", tt.expectedAck)
+			})).Return(nil)
+			mockMetrics.On("PublishMetric", mock.Anything, "", shared.CommonMetrics.ConnectionEstablished,
+				float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+			mockMetrics.On("PublishLatency", mock.Anything, "", "ProcessingLatency",
+				mock.AnythingOfType("time.Duration"), mock.Anything).Return(nil)
+
+			response, err := handler.Handle(context.Background(), event)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 200, response.StatusCode)
+
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_Handle_ReplaysDeliveries(t *testing.T) {
+	tests := []struct {
+		name      string
+		replayErr error
+	}{
+		{name: "replayed"},
+		{name: "replay failure does not fail the connect", replayErr: errors.New("table unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(mockConnectionStore)
+			mockMetrics := new(mockMetricsPublisher)
+			mockVerifier := new(mockJWTVerifier)
+			replayer := new(mockDeliveryReplayer)
+
+			handler := NewHandlerWithVerifier(mockStore, &HandlerConfig{JWTPublicKey: "test-key"}, mockMetrics, mockVerifier)
+			handler.SetDeliveryReplayer(replayer)
+
+			event := events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "test-connection-123",
+					DomainName:   "api.example.com",
+					Stage:        "prod",
+				},
+				QueryStringParameters: map[string]string{"Authorization": "valid-token", "ack": "1"},
+			}
+
+			claims := &shared.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "user123",
+				},
+				TenantID: "tenant456",
+			}
+			mockVerifier.On("Verify", "valid-token").Return(claims, nil)
+			mockStore.On("Save", mock.Anything, mock.Anything).Return(nil)
+			replayer.On("Replay", mock.Anything, mock.MatchedBy(func(conn *store.Connection) bool {
+				return conn.ConnectionID == "test-connection-123" && metadataMatches(conn, "ack", "true")
+			})).Return(2, tt.replayErr)
+			mockMetrics.On("PublishMetric", mock.Anything, "", shared.CommonMetrics.ConnectionEstablished,
+				float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+			mockMetrics.On("PublishLatency", mock.Anything, "", "ProcessingLatency",
+				mock.AnythingOfType("time.Duration"), mock.Anything).Return(nil)
+
+			response, err := handler.Handle(context.Background(), event)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 200, response.StatusCode)
+
+			replayer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_Handle_TokenExpiry(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		expiresAt *jwt.NumericDate
+		want      time.Time
+	}{
+		{name: "token with exp", expiresAt: jwt.NewNumericDate(expiresAt), want: expiresAt},
+		{name: "token without exp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(mockConnectionStore)
+			mockMetrics := new(mockMetricsPublisher)
+			mockVerifier := new(mockJWTVerifier)
+
+			handler := NewHandlerWithVerifier(mockStore, &HandlerConfig{JWTPublicKey: "test-key"}, mockMetrics, mockVerifier)
+
+			event := events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "test-connection-123",
+					DomainName:   "api.example.com",
+					Stage:        "prod",
+				},
+				QueryStringParameters: map[string]string{"Authorization": "valid-token"},
+			}
+
+			claims := &shared.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "user123",
+					ExpiresAt: tt.expiresAt,
+				},
+				TenantID: "tenant456",
+			}
+			mockVerifier.On("Verify", "valid-token").Return(claims, nil)
+			mockStore.On("Save", mock.Anything, mock.MatchedBy(func(conn *store.Connection) bool {
+				return conn.TokenExpiresAt.Equal(tt.want)
+			})).Return(nil)
+			mockMetrics.On("PublishMetric", mock.Anything, "", shared.CommonMetrics.ConnectionEstablished,
+				float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+			mockMetrics.On("PublishLatency", mock.Anything, "", "ProcessingLatency",
+				mock.AnythingOfType("time.Duration"), mock.Anything).Return(nil)
+
+			response, err := handler.Handle(context.Background(), event)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 200, response.StatusCode)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+// metadataMatches reports whether conn.Metadata[key] is expected, or absent if expected is empty
+func metadataMatches(conn *store.Connection, key, expected string) bool {
+	value, ok := conn.Metadata[key]
+	if expected == "" {
+		return !ok
+	}
+	return value == expected
+}
+
 func TestHandler_Handle_EmptyTenantList(t *testing.T) {
 	mockStore := new(mockConnectionStore)
 	mockMetrics := new(mockMetricsPublisher)
@@ -405,7 +709,7 @@ func TestHandler_Handle_EmptyTenantList(t *testing.T) {
 		Headers: map[string]string{},
 	}
 
-	claims := &Claims{
+	claims := &shared.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject: "user123",
 		},
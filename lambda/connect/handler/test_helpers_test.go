@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package handler
 
 import (
 	"crypto/rand"
@@ -10,28 +7,9 @@ import (
 	"encoding/pem"
 	"testing"
 
-	"time"
-
 	"github.com/stretchr/testify/require"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
-// Test helper to create test claims - used by both handler_test.go and auth_test.go
-func createTestClaims(userID, tenantID string, permissions []string) *Claims {
-	now := time.Now()
-	return &Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   userID,
-			Issuer:    "test-issuer",
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(1 * time.Hour)),
-		},
-		TenantID:    tenantID,
-		Permissions: permissions,
-	}
-}
-
 // Test helper to generate RSA key pair
 func generateTestKeyPair(t *testing.T) (*rsa.PrivateKey, string) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"os"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/pay-theory/lift/pkg/lift"
 	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/connect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
 )
 
@@ -19,12 +21,12 @@ import (
 // with full middleware stack including JWT, observability, and WebSocket metrics
 type ConnectHandlerOptimized struct {
 	store   store.ConnectionStore
-	config  *HandlerConfig
+	config  *handler.HandlerConfig
 	metrics shared.MetricsPublisher
 }
 
 // NewConnectHandlerOptimized creates a new optimized Lift-based connect handler
-func NewConnectHandlerOptimized(store store.ConnectionStore, config *HandlerConfig, metrics shared.MetricsPublisher) *ConnectHandlerOptimized {
+func NewConnectHandlerOptimized(store store.ConnectionStore, config *handler.HandlerConfig, metrics shared.MetricsPublisher) *ConnectHandlerOptimized {
 	return &ConnectHandlerOptimized{
 		store:   store,
 		config:  config,
@@ -166,3 +168,12 @@ func (h *ConnectHandlerOptimized) publishAuthFailureMetric(ctx context.Context,
 
 	h.metrics.PublishMetric(ctx, "", shared.CommonMetrics.AuthenticationFailed, 1, types.StandardUnitCount, dimensions...)
 }
+
+// jsonStringify converts a value to a JSON string
+func jsonStringify(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -8,27 +8,36 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/pay-theory/dynamorm/pkg/session"
 	dynamormStore "github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/connect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/connection"
 )
 
 func main() {
 	// Load configuration from environment
-	cfg := &HandlerConfig{
+	cfg := &handler.HandlerConfig{
 		TableName:      getEnv("CONNECTIONS_TABLE", "streamer_connections"),
 		JWTPublicKey:   getEnv("JWT_PUBLIC_KEY", ""),
 		JWTIssuer:      getEnv("JWT_ISSUER", ""),
 		AllowedTenants: getEnvSlice("ALLOWED_TENANTS", []string{}),
 		LogLevel:       getEnv("LOG_LEVEL", "INFO"),
+
+		JWKSURL:             getEnv("JWKS_URL", ""),
+		JWKSFile:            getEnv("JWKS_FILE", ""),
+		JWKS:                getEnv("JWKS", ""),
+		JWKSRefreshInterval: getEnvDuration("JWKS_REFRESH_INTERVAL", shared.DefaultJWKSRefreshInterval),
+		JWKSKeyOverlap:      getEnvDuration("JWKS_KEY_OVERLAP", shared.DefaultJWKSKeyOverlap),
 	}
 
 	// Validate configuration
-	if cfg.JWTPublicKey == "" {
-		log.Fatal("JWT_PUBLIC_KEY environment variable is required")
+	if !cfg.VerifierConfig().Configured() {
+		log.Fatal("JWT_PUBLIC_KEY or one of JWKS_URL, JWKS_FILE, JWKS environment variables is required")
 	}
 
 	// Initialize AWS SDK for CloudWatch metrics
@@ -55,13 +64,20 @@ func main() {
 	metrics := shared.NewCloudWatchMetrics(awsCfg, metricsNamespace)
 
 	// Create handler
-	handler := NewHandler(connStore, cfg, metrics)
+	connectHandler := handler.NewHandler(connStore, cfg, metrics)
+
+	// Point unacked messages at clients that reconnect with acks enabled
+	deliveryConfig, err := shared.LoadDeliveryConfig()
+	if err != nil {
+		log.Fatalf("Failed to load delivery config: %v", err)
+	}
+	connectHandler.SetDeliveryReplayer(connection.NewDeliveryTracker(factory.DeliveryStore(), deliveryConfig))
 
 	// Log startup
 	log.Printf("Connect handler started - Table: %s, Region: %s", cfg.TableName, awsCfg.Region)
 
 	// Start Lambda runtime
-	lambda.Start(handler.Handle)
+	lambda.Start(connectHandler.Handle)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -86,3 +102,16 @@ func getEnvSlice(key string, defaultValue []string) []string {
 	}
 	return result
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s, using default: %v", key, err)
+		return defaultValue
+	}
+	return d
+}
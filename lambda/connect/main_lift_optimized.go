@@ -21,6 +21,7 @@ import (
 	"github.com/pay-theory/lift/pkg/observability/zap"
 	"github.com/pay-theory/lift/pkg/security"
 	dynamormStore "github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/connect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
 )
 
@@ -52,7 +53,7 @@ func main() {
 	metrics := shared.NewCloudWatchMetrics(awsCfg, metricsNamespace)
 
 	// Create handler configuration
-	cfg := &HandlerConfig{
+	cfg := &handler.HandlerConfig{
 		JWTPublicKey:   os.Getenv("JWT_PUBLIC_KEY"),
 		JWTIssuer:      os.Getenv("JWT_ISSUER"),
 		AllowedTenants: parseAllowedTenants(os.Getenv("ALLOWED_TENANTS")),
@@ -64,7 +65,7 @@ func main() {
 	}
 
 	// Create optimized handler
-	handler := NewConnectHandlerOptimized(connStore, cfg, metrics)
+	connectHandler := NewConnectHandlerOptimized(connStore, cfg, metrics)
 
 	// Create Lift app with WebSocket support
 	app := lift.New(lift.WithWebSocketSupport())
@@ -137,7 +138,7 @@ func main() {
 	app.Use(middleware.WebSocketAuth(wsAuthConfig))
 
 	// Register WebSocket connect handler
-	app.WebSocket("$connect", handler.HandleConnect)
+	app.WebSocket("$connect", connectHandler.HandleConnect)
 
 	// Log startup
 	log.Printf("Connect handler (Lift optimized with full middleware stack) started - Region: %s", awsCfg.Region)
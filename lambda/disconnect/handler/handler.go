@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package handler
 
 import (
 	"context"
@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package handler
 
 import (
 	"context"
@@ -39,20 +36,20 @@ func (m *mockConnectionStore) Delete(ctx context.Context, connectionID string) e
 	return args.Error(0)
 }
 
-func (m *mockConnectionStore) ListByUser(ctx context.Context, userID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, userID)
+func (m *mockConnectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, userID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
-func (m *mockConnectionStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, tenantID)
+func (m *mockConnectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, tenantID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
 func (m *mockConnectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
@@ -60,6 +57,27 @@ func (m *mockConnectionStore) UpdateLastPing(ctx context.Context, connectionID s
 	return args.Error(0)
 }
 
+func (m *mockConnectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	args := m.Called(ctx, connectionID, metadata, tokenExpiresAt)
+	return args.Error(0)
+}
+
+func (m *mockConnectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
+func (m *mockConnectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
 func (m *mockConnectionStore) DeleteStale(ctx context.Context, before time.Time) error {
 	args := m.Called(ctx, before)
 	return args.Error(0)
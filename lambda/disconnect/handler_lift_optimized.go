@@ -4,33 +4,35 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/pay-theory/lift/pkg/lift"
 	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/disconnect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
 )
 
 // DisconnectHandlerOptimized handles WebSocket $disconnect requests using Lift's built-in features
 type DisconnectHandlerOptimized struct {
 	connStore     store.ConnectionStore
-	subStore      SubscriptionStore
-	requestStore  RequestStore
-	config        *HandlerConfig
-	metricsLogger *MetricsLogger
+	subStore      handler.SubscriptionStore
+	requestStore  handler.RequestStore
+	config        *handler.HandlerConfig
+	metricsLogger *handler.MetricsLogger
 	metrics       shared.MetricsPublisher
 }
 
 // NewDisconnectHandlerOptimized creates a new optimized Lift-based disconnect handler
-func NewDisconnectHandlerOptimized(connStore store.ConnectionStore, subStore SubscriptionStore, requestStore RequestStore, config *HandlerConfig, metrics shared.MetricsPublisher) *DisconnectHandlerOptimized {
+func NewDisconnectHandlerOptimized(connStore store.ConnectionStore, subStore handler.SubscriptionStore, requestStore handler.RequestStore, config *handler.HandlerConfig, metrics shared.MetricsPublisher) *DisconnectHandlerOptimized {
 	return &DisconnectHandlerOptimized{
 		connStore:     connStore,
 		subStore:      subStore,
 		requestStore:  requestStore,
 		config:        config,
-		metricsLogger: NewMetricsLogger(config.MetricsEnabled),
+		metricsLogger: handler.NewMetricsLogger(config.MetricsEnabled),
 		metrics:       metrics,
 	}
 }
@@ -56,7 +58,7 @@ func (h *DisconnectHandlerOptimized) HandleDisconnect(ctx *lift.Context) error {
 	})
 
 	// Initialize metrics
-	metrics := &DisconnectMetrics{
+	metrics := &handler.DisconnectMetrics{
 		ConnectionID:     connectionID,
 		DisconnectTime:   time.Now(),
 		DisconnectReason: "client_disconnect",
@@ -168,3 +170,19 @@ func (h *DisconnectHandlerOptimized) HandleDisconnect(ctx *lift.Context) error {
 		"message": "Disconnected successfully",
 	})
 }
+
+// Helper function to parse int with default
+func parseIntOrDefault(s string, defaultValue int) int {
+	var value int
+	n, err := fmt.Sscanf(s, "%d", &value)
+	if err != nil || n != 1 {
+		return defaultValue
+	}
+	// Check if we consumed the entire string
+	var remainder string
+	fmt.Sscanf(s, "%d%s", &value, &remainder)
+	if remainder != "" {
+		return defaultValue
+	}
+	return value
+}
@@ -12,15 +12,16 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/pay-theory/dynamorm/pkg/session"
 	dynamormStore "github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/disconnect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
 )
 
 func main() {
 	// Load configuration from environment
-	cfg := &HandlerConfig{
+	cfg := &handler.HandlerConfig{
 		ConnectionsTable:   getEnv("CONNECTIONS_TABLE", "streamer_connections"),
 		SubscriptionsTable: getEnv("SUBSCRIPTIONS_TABLE", "streamer_subscriptions"),
-		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests"),
+		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests_v2"),
 		MetricsEnabled:     getEnvBool("METRICS_ENABLED", true),
 		LogLevel:           getEnv("LOG_LEVEL", "INFO"),
 	}
@@ -50,10 +51,10 @@ func main() {
 	metrics := shared.NewCloudWatchMetrics(awsCfg, metricsNamespace)
 
 	// Create handler
-	handler := NewHandler(connStore, nil, nil, cfg, metrics) // nil for subscription/request stores for now
+	disconnectHandler := handler.NewHandler(connStore, nil, nil, cfg, metrics) // nil for subscription/request stores for now
 
 	// Start Lambda runtime
-	lambda.Start(handler.Handle)
+	lambda.Start(disconnectHandler.Handle)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -13,15 +13,16 @@ import (
 	"github.com/pay-theory/dynamorm/pkg/session"
 	"github.com/pay-theory/lift/pkg/lift"
 	dynamormStore "github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/disconnect/handler"
 	"github.com/pay-theory/streamer/lambda/shared"
 )
 
 func main() {
 	// Load configuration from environment
-	cfg := &HandlerConfig{
+	cfg := &handler.HandlerConfig{
 		ConnectionsTable:   getEnv("CONNECTIONS_TABLE", "streamer_connections"),
 		SubscriptionsTable: getEnv("SUBSCRIPTIONS_TABLE", "streamer_subscriptions"),
-		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests"),
+		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests_v2"),
 		MetricsEnabled:     getEnv("METRICS_ENABLED", "true") == "true",
 		LogLevel:           getEnv("LOG_LEVEL", "INFO"),
 	}
@@ -51,17 +52,17 @@ func main() {
 
 	// TODO: Initialize subscription and request stores when available
 	// For now, we'll pass nil which the handler checks for
-	var subStore SubscriptionStore
-	var requestStore RequestStore
+	var subStore handler.SubscriptionStore
+	var requestStore handler.RequestStore
 
 	// Create optimized Lift-based handler
-	handler := NewDisconnectHandlerOptimized(connStore, subStore, requestStore, cfg, metrics)
+	disconnectHandler := NewDisconnectHandlerOptimized(connStore, subStore, requestStore, cfg, metrics)
 
 	// Create Lift app with WebSocket support and built-in middleware
 	app := lift.New(lift.WithWebSocketSupport())
 
 	// Register WebSocket disconnect handler
-	app.Handle("DISCONNECT", "/disconnect", handler.HandleDisconnect)
+	app.Handle("DISCONNECT", "/disconnect", disconnectHandler.HandleDisconnect)
 
 	// Log startup
 	log.Printf("Disconnect handler (Lift Optimized) started - Tables: %s, %s, %s - Region: %s",
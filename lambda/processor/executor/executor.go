@@ -14,14 +14,20 @@ import (
 	"github.com/pay-theory/streamer/pkg/streamer"
 )
 
+// ErrConcurrencyLimited is returned when a tenant is already running as many requests as its quota allows
+var ErrConcurrencyLimited = errors.New("tenant concurrency limit reached")
+
 // AsyncExecutor handles async request processing
 type AsyncExecutor struct {
-	connManager      connection.ConnectionManager
-	requestQueue     store.RequestQueue
-	handlers         map[string]streamer.Handler
-	progressHandlers map[string]streamer.HandlerWithProgress
-	mu               sync.RWMutex
-	logger           *log.Logger
+	connManager        connection.ConnectionManager
+	requestQueue       store.RequestQueue
+	handlers           map[string]streamer.Handler
+	progressHandlers   map[string]streamer.HandlerWithProgress
+	concurrencyLimiter streamer.ConcurrencyLimiter
+	resultOffloader    *streamer.ResultOffloader
+	deferral           time.Duration
+	mu                 sync.RWMutex
+	logger             *log.Logger
 }
 
 // New creates a new async executor
@@ -31,10 +37,25 @@ func New(connManager connection.ConnectionManager, requestQueue store.RequestQue
 		requestQueue:     requestQueue,
 		handlers:         make(map[string]streamer.Handler),
 		progressHandlers: make(map[string]streamer.HandlerWithProgress),
+		deferral:         30 * time.Second,
 		logger:           logger,
 	}
 }
 
+// SetConcurrencyLimiter sets the limiter consulted before a request starts processing
+func (e *AsyncExecutor) SetConcurrencyLimiter(limiter streamer.ConcurrencyLimiter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.concurrencyLimiter = limiter
+}
+
+// SetResultOffloader sets where results too large for the request item are stored
+func (e *AsyncExecutor) SetResultOffloader(offloader *streamer.ResultOffloader) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resultOffloader = offloader
+}
+
 // RegisterHandler registers an async handler
 func (e *AsyncExecutor) RegisterHandler(action string, handler streamer.Handler) error {
 	e.mu.Lock()
@@ -59,6 +80,18 @@ func (e *AsyncExecutor) RegisterHandler(action string, handler streamer.Handler)
 
 // ProcessRequest processes a single async request
 func (e *AsyncExecutor) ProcessRequest(ctx context.Context, asyncReq *store.AsyncRequest) error {
+	// Leave the request pending if the tenant is at its concurrency cap
+	release, err := e.acquireConcurrency(ctx, asyncReq)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return e.process(ctx, asyncReq)
+}
+
+// process runs the handler for a request that holds a running slot
+func (e *AsyncExecutor) process(ctx context.Context, asyncReq *store.AsyncRequest) error {
 	e.logger.Printf("Processing async request: %s, action: %s", asyncReq.RequestID, asyncReq.Action)
 
 	// Update status to PROCESSING
@@ -92,6 +125,9 @@ func (e *AsyncExecutor) ProcessRequest(ctx context.Context, asyncReq *store.Asyn
 		return fmt.Errorf(errMsg)
 	}
 
+	// Expose the submitting principal to the handler
+	ctx = streamer.WithPrincipal(ctx, streamer.PrincipalFromAsyncRequest(asyncReq))
+
 	// Validate request
 	if err := handler.Validate(request); err != nil {
 		errMsg := fmt.Sprintf("validation failed: %v", err)
@@ -160,6 +196,19 @@ func (e *AsyncExecutor) ProcessRequest(ctx context.Context, asyncReq *store.Asyn
 		resultMap["metadata"] = result.Metadata
 	}
 
+	// Move large results out of the request item
+	e.mu.RLock()
+	offloader := e.resultOffloader
+	e.mu.RUnlock()
+	if offloader != nil {
+		offloaded, err := offloader.Offload(ctx, request, resultMap)
+		if err != nil {
+			e.logger.Printf("Failed to offload result for request %s, keeping it inline: %v", asyncReq.RequestID, err)
+		} else {
+			resultMap = offloaded
+		}
+	}
+
 	// Update processing ended time
 	endTime := time.Now()
 	asyncReq.ProcessingEnded = &endTime
@@ -192,6 +241,17 @@ func (e *AsyncExecutor) ProcessWithRetry(ctx context.Context, asyncReq *store.As
 		maxRetries = 3
 	}
 
+	// Hold the running slot across every attempt; a capped request waits
+	// for the deferred sweep rather than for a slot here
+	release, err := e.acquireConcurrency(ctx, asyncReq)
+	if err != nil {
+		if errors.Is(err, ErrConcurrencyLimited) {
+			e.deferRequest(ctx, asyncReq, err)
+		}
+		return err
+	}
+	defer release()
+
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
@@ -218,7 +278,7 @@ func (e *AsyncExecutor) ProcessWithRetry(ctx context.Context, asyncReq *store.As
 		}
 
 		// Process the request
-		err := e.ProcessRequest(ctx, asyncReq)
+		err := e.process(ctx, asyncReq)
 		if err == nil {
 			return nil
 		}
@@ -235,6 +295,46 @@ func (e *AsyncExecutor) ProcessWithRetry(ctx context.Context, asyncReq *store.As
 	return fmt.Errorf("failed after %d attempts: %w", asyncReq.RetryCount+1, lastErr)
 }
 
+// acquireConcurrency takes a running slot under the tenant's cap, returning ErrConcurrencyLimited
+// if the tenant has none free. The returned func gives the slot back.
+// Limiter failures are logged and the request is allowed to start.
+func (e *AsyncExecutor) acquireConcurrency(ctx context.Context, asyncReq *store.AsyncRequest) (func(), error) {
+	e.mu.RLock()
+	limiter := e.concurrencyLimiter
+	e.mu.RUnlock()
+
+	noop := func() {}
+	if limiter == nil {
+		return noop, nil
+	}
+
+	release, err := limiter.AcquireConcurrency(ctx, asyncReq.TenantID, asyncReq.Action)
+	if err != nil {
+		var limitErr *streamer.Error
+		if errors.As(err, &limitErr) {
+			return nil, fmt.Errorf("%w: %s", ErrConcurrencyLimited, limitErr.Message)
+		}
+		e.logger.Printf("Concurrency check failed for request %s, continuing: %v", asyncReq.RequestID, err)
+		return noop, nil
+	}
+
+	return func() {
+		// Give the slot back even if the request ran out of time
+		if err := release(context.WithoutCancel(ctx)); err != nil {
+			e.logger.Printf("Failed to release running slot for request %s: %v", asyncReq.RequestID, err)
+		}
+	}, nil
+}
+
+// deferRequest holds a pending request back until the deferred sweep resumes it
+func (e *AsyncExecutor) deferRequest(ctx context.Context, asyncReq *store.AsyncRequest, reason error) {
+	until := time.Now().Add(e.deferral)
+	e.logger.Printf("Deferring request %s until %s: %v", asyncReq.RequestID, until.Format(time.RFC3339), reason)
+	if err := e.requestQueue.Defer(ctx, asyncReq.RequestID, until); err != nil {
+		e.logger.Printf("Failed to defer request %s: %v", asyncReq.RequestID, err)
+	}
+}
+
 // isRetryableError determines if an error should trigger a retry
 func isRetryableError(err error) bool {
 	if err == nil {
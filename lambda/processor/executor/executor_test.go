@@ -4,15 +4,23 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+	"github.com/pay-theory/streamer/internal/store/memory"
+	"github.com/pay-theory/streamer/internal/store/results"
 	"github.com/pay-theory/streamer/pkg/connection"
 	"github.com/pay-theory/streamer/pkg/streamer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Note: Using MockConnectionManager from pkg/connection/mocks.go
@@ -77,20 +85,56 @@ func (m *mockRequestQueue) FailRequest(ctx context.Context, requestID string, er
 	return args.Error(0)
 }
 
-func (m *mockRequestQueue) GetByConnection(ctx context.Context, connectionID string, limit int) ([]*store.AsyncRequest, error) {
-	args := m.Called(ctx, connectionID, limit)
+func (m *mockRequestQueue) Defer(ctx context.Context, requestID string, until time.Time) error {
+	args := m.Called(ctx, requestID, until)
+	return args.Error(0)
+}
+
+func (m *mockRequestQueue) Resume(ctx context.Context, requestID string) error {
+	args := m.Called(ctx, requestID)
+	return args.Error(0)
+}
+
+func (m *mockRequestQueue) ListDeferred(ctx context.Context, due time.Time, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	args := m.Called(ctx, due, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.AsyncRequest), args.Error(1)
+	return args.Get(0).(*store.Page[*store.AsyncRequest]), args.Error(1)
 }
 
-func (m *mockRequestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, limit int) ([]*store.AsyncRequest, error) {
-	args := m.Called(ctx, status, limit)
+func (m *mockRequestQueue) RecordCallbackDelivery(ctx context.Context, requestID string, delivery store.CallbackDelivery) error {
+	args := m.Called(ctx, requestID, delivery)
+	return args.Error(0)
+}
+
+func (m *mockRequestQueue) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	args := m.Called(ctx, connectionID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.AsyncRequest), args.Error(1)
+	return args.Get(0).(*store.Page[*store.AsyncRequest]), args.Error(1)
+}
+
+func (m *mockRequestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	args := m.Called(ctx, status, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.AsyncRequest]), args.Error(1)
+}
+
+func (m *mockRequestQueue) GetByUser(ctx context.Context, userID string, filter store.RequestFilter, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	args := m.Called(ctx, userID, filter, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.AsyncRequest]), args.Error(1)
+}
+
+func (m *mockRequestQueue) CountByTenant(ctx context.Context, tenantID, action string, statuses ...store.RequestStatus) (int, error) {
+	args := m.Called(ctx, tenantID, action, statuses)
+	return args.Int(0), args.Error(1)
 }
 
 func (m *mockRequestQueue) Delete(ctx context.Context, requestID string) error {
@@ -788,3 +832,260 @@ func TestContainsSubstring(t *testing.T) {
 		})
 	}
 }
+
+// Mock concurrency limiter
+type mockConcurrencyLimiter struct {
+	mock.Mock
+}
+
+func (m *mockConcurrencyLimiter) AcquireConcurrency(ctx context.Context, tenantID, action string) (func(context.Context) error, error) {
+	args := m.Called(ctx, tenantID, action)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(func(context.Context) error), args.Error(1)
+}
+
+func TestProcessWithRetry_ConcurrencyLimit(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	newExecutor := func(queue *mockRequestQueue, handler *mockHandler, limiter *mockConcurrencyLimiter) *AsyncExecutor {
+		mockConnMgr := connection.NewMockConnectionManager()
+		mockConnMgr.SendFunc = func(ctx context.Context, connectionID string, message interface{}) error {
+			return nil
+		}
+		executor := &AsyncExecutor{
+			connManager:      mockConnMgr,
+			requestQueue:     queue,
+			handlers:         map[string]streamer.Handler{"test-action": handler},
+			progressHandlers: make(map[string]streamer.HandlerWithProgress),
+			deferral:         time.Minute,
+			logger:           logger,
+		}
+		executor.SetConcurrencyLimiter(limiter)
+		return executor
+	}
+
+	newRequest := func(id string) *store.AsyncRequest {
+		return &store.AsyncRequest{
+			RequestID:    id,
+			ConnectionID: "conn-1",
+			TenantID:     "tenant-1",
+			Action:       "test-action",
+			Status:       store.StatusPending,
+			MaxRetries:   3,
+			CreatedAt:    time.Now(),
+		}
+	}
+
+	limited := streamer.NewError(streamer.ErrCodeQuotaExceeded, "Tenant is already running its limit of 2 requests")
+
+	t.Run("holds a slot while processing", func(t *testing.T) {
+		mockQueue := new(mockRequestQueue)
+		mockHandler := new(mockHandler)
+		limiter := new(mockConcurrencyLimiter)
+		executor := newExecutor(mockQueue, mockHandler, limiter)
+
+		released := 0
+		release := func(ctx context.Context) error {
+			released++
+			return nil
+		}
+		limiter.On("AcquireConcurrency", mock.Anything, "tenant-1", "test-action").Return(release, nil).Once()
+		mockQueue.On("UpdateStatus", mock.Anything, "req-cap-1", store.StatusProcessing, "Processing started").Return(nil).Once()
+		mockHandler.On("Validate", mock.Anything).Return(nil)
+		mockHandler.On("Process", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+			assert.Equal(t, 0, released, "the slot is held until the request finishes")
+		}).Return(&streamer.Result{Success: true}, nil)
+		mockQueue.On("CompleteRequest", mock.Anything, "req-cap-1", mock.Anything).Return(nil)
+
+		err := executor.ProcessWithRetry(context.Background(), newRequest("req-cap-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, released)
+
+		limiter.AssertExpectations(t)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("defers when tenant is at cap", func(t *testing.T) {
+		mockQueue := new(mockRequestQueue)
+		mockHandler := new(mockHandler)
+		limiter := new(mockConcurrencyLimiter)
+		executor := newExecutor(mockQueue, mockHandler, limiter)
+
+		limiter.On("AcquireConcurrency", mock.Anything, "tenant-1", "test-action").Return(nil, limited).Once()
+		mockQueue.On("Defer", mock.Anything, "req-cap-2", mock.MatchedBy(func(until time.Time) bool {
+			return until.After(time.Now().Add(50 * time.Second))
+		})).Return(nil).Once()
+
+		start := time.Now()
+		err := executor.ProcessWithRetry(context.Background(), newRequest("req-cap-2"))
+		assert.ErrorIs(t, err, ErrConcurrencyLimited)
+		assert.Less(t, time.Since(start), time.Second, "a capped request is handed back, not waited on")
+
+		mockHandler.AssertNotCalled(t, "Process", mock.Anything, mock.Anything)
+		mockQueue.AssertNotCalled(t, "UpdateStatus", mock.Anything, "req-cap-2", mock.Anything, mock.Anything)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("limiter failure does not block work", func(t *testing.T) {
+		mockQueue := new(mockRequestQueue)
+		mockHandler := new(mockHandler)
+		limiter := new(mockConcurrencyLimiter)
+		executor := newExecutor(mockQueue, mockHandler, limiter)
+
+		limiter.On("AcquireConcurrency", mock.Anything, "tenant-1", "test-action").Return(nil, errors.New("throttled"))
+		mockQueue.On("UpdateStatus", mock.Anything, "req-cap-3", store.StatusProcessing, "Processing started").Return(nil)
+		mockHandler.On("Validate", mock.Anything).Return(nil)
+		mockHandler.On("Process", mock.Anything, mock.Anything).Return(&streamer.Result{Success: true}, nil)
+		mockQueue.On("CompleteRequest", mock.Anything, "req-cap-3", mock.Anything).Return(nil)
+
+		err := executor.ProcessWithRetry(context.Background(), newRequest("req-cap-3"))
+		assert.NoError(t, err)
+		mockQueue.AssertExpectations(t)
+	})
+}
+
+func TestProcessRequest_OffloadsLargeResults(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	resultStore, err := results.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	signer, err := streamer.NewResultTokenSigner([]byte(strings.Repeat("k", 32)), time.Minute)
+	require.NoError(t, err)
+
+	mockConnMgr := connection.NewMockConnectionManager()
+	mockConnMgr.SendFunc = func(ctx context.Context, connectionID string, message interface{}) error {
+		return nil
+	}
+	mockQueue := new(mockRequestQueue)
+	mockHandler := new(mockHandler)
+
+	executor := &AsyncExecutor{
+		connManager:      mockConnMgr,
+		requestQueue:     mockQueue,
+		handlers:         map[string]streamer.Handler{"test-action": mockHandler},
+		progressHandlers: make(map[string]streamer.HandlerWithProgress),
+		logger:           logger,
+	}
+	executor.SetResultOffloader(streamer.NewResultOffloader(resultStore, signer, 1024))
+
+	mockQueue.On("UpdateStatus", mock.Anything, "req-big", store.StatusProcessing, "Processing started").Return(nil)
+	mockHandler.On("Validate", mock.Anything).Return(nil)
+	mockHandler.On("Process", mock.Anything, mock.Anything).Return(&streamer.Result{
+		Success: true,
+		Data:    map[string]interface{}{"predictions": strings.Repeat("0.5,", 1000)},
+	}, nil)
+
+	var stored map[string]interface{}
+	mockQueue.On("CompleteRequest", mock.Anything, "req-big", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(2).(map[string]interface{})
+	}).Return(nil)
+
+	err = executor.ProcessRequest(context.Background(), &store.AsyncRequest{
+		RequestID:    "req-big",
+		ConnectionID: "conn-1",
+		TenantID:     "tenant-1",
+		Action:       "test-action",
+		Status:       store.StatusPending,
+		CreatedAt:    time.Now(),
+	})
+	require.NoError(t, err)
+
+	require.Contains(t, stored, "result_ref")
+	assert.NotContains(t, stored, "predictions")
+
+	ref := stored["result_ref"].(*streamer.ResultReference)
+	data, err := resultStore.Get(context.Background(), ref.Key)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "predictions")
+}
+
+func TestProcessWithRetry_SchedulesCallback(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	var (
+		mu       sync.Mutex
+		received int
+	)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	mockConnMgr := connection.NewMockConnectionManager()
+	mockConnMgr.SendFunc = func(ctx context.Context, connectionID string, message interface{}) error {
+		return nil
+	}
+	queue := memory.NewRequestQueue()
+	callbacks := memory.NewCallbackStore()
+	require.NoError(t, lifecycle.Observe(queue, streamer.NewCallbackSender(callbacks, queue, streamer.CallbackConfig{})))
+
+	// The first run of every request times out and is retried
+	var runs sync.Map
+	handler := streamer.SimpleHandler("report", func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+		if _, retried := runs.LoadOrStore(req.ID, true); !retried || strings.Contains(string(req.Payload), "fail") {
+			return nil, errors.New("upstream timeout")
+		}
+		return &streamer.Result{Success: true, Data: map[string]interface{}{"rows": 10}}, nil
+	})
+
+	executor := New(mockConnMgr, queue, logger)
+	require.NoError(t, executor.RegisterHandler("report", handler))
+
+	process := func(t *testing.T, id, report string) *store.AsyncRequest {
+		t.Helper()
+		req := &store.AsyncRequest{
+			RequestID:      id,
+			ConnectionID:   "conn-1",
+			UserID:         "user-1",
+			TenantID:       "tenant-1",
+			Action:         "report",
+			Payload:        map[string]interface{}{"report": report},
+			MaxRetries:     1,
+			CallbackURL:    server.URL,
+			CallbackSecret: "s3cret",
+		}
+		require.NoError(t, queue.Enqueue(context.Background(), req))
+		executor.ProcessWithRetry(context.Background(), req)
+
+		finished, err := queue.Get(context.Background(), id)
+		require.NoError(t, err)
+		return finished
+	}
+
+	// Callbacks are only scheduled; they are sent by a later DeliverDue pass
+	due := func(t *testing.T) []string {
+		t.Helper()
+		pending, err := callbacks.ListDue(context.Background(), time.Now(), 0)
+		require.NoError(t, err)
+		ids := make([]string, len(pending))
+		for i, callback := range pending {
+			ids[i] = callback.RequestID
+			assert.Zero(t, callback.Attempts)
+		}
+		return ids
+	}
+
+	t.Run("completed after a retry", func(t *testing.T) {
+		finished := process(t, "req-cb-1", "sales")
+		assert.Equal(t, store.StatusCompleted, finished.Status)
+		assert.Empty(t, finished.CallbackDeliveries)
+		assert.Equal(t, []string{"req-cb-1"}, due(t))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		require.NoError(t, callbacks.Delete(context.Background(), "req-cb-1"))
+
+		finished := process(t, "req-cb-2", "fail")
+		assert.Equal(t, store.StatusFailed, finished.Status)
+		assert.Equal(t, []string{"req-cb-2"}, due(t))
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Zero(t, received)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -16,17 +17,27 @@ import (
 
 	"github.com/pay-theory/streamer/internal/store"
 	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
 	"github.com/pay-theory/streamer/lambda/processor/executor"
 	"github.com/pay-theory/streamer/lambda/processor/handlers"
+	"github.com/pay-theory/streamer/lambda/shared"
 	"github.com/pay-theory/streamer/pkg/connection"
 	"github.com/pay-theory/streamer/pkg/streamer"
 )
 
 var (
-	exec   *executor.AsyncExecutor
-	logger *log.Logger
+	exec        *executor.AsyncExecutor
+	connManager *connection.Manager
+	callbacks   *streamer.CallbackSender
+	logger      *log.Logger
 )
 
+// redeliveryBatch bounds the unacked messages redelivered after each batch
+const redeliveryBatch = 25
+
+// callbackBatch bounds the callbacks sent after each batch
+const callbackBatch = 25
+
 func init() {
 	logger = log.New(os.Stdout, "[PROCESSOR] ", log.LstdFlags|log.Lshortfile)
 
@@ -51,6 +62,11 @@ func init() {
 	requestQueue := storeFactory.RequestQueue()
 	connectionStore := storeFactory.ConnectionStore()
 
+	// Record every status change the processor makes
+	if err := lifecycle.Observe(requestQueue, lifecycle.NewAuditLog(logger)); err != nil {
+		logger.Fatalf("Failed to attach request lifecycle hooks: %v", err)
+	}
+
 	// Initialize API Gateway Management API client
 	apiGatewayEndpoint := os.Getenv("WEBSOCKET_ENDPOINT")
 	if apiGatewayEndpoint == "" {
@@ -65,12 +81,72 @@ func init() {
 	apiGatewayAdapter := connection.NewAWSAPIGatewayAdapter(apiGatewayClient)
 
 	// Create real ConnectionManager from Team 1
-	connManager := connection.NewManager(connectionStore, apiGatewayAdapter, apiGatewayEndpoint)
+	connManager = connection.NewManager(connectionStore, apiGatewayAdapter, apiGatewayEndpoint)
 	connManager.SetLogger(logger.Printf)
 
+	// Messages larger than a frame are delivered as chunks
+	if size, err := strconv.Atoi(os.Getenv("MAX_FRAME_SIZE")); err == nil && size > 0 {
+		connManager.SetMaxFrameSize(size)
+	}
+
+	// Messages above the threshold are compressed for connections that negotiated an encoding
+	if threshold, err := strconv.Atoi(os.Getenv("COMPRESSION_THRESHOLD")); err == nil {
+		connManager.SetCompressionThreshold(threshold)
+	}
+
+	// Share breaker state with every instance through the connection records
+	breakerConfig, err := shared.LoadCircuitBreakerConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load circuit breaker config: %v", err)
+	}
+	connManager.SetCircuitBreaker(connection.NewCircuitBreaker(storeFactory.CircuitBreakerStore(), breakerConfig))
+
+	// Number each connection's messages from the counter on its record, so
+	// every instance continues the same sequence
+	connManager.SetSequenceStore(storeFactory.SequenceStore())
+
+	// Persist terminal messages for clients that ack them until they do
+	deliveryConfig, err := shared.LoadDeliveryConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load delivery config: %v", err)
+	}
+	connManager.SetDeliveryTracker(connection.NewDeliveryTracker(storeFactory.DeliveryStore(), deliveryConfig))
+
 	// Create executor
 	exec = executor.New(connManager, requestQueue, logger)
 
+	// Hold back work that would exceed a tenant's concurrency cap
+	quotaConfig, err := loadQuotaConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load quota config: %v", err)
+	}
+	enforcer := streamer.NewTenantQuotaEnforcer(storeFactory.QuotaStore(), requestQueue, quotaConfig)
+	enforcer.SetConcurrencyStore(storeFactory.ConcurrencyStore())
+	exec.SetConcurrencyLimiter(enforcer)
+
+	// Offload results too large to keep on the request item
+	resultStore, resultSigner, err := shared.LoadResultStore(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load result store: %v", err)
+	}
+	if resultStore != nil {
+		threshold, _ := strconv.Atoi(os.Getenv("RESULT_OFFLOAD_THRESHOLD"))
+		exec.SetResultOffloader(streamer.NewResultOffloader(resultStore, resultSigner, threshold))
+	}
+
+	// Schedule a callback when a request submitted with a callback URL
+	// finishes; they are sent after each batch and retried by the reaper
+	callbacks = streamer.NewCallbackSender(storeFactory.CallbackStore(), requestQueue, streamer.CallbackConfig{})
+	callbacks.SetLogger(logger.Printf)
+	secretBox, err := shared.LoadSecretBox()
+	if err != nil {
+		logger.Fatalf("Failed to load callback secret key: %v", err)
+	}
+	callbacks.SetSecretBox(secretBox)
+	if err := lifecycle.Observe(requestQueue, callbacks); err != nil {
+		logger.Fatalf("Failed to attach callback hook: %v", err)
+	}
+
 	// Register async handlers
 	if err := registerAsyncHandlers(exec); err != nil {
 		logger.Fatalf("Failed to register handlers: %v", err)
@@ -79,6 +155,16 @@ func init() {
 	logger.Println("Processor Lambda initialized successfully")
 }
 
+// loadQuotaConfig reads the default tenant quotas from QUOTA_CONFIG.
+// Without it, only quotas stored in the tenant quotas table are enforced.
+func loadQuotaConfig() (streamer.QuotaConfig, error) {
+	raw := os.Getenv("QUOTA_CONFIG")
+	if raw == "" {
+		return streamer.QuotaConfig{}, nil
+	}
+	return streamer.ParseQuotaConfig([]byte(raw))
+}
+
 func handler(ctx context.Context, event events.DynamoDBEvent) error {
 	logger.Printf("Processing %d stream records", len(event.Records))
 
@@ -88,6 +174,11 @@ func handler(ctx context.Context, event events.DynamoDBEvent) error {
 			continue
 		}
 
+		// Moving a request to the new table layout inserts it again
+		if isMigration(record) {
+			continue
+		}
+
 		// Parse the AsyncRequest from DynamoDB stream
 		asyncReq, err := parseAsyncRequest(record)
 		if err != nil {
@@ -101,6 +192,12 @@ func handler(ctx context.Context, event events.DynamoDBEvent) error {
 			continue
 		}
 
+		// A deferred request waits for the reaper to resume it
+		if isDeferred(record) {
+			logger.Printf("Skipping deferred request %s", asyncReq.RequestID)
+			continue
+		}
+
 		// Create context with timeout (Lambda max is 15 minutes, leave 1 minute buffer)
 		processCtx, cancel := context.WithTimeout(ctx, 14*time.Minute)
 
@@ -116,9 +213,42 @@ func handler(ctx context.Context, event events.DynamoDBEvent) error {
 		}
 	}
 
+	// Resend terminal messages whose ack timed out
+	if connManager != nil {
+		if resent, err := connManager.RedeliverDue(ctx, redeliveryBatch); err != nil {
+			logger.Printf("Failed to redeliver unacked messages: %v", err)
+		} else if resent > 0 {
+			logger.Printf("Redelivered %d unacked messages", resent)
+		}
+	}
+
+	// POST the outcome of requests that finished in this batch
+	if callbacks != nil {
+		if delivered, err := callbacks.DeliverDue(ctx, callbackBatch); err != nil {
+			logger.Printf("Failed to deliver callbacks: %v", err)
+		} else if delivered > 0 {
+			logger.Printf("Delivered %d callbacks", delivered)
+		}
+	}
+
 	return nil
 }
 
+// isDeferred reports whether a pending request is held back by its tenant's
+// concurrency cap. Resume removes retry_after once the deferral is due.
+func isDeferred(record events.DynamoDBEventRecord) bool {
+	_, ok := record.Change.NewImage["retry_after"]
+	return ok
+}
+
+// isMigration reports whether a record is the INSERT of a request copied out
+// of the legacy requests table, which repeats a request the processor was
+// already sent from that table's stream
+func isMigration(record events.DynamoDBEventRecord) bool {
+	_, ok := record.Change.NewImage["migrated_at"]
+	return ok && record.EventName == "INSERT"
+}
+
 // parseAsyncRequest converts a DynamoDB stream record to an AsyncRequest
 func parseAsyncRequest(record events.DynamoDBEventRecord) (*store.AsyncRequest, error) {
 	// For INSERT events, use NewImage; for MODIFY events, use NewImage as well
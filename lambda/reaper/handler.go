@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/connection"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	messages "github.com/pay-theory/streamer/pkg/types"
+)
+
+// DefaultIdleTimeout is how long a connection may go without activity before it is reaped
+const DefaultIdleTimeout = 10 * time.Minute
+
+// DefaultTokenWarning is how long before its token expires a connection is warned
+const DefaultTokenWarning = 5 * time.Minute
+
+// Reaper metric names
+const (
+	metricConnectionsReaped = "ConnectionsReaped"
+	metricCloseNoticesSent  = "CloseNoticesSent"
+	metricReaperErrors      = "ReaperErrors"
+	metricTokenWarnings     = "TokenWarningsSent"
+	metricTokensExpired     = "ConnectionsExpired"
+	metricRequestsResumed   = "DeferredRequestsResumed"
+	metricRedelivered       = "DeliveriesRedelivered"
+	metricCallbacks         = "CallbacksDelivered"
+)
+
+// redeliveryBatch bounds the unacked messages redelivered on each run
+const redeliveryBatch = 100
+
+// callbackBatch bounds the callbacks sent on each run
+const callbackBatch = 100
+
+// Redeliverer resends messages whose ack timed out; *connection.Manager implements it
+type Redeliverer interface {
+	RedeliverDue(ctx context.Context, limit int) (int, error)
+}
+
+// CallbackDeliverer sends callbacks whose next attempt is due; *streamer.CallbackSender implements it
+type CallbackDeliverer interface {
+	DeliverDue(ctx context.Context, limit int) (int, error)
+}
+
+// HandlerConfig holds configuration for the reaper
+type HandlerConfig struct {
+	// IdleTimeout is how long since the last ping a connection is considered idle
+	IdleTimeout time.Duration
+
+	// TokenWarning is how long before token expiry a token_expiring warning is sent
+	TokenWarning time.Duration
+}
+
+// ReapResult summarises one reaper run
+type ReapResult struct {
+	Stale       int `json:"stale"`
+	Notified    int `json:"notified"`
+	Closed      int `json:"closed"`
+	AlreadyGone int `json:"already_gone"`
+	Warned      int `json:"warned"`
+	Expired     int `json:"expired"`
+	Resumed     int `json:"resumed"`
+	Redelivered int `json:"redelivered"`
+	Callbacks   int `json:"callbacks"`
+	Errors      int `json:"errors"`
+}
+
+// Handler closes idle and expired WebSocket connections on a schedule
+type Handler struct {
+	connStore  store.ConnectionStore
+	apiGateway connection.APIGatewayClient
+	requests   store.RequestQueue
+	deliveries Redeliverer
+	callbacks  CallbackDeliverer
+	config     *HandlerConfig
+	logger     *shared.Logger
+	metrics    shared.MetricsPublisher
+	now        func() time.Time
+}
+
+// NewHandler creates a new reaper handler
+func NewHandler(connStore store.ConnectionStore, apiGateway connection.APIGatewayClient, config *HandlerConfig, metrics shared.MetricsPublisher) *Handler {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+	if config.TokenWarning <= 0 {
+		config.TokenWarning = DefaultTokenWarning
+	}
+	return &Handler{
+		connStore:  connStore,
+		apiGateway: apiGateway,
+		config:     config,
+		logger:     shared.NewLogger("reaper-handler"),
+		metrics:    metrics,
+		now:        time.Now,
+	}
+}
+
+// SetRequestQueue sets the queue whose deferred requests are resumed on each run
+func (h *Handler) SetRequestQueue(requests store.RequestQueue) {
+	h.requests = requests
+}
+
+// SetRedeliverer sets what resends messages whose ack timed out on each run
+func (h *Handler) SetRedeliverer(deliveries Redeliverer) {
+	h.deliveries = deliveries
+}
+
+// SetCallbackDeliverer sets what sends due callbacks, including retries, on each run
+func (h *Handler) SetCallbackDeliverer(callbacks CallbackDeliverer) {
+	h.callbacks = callbacks
+}
+
+// Handle processes a scheduled event. Each idle connection gets a close
+// notice and is closed at API Gateway, then the stale records are deleted.
+// Connections whose token is about to expire are warned, and those whose
+// token has expired are closed. Both lists are walked a page at a time.
+func (h *Handler) Handle(ctx context.Context, event events.CloudWatchEvent) (*ReapResult, error) {
+	now := h.now()
+	before := now.Add(-h.config.IdleTimeout)
+	result := &ReapResult{}
+
+	reason := fmt.Sprintf("No activity for %s", h.config.IdleTimeout)
+	reaped := make(map[string]bool)
+	stale := store.NewPaginator(0, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return h.connStore.ListStale(ctx, before, opts)
+	})
+	for stale.HasMorePages() {
+		page, err := stale.NextPage(ctx)
+		if err != nil {
+			h.logger.Error(ctx, "Failed to list stale connections", map[string]interface{}{
+				"error": err.Error(),
+			})
+			h.publish(ctx, metricReaperErrors, 1)
+			return nil, fmt.Errorf("failed to list stale connections: %w", err)
+		}
+
+		result.Stale += len(page.Items)
+		for _, conn := range page.Items {
+			h.close(ctx, conn, messages.CloseCodeIdleTimeout, reason, result)
+			reaped[conn.ConnectionID] = true
+		}
+	}
+
+	// Remove the records; the $disconnect handler may already have removed some
+	if err := h.connStore.DeleteStale(ctx, before); err != nil {
+		h.logger.Error(ctx, "Failed to delete stale connections", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Errors++
+	}
+
+	h.checkTokens(ctx, now, reaped, result)
+	h.resumeDeferred(ctx, now, result)
+	h.redeliverDue(ctx, result)
+	h.deliverCallbacks(ctx, result)
+
+	h.logger.Info(ctx, "Reaped idle connections", map[string]interface{}{
+		"stale":        result.Stale,
+		"notified":     result.Notified,
+		"closed":       result.Closed,
+		"already_gone": result.AlreadyGone,
+		"warned":       result.Warned,
+		"expired":      result.Expired,
+		"resumed":      result.Resumed,
+		"redelivered":  result.Redelivered,
+		"callbacks":    result.Callbacks,
+		"errors":       result.Errors,
+		"idle_timeout": h.config.IdleTimeout.String(),
+	})
+
+	h.publish(ctx, metricConnectionsReaped, float64(result.Stale))
+	h.publish(ctx, metricCloseNoticesSent, float64(result.Notified))
+	h.publish(ctx, metricTokenWarnings, float64(result.Warned))
+	h.publish(ctx, metricTokensExpired, float64(result.Expired))
+	h.publish(ctx, metricRequestsResumed, float64(result.Resumed))
+	h.publish(ctx, metricRedelivered, float64(result.Redelivered))
+	h.publish(ctx, metricCallbacks, float64(result.Callbacks))
+	h.publish(ctx, metricReaperErrors, float64(result.Errors))
+
+	return result, nil
+}
+
+// checkTokens warns connections whose token expires within the warning window
+// and closes those whose token has already expired. Connections reaped as idle
+// in this run are skipped.
+func (h *Handler) checkTokens(ctx context.Context, now time.Time, reaped map[string]bool, result *ReapResult) {
+	expiring := store.NewPaginator(0, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return h.connStore.ListExpiring(ctx, now.Add(h.config.TokenWarning), opts)
+	})
+	for expiring.HasMorePages() {
+		page, err := expiring.NextPage(ctx)
+		if err != nil {
+			h.logger.Error(ctx, "Failed to list expiring connections", map[string]interface{}{
+				"error": err.Error(),
+			})
+			result.Errors++
+			return
+		}
+
+		for _, conn := range page.Items {
+			if reaped[conn.ConnectionID] || conn.TokenExpiresAt.IsZero() {
+				continue
+			}
+
+			if !now.Before(conn.TokenExpiresAt) {
+				result.Expired++
+				h.close(ctx, conn, messages.CloseCodeTokenExpired, "Token has expired", result)
+				if err := h.connStore.Delete(ctx, conn.ConnectionID); err != nil && !errors.Is(err, store.ErrNotFound) {
+					h.logger.Error(ctx, "Failed to delete expired connection", map[string]interface{}{
+						"connection_id": conn.ConnectionID,
+						"error":         err.Error(),
+					})
+					result.Errors++
+				}
+				continue
+			}
+
+			// Warnings repeat on each run until the client refreshes its token
+			warning, err := codec.Lookup(conn.Metadata[codec.MetadataKey]).Marshal(messages.NewTokenExpiringMessage(conn.TokenExpiresAt))
+			if err == nil {
+				err = h.apiGateway.PostToConnection(ctx, conn.ConnectionID, warning)
+			}
+			switch {
+			case err == nil:
+				result.Warned++
+			case isGone(err):
+				result.AlreadyGone++
+			default:
+				h.logger.Warn(ctx, "Failed to send token expiry warning", map[string]interface{}{
+					"connection_id": conn.ConnectionID,
+					"error":         err.Error(),
+				})
+				result.Errors++
+			}
+		}
+	}
+}
+
+// resumeDeferred hands requests held back by a tenant's concurrency cap
+// back to the processor once their deferral is due
+func (h *Handler) resumeDeferred(ctx context.Context, now time.Time, result *ReapResult) {
+	if h.requests == nil {
+		return
+	}
+
+	resumed, err := streamer.ResumeDeferred(ctx, h.requests, now)
+	result.Resumed += resumed
+	if err != nil {
+		h.logger.Error(ctx, "Failed to resume deferred requests", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Errors++
+	}
+}
+
+// redeliverDue resends messages whose ack timed out, including those a
+// reconnecting client's $connect made due
+func (h *Handler) redeliverDue(ctx context.Context, result *ReapResult) {
+	if h.deliveries == nil {
+		return
+	}
+
+	redelivered, err := h.deliveries.RedeliverDue(ctx, redeliveryBatch)
+	result.Redelivered += redelivered
+	if err != nil {
+		h.logger.Error(ctx, "Failed to redeliver unacked messages", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Errors++
+	}
+}
+
+// deliverCallbacks sends callbacks whose next attempt is due, retrying those
+// the processor could not deliver
+func (h *Handler) deliverCallbacks(ctx context.Context, result *ReapResult) {
+	if h.callbacks == nil {
+		return
+	}
+
+	delivered, err := h.callbacks.DeliverDue(ctx, callbackBatch)
+	result.Callbacks += delivered
+	if err != nil {
+		h.logger.Error(ctx, "Failed to deliver callbacks", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Errors++
+	}
+}
+
+// close sends a close notice with the given code and closes one connection
+func (h *Handler) close(ctx context.Context, conn *store.Connection, code, reason string, result *ReapResult) {
+	notice, err := codec.Lookup(conn.Metadata[codec.MetadataKey]).Marshal(messages.NewCloseMessage(code, reason))
+	if err == nil {
+		err = h.apiGateway.PostToConnection(ctx, conn.ConnectionID, notice)
+	}
+	switch {
+	case err == nil:
+		result.Notified++
+	case isGone(err):
+		// The socket is already closed; only the record is left to delete
+		result.AlreadyGone++
+		return
+	default:
+		// Close the socket anyway; the client just misses the reason
+		h.logger.Warn(ctx, "Failed to send close notice", map[string]interface{}{
+			"connection_id": conn.ConnectionID,
+			"error":         err.Error(),
+		})
+	}
+
+	if err := h.apiGateway.DeleteConnection(ctx, conn.ConnectionID); err != nil {
+		if isGone(err) {
+			result.AlreadyGone++
+			return
+		}
+		h.logger.Error(ctx, "Failed to close connection", map[string]interface{}{
+			"connection_id": conn.ConnectionID,
+			"error":         err.Error(),
+		})
+		result.Errors++
+		return
+	}
+	result.Closed++
+}
+
+// publish emits a reaper metric, logging failures
+func (h *Handler) publish(ctx context.Context, name string, value float64) {
+	err := h.metrics.PublishMetric(ctx, "", name, value, types.StandardUnitCount,
+		shared.MetricsDimensions{}.Environment(os.Getenv("ENVIRONMENT")),
+		shared.MetricsDimensions{}.Function("reaper"))
+	if err != nil {
+		h.logger.Warn(ctx, "Failed to publish metric", map[string]interface{}{
+			"metric": name,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// isGone reports whether err means the connection no longer exists
+func isGone(err error) bool {
+	var gone connection.GoneError
+	return errors.As(err, &gone)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/memory"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+// Mock connection store
+type mockConnectionStore struct {
+	mock.Mock
+	store.ConnectionStore
+}
+
+func (m *mockConnectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
+func (m *mockConnectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
+func (m *mockConnectionStore) Delete(ctx context.Context, connectionID string) error {
+	args := m.Called(ctx, connectionID)
+	return args.Error(0)
+}
+
+func (m *mockConnectionStore) DeleteStale(ctx context.Context, before time.Time) error {
+	args := m.Called(ctx, before)
+	return args.Error(0)
+}
+
+// Mock metrics publisher
+type mockMetricsPublisher struct {
+	mock.Mock
+}
+
+func (m *mockMetricsPublisher) PublishMetric(ctx context.Context, namespace, metricName string, value float64, unit types.StandardUnit, dimensions ...types.Dimension) error {
+	args := m.Called(ctx, namespace, metricName, value, unit, dimensions)
+	return args.Error(0)
+}
+
+func (m *mockMetricsPublisher) PublishLatency(ctx context.Context, namespace, metricName string, duration time.Duration, dimensions ...types.Dimension) error {
+	args := m.Called(ctx, namespace, metricName, duration, dimensions)
+	return args.Error(0)
+}
+
+func newTestHandler(connStore *mockConnectionStore, apiGateway connection.APIGatewayClient, metrics *mockMetricsPublisher, now time.Time) *Handler {
+	handler := NewHandler(connStore, apiGateway, &HandlerConfig{IdleTimeout: 5 * time.Minute, TokenWarning: 2 * time.Minute}, metrics)
+	handler.now = func() time.Time { return now }
+	return handler
+}
+
+func TestHandler_Handle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(-5 * time.Minute)
+
+	connStore := new(mockConnectionStore)
+	apiGateway := connection.NewMockAPIGatewayClient()
+	metrics := new(mockMetricsPublisher)
+
+	// The stale connections come back over two pages
+	connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{
+		Items: []*store.Connection{
+			{ConnectionID: "conn-idle"},
+			{ConnectionID: "conn-msgpack", Metadata: map[string]string{codec.MetadataKey: codec.NameMessagePack}},
+		},
+		NextToken: "page-2",
+	}, nil)
+	connStore.On("ListStale", ctx, before, store.PageOptions{NextToken: "page-2"}).Return(&store.Page[*store.Connection]{
+		Items: []*store.Connection{
+			{ConnectionID: "conn-gone"},
+			{ConnectionID: "conn-broken"},
+		},
+	}, nil)
+	connStore.On("DeleteStale", ctx, before).Return(nil)
+	connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+
+	var notices [][]byte
+	recordNotice := func(args mock.Arguments) { notices = append(notices, args.Get(2).([]byte)) }
+	apiGateway.On("PostToConnection", ctx, "conn-idle", mock.Anything).Run(recordNotice).Return(nil)
+	apiGateway.On("PostToConnection", ctx, "conn-msgpack", mock.Anything).Run(recordNotice).Return(nil)
+	apiGateway.On("PostToConnection", ctx, "conn-gone", mock.Anything).Return(connection.GoneError{ConnectionID: "conn-gone"})
+	apiGateway.On("PostToConnection", ctx, "conn-broken", mock.Anything).Return(errors.New("throttled"))
+	apiGateway.On("DeleteConnection", ctx, "conn-idle").Return(nil)
+	apiGateway.On("DeleteConnection", ctx, "conn-msgpack").Return(nil)
+	apiGateway.On("DeleteConnection", ctx, "conn-broken").Return(errors.New("throttled"))
+
+	metrics.On("PublishMetric", ctx, "", metricConnectionsReaped, float64(4), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricCloseNoticesSent, float64(2), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricReaperErrors, float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricTokenWarnings, float64(0), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricTokensExpired, float64(0), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricRequestsResumed, float64(0), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricRedelivered, float64(0), types.StandardUnitCount, mock.Anything).Return(nil)
+	metrics.On("PublishMetric", ctx, "", metricCallbacks, float64(0), types.StandardUnitCount, mock.Anything).Return(nil)
+
+	result, err := newTestHandler(connStore, apiGateway, metrics, now).Handle(ctx, events.CloudWatchEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, &ReapResult{Stale: 4, Notified: 2, Closed: 2, AlreadyGone: 1, Errors: 1}, result)
+
+	// Close notices use the connection's codec
+	require.Len(t, notices, 2)
+	var notice map[string]interface{}
+	require.NoError(t, json.Unmarshal(notices[0], &notice))
+	assert.Equal(t, "close", notice["type"])
+	assert.Equal(t, "IDLE_TIMEOUT", notice["code"])
+	assert.Equal(t, "No activity for 5m0s", notice["reason"])
+
+	notice = nil
+	require.NoError(t, codec.MessagePack.Unmarshal(notices[1], &notice))
+	assert.Equal(t, "IDLE_TIMEOUT", notice["code"])
+
+	apiGateway.AssertNotCalled(t, "DeleteConnection", ctx, "conn-gone")
+	connStore.AssertExpectations(t)
+	apiGateway.AssertExpectations(t)
+	metrics.AssertExpectations(t)
+}
+
+func TestHandler_Handle_ListError(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	connStore := new(mockConnectionStore)
+	metrics := new(mockMetricsPublisher)
+
+	connStore.On("ListStale", ctx, now.Add(-5*time.Minute), store.PageOptions{}).Return(nil, errors.New("scan failed"))
+	metrics.On("PublishMetric", ctx, "", metricReaperErrors, float64(1), types.StandardUnitCount, mock.Anything).Return(nil)
+
+	_, err := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now).Handle(ctx, events.CloudWatchEvent{})
+	assert.ErrorContains(t, err, "failed to list stale connections")
+
+	connStore.AssertNotCalled(t, "DeleteStale", mock.Anything, mock.Anything)
+	metrics.AssertExpectations(t)
+}
+
+func TestHandler_Handle_DeleteStaleError(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(-5 * time.Minute)
+
+	connStore := new(mockConnectionStore)
+	metrics := new(mockMetricsPublisher)
+
+	connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+	connStore.On("DeleteStale", ctx, before).Return(errors.New("delete failed"))
+	connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+	metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+	result, err := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now).Handle(ctx, events.CloudWatchEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Errors)
+	metrics.AssertCalled(t, "PublishMetric", ctx, "", metricReaperErrors, float64(1), types.StandardUnitCount, mock.Anything)
+}
+
+func TestHandler_Handle_TokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(-5 * time.Minute)
+
+	connStore := new(mockConnectionStore)
+	apiGateway := connection.NewMockAPIGatewayClient()
+	metrics := new(mockMetricsPublisher)
+
+	connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{Items: []*store.Connection{{ConnectionID: "conn-idle"}}}, nil)
+	connStore.On("DeleteStale", ctx, before).Return(nil)
+	connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{Items: []*store.Connection{
+		{ConnectionID: "conn-idle", TokenExpiresAt: now.Add(-time.Minute)},
+		{ConnectionID: "conn-expiring", TokenExpiresAt: now.Add(time.Minute)},
+		{ConnectionID: "conn-expired", TokenExpiresAt: now.Add(-time.Second)},
+		{ConnectionID: "conn-expiring-gone", TokenExpiresAt: now.Add(time.Minute)},
+	}}, nil)
+	connStore.On("Delete", ctx, "conn-expired").Return(nil)
+
+	frames := map[string][]byte{}
+	recordFrame := func(args mock.Arguments) { frames[args.String(1)] = args.Get(2).([]byte) }
+	apiGateway.On("PostToConnection", ctx, "conn-idle", mock.Anything).Return(nil)
+	apiGateway.On("DeleteConnection", ctx, "conn-idle").Return(nil)
+	apiGateway.On("PostToConnection", ctx, "conn-expiring", mock.Anything).Run(recordFrame).Return(nil)
+	apiGateway.On("PostToConnection", ctx, "conn-expired", mock.Anything).Run(recordFrame).Return(nil)
+	apiGateway.On("DeleteConnection", ctx, "conn-expired").Return(nil)
+	apiGateway.On("PostToConnection", ctx, "conn-expiring-gone", mock.Anything).Return(connection.GoneError{ConnectionID: "conn-expiring-gone"})
+
+	metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+	result, err := newTestHandler(connStore, apiGateway, metrics, now).Handle(ctx, events.CloudWatchEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, &ReapResult{Stale: 1, Notified: 2, Closed: 2, AlreadyGone: 1, Warned: 1, Expired: 1}, result)
+
+	var warning map[string]interface{}
+	require.NoError(t, json.Unmarshal(frames["conn-expiring"], &warning))
+	assert.Equal(t, "token_expiring", warning["type"])
+	assert.Equal(t, float64(now.Add(time.Minute).Unix()), warning["expires_at"])
+
+	var notice map[string]interface{}
+	require.NoError(t, json.Unmarshal(frames["conn-expired"], &notice))
+	assert.Equal(t, "close", notice["type"])
+	assert.Equal(t, "TOKEN_EXPIRED", notice["code"])
+
+	// The idle connection is only closed once
+	apiGateway.AssertNumberOfCalls(t, "DeleteConnection", 2)
+	connStore.AssertExpectations(t)
+	apiGateway.AssertExpectations(t)
+	metrics.AssertCalled(t, "PublishMetric", ctx, "", metricTokenWarnings, float64(1), types.StandardUnitCount, mock.Anything)
+	metrics.AssertCalled(t, "PublishMetric", ctx, "", metricTokensExpired, float64(1), types.StandardUnitCount, mock.Anything)
+}
+
+func TestHandler_Handle_ResumesDeferred(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(-5 * time.Minute)
+
+	connStore := new(mockConnectionStore)
+	metrics := new(mockMetricsPublisher)
+	connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+	connStore.On("DeleteStale", ctx, before).Return(nil)
+	connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+	metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+	requests := memory.NewRequestQueue()
+	for _, id := range []string{"req-due", "req-later"} {
+		require.NoError(t, requests.Enqueue(ctx, &store.AsyncRequest{RequestID: id, ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1", Action: "generate_report"}))
+	}
+	require.NoError(t, requests.Defer(ctx, "req-due", now.Add(-time.Second)))
+	require.NoError(t, requests.Defer(ctx, "req-later", now.Add(time.Minute)))
+
+	handler := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now)
+	handler.SetRequestQueue(requests)
+
+	result, err := handler.Handle(ctx, events.CloudWatchEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Resumed)
+
+	due, err := requests.Get(ctx, "req-due")
+	require.NoError(t, err)
+	assert.True(t, due.RetryAfter.IsZero())
+	later, err := requests.Get(ctx, "req-later")
+	require.NoError(t, err)
+	assert.False(t, later.RetryAfter.IsZero())
+	metrics.AssertCalled(t, "PublishMetric", ctx, "", metricRequestsResumed, float64(1), types.StandardUnitCount, mock.Anything)
+}
+
+// Mock redeliverer
+type mockRedeliverer struct {
+	mock.Mock
+}
+
+func (m *mockRedeliverer) RedeliverDue(ctx context.Context, limit int) (int, error) {
+	args := m.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func TestHandler_Handle_RedeliversDue(t *testing.T) {
+	tests := []struct {
+		name     string
+		resent   int
+		err      error
+		expected *ReapResult
+	}{
+		{name: "redelivered", resent: 3, expected: &ReapResult{Redelivered: 3}},
+		{name: "partial failure", resent: 1, err: errors.New("table unavailable"), expected: &ReapResult{Redelivered: 1, Errors: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			before := now.Add(-5 * time.Minute)
+
+			connStore := new(mockConnectionStore)
+			metrics := new(mockMetricsPublisher)
+			connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+			connStore.On("DeleteStale", ctx, before).Return(nil)
+			connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+			metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+			deliveries := new(mockRedeliverer)
+			deliveries.On("RedeliverDue", ctx, redeliveryBatch).Return(tt.resent, tt.err)
+
+			handler := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now)
+			handler.SetRedeliverer(deliveries)
+
+			result, err := handler.Handle(ctx, events.CloudWatchEvent{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+
+			deliveries.AssertExpectations(t)
+			metrics.AssertCalled(t, "PublishMetric", ctx, "", metricRedelivered, float64(tt.resent), types.StandardUnitCount, mock.Anything)
+		})
+	}
+}
+
+// Mock callback deliverer
+type mockCallbackDeliverer struct {
+	mock.Mock
+}
+
+func (m *mockCallbackDeliverer) DeliverDue(ctx context.Context, limit int) (int, error) {
+	args := m.Called(ctx, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func TestHandler_Handle_DeliversCallbacks(t *testing.T) {
+	tests := []struct {
+		name      string
+		delivered int
+		err       error
+		expected  *ReapResult
+	}{
+		{name: "delivered", delivered: 2, expected: &ReapResult{Callbacks: 2}},
+		{name: "partial failure", delivered: 1, err: errors.New("table unavailable"), expected: &ReapResult{Callbacks: 1, Errors: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			before := now.Add(-5 * time.Minute)
+
+			connStore := new(mockConnectionStore)
+			metrics := new(mockMetricsPublisher)
+			connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+			connStore.On("DeleteStale", ctx, before).Return(nil)
+			connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+			metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+			callbacks := new(mockCallbackDeliverer)
+			callbacks.On("DeliverDue", ctx, callbackBatch).Return(tt.delivered, tt.err)
+
+			handler := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now)
+			handler.SetCallbackDeliverer(callbacks)
+
+			result, err := handler.Handle(ctx, events.CloudWatchEvent{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+
+			callbacks.AssertExpectations(t)
+			metrics.AssertCalled(t, "PublishMetric", ctx, "", metricCallbacks, float64(tt.delivered), types.StandardUnitCount, mock.Anything)
+		})
+	}
+}
+
+func TestHandler_Handle_ListExpiringError(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(-5 * time.Minute)
+
+	connStore := new(mockConnectionStore)
+	metrics := new(mockMetricsPublisher)
+
+	connStore.On("ListStale", ctx, before, store.PageOptions{}).Return(&store.Page[*store.Connection]{}, nil)
+	connStore.On("DeleteStale", ctx, before).Return(nil)
+	connStore.On("ListExpiring", ctx, now.Add(2*time.Minute), store.PageOptions{}).Return(nil, errors.New("scan failed"))
+	metrics.On("PublishMetric", ctx, "", mock.Anything, mock.Anything, types.StandardUnitCount, mock.Anything).Return(nil)
+
+	result, err := newTestHandler(connStore, connection.NewMockAPIGatewayClient(), metrics, now).Handle(ctx, events.CloudWatchEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Errors)
+}
+
+func TestNewHandler_Defaults(t *testing.T) {
+	handler := NewHandler(new(mockConnectionStore), connection.NewMockAPIGatewayClient(), &HandlerConfig{}, new(mockMetricsPublisher))
+	assert.Equal(t, DefaultIdleTimeout, handler.config.IdleTimeout)
+	assert.Equal(t, DefaultTokenWarning, handler.config.TokenWarning)
+}
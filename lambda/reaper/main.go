@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+	"github.com/pay-theory/dynamorm/pkg/session"
+
+	"github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/connection"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+func main() {
+	// Load configuration from environment
+	cfg := &HandlerConfig{}
+	if raw := os.Getenv("IDLE_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("Invalid IDLE_TIMEOUT: %v", err)
+		}
+		cfg.IdleTimeout = timeout
+	}
+	if raw := os.Getenv("TOKEN_WARNING"); raw != "" {
+		warning, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("Invalid TOKEN_WARNING: %v", err)
+		}
+		cfg.TokenWarning = warning
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+
+	factory, err := dynamorm.NewStoreFactory(session.Config{Region: awsCfg.Region})
+	if err != nil {
+		log.Fatalf("Failed to create DynamORM factory: %v", err)
+	}
+
+	// Close sockets through the API Gateway Management API
+	endpoint := os.Getenv("WEBSOCKET_ENDPOINT")
+	if endpoint == "" {
+		log.Fatal("WEBSOCKET_ENDPOINT environment variable is required")
+	}
+	apiGatewayClient := apigatewaymanagementapi.NewFromConfig(awsCfg, func(o *apigatewaymanagementapi.Options) {
+		o.BaseEndpoint = &endpoint
+	})
+
+	metricsNamespace := os.Getenv("METRICS_NAMESPACE")
+	if metricsNamespace == "" {
+		metricsNamespace = "Streamer"
+	}
+	metrics := shared.NewCloudWatchMetrics(awsCfg, metricsNamespace)
+
+	handler := NewHandler(factory.ConnectionStore(), connection.NewAWSAPIGatewayAdapter(apiGatewayClient), cfg, metrics)
+
+	// Hand requests deferred by a tenant's concurrency cap back to the processor
+	handler.SetRequestQueue(factory.RequestQueue())
+
+	// Resend terminal messages whose ack timed out, and those made due when
+	// their client reconnected
+	deliveryConfig, err := shared.LoadDeliveryConfig()
+	if err != nil {
+		log.Fatalf("Failed to load delivery config: %v", err)
+	}
+	connManager := connection.NewManager(factory.ConnectionStore(), connection.NewAWSAPIGatewayAdapter(apiGatewayClient), endpoint)
+	connManager.SetDeliveryTracker(connection.NewDeliveryTracker(factory.DeliveryStore(), deliveryConfig))
+	connManager.SetSequenceStore(factory.SequenceStore())
+	handler.SetRedeliverer(connManager)
+
+	// Retry callbacks the processor could not deliver
+	callbacks := streamer.NewCallbackSender(factory.CallbackStore(), factory.RequestQueue(), streamer.CallbackConfig{})
+	callbacks.SetLogger(log.Printf)
+	secretBox, err := shared.LoadSecretBox()
+	if err != nil {
+		log.Fatalf("Failed to load callback secret key: %v", err)
+	}
+	callbacks.SetSecretBox(secretBox)
+	handler.SetCallbackDeliverer(callbacks)
+
+	// Start Lambda runtime
+	lambda.Start(handler.Handle)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/pay-theory/lift/pkg/lift"
 	"github.com/pay-theory/streamer/internal/store"
 	"github.com/pay-theory/streamer/lambda/router"
+	"github.com/pay-theory/streamer/lambda/router/handlers"
 	"github.com/pay-theory/streamer/pkg/connection"
 	"github.com/pay-theory/streamer/pkg/streamer"
 )
@@ -160,7 +161,7 @@ func CreateStreamerRouter(
 	)
 
 	// Register handlers
-	if err := registerHandlers(router); err != nil {
+	if err := handlers.RegisterHandlers(router); err != nil {
 		return nil, err
 	}
 
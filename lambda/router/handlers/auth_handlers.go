@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// claimsStore loads a connection and records the claims of its refreshed
+// token; implemented by store.ConnectionStore
+type claimsStore interface {
+	Get(ctx context.Context, connectionID string) (*store.Connection, error)
+	UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error
+}
+
+// RegisterAuthHandlers registers the handler clients use to refresh their token
+func RegisterAuthHandlers(router *streamer.DefaultRouter, verifier shared.TokenVerifier, connections claimsStore) error {
+	if err := router.Handle(streamer.ActionAuthRefresh, NewRefreshHandler(verifier, connections)); err != nil {
+		return fmt.Errorf("failed to register auth refresh handler: %w", err)
+	}
+	return nil
+}
+
+// RefreshParams defines the structure for auth.refresh requests
+type RefreshParams struct {
+	Token string `json:"token"`
+}
+
+// RefreshHandler verifies a new token for a live connection and replaces the
+// connection's permissions and expiry with its claims
+type RefreshHandler struct {
+	verifier    shared.TokenVerifier
+	connections claimsStore
+}
+
+func NewRefreshHandler(verifier shared.TokenVerifier, connections claimsStore) *RefreshHandler {
+	return &RefreshHandler{
+		verifier:    verifier,
+		connections: connections,
+	}
+}
+
+func (h *RefreshHandler) EstimatedDuration() time.Duration {
+	return 200 * time.Millisecond
+}
+
+func (h *RefreshHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params RefreshParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if params.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+func (h *RefreshHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	var params RefreshParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+
+	principal, ok := streamer.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Connection has no associated identity")
+	}
+
+	claims, err := h.verifier.Verify(params.Token)
+	if err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, fmt.Sprintf("Invalid token: %v", err))
+	}
+
+	// A refresh extends the session; it cannot switch to another identity
+	if claims.Subject != principal.UserID || claims.TenantID != principal.TenantID {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Token belongs to a different user")
+	}
+
+	conn, err := h.connections.Get(ctx, req.ConnectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load connection: %w", err)
+	}
+
+	permissions, err := json.Marshal(claims.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode permissions: %w", err)
+	}
+	metadata := make(map[string]string, len(conn.Metadata)+1)
+	for k, v := range conn.Metadata {
+		metadata[k] = v
+	}
+	metadata["permissions"] = string(permissions)
+
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := h.connections.UpdateClaims(ctx, req.ConnectionID, metadata, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to update connection claims: %w", err)
+	}
+
+	data := map[string]interface{}{
+		"permissions": claims.Permissions,
+	}
+	if !expiresAt.IsZero() {
+		data["expires_at"] = expiresAt.Unix()
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data:      data,
+	}, nil
+}
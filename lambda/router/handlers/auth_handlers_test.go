@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/lambda/shared"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+type mockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *mockTokenVerifier) Verify(token string) (*shared.Claims, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*shared.Claims), args.Error(1)
+}
+
+type mockClaimsStore struct {
+	mock.Mock
+}
+
+func (m *mockClaimsStore) Get(ctx context.Context, connectionID string) (*store.Connection, error) {
+	args := m.Called(ctx, connectionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Connection), args.Error(1)
+}
+
+func (m *mockClaimsStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	args := m.Called(ctx, connectionID, metadata, tokenExpiresAt)
+	return args.Error(0)
+}
+
+func TestRefreshHandler_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{name: "token", payload: `{"token": "eyJhbGciOiJSUzI1NiJ9.e30.sig"}`},
+		{name: "missing token", payload: `{}`, wantErr: "token is required"},
+		{name: "invalid payload", payload: `[]`, wantErr: "invalid payload format"},
+	}
+
+	handler := NewRefreshHandler(new(mockTokenVerifier), new(mockClaimsStore))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handler.Validate(&streamer.Request{Payload: json.RawMessage(tt.payload)})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshHandler_Process(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	principal := &streamer.Principal{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"}
+	ctx := streamer.WithPrincipal(context.Background(), principal)
+	request := &streamer.Request{
+		ID:           "req-1",
+		ConnectionID: "conn-1",
+		Payload:      json.RawMessage(`{"token": "new-token"}`),
+	}
+
+	claimsFor := func(userID, tenantID string) *shared.Claims {
+		return &shared.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID,
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			TenantID:    tenantID,
+			Permissions: []string{"read", "write"},
+		}
+	}
+
+	t.Run("updates permissions and expiry", func(t *testing.T) {
+		verifier := new(mockTokenVerifier)
+		connections := new(mockClaimsStore)
+		verifier.On("Verify", "new-token").Return(claimsFor("user-1", "tenant-1"), nil)
+		connections.On("Get", ctx, "conn-1").Return(&store.Connection{
+			ConnectionID: "conn-1",
+			Metadata:     map[string]string{"permissions": `["read"]`, "codec": "msgpack"},
+		}, nil)
+		connections.On("UpdateClaims", ctx, "conn-1", map[string]string{
+			"permissions": `["read","write"]`,
+			"codec":       "msgpack",
+		}, expiresAt).Return(nil)
+
+		result, err := NewRefreshHandler(verifier, connections).Process(ctx, request)
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, map[string]interface{}{
+			"permissions": []string{"read", "write"},
+			"expires_at":  expiresAt.Unix(),
+		}, result.Data)
+		connections.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		verifier := new(mockTokenVerifier)
+		verifier.On("Verify", "new-token").Return(nil, errors.New("token has expired"))
+
+		_, err := NewRefreshHandler(verifier, new(mockClaimsStore)).Process(ctx, request)
+		var streamerErr *streamer.Error
+		require.ErrorAs(t, err, &streamerErr)
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+	})
+
+	t.Run("token for another user", func(t *testing.T) {
+		verifier := new(mockTokenVerifier)
+		connections := new(mockClaimsStore)
+		verifier.On("Verify", "new-token").Return(claimsFor("user-2", "tenant-1"), nil)
+
+		_, err := NewRefreshHandler(verifier, connections).Process(ctx, request)
+		var streamerErr *streamer.Error
+		require.ErrorAs(t, err, &streamerErr)
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+		connections.AssertNotCalled(t, "UpdateClaims", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no principal", func(t *testing.T) {
+		_, err := NewRefreshHandler(new(mockTokenVerifier), new(mockClaimsStore)).Process(context.Background(), request)
+		var streamerErr *streamer.Error
+		require.ErrorAs(t, err, &streamerErr)
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		verifier := new(mockTokenVerifier)
+		connections := new(mockClaimsStore)
+		verifier.On("Verify", "new-token").Return(claimsFor("user-1", "tenant-1"), nil)
+		connections.On("Get", ctx, "conn-1").Return(&store.Connection{ConnectionID: "conn-1"}, nil)
+		connections.On("UpdateClaims", ctx, "conn-1", mock.Anything, expiresAt).Return(errors.New("throttled"))
+
+		_, err := NewRefreshHandler(verifier, connections).Process(ctx, request)
+		assert.ErrorContains(t, err, "failed to update connection claims")
+	})
+}
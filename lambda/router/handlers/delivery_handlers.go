@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// maxAckBatch bounds the delivery IDs acked by one request
+const maxAckBatch = 100
+
+// deliveryAcker acknowledges and redelivers tracked messages; implemented by
+// connection.Manager when reliable delivery is enabled
+type deliveryAcker interface {
+	Ack(ctx context.Context, connectionID string, deliveryIDs []string) (int, error)
+	Resume(ctx context.Context, connectionID string) (int, error)
+}
+
+// RegisterDeliveryHandlers registers the handler clients use to ack messages
+func RegisterDeliveryHandlers(router *streamer.DefaultRouter, acker deliveryAcker) error {
+	if err := router.Handle("ack", NewAckHandler(acker)); err != nil {
+		return fmt.Errorf("failed to register ack handler: %w", err)
+	}
+	return nil
+}
+
+// AckParams defines the structure for ack requests
+type AckParams struct {
+	DeliveryIDs []string `json:"delivery_ids"`
+	Resume      bool     `json:"resume,omitempty"` // redeliver everything still unacked
+}
+
+// AckHandler removes acknowledged deliveries and, on resume, redelivers the
+// rest to the calling connection
+type AckHandler struct {
+	acker deliveryAcker
+}
+
+func NewAckHandler(acker deliveryAcker) *AckHandler {
+	return &AckHandler{acker: acker}
+}
+
+func (h *AckHandler) EstimatedDuration() time.Duration {
+	return 100 * time.Millisecond
+}
+
+func (h *AckHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params AckParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if len(params.DeliveryIDs) == 0 && !params.Resume {
+		return errors.New("delivery_ids is required")
+	}
+	if len(params.DeliveryIDs) > maxAckBatch {
+		return fmt.Errorf("at most %d delivery_ids may be acked at once", maxAckBatch)
+	}
+	for _, id := range params.DeliveryIDs {
+		if id == "" {
+			return errors.New("delivery_ids cannot contain empty IDs")
+		}
+	}
+
+	return nil
+}
+
+func (h *AckHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	var params AckParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+
+	acked, err := h.acker.Ack(ctx, req.ConnectionID, params.DeliveryIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ack deliveries: %w", err)
+	}
+
+	redelivered := 0
+	if params.Resume {
+		if redelivered, err = h.acker.Resume(ctx, req.ConnectionID); err != nil {
+			return nil, fmt.Errorf("failed to resume deliveries: %w", err)
+		}
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data: map[string]interface{}{
+			"acked":       acked,
+			"redelivered": redelivered,
+		},
+	}, nil
+}
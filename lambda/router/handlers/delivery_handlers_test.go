@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDeliveryAcker struct {
+	mock.Mock
+}
+
+func (m *mockDeliveryAcker) Ack(ctx context.Context, connectionID string, deliveryIDs []string) (int, error) {
+	args := m.Called(ctx, connectionID, deliveryIDs)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockDeliveryAcker) Resume(ctx context.Context, connectionID string) (int, error) {
+	args := m.Called(ctx, connectionID)
+	return args.Int(0), args.Error(1)
+}
+
+func TestAckHandler_Validate(t *testing.T) {
+	tooMany := make([]string, maxAckBatch+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%q", fmt.Sprintf("dlv-%d", i))
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{name: "ack", payload: `{"delivery_ids": ["dlv-1", "dlv-2"]}`},
+		{name: "resume only", payload: `{"resume": true}`},
+		{name: "nothing to do", payload: `{}`, wantErr: "delivery_ids is required"},
+		{name: "empty ID", payload: `{"delivery_ids": [""]}`, wantErr: "empty IDs"},
+		{name: "too many IDs", payload: `{"delivery_ids": [` + strings.Join(tooMany, ",") + `]}`, wantErr: "at most"},
+		{name: "invalid payload", payload: `[]`, wantErr: "invalid payload format"},
+	}
+
+	handler := NewAckHandler(new(mockDeliveryAcker))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handler.Validate(&streamer.Request{Payload: json.RawMessage(tt.payload)})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAckHandler_Process(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("acks deliveries", func(t *testing.T) {
+		acker := new(mockDeliveryAcker)
+		acker.On("Ack", ctx, "conn-1", []string{"dlv-1", "dlv-2"}).Return(2, nil)
+
+		result, err := NewAckHandler(acker).Process(ctx, &streamer.Request{
+			ID:           "req-1",
+			ConnectionID: "conn-1",
+			Payload:      json.RawMessage(`{"delivery_ids": ["dlv-1", "dlv-2"]}`),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"acked": 2, "redelivered": 0}, result.Data)
+		acker.AssertExpectations(t)
+	})
+
+	t.Run("resume redelivers unacked messages", func(t *testing.T) {
+		acker := new(mockDeliveryAcker)
+		acker.On("Ack", ctx, "conn-1", []string(nil)).Return(0, nil)
+		acker.On("Resume", ctx, "conn-1").Return(3, nil)
+
+		result, err := NewAckHandler(acker).Process(ctx, &streamer.Request{
+			ID:           "req-1",
+			ConnectionID: "conn-1",
+			Payload:      json.RawMessage(`{"resume": true}`),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"acked": 0, "redelivered": 3}, result.Data)
+		acker.AssertExpectations(t)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		acker := new(mockDeliveryAcker)
+		acker.On("Ack", ctx, "conn-1", []string{"dlv-1"}).Return(0, errors.New("dynamodb unavailable"))
+
+		_, err := NewAckHandler(acker).Process(ctx, &streamer.Request{
+			ConnectionID: "conn-1",
+			Payload:      json.RawMessage(`{"delivery_ids": ["dlv-1"]}`),
+		})
+		assert.ErrorContains(t, err, "failed to ack deliveries")
+	})
+}
@@ -1,4 +1,4 @@
-package main
+package handlers
 
 import (
 	"context"
@@ -10,8 +10,8 @@ import (
 	"github.com/pay-theory/streamer/pkg/streamer"
 )
 
-// registerHandlers registers all production handlers
-func registerHandlers(router *streamer.DefaultRouter) error {
+// RegisterHandlers registers all production handlers
+func RegisterHandlers(router *streamer.DefaultRouter) error {
 	// Register simple handlers
 	if err := router.Handle("echo", streamer.NewEchoHandler()); err != nil {
 		return fmt.Errorf("failed to register echo handler: %w", err)
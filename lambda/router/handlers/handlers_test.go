@@ -1,10 +1,9 @@
-package main
+package handlers
 
 import (
 	"context"
 	"encoding/json"
 	"errors"
-	"os"
 	"testing"
 	"time"
 
@@ -15,18 +14,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// TestMain sets up environment variables required for tests
-func TestMain(m *testing.M) {
-	// Set required environment variables for Lambda tests
-	os.Setenv("WEBSOCKET_ENDPOINT", "wss://mock.execute-api.us-east-1.amazonaws.com/dev")
-	os.Setenv("AWS_REGION", "us-east-1")
-	os.Setenv("_X_AMZN_TRACE_ID", "Root=1-mock-trace")
-
-	// Run tests
-	code := m.Run()
-	os.Exit(code)
-}
-
 // Mock Request Store
 type mockRequestStore struct {
 	mock.Mock
@@ -376,7 +363,7 @@ func TestHandler_Route(t *testing.T) {
 func TestRegisterHandlers(t *testing.T) {
 	router := streamer.NewRouter(nil, nil)
 
-	err := registerHandlers(router)
+	err := RegisterHandlers(router)
 	require.NoError(t, err)
 
 	// Test that all expected handlers are registered
@@ -407,7 +394,7 @@ func TestRegisterHandlers(t *testing.T) {
 
 			// Create router with mocks
 			testRouter := streamer.NewRouter(new(mockRequestStore), mockConnMgr)
-			err := registerHandlers(testRouter)
+			err := RegisterHandlers(testRouter)
 			require.NoError(t, err)
 
 			// Route should not return an error (handler exists)
@@ -419,7 +406,7 @@ func TestRegisterHandlers(t *testing.T) {
 
 func TestMiddleware(t *testing.T) {
 	t.Run("validation middleware", func(t *testing.T) {
-		middleware := validationMiddleware()
+		middleware := ValidationMiddleware()
 
 		// Test payload size validation
 		handler := &testHandler{
@@ -441,9 +428,11 @@ func TestMiddleware(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "Payload too large")
 
-		// Test context values are added to metadata
-		ctx := context.WithValue(context.Background(), "userId", "user-123")
-		ctx = context.WithValue(ctx, "tenantId", "tenant-456")
+		// Test principal identity is added to metadata
+		ctx := streamer.WithPrincipal(context.Background(), &streamer.Principal{
+			UserID:   "user-123",
+			TenantID: "tenant-456",
+		})
 
 		smallReq := &streamer.Request{
 			Payload:  []byte("small payload"),
@@ -457,7 +446,7 @@ func TestMiddleware(t *testing.T) {
 	})
 
 	t.Run("metrics middleware", func(t *testing.T) {
-		middleware := metricsMiddleware()
+		middleware := MetricsMiddleware(t.Logf)
 
 		handler := &testHandler{
 			processFunc: func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
@@ -792,112 +781,6 @@ func TestHandlerImplementations(t *testing.T) {
 	})
 }
 
-// TestLambdaHandler tests the main Lambda handler function
-func TestLambdaHandler(t *testing.T) {
-	// Mock stores and managers
-	mockReqStore := new(mockRequestStore)
-	mockConnMgr := new(mockConnectionManager)
-
-	// Create a test router
-	testRouter := streamer.NewRouter(mockReqStore, mockConnMgr)
-	testRouter.SetAsyncThreshold(5 * time.Second)
-
-	// Set the global router for testing
-	oldRouter := router
-	router = testRouter
-	defer func() { router = oldRouter }()
-
-	// Register test handlers
-	testRouter.Handle("test", &testHandler{
-		duration: 100 * time.Millisecond,
-		processFunc: func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
-			return &streamer.Result{Success: true}, nil
-		},
-	})
-
-	tests := []struct {
-		name         string
-		event        events.APIGatewayWebsocketProxyRequest
-		setupMocks   func()
-		wantStatus   int
-		wantResponse string
-	}{
-		{
-			name: "successful request",
-			event: events.APIGatewayWebsocketProxyRequest{
-				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
-					ConnectionID: "conn-123",
-					RouteKey:     "$default",
-				},
-				Body: `{"action": "test"}`,
-			},
-			setupMocks: func() {
-				mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
-			},
-			wantStatus: 200,
-		},
-		{
-			name: "request with authorizer context",
-			event: events.APIGatewayWebsocketProxyRequest{
-				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
-					ConnectionID: "conn-auth",
-					Authorizer: map[string]interface{}{
-						"userId":   "user-123",
-						"tenantId": "tenant-456",
-					},
-				},
-				Body: `{"action": "test"}`,
-			},
-			setupMocks: func() {
-				mockConnMgr.On("Send", mock.Anything, "conn-auth", mock.Anything).Return(nil)
-			},
-			wantStatus: 200,
-		},
-		{
-			name: "error in routing",
-			event: events.APIGatewayWebsocketProxyRequest{
-				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
-					ConnectionID: "conn-error",
-				},
-				Body: `{"action": "test"}`,
-			},
-			setupMocks: func() {
-				// Mock Send to return an error, which will cause Route to fail
-				mockConnMgr.On("Send", mock.Anything, "conn-error", mock.Anything).Return(errors.New("connection error"))
-			},
-			wantStatus:   500,
-			wantResponse: "Internal Server Error",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockReqStore.ExpectedCalls = nil
-			mockConnMgr.ExpectedCalls = nil
-
-			// Setup mocks
-			if tt.setupMocks != nil {
-				tt.setupMocks()
-			}
-
-			// Call handler
-			response, err := handler(context.Background(), tt.event)
-
-			// Check results
-			assert.NoError(t, err)
-			assert.Equal(t, tt.wantStatus, response.StatusCode)
-			if tt.wantResponse != "" {
-				assert.Equal(t, tt.wantResponse, response.Body)
-			}
-
-			// Verify mocks
-			mockReqStore.AssertExpectations(t)
-			mockConnMgr.AssertExpectations(t)
-		})
-	}
-}
-
 // TestReportHandlerEdgeCases tests edge cases for ReportHandler validation
 func TestReportHandlerEdgeCases(t *testing.T) {
 	handler := NewReportHandler()
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// ValidationMiddleware adds request validation
+func ValidationMiddleware() streamer.Middleware {
+	return func(next streamer.Handler) streamer.Handler {
+		return streamer.NewHandlerFunc(
+			func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+				// Add user/tenant IDs from the principal to request metadata
+				if principal, ok := streamer.PrincipalFromContext(ctx); ok {
+					req.Metadata["user_id"] = principal.UserID
+					req.Metadata["tenant_id"] = principal.TenantID
+				}
+
+				// Validate request size
+				if len(req.Payload) > 1024*1024 { // 1MB limit
+					return nil, streamer.NewError(streamer.ErrCodeValidation, "Payload too large (max 1MB)")
+				}
+
+				return next.Process(ctx, req)
+			},
+			next.EstimatedDuration(),
+			next.Validate,
+		)
+	}
+}
+
+// MetricsMiddleware adds basic metrics logging
+func MetricsMiddleware(logger func(format string, args ...interface{})) streamer.Middleware {
+	return func(next streamer.Handler) streamer.Handler {
+		return streamer.NewHandlerFunc(
+			func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+				start := time.Now()
+
+				result, err := next.Process(ctx, req)
+
+				duration := time.Since(start)
+				status := "success"
+				if err != nil {
+					status = "error"
+				}
+
+				logger("METRICS: action=%s, duration=%v, status=%s",
+					req.Action, duration, status)
+
+				return result, err
+			},
+			next.EstimatedDuration(),
+			next.Validate,
+		)
+	}
+}
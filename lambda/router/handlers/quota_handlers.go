@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// quotaAdminPermission is required to read or change tenant quotas
+const quotaAdminPermission = "admin:quotas"
+
+// RegisterAdminHandlers registers handlers for platform administrators
+func RegisterAdminHandlers(router *streamer.DefaultRouter, quotas store.QuotaStore) error {
+	if err := router.Handle("set_tenant_quota", NewSetQuotaHandler(quotas)); err != nil {
+		return fmt.Errorf("failed to register set quota handler: %w", err)
+	}
+
+	if err := router.Handle("get_tenant_quotas", NewGetQuotasHandler(quotas)); err != nil {
+		return fmt.Errorf("failed to register get quotas handler: %w", err)
+	}
+
+	return nil
+}
+
+// QuotaParams defines the structure for quota requests
+type QuotaParams struct {
+	TenantID      string `json:"tenant_id"`
+	Action        string `json:"action,omitempty"` // defaults to all actions
+	MaxActive     int    `json:"max_active"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Delete        bool   `json:"delete,omitempty"`
+}
+
+// requireQuotaAdmin checks that the caller may manage quotas
+func requireQuotaAdmin(ctx context.Context) (*streamer.Principal, error) {
+	principal, ok := streamer.PrincipalFromContext(ctx)
+	if !ok || !principal.HasPermission(quotaAdminPermission) {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Managing quotas requires the "+quotaAdminPermission+" permission")
+	}
+	return principal, nil
+}
+
+// SetQuotaHandler creates, replaces or deletes a tenant quota
+type SetQuotaHandler struct {
+	quotas store.QuotaStore
+}
+
+func NewSetQuotaHandler(quotas store.QuotaStore) *SetQuotaHandler {
+	return &SetQuotaHandler{quotas: quotas}
+}
+
+func (h *SetQuotaHandler) EstimatedDuration() time.Duration {
+	return 100 * time.Millisecond
+}
+
+func (h *SetQuotaHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params QuotaParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if params.TenantID == "" {
+		return errors.New("tenant_id is required")
+	}
+
+	if params.MaxActive < 0 || params.MaxConcurrent < 0 {
+		return errors.New("max_active and max_concurrent cannot be negative")
+	}
+
+	return nil
+}
+
+func (h *SetQuotaHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	principal, err := requireQuotaAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var params QuotaParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+	if params.Action == "" {
+		params.Action = store.QuotaAllActions
+	}
+
+	if params.Delete {
+		if err := h.quotas.Delete(ctx, params.TenantID, params.Action); err != nil {
+			return nil, fmt.Errorf("failed to delete quota: %w", err)
+		}
+		return &streamer.Result{
+			RequestID: req.ID,
+			Success:   true,
+			Data: map[string]interface{}{
+				"tenant_id": params.TenantID,
+				"action":    params.Action,
+				"deleted":   true,
+			},
+		}, nil
+	}
+
+	quota := &store.TenantQuota{
+		TenantID:      params.TenantID,
+		Action:        params.Action,
+		MaxActive:     params.MaxActive,
+		MaxConcurrent: params.MaxConcurrent,
+		UpdatedAt:     time.Now(),
+		UpdatedBy:     principal.UserID,
+	}
+	if err := h.quotas.Save(ctx, quota); err != nil {
+		return nil, fmt.Errorf("failed to save quota: %w", err)
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data:      quota,
+	}, nil
+}
+
+// GetQuotasHandler lists the quotas stored for a tenant
+type GetQuotasHandler struct {
+	quotas store.QuotaStore
+}
+
+func NewGetQuotasHandler(quotas store.QuotaStore) *GetQuotasHandler {
+	return &GetQuotasHandler{quotas: quotas}
+}
+
+func (h *GetQuotasHandler) EstimatedDuration() time.Duration {
+	return 100 * time.Millisecond
+}
+
+func (h *GetQuotasHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params QuotaParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if params.TenantID == "" {
+		return errors.New("tenant_id is required")
+	}
+
+	return nil
+}
+
+func (h *GetQuotasHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	if _, err := requireQuotaAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var params QuotaParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+
+	quotas, err := h.quotas.ListByTenant(ctx, params.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quotas: %w", err)
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data: map[string]interface{}{
+			"tenant_id": params.TenantID,
+			"quotas":    quotas,
+		},
+	}, nil
+}
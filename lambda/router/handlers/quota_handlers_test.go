@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock Quota Store
+type mockQuotaStore struct {
+	mock.Mock
+}
+
+func (m *mockQuotaStore) Get(ctx context.Context, tenantID, action string) (*store.TenantQuota, error) {
+	args := m.Called(ctx, tenantID, action)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.TenantQuota), args.Error(1)
+}
+
+func (m *mockQuotaStore) Save(ctx context.Context, quota *store.TenantQuota) error {
+	args := m.Called(ctx, quota)
+	return args.Error(0)
+}
+
+func (m *mockQuotaStore) Delete(ctx context.Context, tenantID, action string) error {
+	args := m.Called(ctx, tenantID, action)
+	return args.Error(0)
+}
+
+func (m *mockQuotaStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.TenantQuota, error) {
+	args := m.Called(ctx, tenantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*store.TenantQuota), args.Error(1)
+}
+
+func adminContext() context.Context {
+	return streamer.WithPrincipal(context.Background(), &streamer.Principal{
+		UserID:      "admin-1",
+		TenantID:    "platform",
+		Permissions: []string{quotaAdminPermission},
+	})
+}
+
+func TestSetQuotaHandler(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		handler := NewSetQuotaHandler(new(mockQuotaStore))
+
+		tests := []struct {
+			name    string
+			payload string
+			wantErr bool
+		}{
+			{"valid", `{"tenant_id": "tenant-1", "max_active": 10}`, false},
+			{"missing tenant", `{"max_active": 10}`, true},
+			{"negative limit", `{"tenant_id": "tenant-1", "max_concurrent": -1}`, true},
+			{"invalid json", `{`, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := handler.Validate(&streamer.Request{Payload: json.RawMessage(tt.payload)})
+				if tt.wantErr {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		}
+	})
+
+	t.Run("saves tenant-wide quota", func(t *testing.T) {
+		quotas := new(mockQuotaStore)
+		handler := NewSetQuotaHandler(quotas)
+
+		quotas.On("Save", mock.Anything, mock.MatchedBy(func(q *store.TenantQuota) bool {
+			return q.TenantID == "tenant-1" && q.Action == store.QuotaAllActions &&
+				q.MaxActive == 10 && q.MaxConcurrent == 2 && q.UpdatedBy == "admin-1"
+		})).Return(nil)
+
+		result, err := handler.Process(adminContext(), &streamer.Request{
+			ID:      "req-1",
+			Payload: json.RawMessage(`{"tenant_id": "tenant-1", "max_active": 10, "max_concurrent": 2}`),
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		quotas.AssertExpectations(t)
+	})
+
+	t.Run("deletes action quota", func(t *testing.T) {
+		quotas := new(mockQuotaStore)
+		handler := NewSetQuotaHandler(quotas)
+
+		quotas.On("Delete", mock.Anything, "tenant-1", "process_data").Return(nil)
+
+		result, err := handler.Process(adminContext(), &streamer.Request{
+			Payload: json.RawMessage(`{"tenant_id": "tenant-1", "action": "process_data", "delete": true}`),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, true, result.Data.(map[string]interface{})["deleted"])
+		quotas.AssertExpectations(t)
+	})
+
+	t.Run("requires admin permission", func(t *testing.T) {
+		quotas := new(mockQuotaStore)
+		handler := NewSetQuotaHandler(quotas)
+
+		ctx := streamer.WithPrincipal(context.Background(), &streamer.Principal{UserID: "user-1", TenantID: "tenant-1"})
+		_, err := handler.Process(ctx, &streamer.Request{
+			Payload: json.RawMessage(`{"tenant_id": "tenant-1", "max_active": 1000}`),
+		})
+
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+		quotas.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetQuotasHandler(t *testing.T) {
+	quotas := new(mockQuotaStore)
+	handler := NewGetQuotasHandler(quotas)
+
+	assert.Error(t, handler.Validate(&streamer.Request{Payload: json.RawMessage(`{}`)}))
+
+	quotas.On("ListByTenant", mock.Anything, "tenant-1").Return([]*store.TenantQuota{
+		{TenantID: "tenant-1", Action: "*", MaxActive: 100},
+	}, nil)
+
+	result, err := handler.Process(adminContext(), &streamer.Request{
+		Payload: json.RawMessage(`{"tenant_id": "tenant-1"}`),
+	})
+	require.NoError(t, err)
+	data := result.Data.(map[string]interface{})
+	assert.Len(t, data["quotas"], 1)
+
+	_, err = handler.Process(context.Background(), &streamer.Request{
+		Payload: json.RawMessage(`{"tenant_id": "tenant-1"}`),
+	})
+	assert.Error(t, err)
+}
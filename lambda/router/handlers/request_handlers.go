@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// maxListRequestsLimit bounds the requests returned by one list_requests call
+const maxListRequestsLimit = 100
+
+// defaultListRequestsLimit is the page size when list_requests sets no limit
+const defaultListRequestsLimit = 20
+
+// RegisterRequestHandlers registers the handlers clients use to look up
+// their async requests
+func RegisterRequestHandlers(router *streamer.DefaultRouter, requests store.RequestQueue) error {
+	if err := router.Handle("list_requests", NewListRequestsHandler(requests)); err != nil {
+		return fmt.Errorf("failed to register list requests handler: %w", err)
+	}
+
+	if err := router.Handle("get_request", NewGetRequestHandler(requests)); err != nil {
+		return fmt.Errorf("failed to register get request handler: %w", err)
+	}
+
+	return nil
+}
+
+// ListRequestsParams defines the structure for list_requests requests
+type ListRequestsParams struct {
+	Statuses  []store.RequestStatus `json:"statuses,omitempty"`
+	Action    string                `json:"action,omitempty"`
+	Since     time.Time             `json:"since,omitempty"`
+	Until     time.Time             `json:"until,omitempty"`
+	Limit     int                   `json:"limit,omitempty"`
+	NextToken string                `json:"next_token,omitempty"`
+}
+
+// GetRequestParams defines the structure for get_request requests
+type GetRequestParams struct {
+	RequestID string `json:"request_id"`
+}
+
+// RequestView is what a client sees of one of its async requests
+type RequestView struct {
+	RequestID         string                 `json:"request_id"`
+	Action            string                 `json:"action"`
+	Status            store.RequestStatus    `json:"status"`
+	CreatedAt         time.Time              `json:"created_at"`
+	ProcessingStarted *time.Time             `json:"processing_started,omitempty"`
+	ProcessingEnded   *time.Time             `json:"processing_ended,omitempty"`
+	Progress          float64                `json:"progress"`
+	ProgressMessage   string                 `json:"progress_message,omitempty"`
+	Result            map[string]interface{} `json:"result,omitempty"`
+	Error             string                 `json:"error,omitempty"`
+	CallbackURL       string                 `json:"callback_url,omitempty"`
+	Callbacks         []CallbackView         `json:"callbacks,omitempty"`
+}
+
+// CallbackView is one attempt to deliver a request's outcome to its callback URL
+type CallbackView struct {
+	Attempt     int       `json:"attempt"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Delivered   bool      `json:"delivered"`
+}
+
+// newRequestView returns the client's view of a request. The callback
+// secret is never shown.
+func newRequestView(req *store.AsyncRequest) *RequestView {
+	view := &RequestView{
+		RequestID:         req.RequestID,
+		Action:            req.Action,
+		Status:            req.Status,
+		CreatedAt:         req.CreatedAt,
+		ProcessingStarted: req.ProcessingStarted,
+		ProcessingEnded:   req.ProcessingEnded,
+		Progress:          req.Progress,
+		ProgressMessage:   req.ProgressMessage,
+		Result:            req.Result,
+		Error:             req.Error,
+		CallbackURL:       req.CallbackURL,
+	}
+	for _, delivery := range req.CallbackDeliveries {
+		view.Callbacks = append(view.Callbacks, CallbackView{
+			Attempt:     delivery.Attempt,
+			AttemptedAt: delivery.AttemptedAt,
+			StatusCode:  delivery.StatusCode,
+			Error:       delivery.Error,
+			Delivered:   delivery.Delivered,
+		})
+	}
+	return view
+}
+
+// requireCaller returns the principal a request history is looked up for.
+// Both IDs are needed so one tenant's requests are never shown to another.
+func requireCaller(ctx context.Context) (*streamer.Principal, error) {
+	principal, ok := streamer.PrincipalFromContext(ctx)
+	if !ok || principal.UserID == "" || principal.TenantID == "" {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Looking up requests requires an authenticated connection")
+	}
+	return principal, nil
+}
+
+// knownStatuses are the statuses list_requests can filter on
+var knownStatuses = map[store.RequestStatus]bool{
+	store.StatusPending:    true,
+	store.StatusProcessing: true,
+	store.StatusCompleted:  true,
+	store.StatusFailed:     true,
+	store.StatusCancelled:  true,
+	store.StatusRetrying:   true,
+}
+
+// ListRequestsHandler lists the caller's requests, newest first. Requests are
+// looked up by user, so they are found after the connection that submitted
+// them has closed.
+type ListRequestsHandler struct {
+	requests store.RequestQueue
+}
+
+func NewListRequestsHandler(requests store.RequestQueue) *ListRequestsHandler {
+	return &ListRequestsHandler{requests: requests}
+}
+
+func (h *ListRequestsHandler) EstimatedDuration() time.Duration {
+	return 200 * time.Millisecond
+}
+
+func (h *ListRequestsHandler) Validate(req *streamer.Request) error {
+	// Every parameter is optional
+	if len(req.Payload) == 0 {
+		return nil
+	}
+
+	var params ListRequestsParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	for _, status := range params.Statuses {
+		if !knownStatuses[status] {
+			return fmt.Errorf("unknown status %q", status)
+		}
+	}
+
+	if params.Limit < 0 {
+		return errors.New("limit cannot be negative")
+	}
+	if params.Limit > maxListRequestsLimit {
+		return fmt.Errorf("at most %d requests may be listed at once", maxListRequestsLimit)
+	}
+
+	if !params.Since.IsZero() && !params.Until.IsZero() && !params.Since.Before(params.Until) {
+		return errors.New("since must be before until")
+	}
+
+	return nil
+}
+
+func (h *ListRequestsHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	principal, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var params ListRequestsParams
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &params); err != nil {
+			return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+		}
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultListRequestsLimit
+	}
+
+	filter := store.RequestFilter{
+		TenantID:      principal.TenantID,
+		Statuses:      params.Statuses,
+		Action:        params.Action,
+		CreatedAfter:  params.Since,
+		CreatedBefore: params.Until,
+	}
+	page, err := h.requests.GetByUser(ctx, principal.UserID, filter, store.PageOptions{Limit: params.Limit, NextToken: params.NextToken})
+	if err != nil {
+		var validationErr *store.ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid next_token")
+		}
+		return nil, fmt.Errorf("failed to list requests: %w", err)
+	}
+
+	views := make([]*RequestView, len(page.Items))
+	for i, item := range page.Items {
+		views[i] = newRequestView(item)
+	}
+
+	data := map[string]interface{}{
+		"requests": views,
+	}
+	if page.NextToken != "" {
+		data["next_token"] = page.NextToken
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data:      data,
+	}, nil
+}
+
+// GetRequestHandler returns one of the caller's requests with its progress
+// and result or error
+type GetRequestHandler struct {
+	requests store.RequestQueue
+}
+
+func NewGetRequestHandler(requests store.RequestQueue) *GetRequestHandler {
+	return &GetRequestHandler{requests: requests}
+}
+
+func (h *GetRequestHandler) EstimatedDuration() time.Duration {
+	return 100 * time.Millisecond
+}
+
+func (h *GetRequestHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params GetRequestParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if params.RequestID == "" {
+		return errors.New("request_id is required")
+	}
+
+	return nil
+}
+
+func (h *GetRequestHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	principal, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var params GetRequestParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+
+	asyncReq, err := h.requests.Get(ctx, params.RequestID)
+	if err != nil && !store.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+
+	// Another user's request is reported as missing so its ID leaks nothing
+	if err != nil || asyncReq.UserID != principal.UserID || asyncReq.TenantID != principal.TenantID {
+		return nil, streamer.NewError(streamer.ErrCodeNotFound, "Request not found")
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data:      newRequestView(asyncReq),
+	}, nil
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/memory"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHistoryQueue returns a queue holding requests for two users and two tenants
+func newHistoryQueue(t *testing.T) store.RequestQueue {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	queue := memory.NewRequestQueue()
+
+	for _, req := range []*store.AsyncRequest{
+		{RequestID: "req-1", ConnectionID: "conn-old", UserID: "user-1", TenantID: "tenant-1", Action: "generate_report", CreatedAt: now.Add(-3 * time.Hour),
+			CallbackURL: "https://example.com/hooks", CallbackSecret: "s3cret"},
+		{RequestID: "req-2", ConnectionID: "conn-old", UserID: "user-1", TenantID: "tenant-1", Action: "export_data", CreatedAt: now.Add(-2 * time.Hour)},
+		{RequestID: "req-3", ConnectionID: "conn-new", UserID: "user-1", TenantID: "tenant-1", Action: "generate_report", CreatedAt: now.Add(-time.Hour)},
+		{RequestID: "req-4", ConnectionID: "conn-other", UserID: "user-2", TenantID: "tenant-1", Action: "generate_report", CreatedAt: now},
+		{RequestID: "req-5", ConnectionID: "conn-other", UserID: "user-1", TenantID: "tenant-2", Action: "generate_report", CreatedAt: now},
+	} {
+		require.NoError(t, queue.Enqueue(ctx, req))
+	}
+	require.NoError(t, queue.UpdateStatus(ctx, "req-1", store.StatusProcessing, ""))
+	require.NoError(t, queue.CompleteRequest(ctx, "req-1", map[string]interface{}{"rows": 10}))
+	require.NoError(t, queue.RecordCallbackDelivery(ctx, "req-1", store.CallbackDelivery{
+		Attempt: 1, Status: store.StatusCompleted, AttemptedAt: now, StatusCode: 200, Delivered: true,
+	}))
+	require.NoError(t, queue.FailRequest(ctx, "req-2", "export timed out"))
+	require.NoError(t, queue.UpdateProgress(ctx, "req-3", 40, "Halfway there", nil))
+	return queue
+}
+
+// callerContext returns a context authenticated as user-1 of tenant-1
+func callerContext() context.Context {
+	return streamer.WithPrincipal(context.Background(), &streamer.Principal{UserID: "user-1", TenantID: "tenant-1"})
+}
+
+// requestIDs returns the IDs of a list_requests result
+func requestIDs(t *testing.T, result *streamer.Result) []string {
+	t.Helper()
+	views := result.Data.(map[string]interface{})["requests"].([]*RequestView)
+	ids := make([]string, len(views))
+	for i, view := range views {
+		ids[i] = view.RequestID
+	}
+	return ids
+}
+
+func TestListRequestsHandler(t *testing.T) {
+	t.Run("validation", func(t *testing.T) {
+		handler := NewListRequestsHandler(memory.NewRequestQueue())
+
+		tests := []struct {
+			name    string
+			payload string
+			wantErr bool
+		}{
+			{"no payload", ``, false},
+			{"filters", `{"statuses": ["COMPLETED", "FAILED"], "action": "generate_report", "limit": 10}`, false},
+			{"time range", `{"since": "2024-01-01T00:00:00Z", "until": "2024-02-01T00:00:00Z"}`, false},
+			{"unknown status", `{"statuses": ["DONE"]}`, true},
+			{"negative limit", `{"limit": -1}`, true},
+			{"limit too large", `{"limit": 1000}`, true},
+			{"empty time range", `{"since": "2024-02-01T00:00:00Z", "until": "2024-01-01T00:00:00Z"}`, true},
+			{"invalid json", `{`, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := handler.Validate(&streamer.Request{Payload: json.RawMessage(tt.payload)})
+				if tt.wantErr {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		}
+	})
+
+	t.Run("lists the caller's requests newest first", func(t *testing.T) {
+		handler := NewListRequestsHandler(newHistoryQueue(t))
+
+		result, err := handler.Process(callerContext(), &streamer.Request{ID: "list-1"})
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"req-3", "req-2", "req-1"}, requestIDs(t, result))
+		assert.NotContains(t, result.Data, "next_token")
+
+		views := result.Data.(map[string]interface{})["requests"].([]*RequestView)
+		assert.Equal(t, float64(40), views[0].Progress)
+		assert.Equal(t, "Halfway there", views[0].ProgressMessage)
+		assert.Equal(t, "export timed out", views[1].Error)
+		assert.Equal(t, 10, views[2].Result["rows"])
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		handler := NewListRequestsHandler(newHistoryQueue(t))
+		since := time.Now().Add(-150 * time.Minute).Format(time.RFC3339Nano)
+
+		tests := []struct {
+			name    string
+			payload string
+			want    []string
+		}{
+			{"status", `{"statuses": ["COMPLETED", "FAILED"]}`, []string{"req-2", "req-1"}},
+			{"action", `{"action": "generate_report"}`, []string{"req-3", "req-1"}},
+			{"since", `{"since": "` + since + `"}`, []string{"req-3", "req-2"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result, err := handler.Process(callerContext(), &streamer.Request{Payload: json.RawMessage(tt.payload)})
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, requestIDs(t, result))
+			})
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		handler := NewListRequestsHandler(newHistoryQueue(t))
+
+		first, err := handler.Process(callerContext(), &streamer.Request{Payload: json.RawMessage(`{"limit": 2}`)})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"req-3", "req-2"}, requestIDs(t, first))
+		token, ok := first.Data.(map[string]interface{})["next_token"].(string)
+		require.True(t, ok)
+
+		second, err := handler.Process(callerContext(), &streamer.Request{Payload: json.RawMessage(`{"limit": 2, "next_token": "` + token + `"}`)})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"req-1"}, requestIDs(t, second))
+		assert.NotContains(t, second.Data, "next_token")
+
+		_, err = handler.Process(callerContext(), &streamer.Request{Payload: json.RawMessage(`{"next_token": "bogus"}`)})
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeValidation, streamerErr.Code)
+	})
+
+	t.Run("requires an authenticated caller", func(t *testing.T) {
+		handler := NewListRequestsHandler(newHistoryQueue(t))
+
+		_, err := handler.Process(context.Background(), &streamer.Request{})
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+	})
+}
+
+func TestGetRequestHandler(t *testing.T) {
+	handler := NewGetRequestHandler(newHistoryQueue(t))
+
+	assert.Error(t, handler.Validate(&streamer.Request{Payload: json.RawMessage(`{}`)}))
+	assert.NoError(t, handler.Validate(&streamer.Request{Payload: json.RawMessage(`{"request_id": "req-1"}`)}))
+
+	t.Run("returns the caller's request", func(t *testing.T) {
+		result, err := handler.Process(callerContext(), &streamer.Request{
+			ID:      "get-1",
+			Payload: json.RawMessage(`{"request_id": "req-1"}`),
+		})
+		require.NoError(t, err)
+		view := result.Data.(*RequestView)
+		assert.Equal(t, "req-1", view.RequestID)
+		assert.Equal(t, store.StatusCompleted, view.Status)
+		assert.Equal(t, 10, view.Result["rows"])
+		assert.NotNil(t, view.ProcessingEnded)
+		assert.Equal(t, "https://example.com/hooks", view.CallbackURL)
+		require.Len(t, view.Callbacks, 1)
+		assert.Equal(t, 200, view.Callbacks[0].StatusCode)
+		assert.True(t, view.Callbacks[0].Delivered)
+
+		// The callback secret is never shown
+		encoded, err := json.Marshal(view)
+		require.NoError(t, err)
+		assert.NotContains(t, string(encoded), "s3cret")
+	})
+
+	for name, requestID := range map[string]string{
+		"missing request":          "req-missing",
+		"another user's request":   "req-4",
+		"another tenant's request": "req-5",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := handler.Process(callerContext(), &streamer.Request{
+				Payload: json.RawMessage(`{"request_id": "` + requestID + `"}`),
+			})
+			var streamerErr *streamer.Error
+			require.True(t, errors.As(err, &streamerErr))
+			assert.Equal(t, streamer.ErrCodeNotFound, streamerErr.Code)
+		})
+	}
+
+	t.Run("requires an authenticated caller", func(t *testing.T) {
+		_, err := handler.Process(context.Background(), &streamer.Request{Payload: json.RawMessage(`{"request_id": "req-1"}`)})
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+	})
+}
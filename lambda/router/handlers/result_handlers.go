@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// defaultFetchChunkSize keeps each base64 chunk frame under the 128KB frame limit
+const defaultFetchChunkSize = 64 * 1024
+
+// RegisterResultHandlers registers the handler for downloading offloaded results
+func RegisterResultHandlers(router *streamer.DefaultRouter, results store.ResultStore, signer *streamer.ResultTokenSigner, connManager streamer.ConnectionManager) error {
+	if err := router.Handle("fetch_result", NewFetchResultHandler(results, signer, connManager)); err != nil {
+		return fmt.Errorf("failed to register fetch result handler: %w", err)
+	}
+	return nil
+}
+
+// FetchResultParams defines the structure for fetch_result requests
+type FetchResultParams struct {
+	Token string `json:"token"`
+}
+
+// FetchResultHandler streams an offloaded result back to the caller as chunk
+// messages whose message_id is the fetch request ID, then responds with a summary.
+type FetchResultHandler struct {
+	results     store.ResultStore
+	signer      *streamer.ResultTokenSigner
+	connManager streamer.ConnectionManager
+	chunkSize   int
+}
+
+func NewFetchResultHandler(results store.ResultStore, signer *streamer.ResultTokenSigner, connManager streamer.ConnectionManager) *FetchResultHandler {
+	return &FetchResultHandler{
+		results:     results,
+		signer:      signer,
+		connManager: connManager,
+		chunkSize:   defaultFetchChunkSize,
+	}
+}
+
+func (h *FetchResultHandler) EstimatedDuration() time.Duration {
+	return 500 * time.Millisecond
+}
+
+func (h *FetchResultHandler) Validate(req *streamer.Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+
+	var params FetchResultParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return fmt.Errorf("invalid payload format: %w", err)
+	}
+
+	if params.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+func (h *FetchResultHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	var params FetchResultParams
+	if err := json.Unmarshal(req.Payload, &params); err != nil {
+		return nil, streamer.NewError(streamer.ErrCodeValidation, "Invalid payload format")
+	}
+
+	claims, err := h.signer.Verify(params.Token)
+	if err != nil {
+		if errors.Is(err, streamer.ErrResultTokenExpired) {
+			return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Result token has expired")
+		}
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Invalid result token")
+	}
+
+	// A token only works for the tenant whose request produced the result
+	if principal, ok := streamer.PrincipalFromContext(ctx); ok && claims.TenantID != "" && principal.TenantID != claims.TenantID {
+		return nil, streamer.NewError(streamer.ErrCodeUnauthorized, "Result belongs to another tenant")
+	}
+
+	data, err := h.results.Get(ctx, claims.Key)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return nil, streamer.NewError(streamer.ErrCodeNotFound, "Result not found")
+		}
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+
+	total := (len(data) + h.chunkSize - 1) / h.chunkSize
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * h.chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := types.NewChunkMessage(req.ID, seq, total, data[seq*h.chunkSize:end])
+		if err := h.connManager.Send(ctx, req.ConnectionID, chunk); err != nil {
+			return nil, fmt.Errorf("failed to send result chunk %d/%d: %w", seq+1, total, err)
+		}
+	}
+
+	return &streamer.Result{
+		RequestID: req.ID,
+		Success:   true,
+		Data: map[string]interface{}{
+			"message_id": req.ID,
+			"request_id": claims.RequestID,
+			"size":       len(data),
+			"chunks":     total,
+		},
+	}, nil
+}
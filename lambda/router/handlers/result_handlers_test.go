@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store/results"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/pay-theory/streamer/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchResultHandler(t *testing.T) {
+	ctx := context.Background()
+	resultStore, err := results.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	signer, err := streamer.NewResultTokenSigner([]byte(strings.Repeat("k", 32)), time.Minute)
+	require.NoError(t, err)
+
+	stored := []byte(`{"success":true,"predictions":"` + strings.Repeat("0.5,", 500) + `"}`)
+	require.NoError(t, resultStore.Put(ctx, "tenant-1/req-1.json", stored))
+
+	token, _, err := signer.Sign(streamer.ResultTokenClaims{Key: "tenant-1/req-1.json", RequestID: "req-1", TenantID: "tenant-1"})
+	require.NoError(t, err)
+
+	tenantContext := func(tenantID string) context.Context {
+		return streamer.WithPrincipal(ctx, &streamer.Principal{UserID: "user-1", TenantID: tenantID})
+	}
+	fetchRequest := func(token string) *streamer.Request {
+		return &streamer.Request{
+			ID:           "fetch-1",
+			ConnectionID: "conn-1",
+			Payload:      json.RawMessage(`{"token": "` + token + `"}`),
+		}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		handler := NewFetchResultHandler(resultStore, signer, new(mockConnectionManager))
+		assert.Error(t, handler.Validate(&streamer.Request{Payload: json.RawMessage(`{}`)}))
+		assert.NoError(t, handler.Validate(fetchRequest(token)))
+	})
+
+	t.Run("streams result in chunks", func(t *testing.T) {
+		connManager := new(mockConnectionManager)
+		handler := NewFetchResultHandler(resultStore, signer, connManager)
+		handler.chunkSize = 512
+
+		var chunks []*types.ChunkMessage
+		connManager.On("Send", mock.Anything, "conn-1", mock.AnythingOfType("*types.ChunkMessage")).Run(func(args mock.Arguments) {
+			chunks = append(chunks, args.Get(2).(*types.ChunkMessage))
+		}).Return(nil)
+
+		result, err := handler.Process(tenantContext("tenant-1"), fetchRequest(token))
+		require.NoError(t, err)
+
+		data := result.Data.(map[string]interface{})
+		assert.Equal(t, len(stored), data["size"])
+		assert.Equal(t, len(chunks), data["chunks"])
+		require.Greater(t, len(chunks), 1)
+
+		var reassembled bytes.Buffer
+		for i, chunk := range chunks {
+			assert.Equal(t, "fetch-1", chunk.MessageID)
+			assert.Equal(t, i, chunk.Sequence)
+			assert.Equal(t, len(chunks), chunk.Total)
+			fragment, err := base64.StdEncoding.DecodeString(chunk.Data)
+			require.NoError(t, err)
+			reassembled.Write(fragment)
+		}
+		assert.Equal(t, stored, reassembled.Bytes())
+	})
+
+	t.Run("rejects other tenants", func(t *testing.T) {
+		connManager := new(mockConnectionManager)
+		handler := NewFetchResultHandler(resultStore, signer, connManager)
+
+		_, err := handler.Process(tenantContext("tenant-2"), fetchRequest(token))
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+		connManager.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects invalid token", func(t *testing.T) {
+		handler := NewFetchResultHandler(resultStore, signer, new(mockConnectionManager))
+
+		_, err := handler.Process(tenantContext("tenant-1"), fetchRequest(token+"x"))
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeUnauthorized, streamerErr.Code)
+	})
+
+	t.Run("missing result", func(t *testing.T) {
+		handler := NewFetchResultHandler(resultStore, signer, new(mockConnectionManager))
+		missing, _, err := signer.Sign(streamer.ResultTokenClaims{Key: "tenant-1/gone.json", TenantID: "tenant-1"})
+		require.NoError(t, err)
+
+		_, err = handler.Process(tenantContext("tenant-1"), fetchRequest(missing))
+		var streamerErr *streamer.Error
+		require.True(t, errors.As(err, &streamerErr))
+		assert.Equal(t, streamer.ErrCodeNotFound, streamerErr.Code)
+	})
+}
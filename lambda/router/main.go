@@ -7,6 +7,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -16,6 +17,8 @@ import (
 
 	"github.com/pay-theory/dynamorm/pkg/session"
 	dynamormStore "github.com/pay-theory/streamer/internal/store/dynamorm"
+	"github.com/pay-theory/streamer/lambda/router/handlers"
+	"github.com/pay-theory/streamer/lambda/shared"
 	"github.com/pay-theory/streamer/pkg/connection"
 	"github.com/pay-theory/streamer/pkg/streamer"
 )
@@ -44,7 +47,7 @@ func init() {
 
 	requestsTable = os.Getenv("REQUESTS_TABLE")
 	if requestsTable == "" {
-		requestsTable = "streamer_requests"
+		requestsTable = "streamer_requests_v2"
 	}
 
 	subscriptionsTable = os.Getenv("SUBSCRIPTIONS_TABLE")
@@ -94,25 +97,148 @@ func init() {
 	connManager := connection.NewManager(connStore, apiGatewayAdapter, apiGatewayEndpoint)
 	connManager.SetLogger(logger.Printf)
 
+	// Messages larger than a frame are delivered as chunks
+	if size, err := strconv.Atoi(os.Getenv("MAX_FRAME_SIZE")); err == nil && size > 0 {
+		connManager.SetMaxFrameSize(size)
+	}
+
+	// Messages above the threshold are compressed for connections that negotiated an encoding
+	if threshold, err := strconv.Atoi(os.Getenv("COMPRESSION_THRESHOLD")); err == nil {
+		connManager.SetCompressionThreshold(threshold)
+	}
+
+	// Share breaker state with every instance through the connection records
+	breakerConfig, err := shared.LoadCircuitBreakerConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load circuit breaker config: %v", err)
+	}
+	connManager.SetCircuitBreaker(connection.NewCircuitBreaker(factory.CircuitBreakerStore(), breakerConfig))
+
+	// Number each connection's messages from the counter on its record, so
+	// every instance continues the same sequence
+	connManager.SetSequenceStore(factory.SequenceStore())
+
+	// Track messages that clients opted in to ack so the ack action can clear them
+	deliveryConfig, err := shared.LoadDeliveryConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load delivery config: %v", err)
+	}
+	connManager.SetDeliveryTracker(connection.NewDeliveryTracker(factory.DeliveryStore(), deliveryConfig))
+
 	// Create router
 	router = streamer.NewRouter(queueAdapter, connManager)
 	router.SetAsyncThreshold(5 * time.Second)
 
+	// Resolve the caller of each message from the connection record saved at $connect
+	router.SetPrincipalResolver(streamer.NewConnectionPrincipalResolver(connStore))
+
+	// Client pings and pongs keep the connection from being reaped as idle
+	router.SetHeartbeatRecorder(connStore)
+
+	// Throttle callers using buckets shared by every router instance
+	rateLimitConfig, err := loadRateLimitConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load rate limit config: %v", err)
+	}
+	router.SetRateLimiter(streamer.NewTokenBucketLimiter(factory.RateLimitStore(), rateLimitConfig))
+
+	// Cap the async work each tenant may have outstanding
+	quotaConfig, err := loadQuotaConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load quota config: %v", err)
+	}
+	router.SetQuotaChecker(streamer.NewTenantQuotaEnforcer(factory.QuotaStore(), reqQueue, quotaConfig))
+
+	// Seal callback secrets so requests are never stored with them in plaintext
+	secretBox, err := shared.LoadSecretBox()
+	if err != nil {
+		logger.Fatalf("Failed to load callback secret key: %v", err)
+	}
+	router.SetSecretBox(secretBox)
+
 	// Apply middleware
 	router.SetMiddleware(
 		streamer.LoggingMiddleware(logger.Printf),
-		validationMiddleware(),
-		metricsMiddleware(),
+		handlers.ValidationMiddleware(),
+		handlers.MetricsMiddleware(logger.Printf),
 	)
 
 	// Register handlers
-	if err := registerHandlers(router); err != nil {
+	if err := handlers.RegisterHandlers(router); err != nil {
 		logger.Fatalf("Failed to register handlers: %v", err)
 	}
 
+	if err := handlers.RegisterAdminHandlers(router, factory.QuotaStore()); err != nil {
+		logger.Fatalf("Failed to register admin handlers: %v", err)
+	}
+
+	if err := handlers.RegisterDeliveryHandlers(router, connManager); err != nil {
+		logger.Fatalf("Failed to register delivery handlers: %v", err)
+	}
+
+	if err := handlers.RegisterRequestHandlers(router, reqQueue); err != nil {
+		logger.Fatalf("Failed to register request handlers: %v", err)
+	}
+
+	// Let clients replace their token before it expires
+	verifierConfig, err := shared.LoadVerifierConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load JWT verifier config: %v", err)
+	}
+	if verifierConfig.Configured() {
+		verifier, err := shared.NewVerifier(verifierConfig)
+		if err != nil {
+			logger.Fatalf("Failed to create JWT verifier: %v", err)
+		}
+		if err := handlers.RegisterAuthHandlers(router, verifier, connStore); err != nil {
+			logger.Fatalf("Failed to register auth handlers: %v", err)
+		}
+	} else {
+		logger.Println("WARNING: JWT_PUBLIC_KEY and JWKS settings are not set; auth.refresh is disabled")
+	}
+
+	// Serve results the processor offloaded to the result store
+	resultStore, resultSigner, err := shared.LoadResultStore(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to load result store: %v", err)
+	}
+	if resultStore != nil {
+		if err := handlers.RegisterResultHandlers(router, resultStore, resultSigner, connManager); err != nil {
+			logger.Fatalf("Failed to register result handlers: %v", err)
+		}
+	}
+
 	logger.Println("Router Lambda initialized successfully")
 }
 
+// defaultRateLimitConfig is applied when RATE_LIMIT_CONFIG is not set
+var defaultRateLimitConfig = streamer.RateLimitConfig{
+	Default: streamer.RateLimitPolicy{
+		Connection: streamer.RateLimit{Rate: 10, Burst: 20},
+		User:       streamer.RateLimit{Rate: 20, Burst: 40},
+		Tenant:     streamer.RateLimit{Rate: 200, Burst: 400},
+	},
+}
+
+// loadRateLimitConfig reads the JSON rate limit config from RATE_LIMIT_CONFIG
+func loadRateLimitConfig() (streamer.RateLimitConfig, error) {
+	raw := os.Getenv("RATE_LIMIT_CONFIG")
+	if raw == "" {
+		return defaultRateLimitConfig, nil
+	}
+	return streamer.ParseRateLimitConfig([]byte(raw))
+}
+
+// loadQuotaConfig reads the default tenant quotas from QUOTA_CONFIG.
+// Without it, only quotas stored in the tenant quotas table are enforced.
+func loadQuotaConfig() (streamer.QuotaConfig, error) {
+	raw := os.Getenv("QUOTA_CONFIG")
+	if raw == "" {
+		return streamer.QuotaConfig{}, nil
+	}
+	return streamer.ParseQuotaConfig([]byte(raw))
+}
+
 // isRunningTests checks if we're running under go test
 func isRunningTests() bool {
 	for _, arg := range os.Args {
@@ -129,15 +255,18 @@ func handler(ctx context.Context, event events.APIGatewayWebsocketProxyRequest)
 		event.RequestContext.ConnectionID,
 		event.RequestContext.RouteKey)
 
-	// Add request metadata
+	// Seed the principal from a custom authorizer if one is configured.
+	// The router replaces it with the connection record when it has a resolver.
 	if event.RequestContext.Authorizer != nil {
-		// Extract user/tenant info from authorizer if available
 		if authData, ok := event.RequestContext.Authorizer.(map[string]interface{}); ok {
-			if userID, ok := authData["userId"].(string); ok {
-				ctx = context.WithValue(ctx, "userId", userID)
-			}
-			if tenantID, ok := authData["tenantId"].(string); ok {
-				ctx = context.WithValue(ctx, "tenantId", tenantID)
+			userID, _ := authData["userId"].(string)
+			tenantID, _ := authData["tenantId"].(string)
+			if userID != "" && tenantID != "" {
+				ctx = streamer.WithPrincipal(ctx, &streamer.Principal{
+					ConnectionID: event.RequestContext.ConnectionID,
+					UserID:       userID,
+					TenantID:     tenantID,
+				})
 			}
 		}
 	}
@@ -157,58 +286,6 @@ func handler(ctx context.Context, event events.APIGatewayWebsocketProxyRequest)
 	}, nil
 }
 
-// validationMiddleware adds request validation
-func validationMiddleware() streamer.Middleware {
-	return func(next streamer.Handler) streamer.Handler {
-		return streamer.NewHandlerFunc(
-			func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
-				// Add user/tenant IDs from context to request metadata
-				if userID, ok := ctx.Value("userId").(string); ok {
-					req.Metadata["user_id"] = userID
-				}
-				if tenantID, ok := ctx.Value("tenantId").(string); ok {
-					req.Metadata["tenant_id"] = tenantID
-				}
-
-				// Validate request size
-				if len(req.Payload) > 1024*1024 { // 1MB limit
-					return nil, streamer.NewError(streamer.ErrCodeValidation, "Payload too large (max 1MB)")
-				}
-
-				return next.Process(ctx, req)
-			},
-			next.EstimatedDuration(),
-			next.Validate,
-		)
-	}
-}
-
-// metricsMiddleware adds basic metrics logging
-func metricsMiddleware() streamer.Middleware {
-	return func(next streamer.Handler) streamer.Handler {
-		return streamer.NewHandlerFunc(
-			func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
-				start := time.Now()
-
-				result, err := next.Process(ctx, req)
-
-				duration := time.Since(start)
-				status := "success"
-				if err != nil {
-					status = "error"
-				}
-
-				logger.Printf("METRICS: action=%s, duration=%v, status=%s",
-					req.Action, duration, status)
-
-				return result, err
-			},
-			next.EstimatedDuration(),
-			next.Validate,
-		)
-	}
-}
-
 func main() {
 	lambda.Start(handler)
 }
@@ -21,7 +21,7 @@ func main() {
 	// Load configuration from environment
 	cfg := &HandlerConfig{
 		ConnectionsTable:   getEnv("CONNECTIONS_TABLE", "streamer_connections"),
-		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests"),
+		RequestsTable:      getEnv("REQUESTS_TABLE", "streamer_requests_v2"),
 		SubscriptionsTable: getEnv("SUBSCRIPTIONS_TABLE", "streamer_subscriptions"),
 		WebSocketEndpoint:  os.Getenv("WEBSOCKET_ENDPOINT"),
 		AsyncThreshold:     5 * time.Second,
@@ -0,0 +1,180 @@
+//go:build !lift
+// +build !lift
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/pay-theory/streamer/pkg/streamer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestMain sets up environment variables required for tests
+func TestMain(m *testing.M) {
+	// Set required environment variables for Lambda tests
+	os.Setenv("WEBSOCKET_ENDPOINT", "wss://mock.execute-api.us-east-1.amazonaws.com/dev")
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("_X_AMZN_TRACE_ID", "Root=1-mock-trace")
+
+	// Run tests
+	code := m.Run()
+	os.Exit(code)
+}
+
+// Mock Request Store
+type mockRequestStore struct {
+	mock.Mock
+}
+
+func (m *mockRequestStore) Enqueue(ctx context.Context, request *streamer.Request) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+// Mock Connection Manager
+type mockConnectionManager struct {
+	mock.Mock
+}
+
+func (m *mockConnectionManager) Send(ctx context.Context, connectionID string, message interface{}) error {
+	args := m.Called(ctx, connectionID, message)
+	return args.Error(0)
+}
+
+// Test handler for actual handler tests
+type testHandler struct {
+	validateFunc func(req *streamer.Request) error
+	processFunc  func(ctx context.Context, req *streamer.Request) (*streamer.Result, error)
+	duration     time.Duration
+}
+
+func (h *testHandler) EstimatedDuration() time.Duration {
+	return h.duration
+}
+
+func (h *testHandler) Validate(req *streamer.Request) error {
+	if h.validateFunc != nil {
+		return h.validateFunc(req)
+	}
+	return nil
+}
+
+func (h *testHandler) Process(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+	if h.processFunc != nil {
+		return h.processFunc(ctx, req)
+	}
+	return &streamer.Result{Success: true}, nil
+}
+
+// TestLambdaHandler tests the main Lambda handler function
+func TestLambdaHandler(t *testing.T) {
+	// Mock stores and managers
+	mockReqStore := new(mockRequestStore)
+	mockConnMgr := new(mockConnectionManager)
+
+	// Create a test router
+	testRouter := streamer.NewRouter(mockReqStore, mockConnMgr)
+	testRouter.SetAsyncThreshold(5 * time.Second)
+
+	// Set the global router for testing
+	oldRouter := router
+	router = testRouter
+	defer func() { router = oldRouter }()
+
+	// Register test handlers
+	testRouter.Handle("test", &testHandler{
+		duration: 100 * time.Millisecond,
+		processFunc: func(ctx context.Context, req *streamer.Request) (*streamer.Result, error) {
+			return &streamer.Result{Success: true}, nil
+		},
+	})
+
+	tests := []struct {
+		name         string
+		event        events.APIGatewayWebsocketProxyRequest
+		setupMocks   func()
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name: "successful request",
+			event: events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "conn-123",
+					RouteKey:     "$default",
+				},
+				Body: `{"action": "test"}`,
+			},
+			setupMocks: func() {
+				mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+			},
+			wantStatus: 200,
+		},
+		{
+			name: "request with authorizer context",
+			event: events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "conn-auth",
+					Authorizer: map[string]interface{}{
+						"userId":   "user-123",
+						"tenantId": "tenant-456",
+					},
+				},
+				Body: `{"action": "test"}`,
+			},
+			setupMocks: func() {
+				mockConnMgr.On("Send", mock.Anything, "conn-auth", mock.Anything).Return(nil)
+			},
+			wantStatus: 200,
+		},
+		{
+			name: "error in routing",
+			event: events.APIGatewayWebsocketProxyRequest{
+				RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+					ConnectionID: "conn-error",
+				},
+				Body: `{"action": "test"}`,
+			},
+			setupMocks: func() {
+				// Mock Send to return an error, which will cause Route to fail
+				mockConnMgr.On("Send", mock.Anything, "conn-error", mock.Anything).Return(errors.New("connection error"))
+			},
+			wantStatus:   500,
+			wantResponse: "Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Reset mocks
+			mockReqStore.ExpectedCalls = nil
+			mockConnMgr.ExpectedCalls = nil
+
+			// Setup mocks
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			// Call handler
+			response, err := handler(context.Background(), tt.event)
+
+			// Check results
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, response.StatusCode)
+			if tt.wantResponse != "" {
+				assert.Equal(t, tt.wantResponse, response.Body)
+			}
+
+			// Verify mocks
+			mockReqStore.AssertExpectations(t)
+			mockConnMgr.AssertExpectations(t)
+		})
+	}
+}
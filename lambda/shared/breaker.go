@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+// LoadCircuitBreakerConfig reads circuit breaker settings from the environment:
+//
+//	BREAKER_FAILURE_THRESHOLD  consecutive failed sends that open a breaker (default 3)
+//	BREAKER_OPEN_TIMEOUT       how long a breaker stays open, e.g. "1m" (default 30s)
+//	BREAKER_HALF_OPEN_PROBES   sends admitted per window while half-open (default 1)
+//	BREAKER_CACHE_TTL          how long stored state is trusted, e.g. "5s" (default 2s)
+//
+// Unset values use the connection package defaults.
+func LoadCircuitBreakerConfig() (connection.CircuitBreakerConfig, error) {
+	var config connection.CircuitBreakerConfig
+	var err error
+
+	if raw := os.Getenv("BREAKER_FAILURE_THRESHOLD"); raw != "" {
+		if config.FailureThreshold, err = strconv.Atoi(raw); err != nil {
+			return config, fmt.Errorf("invalid BREAKER_FAILURE_THRESHOLD: %w", err)
+		}
+	}
+	if raw := os.Getenv("BREAKER_OPEN_TIMEOUT"); raw != "" {
+		if config.OpenTimeout, err = time.ParseDuration(raw); err != nil {
+			return config, fmt.Errorf("invalid BREAKER_OPEN_TIMEOUT: %w", err)
+		}
+	}
+	if raw := os.Getenv("BREAKER_HALF_OPEN_PROBES"); raw != "" {
+		if config.HalfOpenProbes, err = strconv.Atoi(raw); err != nil {
+			return config, fmt.Errorf("invalid BREAKER_HALF_OPEN_PROBES: %w", err)
+		}
+	}
+	if raw := os.Getenv("BREAKER_CACHE_TTL"); raw != "" {
+		if config.CacheTTL, err = time.ParseDuration(raw); err != nil {
+			return config, fmt.Errorf("invalid BREAKER_CACHE_TTL: %w", err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+func TestLoadCircuitBreakerConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := LoadCircuitBreakerConfig()
+		require.NoError(t, err)
+		assert.Equal(t, connection.CircuitBreakerConfig{}, config)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("BREAKER_FAILURE_THRESHOLD", "5")
+		t.Setenv("BREAKER_OPEN_TIMEOUT", "1m")
+		t.Setenv("BREAKER_HALF_OPEN_PROBES", "2")
+		t.Setenv("BREAKER_CACHE_TTL", "5s")
+
+		config, err := LoadCircuitBreakerConfig()
+		require.NoError(t, err)
+		assert.Equal(t, connection.CircuitBreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      time.Minute,
+			HalfOpenProbes:   2,
+			CacheTTL:         5 * time.Second,
+		}, config)
+	})
+
+	tests := map[string]string{
+		"BREAKER_FAILURE_THRESHOLD": "many",
+		"BREAKER_OPEN_TIMEOUT":      "soon",
+		"BREAKER_HALF_OPEN_PROBES":  "one",
+		"BREAKER_CACHE_TTL":         "brief",
+	}
+	for name, value := range tests {
+		t.Run("invalid "+name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := LoadCircuitBreakerConfig()
+			assert.ErrorContains(t, err, name)
+		})
+	}
+}
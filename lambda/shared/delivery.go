@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+// LoadDeliveryConfig reads reliable delivery settings from the environment:
+//
+//	ACK_TIMEOUT        how long to wait for an ack before redelivering, e.g. "1m" (default 30s)
+//	MAX_DELIVERIES     sends per message, including the first, before it is dropped (default 5)
+//	ACK_MESSAGE_TYPES  comma-separated message types that require an ack (default "complete,error")
+//
+// Unset values use the connection package defaults.
+func LoadDeliveryConfig() (connection.DeliveryConfig, error) {
+	var config connection.DeliveryConfig
+	var err error
+
+	if raw := os.Getenv("ACK_TIMEOUT"); raw != "" {
+		if config.AckTimeout, err = time.ParseDuration(raw); err != nil {
+			return config, fmt.Errorf("invalid ACK_TIMEOUT: %w", err)
+		}
+	}
+	if raw := os.Getenv("MAX_DELIVERIES"); raw != "" {
+		if config.MaxDeliveries, err = strconv.Atoi(raw); err != nil {
+			return config, fmt.Errorf("invalid MAX_DELIVERIES: %w", err)
+		}
+	}
+	if raw := os.Getenv("ACK_MESSAGE_TYPES"); raw != "" {
+		for _, messageType := range strings.Split(raw, ",") {
+			if messageType = strings.TrimSpace(messageType); messageType != "" {
+				config.MessageTypes = append(config.MessageTypes, messageType)
+			}
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/pkg/connection"
+)
+
+func TestLoadDeliveryConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := LoadDeliveryConfig()
+		require.NoError(t, err)
+		assert.Equal(t, connection.DeliveryConfig{}, config)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("ACK_TIMEOUT", "1m")
+		t.Setenv("MAX_DELIVERIES", "3")
+		t.Setenv("ACK_MESSAGE_TYPES", "complete, error,,result")
+
+		config, err := LoadDeliveryConfig()
+		require.NoError(t, err)
+		assert.Equal(t, connection.DeliveryConfig{
+			AckTimeout:    time.Minute,
+			MaxDeliveries: 3,
+			MessageTypes:  []string{"complete", "error", "result"},
+		}, config)
+	})
+
+	tests := map[string]string{
+		"ACK_TIMEOUT":    "soon",
+		"MAX_DELIVERIES": "many",
+	}
+	for name, value := range tests {
+		t.Run("invalid "+name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := LoadDeliveryConfig()
+			assert.ErrorContains(t, err, name)
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package shared
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Default JWKS cache settings
+const (
+	DefaultJWKSRefreshInterval = 5 * time.Minute
+	DefaultJWKSKeyOverlap      = 1 * time.Hour
+
+	// minJWKSRefreshInterval limits refreshes triggered by unknown key IDs
+	minJWKSRefreshInterval = 30 * time.Second
+
+	// jwksFetchTimeout bounds a single fetch of the key set
+	jwksFetchTimeout = 5 * time.Second
+)
+
+// supportedJWKSAlgorithms are the signing algorithms accepted by JWKSVerifier
+var supportedJWKSAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// JWKSSource loads a JSON Web Key Set document
+type JWKSSource interface {
+	Fetch(ctx context.Context) ([]byte, error)
+}
+
+// StaticJWKSSource serves a JWKS document held in memory, e.g. from an environment variable
+type StaticJWKSSource []byte
+
+// Fetch returns the document
+func (s StaticJWKSSource) Fetch(ctx context.Context) ([]byte, error) {
+	return s, nil
+}
+
+// FileJWKSSource reads a JWKS document from disk on every fetch
+type FileJWKSSource string
+
+// Fetch reads the file
+func (s FileJWKSSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return data, nil
+}
+
+// HTTPJWKSSource fetches a JWKS document from an HTTP endpoint
+type HTTPJWKSSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// Fetch downloads the document
+func (s *HTTPJWKSSource) Fetch(ctx context.Context) ([]byte, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS response: %w", err)
+	}
+	return data, nil
+}
+
+// NewJWKSSource picks a source from the verifier configuration.
+// It returns nil when no JWKS is configured.
+func NewJWKSSource(config VerifierConfig) JWKSSource {
+	switch {
+	case config.JWKSURL != "":
+		return &HTTPJWKSSource{URL: config.JWKSURL}
+	case config.JWKSFile != "":
+		return FileJWKSSource(config.JWKSFile)
+	case config.JWKS != "":
+		return StaticJWKSSource(config.JWKS)
+	default:
+		return nil
+	}
+}
+
+// jsonWebKey is a single entry of a JWKS document
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// verificationKey is a parsed public key and the algorithm it verifies
+type verificationKey struct {
+	key       interface{}
+	alg       string
+	retiredAt time.Time // zero while the key is still published
+}
+
+// parseJWKS converts a JWKS document into verification keys indexed by kid.
+// Keys that are not for signatures or use unsupported types are skipped.
+func parseJWKS(data []byte) (map[string]*verificationKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make(map[string]*verificationKey)
+	for _, jwk := range doc.Keys {
+		if jwk.Kid == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS document contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+// parseJSONWebKey converts a single JWK into a public key
+func parseJSONWebKey(jwk jsonWebKey) (*verificationKey, error) {
+	var key *verificationKey
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJWKField(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKField(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > int64(^uint32(0)>>1) {
+			return nil, errors.New("RSA exponent is too large")
+		}
+		key = &verificationKey{
+			key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())},
+			alg: "RS256",
+		}
+
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve: %s", jwk.Crv)
+		}
+		x, err := decodeJWKField(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKField(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		key = &verificationKey{key: pub, alg: "ES256"}
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve: %s", jwk.Crv)
+		}
+		x, err := decodeJWKField(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		key = &verificationKey{key: ed25519.PublicKey(x), alg: "EdDSA"}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+	}
+
+	if jwk.Alg != "" && jwk.Alg != key.alg {
+		return nil, fmt.Errorf("unsupported algorithm %s for key type %s", jwk.Alg, jwk.Kty)
+	}
+	return key, nil
+}
+
+// decodeJWKField decodes a base64url JWK member, with or without padding
+func decodeJWKField(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// JWKSVerifier verifies JWTs against a cached JSON Web Key Set.
+// Keys removed from the set stay valid for the overlap window so tokens
+// signed just before a rotation keep working.
+type JWKSVerifier struct {
+	source          JWKSSource
+	issuer          string
+	refreshInterval time.Duration
+	overlap         time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*verificationKey
+	lastRefresh time.Time
+	lastAttempt time.Time
+	now         func() time.Time
+}
+
+// NewJWKSVerifier creates a verifier and loads the key set once.
+// Zero durations use DefaultJWKSRefreshInterval and DefaultJWKSKeyOverlap.
+func NewJWKSVerifier(source JWKSSource, issuer string, refreshInterval, overlap time.Duration) (*JWKSVerifier, error) {
+	if source == nil {
+		return nil, errors.New("JWKS source is required")
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultJWKSRefreshInterval
+	}
+	if overlap <= 0 {
+		overlap = DefaultJWKSKeyOverlap
+	}
+
+	v := &JWKSVerifier{
+		source:          source,
+		issuer:          issuer,
+		refreshInterval: refreshInterval,
+		overlap:         overlap,
+		keys:            make(map[string]*verificationKey),
+		now:             time.Now,
+	}
+
+	if err := v.Refresh(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+	return v, nil
+}
+
+// Refresh reloads the key set. Keys missing from the new set are retired
+// rather than dropped, and are removed once the overlap window has passed.
+func (v *JWKSVerifier) Refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	v.mu.Lock()
+	v.lastAttempt = v.now()
+	v.mu.Unlock()
+
+	data, err := v.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	fresh, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	for kid, old := range v.keys {
+		if _, published := fresh[kid]; published {
+			continue
+		}
+		if old.retiredAt.IsZero() {
+			old.retiredAt = now
+		}
+		if now.Sub(old.retiredAt) < v.overlap {
+			fresh[kid] = old
+		}
+	}
+
+	v.keys = fresh
+	v.lastRefresh = now
+	return nil
+}
+
+// Verify validates a JWT token and returns the claims
+func (v *JWKSVerifier) Verify(tokenString string) (*Claims, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	tokenString = strings.TrimSpace(tokenString)
+
+	// Refresh before verifying if the cache is stale; this blocks the
+	// request for one fetch. A failed refresh keeps serving the cached keys.
+	if v.stale() {
+		_ = v.Refresh(context.Background())
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, v.keyFunc,
+		jwt.WithValidMethods(supportedJWKSAlgorithms))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	if !token.Valid {
+		return nil, errors.New("token is invalid")
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("failed to extract claims")
+	}
+
+	if err := validateClaims(claims, v.issuer); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// keyFunc selects the verification key by kid and checks it matches the token algorithm
+func (v *JWKSVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no key ID")
+	}
+
+	key, ok := v.lookup(kid)
+	if !ok && v.canRefreshForUnknownKey() {
+		// The signer may have rotated to a key we have not seen yet
+		if err := v.Refresh(context.Background()); err == nil {
+			key, ok = v.lookup(kid)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID: %s", kid)
+	}
+
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("algorithm %s does not match key %s", token.Method.Alg(), kid)
+	}
+	return key.key, nil
+}
+
+// lookup returns the key for kid if it is published or still in its overlap window
+func (v *JWKSVerifier) lookup(kid string) (*verificationKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	if !key.retiredAt.IsZero() && v.now().Sub(key.retiredAt) >= v.overlap {
+		return nil, false
+	}
+	return key, true
+}
+
+// stale reports whether the cached key set is due for a refresh
+func (v *JWKSVerifier) stale() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	now := v.now()
+	return now.Sub(v.lastRefresh) >= v.refreshInterval && now.Sub(v.lastAttempt) >= minJWKSRefreshInterval
+}
+
+// canRefreshForUnknownKey limits how often unknown key IDs can trigger a fetch
+func (v *JWKSVerifier) canRefreshForUnknownKey() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.now().Sub(v.lastAttempt) >= minJWKSRefreshInterval
+}
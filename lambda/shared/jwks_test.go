@@ -0,0 +1,247 @@
+package shared
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJWKS is a mutable key set served to verifiers under test
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches int
+	err     error
+}
+
+func (s *testJWKS) Fetch(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return json.Marshal(map[string]interface{}{"keys": s.keys})
+}
+
+func (s *testJWKS) set(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func okpJWK(kid string, key ed25519.PublicKey) map[string]string {
+	return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(key)}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	token := jwt.NewWithClaims(method, createTestClaims("user123", "tenant456", []string{"read"}))
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJWKSVerifier_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	source := &testJWKS{}
+	source.set(rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey), okpJWK("ed-1", edPub))
+
+	verifier, err := NewJWKSVerifier(source, "test-issuer", 0, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256", signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey), ""},
+		{"ES256", signTestToken(t, jwt.SigningMethodES256, "ec-1", ecKey), ""},
+		{"EdDSA", signTestToken(t, jwt.SigningMethodEdDSA, "ed-1", edKey), ""},
+		{"bearer prefix", "Bearer " + signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey), ""},
+		{"wrong key for kid", signTestToken(t, jwt.SigningMethodES256, "rsa-1", ecKey), "does not match key"},
+		{"missing kid", signTestToken(t, jwt.SigningMethodRS256, "", rsaKey), "no key ID"},
+		{"unsupported algorithm", signTestToken(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret")), "signing method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "user123", claims.Subject)
+			assert.Equal(t, "tenant456", claims.TenantID)
+		})
+	}
+}
+
+func TestJWKSVerifier_Rotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	source := &testJWKS{}
+	source.set(rsaJWK("old", &oldKey.PublicKey))
+
+	verifier, err := NewJWKSVerifier(source, "test-issuer", time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	oldToken := signTestToken(t, jwt.SigningMethodRS256, "old", oldKey)
+	newToken := signTestToken(t, jwt.SigningMethodRS256, "new", newKey)
+
+	// An unknown kid triggers a refresh, but not more than once per interval
+	source.set(rsaJWK("new", &newKey.PublicKey))
+	_, err = verifier.Verify(newToken)
+	assert.ErrorContains(t, err, "unknown key ID")
+
+	now = now.Add(minJWKSRefreshInterval)
+	_, err = verifier.Verify(newToken)
+	require.NoError(t, err)
+
+	// The rotated-out key keeps working during the overlap window
+	_, err = verifier.Verify(oldToken)
+	require.NoError(t, err)
+
+	now = now.Add(time.Hour)
+	_, err = verifier.Verify(oldToken)
+	assert.ErrorContains(t, err, "unknown key ID")
+
+	_, err = verifier.Verify(newToken)
+	assert.NoError(t, err)
+}
+
+func TestJWKSVerifier_RefreshFailureKeepsKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	source := &testJWKS{}
+	source.set(rsaJWK("k1", &key.PublicKey))
+
+	verifier, err := NewJWKSVerifier(source, "", time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now().Add(time.Hour)
+	verifier.now = func() time.Time { return now }
+	source.err = errors.New("endpoint down")
+
+	_, err = verifier.Verify(signTestToken(t, jwt.SigningMethodRS256, "k1", key))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, source.fetches)
+}
+
+func TestNewJWKSVerifier_Errors(t *testing.T) {
+	_, err := NewJWKSVerifier(nil, "", 0, 0)
+	assert.Error(t, err)
+
+	_, err = NewJWKSVerifier(StaticJWKSSource(`{"keys": []}`), "", 0, 0)
+	assert.ErrorContains(t, err, "no usable signing keys")
+
+	_, err = NewJWKSVerifier(StaticJWKSSource(`{`), "", 0, 0)
+	assert.ErrorContains(t, err, "invalid JWKS document")
+
+	// Encryption keys and unsupported curves are skipped
+	_, err = NewJWKSVerifier(StaticJWKSSource(`{"keys": [
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AQAB", "y": "AQAB"}
+	]}`), "", 0, 0)
+	assert.Error(t, err)
+}
+
+func TestJWKSSources(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	doc, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &key.PublicKey)}})
+	require.NoError(t, err)
+	token := signTestToken(t, jwt.SigningMethodRS256, "k1", key)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, doc, 0o600))
+
+	tests := []struct {
+		name   string
+		config VerifierConfig
+	}{
+		{"url", VerifierConfig{JWKSURL: server.URL}},
+		{"file", VerifierConfig{JWKSFile: path}},
+		{"inline", VerifierConfig{JWKS: string(doc)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.True(t, tt.config.UsesJWKS())
+			verifier, err := NewJWKSVerifier(NewJWKSSource(tt.config), "test-issuer", 0, 0)
+			require.NoError(t, err)
+
+			_, err = verifier.Verify(token)
+			assert.NoError(t, err)
+		})
+	}
+
+	assert.Nil(t, NewJWKSSource(VerifierConfig{}))
+
+	t.Run("http error status", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		_, err := (&HTTPJWKSSource{URL: failing.URL}).Fetch(context.Background())
+		assert.ErrorContains(t, err, "unexpected status 503")
+	})
+}
@@ -1,4 +1,4 @@
-package main
+package shared
 
 import (
 	"crypto/rsa"
@@ -89,6 +89,12 @@ func (v *JWTVerifier) VerifyWithoutIssuerCheck(tokenString string) (*Claims, err
 
 // validateClaims performs additional validation on the claims
 func (v *JWTVerifier) validateClaims(claims *Claims) error {
+	return validateClaims(claims, v.issuer)
+}
+
+// validateClaims checks timing, issuer and the fields the connect handler relies on.
+// An empty issuer skips the issuer check.
+func validateClaims(claims *Claims, issuer string) error {
 	now := time.Now()
 
 	// Check expiration
@@ -107,8 +113,8 @@ func (v *JWTVerifier) validateClaims(claims *Claims) error {
 	}
 
 	// Validate issuer if configured
-	if v.issuer != "" && claims.Issuer != v.issuer {
-		return fmt.Errorf("invalid issuer: expected %s, got %s", v.issuer, claims.Issuer)
+	if issuer != "" && claims.Issuer != issuer {
+		return fmt.Errorf("invalid issuer: expected %s, got %s", issuer, claims.Issuer)
 	}
 
 	// Validate required fields
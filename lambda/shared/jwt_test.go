@@ -1,7 +1,4 @@
-//go:build !lift
-// +build !lift
-
-package main
+package shared
 
 import (
 	"crypto/rand"
@@ -16,6 +13,38 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// Test helper to create test claims - used by jwt_test.go and jwks_test.go
+func createTestClaims(userID, tenantID string, permissions []string) *Claims {
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    "test-issuer",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(1 * time.Hour)),
+		},
+		TenantID:    tenantID,
+		Permissions: permissions,
+	}
+}
+
+// Test helper to generate RSA key pair
+func generateTestKeyPair(t *testing.T) (*rsa.PrivateKey, string) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	// Convert public key to PEM format
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKeyBytes,
+	})
+
+	return privateKey, string(publicKeyPEM)
+}
+
 // Test helper to create a signed JWT token
 func createTestToken(t *testing.T, privateKey *rsa.PrivateKey, claims *Claims) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/results"
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// LoadResultStore builds the offloaded result store and its token signer from the environment:
+//
+//	RESULT_BUCKET        S3 bucket for results (RESULT_PREFIX optionally prefixes keys)
+//	RESULT_DIR           local directory for results, used when RESULT_BUCKET is unset
+//	RESULT_TOKEN_SECRET  HMAC secret for download tokens, at least 32 bytes
+//	RESULT_TOKEN_TTL     token lifetime, e.g. "30m" (default 1h)
+//
+// It returns nil values when neither RESULT_BUCKET nor RESULT_DIR is set.
+func LoadResultStore(cfg aws.Config) (store.ResultStore, *streamer.ResultTokenSigner, error) {
+	var resultStore store.ResultStore
+	var err error
+
+	switch {
+	case os.Getenv("RESULT_BUCKET") != "":
+		resultStore, err = results.NewS3Store(cfg, os.Getenv("RESULT_BUCKET"), results.WithPrefix(os.Getenv("RESULT_PREFIX")))
+	case os.Getenv("RESULT_DIR") != "":
+		resultStore, err = results.NewFileStore(os.Getenv("RESULT_DIR"))
+	default:
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create result store: %w", err)
+	}
+
+	secret := os.Getenv("RESULT_TOKEN_SECRET")
+	if secret == "" {
+		return nil, nil, errors.New("RESULT_TOKEN_SECRET is required when a result store is configured")
+	}
+
+	var ttl time.Duration
+	if raw := os.Getenv("RESULT_TOKEN_TTL"); raw != "" {
+		if ttl, err = time.ParseDuration(raw); err != nil {
+			return nil, nil, fmt.Errorf("invalid RESULT_TOKEN_TTL: %w", err)
+		}
+	}
+
+	signer, err := streamer.NewResultTokenSigner([]byte(secret), ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resultStore, signer, nil
+}
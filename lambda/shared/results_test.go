@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store/results"
+)
+
+func TestLoadResultStore(t *testing.T) {
+	t.Run("not configured", func(t *testing.T) {
+		resultStore, signer, err := LoadResultStore(aws.Config{})
+		require.NoError(t, err)
+		assert.Nil(t, resultStore)
+		assert.Nil(t, signer)
+	})
+
+	t.Run("file store", func(t *testing.T) {
+		t.Setenv("RESULT_DIR", t.TempDir())
+		t.Setenv("RESULT_TOKEN_SECRET", strings.Repeat("s", 32))
+		t.Setenv("RESULT_TOKEN_TTL", "10m")
+
+		resultStore, signer, err := LoadResultStore(aws.Config{})
+		require.NoError(t, err)
+		assert.IsType(t, &results.FileStore{}, resultStore)
+		assert.NotNil(t, signer)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		t.Setenv("RESULT_DIR", t.TempDir())
+
+		_, _, err := LoadResultStore(aws.Config{})
+		assert.ErrorContains(t, err, "RESULT_TOKEN_SECRET")
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		t.Setenv("RESULT_DIR", t.TempDir())
+		t.Setenv("RESULT_TOKEN_SECRET", strings.Repeat("s", 32))
+		t.Setenv("RESULT_TOKEN_TTL", "soon")
+
+		_, _, err := LoadResultStore(aws.Config{})
+		assert.ErrorContains(t, err, "RESULT_TOKEN_TTL")
+	})
+}
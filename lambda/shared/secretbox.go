@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"errors"
+	"os"
+
+	"github.com/pay-theory/streamer/pkg/streamer"
+)
+
+// LoadSecretBox builds the box callback secrets are sealed in from the environment:
+//
+//	CALLBACK_SECRET_KEY  base64 encoded 32 byte key
+//
+// The router seals secrets with it and the processor and reaper open them,
+// so all three must share the key. It is required; without it callback
+// secrets would be stored in plaintext.
+func LoadSecretBox() (*streamer.SecretBox, error) {
+	key := os.Getenv("CALLBACK_SECRET_KEY")
+	if key == "" {
+		return nil, errors.New("CALLBACK_SECRET_KEY is required to seal callback secrets")
+	}
+	return streamer.ParseSecretBoxKey(key)
+}
@@ -0,0 +1,39 @@
+package shared
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecretBox(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("CALLBACK_SECRET_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+
+		box, err := LoadSecretBox()
+		require.NoError(t, err)
+
+		sealed, err := box.Seal("req_123", "s3cret")
+		require.NoError(t, err)
+		opened, err := box.Open("req_123", sealed)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", opened)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		t.Setenv("CALLBACK_SECRET_KEY", "")
+
+		_, err := LoadSecretBox()
+		assert.ErrorContains(t, err, "CALLBACK_SECRET_KEY")
+	})
+
+	t.Run("short key", func(t *testing.T) {
+		t.Setenv("CALLBACK_SECRET_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+
+		_, err := LoadSecretBox()
+		assert.ErrorContains(t, err, "32 bytes")
+	})
+}
@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// TokenVerifier verifies a JWT and returns its claims
+type TokenVerifier interface {
+	Verify(token string) (*Claims, error)
+}
+
+// VerifierConfig selects how JWTs are verified
+type VerifierConfig struct {
+	PublicKey string
+	Issuer    string
+
+	// JWKS settings; any one source takes precedence over PublicKey
+	JWKSURL             string
+	JWKSFile            string
+	JWKS                string
+	JWKSRefreshInterval time.Duration
+	JWKSKeyOverlap      time.Duration
+}
+
+// UsesJWKS reports whether a JWKS source is configured
+func (c VerifierConfig) UsesJWKS() bool {
+	return c.JWKSURL != "" || c.JWKSFile != "" || c.JWKS != ""
+}
+
+// Configured reports whether any verification key is configured
+func (c VerifierConfig) Configured() bool {
+	return c.PublicKey != "" || c.UsesJWKS()
+}
+
+// NewVerifier creates a JWKSVerifier when a JWKS source is configured and a
+// JWTVerifier for the public key otherwise
+func NewVerifier(config VerifierConfig) (TokenVerifier, error) {
+	if config.UsesJWKS() {
+		return NewJWKSVerifier(NewJWKSSource(config), config.Issuer, config.JWKSRefreshInterval, config.JWKSKeyOverlap)
+	}
+	return NewJWTVerifier(config.PublicKey, config.Issuer)
+}
+
+// LoadVerifierConfig reads JWT verification settings from the environment:
+//
+//	JWT_PUBLIC_KEY         PEM-encoded RSA public key
+//	JWT_ISSUER             required issuer; empty skips the check
+//	JWKS_URL, JWKS_FILE, JWKS  key set to verify against instead of JWT_PUBLIC_KEY
+//	JWKS_REFRESH_INTERVAL  how often the key set is refetched (default 5m)
+//	JWKS_KEY_OVERLAP       how long rotated-out keys stay valid (default 1h)
+func LoadVerifierConfig() (VerifierConfig, error) {
+	config := VerifierConfig{
+		PublicKey:           os.Getenv("JWT_PUBLIC_KEY"),
+		Issuer:              os.Getenv("JWT_ISSUER"),
+		JWKSURL:             os.Getenv("JWKS_URL"),
+		JWKSFile:            os.Getenv("JWKS_FILE"),
+		JWKS:                os.Getenv("JWKS"),
+		JWKSRefreshInterval: DefaultJWKSRefreshInterval,
+		JWKSKeyOverlap:      DefaultJWKSKeyOverlap,
+	}
+
+	var err error
+	if raw := os.Getenv("JWKS_REFRESH_INTERVAL"); raw != "" {
+		if config.JWKSRefreshInterval, err = time.ParseDuration(raw); err != nil {
+			return config, fmt.Errorf("invalid JWKS_REFRESH_INTERVAL: %w", err)
+		}
+	}
+	if raw := os.Getenv("JWKS_KEY_OVERLAP"); raw != "" {
+		if config.JWKSKeyOverlap, err = time.ParseDuration(raw); err != nil {
+			return config, fmt.Errorf("invalid JWKS_KEY_OVERLAP: %w", err)
+		}
+	}
+	return config, nil
+}
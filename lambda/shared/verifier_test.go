@@ -0,0 +1,58 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVerifier(t *testing.T) {
+	privateKey, publicKeyPEM := generateTestKeyPair(t)
+	token := createTestToken(t, privateKey, createTestClaims("user123", "tenant456", nil))
+
+	verifier, err := NewVerifier(VerifierConfig{PublicKey: publicKeyPEM, Issuer: "test-issuer"})
+	require.NoError(t, err)
+	assert.IsType(t, &JWTVerifier{}, verifier)
+
+	claims, err := verifier.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user123", claims.Subject)
+
+	_, err = NewVerifier(VerifierConfig{})
+	assert.Error(t, err)
+}
+
+func TestLoadVerifierConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := LoadVerifierConfig()
+		require.NoError(t, err)
+		assert.False(t, config.Configured())
+		assert.Equal(t, DefaultJWKSRefreshInterval, config.JWKSRefreshInterval)
+		assert.Equal(t, DefaultJWKSKeyOverlap, config.JWKSKeyOverlap)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		t.Setenv("JWKS_URL", "https://auth.example.com/.well-known/jwks.json")
+		t.Setenv("JWT_ISSUER", "auth.example.com")
+		t.Setenv("JWKS_REFRESH_INTERVAL", "1m")
+		t.Setenv("JWKS_KEY_OVERLAP", "10m")
+
+		config, err := LoadVerifierConfig()
+		require.NoError(t, err)
+		assert.True(t, config.Configured())
+		assert.True(t, config.UsesJWKS())
+		assert.Equal(t, "auth.example.com", config.Issuer)
+		assert.Equal(t, time.Minute, config.JWKSRefreshInterval)
+		assert.Equal(t, 10*time.Minute, config.JWKSKeyOverlap)
+	})
+
+	for _, name := range []string{"JWKS_REFRESH_INTERVAL", "JWKS_KEY_OVERLAP"} {
+		t.Run("invalid "+name, func(t *testing.T) {
+			t.Setenv(name, "often")
+			_, err := LoadVerifierConfig()
+			assert.ErrorContains(t, err, name)
+		})
+	}
+}
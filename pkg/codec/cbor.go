@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborCodec implements CBOR (RFC 8949). Map keys are sorted as in Core
+// Deterministic Encoding and times are encoded as RFC 3339 strings, as in JSON.
+type cborCodec struct{}
+
+// cborEncMode and cborDecMode hold the codec's encoding and decoding options
+var (
+	cborEncMode = mustCBOREncMode(cbor.EncOptions{
+		Sort:    cbor.SortCoreDeterministic,
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagNone,
+	})
+	cborDecMode = mustCBORDecMode(cbor.DecOptions{
+		MaxNestedLevels: maxDepth,
+		DefaultMapType:  reflect.TypeOf(map[string]interface{}(nil)),
+		DupMapKey:       cbor.DupMapKeyEnforcedAPF,
+	})
+)
+
+func (cborCodec) Name() string { return NameCBOR }
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cborEncMode.Marshal(v)
+}
+
+// Unmarshal decodes one value that must fill data. Integers decoded into
+// interface{} values are int64 or uint64, and maps have string keys.
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) > maxInputSize {
+		return ErrTooLarge
+	}
+
+	err := cborDecMode.Unmarshal(data, v)
+	var trailing *cbor.ExtraneousDataError
+	var tooDeep *cbor.MaxNestedLevelError
+	switch {
+	case errors.As(err, &trailing):
+		return fmt.Errorf("%w: %v", ErrTrailing, err)
+	case errors.As(err, &tooDeep):
+		return fmt.Errorf("%w: %v", ErrTooDeep, err)
+	}
+	return err
+}
+
+func mustCBOREncMode(opts cbor.EncOptions) cbor.EncMode {
+	mode, err := opts.EncMode()
+	if err != nil {
+		panic(fmt.Sprintf("codec: invalid CBOR encoding options: %v", err))
+	}
+	return mode
+}
+
+func mustCBORDecMode(opts cbor.DecOptions) cbor.DecMode {
+	mode, err := opts.DecMode()
+	if err != nil {
+		panic(fmt.Sprintf("codec: invalid CBOR decoding options: %v", err))
+	}
+	return mode
+}
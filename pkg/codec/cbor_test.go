@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCBOR_Encoding(t *testing.T) {
+	// Vectors from RFC 8949 Appendix A
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected []byte
+	}{
+		{name: "0", value: 0, expected: []byte{0x00}},
+		{name: "23", value: 23, expected: []byte{0x17}},
+		{name: "24", value: 24, expected: []byte{0x18, 0x18}},
+		{name: "1000", value: 1000, expected: []byte{0x19, 0x03, 0xe8}},
+		{name: "1000000", value: 1000000, expected: []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{name: "max uint64", value: uint64(math.MaxUint64), expected: []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "-1", value: -1, expected: []byte{0x20}},
+		{name: "-1000", value: -1000, expected: []byte{0x39, 0x03, 0xe7}},
+		{name: "1.1", value: 1.1, expected: []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{name: "false", value: false, expected: []byte{0xf4}},
+		{name: "null", value: nil, expected: []byte{0xf6}},
+		{name: "text", value: "IETF", expected: []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		{name: "array", value: []int{1, 2, 3}, expected: []byte{0x83, 0x01, 0x02, 0x03}},
+		{name: "map", value: map[string]string{"a": "A", "b": "B"}, expected: []byte{0xa2, 0x61, 0x61, 0x61, 0x41, 0x61, 0x62, 0x61, 0x42}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := CBOR.Marshal(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, data)
+		})
+	}
+}
+
+func TestCBOR_DecodesClientFormats(t *testing.T) {
+	// Vectors from RFC 8949 Appendix A that Marshal never produces
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{name: "half float", data: []byte{0xf9, 0x3e, 0x00}, expected: 1.5},
+		{name: "half float subnormal", data: []byte{0xf9, 0x00, 0x01}, expected: 5.960464477539063e-08},
+		{name: "half float negative", data: []byte{0xf9, 0xc4, 0x00}, expected: -4.0},
+		{name: "single float", data: []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, expected: 100000.0},
+		{name: "undefined", data: []byte{0xf7}, expected: nil},
+		{name: "byte string", data: []byte{0x44, 0x01, 0x02, 0x03, 0x04}, expected: []byte{0x01, 0x02, 0x03, 0x04}},
+		{name: "tagged timestamp", data: []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, expected: time.Unix(1363896240, 0)},
+		{name: "indefinite text", data: []byte{0x7f, 0x65, 0x73, 0x74, 0x72, 0x65, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x67, 0xff}, expected: "streaming"},
+		{name: "indefinite array", data: []byte{0x9f, 0x01, 0x82, 0x02, 0x03, 0xff}, expected: []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}}},
+		{name: "indefinite map", data: []byte{0xbf, 0x61, 0x61, 0x01, 0x61, 0x62, 0x9f, 0x02, 0x03, 0xff, 0xff}, expected: map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out interface{}
+			require.NoError(t, CBOR.Unmarshal(tt.data, &out))
+			assert.Equal(t, tt.expected, out)
+		})
+	}
+}
+
+func TestCBOR_RejectsInvalidItems(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "reserved additional info", data: []byte{0x1c}},
+		{name: "indefinite integer", data: []byte{0x1f}},
+		{name: "mismatched chunk", data: []byte{0x7f, 0x41, 0x00, 0xff}},
+		{name: "two-byte simple value below 32", data: []byte{0xf8, 0x18}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out interface{}
+			assert.Error(t, CBOR.Unmarshal(tt.data, &out))
+		})
+	}
+}
+
+// FuzzCBORDecode checks that decoding untrusted input never panics, and that whatever
+// decodes re-encodes to a value that decodes the same way.
+func FuzzCBORDecode(f *testing.F) {
+	for _, seed := range [][]byte{
+		[]byte{0xa0},
+		[]byte{0xa1, 0x61, 'a', 0x01},
+		[]byte{0x82, 0xf5, 0xf9, 0x3c, 0x00},
+		[]byte{0xbf, 0x61, 'a', 0x9f, 0x01, 0xff, 0xff},
+		[]byte{0xc1, 0x1a, 0x5f, 0x5e, 0x10, 0x00},
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v interface{}
+		if err := CBOR.Unmarshal(data, &v); err != nil {
+			return
+		}
+
+		encoded, err := CBOR.Marshal(v)
+		require.NoError(t, err)
+
+		var again interface{}
+		require.NoError(t, CBOR.Unmarshal(encoded, &again))
+		assertSameJSON(t, v, again)
+	})
+}
@@ -0,0 +1,104 @@
+// Package codec defines the wire encodings used for WebSocket messages.
+//
+// JSON is the default. MessagePack and CBOR are compact binary alternatives
+// that clients can select at $connect. The binary codecs encode values
+// directly with vmihailenco/msgpack and fxamacker/cbor, reading the same
+// `json` struct tags as JSON, so a message has the same fields whichever
+// codec a connection uses.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Codec names
+const (
+	NameJSON        = "json"
+	NameMessagePack = "msgpack"
+	NameCBOR        = "cbor"
+)
+
+// MetadataKey is the Connection.Metadata key holding the negotiated codec
+const MetadataKey = "codec"
+
+// Codec encodes and decodes WebSocket messages
+type Codec interface {
+	// Name identifies the codec, e.g. "json"
+	Name() string
+
+	// Marshal encodes v
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into v
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Built-in codecs
+var (
+	JSON        Codec = jsonCodec{}
+	MessagePack Codec = msgpackCodec{}
+	CBOR        Codec = cborCodec{}
+)
+
+// codecPreference lists binary codecs from most to least preferred
+var codecPreference = []Codec{MessagePack, CBOR}
+
+// Lookup returns the codec with the given name, or JSON if the name is empty or unknown
+func Lookup(name string) Codec {
+	switch name {
+	case NameMessagePack:
+		return MessagePack
+	case NameCBOR:
+		return CBOR
+	default:
+		return JSON
+	}
+}
+
+// Negotiate picks the preferred binary codec from those a client offers.
+// Offers may be plain names ("msgpack") or subprotocol names ("streamer.msgpack").
+// It returns "" when none are supported, meaning the connection uses JSON.
+func Negotiate(offered []string) string {
+	supported := make(map[string]bool, len(offered))
+	for _, offer := range offered {
+		offer = strings.ToLower(strings.TrimSpace(offer))
+		supported[strings.TrimPrefix(offer, "streamer.")] = true
+	}
+
+	for _, c := range codecPreference {
+		if supported[c.Name()] {
+			return c.Name()
+		}
+	}
+	return ""
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return NameJSON }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Binary decoding errors
+var (
+	ErrTrailing = errors.New("codec: unexpected data after value")
+	ErrTooDeep  = errors.New("codec: value nested too deeply")
+	ErrTooLarge = errors.New("codec: input too large")
+)
+
+// Limits on untrusted binary input
+const (
+	// maxDepth bounds nesting
+	maxDepth = 128
+
+	// maxInputSize bounds a single message
+	maxInputSize = 4 << 20
+)
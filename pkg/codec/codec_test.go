@@ -0,0 +1,215 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, JSON, Lookup(""))
+	assert.Equal(t, JSON, Lookup("json"))
+	assert.Equal(t, JSON, Lookup("protobuf"))
+	assert.Equal(t, MessagePack, Lookup("msgpack"))
+	assert.Equal(t, CBOR, Lookup("cbor"))
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		offered  []string
+		expected string
+	}{
+		{name: "nothing offered", offered: nil, expected: ""},
+		{name: "json only", offered: []string{"json"}, expected: ""},
+		{name: "msgpack preferred", offered: []string{"cbor", "msgpack"}, expected: NameMessagePack},
+		{name: "cbor", offered: []string{"CBOR"}, expected: NameCBOR},
+		{name: "subprotocol names", offered: []string{"streamer.gzip", " streamer.cbor"}, expected: NameCBOR},
+		{name: "unsupported", offered: []string{"protobuf"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Negotiate(tt.offered))
+		})
+	}
+}
+
+type testMessage struct {
+	Type      string                 `json:"type"`
+	RequestID string                 `json:"request_id"`
+	Progress  float64                `json:"progress"`
+	Count     int64                  `json:"count"`
+	Big       uint64                 `json:"big"`
+	Done      bool                   `json:"done"`
+	Tags      []string               `json:"tags"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Raw       []byte                 `json:"raw"`
+	Skipped   string                 `json:"-"`
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	in := testMessage{
+		Type:      "progress",
+		RequestID: "req_123",
+		Progress:  42.5,
+		Count:     -70000,
+		Big:       1<<64 - 1,
+		Done:      true,
+		Tags:      []string{"a", "b"},
+		Data: map[string]interface{}{
+			"nested": map[string]interface{}{"ok": true},
+			"list":   []interface{}{"x", nil},
+		},
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:       []byte{0, 1, 2, 255},
+		Skipped:   "not encoded",
+	}
+
+	for _, c := range []Codec{JSON, MessagePack, CBOR} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(in)
+			require.NoError(t, err)
+
+			var out testMessage
+			require.NoError(t, c.Unmarshal(data, &out))
+
+			// Times may decode in another location
+			assert.True(t, in.Timestamp.Equal(out.Timestamp))
+			out.Timestamp = in.Timestamp
+
+			expected := in
+			expected.Skipped = ""
+			assert.Equal(t, expected, out)
+		})
+	}
+}
+
+// assertSameJSON checks that a decoded value re-decodes to the same message. Types
+// may differ, e.g. a CBOR timestamp comes back as an RFC 3339 string, so the values
+// are compared in their JSON form. Values JSON cannot hold are not compared.
+func assertSameJSON(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	want, err := json.Marshal(expected)
+	if err != nil {
+		return
+	}
+	got, err := json.Marshal(actual)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+}
+
+func TestCodecs_BinaryIsSmaller(t *testing.T) {
+	message := map[string]interface{}{
+		"type":       "progress",
+		"request_id": "req_123",
+		"percentage": 50,
+		"message":    "Halfway there",
+	}
+
+	jsonData, err := JSON.Marshal(message)
+	require.NoError(t, err)
+
+	for _, c := range []Codec{MessagePack, CBOR} {
+		data, err := c.Marshal(message)
+		require.NoError(t, err)
+		assert.Less(t, len(data), len(jsonData), c.Name())
+	}
+}
+
+func TestCodecs_DeterministicMaps(t *testing.T) {
+	message := map[string]interface{}{"b": 1, "a": 2, "c": 3}
+	for _, c := range []Codec{MessagePack, CBOR} {
+		first, err := c.Marshal(message)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			again, err := c.Marshal(message)
+			require.NoError(t, err)
+			assert.Equal(t, first, again, c.Name())
+		}
+	}
+}
+
+func TestCodecs_RejectMalformedInput(t *testing.T) {
+	deep := make([]byte, maxDepth+2)
+	for i := range deep {
+		deep[i] = 0x91 // msgpack array of one element
+	}
+	deepCBOR := make([]byte, maxDepth+2)
+	for i := range deepCBOR {
+		deepCBOR[i] = 0x81 // CBOR array of one element
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec
+		data  []byte
+		err   error
+	}{
+		{name: "msgpack trailing data", codec: MessagePack, data: []byte{0xc0, 0xc0}, err: ErrTrailing},
+		{name: "msgpack too deep", codec: MessagePack, data: deep, err: ErrTooDeep},
+		{name: "msgpack too large", codec: MessagePack, data: make([]byte, maxInputSize+1), err: ErrTooLarge},
+		{name: "cbor trailing data", codec: CBOR, data: []byte{0xf6, 0xf6}, err: ErrTrailing},
+		{name: "cbor too deep", codec: CBOR, data: deepCBOR, err: ErrTooDeep},
+		{name: "cbor too large", codec: CBOR, data: make([]byte, maxInputSize+1), err: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			assert.ErrorIs(t, tt.codec.Unmarshal(tt.data, &v), tt.err)
+		})
+	}
+
+	truncated := []struct {
+		name  string
+		codec Codec
+		data  []byte
+	}{
+		{name: "msgpack empty", codec: MessagePack, data: nil},
+		{name: "msgpack truncated string", codec: MessagePack, data: []byte{0xa5, 'a'}},
+		{name: "msgpack huge array", codec: MessagePack, data: []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{name: "cbor truncated", codec: CBOR, data: []byte{0x19, 0x01}},
+		{name: "cbor huge map", codec: CBOR, data: []byte{0xba, 0xff, 0xff, 0xff, 0xff}},
+		{name: "cbor unterminated array", codec: CBOR, data: []byte{0x9f, 0x01}},
+	}
+	for _, tt := range truncated {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			assert.Error(t, tt.codec.Unmarshal(tt.data, &v))
+		})
+	}
+
+	t.Run("non-string map keys", func(t *testing.T) {
+		var v interface{}
+		assert.Error(t, MessagePack.Unmarshal([]byte{0x81, 0x01, 0x02}, &v))
+		assert.Error(t, CBOR.Unmarshal([]byte{0xa1, 0x01, 0x02}, &v))
+	})
+}
+
+func TestCodecs_EmbeddedStructs(t *testing.T) {
+	type base struct {
+		Type string `json:"type"`
+		ID   string `json:"id,omitempty"`
+	}
+	type message struct {
+		base
+		RequestID string `json:"request_id"`
+	}
+
+	for _, c := range []Codec{JSON, MessagePack, CBOR} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(message{base: base{Type: "progress"}, RequestID: "req_1"})
+			require.NoError(t, err)
+
+			// Embedded fields are flattened and empty ones omitted, as in JSON
+			var got map[string]interface{}
+			require.NoError(t, c.Unmarshal(data, &got))
+			assert.Equal(t, map[string]interface{}{"type": "progress", "request_id": "req_1"}, got)
+		})
+	}
+}
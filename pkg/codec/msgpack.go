@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// msgpackCodec implements the MessagePack format (https://msgpack.org).
+// Keys of map[string]interface{} values are sorted, and times use the
+// standard timestamp extension; other extension types are not supported.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return NameMessagePack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes one value that must fill data. Numbers decoded into
+// interface{} values keep their encoded width, e.g. int8 or float32.
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) > maxInputSize {
+		return ErrTooLarge
+	}
+
+	// The decoder does not bound nesting, so check it before decoding
+	r := bytes.NewReader(data)
+	if err := checkMsgpackDepth(newMsgpackDecoder(r), 0); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return ErrTrailing
+	}
+
+	return newMsgpackDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// newMsgpackDecoder creates a decoder reading r with the codec's options
+func newMsgpackDecoder(r *bytes.Reader) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec
+}
+
+// checkMsgpackDepth skips the next value, failing with ErrTooDeep if its
+// arrays and maps nest more than maxDepth deep
+func checkMsgpackDepth(dec *msgpack.Decoder, depth int) error {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	var items int
+	switch {
+	case msgpcode.IsFixedArray(code), code == msgpcode.Array16, code == msgpcode.Array32:
+		if items, err = dec.DecodeArrayLen(); err != nil {
+			return err
+		}
+	case msgpcode.IsFixedMap(code), code == msgpcode.Map16, code == msgpcode.Map32:
+		n, err := dec.DecodeMapLen()
+		if err != nil {
+			return err
+		}
+		items = 2 * n
+	default:
+		return dec.Skip()
+	}
+
+	if depth >= maxDepth {
+		return fmt.Errorf("%w: more than %d levels", ErrTooDeep, maxDepth)
+	}
+	for i := 0; i < items; i++ {
+		if err := checkMsgpackDepth(dec, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessagePack_Encoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected []byte
+	}{
+		{name: "nil", value: nil, expected: []byte{0xc0}},
+		{name: "true", value: true, expected: []byte{0xc3}},
+		{name: "positive fixint", value: 127, expected: []byte{0x7f}},
+		{name: "uint8", value: 200, expected: []byte{0xcc, 0xc8}},
+		{name: "uint16", value: 65535, expected: []byte{0xcd, 0xff, 0xff}},
+		{name: "uint32", value: 65536, expected: []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{name: "uint64", value: uint64(1 << 63), expected: []byte{0xcf, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "negative fixint", value: -32, expected: []byte{0xe0}},
+		{name: "int8", value: -33, expected: []byte{0xd0, 0xdf}},
+		{name: "int16", value: -129, expected: []byte{0xd1, 0xff, 0x7f}},
+		{name: "float", value: 1.5, expected: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "fixstr", value: "hi", expected: []byte{0xa2, 'h', 'i'}},
+		{name: "fixarray", value: []int{1, 2}, expected: []byte{0x92, 0x01, 0x02}},
+		{name: "fixmap", value: map[string]interface{}{"b": 2, "a": 1}, expected: []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MessagePack.Marshal(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, data)
+		})
+	}
+}
+
+func TestMessagePack_LongValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		header []byte
+	}{
+		{name: "str8", value: strings.Repeat("x", 32), header: []byte{0xd9, 32}},
+		{name: "str16", value: strings.Repeat("x", 256), header: []byte{0xda, 0x01, 0x00}},
+		{name: "str32", value: strings.Repeat("x", 65536), header: []byte{0xdb, 0x00, 0x01, 0x00, 0x00}},
+		{name: "array16", value: make([]int, 16), header: []byte{0xdc, 0x00, 0x10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MessagePack.Marshal(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.header, data[:len(tt.header)])
+
+			var out interface{}
+			require.NoError(t, MessagePack.Unmarshal(data, &out))
+		})
+	}
+}
+
+func TestMessagePack_DecodesClientFormats(t *testing.T) {
+	// Formats other encoders produce that Marshal never does
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{name: "float32", data: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, expected: float32(1.5)},
+		{name: "int32", data: []byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, expected: int32(-2)},
+		{name: "int64", data: []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, expected: int64(-1)},
+		{name: "uint8", data: []byte{0xcc, 0xc8}, expected: uint8(200)},
+		{name: "bin8", data: []byte{0xc4, 0x02, 0x01, 0x02}, expected: []byte{0x01, 0x02}},
+		{name: "map16", data: []byte{0xde, 0x00, 0x01, 0xa1, 'k', 0xc2}, expected: map[string]interface{}{"k": false}},
+		{name: "timestamp", data: []byte{0xd6, 0xff, 0x51, 0x4b, 0x67, 0xb0}, expected: time.Unix(1363896240, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out interface{}
+			require.NoError(t, MessagePack.Unmarshal(tt.data, &out))
+			assert.Equal(t, tt.expected, out)
+		})
+	}
+}
+
+func TestMessagePack_RejectsExtensions(t *testing.T) {
+	var out interface{}
+	assert.Error(t, MessagePack.Unmarshal([]byte{0xd4, 0x01, 0x00}, &out))
+}
+
+func TestMessagePack_Times(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := MessagePack.Marshal(map[string]interface{}{"at": at})
+	require.NoError(t, err)
+
+	// Times use the timestamp extension, type -1
+	assert.Contains(t, string(data), string([]byte{0xd6, 0xff}))
+
+	var out struct {
+		At time.Time `json:"at"`
+	}
+	require.NoError(t, MessagePack.Unmarshal(data, &out))
+	assert.True(t, at.Equal(out.At))
+}
+
+// FuzzMessagePackDecode checks that decoding untrusted input never panics, and that whatever
+// decodes re-encodes to a value that decodes the same way.
+func FuzzMessagePackDecode(f *testing.F) {
+	for _, seed := range [][]byte{
+		[]byte{0x80},
+		[]byte{0x81, 0xa1, 'a', 0x01},
+		[]byte{0x92, 0xc3, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+		[]byte{0xdd, 0xff, 0xff, 0xff, 0xff},
+		[]byte{0xc4, 0x02, 0x01, 0x02},
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v interface{}
+		if err := MessagePack.Unmarshal(data, &v); err != nil {
+			return
+		}
+
+		encoded, err := MessagePack.Marshal(v)
+		require.NoError(t, err)
+
+		var again interface{}
+		require.NoError(t, MessagePack.Unmarshal(encoded, &again))
+		assertSameJSON(t, v, again)
+	})
+}
@@ -1,6 +1,7 @@
 package connection
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -81,11 +82,8 @@ func BenchmarkManager_WorkerPool(b *testing.B) {
 
 // BenchmarkManager_CircuitBreaker tests circuit breaker performance
 func BenchmarkManager_CircuitBreaker(b *testing.B) {
-	cb := &CircuitBreaker{
-		failures:   make(map[string]int),
-		lastFailed: make(map[string]time.Time),
-		mu:         sync.RWMutex{},
-	}
+	cb := NewCircuitBreaker(NewMemoryBreakerStore(0, 0), CircuitBreakerConfig{})
+	ctx := context.Background()
 
 	// Test with different connection counts
 	connCounts := []int{10, 100, 1000}
@@ -95,7 +93,7 @@ func BenchmarkManager_CircuitBreaker(b *testing.B) {
 			// Pre-populate some failures
 			for i := 0; i < count/10; i++ {
 				connID := fmt.Sprintf("conn-%d", i)
-				cb.RecordFailure(connID)
+				cb.RecordFailure(ctx, connID)
 			}
 
 			b.ResetTimer()
@@ -103,7 +101,7 @@ func BenchmarkManager_CircuitBreaker(b *testing.B) {
 				i := 0
 				for pb.Next() {
 					connID := fmt.Sprintf("conn-%d", i%count)
-					_ = cb.IsOpen(connID)
+					_ = cb.Allow(ctx, connID)
 					i++
 				}
 			})
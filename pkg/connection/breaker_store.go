@@ -0,0 +1,222 @@
+package connection
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+const (
+	// DefaultBreakerStoreSize is the most breakers a MemoryBreakerStore keeps
+	DefaultBreakerStoreSize = 10000
+
+	// DefaultBreakerStateTTL is how long breaker state is kept after its last update
+	DefaultBreakerStateTTL = 10 * time.Minute
+
+	// breakerStoreName identifies the in-memory store in errors
+	breakerStoreName = "memory_circuit_breakers"
+)
+
+// MemoryBreakerStore keeps circuit breaker state in process memory. State
+// expires ttl after its last update and the least recently used entries are
+// evicted beyond maxEntries, so breakers for dead connections do not pile up.
+type MemoryBreakerStore struct {
+	states *breakerLRU
+}
+
+// NewMemoryBreakerStore creates an in-memory breaker store. Zero values use
+// DefaultBreakerStoreSize and DefaultBreakerStateTTL.
+func NewMemoryBreakerStore(maxEntries int, ttl time.Duration) *MemoryBreakerStore {
+	return &MemoryBreakerStore{states: newBreakerLRU(maxEntries, ttl)}
+}
+
+// Get retrieves the breaker state for a connection
+func (s *MemoryBreakerStore) Get(ctx context.Context, connectionID string) (*store.CircuitBreakerState, error) {
+	state, ok := s.states.get(connectionID)
+	if !ok {
+		return nil, store.NewStoreError("Get", breakerStoreName, connectionID, store.ErrNotFound)
+	}
+	return state, nil
+}
+
+// Save writes state if its version matches the stored version
+func (s *MemoryBreakerStore) Save(ctx context.Context, state *store.CircuitBreakerState) error {
+	if state == nil {
+		return store.NewValidationError("state", "cannot be nil")
+	}
+	if state.ConnectionID == "" {
+		return store.NewValidationError("ConnectionID", "cannot be empty")
+	}
+
+	if !s.states.compareAndSet(state) {
+		return store.NewStoreError("Save", breakerStoreName, state.ConnectionID, store.ErrConcurrentModification)
+	}
+	return nil
+}
+
+// Delete clears the breaker state for a connection
+func (s *MemoryBreakerStore) Delete(ctx context.Context, connectionID string) error {
+	s.states.remove(connectionID)
+	return nil
+}
+
+// Len returns the number of breakers held, including any expired but not yet evicted
+func (s *MemoryBreakerStore) Len() int {
+	return s.states.len()
+}
+
+// breakerLRU is a size- and age-bounded map of breaker state
+type breakerLRU struct {
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+type breakerEntry struct {
+	state     store.CircuitBreakerState
+	expiresAt time.Time
+}
+
+func newBreakerLRU(maxEntries int, ttl time.Duration) *breakerLRU {
+	if maxEntries <= 0 {
+		maxEntries = DefaultBreakerStoreSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultBreakerStateTTL
+	}
+	return &breakerLRU{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns a copy of the live state for a connection
+func (l *breakerLRU) get(connectionID string) (*store.CircuitBreakerState, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.lookup(connectionID)
+	if !ok {
+		return nil, false
+	}
+	state := entry.state
+	return &state, true
+}
+
+// set stores a copy of state, replacing any existing entry
+func (l *breakerLRU) set(state *store.CircuitBreakerState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store(state)
+}
+
+// compareAndSet stores state, with its version incremented, if the stored
+// version still matches. Missing and expired entries have version zero.
+func (l *breakerLRU) compareAndSet(state *store.CircuitBreakerState) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var current int64
+	if entry, ok := l.lookup(state.ConnectionID); ok {
+		current = entry.state.Version
+	}
+	if current != state.Version {
+		return false
+	}
+
+	state.Version++
+	l.store(state)
+	return true
+}
+
+// addFailure counts a failure at for a connection and returns the new count
+func (l *breakerLRU) addFailure(connectionID string, at time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := store.CircuitBreakerState{ConnectionID: connectionID}
+	if entry, ok := l.lookup(connectionID); ok {
+		state = entry.state
+	}
+	state.Failures++
+	state.LastFailure = at
+	l.store(&state)
+	return state.Failures
+}
+
+func (l *breakerLRU) remove(connectionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[connectionID]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, connectionID)
+	}
+}
+
+func (l *breakerLRU) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// each calls fn with a copy of every live entry
+func (l *breakerLRU) each(fn func(state store.CircuitBreakerState)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for elem := l.order.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*breakerEntry)
+		if now.Before(entry.expiresAt) {
+			fn(entry.state)
+		}
+	}
+}
+
+// lookup finds a live entry and marks it recently used. Callers hold l.mu.
+func (l *breakerLRU) lookup(connectionID string) (*breakerEntry, bool) {
+	elem, ok := l.entries[connectionID]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*breakerEntry)
+	if !l.now().Before(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.entries, connectionID)
+		return nil, false
+	}
+
+	l.order.MoveToFront(elem)
+	return entry, true
+}
+
+// store writes an entry and evicts the least recently used beyond maxEntries.
+// Callers hold l.mu.
+func (l *breakerLRU) store(state *store.CircuitBreakerState) {
+	entry := &breakerEntry{state: *state, expiresAt: l.now().Add(l.ttl)}
+
+	if elem, ok := l.entries[state.ConnectionID]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+	} else {
+		l.entries[state.ConnectionID] = l.order.PushFront(entry)
+	}
+
+	for l.order.Len() > l.maxEntries {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*breakerEntry).state.ConnectionID)
+	}
+}
@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+func TestMemoryBreakerStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("save and get", func(t *testing.T) {
+		breakers := NewMemoryBreakerStore(0, 0)
+
+		_, err := breakers.Get(ctx, "conn-1")
+		assert.True(t, store.IsNotFound(err))
+
+		state := &store.CircuitBreakerState{ConnectionID: "conn-1", Failures: 2}
+		require.NoError(t, breakers.Save(ctx, state))
+		assert.Equal(t, int64(1), state.Version)
+
+		got, err := breakers.Get(ctx, "conn-1")
+		require.NoError(t, err)
+		assert.Equal(t, state, got)
+
+		// Callers get a copy
+		got.Failures = 10
+		again, err := breakers.Get(ctx, "conn-1")
+		require.NoError(t, err)
+		assert.Equal(t, 2, again.Failures)
+
+		require.NoError(t, breakers.Delete(ctx, "conn-1"))
+		_, err = breakers.Get(ctx, "conn-1")
+		assert.True(t, store.IsNotFound(err))
+	})
+
+	t.Run("stale versions are rejected", func(t *testing.T) {
+		breakers := NewMemoryBreakerStore(0, 0)
+		require.NoError(t, breakers.Save(ctx, &store.CircuitBreakerState{ConnectionID: "conn-1"}))
+
+		stale := &store.CircuitBreakerState{ConnectionID: "conn-1"}
+		assert.ErrorIs(t, breakers.Save(ctx, stale), store.ErrConcurrentModification)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		breakers := NewMemoryBreakerStore(0, time.Minute)
+		now := time.Now()
+		breakers.states.now = func() time.Time { return now }
+
+		state := &store.CircuitBreakerState{ConnectionID: "conn-1", Failures: 1}
+		require.NoError(t, breakers.Save(ctx, state))
+
+		now = now.Add(2 * time.Minute)
+		_, err := breakers.Get(ctx, "conn-1")
+		assert.True(t, store.IsNotFound(err))
+		assert.Zero(t, breakers.Len())
+
+		// An expired entry counts as version zero again
+		require.NoError(t, breakers.Save(ctx, &store.CircuitBreakerState{ConnectionID: "conn-1"}))
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		breakers := NewMemoryBreakerStore(3, 0)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, breakers.Save(ctx, &store.CircuitBreakerState{ConnectionID: fmt.Sprintf("conn-%d", i)}))
+		}
+
+		// Touch conn-0 so conn-1 is the oldest
+		_, err := breakers.Get(ctx, "conn-0")
+		require.NoError(t, err)
+
+		require.NoError(t, breakers.Save(ctx, &store.CircuitBreakerState{ConnectionID: "conn-3"}))
+		assert.Equal(t, 3, breakers.Len())
+
+		_, err = breakers.Get(ctx, "conn-1")
+		assert.True(t, store.IsNotFound(err))
+		for _, id := range []string{"conn-0", "conn-2", "conn-3"} {
+			_, err := breakers.Get(ctx, id)
+			assert.NoError(t, err, id)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		breakers := NewMemoryBreakerStore(0, 0)
+		assert.Error(t, breakers.Save(ctx, nil))
+		assert.Error(t, breakers.Save(ctx, &store.CircuitBreakerState{}))
+	})
+}
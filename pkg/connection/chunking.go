@@ -0,0 +1,79 @@
+package connection
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+const (
+	// DefaultMaxFrameSize is the API Gateway WebSocket message limit (128KB)
+	DefaultMaxFrameSize = 128 * 1024
+
+	// MinMaxFrameSize is the smallest frame size that leaves room for chunk data
+	MinMaxFrameSize = 1024
+
+	// chunkEnvelopeSize is reserved in every chunk for the chunk message
+	// fields, including its sequence number
+	chunkEnvelopeSize = 256
+
+	// sequenceFieldSize is reserved in an unchunked message for the sequence
+	// number stamped on it when it is sent: `,"sequence":` and the largest
+	// uint64 in JSON, the longest of the codecs
+	sequenceFieldSize = 32
+)
+
+// SetMaxFrameSize sets the largest frame posted to a connection. Messages that
+// marshal to more than this are split into chunk messages. Values below
+// MinMaxFrameSize are raised to it.
+func (m *Manager) SetMaxFrameSize(size int) {
+	if size < MinMaxFrameSize {
+		size = MinMaxFrameSize
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxFrameSize = size
+}
+
+// frames splits a message into frames no larger than the configured max frame
+// size. frame is the message to send and data its encoded form. A message that
+// fits with its sequence number is returned as the only frame; a larger one is
+// split into chunk messages carrying data.
+func (m *Manager) frames(frame interface{}, data []byte) []interface{} {
+	m.mu.RLock()
+	maxFrameSize := m.maxFrameSize
+	m.mu.RUnlock()
+
+	if len(data)+sequenceFieldSize <= maxFrameSize {
+		return []interface{}{frame}
+	}
+
+	// Chunk data is base64 encoded, so each frame carries 3 raw bytes per 4 encoded
+	fragmentSize := (maxFrameSize - chunkEnvelopeSize) / 4 * 3
+	total := (len(data) + fragmentSize - 1) / fragmentSize
+	messageID := newChunkMessageID()
+
+	frames := make([]interface{}, 0, total)
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * fragmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		frames = append(frames, types.NewChunkMessage(messageID, seq, total, data[seq*fragmentSize:end]))
+	}
+
+	m.metrics.ChunkedMessages.Add(1)
+	return frames
+}
+
+// newChunkMessageID generates an ID shared by all chunks of one message
+func newChunkMessageID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("msg_%d", time.Now().UnixNano())
+	}
+	return "msg_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+package connection
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// reassemble decodes chunk frames the way a client would
+func reassemble(t *testing.T, frames [][]byte) []byte {
+	var data []byte
+	var messageID string
+	for i, frame := range frames {
+		require.LessOrEqual(t, len(frame), MinMaxFrameSize*4)
+
+		var chunk types.ChunkMessage
+		require.NoError(t, json.Unmarshal(frame, &chunk))
+		assert.Equal(t, types.MessageTypeChunk, chunk.Type)
+		assert.Equal(t, i, chunk.Sequence)
+		assert.Equal(t, len(frames), chunk.Total)
+		if messageID == "" {
+			messageID = chunk.MessageID
+		}
+		assert.Equal(t, messageID, chunk.MessageID)
+
+		fragment, err := base64.StdEncoding.DecodeString(chunk.Data)
+		require.NoError(t, err)
+		data = append(data, fragment...)
+	}
+	return data
+}
+
+func TestManager_Frames(t *testing.T) {
+	manager := NewManager(new(MockConnectionStore), NewMockAPIGatewayClient(), "wss://example.com")
+	manager.SetMaxFrameSize(MinMaxFrameSize * 4)
+
+	t.Run("small message is sent as is", func(t *testing.T) {
+		message := map[string]interface{}{"type": "response"}
+		frames := manager.frames(message, []byte(`{"type":"response"}`))
+		assert.Equal(t, []interface{}{message}, frames)
+	})
+
+	t.Run("large message is chunked", func(t *testing.T) {
+		message := map[string]interface{}{"data": strings.Repeat("é≈x", 5000)}
+		data, err := json.Marshal(message)
+		require.NoError(t, err)
+
+		frames := manager.frames(message, data)
+		assert.Greater(t, len(frames), 1)
+
+		encoded := make([][]byte, 0, len(frames))
+		for _, frame := range frames {
+			chunk, err := codec.JSON.Marshal(frame)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(chunk), MinMaxFrameSize*4)
+			encoded = append(encoded, chunk)
+		}
+		assert.Equal(t, data, reassemble(t, encoded))
+		assert.Equal(t, int64(1), manager.GetMetrics()["chunked_messages"])
+	})
+
+	t.Run("message that fits only without its sequence is chunked", func(t *testing.T) {
+		message := map[string]interface{}{"type": "response"}
+		data := []byte(strings.Repeat("a", MinMaxFrameSize*4-sequenceFieldSize+1))
+		assert.Greater(t, len(manager.frames(message, data)), 1)
+	})
+
+	t.Run("frame size has a floor", func(t *testing.T) {
+		manager := NewManager(new(MockConnectionStore), NewMockAPIGatewayClient(), "wss://example.com")
+		manager.SetMaxFrameSize(10)
+		assert.Equal(t, MinMaxFrameSize, manager.maxFrameSize)
+	})
+}
+
+func TestManager_SendChunked(t *testing.T) {
+	mockStore := new(MockConnectionStore)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	apiGateway.AddConnection("conn456", "127.0.0.1")
+
+	mockStore.On("Get", mock.Anything, "conn123").Return(&store.Connection{ConnectionID: "conn123", LastPing: time.Now()}, nil)
+	mockStore.On("Get", mock.Anything, "conn456").Return(&store.Connection{ConnectionID: "conn456", LastPing: time.Now()}, nil)
+	mockStore.On("UpdateLastPing", mock.Anything, "conn123").Return(nil).Maybe()
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	manager.SetMaxFrameSize(MinMaxFrameSize * 4)
+
+	message := map[string]interface{}{
+		"type":       "response",
+		"request_id": "req_123",
+		"data":       strings.Repeat("prediction ", 2000),
+	}
+	expected, err := json.Marshal(message)
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Send(context.Background(), "conn123", message))
+	frames := apiGateway.GetMessages("conn123")
+	assert.Greater(t, len(frames), 1)
+	assert.Equal(t, expected, reassemble(t, frames))
+
+	require.NoError(t, manager.Broadcast(context.Background(), []string{"conn456"}, message))
+	assert.Equal(t, expected, reassemble(t, apiGateway.GetMessages("conn456")))
+
+	time.Sleep(10 * time.Millisecond)
+}
@@ -0,0 +1,352 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Circuit breaker defaults
+const (
+	DefaultBreakerFailureThreshold = 3
+	DefaultBreakerOpenTimeout      = 30 * time.Second
+	DefaultBreakerHalfOpenProbes   = 1
+	DefaultBreakerCacheTTL         = 2 * time.Second
+)
+
+// maxBreakerAttempts bounds the optimistic locking retries for one state change
+const maxBreakerAttempts = 3
+
+// CircuitBreakerConfig controls when breakers open and how they recover
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed sends that opens a
+	// breaker. Failures are counted by each instance.
+	FailureThreshold int
+
+	// OpenTimeout is how long an open breaker rejects sends before going half-open
+	OpenTimeout time.Duration
+
+	// HalfOpenProbes is how many sends a half-open breaker lets through per
+	// OpenTimeout. A successful probe closes the breaker; a failed one reopens it.
+	HalfOpenProbes int
+
+	// CacheTTL is how long an instance trusts breaker state it read from the
+	// store. Breakers opened elsewhere are honoured within this delay.
+	CacheTTL time.Duration
+}
+
+// withDefaults fills unset fields with the defaults
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = DefaultBreakerHalfOpenProbes
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = DefaultBreakerCacheTTL
+	}
+	return c
+}
+
+// breakerPhase is the state of a breaker at a point in time
+type breakerPhase int
+
+const (
+	breakerClosed breakerPhase = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker stops sending to connections that keep failing. State is
+// kept in a CircuitBreakerStore so that, with a shared backend, every Lambda
+// instance honours breakers opened by the others. The store is read at most
+// once per CacheTTL per connection and written only when a breaker opens,
+// claims a probe or closes. Store errors fail open.
+type CircuitBreaker struct {
+	store  store.CircuitBreakerStore
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	// cache holds state read from the store, including closed breakers, for
+	// CacheTTL so most sends do not touch the store
+	cache *breakerLRU
+
+	// failures counts consecutive failed sends on this instance until they
+	// reach the threshold
+	failures *breakerLRU
+
+	// observed holds non-closed state this instance has seen, so successes
+	// only write to the store when there is a breaker to close and open
+	// breakers can be counted without scanning the store
+	observed *breakerLRU
+
+	opened      atomic.Int64
+	probes      atomic.Int64
+	recovered   atomic.Int64
+	rejected    atomic.Int64
+	storeErrors atomic.Int64
+}
+
+// NewCircuitBreaker creates a circuit breaker backed by backend. A nil backend
+// keeps state in memory for this instance only.
+func NewCircuitBreaker(backend store.CircuitBreakerStore, config CircuitBreakerConfig) *CircuitBreaker {
+	if backend == nil {
+		backend = NewMemoryBreakerStore(0, 0)
+	}
+	config = config.withDefaults()
+	return &CircuitBreaker{
+		store:    backend,
+		config:   config,
+		now:      time.Now,
+		cache:    newBreakerLRU(0, config.CacheTTL),
+		failures: newBreakerLRU(0, 0),
+		observed: newBreakerLRU(0, 0),
+	}
+}
+
+// Allow reports whether a send to a connection may proceed. Once the open
+// timeout passes, the breaker admits HalfOpenProbes sends and rejects the
+// rest until a probe reports back or another timeout passes.
+func (cb *CircuitBreaker) Allow(ctx context.Context, connectionID string) bool {
+	state, ok := cb.cached(ctx, connectionID)
+	if !ok {
+		return true
+	}
+
+	now := cb.now()
+	switch cb.phase(state, now) {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		cb.rejected.Add(1)
+		return false
+	}
+
+	// Half-open: probe slots already seen taken stay taken for the window
+	if cb.probesTaken(state, now) {
+		cb.rejected.Add(1)
+		return false
+	}
+	return cb.claimProbe(ctx, connectionID)
+}
+
+// claimProbe takes a probe slot on a half-open breaker. Slots are shared by
+// every instance, so they are claimed against the store, not the cache.
+func (cb *CircuitBreaker) claimProbe(ctx context.Context, connectionID string) bool {
+	for attempt := 0; attempt < maxBreakerAttempts; attempt++ {
+		state, ok := cb.load(ctx, connectionID)
+		if !ok {
+			return true
+		}
+
+		now := cb.now()
+		switch cb.phase(state, now) {
+		case breakerClosed:
+			return true
+		case breakerOpen:
+			cb.rejected.Add(1)
+			return false
+		}
+
+		if cb.probesTaken(state, now) {
+			cb.rejected.Add(1)
+			return false
+		}
+		if state.ProbeWindow.IsZero() || !now.Before(state.ProbeWindow.Add(cb.config.OpenTimeout)) {
+			state.ProbeWindow = now
+			state.Probes = 0
+		}
+		state.Probes++
+
+		err := cb.store.Save(ctx, state)
+		if err == nil {
+			cb.remember(state)
+			cb.probes.Add(1)
+			return true
+		}
+		if !errors.Is(err, store.ErrConcurrentModification) {
+			cb.storeErrors.Add(1)
+			return true
+		}
+	}
+
+	// Lost every race for a probe slot; other instances are probing
+	cb.rejected.Add(1)
+	return false
+}
+
+// IsOpen reports whether a connection's breaker is rejecting sends. A
+// half-open breaker is not reported as open.
+func (cb *CircuitBreaker) IsOpen(ctx context.Context, connectionID string) bool {
+	state, ok := cb.cached(ctx, connectionID)
+	return ok && cb.phase(state, cb.now()) == breakerOpen
+}
+
+// RecordFailure counts a failed send, opening the breaker at the failure
+// threshold and reopening it when a half-open probe fails. Failures below
+// the threshold are only counted in memory.
+func (cb *CircuitBreaker) RecordFailure(ctx context.Context, connectionID string) {
+	now := cb.now()
+	failures := cb.failures.addFailure(connectionID, now)
+
+	phase := breakerClosed
+	if state, ok := cb.cached(ctx, connectionID); ok {
+		phase = cb.phase(state, now)
+	}
+	switch {
+	case phase == breakerOpen:
+		return
+	case phase == breakerClosed && failures < cb.config.FailureThreshold:
+		return
+	}
+	cb.open(ctx, connectionID, failures)
+}
+
+// open writes an open breaker to the store, unless another instance has
+// already opened it
+func (cb *CircuitBreaker) open(ctx context.Context, connectionID string, failures int) {
+	for attempt := 0; attempt < maxBreakerAttempts; attempt++ {
+		state, err := cb.store.Get(ctx, connectionID)
+		if err != nil {
+			if !store.IsNotFound(err) {
+				cb.storeErrors.Add(1)
+				return
+			}
+			state = &store.CircuitBreakerState{ConnectionID: connectionID}
+		}
+
+		now := cb.now()
+		if cb.phase(state, now) == breakerOpen {
+			cb.remember(state)
+			cb.failures.remove(connectionID)
+			return
+		}
+
+		state.Failures = failures
+		state.LastFailure = now
+		state.OpenedAt = now
+		state.Probes = 0
+		state.ProbeWindow = time.Time{}
+
+		err = cb.store.Save(ctx, state)
+		if err == nil {
+			cb.remember(state)
+			cb.failures.remove(connectionID)
+			cb.opened.Add(1)
+			return
+		}
+		if !errors.Is(err, store.ErrConcurrentModification) {
+			cb.storeErrors.Add(1)
+			return
+		}
+	}
+	cb.storeErrors.Add(1)
+}
+
+// RecordSuccess resets the failure count for a connection and closes its
+// breaker. The store is only written when this instance has seen the
+// breaker open or half-open.
+func (cb *CircuitBreaker) RecordSuccess(ctx context.Context, connectionID string) {
+	cb.failures.remove(connectionID)
+
+	if _, ok := cb.observed.get(connectionID); !ok {
+		return
+	}
+	if err := cb.store.Delete(ctx, connectionID); err != nil {
+		cb.storeErrors.Add(1)
+		return
+	}
+	cb.remember(&store.CircuitBreakerState{ConnectionID: connectionID})
+	cb.recovered.Add(1)
+}
+
+// CountOpen returns the number of open breakers this instance has seen
+func (cb *CircuitBreaker) CountOpen() int {
+	now := cb.now()
+	count := 0
+	cb.observed.each(func(state store.CircuitBreakerState) {
+		if cb.phase(&state, now) == breakerOpen {
+			count++
+		}
+	})
+	return count
+}
+
+// Stats returns breaker counters for metrics
+func (cb *CircuitBreaker) Stats() map[string]int64 {
+	return map[string]int64{
+		"open":         int64(cb.CountOpen()),
+		"opened":       cb.opened.Load(),
+		"probes":       cb.probes.Load(),
+		"recovered":    cb.recovered.Load(),
+		"rejected":     cb.rejected.Load(),
+		"store_errors": cb.storeErrors.Load(),
+	}
+}
+
+// cached returns breaker state no older than CacheTTL, reading the store on
+// a miss. It returns false when there is no state or the store is unavailable.
+func (cb *CircuitBreaker) cached(ctx context.Context, connectionID string) (*store.CircuitBreakerState, bool) {
+	if state, ok := cb.cache.get(connectionID); ok {
+		if state.OpenedAt.IsZero() {
+			return nil, false
+		}
+		return state, true
+	}
+	return cb.load(ctx, connectionID)
+}
+
+// load reads breaker state from the store and caches it. It returns false
+// when there is no state or the store is unavailable.
+func (cb *CircuitBreaker) load(ctx context.Context, connectionID string) (*store.CircuitBreakerState, bool) {
+	state, err := cb.store.Get(ctx, connectionID)
+	if err != nil {
+		if store.IsNotFound(err) {
+			cb.remember(&store.CircuitBreakerState{ConnectionID: connectionID})
+		} else {
+			cb.storeErrors.Add(1)
+		}
+		return nil, false
+	}
+
+	cb.remember(state)
+	return state, true
+}
+
+// remember caches state. Closed state is cached too, so healthy connections
+// are not read again until CacheTTL passes.
+func (cb *CircuitBreaker) remember(state *store.CircuitBreakerState) {
+	cb.cache.set(state)
+	if state.OpenedAt.IsZero() {
+		cb.observed.remove(state.ConnectionID)
+	} else {
+		cb.observed.set(state)
+	}
+}
+
+// probesTaken reports whether a half-open breaker has no probe slots left
+// in its current window
+func (cb *CircuitBreaker) probesTaken(state *store.CircuitBreakerState, now time.Time) bool {
+	return !state.ProbeWindow.IsZero() &&
+		now.Before(state.ProbeWindow.Add(cb.config.OpenTimeout)) &&
+		state.Probes >= cb.config.HalfOpenProbes
+}
+
+// phase reports whether a breaker is closed, open or half-open at now
+func (cb *CircuitBreaker) phase(state *store.CircuitBreakerState, now time.Time) breakerPhase {
+	switch {
+	case state.OpenedAt.IsZero():
+		return breakerClosed
+	case now.Before(state.OpenedAt.Add(cb.config.OpenTimeout)):
+		return breakerOpen
+	default:
+		return breakerHalfOpen
+	}
+}
@@ -0,0 +1,294 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// testClock is a manually advanced clock
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestBreaker returns a breaker and its backing store driven by clock
+func newTestBreaker(config CircuitBreakerConfig, clock *testClock) (*CircuitBreaker, *MemoryBreakerStore) {
+	backend := NewMemoryBreakerStore(0, 0)
+	backend.states.now = clock.Now
+	cb := NewCircuitBreaker(backend, config)
+	useClock(cb, clock)
+	return cb, backend
+}
+
+// useClock drives a breaker and its caches from clock
+func useClock(cb *CircuitBreaker, clock *testClock) {
+	cb.now = clock.Now
+	cb.cache.now = clock.Now
+	cb.failures.now = clock.Now
+	cb.observed.now = clock.Now
+}
+
+// countingBreakerStore counts the calls made to a breaker store
+type countingBreakerStore struct {
+	store.CircuitBreakerStore
+	gets, saves, deletes int
+}
+
+func (s *countingBreakerStore) Get(ctx context.Context, connectionID string) (*store.CircuitBreakerState, error) {
+	s.gets++
+	return s.CircuitBreakerStore.Get(ctx, connectionID)
+}
+
+func (s *countingBreakerStore) Save(ctx context.Context, state *store.CircuitBreakerState) error {
+	s.saves++
+	return s.CircuitBreakerStore.Save(ctx, state)
+}
+
+func (s *countingBreakerStore) Delete(ctx context.Context, connectionID string) error {
+	s.deletes++
+	return s.CircuitBreakerStore.Delete(ctx, connectionID)
+}
+
+// failingBreakerStore fails every operation
+type failingBreakerStore struct{}
+
+func (failingBreakerStore) Get(ctx context.Context, connectionID string) (*store.CircuitBreakerState, error) {
+	return nil, errors.New("table unavailable")
+}
+
+func (failingBreakerStore) Save(ctx context.Context, state *store.CircuitBreakerState) error {
+	return errors.New("table unavailable")
+}
+
+func (failingBreakerStore) Delete(ctx context.Context, connectionID string) error {
+	return errors.New("table unavailable")
+}
+
+func TestCircuitBreaker_OpensAtThreshold(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now()}
+	cb, _ := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute}, clock)
+
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+	cb.RecordFailure(ctx, "conn-1")
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+	assert.False(t, cb.IsOpen(ctx, "conn-1"))
+
+	cb.RecordFailure(ctx, "conn-1")
+	assert.False(t, cb.Allow(ctx, "conn-1"))
+	assert.True(t, cb.IsOpen(ctx, "conn-1"))
+	assert.Equal(t, 1, cb.CountOpen())
+
+	// Other connections are unaffected
+	assert.True(t, cb.Allow(ctx, "conn-2"))
+
+	stats := cb.Stats()
+	assert.Equal(t, int64(1), stats["open"])
+	assert.Equal(t, int64(1), stats["opened"])
+	assert.Equal(t, int64(1), stats["rejected"])
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now()}
+	cb, backend := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 2}, clock)
+
+	cb.RecordFailure(ctx, "conn-1")
+	cb.RecordSuccess(ctx, "conn-1")
+	cb.RecordFailure(ctx, "conn-1")
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+
+	// Failures below the threshold are only counted in memory
+	assert.Zero(t, backend.Len())
+
+	cb.RecordFailure(ctx, "conn-1")
+	assert.False(t, cb.Allow(ctx, "conn-1"))
+	assert.Equal(t, 1, backend.Len())
+}
+
+func TestCircuitBreaker_WritesOnlyOnTransitions(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now()}
+	backend := &countingBreakerStore{CircuitBreakerStore: NewMemoryBreakerStore(0, 0)}
+	cb := NewCircuitBreaker(backend, CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: 30 * time.Second, CacheTTL: time.Second})
+	useClock(cb, clock)
+
+	// Healthy sends read the store once per cache TTL and never write it
+	for i := 0; i < 10; i++ {
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+		cb.RecordSuccess(ctx, "conn-1")
+	}
+	assert.Equal(t, 1, backend.gets)
+	assert.Zero(t, backend.saves)
+	assert.Zero(t, backend.deletes)
+
+	clock.Advance(2 * time.Second)
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+	assert.Equal(t, 2, backend.gets)
+
+	// Opening is one write, and rejections are served from the cache
+	for i := 0; i < 3; i++ {
+		cb.RecordFailure(ctx, "conn-1")
+	}
+	assert.Equal(t, 1, backend.saves)
+	gets := backend.gets
+	for i := 0; i < 10; i++ {
+		assert.False(t, cb.Allow(ctx, "conn-1"))
+	}
+	assert.Equal(t, gets, backend.gets)
+
+	// Claiming the probe and closing are one write each
+	clock.Advance(31 * time.Second)
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+	assert.False(t, cb.Allow(ctx, "conn-1"))
+	assert.Equal(t, 2, backend.saves)
+	cb.RecordSuccess(ctx, "conn-1")
+	assert.Equal(t, 1, backend.deletes)
+
+	cb.RecordSuccess(ctx, "conn-1")
+	assert.Equal(t, 1, backend.deletes)
+}
+
+func TestCircuitBreaker_HalfOpenProbing(t *testing.T) {
+	ctx := context.Background()
+
+	open := func(t *testing.T, probes int) (*CircuitBreaker, *testClock) {
+		clock := &testClock{now: time.Now()}
+		cb, _ := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 30 * time.Second, HalfOpenProbes: probes}, clock)
+		cb.RecordFailure(ctx, "conn-1")
+		require.False(t, cb.Allow(ctx, "conn-1"))
+		clock.Advance(31 * time.Second)
+		return cb, clock
+	}
+
+	t.Run("successful probe closes the breaker", func(t *testing.T) {
+		cb, _ := open(t, 1)
+
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+		assert.False(t, cb.Allow(ctx, "conn-1"), "only one probe is admitted")
+		assert.False(t, cb.IsOpen(ctx, "conn-1"), "half-open is not open")
+
+		cb.RecordSuccess(ctx, "conn-1")
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+
+		stats := cb.Stats()
+		assert.Equal(t, int64(1), stats["probes"])
+		assert.Equal(t, int64(1), stats["recovered"])
+		assert.Zero(t, stats["open"])
+	})
+
+	t.Run("failed probe reopens the breaker", func(t *testing.T) {
+		cb, clock := open(t, 1)
+
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+		cb.RecordFailure(ctx, "conn-1")
+		assert.False(t, cb.Allow(ctx, "conn-1"))
+		assert.True(t, cb.IsOpen(ctx, "conn-1"))
+		assert.Equal(t, int64(2), cb.Stats()["opened"])
+
+		clock.Advance(31 * time.Second)
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+	})
+
+	t.Run("several probes per window", func(t *testing.T) {
+		cb, _ := open(t, 3)
+
+		for i := 0; i < 3; i++ {
+			assert.True(t, cb.Allow(ctx, "conn-1"), "probe %d", i)
+		}
+		assert.False(t, cb.Allow(ctx, "conn-1"))
+	})
+
+	t.Run("lost probes are replaced after the timeout", func(t *testing.T) {
+		cb, clock := open(t, 1)
+
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+		assert.False(t, cb.Allow(ctx, "conn-1"))
+
+		clock.Advance(31 * time.Second)
+		assert.True(t, cb.Allow(ctx, "conn-1"))
+	})
+}
+
+func TestCircuitBreaker_SharedState(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now()}
+	backend := NewMemoryBreakerStore(0, 0)
+	backend.states.now = clock.Now
+
+	config := CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 30 * time.Second, CacheTTL: time.Second}
+	first := NewCircuitBreaker(backend, config)
+	second := NewCircuitBreaker(backend, config)
+	useClock(first, clock)
+	useClock(second, clock)
+
+	// A breaker opened by one instance reaches the others within the cache TTL
+	assert.True(t, second.Allow(ctx, "conn-1"))
+	first.RecordFailure(ctx, "conn-1")
+	first.RecordFailure(ctx, "conn-1")
+	assert.False(t, first.Allow(ctx, "conn-1"))
+	assert.True(t, second.Allow(ctx, "conn-1"), "closed state is cached")
+
+	clock.Advance(2 * time.Second)
+	assert.False(t, second.Allow(ctx, "conn-1"))
+
+	// Only one instance wins the probe
+	clock.Advance(31 * time.Second)
+	assert.True(t, second.Allow(ctx, "conn-1"))
+	assert.False(t, first.Allow(ctx, "conn-1"))
+
+	// The probe result closes the breaker for everyone once their caches expire
+	second.RecordSuccess(ctx, "conn-1")
+	assert.True(t, second.Allow(ctx, "conn-1"))
+	clock.Advance(2 * time.Second)
+	assert.True(t, first.Allow(ctx, "conn-1"))
+}
+
+func TestCircuitBreaker_StoreErrorsFailOpen(t *testing.T) {
+	ctx := context.Background()
+	cb := NewCircuitBreaker(failingBreakerStore{}, CircuitBreakerConfig{FailureThreshold: 1})
+
+	cb.RecordFailure(ctx, "conn-1")
+	assert.True(t, cb.Allow(ctx, "conn-1"))
+	assert.False(t, cb.IsOpen(ctx, "conn-1"))
+	assert.Equal(t, int64(4), cb.Stats()["store_errors"])
+}
+
+func TestManager_SendCircuitBreaker(t *testing.T) {
+	mockStore := new(MockConnectionStore)
+	apiGateway := NewMockAPIGatewayClient()
+
+	mockStore.On("Get", mock.Anything, "conn-1").Return(&store.Connection{ConnectionID: "conn-1"}, nil)
+	apiGateway.On("PostToConnection", mock.Anything, "conn-1", mock.Anything).
+		Return(ForbiddenError{ConnectionID: "conn-1", Message: "forbidden"})
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	manager.SetCircuitBreaker(NewCircuitBreaker(nil, CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute}))
+
+	message := map[string]string{"type": "test"}
+	assert.Error(t, manager.Send(context.Background(), "conn-1", message))
+	assert.Error(t, manager.Send(context.Background(), "conn-1", message))
+
+	err := manager.Send(context.Background(), "conn-1", message)
+	assert.ErrorContains(t, err, "circuit breaker open")
+	apiGateway.AssertNumberOfCalls(t, "PostToConnection", 2)
+
+	metrics := manager.GetMetrics()
+	assert.Equal(t, 1, metrics["circuit_breakers_open"])
+	breaker := metrics["circuit_breaker"].(map[string]int64)
+	assert.Equal(t, int64(1), breaker["opened"])
+	assert.Equal(t, int64(1), breaker["rejected"])
+}
@@ -0,0 +1,93 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+)
+
+// connectionFormat returns the codec and compression encoding negotiated for a connection
+func connectionFormat(conn *store.Connection) (codec.Codec, string) {
+	return codec.Lookup(conn.Metadata[codec.MetadataKey]), conn.Metadata[MetadataEncodingKey]
+}
+
+// encodedFrames lazily encodes, compresses and chunks a broadcast message
+// once per codec and encoding in use
+type encodedFrames struct {
+	manager *Manager
+	message interface{}
+
+	mu      sync.Mutex
+	byCodec map[string][]byte
+	formats map[messageFormat]*encodedPayload
+}
+
+// messageFormat identifies how a message is delivered to a connection
+type messageFormat struct {
+	codec    string
+	encoding string
+}
+
+// encodedPayload is a message marshaled with one codec, compressed for one
+// encoding and split into frames. The frames are shared by every connection
+// using the format; stamping a frame copies it.
+type encodedPayload struct {
+	data    []byte
+	payload []byte
+	frames  []interface{}
+}
+
+// newEncodedFrames marshals message as JSON up front so a message that cannot
+// be encoded fails before anything is sent
+func newEncodedFrames(m *Manager, message interface{}) (*encodedFrames, error) {
+	data, err := codec.JSON.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return &encodedFrames{
+		manager: m,
+		message: message,
+		byCodec: map[string][]byte{codec.NameJSON: data},
+		formats: make(map[messageFormat]*encodedPayload),
+	}, nil
+}
+
+// forConnection returns the frames to post to a connection and the codec they
+// are encoded with. If the connection cannot be looked up the message is sent
+// as uncompressed JSON.
+func (e *encodedFrames) forConnection(ctx context.Context, connectionID string) (codec.Codec, []interface{}, error) {
+	c, encoding := codec.JSON, ""
+	if conn, err := e.manager.store.Get(ctx, connectionID); err == nil {
+		c, encoding = connectionFormat(conn)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	format := messageFormat{codec: c.Name(), encoding: encoding}
+	encoded, ok := e.formats[format]
+	if !ok {
+		data, ok := e.byCodec[c.Name()]
+		if !ok {
+			var err error
+			if data, err = c.Marshal(e.message); err != nil {
+				return nil, nil, fmt.Errorf("failed to marshal message: %w", err)
+			}
+			e.byCodec[c.Name()] = data
+		}
+
+		frame, payload, err := e.manager.encode(e.message, data, encoding, c)
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded = &encodedPayload{data: data, payload: payload, frames: e.manager.frames(frame, payload)}
+		e.formats[format] = encoded
+	}
+
+	e.manager.recordCompression(encoded.data, encoded.payload)
+	return c, encoded.frames, nil
+}
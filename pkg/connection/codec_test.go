@@ -0,0 +1,156 @@
+package connection
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+func TestManager_SendWithCodec(t *testing.T) {
+	message := map[string]interface{}{
+		"type":       "progress",
+		"request_id": "req_123",
+		"percentage": 50,
+	}
+
+	for _, c := range []codec.Codec{codec.JSON, codec.MessagePack, codec.CBOR} {
+		t.Run(c.Name(), func(t *testing.T) {
+			mockStore := new(MockConnectionStore)
+			apiGateway := NewTestableAPIGatewayClient()
+			apiGateway.AddConnection("conn123", "127.0.0.1")
+
+			mockStore.On("Get", mock.Anything, "conn123").Return(&store.Connection{
+				ConnectionID: "conn123",
+				LastPing:     time.Now(),
+				Metadata:     map[string]string{codec.MetadataKey: c.Name()},
+			}, nil)
+			mockStore.On("UpdateLastPing", mock.Anything, "conn123").Return(nil).Maybe()
+
+			manager := NewManager(mockStore, apiGateway, "wss://example.com")
+			manager.SetLogger(func(format string, args ...interface{}) {})
+
+			require.NoError(t, manager.Send(context.Background(), "conn123", message))
+
+			frames := apiGateway.GetMessages("conn123")
+			require.Len(t, frames, 1)
+
+			assert.Equal(t, sequenced(t, c, message, 1), frames[0])
+
+			var decoded map[string]interface{}
+			require.NoError(t, c.Unmarshal(frames[0], &decoded))
+			assert.Equal(t, "req_123", decoded["request_id"])
+			time.Sleep(10 * time.Millisecond)
+		})
+	}
+}
+
+func TestManager_SendWithCodecChunksAndCompresses(t *testing.T) {
+	mockStore := new(MockConnectionStore)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+
+	mockStore.On("Get", mock.Anything, "conn123").Return(&store.Connection{
+		ConnectionID: "conn123",
+		LastPing:     time.Now(),
+		Metadata: map[string]string{
+			codec.MetadataKey:   codec.NameMessagePack,
+			MetadataEncodingKey: EncodingGzip,
+		},
+	}, nil)
+	mockStore.On("UpdateLastPing", mock.Anything, "conn123").Return(nil).Maybe()
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	manager.SetMaxFrameSize(MinMaxFrameSize)
+
+	// Random-looking data so the compressed message still needs chunking
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		sb.WriteString(time.Duration(i * 7919).String())
+	}
+	message := map[string]interface{}{"type": "response", "data": sb.String()}
+
+	require.NoError(t, manager.Send(context.Background(), "conn123", message))
+
+	frames := apiGateway.GetMessages("conn123")
+	require.Greater(t, len(frames), 1)
+
+	var payload []byte
+	for _, frame := range frames {
+		var chunk types.ChunkMessage
+		require.NoError(t, codec.MessagePack.Unmarshal(frame, &chunk))
+		assert.Equal(t, types.MessageTypeChunk, chunk.Type)
+
+		fragment, err := base64.StdEncoding.DecodeString(chunk.Data)
+		require.NoError(t, err)
+		payload = append(payload, fragment...)
+	}
+
+	var compressed types.CompressedMessage
+	require.NoError(t, codec.MessagePack.Unmarshal(payload, &compressed))
+	assert.Equal(t, types.MessageTypeCompressed, compressed.Type)
+	assert.Equal(t, EncodingGzip, compressed.Encoding)
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestManager_BroadcastEncodesOncePerCodec(t *testing.T) {
+	mockStore := new(MockConnectionStore)
+	apiGateway := NewTestableAPIGatewayClient()
+
+	codecs := map[string]string{
+		"conn-json-1":  "",
+		"conn-json-2":  codec.NameJSON,
+		"conn-msgpack": codec.NameMessagePack,
+		"conn-cbor-1":  codec.NameCBOR,
+		"conn-cbor-2":  codec.NameCBOR,
+	}
+	var connIDs []string
+	for connID, name := range codecs {
+		connIDs = append(connIDs, connID)
+		apiGateway.AddConnection(connID, "127.0.0.1")
+		mockStore.On("Get", mock.Anything, connID).Return(&store.Connection{
+			ConnectionID: connID,
+			Metadata:     map[string]string{codec.MetadataKey: name},
+		}, nil)
+	}
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+
+	message := map[string]interface{}{"type": "broadcast", "message": "maintenance at 02:00"}
+	encoded, err := newEncodedFrames(manager, message)
+	require.NoError(t, err)
+
+	for _, connID := range connIDs {
+		c, frames, err := encoded.forConnection(context.Background(), connID)
+		require.NoError(t, err)
+		require.NoError(t, manager.deliver(context.Background(), newOutboundMessage(context.Background(), connID, c, frames, message)))
+	}
+
+	assert.Len(t, encoded.byCodec, 3)
+	assert.Len(t, encoded.formats, 3)
+
+	for connID, name := range codecs {
+		frames := apiGateway.GetMessages(connID)
+		require.Len(t, frames, 1, connID)
+
+		var decoded map[string]interface{}
+		require.NoError(t, codec.Lookup(name).Unmarshal(frames[0], &decoded), connID)
+		assert.Equal(t, "maintenance at 02:00", decoded["message"], connID)
+	}
+
+	t.Run("unencodable message fails before sending", func(t *testing.T) {
+		err := manager.Broadcast(context.Background(), connIDs, map[string]interface{}{"bad": make(chan int)})
+		assert.Error(t, err)
+	})
+}
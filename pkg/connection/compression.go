@@ -0,0 +1,125 @@
+package connection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// Supported message encodings
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// MetadataEncodingKey is the Connection.Metadata key holding the negotiated encoding
+const MetadataEncodingKey = "encoding"
+
+// DefaultCompressionThreshold is the smallest message compressed for connections that support it
+const DefaultCompressionThreshold = 1024
+
+// encodingPreference lists supported encodings from most to least preferred
+var encodingPreference = []string{EncodingBrotli, EncodingGzip}
+
+// NegotiateEncoding picks the preferred encoding from those a client offers.
+// Offers may be plain names ("gzip") or subprotocol names ("streamer.gzip").
+// It returns "" when none are supported.
+func NegotiateEncoding(offered []string) string {
+	supported := make(map[string]bool, len(offered))
+	for _, offer := range offered {
+		offer = strings.ToLower(strings.TrimSpace(offer))
+		supported[strings.TrimPrefix(offer, "streamer.")] = true
+	}
+
+	for _, encoding := range encodingPreference {
+		if supported[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// SetCompressionThreshold sets the smallest marshaled message that is
+// compressed for connections with a negotiated encoding. Zero or less
+// turns compression off.
+func (m *Manager) SetCompressionThreshold(threshold int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compressionThreshold = threshold
+}
+
+// encode compresses message into a compressed message, encoded with c, when
+// the encoding is supported, data (message encoded with c) is over the
+// threshold and compression actually saves bytes. It returns the frame to
+// send and its encoded form, which are message and data when the message
+// is not compressed.
+func (m *Manager) encode(message interface{}, data []byte, encoding string, c codec.Codec) (interface{}, []byte, error) {
+	m.mu.RLock()
+	threshold := m.compressionThreshold
+	m.mu.RUnlock()
+
+	if encoding == "" || threshold <= 0 || len(data) < threshold {
+		return message, data, nil
+	}
+
+	compressed, err := compress(encoding, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if compressed == nil {
+		// Unknown encoding; send uncompressed
+		return message, data, nil
+	}
+
+	frame := types.NewCompressedMessage(encoding, compressed)
+	payload, err := c.Marshal(frame)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal compressed message: %w", err)
+	}
+	if len(payload) >= len(data) {
+		return message, data, nil
+	}
+	return frame, payload, nil
+}
+
+// recordCompression adds one delivery to the compression savings metrics.
+// encode only returns a different payload when it is smaller, so a shorter
+// payload means the message was compressed.
+func (m *Manager) recordCompression(data, payload []byte) {
+	if len(payload) >= len(data) {
+		return
+	}
+	m.metrics.CompressedMessages.Add(1)
+	m.metrics.BytesBeforeCompression.Add(int64(len(data)))
+	m.metrics.BytesAfterCompression.Add(int64(len(payload)))
+}
+
+// compress compresses data with encoding. It returns nil for unsupported encodings.
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	default:
+		return nil, nil
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
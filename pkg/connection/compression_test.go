@@ -0,0 +1,162 @@
+package connection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// decodeFrame reverses compression the way a client would
+func decodeFrame(t *testing.T, frame []byte) []byte {
+	var msg types.CompressedMessage
+	require.NoError(t, json.Unmarshal(frame, &msg))
+	if msg.Type != types.MessageTypeCompressed {
+		return unsequenced(t, frame)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(msg.Data)
+	require.NoError(t, err)
+
+	var r io.Reader
+	switch msg.Encoding {
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(compressed))
+	default:
+		t.Fatalf("unexpected encoding %q", msg.Encoding)
+	}
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"gzip"}, EncodingGzip},
+		{[]string{"gzip", "br"}, EncodingBrotli},
+		{[]string{"streamer.v1", "streamer.gzip"}, EncodingGzip},
+		{[]string{" BR "}, EncodingBrotli},
+		{[]string{"deflate", "zstd"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NegotiateEncoding(tt.offered), "offered %v", tt.offered)
+	}
+}
+
+func TestManager_SendCompressed(t *testing.T) {
+	message := map[string]interface{}{
+		"type": "progress",
+		"data": strings.Repeat("processing batch ", 200),
+	}
+	expected, err := json.Marshal(message)
+	require.NoError(t, err)
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, "", "zstd"} {
+		t.Run("encoding "+encoding, func(t *testing.T) {
+			mockStore := new(MockConnectionStore)
+			apiGateway := NewTestableAPIGatewayClient()
+			apiGateway.AddConnection("conn123", "127.0.0.1")
+
+			mockStore.On("Get", mock.Anything, "conn123").Return(&store.Connection{
+				ConnectionID: "conn123",
+				LastPing:     time.Now(),
+				Metadata:     map[string]string{MetadataEncodingKey: encoding},
+			}, nil)
+			mockStore.On("UpdateLastPing", mock.Anything, "conn123").Return(nil).Maybe()
+
+			manager := NewManager(mockStore, apiGateway, "wss://example.com")
+			manager.SetLogger(func(format string, args ...interface{}) {})
+
+			require.NoError(t, manager.Send(context.Background(), "conn123", message))
+
+			frames := apiGateway.GetMessages("conn123")
+			require.Len(t, frames, 1)
+			assert.Equal(t, expected, decodeFrame(t, frames[0]))
+
+			compression := manager.GetMetrics()["compression"].(map[string]int64)
+			if encoding == EncodingGzip || encoding == EncodingBrotli {
+				assert.Less(t, len(frames[0]), len(expected))
+				assert.Equal(t, int64(1), compression["messages"])
+				assert.Equal(t, int64(len(expected)-len(unsequenced(t, frames[0]))), compression["bytes_saved"])
+			} else {
+				assert.Equal(t, sequenced(t, codec.JSON, message, 1), frames[0])
+				assert.Zero(t, compression["messages"])
+			}
+			time.Sleep(10 * time.Millisecond)
+		})
+	}
+
+	t.Run("small messages are not compressed", func(t *testing.T) {
+		manager := NewManager(new(MockConnectionStore), NewMockAPIGatewayClient(), "wss://example.com")
+		message := map[string]interface{}{"type": "pong"}
+		data := []byte(`{"type":"pong"}`)
+
+		frame, payload, err := manager.encode(message, data, EncodingGzip, codec.JSON)
+		require.NoError(t, err)
+		assert.Equal(t, message, frame)
+		assert.Equal(t, data, payload)
+
+		manager.SetCompressionThreshold(0)
+		frame, payload, err = manager.encode(message, bytes.Repeat([]byte("a"), 4096), EncodingGzip, codec.JSON)
+		require.NoError(t, err)
+		assert.Equal(t, message, frame)
+		assert.Len(t, payload, 4096)
+	})
+}
+
+func TestManager_BroadcastCompressed(t *testing.T) {
+	mockStore := new(MockConnectionStore)
+	apiGateway := NewTestableAPIGatewayClient()
+
+	encodings := map[string]string{"conn-gzip": EncodingGzip, "conn-br": EncodingBrotli, "conn-plain": ""}
+	for connID, encoding := range encodings {
+		apiGateway.AddConnection(connID, "127.0.0.1")
+		mockStore.On("Get", mock.Anything, connID).Return(&store.Connection{
+			ConnectionID: connID,
+			Metadata:     map[string]string{MetadataEncodingKey: encoding},
+		}, nil)
+	}
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+
+	message := map[string]interface{}{"type": "broadcast", "data": strings.Repeat("tenant update ", 300)}
+	expected, err := json.Marshal(message)
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Broadcast(context.Background(), []string{"conn-gzip", "conn-br", "conn-plain"}, message))
+
+	for connID := range encodings {
+		frames := apiGateway.GetMessages(connID)
+		require.Len(t, frames, 1, connID)
+		assert.Equal(t, expected, decodeFrame(t, frames[0]), connID)
+	}
+
+	compression := manager.GetMetrics()["compression"].(map[string]int64)
+	assert.Equal(t, int64(2), compression["messages"])
+	assert.Equal(t, int64(2*len(expected)), compression["bytes_before"])
+	assert.Positive(t, compression["bytes_saved"])
+}
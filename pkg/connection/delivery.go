@@ -0,0 +1,419 @@
+package connection
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Reliable delivery defaults
+const (
+	DefaultAckTimeout    = 30 * time.Second
+	DefaultMaxDeliveries = 5
+)
+
+// Reliable delivery message fields and connection metadata
+const (
+	// MetadataAckKey is the connection metadata key set to "true" when the
+	// client opted in to acking terminal messages
+	MetadataAckKey = "ack"
+
+	// DeliveryIDKey is the message field carrying the ID the client acks
+	DeliveryIDKey = "delivery_id"
+
+	// RedeliveredKey is set on messages sent again after a missed ack
+	RedeliveredKey = "redelivered"
+)
+
+// deliveryRetention bounds how long an unacked delivery is kept in the store
+const deliveryRetention = 24 * time.Hour
+
+// DefaultDeliveryMessageTypes are the message types tracked when none are configured
+var DefaultDeliveryMessageTypes = []string{"complete", "error"}
+
+// DeliveryConfig controls which messages are tracked and how they are redelivered
+type DeliveryConfig struct {
+	// AckTimeout is how long a delivery waits for an ack before it is redelivered
+	AckTimeout time.Duration
+
+	// MaxDeliveries is how many times a message is sent, including the first
+	// send, before it is dropped
+	MaxDeliveries int
+
+	// MessageTypes are the message types that require an ack
+	MessageTypes []string
+}
+
+// withDefaults fills unset fields with the defaults
+func (c DeliveryConfig) withDefaults() DeliveryConfig {
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = DefaultAckTimeout
+	}
+	if c.MaxDeliveries <= 0 {
+		c.MaxDeliveries = DefaultMaxDeliveries
+	}
+	if len(c.MessageTypes) == 0 {
+		c.MessageTypes = DefaultDeliveryMessageTypes
+	}
+	return c
+}
+
+// DeliveryTracker gives terminal messages at-least-once semantics for
+// connections that opted in with the ack connection metadata. Tracked
+// messages carry a delivery ID and are persisted per recipient until the
+// client acks them; unacked messages are redelivered on resume or after
+// AckTimeout, up to MaxDeliveries sends.
+type DeliveryTracker struct {
+	store  store.DeliveryStore
+	config DeliveryConfig
+	types  map[string]bool
+	now    func() time.Time
+
+	sent        atomic.Int64
+	redelivered atomic.Int64
+	acked       atomic.Int64
+	expired     atomic.Int64
+	storeErrors atomic.Int64
+}
+
+// NewDeliveryTracker creates a delivery tracker persisting to deliveries
+func NewDeliveryTracker(deliveries store.DeliveryStore, config DeliveryConfig) *DeliveryTracker {
+	config = config.withDefaults()
+
+	messageTypes := make(map[string]bool, len(config.MessageTypes))
+	for _, messageType := range config.MessageTypes {
+		messageTypes[messageType] = true
+	}
+
+	return &DeliveryTracker{
+		store:  deliveries,
+		config: config,
+		types:  messageTypes,
+		now:    time.Now,
+	}
+}
+
+// Stats returns delivery counters for this instance
+func (t *DeliveryTracker) Stats() map[string]int64 {
+	return map[string]int64{
+		"sent":         t.sent.Load(),
+		"redelivered":  t.redelivered.Load(),
+		"acked":        t.acked.Load(),
+		"expired":      t.expired.Load(),
+		"store_errors": t.storeErrors.Load(),
+	}
+}
+
+// SetDeliveryTracker enables reliable delivery for connections that opted in
+func (m *Manager) SetDeliveryTracker(tracker *DeliveryTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = tracker
+}
+
+// tracker returns the delivery tracker, or nil when reliable delivery is off
+func (m *Manager) tracker() *DeliveryTracker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.deliveries
+}
+
+// deliveryStats returns the tracker's counters, or zeros when reliable delivery is off
+func (m *Manager) deliveryStats() map[string]int64 {
+	if tracker := m.tracker(); tracker != nil {
+		return tracker.Stats()
+	}
+	return (&DeliveryTracker{}).Stats()
+}
+
+// wantsAcks reports whether the connection opted in to reliable delivery
+func wantsAcks(conn *store.Connection) bool {
+	return conn.Metadata[MetadataAckKey] == "true"
+}
+
+// track persists message for redelivery when the connection acks messages of
+// its type, returning message stamped with the delivery ID. Messages that
+// already carry a delivery ID are redeliveries and are sent as they are. If
+// the delivery cannot be persisted the message is sent untracked.
+func (m *Manager) track(ctx context.Context, conn *store.Connection, message interface{}) interface{} {
+	tracker := m.tracker()
+	if tracker == nil || !wantsAcks(conn) {
+		return message
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return message
+	}
+	var head struct {
+		Type       string `json:"type"`
+		DeliveryID string `json:"delivery_id"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil || !tracker.types[head.Type] || head.DeliveryID != "" {
+		return message
+	}
+
+	deliveryID, err := newDeliveryID()
+	if err != nil {
+		return message
+	}
+	stamped := withDeliveryID(message, deliveryID)
+	stored, err := json.Marshal(stamped)
+	if err != nil {
+		return message
+	}
+
+	now := tracker.now()
+	delivery := &store.PendingDelivery{
+		Recipient:     store.DeliveryRecipient(conn),
+		DeliveryID:    deliveryID,
+		ConnectionID:  conn.ConnectionID,
+		UserID:        conn.UserID,
+		TenantID:      conn.TenantID,
+		Message:       stored,
+		Attempts:      1,
+		CreatedAt:     now,
+		NextAttemptAt: now.Add(tracker.config.AckTimeout),
+		TTL:           now.Add(deliveryRetention).Unix(),
+	}
+	if err := tracker.store.Save(ctx, delivery); err != nil {
+		tracker.storeErrors.Add(1)
+		m.logger("Failed to persist delivery for connection %s, sending untracked: %v", conn.ConnectionID, err)
+		return message
+	}
+
+	tracker.sent.Add(1)
+	return stamped
+}
+
+// Ack removes acknowledged deliveries for the connection's recipient and
+// returns how many IDs were acked. Unknown IDs are ignored.
+func (m *Manager) Ack(ctx context.Context, connectionID string, deliveryIDs []string) (int, error) {
+	tracker := m.tracker()
+	if tracker == nil {
+		return 0, nil
+	}
+
+	conn, err := m.store.Get(ctx, connectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	recipient := store.DeliveryRecipient(conn)
+
+	acked := 0
+	for _, deliveryID := range deliveryIDs {
+		if err := tracker.store.Delete(ctx, recipient, deliveryID); err != nil {
+			tracker.storeErrors.Add(1)
+			return acked, fmt.Errorf("failed to ack delivery %s: %w", deliveryID, err)
+		}
+		acked++
+	}
+
+	tracker.acked.Add(int64(acked))
+	return acked, nil
+}
+
+// Resume redelivers every unacked message for the connection's recipient to
+// the connection, e.g. after a client reconnects. It returns how many
+// messages were sent.
+func (m *Manager) Resume(ctx context.Context, connectionID string) (int, error) {
+	tracker := m.tracker()
+	if tracker == nil {
+		return 0, nil
+	}
+
+	conn, err := m.store.Get(ctx, connectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	pending, err := tracker.store.ListByRecipient(ctx, store.DeliveryRecipient(conn))
+	if err != nil {
+		tracker.storeErrors.Add(1)
+		return 0, fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+
+	resent := 0
+	for _, delivery := range pending {
+		sent, err := m.redeliver(ctx, tracker, conn.ConnectionID, delivery)
+		if err != nil {
+			return resent, err
+		}
+		if sent {
+			resent++
+		}
+	}
+	return resent, nil
+}
+
+// Replay points every unacked message for conn's recipient at conn and makes
+// it due now, so the next RedeliverDue pass sends it there without using up
+// an attempt. The connect handler calls this because API Gateway does not
+// accept posts to a connection until $connect returns. It returns how many
+// deliveries were rescheduled.
+func (t *DeliveryTracker) Replay(ctx context.Context, conn *store.Connection) (int, error) {
+	if !wantsAcks(conn) {
+		return 0, nil
+	}
+
+	pending, err := t.store.ListByRecipient(ctx, store.DeliveryRecipient(conn))
+	if err != nil {
+		t.storeErrors.Add(1)
+		return 0, fmt.Errorf("failed to list pending deliveries: %w", err)
+	}
+
+	now := t.now()
+	replayed := 0
+	for _, delivery := range pending {
+		delivery.ConnectionID = conn.ConnectionID
+		delivery.NextAttemptAt = now
+		if err := t.store.Save(ctx, delivery); err != nil {
+			t.storeErrors.Add(1)
+			return replayed, fmt.Errorf("failed to reschedule delivery %s: %w", delivery.DeliveryID, err)
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// RedeliverDue redelivers up to limit messages whose ack timed out. A
+// delivery goes to the connection it was last sent to or, when that one is
+// gone, to another of the user's connections. It returns how many messages
+// were sent.
+func (m *Manager) RedeliverDue(ctx context.Context, limit int) (int, error) {
+	tracker := m.tracker()
+	if tracker == nil {
+		return 0, nil
+	}
+
+	due, err := tracker.store.ListDue(ctx, tracker.now(), limit)
+	if err != nil {
+		tracker.storeErrors.Add(1)
+		return 0, fmt.Errorf("failed to list due deliveries: %w", err)
+	}
+
+	resent := 0
+	for _, delivery := range due {
+		connectionID, err := m.redeliveryTarget(ctx, delivery)
+		if err != nil {
+			return resent, err
+		}
+
+		if connectionID == "" {
+			if delivery.UserID == "" {
+				// Connection-scoped deliveries die with their connection
+				if err := m.expire(ctx, tracker, delivery); err != nil {
+					return resent, err
+				}
+				continue
+			}
+
+			// Hold the message until the user reconnects without using up an attempt
+			delivery.NextAttemptAt = tracker.now().Add(tracker.config.AckTimeout)
+			if err := tracker.store.Save(ctx, delivery); err != nil {
+				tracker.storeErrors.Add(1)
+				return resent, fmt.Errorf("failed to reschedule delivery %s: %w", delivery.DeliveryID, err)
+			}
+			continue
+		}
+
+		sent, err := m.redeliver(ctx, tracker, connectionID, delivery)
+		if err != nil {
+			return resent, err
+		}
+		if sent {
+			resent++
+		}
+	}
+	return resent, nil
+}
+
+// redeliveryTarget picks the connection a due delivery is sent to, or ""
+// when the recipient has no active connection
+func (m *Manager) redeliveryTarget(ctx context.Context, delivery *store.PendingDelivery) (string, error) {
+	if _, err := m.store.Get(ctx, delivery.ConnectionID); err == nil {
+		return delivery.ConnectionID, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return "", fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	if delivery.UserID == "" {
+		return "", nil
+	}
+
+	pages := store.NewPaginator(0, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return m.store.ListByUser(ctx, delivery.UserID, opts)
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list user connections: %w", err)
+		}
+		for _, conn := range page.Items {
+			if conn.TenantID == delivery.TenantID && wantsAcks(conn) {
+				return conn.ConnectionID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// redeliver sends a pending delivery again, dropping it once it has been
+// sent MaxDeliveries times. The attempt is recorded before sending so a
+// failed send is retried on the next pass.
+func (m *Manager) redeliver(ctx context.Context, tracker *DeliveryTracker, connectionID string, delivery *store.PendingDelivery) (bool, error) {
+	if delivery.Attempts >= tracker.config.MaxDeliveries {
+		return false, m.expire(ctx, tracker, delivery)
+	}
+
+	var message map[string]interface{}
+	if err := json.Unmarshal(delivery.Message, &message); err != nil {
+		m.logger("Dropping undecodable delivery %s: %v", delivery.DeliveryID, err)
+		return false, m.expire(ctx, tracker, delivery)
+	}
+	message[DeliveryIDKey] = delivery.DeliveryID
+	message[RedeliveredKey] = true
+
+	delivery.Attempts++
+	delivery.ConnectionID = connectionID
+	delivery.NextAttemptAt = tracker.now().Add(tracker.config.AckTimeout)
+	if err := tracker.store.Save(ctx, delivery); err != nil {
+		tracker.storeErrors.Add(1)
+		return false, fmt.Errorf("failed to record delivery attempt %s: %w", delivery.DeliveryID, err)
+	}
+
+	if err := m.Send(ctx, connectionID, message); err != nil {
+		m.logger("Failed to redeliver %s to connection %s: %v", delivery.DeliveryID, connectionID, err)
+		return false, nil
+	}
+
+	tracker.redelivered.Add(1)
+	return true, nil
+}
+
+// expire drops a delivery that will not be sent again
+func (m *Manager) expire(ctx context.Context, tracker *DeliveryTracker, delivery *store.PendingDelivery) error {
+	if err := tracker.store.Delete(ctx, delivery.Recipient, delivery.DeliveryID); err != nil {
+		tracker.storeErrors.Add(1)
+		return fmt.Errorf("failed to expire delivery %s: %w", delivery.DeliveryID, err)
+	}
+	tracker.expired.Add(1)
+	m.logger("Dropping unacked delivery %s after %d attempts", delivery.DeliveryID, delivery.Attempts)
+	return nil
+}
+
+// newDeliveryID returns a random delivery ID
+func newDeliveryID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "dlv_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,378 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// memoryDeliveryStore is an in-memory store.DeliveryStore for tests
+type memoryDeliveryStore struct {
+	mu         sync.Mutex
+	deliveries map[string]store.PendingDelivery
+	saveErr    error
+}
+
+func newMemoryDeliveryStore() *memoryDeliveryStore {
+	return &memoryDeliveryStore{deliveries: make(map[string]store.PendingDelivery)}
+}
+
+func (s *memoryDeliveryStore) Save(ctx context.Context, delivery *store.PendingDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.deliveries[delivery.Recipient+"|"+delivery.DeliveryID] = *delivery
+	return nil
+}
+
+func (s *memoryDeliveryStore) Delete(ctx context.Context, recipient, deliveryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deliveries, recipient+"|"+deliveryID)
+	return nil
+}
+
+func (s *memoryDeliveryStore) ListByRecipient(ctx context.Context, recipient string) ([]*store.PendingDelivery, error) {
+	return s.list(func(d store.PendingDelivery) bool { return d.Recipient == recipient }), nil
+}
+
+func (s *memoryDeliveryStore) ListDue(ctx context.Context, before time.Time, limit int) ([]*store.PendingDelivery, error) {
+	due := s.list(func(d store.PendingDelivery) bool { return !d.NextAttemptAt.After(before) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *memoryDeliveryStore) list(match func(store.PendingDelivery) bool) []*store.PendingDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*store.PendingDelivery
+	for _, d := range s.deliveries {
+		if match(d) {
+			delivery := d
+			result = append(result, &delivery)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result
+}
+
+func (s *memoryDeliveryStore) all() []*store.PendingDelivery {
+	return s.list(func(store.PendingDelivery) bool { return true })
+}
+
+// newDeliveryTestManager creates a manager with reliable delivery enabled and
+// a clock the test controls
+func newDeliveryTestManager(t *testing.T, connStore *MockConnectionStore, apiGateway APIGatewayClient) (*Manager, *memoryDeliveryStore, *time.Time) {
+	deliveries := newMemoryDeliveryStore()
+	now := time.Now()
+
+	tracker := NewDeliveryTracker(deliveries, DeliveryConfig{AckTimeout: 30 * time.Second, MaxDeliveries: 3})
+	tracker.now = func() time.Time { return now }
+
+	connStore.On("UpdateLastPing", mock.Anything, mock.Anything).Return(nil).Maybe()
+	manager := NewManager(connStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	manager.SetDeliveryTracker(tracker)
+	t.Cleanup(func() { time.Sleep(10 * time.Millisecond) })
+	return manager, deliveries, &now
+}
+
+func ackingConnection(connectionID string) *store.Connection {
+	return &store.Connection{
+		ConnectionID: connectionID,
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Metadata:     map[string]string{MetadataAckKey: "true"},
+	}
+}
+
+// sentMessages decodes every frame sent to a connection
+func sentMessages(t *testing.T, apiGateway *TestableAPIGatewayClient, connectionID string) []map[string]interface{} {
+	var sent []map[string]interface{}
+	for _, frame := range apiGateway.GetMessages(connectionID) {
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(frame, &msg))
+		sent = append(sent, msg)
+	}
+	return sent
+}
+
+func TestManager_SendTracksTerminalMessages(t *testing.T) {
+	tests := []struct {
+		name        string
+		conn        *store.Connection
+		message     map[string]interface{}
+		wantTracked bool
+	}{
+		{
+			name:        "complete message to acking connection",
+			conn:        ackingConnection("conn-1"),
+			message:     map[string]interface{}{"type": "complete", "request_id": "req-1"},
+			wantTracked: true,
+		},
+		{
+			name:        "error message to acking connection",
+			conn:        ackingConnection("conn-1"),
+			message:     map[string]interface{}{"type": "error", "request_id": "req-1"},
+			wantTracked: true,
+		},
+		{
+			name:    "progress messages are not tracked",
+			conn:    ackingConnection("conn-1"),
+			message: map[string]interface{}{"type": "progress", "request_id": "req-1"},
+		},
+		{
+			name:    "connection without ack opt-in",
+			conn:    &store.Connection{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"},
+			message: map[string]interface{}{"type": "complete", "request_id": "req-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connStore := new(MockConnectionStore)
+			connStore.On("Get", mock.Anything, "conn-1").Return(tt.conn, nil)
+			apiGateway := NewTestableAPIGatewayClient()
+			apiGateway.AddConnection("conn-1", "127.0.0.1")
+			manager, deliveries, _ := newDeliveryTestManager(t, connStore, apiGateway)
+
+			require.NoError(t, manager.Send(context.Background(), "conn-1", tt.message))
+
+			sent := sentMessages(t, apiGateway, "conn-1")
+			require.Len(t, sent, 1)
+			pending := deliveries.all()
+
+			if !tt.wantTracked {
+				assert.NotContains(t, sent[0], DeliveryIDKey)
+				assert.Empty(t, pending)
+				return
+			}
+
+			require.Len(t, pending, 1)
+			assert.Equal(t, pending[0].DeliveryID, sent[0][DeliveryIDKey])
+			assert.Equal(t, "USER#tenant-1#user-1", pending[0].Recipient)
+			assert.Equal(t, 1, pending[0].Attempts)
+
+			var stored map[string]interface{}
+			require.NoError(t, json.Unmarshal(pending[0].Message, &stored))
+			assert.Equal(t, pending[0].DeliveryID, stored[DeliveryIDKey])
+			assert.Equal(t, "req-1", stored["request_id"])
+			assert.Equal(t, int64(1), manager.GetMetrics()["delivery"].(map[string]int64)["sent"])
+		})
+	}
+}
+
+func TestManager_SendUntrackedWhenStoreFails(t *testing.T) {
+	connStore := new(MockConnectionStore)
+	connStore.On("Get", mock.Anything, "conn-1").Return(ackingConnection("conn-1"), nil)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	manager, deliveries, _ := newDeliveryTestManager(t, connStore, apiGateway)
+	deliveries.saveErr = errors.New("dynamodb unavailable")
+
+	require.NoError(t, manager.Send(context.Background(), "conn-1", map[string]interface{}{"type": "complete"}))
+
+	sent := sentMessages(t, apiGateway, "conn-1")
+	require.Len(t, sent, 1)
+	assert.NotContains(t, sent[0], DeliveryIDKey)
+	assert.Equal(t, int64(1), manager.GetMetrics()["delivery"].(map[string]int64)["store_errors"])
+}
+
+func TestManager_AckAndResume(t *testing.T) {
+	ctx := context.Background()
+	connStore := new(MockConnectionStore)
+	connStore.On("Get", mock.Anything, "conn-1").Return(ackingConnection("conn-1"), nil)
+	connStore.On("Get", mock.Anything, "conn-2").Return(ackingConnection("conn-2"), nil)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	apiGateway.AddConnection("conn-2", "127.0.0.1")
+	manager, deliveries, now := newDeliveryTestManager(t, connStore, apiGateway)
+
+	for _, requestID := range []string{"req-1", "req-2"} {
+		require.NoError(t, manager.Send(ctx, "conn-1", map[string]interface{}{"type": "complete", "request_id": requestID}))
+		*now = now.Add(time.Second)
+	}
+	sent := sentMessages(t, apiGateway, "conn-1")
+	require.Len(t, sent, 2)
+
+	// The client acks the first message before reconnecting
+	acked, err := manager.Ack(ctx, "conn-1", []string{sent[0][DeliveryIDKey].(string)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, acked)
+	require.Len(t, deliveries.all(), 1)
+
+	// The unacked message follows the user to their new connection
+	resent, err := manager.Resume(ctx, "conn-2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, resent)
+
+	redelivered := sentMessages(t, apiGateway, "conn-2")
+	require.Len(t, redelivered, 1)
+	assert.Equal(t, sent[1][DeliveryIDKey], redelivered[0][DeliveryIDKey])
+	assert.Equal(t, "req-2", redelivered[0]["request_id"])
+	assert.Equal(t, true, redelivered[0][RedeliveredKey])
+
+	pending := deliveries.all()
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].Attempts)
+	assert.Equal(t, "conn-2", pending[0].ConnectionID)
+
+	stats := manager.GetMetrics()["delivery"].(map[string]int64)
+	assert.Equal(t, int64(2), stats["sent"])
+	assert.Equal(t, int64(1), stats["acked"])
+	assert.Equal(t, int64(1), stats["redelivered"])
+}
+
+func TestManager_RedeliverDue(t *testing.T) {
+	ctx := context.Background()
+	connStore := new(MockConnectionStore)
+	connStore.On("Get", mock.Anything, "conn-1").Return(ackingConnection("conn-1"), nil)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	manager, deliveries, now := newDeliveryTestManager(t, connStore, apiGateway)
+
+	require.NoError(t, manager.Send(ctx, "conn-1", map[string]interface{}{"type": "complete", "request_id": "req-1"}))
+
+	// Nothing is due before the ack timeout
+	resent, err := manager.RedeliverDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resent)
+
+	// Each timeout sends the message again until MaxDeliveries is reached
+	for attempt := 2; attempt <= 3; attempt++ {
+		*now = now.Add(31 * time.Second)
+		resent, err = manager.RedeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, resent)
+		require.Len(t, deliveries.all(), 1)
+		assert.Equal(t, attempt, deliveries.all()[0].Attempts)
+	}
+
+	*now = now.Add(31 * time.Second)
+	resent, err = manager.RedeliverDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resent)
+	assert.Empty(t, deliveries.all())
+
+	assert.Len(t, apiGateway.GetMessages("conn-1"), 3)
+	assert.Equal(t, int64(1), manager.GetMetrics()["delivery"].(map[string]int64)["expired"])
+}
+
+func TestManager_RedeliverDueWithoutConnection(t *testing.T) {
+	ctx := context.Background()
+	connStore := new(MockConnectionStore)
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn-2", "127.0.0.1")
+	manager, deliveries, now := newDeliveryTestManager(t, connStore, apiGateway)
+
+	created := *now
+	userDelivery := &store.PendingDelivery{
+		Recipient: "USER#tenant-1#user-1", DeliveryID: "dlv-user", ConnectionID: "conn-1",
+		UserID: "user-1", TenantID: "tenant-1", Message: []byte(`{"type":"complete"}`),
+		Attempts: 1, CreatedAt: created, NextAttemptAt: created,
+	}
+	connDelivery := &store.PendingDelivery{
+		Recipient: "CONN#conn-3", DeliveryID: "dlv-conn", ConnectionID: "conn-3",
+		Message: []byte(`{"type":"complete"}`), Attempts: 1, CreatedAt: created.Add(time.Millisecond), NextAttemptAt: created,
+	}
+	require.NoError(t, deliveries.Save(ctx, userDelivery))
+	require.NoError(t, deliveries.Save(ctx, connDelivery))
+
+	connStore.On("Get", mock.Anything, "conn-1").Return(nil, store.ErrNotFound)
+	connStore.On("Get", mock.Anything, "conn-3").Return(nil, store.ErrNotFound)
+
+	// With no connection for the user the delivery waits without using an attempt,
+	// while the connection-scoped delivery is dropped
+	connStore.On("ListByUser", mock.Anything, "user-1", mock.Anything).Return(&store.Page[*store.Connection]{}, nil).Once()
+	resent, err := manager.RedeliverDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resent)
+
+	pending := deliveries.all()
+	require.Len(t, pending, 1)
+	assert.Equal(t, "dlv-user", pending[0].DeliveryID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, created.Add(30*time.Second), pending[0].NextAttemptAt)
+
+	// Once the user reconnects the delivery goes to the new connection
+	*now = now.Add(31 * time.Second)
+	connStore.On("ListByUser", mock.Anything, "user-1", mock.Anything).Return(&store.Page[*store.Connection]{Items: []*store.Connection{
+		{ConnectionID: "conn-4", UserID: "user-1", TenantID: "tenant-2", Metadata: map[string]string{MetadataAckKey: "true"}},
+		ackingConnection("conn-2"),
+	}}, nil)
+	connStore.On("Get", mock.Anything, "conn-2").Return(ackingConnection("conn-2"), nil)
+
+	resent, err = manager.RedeliverDue(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resent)
+
+	sent := sentMessages(t, apiGateway, "conn-2")
+	require.Len(t, sent, 1)
+	assert.Equal(t, "dlv-user", sent[0][DeliveryIDKey])
+	assert.Equal(t, true, sent[0][RedeliveredKey])
+}
+
+func TestDeliveryTracker_Replay(t *testing.T) {
+	ctx := context.Background()
+	deliveries := newMemoryDeliveryStore()
+	now := time.Now()
+	tracker := NewDeliveryTracker(deliveries, DeliveryConfig{AckTimeout: 30 * time.Second})
+	tracker.now = func() time.Time { return now }
+
+	created := now.Add(-time.Minute)
+	require.NoError(t, deliveries.Save(ctx, &store.PendingDelivery{
+		Recipient: "USER#tenant-1#user-1", DeliveryID: "dlv-1", ConnectionID: "conn-1",
+		UserID: "user-1", TenantID: "tenant-1", Message: []byte(`{"type":"complete"}`),
+		Attempts: 2, CreatedAt: created, NextAttemptAt: now.Add(20 * time.Second),
+	}))
+	require.NoError(t, deliveries.Save(ctx, &store.PendingDelivery{
+		Recipient: "USER#tenant-2#user-1", DeliveryID: "dlv-2", ConnectionID: "conn-9",
+		UserID: "user-1", TenantID: "tenant-2", Message: []byte(`{"type":"complete"}`),
+		Attempts: 1, CreatedAt: created, NextAttemptAt: now.Add(20 * time.Second),
+	}))
+
+	// Connections that do not ack have nothing to replay
+	replayed, err := tracker.Replay(ctx, &store.Connection{ConnectionID: "conn-2", UserID: "user-1", TenantID: "tenant-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, replayed)
+
+	replayed, err = tracker.Replay(ctx, ackingConnection("conn-2"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+
+	due, err := deliveries.ListDue(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "dlv-1", due[0].DeliveryID)
+	assert.Equal(t, "conn-2", due[0].ConnectionID)
+	assert.Equal(t, 2, due[0].Attempts, "replaying does not use up an attempt")
+}
+
+func TestManager_DeliveryDisabled(t *testing.T) {
+	manager := NewManager(new(MockConnectionStore), NewTestableAPIGatewayClient(), "wss://example.com")
+
+	acked, err := manager.Ack(context.Background(), "conn-1", []string{"dlv-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, acked)
+
+	resent, err := manager.RedeliverDue(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, resent)
+
+	assert.Equal(t, int64(0), manager.GetMetrics()["delivery"].(map[string]int64)["sent"])
+}
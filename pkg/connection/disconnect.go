@@ -0,0 +1,143 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// DisconnectReason tells a client why the server closed its connection
+type DisconnectReason struct {
+	// Code is one of the types.CloseCode* values
+	Code string
+
+	// Message is a human-readable explanation
+	Message string
+}
+
+// Common disconnect reasons
+var (
+	ReasonTokenRevoked    = DisconnectReason{Code: types.CloseCodeTokenRevoked, Message: "Your session was revoked"}
+	ReasonTenantSuspended = DisconnectReason{Code: types.CloseCodeTenantSuspended, Message: "Your account is suspended"}
+	ReasonServerClosed    = DisconnectReason{Code: types.CloseCodeServerClosed, Message: "Connection closed by the server"}
+)
+
+// Disconnect sends a connection a final close message with the reason, then
+// closes its socket through API Gateway and removes it from the store
+func (m *Manager) Disconnect(ctx context.Context, connectionID string, reason DisconnectReason) error {
+	conn, err := m.store.Get(ctx, connectionID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrConnectionNotFound
+		}
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	return m.disconnect(ctx, conn, reason)
+}
+
+// DisconnectUser disconnects every connection of a user, e.g. after their
+// token is revoked. It returns how many connections were closed.
+func (m *Manager) DisconnectUser(ctx context.Context, userID string, reason DisconnectReason) (int, error) {
+	return m.disconnectPages(ctx, "user "+userID, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return m.store.ListByUser(ctx, userID, opts)
+	}, reason)
+}
+
+// DisconnectTenant disconnects every connection of a tenant, e.g. when the
+// tenant is suspended. It returns how many connections were closed.
+func (m *Manager) DisconnectTenant(ctx context.Context, tenantID string, reason DisconnectReason) (int, error) {
+	return m.disconnectPages(ctx, "tenant "+tenantID, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return m.store.ListByTenant(ctx, tenantID, opts)
+	}, reason)
+}
+
+// disconnectPages disconnects each page of connections that list returns. A
+// failed page does not stop the rest; the first failure is returned.
+func (m *Manager) disconnectPages(ctx context.Context, owner string, list store.PageFunc[*store.Connection], reason DisconnectReason) (int, error) {
+	var (
+		closed int
+		failed error
+	)
+	err := eachConnectionPage(ctx, owner, list, func(conns []*store.Connection) {
+		n, err := m.disconnectAll(ctx, conns, reason)
+		closed += n
+		if err != nil && failed == nil {
+			failed = err
+		}
+	})
+	if err != nil {
+		return closed, err
+	}
+	return closed, failed
+}
+
+// disconnectAll disconnects connections in parallel using the worker pool
+func (m *Manager) disconnectAll(ctx context.Context, conns []*store.Connection, reason DisconnectReason) (int, error) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		closed int
+		errs   []error
+	)
+
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *store.Connection) {
+			defer wg.Done()
+
+			m.workerPool <- struct{}{}
+			err := m.disconnect(ctx, conn, reason)
+			<-m.workerPool
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			closed++
+		}(conn)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		m.logger("Disconnect completed with %d errors out of %d connections", len(errs), len(conns))
+		return closed, fmt.Errorf("disconnect had %d failures: %w", len(errs), errs[0])
+	}
+	return closed, nil
+}
+
+// disconnect closes one connection. The close notice is queued behind any
+// messages already on their way, and failing to send it does not stop the
+// socket from being closed.
+func (m *Manager) disconnect(ctx context.Context, conn *store.Connection, reason DisconnectReason) error {
+	c, _ := connectionFormat(conn)
+	notice := types.NewCloseMessage(reason.Code, reason.Message)
+	err := m.deliver(ctx, newOutboundMessage(ctx, conn.ConnectionID, c, []interface{}{notice}, notice))
+
+	gone := isConnectionGone(err)
+	if err != nil && !gone {
+		m.logger("Failed to send close notice to connection %s: %v", conn.ConnectionID, err)
+	}
+
+	// A connection that is already gone has no socket left to close
+	if !gone {
+		if err := m.apiGateway.DeleteConnection(ctx, conn.ConnectionID); err != nil && !isConnectionGone(err) {
+			m.metrics.ErrorsByType["network_error"].Add(1)
+			return fmt.Errorf("failed to close connection %s: %w", conn.ConnectionID, err)
+		}
+	}
+
+	// The $disconnect handler may have removed the record already
+	if err := m.store.Delete(ctx, conn.ConnectionID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to delete connection %s: %w", conn.ConnectionID, err)
+	}
+
+	m.logger("Disconnected connection %s: %s", conn.ConnectionID, reason.Code)
+	return nil
+}
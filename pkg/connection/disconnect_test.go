@@ -0,0 +1,237 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// closingAPIGateway keeps the frames sent to a connection before it was
+// deleted, which TestableAPIGatewayClient discards
+type closingAPIGateway struct {
+	*TestableAPIGatewayClient
+
+	mu        sync.Mutex
+	closed    map[string][][]byte
+	deleteErr error
+}
+
+func newClosingAPIGateway() *closingAPIGateway {
+	return &closingAPIGateway{
+		TestableAPIGatewayClient: NewTestableAPIGatewayClient(),
+		closed:                   make(map[string][][]byte),
+	}
+}
+
+func (c *closingAPIGateway) DeleteConnection(ctx context.Context, connectionID string) error {
+	if c.deleteErr != nil {
+		return c.deleteErr
+	}
+
+	frames := c.GetMessages(connectionID)
+	if err := c.TestableAPIGatewayClient.DeleteConnection(ctx, connectionID); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed[connectionID] = frames
+	return nil
+}
+
+func (c *closingAPIGateway) closedWith(t *testing.T, connectionID string) []map[string]interface{} {
+	c.mu.Lock()
+	frames, ok := c.closed[connectionID]
+	c.mu.Unlock()
+	require.True(t, ok, "connection %s was not closed", connectionID)
+
+	var sent []map[string]interface{}
+	for _, frame := range frames {
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(frame, &msg))
+		sent = append(sent, msg)
+	}
+	return sent
+}
+
+func newDisconnectTestManager(connStore *MockConnectionStore, apiGateway APIGatewayClient) *Manager {
+	manager := NewManager(connStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	return manager
+}
+
+func TestManager_Disconnect(t *testing.T) {
+	conn := &store.Connection{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"}
+
+	connStore := new(MockConnectionStore)
+	connStore.On("Get", mock.Anything, "conn-1").Return(conn, nil)
+	connStore.On("Delete", mock.Anything, "conn-1").Return(nil)
+	apiGateway := newClosingAPIGateway()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	manager := newDisconnectTestManager(connStore, apiGateway)
+
+	err := manager.Disconnect(context.Background(), "conn-1", ReasonTokenRevoked)
+	require.NoError(t, err)
+
+	sent := apiGateway.closedWith(t, "conn-1")
+	require.Len(t, sent, 1)
+	assert.Equal(t, "close", sent[0]["type"])
+	assert.Equal(t, types.CloseCodeTokenRevoked, sent[0]["code"])
+	assert.Equal(t, ReasonTokenRevoked.Message, sent[0]["reason"])
+	connStore.AssertExpectations(t)
+}
+
+func TestManager_DisconnectErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		getErr     error
+		deleteErr  error
+		gone       bool
+		wantErr    error
+		wantDelete bool
+	}{
+		{
+			name:    "connection not found",
+			getErr:  store.ErrNotFound,
+			wantErr: ErrConnectionNotFound,
+		},
+		{
+			name:       "socket already gone",
+			gone:       true,
+			wantDelete: true,
+		},
+		{
+			name:      "API Gateway fails to close the socket",
+			deleteErr: errors.New("service unavailable"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &store.Connection{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"}
+
+			connStore := new(MockConnectionStore)
+			if tt.getErr != nil {
+				connStore.On("Get", mock.Anything, "conn-1").Return(nil, tt.getErr)
+			} else {
+				connStore.On("Get", mock.Anything, "conn-1").Return(conn, nil)
+			}
+			if tt.wantDelete {
+				connStore.On("Delete", mock.Anything, "conn-1").Return(nil)
+			}
+
+			apiGateway := newClosingAPIGateway()
+			apiGateway.deleteErr = tt.deleteErr
+			if !tt.gone {
+				apiGateway.AddConnection("conn-1", "127.0.0.1")
+			}
+			manager := newDisconnectTestManager(connStore, apiGateway)
+
+			err := manager.Disconnect(context.Background(), "conn-1", ReasonServerClosed)
+
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.deleteErr != nil:
+				assert.ErrorIs(t, err, tt.deleteErr)
+			default:
+				assert.NoError(t, err)
+			}
+			if !tt.wantDelete {
+				connStore.AssertNotCalled(t, "Delete", mock.Anything, "conn-1")
+			}
+			connStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestManager_DisconnectUser(t *testing.T) {
+	conns := []*store.Connection{
+		{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"},
+		{ConnectionID: "conn-2", UserID: "user-1", TenantID: "tenant-1"},
+	}
+
+	connStore := new(MockConnectionStore)
+	connStore.On("ListByUser", mock.Anything, "user-1", mock.Anything).Return(&store.Page[*store.Connection]{Items: conns}, nil)
+	connStore.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	apiGateway := newClosingAPIGateway()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	apiGateway.AddConnection("conn-2", "127.0.0.1")
+	manager := newDisconnectTestManager(connStore, apiGateway)
+
+	closed, err := manager.DisconnectUser(context.Background(), "user-1", ReasonTokenRevoked)
+	require.NoError(t, err)
+	assert.Equal(t, 2, closed)
+
+	for _, conn := range conns {
+		sent := apiGateway.closedWith(t, conn.ConnectionID)
+		require.Len(t, sent, 1)
+		assert.Equal(t, types.CloseCodeTokenRevoked, sent[0]["code"])
+	}
+	connStore.AssertNumberOfCalls(t, "Delete", 2)
+}
+
+func TestManager_DisconnectTenant(t *testing.T) {
+	conns := []*store.Connection{
+		{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"},
+		{ConnectionID: "conn-2", UserID: "user-2", TenantID: "tenant-1"},
+		{ConnectionID: "conn-3", UserID: "user-3", TenantID: "tenant-1"},
+	}
+
+	connStore := new(MockConnectionStore)
+	connStore.On("ListByTenant", mock.Anything, "tenant-1", mock.Anything).Return(&store.Page[*store.Connection]{Items: conns}, nil)
+	connStore.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	apiGateway := newClosingAPIGateway()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	apiGateway.AddConnection("conn-2", "127.0.0.1")
+	apiGateway.AddConnection("conn-3", "127.0.0.1")
+	apiGateway.SetError("conn-3", ForbiddenError{ConnectionID: "conn-3", Message: "forbidden"})
+	manager := newDisconnectTestManager(connStore, apiGateway)
+
+	// conn-3 misses its close notice but is still closed
+	closed, err := manager.DisconnectTenant(context.Background(), "tenant-1", ReasonTenantSuspended)
+	require.NoError(t, err)
+	assert.Equal(t, 3, closed)
+	assert.Empty(t, apiGateway.closedWith(t, "conn-3"))
+	assert.Equal(t, types.CloseCodeTenantSuspended, apiGateway.closedWith(t, "conn-1")[0]["code"])
+}
+
+func TestManager_DisconnectTenantPages(t *testing.T) {
+	connStore := new(MockConnectionStore)
+	connStore.On("ListByTenant", mock.Anything, "tenant-1", store.PageOptions{}).Return(&store.Page[*store.Connection]{
+		Items:     []*store.Connection{{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"}},
+		NextToken: "page-2",
+	}, nil)
+	connStore.On("ListByTenant", mock.Anything, "tenant-1", store.PageOptions{NextToken: "page-2"}).Return(&store.Page[*store.Connection]{
+		Items: []*store.Connection{{ConnectionID: "conn-2", UserID: "user-2", TenantID: "tenant-1"}},
+	}, nil)
+	connStore.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	apiGateway := newClosingAPIGateway()
+	apiGateway.AddConnection("conn-1", "127.0.0.1")
+	apiGateway.AddConnection("conn-2", "127.0.0.1")
+	manager := newDisconnectTestManager(connStore, apiGateway)
+
+	closed, err := manager.DisconnectTenant(context.Background(), "tenant-1", ReasonTenantSuspended)
+	require.NoError(t, err)
+	assert.Equal(t, 2, closed)
+	connStore.AssertNumberOfCalls(t, "ListByTenant", 2)
+}
+
+func TestManager_DisconnectTenantListError(t *testing.T) {
+	connStore := new(MockConnectionStore)
+	connStore.On("ListByTenant", mock.Anything, "tenant-1", mock.Anything).Return(nil, errors.New("throttled"))
+	manager := newDisconnectTestManager(connStore, newClosingAPIGateway())
+
+	closed, err := manager.DisconnectTenant(context.Background(), "tenant-1", ReasonTenantSuspended)
+	assert.Error(t, err)
+	assert.Zero(t, closed)
+}
@@ -18,6 +18,12 @@ var (
 
 	// ErrBroadcastPartialFailure indicates some connections failed during broadcast
 	ErrBroadcastPartialFailure = errors.New("broadcast partially failed")
+
+	// ErrOutboxFull indicates a connection's outbox stayed full until the send's context ended
+	ErrOutboxFull = errors.New("connection outbox full")
+
+	// ErrSequenceUnavailable indicates sequence numbers could not be reserved for a message
+	ErrSequenceUnavailable = errors.New("connection sequence unavailable")
 )
 
 // ConnectionError represents a connection-specific error
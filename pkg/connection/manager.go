@@ -2,7 +2,6 @@ package connection
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -20,7 +19,17 @@ type Metrics struct {
 	BroadcastLatency *LatencyTracker
 	ErrorsByType     map[string]*atomic.Int64
 	ActiveSends      *atomic.Int32
-	mu               sync.RWMutex
+	ChunkedMessages  *atomic.Int64
+
+	// Compression savings
+	CompressedMessages     *atomic.Int64
+	BytesBeforeCompression *atomic.Int64
+	BytesAfterCompression  *atomic.Int64
+
+	// Outbox activity
+	CoalescedMessages *atomic.Int64
+	OutboxWaits       *atomic.Int64
+	mu                sync.RWMutex
 }
 
 // LatencyTracker tracks latency percentiles
@@ -29,19 +38,29 @@ type LatencyTracker struct {
 	mu      sync.Mutex
 }
 
-// CircuitBreaker tracks connection health
-type CircuitBreaker struct {
-	failures   map[string]int
-	lastFailed map[string]time.Time
-	mu         sync.RWMutex
-}
-
 // Manager handles WebSocket connections through API Gateway
 type Manager struct {
 	store      store.ConnectionStore
 	apiGateway APIGatewayClient
 	endpoint   string
 
+	// maxFrameSize is the largest payload posted in one frame; see SetMaxFrameSize
+	maxFrameSize int
+
+	// compressionThreshold is the smallest message compressed; see SetCompressionThreshold
+	compressionThreshold int
+
+	// outboxes order delivery per connection; see outbox.go
+	outboxMu    sync.Mutex
+	outboxes    map[string]*outbox
+	outboxLimit int
+
+	// deliveries tracks messages that need client acks; see delivery.go
+	deliveries *DeliveryTracker
+
+	// sequences numbers frames across instances; see SetSequenceStore
+	sequences store.SequenceStore
+
 	// Production features
 	workerPool     chan struct{}
 	circuitBreaker *CircuitBreaker
@@ -56,26 +75,36 @@ type Manager struct {
 // NewManager creates a new connection manager
 func NewManager(store store.ConnectionStore, apiGateway APIGatewayClient, endpoint string) *Manager {
 	m := &Manager{
-		store:      store,
-		apiGateway: apiGateway,
-		endpoint:   endpoint,
-		workerPool: make(chan struct{}, 10), // 10 concurrent workers
-		circuitBreaker: &CircuitBreaker{
-			failures:   make(map[string]int),
-			lastFailed: make(map[string]time.Time),
-		},
+		store:        store,
+		apiGateway:   apiGateway,
+		endpoint:     endpoint,
+		maxFrameSize: DefaultMaxFrameSize,
+
+		compressionThreshold: DefaultCompressionThreshold,
+		outboxes:             make(map[string]*outbox),
+		outboxLimit:          DefaultOutboxLimit,
+		workerPool:           make(chan struct{}, 10), // 10 concurrent workers
+		circuitBreaker:       NewCircuitBreaker(nil, CircuitBreakerConfig{}),
 		metrics: &Metrics{
 			SendLatency:      &LatencyTracker{},
 			BroadcastLatency: &LatencyTracker{},
 			ErrorsByType:     make(map[string]*atomic.Int64),
 			ActiveSends:      &atomic.Int32{},
+			ChunkedMessages:  &atomic.Int64{},
+
+			CompressedMessages:     &atomic.Int64{},
+			BytesBeforeCompression: &atomic.Int64{},
+			BytesAfterCompression:  &atomic.Int64{},
+
+			CoalescedMessages: &atomic.Int64{},
+			OutboxWaits:       &atomic.Int64{},
 		},
 		shutdownCh: make(chan struct{}),
 		logger:     func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) },
 	}
 
 	// Initialize error counters
-	errorTypes := []string{"connection_not_found", "connection_stale", "marshal_error", "network_error", "timeout", "circuit_open"}
+	errorTypes := []string{"connection_not_found", "connection_stale", "marshal_error", "network_error", "timeout", "circuit_open", "outbox_full", "sequence_error"}
 	for _, errType := range errorTypes {
 		m.metrics.ErrorsByType[errType] = &atomic.Int64{}
 	}
@@ -90,6 +119,21 @@ func (m *Manager) SetLogger(logger func(format string, args ...interface{})) {
 	m.logger = logger
 }
 
+// SetCircuitBreaker replaces the circuit breaker, e.g. with one whose state
+// is shared across instances through a DynamoDB-backed store
+func (m *Manager) SetCircuitBreaker(breaker *CircuitBreaker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.circuitBreaker = breaker
+}
+
+// breaker returns the current circuit breaker
+func (m *Manager) breaker() *CircuitBreaker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.circuitBreaker
+}
+
 // Shutdown gracefully shuts down the manager
 func (m *Manager) Shutdown(ctx context.Context) error {
 	m.logger("Shutting down connection manager...")
@@ -134,7 +178,8 @@ func (m *Manager) Send(ctx context.Context, connectionID string, message interfa
 	}()
 
 	// Check circuit breaker
-	if m.circuitBreaker.IsOpen(connectionID) {
+	breaker := m.breaker()
+	if !breaker.Allow(ctx, connectionID) {
 		m.metrics.ErrorsByType["circuit_open"].Add(1)
 		return fmt.Errorf("circuit breaker open for connection %s", connectionID)
 	}
@@ -150,16 +195,33 @@ func (m *Manager) Send(ctx context.Context, connectionID string, message interfa
 		return fmt.Errorf("failed to get connection: %w", err)
 	}
 
-	// Marshal message to JSON
-	data, err := json.Marshal(message)
+	// Persist messages the client must ack and stamp them with a delivery ID
+	stamped := m.track(ctx, conn, message)
+
+	// Marshal message with the connection's codec
+	c, encoding := connectionFormat(conn)
+	data, err := c.Marshal(stamped)
 	if err != nil {
 		m.metrics.ErrorsByType["marshal_error"].Add(1)
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	// Send with retry logic
-	err = m.sendWithRetry(ctx, connectionID, data)
+	// Compress for connections that negotiated an encoding, then split
+	// oversized messages into chunks
+	frame, payload, err := m.encode(stamped, data, encoding, c)
+	if err != nil {
+		m.metrics.ErrorsByType["marshal_error"].Add(1)
+		return err
+	}
+	frames := m.frames(frame, payload)
+
+	// Queue behind earlier messages to this connection and wait for delivery
+	err = m.deliver(ctx, newOutboundMessage(ctx, connectionID, c, frames, message))
 	if err != nil {
+		if errors.Is(err, ErrOutboxFull) || errors.Is(err, ErrSequenceUnavailable) {
+			return err
+		}
+
 		// Handle 410 Gone - connection is stale
 		if isConnectionGone(err) {
 			m.metrics.ErrorsByType["connection_stale"].Add(1)
@@ -171,13 +233,14 @@ func (m *Manager) Send(ctx context.Context, connectionID string, message interfa
 		}
 
 		// Record failure for circuit breaker
-		m.circuitBreaker.RecordFailure(connectionID)
+		breaker.RecordFailure(ctx, connectionID)
 		m.metrics.ErrorsByType["network_error"].Add(1)
 		return err
 	}
 
 	// Record success
-	m.circuitBreaker.RecordSuccess(connectionID)
+	breaker.RecordSuccess(ctx, connectionID)
+	m.recordCompression(data, payload)
 
 	// Update last ping time
 	go func() {
@@ -203,11 +266,11 @@ func (m *Manager) Broadcast(ctx context.Context, connectionIDs []string, message
 		m.metrics.BroadcastLatency.Record(time.Since(start))
 	}()
 
-	// Marshal message once
-	data, err := json.Marshal(message)
+	// Marshal, compress and chunk once per codec and encoding in use
+	encoded, err := newEncodedFrames(m, message)
 	if err != nil {
 		m.metrics.ErrorsByType["marshal_error"].Add(1)
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return err
 	}
 
 	// Use worker pool for parallel sending
@@ -230,7 +293,10 @@ func (m *Manager) Broadcast(ctx context.Context, connectionIDs []string, message
 					results <- errors.New("shutdown in progress")
 					return
 				case m.workerPool <- struct{}{}:
-					err := m.sendWithRetry(ctx, connID, data)
+					c, frames, err := encoded.forConnection(ctx, connID)
+					if err == nil {
+						err = m.deliver(ctx, newOutboundMessage(ctx, connID, c, frames, message))
+					}
 					<-m.workerPool
 
 					if err != nil {
@@ -278,7 +344,8 @@ func (m *Manager) Broadcast(ctx context.Context, connectionIDs []string, message
 // IsActive checks if a connection is active
 func (m *Manager) IsActive(ctx context.Context, connectionID string) bool {
 	// Check circuit breaker first
-	if m.circuitBreaker.IsOpen(connectionID) {
+	breaker := m.breaker()
+	if breaker.IsOpen(ctx, connectionID) {
 		return false
 	}
 
@@ -293,10 +360,10 @@ func (m *Manager) IsActive(ctx context.Context, connectionID string) bool {
 		testData := []byte(`{"type":"ping"}`)
 		err := m.sendMessage(ctx, connectionID, testData)
 		if err != nil {
-			m.circuitBreaker.RecordFailure(connectionID)
+			breaker.RecordFailure(ctx, connectionID)
 			return false
 		}
-		m.circuitBreaker.RecordSuccess(connectionID)
+		breaker.RecordSuccess(ctx, connectionID)
 	}
 
 	return true
@@ -304,6 +371,9 @@ func (m *Manager) IsActive(ctx context.Context, connectionID string) bool {
 
 // GetMetrics returns current performance metrics
 func (m *Manager) GetMetrics() map[string]interface{} {
+	before := m.metrics.BytesBeforeCompression.Load()
+	after := m.metrics.BytesAfterCompression.Load()
+
 	return map[string]interface{}{
 		"active_sends":          m.metrics.ActiveSends.Load(),
 		"send_latency_p50":      m.metrics.SendLatency.Percentile(0.5),
@@ -315,8 +385,24 @@ func (m *Manager) GetMetrics() map[string]interface{} {
 			"connection_stale":     m.metrics.ErrorsByType["connection_stale"].Load(),
 			"marshal_error":        m.metrics.ErrorsByType["marshal_error"].Load(),
 			"network_error":        m.metrics.ErrorsByType["network_error"].Load(),
+			"outbox_full":          m.metrics.ErrorsByType["outbox_full"].Load(),
+			"sequence_error":       m.metrics.ErrorsByType["sequence_error"].Load(),
+		},
+		"chunked_messages": m.metrics.ChunkedMessages.Load(),
+		"compression": map[string]int64{
+			"messages":     m.metrics.CompressedMessages.Load(),
+			"bytes_before": before,
+			"bytes_after":  after,
+			"bytes_saved":  before - after,
+		},
+		"outbox": map[string]int64{
+			"queued":    m.queuedMessages(),
+			"coalesced": m.metrics.CoalescedMessages.Load(),
+			"waits":     m.metrics.OutboxWaits.Load(),
 		},
-		"circuit_breakers_open": m.circuitBreaker.CountOpen(),
+		"circuit_breakers_open": m.breaker().CountOpen(),
+		"circuit_breaker":       m.breaker().Stats(),
+		"delivery":              m.deliveryStats(),
 	}
 }
 
@@ -414,58 +500,6 @@ func isConnectionGone(err error) bool {
 	return false
 }
 
-// CircuitBreaker methods
-
-func (cb *CircuitBreaker) IsOpen(connectionID string) bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-
-	failures, exists := cb.failures[connectionID]
-	if !exists {
-		return false
-	}
-
-	// Open circuit after 3 consecutive failures
-	if failures >= 3 {
-		// Check if we should try again (after 30 seconds)
-		if time.Since(cb.lastFailed[connectionID]) > 30*time.Second {
-			return false
-		}
-		return true
-	}
-
-	return false
-}
-
-func (cb *CircuitBreaker) RecordFailure(connectionID string) {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	cb.failures[connectionID]++
-	cb.lastFailed[connectionID] = time.Now()
-}
-
-func (cb *CircuitBreaker) RecordSuccess(connectionID string) {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	delete(cb.failures, connectionID)
-	delete(cb.lastFailed, connectionID)
-}
-
-func (cb *CircuitBreaker) CountOpen() int {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-
-	count := 0
-	for connID, failures := range cb.failures {
-		if failures >= 3 && time.Since(cb.lastFailed[connID]) <= 30*time.Second {
-			count++
-		}
-	}
-	return count
-}
-
 // LatencyTracker methods
 
 func (lt *LatencyTracker) Record(d time.Duration) {
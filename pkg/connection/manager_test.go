@@ -36,20 +36,20 @@ func (m *MockConnectionStore) Delete(ctx context.Context, connectionID string) e
 	return args.Error(0)
 }
 
-func (m *MockConnectionStore) ListByUser(ctx context.Context, userID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, userID)
+func (m *MockConnectionStore) ListByUser(ctx context.Context, userID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, userID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
-func (m *MockConnectionStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.Connection, error) {
-	args := m.Called(ctx, tenantID)
+func (m *MockConnectionStore) ListByTenant(ctx context.Context, tenantID string, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, tenantID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*store.Connection), args.Error(1)
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
 }
 
 func (m *MockConnectionStore) UpdateLastPing(ctx context.Context, connectionID string) error {
@@ -57,6 +57,27 @@ func (m *MockConnectionStore) UpdateLastPing(ctx context.Context, connectionID s
 	return args.Error(0)
 }
 
+func (m *MockConnectionStore) UpdateClaims(ctx context.Context, connectionID string, metadata map[string]string, tokenExpiresAt time.Time) error {
+	args := m.Called(ctx, connectionID, metadata, tokenExpiresAt)
+	return args.Error(0)
+}
+
+func (m *MockConnectionStore) ListExpiring(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
+func (m *MockConnectionStore) ListStale(ctx context.Context, before time.Time, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+	args := m.Called(ctx, before, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.Connection]), args.Error(1)
+}
+
 func (m *MockConnectionStore) DeleteStale(ctx context.Context, before time.Time) error {
 	args := m.Called(ctx, before)
 	return args.Error(0)
@@ -0,0 +1,288 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+const (
+	// DefaultOutboxLimit is how many messages may wait for one connection
+	// before senders block
+	DefaultOutboxLimit = 64
+
+	// SequenceKey is the field stamped on every frame with its position in
+	// the connection's stream
+	SequenceKey = "sequence"
+
+	// maxIdleOutboxes is how many outboxes are kept before idle ones are pruned
+	maxIdleOutboxes = 10000
+
+	// outboxIdleTTL is how long an idle outbox, and its sequence, is kept
+	outboxIdleTTL = 10 * time.Minute
+)
+
+// outbox orders delivery to one connection. Messages are sent one at a time
+// in the order they were queued, so concurrent senders cannot reorder them.
+// sequence counts the frames sent when no SequenceStore is set.
+type outbox struct {
+	queue    []*outboundMessage
+	sending  bool
+	sequence uint64
+	lastUsed time.Time
+
+	// space is closed, and replaced, whenever a message leaves the queue
+	space chan struct{}
+}
+
+// outboundMessage is one message waiting in an outbox. Its frames are
+// encoded with codec once they are stamped with their sequence numbers.
+type outboundMessage struct {
+	ctx          context.Context
+	connectionID string
+	codec        codec.Codec
+	frames       []interface{}
+
+	// progress is the request ID of a progress update, which a later
+	// update for the same request replaces while both are queued
+	progress string
+
+	done chan error
+}
+
+func newOutboundMessage(ctx context.Context, connectionID string, c codec.Codec, frames []interface{}, message interface{}) *outboundMessage {
+	return &outboundMessage{
+		ctx:          ctx,
+		connectionID: connectionID,
+		codec:        c,
+		frames:       frames,
+		progress:     progressRequestID(message),
+		done:         make(chan error, 1),
+	}
+}
+
+// SetOutboxLimit sets how many messages may wait for one connection. Senders
+// block while a connection's outbox is full and fail with ErrOutboxFull if
+// their context ends first. Values below 1 use DefaultOutboxLimit.
+func (m *Manager) SetOutboxLimit(limit int) {
+	if limit < 1 {
+		limit = DefaultOutboxLimit
+	}
+	m.outboxMu.Lock()
+	defer m.outboxMu.Unlock()
+	m.outboxLimit = limit
+}
+
+// SetSequenceStore numbers each connection's frames from a counter in store,
+// so a client sees one sequence however many instances send to it. Without
+// one, each instance counts the frames it sends itself.
+func (m *Manager) SetSequenceStore(sequences store.SequenceStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sequences = sequences
+}
+
+// deliver queues a message for its connection and waits for it to be sent
+func (m *Manager) deliver(ctx context.Context, msg *outboundMessage) error {
+	if err := m.enqueue(ctx, msg); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-msg.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue adds a message to a connection's outbox, replacing a queued
+// progress update it supersedes, and starts draining the outbox if idle
+func (m *Manager) enqueue(ctx context.Context, msg *outboundMessage) error {
+	waited := false
+	for {
+		m.outboxMu.Lock()
+		box := m.outbox(msg.connectionID)
+
+		if msg.progress != "" {
+			for i, queued := range box.queue {
+				if queued.progress == msg.progress {
+					box.queue[i] = msg
+					m.outboxMu.Unlock()
+
+					queued.done <- nil
+					m.metrics.CoalescedMessages.Add(1)
+					return nil
+				}
+			}
+		}
+
+		if len(box.queue) < m.outboxLimit {
+			box.queue = append(box.queue, msg)
+			if !box.sending {
+				box.sending = true
+				m.wg.Add(1)
+				go m.drain(box)
+			}
+			m.outboxMu.Unlock()
+			return nil
+		}
+
+		space := box.space
+		m.outboxMu.Unlock()
+
+		if !waited {
+			waited = true
+			m.metrics.OutboxWaits.Add(1)
+		}
+
+		select {
+		case <-space:
+		case <-ctx.Done():
+			m.metrics.ErrorsByType["outbox_full"].Add(1)
+			return fmt.Errorf("%w for connection %s: %v", ErrOutboxFull, msg.connectionID, ctx.Err())
+		case <-m.shutdownCh:
+			return errors.New("manager is shutting down")
+		}
+	}
+}
+
+// outbox returns the outbox for a connection, creating it if needed.
+// Callers hold m.outboxMu.
+func (m *Manager) outbox(connectionID string) *outbox {
+	now := time.Now()
+	box, ok := m.outboxes[connectionID]
+	if !ok {
+		if len(m.outboxes) >= maxIdleOutboxes {
+			m.pruneOutboxes(now)
+		}
+		box = &outbox{space: make(chan struct{})}
+		m.outboxes[connectionID] = box
+	}
+	box.lastUsed = now
+	return box
+}
+
+// pruneOutboxes drops outboxes that have been idle for outboxIdleTTL.
+// Callers hold m.outboxMu.
+func (m *Manager) pruneOutboxes(now time.Time) {
+	for id, box := range m.outboxes {
+		if !box.sending && len(box.queue) == 0 && now.Sub(box.lastUsed) > outboxIdleTTL {
+			delete(m.outboxes, id)
+		}
+	}
+}
+
+// drain sends queued messages in order until the outbox is empty
+func (m *Manager) drain(box *outbox) {
+	defer m.wg.Done()
+
+	for {
+		m.outboxMu.Lock()
+		if len(box.queue) == 0 {
+			box.sending = false
+			m.outboxMu.Unlock()
+			return
+		}
+
+		msg := box.queue[0]
+		box.queue[0] = nil
+		box.queue = box.queue[1:]
+		close(box.space)
+		box.space = make(chan struct{})
+
+		m.outboxMu.Unlock()
+
+		// Every frame takes a sequence number, even if sending fails, so
+		// clients can tell a lost message from a late one
+		first, err := m.reserveSequence(msg, box)
+		if err != nil {
+			msg.done <- err
+			continue
+		}
+		msg.done <- m.sendSequenced(msg, first)
+	}
+}
+
+// reserveSequence reserves a sequence number for each frame of a message and
+// returns the first, from the sequence store when one is set
+func (m *Manager) reserveSequence(msg *outboundMessage, box *outbox) (uint64, error) {
+	m.mu.RLock()
+	sequences := m.sequences
+	m.mu.RUnlock()
+
+	if sequences == nil {
+		m.outboxMu.Lock()
+		defer m.outboxMu.Unlock()
+		first := box.sequence + 1
+		box.sequence += uint64(len(msg.frames))
+		return first, nil
+	}
+
+	first, err := sequences.Reserve(msg.ctx, msg.connectionID, len(msg.frames))
+	if err != nil {
+		m.metrics.ErrorsByType["sequence_error"].Add(1)
+		return 0, fmt.Errorf("%w for connection %s: %w", ErrSequenceUnavailable, msg.connectionID, err)
+	}
+	return first, nil
+}
+
+// sendSequenced stamps each frame of a message with its sequence number,
+// encodes it and sends the frames in order
+func (m *Manager) sendSequenced(msg *outboundMessage, first uint64) error {
+	if err := msg.ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, frame := range msg.frames {
+		data, err := msg.codec.Marshal(withSequence(frame, first+uint64(i)))
+		if err != nil {
+			m.metrics.ErrorsByType["marshal_error"].Add(1)
+			return fmt.Errorf("failed to marshal frame: %w", err)
+		}
+
+		if err := m.sendWithRetry(msg.ctx, msg.connectionID, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressRequestID returns the request ID of a progress update, or "" for
+// any other message
+func progressRequestID(message interface{}) string {
+	switch msg := message.(type) {
+	case map[string]interface{}:
+		if fmt.Sprint(msg["type"]) != string(types.MessageTypeProgress) {
+			return ""
+		}
+		requestID, _ := msg["request_id"].(string)
+		return requestID
+	case *types.ProgressMessage:
+		if msg == nil {
+			return ""
+		}
+		return msg.RequestID
+	case types.ProgressMessage:
+		return msg.RequestID
+	default:
+		return ""
+	}
+}
+
+// queuedMessages returns the number of messages waiting in all outboxes
+func (m *Manager) queuedMessages() int64 {
+	m.outboxMu.Lock()
+	defer m.outboxMu.Unlock()
+
+	var queued int64
+	for _, box := range m.outboxes {
+		queued += int64(len(box.queue))
+	}
+	return queued
+}
@@ -0,0 +1,301 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// sequenced returns message encoded with c as it is sent at position seq
+func sequenced(t *testing.T, c codec.Codec, message map[string]interface{}, seq uint64) []byte {
+	stamped := map[string]interface{}{SequenceKey: seq}
+	for k, v := range message {
+		stamped[k] = v
+	}
+	data, err := c.Marshal(stamped)
+	require.NoError(t, err)
+	return data
+}
+
+// unsequenced returns a JSON frame without its sequence number
+func unsequenced(t *testing.T, frame []byte) []byte {
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(frame, &fields))
+	delete(fields, SequenceKey)
+
+	data, err := json.Marshal(fields)
+	require.NoError(t, err)
+	return data
+}
+
+// blockingAPIGatewayClient holds every post until released
+type blockingAPIGatewayClient struct {
+	*TestableAPIGatewayClient
+	posted  chan struct{}
+	release chan struct{}
+}
+
+func newBlockingAPIGatewayClient() *blockingAPIGatewayClient {
+	return &blockingAPIGatewayClient{
+		TestableAPIGatewayClient: NewTestableAPIGatewayClient(),
+		posted:                   make(chan struct{}, 100),
+		release:                  make(chan struct{}),
+	}
+}
+
+func (b *blockingAPIGatewayClient) PostToConnection(ctx context.Context, connectionID string, data []byte) error {
+	b.posted <- struct{}{}
+	<-b.release
+	return b.TestableAPIGatewayClient.PostToConnection(ctx, connectionID, data)
+}
+
+func newOutboxTestManager(t *testing.T, apiGateway APIGatewayClient) *Manager {
+	mockStore := new(MockConnectionStore)
+	mockStore.On("Get", mock.Anything, "conn123").Return(&store.Connection{
+		ConnectionID: "conn123",
+		LastPing:     time.Now(),
+	}, nil)
+	mockStore.On("UpdateLastPing", mock.Anything, "conn123").Return(nil).Maybe()
+
+	manager := NewManager(mockStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	t.Cleanup(func() { time.Sleep(10 * time.Millisecond) })
+	return manager
+}
+
+// decodeSent decodes every frame sent to conn123
+func decodeSent(t *testing.T, apiGateway *TestableAPIGatewayClient) []map[string]interface{} {
+	var sent []map[string]interface{}
+	for _, frame := range apiGateway.GetMessages("conn123") {
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(frame, &msg))
+		sent = append(sent, msg)
+	}
+	return sent
+}
+
+func TestManager_SendStampsSequence(t *testing.T) {
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	manager := newOutboxTestManager(t, apiGateway)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, manager.Send(context.Background(), "conn123", map[string]interface{}{"type": "response", "n": i}))
+	}
+
+	// Messages that are not objects are sent as-is
+	require.NoError(t, manager.Send(context.Background(), "conn123", "plain"))
+
+	sent := apiGateway.GetMessages("conn123")
+	require.Len(t, sent, 4)
+	for i, frame := range sent[:3] {
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(frame, &msg))
+		assert.Equal(t, float64(i+1), msg[SequenceKey])
+		assert.Equal(t, float64(i), msg["n"])
+	}
+	assert.Equal(t, `"plain"`, string(sent[3]))
+}
+
+// sharedSequenceStore hands out sequence numbers from one counter, like the
+// counter on a connection record that every instance shares
+type sharedSequenceStore struct {
+	mu   sync.Mutex
+	last uint64
+	err  error
+}
+
+func (s *sharedSequenceStore) Reserve(ctx context.Context, connectionID string, count int) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	first := s.last + 1
+	s.last += uint64(count)
+	return first, nil
+}
+
+func TestManager_SendSharesSequence(t *testing.T) {
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	sequences := &sharedSequenceStore{}
+
+	// Two instances sending to one connection continue each other's sequence
+	instances := []*Manager{newOutboxTestManager(t, apiGateway), newOutboxTestManager(t, apiGateway)}
+	for _, manager := range instances {
+		manager.SetSequenceStore(sequences)
+	}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, instances[i%2].Send(context.Background(), "conn123", map[string]interface{}{"type": "response", "n": i}))
+	}
+
+	sent := decodeSent(t, apiGateway)
+	require.Len(t, sent, 4)
+	for i, msg := range sent {
+		assert.Equal(t, float64(i+1), msg[SequenceKey])
+	}
+
+	t.Run("store failure", func(t *testing.T) {
+		manager := newOutboxTestManager(t, apiGateway)
+		manager.SetSequenceStore(&sharedSequenceStore{err: errors.New("throttled")})
+
+		err := manager.Send(context.Background(), "conn123", map[string]interface{}{"type": "response"})
+		assert.ErrorIs(t, err, ErrSequenceUnavailable)
+		assert.Len(t, apiGateway.GetMessages("conn123"), 4)
+
+		errs := manager.GetMetrics()["errors"].(map[string]int64)
+		assert.Equal(t, int64(1), errs["sequence_error"])
+		assert.Zero(t, errs["network_error"])
+	})
+}
+
+func TestManager_SendPreservesOrder(t *testing.T) {
+	apiGateway := newBlockingAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	manager := newOutboxTestManager(t, apiGateway)
+
+	// Hold the first message in flight so the rest queue behind it
+	var wg sync.WaitGroup
+	send := func(message interface{}) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, manager.Send(context.Background(), "conn123", message))
+		}()
+	}
+
+	send(map[string]interface{}{"type": "progress", "request_id": "req_1", "percentage": 10})
+	<-apiGateway.posted
+
+	send(map[string]interface{}{"type": "progress", "request_id": "req_1", "percentage": 50})
+	require.Eventually(t, func() bool { return manager.queuedMessages() == 1 }, time.Second, time.Millisecond)
+	send(map[string]interface{}{"type": "progress", "request_id": "req_2", "percentage": 20})
+	require.Eventually(t, func() bool { return manager.queuedMessages() == 2 }, time.Second, time.Millisecond)
+	send(map[string]interface{}{"type": "progress", "request_id": "req_1", "percentage": 90})
+	require.Eventually(t, func() bool { return manager.metrics.CoalescedMessages.Load() == 1 }, time.Second, time.Millisecond)
+	send(map[string]interface{}{"type": "complete", "request_id": "req_1"})
+	require.Eventually(t, func() bool { return manager.queuedMessages() == 3 }, time.Second, time.Millisecond)
+
+	close(apiGateway.release)
+	wg.Wait()
+
+	sent := decodeSent(t, apiGateway.TestableAPIGatewayClient)
+	require.Len(t, sent, 4)
+
+	// The superseded 50% update was replaced in place by the 90% one
+	expected := []struct {
+		requestID  string
+		msgType    string
+		percentage interface{}
+	}{
+		{"req_1", "progress", float64(10)},
+		{"req_1", "progress", float64(90)},
+		{"req_2", "progress", float64(20)},
+		{"req_1", "complete", nil},
+	}
+	for i, want := range expected {
+		assert.Equal(t, float64(i+1), sent[i][SequenceKey])
+		assert.Equal(t, want.requestID, sent[i]["request_id"])
+		assert.Equal(t, want.msgType, sent[i]["type"])
+		assert.Equal(t, want.percentage, sent[i]["percentage"])
+	}
+
+	outbox := manager.GetMetrics()["outbox"].(map[string]int64)
+	assert.Equal(t, int64(1), outbox["coalesced"])
+	assert.Zero(t, outbox["queued"])
+}
+
+func TestManager_SendBackpressure(t *testing.T) {
+	apiGateway := newBlockingAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	manager := newOutboxTestManager(t, apiGateway)
+	manager.SetOutboxLimit(1)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, manager.Send(context.Background(), "conn123", map[string]interface{}{"n": 1}))
+	}()
+	<-apiGateway.posted
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, manager.Send(context.Background(), "conn123", map[string]interface{}{"n": 2}))
+	}()
+	require.Eventually(t, func() bool { return manager.queuedMessages() == 1 }, time.Second, time.Millisecond)
+
+	// The outbox is full, so a third sender waits until its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := manager.Send(ctx, "conn123", map[string]interface{}{"n": 3})
+	assert.True(t, errors.Is(err, ErrOutboxFull), "got %v", err)
+
+	// Blocked senders proceed once the queue drains
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, manager.Send(context.Background(), "conn123", map[string]interface{}{"n": 4}))
+	}()
+	require.Eventually(t, func() bool { return manager.metrics.OutboxWaits.Load() == 2 }, time.Second, time.Millisecond)
+
+	close(apiGateway.release)
+	wg.Wait()
+
+	sent := decodeSent(t, apiGateway.TestableAPIGatewayClient)
+	require.Len(t, sent, 3)
+	for i, n := range []float64{1, 2, 4} {
+		assert.Equal(t, n, sent[i]["n"])
+		assert.Equal(t, float64(i+1), sent[i][SequenceKey])
+	}
+
+	metrics := manager.GetMetrics()
+	assert.Equal(t, int64(1), metrics["errors"].(map[string]int64)["outbox_full"])
+	assert.Equal(t, int64(2), metrics["outbox"].(map[string]int64)["waits"])
+}
+
+func TestManager_BroadcastSharesOutbox(t *testing.T) {
+	apiGateway := NewTestableAPIGatewayClient()
+	apiGateway.AddConnection("conn123", "127.0.0.1")
+	manager := newOutboxTestManager(t, apiGateway)
+
+	require.NoError(t, manager.Send(context.Background(), "conn123", map[string]interface{}{"type": "response"}))
+	require.NoError(t, manager.Broadcast(context.Background(), []string{"conn123"}, map[string]interface{}{"type": "broadcast"}))
+
+	sent := decodeSent(t, apiGateway)
+	require.Len(t, sent, 2)
+	assert.Equal(t, float64(2), sent[1][SequenceKey])
+	assert.Equal(t, "broadcast", sent[1]["type"])
+}
+
+func TestProgressRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  interface{}
+		expected string
+	}{
+		{name: "progress map", message: map[string]interface{}{"type": "progress", "request_id": "req_1"}, expected: "req_1"},
+		{name: "typed progress map", message: map[string]interface{}{"type": types.MessageTypeProgress, "request_id": "req_1"}, expected: "req_1"},
+		{name: "complete map", message: map[string]interface{}{"type": "complete", "request_id": "req_1"}},
+		{name: "progress message", message: &types.ProgressMessage{RequestID: "req_2"}, expected: "req_2"},
+		{name: "nil progress message", message: (*types.ProgressMessage)(nil)},
+		{name: "other", message: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, progressRequestID(tt.message))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package connection
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// memoryPubSubBuffer is how many messages a subscription holds before
+// publishers wait for its handler
+const memoryPubSubBuffer = 64
+
+// MemoryPubSub carries topic broadcasts between goroutines of one process,
+// for local development and tests. Use a shared backend such as
+// redisstore.PubSub when publishers run in other processes.
+type MemoryPubSub struct {
+	mu   sync.RWMutex
+	subs map[*memorySubscription]struct{}
+}
+
+type memorySubscription struct {
+	patterns []string
+	messages chan topicMessage
+	done     <-chan struct{}
+}
+
+type topicMessage struct {
+	topic   string
+	payload []byte
+}
+
+// NewMemoryPubSub creates an in-process pub/sub
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{subs: make(map[*memorySubscription]struct{})}
+}
+
+// Publish queues payload for every subscription matching topic. It waits
+// only while a subscription's buffer is full.
+func (p *MemoryPubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.RLock()
+	subs := make([]*memorySubscription, 0, len(p.subs))
+	for sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	p.mu.RUnlock()
+
+	for _, sub := range subs {
+		if !sub.matches(topic) {
+			continue
+		}
+		select {
+		case sub.messages <- topicMessage{topic: topic, payload: payload}:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe calls handler, one message at a time, for messages published to
+// topics matching any of the glob patterns, until ctx is done. As with Redis
+// PSUBSCRIBE, '*' in a pattern matches any run of characters.
+func (p *MemoryPubSub) Subscribe(ctx context.Context, handler func(topic string, payload []byte), patterns ...string) error {
+	sub := &memorySubscription{
+		patterns: patterns,
+		messages: make(chan topicMessage, memoryPubSubBuffer),
+		done:     ctx.Done(),
+	}
+
+	p.mu.Lock()
+	p.subs[sub] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.subs, sub)
+			p.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-sub.messages:
+				handler(msg.topic, msg.payload)
+			}
+		}
+	}()
+	return nil
+}
+
+// matches reports whether topic matches any of the subscription's patterns
+func (s *memorySubscription) matches(topic string) bool {
+	for _, pattern := range s.patterns {
+		if matchTopic(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTopic reports whether topic matches a glob pattern in which '*'
+// matches any run of characters
+func matchTopic(pattern, topic string) bool {
+	before, after, found := strings.Cut(pattern, "*")
+	if !found {
+		return pattern == topic
+	}
+	if !strings.HasPrefix(topic, before) {
+		return false
+	}
+	rest := topic[len(before):]
+	for i := 0; i <= len(rest); i++ {
+		if matchTopic(after, rest[i:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{pattern: "tenant:*", topic: "tenant:t-1", want: true},
+		{pattern: "tenant:*", topic: "tenant:a/b", want: true},
+		{pattern: "tenant:*", topic: "tenant:", want: true},
+		{pattern: "tenant:*", topic: "user:u-1", want: false},
+		{pattern: "*:t-1", topic: "tenant:t-1", want: true},
+		{pattern: "a*b*c", topic: "aXbYc", want: true},
+		{pattern: "a*b*c", topic: "aXcYb", want: false},
+		{pattern: "tenant:t-1", topic: "tenant:t-1", want: true},
+		{pattern: "tenant:t-1", topic: "tenant:t-10", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.topic, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchTopic(tt.pattern, tt.topic))
+		})
+	}
+}
+
+func TestMemoryPubSub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pubsub := NewMemoryPubSub()
+
+	var mu sync.Mutex
+	var got []string
+	require.NoError(t, pubsub.Subscribe(ctx, func(topic string, payload []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, topic+"="+string(payload))
+	}, "tenant:*"))
+
+	require.NoError(t, pubsub.Publish(ctx, "tenant:t-1", []byte("one")))
+	require.NoError(t, pubsub.Publish(ctx, "user:u-1", []byte("skipped")))
+	require.NoError(t, pubsub.Publish(ctx, "tenant:t-2", []byte("two")))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{"tenant:t-1=one", "tenant:t-2=two"}, got)
+	mu.Unlock()
+
+	// Ended subscriptions are dropped
+	cancel()
+	assert.Eventually(t, func() bool {
+		pubsub.mu.RLock()
+		defer pubsub.mu.RUnlock()
+		return len(pubsub.subs) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, pubsub.Publish(context.Background(), "tenant:t-1", []byte("late")))
+}
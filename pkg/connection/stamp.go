@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// envelope is implemented by pointers to the pkg/types messages, which all
+// embed types.Message
+type envelope interface {
+	Base() *types.Message
+}
+
+// withSequence returns message stamped with its sequence number
+func withSequence(message interface{}, sequence uint64) interface{} {
+	return withField(message, SequenceKey, sequence, func(base *types.Message) {
+		base.Sequence = sequence
+	})
+}
+
+// withDeliveryID returns message stamped with the ID the client acks
+func withDeliveryID(message interface{}, deliveryID string) interface{} {
+	return withField(message, DeliveryIDKey, deliveryID, func(base *types.Message) {
+		base.DeliveryID = deliveryID
+	})
+}
+
+// withField returns a copy of message with key set to value, before it is
+// encoded. message itself is left untouched since a broadcast shares it
+// between connections.
+//
+// Maps are copied and key is set. pkg/types messages are copied and set is
+// applied to their embedded types.Message. Other values are stamped through
+// their JSON form, as a JSON client would see them. Values that are not
+// objects have nowhere to carry a field and are returned as they are.
+func withField(message interface{}, key string, value interface{}, set func(*types.Message)) interface{} {
+	if fields, ok := message.(map[string]interface{}); ok {
+		stamped := make(map[string]interface{}, len(fields)+1)
+		for k, v := range fields {
+			stamped[k] = v
+		}
+		stamped[key] = value
+		return stamped
+	}
+
+	v := reflect.ValueOf(message)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		copied := reflect.New(v.Type())
+		copied.Elem().Set(v)
+		if e, ok := copied.Interface().(envelope); ok {
+			set(e.Base())
+			return copied.Interface()
+		}
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return message
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return message
+	}
+	fields[key] = value
+	return fields
+}
@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/pkg/codec"
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+func TestWithSequence(t *testing.T) {
+	t.Run("map is copied", func(t *testing.T) {
+		message := map[string]interface{}{"type": "progress"}
+		stamped := withSequence(message, 7)
+
+		assert.Equal(t, map[string]interface{}{"type": "progress", SequenceKey: uint64(7)}, stamped)
+		assert.NotContains(t, message, SequenceKey)
+	})
+
+	t.Run("types message is copied", func(t *testing.T) {
+		message := types.NewProgressMessage("req_123", 50, "Halfway")
+		stamped := withSequence(message, 7)
+
+		progress, ok := stamped.(*types.ProgressMessage)
+		require.True(t, ok)
+		assert.Equal(t, uint64(7), progress.Sequence)
+		assert.Equal(t, "req_123", progress.RequestID)
+		assert.Zero(t, message.Sequence)
+	})
+
+	t.Run("types message value", func(t *testing.T) {
+		message := *types.NewCloseMessage(types.CloseCodeServerClosed, "")
+		stamped := withSequence(message, 7)
+
+		closing, ok := stamped.(*types.CloseMessage)
+		require.True(t, ok)
+		assert.Equal(t, uint64(7), closing.Sequence)
+	})
+
+	t.Run("chunk keeps its chunk sequence", func(t *testing.T) {
+		stamped := withSequence(types.NewChunkMessage("msg_1", 2, 3, []byte("abc")), 7)
+
+		data, err := codec.JSON.Marshal(stamped)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"seq":2`)
+		assert.Contains(t, string(data), `"sequence":7`)
+	})
+
+	t.Run("other struct is stamped through JSON", func(t *testing.T) {
+		message := struct {
+			Type string `json:"type"`
+		}{Type: "custom"}
+
+		assert.Equal(t, map[string]interface{}{"type": "custom", SequenceKey: uint64(7)}, withSequence(message, 7))
+	})
+
+	t.Run("non-object is left alone", func(t *testing.T) {
+		assert.Equal(t, "hello", withSequence("hello", 7))
+		assert.Equal(t, []int{1, 2}, withSequence([]int{1, 2}, 7))
+	})
+}
+
+func TestWithDeliveryID(t *testing.T) {
+	message := types.NewErrorMessage("req_123", types.NewErrorInfo("FAILED", "failed"))
+
+	for _, c := range []codec.Codec{codec.JSON, codec.MessagePack, codec.CBOR} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(withDeliveryID(message, "dlv_1"))
+			require.NoError(t, err)
+
+			var decoded map[string]interface{}
+			require.NoError(t, c.Unmarshal(data, &decoded))
+			assert.Equal(t, "dlv_1", decoded[DeliveryIDKey])
+			assert.Equal(t, "req_123", decoded["request_id"])
+		})
+	}
+	assert.Empty(t, message.DeliveryID)
+}
@@ -0,0 +1,158 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Topic prefixes for broadcasts to every connection of a tenant or user
+const (
+	tenantTopicPrefix = "tenant:"
+	userTopicPrefix   = "user:"
+)
+
+// TenantTopic returns the topic that reaches every connection of a tenant
+func TenantTopic(tenantID string) string {
+	return tenantTopicPrefix + tenantID
+}
+
+// UserTopic returns the topic that reaches every connection of a user
+func UserTopic(userID string) string {
+	return userTopicPrefix + userID
+}
+
+// PubSub carries topic broadcasts between processes
+type PubSub interface {
+	// Publish sends payload to every current subscriber of topic
+	Publish(ctx context.Context, topic string, payload []byte) error
+
+	// Subscribe calls handler for messages published to topics matching any
+	// of the glob patterns until ctx is done. It returns once the subscription
+	// is active.
+	Subscribe(ctx context.Context, handler func(topic string, payload []byte), patterns ...string) error
+}
+
+// BroadcastToTenant sends a message to every connection of a tenant, a page
+// of connections at a time
+func (m *Manager) BroadcastToTenant(ctx context.Context, tenantID string, message interface{}) error {
+	return m.broadcastPages(ctx, "tenant "+tenantID, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return m.store.ListByTenant(ctx, tenantID, opts)
+	}, message)
+}
+
+// BroadcastToUser sends a message to every connection of a user, a page of
+// connections at a time
+func (m *Manager) BroadcastToUser(ctx context.Context, userID string, message interface{}) error {
+	return m.broadcastPages(ctx, "user "+userID, func(ctx context.Context, opts store.PageOptions) (*store.Page[*store.Connection], error) {
+		return m.store.ListByUser(ctx, userID, opts)
+	}, message)
+}
+
+// broadcastPages broadcasts a message to each page of connections that list
+// returns. A failed page does not stop the rest; the first failure is returned.
+func (m *Manager) broadcastPages(ctx context.Context, owner string, list store.PageFunc[*store.Connection], message interface{}) error {
+	var failed error
+	err := eachConnectionPage(ctx, owner, list, func(conns []*store.Connection) {
+		if err := m.Broadcast(ctx, connectionIDs(conns), message); err != nil && failed == nil {
+			failed = err
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return failed
+}
+
+// TopicBroadcaster hands tenant and user broadcasts to a PubSub so that the
+// publisher does not wait for the fan-out. Every process that has started the
+// broadcaster delivers every message it receives, so start it in exactly one
+// long-running process per deployment. A Lambda function cannot hold the
+// subscription between invocations; Lambda-only deployments should call
+// BroadcastToTenant and BroadcastToUser directly.
+type TopicBroadcaster struct {
+	manager *Manager
+	pubsub  PubSub
+}
+
+// NewTopicBroadcaster creates a broadcaster that delivers through manager
+func NewTopicBroadcaster(manager *Manager, pubsub PubSub) *TopicBroadcaster {
+	return &TopicBroadcaster{
+		manager: manager,
+		pubsub:  pubsub,
+	}
+}
+
+// PublishToTenant publishes a message for every connection of a tenant
+func (b *TopicBroadcaster) PublishToTenant(ctx context.Context, tenantID string, message interface{}) error {
+	return b.publish(ctx, TenantTopic(tenantID), message)
+}
+
+// PublishToUser publishes a message for every connection of a user
+func (b *TopicBroadcaster) PublishToUser(ctx context.Context, userID string, message interface{}) error {
+	return b.publish(ctx, UserTopic(userID), message)
+}
+
+// Start subscribes to the tenant and user topics and delivers their messages
+// until ctx is done
+func (b *TopicBroadcaster) Start(ctx context.Context) error {
+	return b.pubsub.Subscribe(ctx, func(topic string, payload []byte) {
+		b.deliver(ctx, topic, payload)
+	}, tenantTopicPrefix+"*", userTopicPrefix+"*")
+}
+
+// publish encodes a message as JSON and publishes it on topic
+func (b *TopicBroadcaster) publish(ctx context.Context, topic string, message interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message for topic %s: %w", topic, err)
+	}
+	return b.pubsub.Publish(ctx, topic, payload)
+}
+
+// deliver broadcasts a published message to the connections of its topic.
+// The message is decoded so each connection receives it in its own codec.
+func (b *TopicBroadcaster) deliver(ctx context.Context, topic string, payload []byte) {
+	var message interface{}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		b.manager.logger("Dropping malformed message on topic %s: %v", topic, err)
+		return
+	}
+
+	var err error
+	if tenantID, ok := strings.CutPrefix(topic, tenantTopicPrefix); ok {
+		err = b.manager.BroadcastToTenant(ctx, tenantID, message)
+	} else if userID, ok := strings.CutPrefix(topic, userTopicPrefix); ok {
+		err = b.manager.BroadcastToUser(ctx, userID, message)
+	}
+	if err != nil {
+		b.manager.logger("Broadcast to topic %s failed: %v", topic, err)
+	}
+}
+
+// eachConnectionPage calls fn with each page of connections that list
+// returns, stopping when listing fails or ctx is done. owner names the list in
+// errors.
+func eachConnectionPage(ctx context.Context, owner string, list store.PageFunc[*store.Connection], fn func([]*store.Connection)) error {
+	pages := store.NewPaginator(0, list)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list connections for %s: %w", owner, err)
+		}
+		fn(page.Items)
+	}
+	return nil
+}
+
+// connectionIDs returns the IDs of connections
+func connectionIDs(conns []*store.Connection) []string {
+	ids := make([]string, len(conns))
+	for i, conn := range conns {
+		ids[i] = conn.ConnectionID
+	}
+	return ids
+}
@@ -0,0 +1,141 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/memory"
+)
+
+// localPubSub delivers published messages to subscribers in the same process
+type localPubSub struct {
+	mu   sync.Mutex
+	subs []localSubscription
+}
+
+type localSubscription struct {
+	ctx      context.Context
+	handler  func(topic string, payload []byte)
+	patterns []string
+}
+
+func (p *localPubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, sub := range p.subs {
+		if sub.ctx.Err() != nil {
+			continue
+		}
+		for _, pattern := range sub.patterns {
+			if ok, _ := path.Match(pattern, topic); ok {
+				sub.handler(topic, payload)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (p *localPubSub) Subscribe(ctx context.Context, handler func(topic string, payload []byte), patterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subs = append(p.subs, localSubscription{ctx: ctx, handler: handler, patterns: patterns})
+	return nil
+}
+
+// newTopicTestManager returns a manager over an in-memory store with
+// connections for two users of tenant-1 and one user of tenant-2
+func newTopicTestManager(t *testing.T) (*Manager, *TestableAPIGatewayClient) {
+	t.Helper()
+
+	connStore := memory.NewConnectionStore()
+	apiGateway := NewTestableAPIGatewayClient()
+	for _, conn := range []*store.Connection{
+		{ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1"},
+		{ConnectionID: "conn-2", UserID: "user-1", TenantID: "tenant-1"},
+		{ConnectionID: "conn-3", UserID: "user-2", TenantID: "tenant-1"},
+		{ConnectionID: "conn-4", UserID: "user-3", TenantID: "tenant-2"},
+	} {
+		conn.Endpoint = "wss://example.com"
+		conn.LastPing = time.Now()
+		require.NoError(t, connStore.Save(context.Background(), conn))
+		apiGateway.AddConnection(conn.ConnectionID, "127.0.0.1")
+	}
+
+	manager := NewManager(connStore, apiGateway, "wss://example.com")
+	manager.SetLogger(func(format string, args ...interface{}) {})
+	return manager, apiGateway
+}
+
+// received returns the JSON messages posted to each connection
+func received(t *testing.T, apiGateway *TestableAPIGatewayClient) map[string][]map[string]interface{} {
+	t.Helper()
+
+	result := make(map[string][]map[string]interface{})
+	for _, id := range []string{"conn-1", "conn-2", "conn-3", "conn-4"} {
+		for _, frame := range apiGateway.GetMessages(id) {
+			var msg map[string]interface{}
+			require.NoError(t, json.Unmarshal(frame, &msg))
+			result[id] = append(result[id], msg)
+		}
+	}
+	return result
+}
+
+func TestTopics(t *testing.T) {
+	assert.Equal(t, "tenant:tenant-1", TenantTopic("tenant-1"))
+	assert.Equal(t, "user:user-1", UserTopic("user-1"))
+}
+
+func TestManager_BroadcastToTenantAndUser(t *testing.T) {
+	ctx := context.Background()
+	manager, apiGateway := newTopicTestManager(t)
+
+	require.NoError(t, manager.BroadcastToTenant(ctx, "tenant-1", map[string]interface{}{"type": "tenant"}))
+	require.NoError(t, manager.BroadcastToUser(ctx, "user-3", map[string]interface{}{"type": "user"}))
+
+	sent := received(t, apiGateway)
+	for _, id := range []string{"conn-1", "conn-2", "conn-3"} {
+		require.Len(t, sent[id], 1, id)
+		assert.Equal(t, "tenant", sent[id][0]["type"])
+	}
+	require.Len(t, sent["conn-4"], 1)
+	assert.Equal(t, "user", sent["conn-4"][0]["type"])
+
+	// A tenant with no connections is not an error
+	assert.NoError(t, manager.BroadcastToTenant(ctx, "tenant-none", map[string]interface{}{"type": "tenant"}))
+}
+
+func TestTopicBroadcaster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager, apiGateway := newTopicTestManager(t)
+	broadcaster := NewTopicBroadcaster(manager, &localPubSub{})
+	require.NoError(t, broadcaster.Start(ctx))
+
+	require.NoError(t, broadcaster.PublishToTenant(ctx, "tenant-2", map[string]interface{}{"type": "notice", "text": "maintenance"}))
+	require.NoError(t, broadcaster.PublishToUser(ctx, "user-1", map[string]interface{}{"type": "notice", "text": "hello"}))
+
+	sent := received(t, apiGateway)
+	require.Len(t, sent["conn-4"], 1)
+	assert.Equal(t, "maintenance", sent["conn-4"][0]["text"])
+	for _, id := range []string{"conn-1", "conn-2"} {
+		require.Len(t, sent[id], 1, id)
+		assert.Equal(t, "hello", sent[id][0]["text"])
+	}
+	assert.Empty(t, sent["conn-3"])
+
+	t.Run("unmarshalable message", func(t *testing.T) {
+		assert.Error(t, broadcaster.PublishToUser(ctx, "user-1", map[string]interface{}{"bad": make(chan int)}))
+	})
+}
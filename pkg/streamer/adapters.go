@@ -24,16 +24,20 @@ func NewRequestQueueAdapter(queue store.RequestQueue) RequestStore {
 func (a *RequestQueueAdapter) Enqueue(ctx context.Context, request *Request) error {
 	// Convert router.Request to store.AsyncRequest
 	asyncReq := &store.AsyncRequest{
-		RequestID:    request.ID,
-		ConnectionID: request.ConnectionID,
-		Action:       request.Action,
-		Status:       store.StatusPending,
-		Payload:      make(map[string]interface{}),
-		CreatedAt:    request.CreatedAt,
-		Progress:     0,
-		RetryCount:   0,
-		MaxRetries:   3,                                         // Default retry count
-		TTL:          time.Now().Add(7 * 24 * time.Hour).Unix(), // 7 days TTL
+		RequestID:      request.ID,
+		ConnectionID:   request.ConnectionID,
+		UserID:         request.UserID,
+		TenantID:       request.TenantID,
+		Action:         request.Action,
+		Status:         store.StatusPending,
+		Payload:        make(map[string]interface{}),
+		CreatedAt:      request.CreatedAt,
+		Progress:       0,
+		RetryCount:     0,
+		MaxRetries:     3,                                         // Default retry count
+		TTL:            time.Now().Add(7 * 24 * time.Hour).Unix(), // 7 days TTL
+		CallbackURL:    request.CallbackURL,
+		CallbackSecret: request.CallbackSecret,
 	}
 
 	// Convert payload from json.RawMessage to map[string]interface{}
@@ -48,12 +52,25 @@ func (a *RequestQueueAdapter) Enqueue(ctx context.Context, request *Request) err
 		asyncReq.Payload["_metadata"] = request.Metadata
 	}
 
-	// Extract UserID and TenantID from request metadata if available
-	if userID, ok := request.Metadata["user_id"]; ok {
-		asyncReq.UserID = userID
+	// Fall back to the principal, then to legacy metadata, for requests
+	// that were not built by the router
+	principal, hasPrincipal := PrincipalFromContext(ctx)
+	if hasPrincipal {
+		asyncReq.Permissions = principal.Permissions
 	}
-	if tenantID, ok := request.Metadata["tenant_id"]; ok {
-		asyncReq.TenantID = tenantID
+	if asyncReq.UserID == "" {
+		if hasPrincipal {
+			asyncReq.UserID = principal.UserID
+		} else {
+			asyncReq.UserID = request.Metadata["user_id"]
+		}
+	}
+	if asyncReq.TenantID == "" {
+		if hasPrincipal {
+			asyncReq.TenantID = principal.TenantID
+		} else {
+			asyncReq.TenantID = request.Metadata["tenant_id"]
+		}
 	}
 
 	// Map error if enqueue fails
@@ -69,9 +86,12 @@ func ConvertAsyncRequestToRequest(asyncReq *store.AsyncRequest) (*Request, error
 	request := &Request{
 		ID:           asyncReq.RequestID,
 		ConnectionID: asyncReq.ConnectionID,
+		UserID:       asyncReq.UserID,
+		TenantID:     asyncReq.TenantID,
 		Action:       asyncReq.Action,
 		CreatedAt:    asyncReq.CreatedAt,
 		Metadata:     make(map[string]string),
+		CallbackURL:  asyncReq.CallbackURL,
 	}
 
 	// Extract metadata from payload if it exists
@@ -17,6 +17,8 @@ type mockRequestQueue struct {
 	enqueueErr       error
 	getErr           error
 	updateErr        error
+	counts           map[string]int // keyed by action, "" for all actions
+	countErr         error
 }
 
 func (m *mockRequestQueue) Enqueue(ctx context.Context, req *store.AsyncRequest) error {
@@ -70,12 +72,32 @@ func (m *mockRequestQueue) Dequeue(ctx context.Context, limit int) ([]*store.Asy
 func (m *mockRequestQueue) UpdateStatus(ctx context.Context, requestID string, status store.RequestStatus, message string) error {
 	return nil
 }
-func (m *mockRequestQueue) GetByConnection(ctx context.Context, connectionID string, limit int) ([]*store.AsyncRequest, error) {
+func (m *mockRequestQueue) Defer(ctx context.Context, requestID string, until time.Time) error {
+	return nil
+}
+func (m *mockRequestQueue) Resume(ctx context.Context, requestID string) error { return nil }
+func (m *mockRequestQueue) ListDeferred(ctx context.Context, due time.Time, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
 	return nil, nil
 }
-func (m *mockRequestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, limit int) ([]*store.AsyncRequest, error) {
+func (m *mockRequestQueue) RecordCallbackDelivery(ctx context.Context, requestID string, delivery store.CallbackDelivery) error {
+	return m.updateErr
+}
+
+func (m *mockRequestQueue) GetByConnection(ctx context.Context, connectionID string, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
 	return nil, nil
 }
+func (m *mockRequestQueue) GetByStatus(ctx context.Context, status store.RequestStatus, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return nil, nil
+}
+func (m *mockRequestQueue) GetByUser(ctx context.Context, userID string, filter store.RequestFilter, opts store.PageOptions) (*store.Page[*store.AsyncRequest], error) {
+	return nil, nil
+}
+func (m *mockRequestQueue) CountByTenant(ctx context.Context, tenantID, action string, statuses ...store.RequestStatus) (int, error) {
+	if m.countErr != nil {
+		return 0, m.countErr
+	}
+	return m.counts[action], nil
+}
 func (m *mockRequestQueue) Delete(ctx context.Context, requestID string) error { return nil }
 
 func TestRequestQueueAdapter_Enqueue(t *testing.T) {
@@ -147,6 +169,50 @@ func TestRequestQueueAdapter_Enqueue(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "request with identity fields",
+			request: &Request{
+				ID:           "req-id",
+				ConnectionID: "conn-id",
+				UserID:       "user-abc",
+				TenantID:     "tenant-def",
+				Action:       "process_data",
+				Metadata: map[string]string{
+					"user_id":   "spoofed-user",
+					"tenant_id": "spoofed-tenant",
+				},
+				CreatedAt: time.Now(),
+			},
+			wantErr: false,
+			verify: func(t *testing.T, asyncReq *store.AsyncRequest) {
+				if asyncReq.UserID != "user-abc" {
+					t.Errorf("UserID = %v, want %v", asyncReq.UserID, "user-abc")
+				}
+				if asyncReq.TenantID != "tenant-def" {
+					t.Errorf("TenantID = %v, want %v", asyncReq.TenantID, "tenant-def")
+				}
+			},
+		},
+		{
+			name: "request with callback",
+			request: &Request{
+				ID:             "req-cb",
+				ConnectionID:   "conn-cb",
+				Action:         "process_data",
+				CreatedAt:      time.Now(),
+				CallbackURL:    "https://example.com/hooks",
+				CallbackSecret: "s3cret",
+			},
+			wantErr: false,
+			verify: func(t *testing.T, asyncReq *store.AsyncRequest) {
+				if asyncReq.CallbackURL != "https://example.com/hooks" {
+					t.Errorf("CallbackURL = %v, want %v", asyncReq.CallbackURL, "https://example.com/hooks")
+				}
+				if asyncReq.CallbackSecret != "s3cret" {
+					t.Errorf("CallbackSecret = %v, want %v", asyncReq.CallbackSecret, "s3cret")
+				}
+			},
+		},
 		{
 			name: "invalid payload JSON",
 			request: &Request{
@@ -256,6 +322,12 @@ func TestConvertAsyncRequestToRequest(t *testing.T) {
 				if got.Action != tt.want.Action {
 					t.Errorf("Action = %v, want %v", got.Action, tt.want.Action)
 				}
+				if got.UserID != tt.asyncReq.UserID {
+					t.Errorf("UserID = %v, want %v", got.UserID, tt.asyncReq.UserID)
+				}
+				if got.TenantID != tt.asyncReq.TenantID {
+					t.Errorf("TenantID = %v, want %v", got.TenantID, tt.asyncReq.TenantID)
+				}
 				// Verify metadata
 				for k, v := range tt.want.Metadata {
 					if got.Metadata[k] != v {
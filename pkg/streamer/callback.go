@@ -0,0 +1,442 @@
+package streamer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Callback delivery defaults
+const (
+	// DefaultCallbackMaxAttempts is how many times a callback is POSTed before it is given up on
+	DefaultCallbackMaxAttempts = 5
+
+	// DefaultCallbackInitialBackoff is the wait before the second attempt; each later wait doubles
+	DefaultCallbackInitialBackoff = 1 * time.Minute
+
+	// DefaultCallbackMaxBackoff caps the wait between attempts
+	DefaultCallbackMaxBackoff = 1 * time.Hour
+
+	// DefaultCallbackTimeout bounds a single attempt
+	DefaultCallbackTimeout = 10 * time.Second
+)
+
+// callbackRetention bounds how long an undelivered callback is kept in the store
+const callbackRetention = 24 * time.Hour
+
+// Headers sent with every callback
+const (
+	CallbackSignatureHeader = "X-Streamer-Signature"
+	CallbackTimestampHeader = "X-Streamer-Timestamp"
+	CallbackRequestIDHeader = "X-Streamer-Request-Id"
+	CallbackAttemptHeader   = "X-Streamer-Delivery-Attempt"
+)
+
+// callbackSignaturePrefix names the algorithm in the signature header
+const callbackSignaturePrefix = "sha256="
+
+// Callback errors
+var (
+	ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+	ErrCallbackExpired          = errors.New("callback timestamp outside tolerance")
+	ErrCallbackAddressBlocked   = errors.New("callback address is not allowed")
+)
+
+// metadataAddrs are the cloud instance metadata endpoints. Both are already
+// link-local or private; they are listed so the intent survives any change
+// to the address checks.
+var metadataAddrs = []netip.Addr{
+	netip.MustParseAddr("169.254.169.254"),
+	netip.MustParseAddr("fd00:ec2::254"),
+}
+
+// blockedPrefixes are ranges outside the loopback, private and link-local
+// checks that still only reach hosts on the local network
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// CallbackPayload is the JSON body POSTed to a request's callback URL once it finishes
+type CallbackPayload struct {
+	RequestID  string                 `json:"request_id"`
+	Action     string                 `json:"action"`
+	Status     store.RequestStatus    `json:"status"`
+	Result     map[string]interface{} `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	FinishedAt time.Time              `json:"finished_at"`
+}
+
+// CallbackConfig controls how a failed delivery is retried. Zero fields use the defaults.
+type CallbackConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+}
+
+// withDefaults fills unset fields with the defaults
+func (c CallbackConfig) withDefaults() CallbackConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultCallbackMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultCallbackInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultCallbackMaxBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultCallbackTimeout
+	}
+	return c
+}
+
+// ValidateCallback checks the callback a client asked an async request's outcome to be sent to.
+// The URL must be absolute https, and a secret is required so the receiver can verify deliveries.
+// URLs naming localhost or a loopback, private, link-local or metadata address are refused. A
+// host name may still resolve to such an address, so the sender checks again when it connects.
+func ValidateCallback(callbackURL, secret string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Host == "" {
+		return errors.New("callback_url must be an absolute URL")
+	}
+	if u.Scheme != "https" {
+		return errors.New("callback_url must use https")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("callback_url must not point to a local address")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !callbackAddrAllowed(addr) {
+		return errors.New("callback_url must not point to a local address")
+	}
+
+	if secret == "" {
+		return errors.New("callback_secret is required with callback_url")
+	}
+	return nil
+}
+
+// callbackAddrAllowed reports whether a callback may be sent to addr. Only
+// public unicast addresses are allowed.
+func callbackAddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, metadata := range metadataAddrs {
+		if addr == metadata {
+			return false
+		}
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// newCallbackClient returns the client callbacks are POSTed with. Every
+// connection is checked against allowed once its host name is resolved, so a
+// name cannot be pointed at an internal address after it was validated. The
+// client uses no proxy, so the address checked is the receiver's, and it does
+// not follow redirects.
+func newCallbackClient(timeout time.Duration, allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrCallbackAddressBlocked, address)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// SignCallback returns the signature header value for a callback body sent at timestamp.
+// The signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the request's secret.
+func SignCallback(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return callbackSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback checks the signature headers of a received callback against its body.
+// A positive tolerance also rejects callbacks whose timestamp is further than that from now.
+func VerifyCallback(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(CallbackTimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidCallbackSignature
+	}
+
+	expected := SignCallback(secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(CallbackSignatureHeader)), []byte(expected)) {
+		return ErrInvalidCallbackSignature
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrCallbackExpired
+		}
+	}
+
+	return nil
+}
+
+// CallbackSender POSTs the outcome of finished requests to their callback
+// URLs. It is a lifecycle hook: a request that completes or fails has its
+// callback scheduled in a CallbackStore, and DeliverDue sends the callbacks
+// that are due, so no HTTP call is made while a request is being processed.
+// Network errors, 408, 429 and 5xx responses are retried on a later pass
+// with exponential backoff; any other response ends the delivery.
+type CallbackSender struct {
+	callbacks store.CallbackStore
+	requests  store.RequestQueue
+	client    *http.Client
+	config    CallbackConfig
+	secrets   *SecretBox
+	now       func() time.Time
+	logger    func(format string, args ...interface{})
+}
+
+// NewCallbackSender creates a sender that schedules callbacks in callbacks
+// and reads the finished requests from requests, recording each attempt there
+func NewCallbackSender(callbacks store.CallbackStore, requests store.RequestQueue, config CallbackConfig) *CallbackSender {
+	config = config.withDefaults()
+	return &CallbackSender{
+		callbacks: callbacks,
+		requests:  requests,
+		client:    newCallbackClient(config.Timeout, callbackAddrAllowed),
+		config:    config,
+		now:       time.Now,
+		logger:    func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) },
+	}
+}
+
+// SetLogger sets the logger for scheduling and delivery failures
+func (s *CallbackSender) SetLogger(logger func(format string, args ...interface{})) {
+	s.logger = logger
+}
+
+// SetSecretBox sets the box the router sealed callback secrets in, which
+// opens them before each delivery is signed
+func (s *CallbackSender) SetSecretBox(box *SecretBox) {
+	s.secrets = box
+}
+
+// OnTransition schedules the callback of a request that completed or failed.
+// A failed request that is retried has its callback withdrawn until it
+// finishes again. Register the sender with lifecycle.Observe on the queue
+// the processor finishes requests through.
+func (s *CallbackSender) OnTransition(ctx context.Context, from, to store.RequestStatus, req *store.AsyncRequest) {
+	if req.CallbackURL == "" {
+		return
+	}
+
+	switch to {
+	case store.StatusCompleted, store.StatusFailed:
+		now := s.now()
+		err := s.callbacks.Save(ctx, &store.PendingCallback{
+			RequestID:     req.RequestID,
+			CreatedAt:     now,
+			NextAttemptAt: now,
+			TTL:           now.Add(callbackRetention).Unix(),
+		})
+		if err != nil {
+			s.logger("Failed to schedule callback for request %s: %v", req.RequestID, err)
+		}
+	case store.StatusRetrying:
+		if from != store.StatusFailed {
+			return
+		}
+		if err := s.callbacks.Delete(ctx, req.RequestID); err != nil {
+			s.logger("Failed to withdraw callback for request %s: %v", req.RequestID, err)
+		}
+	}
+}
+
+// DeliverDue makes the next attempt of up to limit callbacks that are due and
+// returns how many were delivered. Every attempt is recorded on its request
+// with RecordCallbackDelivery.
+func (s *CallbackSender) DeliverDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.callbacks.ListDue(ctx, s.now(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due callbacks: %w", err)
+	}
+
+	delivered := 0
+	for _, callback := range due {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		sent, err := s.deliver(ctx, callback)
+		if err != nil {
+			return delivered, err
+		}
+		if sent {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// deliver makes the next attempt of a pending callback. The following attempt
+// is scheduled before sending, so a pass that stops mid-send retries it. The
+// callback is dropped once it is delivered, refused or out of attempts, or
+// when its request has no outcome to send.
+func (s *CallbackSender) deliver(ctx context.Context, callback *store.PendingCallback) (bool, error) {
+	req, err := s.requests.Get(ctx, callback.RequestID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, s.drop(ctx, callback)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get request %s: %w", callback.RequestID, err)
+	}
+
+	// A retried request is scheduled again when it finishes
+	if req.CallbackURL == "" || (req.Status != store.StatusCompleted && req.Status != store.StatusFailed) {
+		return false, s.drop(ctx, callback)
+	}
+
+	callback.Attempts++
+	callback.NextAttemptAt = s.now().Add(s.backoff(callback.Attempts))
+	if err := s.callbacks.Save(ctx, callback); err != nil {
+		return false, fmt.Errorf("failed to schedule callback for request %s: %w", callback.RequestID, err)
+	}
+
+	delivery, retry := s.attempt(ctx, req, callback.Attempts)
+	if err := s.requests.RecordCallbackDelivery(ctx, req.RequestID, delivery); err != nil {
+		s.logger("Failed to record callback delivery for request %s: %v", req.RequestID, err)
+	}
+
+	if delivery.Delivered {
+		return true, s.drop(ctx, callback)
+	}
+	if !retry || callback.Attempts >= s.config.MaxAttempts {
+		s.logger("Giving up on callback for request %s after %d attempts: %s", req.RequestID, callback.Attempts, delivery.Error)
+		return false, s.drop(ctx, callback)
+	}
+	return false, nil
+}
+
+// backoff returns the wait after the given attempt
+func (s *CallbackSender) backoff(attempt int) time.Duration {
+	backoff := s.config.InitialBackoff
+	for i := 1; i < attempt && backoff < s.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.config.MaxBackoff {
+		backoff = s.config.MaxBackoff
+	}
+	return backoff
+}
+
+// drop removes a callback that will not be sent again
+func (s *CallbackSender) drop(ctx context.Context, callback *store.PendingCallback) error {
+	if err := s.callbacks.Delete(ctx, callback.RequestID); err != nil {
+		return fmt.Errorf("failed to remove callback for request %s: %w", callback.RequestID, err)
+	}
+	return nil
+}
+
+// attempt makes a single delivery and reports whether a failure is worth retrying
+func (s *CallbackSender) attempt(ctx context.Context, req *store.AsyncRequest, attempt int) (store.CallbackDelivery, bool) {
+	sentAt := s.now()
+	delivery := store.CallbackDelivery{
+		Attempt:     attempt,
+		Status:      req.Status,
+		AttemptedAt: sentAt.UTC(),
+	}
+
+	payload := CallbackPayload{
+		RequestID:  req.RequestID,
+		Action:     req.Action,
+		Status:     req.Status,
+		Result:     req.Result,
+		Error:      req.Error,
+		FinishedAt: sentAt.UTC(),
+	}
+	if req.ProcessingEnded != nil {
+		payload.FinishedAt = req.ProcessingEnded.UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Error = fmt.Sprintf("failed to marshal callback: %v", err)
+		return delivery, false
+	}
+
+	secret, err := s.secrets.Open(req.RequestID, req.CallbackSecret)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, false
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, false
+	}
+	timestamp := sentAt.Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(CallbackSignatureHeader, SignCallback(secret, timestamp, body))
+	httpReq.Header.Set(CallbackTimestampHeader, strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set(CallbackRequestIDHeader, req.RequestID)
+	httpReq.Header.Set(CallbackAttemptHeader, strconv.Itoa(attempt))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, ctx.Err() == nil && !errors.Is(err, ErrCallbackAddressBlocked)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Delivered = true
+		return delivery, false
+	}
+
+	delivery.Error = fmt.Sprintf("callback returned %s", resp.Status)
+	return delivery, resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,457 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/lifecycle"
+	"github.com/pay-theory/streamer/internal/store/memory"
+)
+
+// callbackReceiver records the callbacks a test server is sent
+type callbackReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+// serve answers each callback with the next status, repeating the last
+func (r *callbackReceiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.statuses[len(r.statuses)-1]
+	if len(r.received) < len(r.statuses) {
+		status = r.statuses[len(r.received)]
+	}
+	r.received = append(r.received, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(status)
+}
+
+func newCallbackServer(t *testing.T, statuses ...int) (*httptest.Server, *callbackReceiver) {
+	receiver := &callbackReceiver{statuses: statuses}
+	server := httptest.NewTLSServer(http.HandlerFunc(receiver.serve))
+	t.Cleanup(server.Close)
+	return server, receiver
+}
+
+// callbackTest is a callback sender over in-memory stores with a clock the test moves
+type callbackTest struct {
+	sender    *CallbackSender
+	requests  store.RequestQueue
+	callbacks store.CallbackStore
+	now       time.Time
+
+	// secret is stored with the request; empty means "s3cret"
+	secret string
+}
+
+// newCallbackTest creates a sender registered as a hook on an in-memory
+// queue. It sends to server, whose loopback address the test allows.
+func newCallbackTest(t *testing.T, server *httptest.Server) *callbackTest {
+	ct := &callbackTest{
+		requests:  memory.NewRequestQueue(),
+		callbacks: memory.NewCallbackStore(),
+		now:       time.Now(),
+	}
+	ct.sender = NewCallbackSender(ct.callbacks, ct.requests, CallbackConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     90 * time.Second,
+	})
+	ct.sender.now = func() time.Time { return ct.now }
+	ct.sender.SetLogger(t.Logf)
+	if server != nil {
+		ct.sender.client = newCallbackClient(time.Second, func(netip.Addr) bool { return true })
+		ct.sender.client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+	require.NoError(t, lifecycle.Observe(ct.requests, ct.sender))
+	return ct
+}
+
+// finish enqueues a request with a callback to url and completes it, or
+// fails it with errMsg
+func (ct *callbackTest) finish(t *testing.T, url, errMsg string) {
+	ctx := context.Background()
+	secret := ct.secret
+	if secret == "" {
+		secret = "s3cret"
+	}
+	require.NoError(t, ct.requests.Enqueue(ctx, &store.AsyncRequest{
+		RequestID:      "req-1",
+		ConnectionID:   "conn-1",
+		Action:         "generate_report",
+		UserID:         "user-1",
+		TenantID:       "tenant-1",
+		CallbackURL:    url,
+		CallbackSecret: secret,
+	}))
+	require.NoError(t, ct.requests.UpdateStatus(ctx, "req-1", store.StatusProcessing, ""))
+	if errMsg != "" {
+		require.NoError(t, ct.requests.FailRequest(ctx, "req-1", errMsg))
+		return
+	}
+	require.NoError(t, ct.requests.CompleteRequest(ctx, "req-1", map[string]interface{}{"rows": float64(10)}))
+}
+
+// pending returns the callbacks still scheduled, however far off
+func (ct *callbackTest) pending(t *testing.T) []*store.PendingCallback {
+	pending, err := ct.callbacks.ListDue(context.Background(), ct.now.Add(24*time.Hour), 0)
+	require.NoError(t, err)
+	return pending
+}
+
+// deliveries returns the attempts recorded on the request
+func (ct *callbackTest) deliveries(t *testing.T) []store.CallbackDelivery {
+	req, err := ct.requests.Get(context.Background(), "req-1")
+	require.NoError(t, err)
+	return req.CallbackDeliveries
+}
+
+func TestValidateCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		secret  string
+		wantErr string
+	}{
+		{name: "valid", url: "https://example.com/hooks", secret: "s3cret"},
+		{name: "plain http", url: "http://example.com/hooks", secret: "s3cret", wantErr: "https"},
+		{name: "relative", url: "/hooks", secret: "s3cret", wantErr: "absolute"},
+		{name: "unparseable", url: "https://exa mple.com", secret: "s3cret", wantErr: "absolute"},
+		{name: "missing secret", url: "https://example.com/hooks", wantErr: "callback_secret"},
+		{name: "public address", url: "https://93.184.216.34/hooks", secret: "s3cret"},
+		{name: "localhost", url: "https://localhost:8443/hooks", secret: "s3cret", wantErr: "local address"},
+		{name: "loopback", url: "https://127.0.0.1/hooks", secret: "s3cret", wantErr: "local address"},
+		{name: "ipv6 loopback", url: "https://[::1]/hooks", secret: "s3cret", wantErr: "local address"},
+		{name: "private", url: "https://10.0.0.8/hooks", secret: "s3cret", wantErr: "local address"},
+		{name: "mapped private", url: "https://[::ffff:192.168.1.1]/hooks", secret: "s3cret", wantErr: "local address"},
+		{name: "metadata", url: "https://169.254.169.254/latest/meta-data", secret: "s3cret", wantErr: "local address"},
+		{name: "unspecified", url: "https://0.0.0.0/hooks", secret: "s3cret", wantErr: "local address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCallback(tt.url, tt.secret)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSignAndVerifyCallback(t *testing.T) {
+	body := []byte(`{"request_id":"req-1"}`)
+	now := time.Now().Unix()
+	header := http.Header{}
+	header.Set(CallbackTimestampHeader, strconv.FormatInt(now, 10))
+	header.Set(CallbackSignatureHeader, SignCallback("s3cret", now, body))
+
+	assert.NoError(t, VerifyCallback("s3cret", header, body, time.Minute))
+	assert.ErrorIs(t, VerifyCallback("other", header, body, time.Minute), ErrInvalidCallbackSignature)
+	assert.ErrorIs(t, VerifyCallback("s3cret", header, []byte(`{"request_id":"req-2"}`), time.Minute), ErrInvalidCallbackSignature)
+
+	// The timestamp is covered by the signature
+	replayed := header.Clone()
+	replayed.Set(CallbackTimestampHeader, strconv.FormatInt(now+1, 10))
+	assert.ErrorIs(t, VerifyCallback("s3cret", replayed, body, time.Minute), ErrInvalidCallbackSignature)
+
+	old := time.Now().Add(-time.Hour).Unix()
+	stale := http.Header{}
+	stale.Set(CallbackTimestampHeader, strconv.FormatInt(old, 10))
+	stale.Set(CallbackSignatureHeader, SignCallback("s3cret", old, body))
+	assert.ErrorIs(t, VerifyCallback("s3cret", stale, body, time.Minute), ErrCallbackExpired)
+	assert.NoError(t, VerifyCallback("s3cret", stale, body, 0))
+}
+
+func TestCallbackAddrAllowed(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.0.1"},
+		{addr: "169.254.169.254"},
+		{addr: "169.254.10.1"},
+		{addr: "fd00:ec2::254"},
+		{addr: "fe80::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "100.64.0.1"},
+		{addr: "224.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, callbackAddrAllowed(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func TestCallbackSender_OnTransition(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("finished requests are scheduled", func(t *testing.T) {
+		ct := newCallbackTest(t, nil)
+		ct.finish(t, "https://example.com/hooks", "")
+
+		pending := ct.pending(t)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "req-1", pending[0].RequestID)
+		assert.Zero(t, pending[0].Attempts)
+		assert.True(t, pending[0].NextAttemptAt.Equal(ct.now))
+		assert.Equal(t, ct.now.Add(callbackRetention).Unix(), pending[0].TTL)
+	})
+
+	t.Run("retried requests withdraw their callback", func(t *testing.T) {
+		ct := newCallbackTest(t, nil)
+		ct.finish(t, "https://example.com/hooks", "handler failed")
+		require.Len(t, ct.pending(t), 1)
+
+		require.NoError(t, ct.requests.UpdateStatus(ctx, "req-1", store.StatusRetrying, "Retry attempt 1/3"))
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("no callback url", func(t *testing.T) {
+		ct := newCallbackTest(t, nil)
+		ct.finish(t, "", "")
+		assert.Empty(t, ct.pending(t))
+	})
+}
+
+func TestCallbackSender_DeliverDue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signed payload", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusNoContent)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		require.Len(t, receiver.received, 1)
+		received := receiver.received[0]
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.Equal(t, "req-1", received.Header.Get(CallbackRequestIDHeader))
+		assert.Equal(t, "1", received.Header.Get(CallbackAttemptHeader))
+		assert.NoError(t, VerifyCallback("s3cret", received.Header, receiver.bodies[0], time.Minute))
+
+		var payload CallbackPayload
+		require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+		assert.Equal(t, "req-1", payload.RequestID)
+		assert.Equal(t, "generate_report", payload.Action)
+		assert.Equal(t, store.StatusCompleted, payload.Status)
+		assert.Equal(t, map[string]interface{}{"rows": float64(10)}, payload.Result)
+		assert.False(t, payload.FinishedAt.IsZero())
+
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, 1, deliveries[0].Attempt)
+		assert.Equal(t, store.StatusCompleted, deliveries[0].Status)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+		assert.True(t, deliveries[0].Delivered)
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("sealed secret", func(t *testing.T) {
+		box := newTestSecretBox(t)
+		sealed, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+
+		server, receiver := newCallbackServer(t, http.StatusOK)
+		ct := newCallbackTest(t, server)
+		ct.sender.SetSecretBox(box)
+		ct.secret = sealed
+		ct.finish(t, server.URL, "")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		require.Len(t, receiver.received, 1)
+		assert.NoError(t, VerifyCallback("s3cret", receiver.received[0].Header, receiver.bodies[0], time.Minute))
+	})
+
+	t.Run("sealed secret without a key is not sent", func(t *testing.T) {
+		sealed, err := newTestSecretBox(t).Seal("req-1", "s3cret")
+		require.NoError(t, err)
+
+		server, receiver := newCallbackServer(t, http.StatusOK)
+		ct := newCallbackTest(t, server)
+		ct.secret = sealed
+		ct.finish(t, server.URL, "")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.Empty(t, receiver.received)
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 1)
+		assert.Contains(t, deliveries[0].Error, "no key is configured")
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("retries server errors on later passes", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		pending := ct.pending(t)
+		require.Len(t, pending, 1)
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.True(t, pending[0].NextAttemptAt.Equal(ct.now.Add(time.Minute)))
+
+		// Nothing is sent before the next attempt is due
+		delivered, err = ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.Len(t, receiver.received, 1)
+
+		ct.now = ct.now.Add(time.Minute)
+		_, err = ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		pending = ct.pending(t)
+		require.Len(t, pending, 1)
+		assert.True(t, pending[0].NextAttemptAt.Equal(ct.now.Add(90*time.Second)), "backoff is capped")
+
+		ct.now = ct.now.Add(90 * time.Second)
+		delivered, err = ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		require.Len(t, receiver.received, 3)
+		assert.Equal(t, "3", receiver.received[2].Header.Get(CallbackAttemptHeader))
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 3)
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+		assert.Contains(t, deliveries[0].Error, "503")
+		assert.False(t, deliveries[0].Delivered)
+		assert.True(t, deliveries[2].Delivered)
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusBadGateway)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+
+		for i := 0; i < 5; i++ {
+			_, err := ct.sender.DeliverDue(ctx, 10)
+			require.NoError(t, err)
+			ct.now = ct.now.Add(time.Hour)
+		}
+		assert.Len(t, receiver.received, 3)
+		assert.Len(t, ct.deliveries(t), 3)
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusUnauthorized)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.Len(t, receiver.received, 1)
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("network errors are retried", func(t *testing.T) {
+		server, _ := newCallbackServer(t, http.StatusOK)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+		server.Close()
+
+		_, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 1)
+		assert.Zero(t, deliveries[0].StatusCode)
+		assert.NotEmpty(t, deliveries[0].Error)
+		assert.Len(t, ct.pending(t), 1)
+	})
+
+	t.Run("redirects are not followed", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusFound)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "")
+
+		_, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, receiver.received, 1)
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, http.StatusFound, deliveries[0].StatusCode)
+		assert.False(t, deliveries[0].Delivered)
+		assert.Empty(t, ct.pending(t))
+	})
+
+	t.Run("internal addresses are refused when connecting", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusOK)
+		ct := newCallbackTest(t, nil)
+		ct.finish(t, server.URL, "")
+
+		_, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, receiver.received)
+		deliveries := ct.deliveries(t)
+		require.Len(t, deliveries, 1)
+		assert.Contains(t, deliveries[0].Error, ErrCallbackAddressBlocked.Error())
+		assert.Empty(t, ct.pending(t), "blocked addresses are not retried")
+	})
+
+	t.Run("failed request", func(t *testing.T) {
+		server, receiver := newCallbackServer(t, http.StatusOK)
+		ct := newCallbackTest(t, server)
+		ct.finish(t, server.URL, "handler failed: timed out")
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		var payload CallbackPayload
+		require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+		assert.Equal(t, store.StatusFailed, payload.Status)
+		assert.Equal(t, "handler failed: timed out", payload.Error)
+		assert.Nil(t, payload.Result)
+	})
+
+	t.Run("requests that moved on are dropped", func(t *testing.T) {
+		ct := newCallbackTest(t, nil)
+		require.NoError(t, ct.callbacks.Save(ctx, &store.PendingCallback{RequestID: "req-missing", NextAttemptAt: ct.now}))
+
+		delivered, err := ct.sender.DeliverDue(ctx, 10)
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+		assert.Empty(t, ct.pending(t))
+	})
+}
@@ -0,0 +1,141 @@
+package streamer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pay-theory/streamer/pkg/types"
+)
+
+// Heartbeat actions answered by the router when no handler is registered for them
+const (
+	// ActionPing asks the server to confirm the connection is alive; the
+	// router replies with a pong message
+	ActionPing = "ping"
+
+	// ActionPong answers a server ping and gets no reply
+	ActionPong = "pong"
+)
+
+// DefaultActivityInterval is the least time between two activity records for
+// one connection. It must stay well below the reaper's idle timeout.
+const DefaultActivityInterval = time.Minute
+
+// maxActivityEntries bounds the connections whose last record is remembered
+const maxActivityEntries = 10000
+
+// HeartbeatRecorder records that a connection showed signs of life.
+// store.ConnectionStore satisfies it.
+type HeartbeatRecorder interface {
+	UpdateLastPing(ctx context.Context, connectionID string) error
+}
+
+// SetHeartbeatRecorder sets where activity is recorded. Every routed
+// message counts, including heartbeats and actions with their own handler,
+// so busy connections are not reaped as idle.
+func (r *DefaultRouter) SetHeartbeatRecorder(recorder HeartbeatRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.heartbeats = recorder
+}
+
+// SetActivityInterval sets the least time between activity records for one
+// connection, bounding the store writes a chatty client causes
+func (r *DefaultRouter) SetActivityInterval(interval time.Duration) {
+	r.activity.setInterval(interval)
+}
+
+// isHeartbeat reports whether action is a heartbeat
+func isHeartbeat(action string) bool {
+	return action == ActionPing || action == ActionPong
+}
+
+// recordActivity records that a connection sent a message, at most once per
+// activity interval
+func (r *DefaultRouter) recordActivity(ctx context.Context, connectionID string) {
+	r.mu.RLock()
+	recorder := r.heartbeats
+	r.mu.RUnlock()
+
+	if recorder == nil || !r.activity.due(connectionID) {
+		return
+	}
+
+	// A failed update only brings the connection closer to the idle timeout,
+	// so the message is still handled
+	if err := recorder.UpdateLastPing(ctx, connectionID); err != nil {
+		r.activity.forget(connectionID)
+	}
+}
+
+// heartbeat answers pings; pongs need no reply
+func (r *DefaultRouter) heartbeat(ctx context.Context, request *Request) error {
+	if request.Action != ActionPing {
+		return nil
+	}
+
+	pong := map[string]interface{}{
+		"type":       string(types.MessageTypePong),
+		"request_id": request.ID,
+		"timestamp":  time.Now().Unix(),
+	}
+	return r.connManager.Send(ctx, request.ConnectionID, pong)
+}
+
+// activityThrottle remembers when each connection's activity was last
+// recorded by this instance
+type activityThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	now      func() time.Time
+	recorded map[string]time.Time
+}
+
+func newActivityThrottle() *activityThrottle {
+	return &activityThrottle{
+		interval: DefaultActivityInterval,
+		now:      time.Now,
+		recorded: make(map[string]time.Time),
+	}
+}
+
+func (t *activityThrottle) setInterval(interval time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = interval
+}
+
+// due reports whether a connection's activity should be recorded now, and
+// if so notes it as recorded
+func (t *activityThrottle) due(connectionID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if last, ok := t.recorded[connectionID]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+
+	if len(t.recorded) >= maxActivityEntries {
+		for id, last := range t.recorded {
+			if now.Sub(last) >= t.interval {
+				delete(t.recorded, id)
+			}
+		}
+		if len(t.recorded) >= maxActivityEntries {
+			// Every entry is recent; recording some connections early is harmless
+			t.recorded = make(map[string]time.Time)
+		}
+	}
+
+	t.recorded[connectionID] = now
+	return true
+}
+
+// forget drops a connection's record so its next message records again
+func (t *activityThrottle) forget(connectionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.recorded, connectionID)
+}
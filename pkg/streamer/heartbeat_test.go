@@ -0,0 +1,165 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockHeartbeatRecorder struct {
+	mock.Mock
+}
+
+func (m *mockHeartbeatRecorder) UpdateLastPing(ctx context.Context, connectionID string) error {
+	args := m.Called(ctx, connectionID)
+	return args.Error(0)
+}
+
+func heartbeatEvent(body string) events.APIGatewayWebsocketProxyRequest {
+	return events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+			ConnectionID: "conn-123",
+		},
+		Body: body,
+	}
+}
+
+func TestDefaultRouter_Heartbeat(t *testing.T) {
+	isPong := mock.MatchedBy(func(msg interface{}) bool {
+		m, ok := msg.(map[string]interface{})
+		return ok && m["type"] == "pong" && m["request_id"] == "ping-1"
+	})
+
+	t.Run("ping is recorded and answered", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		recorder := new(mockHeartbeatRecorder)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetHeartbeatRecorder(recorder)
+
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+		mockConnMgr.On("Send", mock.Anything, "conn-123", isPong).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "ping", "id": "ping-1"}`)))
+		recorder.AssertExpectations(t)
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("pong is recorded without a reply", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		recorder := new(mockHeartbeatRecorder)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetHeartbeatRecorder(recorder)
+
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		recorder.AssertExpectations(t)
+		mockConnMgr.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ping is answered when recording fails", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		recorder := new(mockHeartbeatRecorder)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetHeartbeatRecorder(recorder)
+
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(errors.New("dynamodb unavailable"))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", isPong).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "ping", "id": "ping-1"}`)))
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("ping without a recorder", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+
+		mockConnMgr.On("Send", mock.Anything, "conn-123", isPong).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "ping", "id": "ping-1"}`)))
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("registered handler takes precedence", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		recorder := new(mockHeartbeatRecorder)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetHeartbeatRecorder(recorder)
+
+		handler := new(mockHandler)
+		handler.On("Validate", mock.Anything).Return(nil)
+		handler.On("EstimatedDuration").Return(time.Millisecond)
+		handler.On("Process", mock.Anything, mock.Anything).Return(&Result{Success: true}, nil)
+		assert.NoError(t, router.Handle("ping", handler))
+
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			return ok && m["type"] == "response"
+		})).Return(nil)
+
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "ping", "id": "ping-1"}`)))
+		handler.AssertExpectations(t)
+		recorder.AssertExpectations(t)
+	})
+}
+
+func TestDefaultRouter_RecordsActivity(t *testing.T) {
+	newRouter := func(recorder *mockHeartbeatRecorder, clock *time.Time) *DefaultRouter {
+		mockConnMgr := new(mockConnectionManager)
+		mockConnMgr.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetHeartbeatRecorder(recorder)
+		router.activity.now = func() time.Time { return *clock }
+		return router
+	}
+
+	t.Run("any action counts and updates are throttled", func(t *testing.T) {
+		clock := time.Now()
+		recorder := new(mockHeartbeatRecorder)
+		router := newRouter(recorder, &clock)
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+
+		// Unknown actions still show the connection is alive
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "report.generate"}`)))
+		}
+		recorder.AssertNumberOfCalls(t, "UpdateLastPing", 1)
+
+		clock = clock.Add(DefaultActivityInterval)
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "ping", "id": "ping-1"}`)))
+		recorder.AssertNumberOfCalls(t, "UpdateLastPing", 2)
+	})
+
+	t.Run("failed updates are retried on the next message", func(t *testing.T) {
+		clock := time.Now()
+		recorder := new(mockHeartbeatRecorder)
+		router := newRouter(recorder, &clock)
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(errors.New("dynamodb unavailable")).Once()
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		recorder.AssertNumberOfCalls(t, "UpdateLastPing", 2)
+	})
+
+	t.Run("custom interval", func(t *testing.T) {
+		clock := time.Now()
+		recorder := new(mockHeartbeatRecorder)
+		router := newRouter(recorder, &clock)
+		router.SetActivityInterval(10 * time.Second)
+		recorder.On("UpdateLastPing", mock.Anything, "conn-123").Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		clock = clock.Add(10 * time.Second)
+		assert.NoError(t, router.Route(context.Background(), heartbeatEvent(`{"action": "pong"}`)))
+		recorder.AssertNumberOfCalls(t, "UpdateLastPing", 2)
+	})
+}
@@ -0,0 +1,131 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Principal describes the authenticated identity behind a WebSocket connection
+type Principal struct {
+	ConnectionID string            `json:"connection_id"`
+	UserID       string            `json:"user_id"`
+	TenantID     string            `json:"tenant_id"`
+	Permissions  []string          `json:"permissions,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+
+	// ExpiresAt is when the connection's token expires; zero if it does not
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// HasPermission reports whether the principal was granted the given permission
+func (p *Principal) HasPermission(permission string) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the principal's token has expired at now
+func (p *Principal) Expired(now time.Time) bool {
+	return p != nil && !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// PrincipalResolver resolves the principal for a connection
+type PrincipalResolver interface {
+	ResolvePrincipal(ctx context.Context, connectionID string) (*Principal, error)
+}
+
+// ConnectionPrincipalResolver resolves principals from stored connection records
+type ConnectionPrincipalResolver struct {
+	store store.ConnectionStore
+}
+
+// NewConnectionPrincipalResolver creates a resolver backed by the connection store
+func NewConnectionPrincipalResolver(connStore store.ConnectionStore) *ConnectionPrincipalResolver {
+	return &ConnectionPrincipalResolver{store: connStore}
+}
+
+// ResolvePrincipal loads the connection record and converts it to a Principal
+func (r *ConnectionPrincipalResolver) ResolvePrincipal(ctx context.Context, connectionID string) (*Principal, error) {
+	conn, err := r.store.Get(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	return PrincipalFromConnection(conn), nil
+}
+
+// PrincipalFromConnection builds a Principal from a connection record.
+// Permissions are stored by the $connect handler as a JSON array in Metadata["permissions"].
+func PrincipalFromConnection(conn *store.Connection) *Principal {
+	principal := &Principal{
+		ConnectionID: conn.ConnectionID,
+		UserID:       conn.UserID,
+		TenantID:     conn.TenantID,
+		Metadata:     make(map[string]string),
+		ExpiresAt:    conn.TokenExpiresAt,
+	}
+
+	for k, v := range conn.Metadata {
+		if k == "permissions" {
+			continue
+		}
+		principal.Metadata[k] = v
+	}
+
+	if raw, ok := conn.Metadata["permissions"]; ok && raw != "" {
+		var permissions []string
+		if err := json.Unmarshal([]byte(raw), &permissions); err == nil {
+			principal.Permissions = permissions
+		}
+	}
+
+	return principal
+}
+
+// PrincipalFromAsyncRequest rebuilds the principal that submitted a queued request
+func PrincipalFromAsyncRequest(req *store.AsyncRequest) *Principal {
+	return &Principal{
+		ConnectionID: req.ConnectionID,
+		UserID:       req.UserID,
+		TenantID:     req.TenantID,
+		Permissions:  req.Permissions,
+	}
+}
+
+// principalContextKey is the type for the principal context key
+type principalContextKey struct{}
+
+// WithPrincipal adds a principal to the context
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext retrieves the principal from context
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// resolvePrincipal resolves and validates the principal for a connection
+func resolvePrincipal(ctx context.Context, resolver PrincipalResolver, connectionID string) (*Principal, *Error) {
+	principal, err := resolver.ResolvePrincipal(ctx, connectionID)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return nil, NewError(ErrCodeUnauthorized, "Connection is not registered")
+		}
+		return nil, NewError(ErrCodeInternalError, fmt.Sprintf("Failed to resolve connection: %v", err))
+	}
+	if principal == nil || principal.UserID == "" || principal.TenantID == "" {
+		return nil, NewError(ErrCodeUnauthorized, "Connection has no associated identity")
+	}
+	return principal, nil
+}
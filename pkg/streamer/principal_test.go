@@ -0,0 +1,207 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Mock PrincipalResolver
+type mockPrincipalResolver struct {
+	mock.Mock
+}
+
+func (m *mockPrincipalResolver) ResolvePrincipal(ctx context.Context, connectionID string) (*Principal, error) {
+	args := m.Called(ctx, connectionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Principal), args.Error(1)
+}
+
+func TestPrincipalFromConnection(t *testing.T) {
+	conn := &store.Connection{
+		ConnectionID: "conn-123",
+		UserID:       "user-123",
+		TenantID:     "tenant-456",
+		Metadata: map[string]string{
+			"user_agent":  "test-agent",
+			"permissions": `["read","write"]`,
+		},
+	}
+
+	principal := PrincipalFromConnection(conn)
+
+	assert.Equal(t, "conn-123", principal.ConnectionID)
+	assert.Equal(t, "user-123", principal.UserID)
+	assert.Equal(t, "tenant-456", principal.TenantID)
+	assert.Equal(t, []string{"read", "write"}, principal.Permissions)
+	assert.Equal(t, "test-agent", principal.Metadata["user_agent"])
+	assert.NotContains(t, principal.Metadata, "permissions")
+	assert.True(t, principal.HasPermission("write"))
+	assert.False(t, principal.HasPermission("admin"))
+}
+
+func TestPrincipalFromConnection_InvalidPermissions(t *testing.T) {
+	conn := &store.Connection{
+		ConnectionID: "conn-123",
+		UserID:       "user-123",
+		TenantID:     "tenant-456",
+		Metadata:     map[string]string{"permissions": "not-json"},
+	}
+
+	principal := PrincipalFromConnection(conn)
+	assert.Empty(t, principal.Permissions)
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	var nilPrincipal *Principal
+	assert.False(t, nilPrincipal.HasPermission("read"))
+
+	ctx := WithPrincipal(context.Background(), &Principal{UserID: "user-1", TenantID: "tenant-1"})
+	principal, ok := PrincipalFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "user-1", principal.UserID)
+}
+
+func TestPrincipalFromAsyncRequest(t *testing.T) {
+	principal := PrincipalFromAsyncRequest(&store.AsyncRequest{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Permissions:  []string{"reports:read"},
+	})
+
+	assert.Equal(t, "conn-1", principal.ConnectionID)
+	assert.Equal(t, "user-1", principal.UserID)
+	assert.Equal(t, "tenant-1", principal.TenantID)
+	assert.True(t, principal.HasPermission("reports:read"))
+}
+
+func TestDefaultRouter_Route_WithPrincipalResolver(t *testing.T) {
+	t.Run("principal is available to sync handlers", func(t *testing.T) {
+		mockStore := new(mockRequestStore)
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		router := NewRouter(mockStore, mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-123").Return(&Principal{
+			ConnectionID: "conn-123",
+			UserID:       "user-123",
+			TenantID:     "tenant-456",
+			Permissions:  []string{"read"},
+		}, nil)
+
+		var seen *Principal
+		var seenReq *Request
+		handler := NewHandlerFunc(func(ctx context.Context, req *Request) (*Result, error) {
+			seen, _ = PrincipalFromContext(ctx)
+			seenReq = req
+			return &Result{RequestID: req.ID, Success: true}, nil
+		}, 10*time.Millisecond, nil)
+		require.NoError(t, router.Handle("whoami", handler))
+
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "whoami"}`,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, seen)
+		assert.Equal(t, "user-123", seen.UserID)
+		assert.Equal(t, "user-123", seenReq.UserID)
+		assert.Equal(t, "tenant-456", seenReq.TenantID)
+		assert.True(t, seen.HasPermission("read"))
+	})
+
+	t.Run("principal is carried onto enqueued request", func(t *testing.T) {
+		queue := &mockRequestQueue{}
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		router := NewRouter(NewRequestQueueAdapter(queue), mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-123").Return(&Principal{
+			ConnectionID: "conn-123",
+			UserID:       "user-123",
+			TenantID:     "tenant-456",
+			Permissions:  []string{"reports:create"},
+		}, nil)
+		require.NoError(t, router.Handle("slow", NewDelayHandler(time.Minute)))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "slow"}`,
+		})
+		require.NoError(t, err)
+		require.Len(t, queue.enqueuedRequests, 1)
+		assert.Equal(t, "user-123", queue.enqueuedRequests[0].UserID)
+		assert.Equal(t, "tenant-456", queue.enqueuedRequests[0].TenantID)
+		assert.Equal(t, []string{"reports:create"}, queue.enqueuedRequests[0].Permissions)
+	})
+
+	t.Run("unknown connection is rejected", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+		require.NoError(t, router.Handle("echo", NewEchoHandler()))
+
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-gone").
+			Return(nil, store.NewStoreError("Get", store.ConnectionsTable, "conn-gone", store.ErrNotFound))
+		mockConnMgr.On("Send", mock.Anything, "conn-gone", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeUnauthorized
+		})).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-gone"},
+			Body:           `{"action": "echo"}`,
+		})
+		assert.NoError(t, err)
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("store failure is an internal error", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+		require.NoError(t, router.Handle("echo", NewEchoHandler()))
+
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-1").Return(nil, errors.New("throttled"))
+		mockConnMgr.On("Send", mock.Anything, "conn-1", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeInternalError
+		})).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-1"},
+			Body:           `{"action": "echo"}`,
+		})
+		assert.NoError(t, err)
+		mockConnMgr.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,281 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// QuotaChecker decides whether a request may be queued for async processing.
+// CheckEnqueue returns an *Error with code QUOTA_EXCEEDED when the tenant is over quota.
+type QuotaChecker interface {
+	CheckEnqueue(ctx context.Context, request *Request) error
+}
+
+// ConcurrencyLimiter decides whether queued work for a tenant may start.
+// AcquireConcurrency takes a running slot and returns a func that gives it back
+// once the work finishes. It returns an *Error with code QUOTA_EXCEEDED when the
+// tenant is at its cap.
+type ConcurrencyLimiter interface {
+	AcquireConcurrency(ctx context.Context, tenantID, action string) (release func(context.Context) error, err error)
+}
+
+// QuotaLimits caps a tenant's outstanding async work. Zero means unlimited.
+type QuotaLimits struct {
+	// MaxActive limits queued plus running requests
+	MaxActive int `json:"max_active,omitempty"`
+
+	// MaxConcurrent limits running requests
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// QuotaConfig holds the limits for tenants without a stored quota.
+// Default applies across all of a tenant's actions; Actions applies per action.
+type QuotaConfig struct {
+	Default QuotaLimits            `json:"default"`
+	Actions map[string]QuotaLimits `json:"actions,omitempty"`
+}
+
+// ParseQuotaConfig parses a JSON quota configuration
+func ParseQuotaConfig(data []byte) (QuotaConfig, error) {
+	var config QuotaConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid quota config: %w", err)
+	}
+	return config, nil
+}
+
+// activeStatuses are the statuses counted against MaxActive
+var activeStatuses = []store.RequestStatus{store.StatusPending, store.StatusProcessing, store.StatusRetrying}
+
+// runningStatuses are the statuses counted against MaxConcurrent
+var runningStatuses = []store.RequestStatus{store.StatusProcessing, store.StatusRetrying}
+
+// TenantQuotaEnforcer enforces tenant quotas by counting requests in the request queue.
+// Quotas stored in the QuotaStore replace the configured defaults for that tenant and scope.
+// Running requests are tracked in a ConcurrencyStore when one is set.
+type TenantQuotaEnforcer struct {
+	quotas   store.QuotaStore
+	requests store.RequestQueue
+	counters store.ConcurrencyStore
+	config   QuotaConfig
+}
+
+// NewTenantQuotaEnforcer creates a quota enforcer. quotas may be nil to use only the defaults.
+func NewTenantQuotaEnforcer(quotas store.QuotaStore, requests store.RequestQueue, config QuotaConfig) *TenantQuotaEnforcer {
+	return &TenantQuotaEnforcer{
+		quotas:   quotas,
+		requests: requests,
+		config:   config,
+	}
+}
+
+// SetConcurrencyStore sets the counters MaxConcurrent is enforced with.
+// Without one, running requests are counted in the request queue, which does
+// not stop two processors from taking the last slot at the same time.
+func (e *TenantQuotaEnforcer) SetConcurrencyStore(counters store.ConcurrencyStore) {
+	e.counters = counters
+}
+
+// quotaScope pairs the action a limit is counted over with the limit itself
+type quotaScope struct {
+	action string // empty for tenant-wide limits
+	limits QuotaLimits
+}
+
+// CheckEnqueue rejects the request if the tenant already has MaxActive requests outstanding
+func (e *TenantQuotaEnforcer) CheckEnqueue(ctx context.Context, request *Request) error {
+	if request.TenantID == "" {
+		return nil
+	}
+
+	scopes, err := e.scopes(ctx, request.TenantID, request.Action)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		if err := e.check(ctx, request.TenantID, scope.action, scope.limits.MaxActive, activeStatuses, "active"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AcquireConcurrency takes a running slot in each of the tenant's scopes,
+// rejecting the work if any scope already has MaxConcurrent requests running
+func (e *TenantQuotaEnforcer) AcquireConcurrency(ctx context.Context, tenantID, action string) (func(context.Context) error, error) {
+	if tenantID == "" {
+		return releaseNothing, nil
+	}
+
+	scopes, err := e.scopes(ctx, tenantID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.counters == nil {
+		for _, scope := range scopes {
+			if err := e.check(ctx, tenantID, scope.action, scope.limits.MaxConcurrent, runningStatuses, "running"); err != nil {
+				return nil, err
+			}
+		}
+		return releaseNothing, nil
+	}
+
+	// The lease lapses when the work can no longer be running, so a processor
+	// killed before it releases the slot does not hold it for good
+	expiresAt := time.Now().Add(maxLeaseDuration)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(expiresAt) {
+		expiresAt = deadline
+	}
+
+	type heldLease struct{ key, lease string }
+	var held []heldLease
+	release := func(ctx context.Context) error {
+		var errs []error
+		for _, h := range held {
+			if err := e.counters.Release(ctx, h.key, h.lease); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, scope := range scopes {
+		limit := scope.limits.MaxConcurrent
+		if limit <= 0 {
+			continue
+		}
+
+		key := concurrencyKey(tenantID, scope.action)
+		lease, err := e.counters.Acquire(ctx, key, limit, expiresAt)
+		if err == nil && lease == "" {
+			err = NewError(ErrCodeQuotaExceeded, fmt.Sprintf("Tenant is already running its limit of %d requests", limit)).
+				WithDetail("scope", scopeName(scope.action)).
+				WithDetail("limit", limit)
+		} else if err != nil {
+			err = fmt.Errorf("failed to acquire a running slot for tenant %s: %w", tenantID, err)
+		}
+		if err != nil {
+			// Give back the slots already taken in the narrower scopes
+			_ = release(context.WithoutCancel(ctx))
+			return nil, err
+		}
+		held = append(held, heldLease{key: key, lease: lease})
+	}
+
+	return release, nil
+}
+
+// maxLeaseDuration bounds a running slot's lease when the work has no
+// deadline. It matches the longest a Lambda can run.
+const maxLeaseDuration = 15 * time.Minute
+
+// releaseNothing is returned when no running slot was taken
+func releaseNothing(context.Context) error {
+	return nil
+}
+
+// concurrencyKey names the leases on a tenant's running requests in a scope
+func concurrencyKey(tenantID, action string) string {
+	return fmt.Sprintf("tenant#%s#%s", tenantID, scopeName(action))
+}
+
+// scopeName returns the name a scope is reported and stored under
+func scopeName(action string) string {
+	if action == "" {
+		return store.QuotaAllActions
+	}
+	return action
+}
+
+// check counts the tenant's requests in statuses and compares them to limit
+func (e *TenantQuotaEnforcer) check(ctx context.Context, tenantID, action string, limit int, statuses []store.RequestStatus, kind string) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := e.requests.CountByTenant(ctx, tenantID, action, statuses...)
+	if err != nil {
+		return fmt.Errorf("failed to count %s requests for tenant %s: %w", kind, tenantID, err)
+	}
+	if count < limit {
+		return nil
+	}
+
+	return NewError(ErrCodeQuotaExceeded, fmt.Sprintf("Tenant has %d %s requests, the limit is %d", count, kind, limit)).
+		WithDetail("scope", scopeName(action)).
+		WithDetail("limit", limit).
+		WithDetail(kind, count)
+}
+
+// scopes resolves the action-specific and tenant-wide limits for a tenant
+func (e *TenantQuotaEnforcer) scopes(ctx context.Context, tenantID, action string) ([]quotaScope, error) {
+	actionLimits, err := e.limits(ctx, tenantID, action, e.config.Actions[action])
+	if err != nil {
+		return nil, err
+	}
+	tenantLimits, err := e.limits(ctx, tenantID, store.QuotaAllActions, e.config.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	return []quotaScope{
+		{action: action, limits: actionLimits},
+		{action: "", limits: tenantLimits},
+	}, nil
+}
+
+// limits returns the stored quota for the tenant and action, or fallback if none is stored
+func (e *TenantQuotaEnforcer) limits(ctx context.Context, tenantID, action string, fallback QuotaLimits) (QuotaLimits, error) {
+	if e.quotas == nil || action == "" {
+		return fallback, nil
+	}
+
+	quota, err := e.quotas.Get(ctx, tenantID, action)
+	if err != nil {
+		if store.IsNotFound(err) {
+			return fallback, nil
+		}
+		return QuotaLimits{}, fmt.Errorf("failed to load quota for tenant %s: %w", tenantID, err)
+	}
+
+	return QuotaLimits{
+		MaxActive:     quota.MaxActive,
+		MaxConcurrent: quota.MaxConcurrent,
+	}, nil
+}
+
+// ResumeDeferred resumes every request whose deferral has expired, so the
+// stream delivers it to the processor again. It returns how many were resumed.
+func ResumeDeferred(ctx context.Context, queue store.RequestQueue, now time.Time) (int, error) {
+	resumed := 0
+	opts := store.PageOptions{}
+	for {
+		page, err := queue.ListDeferred(ctx, now, opts)
+		if err != nil {
+			return resumed, fmt.Errorf("failed to list deferred requests: %w", err)
+		}
+
+		for _, req := range page.Items {
+			if err := queue.Resume(ctx, req.RequestID); err != nil {
+				// The request already moved on
+				if errors.Is(err, store.ErrRequestNotPending) {
+					continue
+				}
+				return resumed, fmt.Errorf("failed to resume request %s: %w", req.RequestID, err)
+			}
+			resumed++
+		}
+
+		if page.NextToken == "" {
+			return resumed, nil
+		}
+		opts.NextToken = page.NextToken
+	}
+}
@@ -0,0 +1,310 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+	"github.com/pay-theory/streamer/internal/store/memory"
+)
+
+// mockQuotaStore implements store.QuotaStore for testing
+type mockQuotaStore struct {
+	quotas map[string]*store.TenantQuota // keyed by tenant#action
+	getErr error
+}
+
+func (m *mockQuotaStore) Get(ctx context.Context, tenantID, action string) (*store.TenantQuota, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	quota, ok := m.quotas[tenantID+"#"+action]
+	if !ok {
+		return nil, store.NewStoreError("Get", store.TenantQuotasTable, tenantID, store.ErrNotFound)
+	}
+	return quota, nil
+}
+func (m *mockQuotaStore) Save(ctx context.Context, quota *store.TenantQuota) error { return nil }
+func (m *mockQuotaStore) Delete(ctx context.Context, tenantID, action string) error {
+	return nil
+}
+func (m *mockQuotaStore) ListByTenant(ctx context.Context, tenantID string) ([]*store.TenantQuota, error) {
+	return nil, nil
+}
+
+// Mock QuotaChecker
+type mockQuotaChecker struct {
+	mock.Mock
+}
+
+func (m *mockQuotaChecker) CheckEnqueue(ctx context.Context, request *Request) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func TestParseQuotaConfig(t *testing.T) {
+	config, err := ParseQuotaConfig([]byte(`{
+		"default": {"max_active": 100, "max_concurrent": 10},
+		"actions": {"process_data": {"max_active": 20, "max_concurrent": 2}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, QuotaLimits{MaxActive: 100, MaxConcurrent: 10}, config.Default)
+	assert.Equal(t, 2, config.Actions["process_data"].MaxConcurrent)
+
+	_, err = ParseQuotaConfig([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestTenantQuotaEnforcer_CheckEnqueue(t *testing.T) {
+	config := QuotaConfig{
+		Default: QuotaLimits{MaxActive: 10},
+		Actions: map[string]QuotaLimits{"process_data": {MaxActive: 3}},
+	}
+	request := &Request{TenantID: "tenant-1", Action: "process_data"}
+
+	tests := []struct {
+		name      string
+		quotas    *mockQuotaStore
+		counts    map[string]int
+		countErr  error
+		wantCode  string
+		wantScope string
+		wantPlain bool
+	}{
+		{
+			name:   "under quota",
+			counts: map[string]int{"process_data": 2, "": 5},
+		},
+		{
+			name:      "action quota exceeded",
+			counts:    map[string]int{"process_data": 3, "": 5},
+			wantCode:  ErrCodeQuotaExceeded,
+			wantScope: "process_data",
+		},
+		{
+			name:      "tenant quota exceeded",
+			counts:    map[string]int{"process_data": 0, "": 10},
+			wantCode:  ErrCodeQuotaExceeded,
+			wantScope: store.QuotaAllActions,
+		},
+		{
+			name: "stored quota replaces default",
+			quotas: &mockQuotaStore{quotas: map[string]*store.TenantQuota{
+				"tenant-1#process_data": {TenantID: "tenant-1", Action: "process_data", MaxActive: 50},
+			}},
+			counts: map[string]int{"process_data": 10, "": 5},
+		},
+		{
+			name: "stored zero quota is unlimited",
+			quotas: &mockQuotaStore{quotas: map[string]*store.TenantQuota{
+				"tenant-1#*": {TenantID: "tenant-1", Action: "*"},
+			}},
+			counts: map[string]int{"process_data": 0, "": 1000},
+		},
+		{
+			name:      "count failure is a plain error",
+			countErr:  errors.New("throttled"),
+			wantPlain: true,
+		},
+		{
+			name:      "quota store failure is a plain error",
+			quotas:    &mockQuotaStore{getErr: errors.New("throttled")},
+			wantPlain: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &mockRequestQueue{counts: tt.counts, countErr: tt.countErr}
+			var quotas store.QuotaStore
+			if tt.quotas != nil {
+				quotas = tt.quotas
+			}
+
+			err := NewTenantQuotaEnforcer(quotas, queue, config).CheckEnqueue(context.Background(), request)
+
+			var quotaErr *Error
+			switch {
+			case tt.wantCode != "":
+				require.True(t, errors.As(err, &quotaErr))
+				assert.Equal(t, tt.wantCode, quotaErr.Code)
+				assert.Equal(t, tt.wantScope, quotaErr.Details["scope"])
+			case tt.wantPlain:
+				require.Error(t, err)
+				assert.False(t, errors.As(err, &quotaErr))
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("requests without a tenant are not counted", func(t *testing.T) {
+		queue := &mockRequestQueue{countErr: errors.New("should not be called")}
+		err := NewTenantQuotaEnforcer(nil, queue, config).CheckEnqueue(context.Background(), &Request{Action: "process_data"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestTenantQuotaEnforcer_AcquireConcurrency(t *testing.T) {
+	ctx := context.Background()
+	config := QuotaConfig{
+		Default: QuotaLimits{MaxConcurrent: 2},
+		Actions: map[string]QuotaLimits{"process_data": {MaxConcurrent: 1}},
+	}
+
+	t.Run("counters", func(t *testing.T) {
+		enforcer := NewTenantQuotaEnforcer(nil, &mockRequestQueue{}, config)
+		enforcer.SetConcurrencyStore(memory.NewConcurrencyStore())
+
+		release, err := enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		require.NoError(t, err)
+
+		// The action slot is taken
+		_, err = enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		var quotaErr *Error
+		require.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, ErrCodeQuotaExceeded, quotaErr.Code)
+		assert.Equal(t, "process_data", quotaErr.Details["scope"])
+
+		// Two other requests fill the tenant-wide slots
+		require.NoError(t, release(ctx))
+		first, err := enforcer.AcquireConcurrency(ctx, "tenant-1", "export")
+		require.NoError(t, err)
+		second, err := enforcer.AcquireConcurrency(ctx, "tenant-1", "export")
+		require.NoError(t, err)
+
+		// The action slot is free, but the tenant is at its cap,
+		// and the refused attempt must not keep the action slot
+		_, err = enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		require.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, store.QuotaAllActions, quotaErr.Details["scope"])
+
+		require.NoError(t, first(ctx))
+		require.NoError(t, second(ctx))
+		release, err = enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		require.NoError(t, err)
+		require.NoError(t, release(ctx))
+	})
+
+	t.Run("slot held past its deadline lapses", func(t *testing.T) {
+		enforcer := NewTenantQuotaEnforcer(nil, &mockRequestQueue{}, config)
+		enforcer.SetConcurrencyStore(memory.NewConcurrencyStore())
+
+		// A processor killed before it could release its slot
+		deadCtx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+		_, err := enforcer.AcquireConcurrency(deadCtx, "tenant-1", "process_data")
+		require.NoError(t, err)
+
+		release, err := enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		require.NoError(t, err)
+		require.NoError(t, release(ctx))
+	})
+
+	t.Run("counting without counters", func(t *testing.T) {
+		queue := &mockRequestQueue{counts: map[string]int{"": 1}}
+		enforcer := NewTenantQuotaEnforcer(nil, queue, QuotaConfig{Default: QuotaLimits{MaxConcurrent: 2}})
+
+		release, err := enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		require.NoError(t, err)
+		assert.NoError(t, release(ctx))
+
+		queue.counts[""] = 2
+		_, err = enforcer.AcquireConcurrency(ctx, "tenant-1", "process_data")
+		var quotaErr *Error
+		require.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, ErrCodeQuotaExceeded, quotaErr.Code)
+		assert.Equal(t, 2, quotaErr.Details["running"])
+	})
+}
+
+func TestResumeDeferred(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	queue := memory.NewRequestQueue()
+
+	for i, until := range []time.Time{now.Add(-time.Minute), now.Add(-time.Second), now.Add(time.Minute)} {
+		id := fmt.Sprintf("req-%d", i)
+		require.NoError(t, queue.Enqueue(ctx, &store.AsyncRequest{RequestID: id, ConnectionID: "conn-1", UserID: "user-1", TenantID: "tenant-1", Action: "process_data"}))
+		require.NoError(t, queue.Defer(ctx, id, until))
+	}
+
+	resumed, err := ResumeDeferred(ctx, queue, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resumed)
+
+	page, err := queue.ListDeferred(ctx, now.Add(time.Hour), store.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "req-2", page.Items[0].RequestID)
+}
+
+func TestDefaultRouter_Route_WithQuotaChecker(t *testing.T) {
+	slowEvent := events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+		Body:           `{"action": "slow"}`,
+	}
+
+	t.Run("over quota request is not queued", func(t *testing.T) {
+		queue := &mockRequestQueue{}
+		mockConnMgr := new(mockConnectionManager)
+		checker := new(mockQuotaChecker)
+		router := NewRouter(NewRequestQueueAdapter(queue), mockConnMgr)
+		router.SetQuotaChecker(checker)
+		require.NoError(t, router.Handle("slow", NewDelayHandler(time.Minute)))
+
+		checker.On("CheckEnqueue", mock.Anything, mock.Anything).Return(NewError(ErrCodeQuotaExceeded, "Too many active requests"))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeQuotaExceeded
+		})).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), slowEvent))
+		assert.Empty(t, queue.enqueuedRequests)
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("checker failure fails open", func(t *testing.T) {
+		queue := &mockRequestQueue{}
+		mockConnMgr := new(mockConnectionManager)
+		checker := new(mockQuotaChecker)
+		router := NewRouter(NewRequestQueueAdapter(queue), mockConnMgr)
+		router.SetQuotaChecker(checker)
+		require.NoError(t, router.Handle("slow", NewDelayHandler(time.Minute)))
+
+		checker.On("CheckEnqueue", mock.Anything, mock.Anything).Return(errors.New("throttled"))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), slowEvent))
+		assert.Len(t, queue.enqueuedRequests, 1)
+	})
+
+	t.Run("sync requests are not checked", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		checker := new(mockQuotaChecker)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetQuotaChecker(checker)
+		require.NoError(t, router.Handle("echo", NewEchoHandler()))
+
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "echo"}`,
+		})
+		assert.NoError(t, err)
+		checker.AssertNotCalled(t, "CheckEnqueue", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,146 @@
+package streamer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// RateLimiter decides whether a request may be processed.
+// Allow returns an *Error with code RATE_LIMITED when the caller must back off.
+type RateLimiter interface {
+	Allow(ctx context.Context, request *Request) error
+}
+
+// RateLimit describes a token bucket. A zero Rate disables the limit.
+type RateLimit struct {
+	// Rate is the sustained number of requests allowed per second
+	Rate float64 `json:"rate"`
+
+	// Burst is the bucket capacity. Defaults to the rate rounded up, with a minimum of 1.
+	Burst int `json:"burst,omitempty"`
+}
+
+// burst returns the effective bucket capacity
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Max(1, math.Ceil(l.Rate)))
+}
+
+// interval returns how long the bucket takes to refill one token
+func (l RateLimit) interval() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+// RateLimitPolicy holds the limits applied per connection, user and tenant
+type RateLimitPolicy struct {
+	Connection RateLimit `json:"connection"`
+	User       RateLimit `json:"user"`
+	Tenant     RateLimit `json:"tenant"`
+}
+
+// RateLimitConfig configures rate limiting for the router.
+// Actions listed in Actions get their own buckets; all other actions share the default buckets.
+type RateLimitConfig struct {
+	Default RateLimitPolicy            `json:"default"`
+	Actions map[string]RateLimitPolicy `json:"actions,omitempty"`
+}
+
+// ParseRateLimitConfig parses a JSON rate limit configuration
+func ParseRateLimitConfig(data []byte) (RateLimitConfig, error) {
+	var config RateLimitConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid rate limit config: %w", err)
+	}
+	return config, nil
+}
+
+// TokenBucketLimiter enforces RateLimitConfig using buckets kept in a RateLimitStore,
+// so limits hold across every Lambda instance.
+type TokenBucketLimiter struct {
+	store  store.RateLimitStore
+	config RateLimitConfig
+}
+
+// NewTokenBucketLimiter creates a rate limiter backed by the given store
+func NewTokenBucketLimiter(rateLimitStore store.RateLimitStore, config RateLimitConfig) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		store:  rateLimitStore,
+		config: config,
+	}
+}
+
+// Allow takes a token from the connection, user and tenant buckets for the request.
+// If a bucket denies the request, tokens already taken from the others are
+// refunded so a denied request costs nothing.
+// A bucket too contended to update is treated as empty, since contention
+// means it is being drained. Other store failures are returned as plain
+// errors so the caller can decide whether to fail open.
+func (l *TokenBucketLimiter) Allow(ctx context.Context, request *Request) error {
+	policy := l.config.Default
+	scope := "*"
+	if actionPolicy, ok := l.config.Actions[request.Action]; ok {
+		policy = actionPolicy
+		scope = request.Action
+	}
+
+	checks := []struct {
+		kind  string
+		id    string
+		limit RateLimit
+	}{
+		{"connection", request.ConnectionID, policy.Connection},
+		{"user", request.UserID, policy.User},
+		{"tenant", request.TenantID, policy.Tenant},
+	}
+
+	var taken []bucketTake
+	for _, check := range checks {
+		if check.id == "" || check.limit.Rate <= 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s#%s#%s", check.kind, check.id, scope)
+		allowed, retryAfter, err := l.store.Take(ctx, key, check.limit.Rate, check.limit.burst())
+		if errors.Is(err, store.ErrConcurrentModification) {
+			allowed, retryAfter, err = false, check.limit.interval(), nil
+		}
+		if err != nil {
+			l.refund(ctx, taken)
+			return fmt.Errorf("rate limit check failed for %s: %w", key, err)
+		}
+		if !allowed {
+			l.refund(ctx, taken)
+			return NewError(ErrCodeRateLimited, fmt.Sprintf("Too many requests for this %s", check.kind)).
+				WithDetail("scope", check.kind).
+				WithDetail("action", request.Action).
+				WithDetail("retry_after_ms", retryAfter.Milliseconds()).
+				WithRetry(true, time.Now().Add(retryAfter), 0)
+		}
+		taken = append(taken, bucketTake{key: key, limit: check.limit})
+	}
+
+	return nil
+}
+
+// bucketTake is a token Allow took from a bucket
+type bucketTake struct {
+	key   string
+	limit RateLimit
+}
+
+// refund gives back the tokens taken for a request that was denied. A failed
+// refund only leaves the bucket a token short until it refills, so it is not
+// reported.
+func (l *TokenBucketLimiter) refund(ctx context.Context, taken []bucketTake) {
+	for _, t := range taken {
+		_ = l.store.Refund(ctx, t.key, t.limit.Rate, t.limit.burst())
+	}
+}
@@ -0,0 +1,269 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Mock RateLimitStore
+type mockRateLimitStore struct {
+	mock.Mock
+}
+
+func (m *mockRateLimitStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	args := m.Called(ctx, key, rate, burst)
+	return args.Bool(0), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func (m *mockRateLimitStore) Refund(ctx context.Context, key string, rate float64, burst int) error {
+	args := m.Called(ctx, key, rate, burst)
+	return args.Error(0)
+}
+
+// Mock RateLimiter
+type mockRateLimiter struct {
+	mock.Mock
+}
+
+func (m *mockRateLimiter) Allow(ctx context.Context, request *Request) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func TestParseRateLimitConfig(t *testing.T) {
+	config, err := ParseRateLimitConfig([]byte(`{
+		"default": {"connection": {"rate": 5, "burst": 10}, "user": {"rate": 10}},
+		"actions": {"generate_report": {"tenant": {"rate": 0.1, "burst": 2}}}
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, RateLimit{Rate: 5, Burst: 10}, config.Default.Connection)
+	assert.Equal(t, 10, config.Default.User.burst())
+	assert.Equal(t, 2, config.Actions["generate_report"].Tenant.burst())
+	assert.Equal(t, 1, RateLimit{Rate: 0.1}.burst())
+
+	_, err = ParseRateLimitConfig([]byte(`not-json`))
+	assert.Error(t, err)
+}
+
+func TestTokenBucketLimiter_Allow(t *testing.T) {
+	config := RateLimitConfig{
+		Default: RateLimitPolicy{
+			Connection: RateLimit{Rate: 5, Burst: 10},
+			User:       RateLimit{Rate: 10, Burst: 20},
+			Tenant:     RateLimit{Rate: 100, Burst: 200},
+		},
+		Actions: map[string]RateLimitPolicy{
+			"generate_report": {
+				Tenant: RateLimit{Rate: 1, Burst: 2},
+			},
+		},
+	}
+
+	request := &Request{
+		ConnectionID: "conn-1",
+		UserID:       "user-1",
+		TenantID:     "tenant-1",
+		Action:       "echo",
+	}
+
+	t.Run("all scopes allowed", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "user#user-1#*", 10.0, 20).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "tenant#tenant-1#*", 100.0, 200).Return(true, time.Duration(0), nil)
+
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), request)
+		assert.NoError(t, err)
+		rlStore.AssertExpectations(t)
+	})
+
+	t.Run("user limit exceeded", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "user#user-1#*", 10.0, 20).Return(false, 1500*time.Millisecond, nil)
+		rlStore.On("Refund", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(nil)
+
+		before := time.Now()
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), request)
+
+		var limitErr *Error
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, ErrCodeRateLimited, limitErr.Code)
+		assert.Equal(t, "user", limitErr.Details["scope"])
+		assert.Equal(t, int64(1500), limitErr.Details["retry_after_ms"])
+		require.NotNil(t, limitErr.Retry)
+		assert.True(t, limitErr.Retry.Retryable)
+		assert.WithinDuration(t, before.Add(1500*time.Millisecond), limitErr.Retry.After, time.Second)
+		rlStore.AssertNotCalled(t, "Take", mock.Anything, "tenant#tenant-1#*", mock.Anything, mock.Anything)
+
+		// The connection token is given back, since the request was denied
+		rlStore.AssertCalled(t, "Refund", mock.Anything, "connection#conn-1#*", 5.0, 10)
+		rlStore.AssertNumberOfCalls(t, "Refund", 1)
+	})
+
+	t.Run("tenant limit refunds connection and user", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "user#user-1#*", 10.0, 20).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "tenant#tenant-1#*", 100.0, 200).Return(false, time.Second, nil)
+		rlStore.On("Refund", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(nil)
+		rlStore.On("Refund", mock.Anything, "user#user-1#*", 10.0, 20).Return(errors.New("throttled"))
+
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), request)
+
+		var limitErr *Error
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, "tenant", limitErr.Details["scope"])
+		rlStore.AssertExpectations(t)
+	})
+
+	t.Run("per-action policy uses its own buckets", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "tenant#tenant-1#generate_report", 1.0, 2).Return(true, time.Duration(0), nil)
+
+		req := *request
+		req.Action = "generate_report"
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), &req)
+		assert.NoError(t, err)
+		rlStore.AssertExpectations(t)
+		rlStore.AssertNumberOfCalls(t, "Take", 1)
+	})
+
+	t.Run("unidentified caller skips user and tenant", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(true, time.Duration(0), nil)
+
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), &Request{ConnectionID: "conn-1", Action: "echo"})
+		assert.NoError(t, err)
+		rlStore.AssertNumberOfCalls(t, "Take", 1)
+	})
+
+	t.Run("contended bucket denies", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(true, time.Duration(0), nil)
+		rlStore.On("Take", mock.Anything, "user#user-1#*", 10.0, 20).Return(false, time.Duration(0),
+			store.NewStoreError("Take", store.RateLimitsTable, "user#user-1#*", store.ErrConcurrentModification))
+		rlStore.On("Refund", mock.Anything, "connection#conn-1#*", 5.0, 10).Return(nil)
+
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), request)
+
+		var limitErr *Error
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, ErrCodeRateLimited, limitErr.Code)
+		assert.Equal(t, "user", limitErr.Details["scope"])
+		assert.Equal(t, int64(100), limitErr.Details["retry_after_ms"])
+		rlStore.AssertExpectations(t)
+	})
+
+	t.Run("store failure is a plain error", func(t *testing.T) {
+		rlStore := new(mockRateLimitStore)
+		rlStore.On("Take", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, time.Duration(0), errors.New("throttled"))
+		rlStore.On("Refund", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		err := NewTokenBucketLimiter(rlStore, config).Allow(context.Background(), request)
+		require.Error(t, err)
+		var limitErr *Error
+		assert.False(t, errors.As(err, &limitErr))
+	})
+}
+
+func TestDefaultRouter_Route_WithRateLimiter(t *testing.T) {
+	event := events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+		Body:           `{"action": "echo", "payload": {"message": "hi"}}`,
+	}
+
+	t.Run("limited request is rejected with retry info", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		limiter := new(mockRateLimiter)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetRateLimiter(limiter)
+		require.NoError(t, router.Handle("echo", NewEchoHandler()))
+
+		limiter.On("Allow", mock.Anything, mock.AnythingOfType("*streamer.Request")).
+			Return(NewError(ErrCodeRateLimited, "Too many requests").WithRetry(true, time.Now().Add(time.Second), 0))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeRateLimited && err.Retry != nil
+		})).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), event))
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("queued requests are limited too", func(t *testing.T) {
+		queue := &mockRequestQueue{}
+		mockConnMgr := new(mockConnectionManager)
+		limiter := new(mockRateLimiter)
+		router := NewRouter(NewRequestQueueAdapter(queue), mockConnMgr)
+		router.SetRateLimiter(limiter)
+		require.NoError(t, router.Handle("slow", NewDelayHandler(time.Minute)))
+
+		limiter.On("Allow", mock.Anything, mock.Anything).Return(NewError(ErrCodeRateLimited, "Too many requests"))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "slow"}`,
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, queue.enqueuedRequests)
+	})
+
+	t.Run("limiter failure fails open", func(t *testing.T) {
+		mockConnMgr := new(mockConnectionManager)
+		limiter := new(mockRateLimiter)
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetRateLimiter(limiter)
+		require.NoError(t, router.Handle("echo", NewEchoHandler()))
+
+		limiter.On("Allow", mock.Anything, mock.Anything).Return(errors.New("dynamodb unavailable"))
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			return ok && m["type"] == "response"
+		})).Return(nil)
+
+		assert.NoError(t, router.Route(context.Background(), event))
+		mockConnMgr.AssertExpectations(t)
+	})
+}
+
+func TestDefaultRouter_Route_HandlerErrorPassthrough(t *testing.T) {
+	mockConnMgr := new(mockConnectionManager)
+	router := NewRouter(new(mockRequestStore), mockConnMgr)
+
+	handler := NewHandlerFunc(func(ctx context.Context, req *Request) (*Result, error) {
+		return nil, NewError(ErrCodeNotFound, "Report not found")
+	}, 10*time.Millisecond, nil)
+	require.NoError(t, router.Handle("get_report", handler))
+
+	mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+		m, ok := msg.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		err, ok := m["error"].(*Error)
+		return ok && err.Code == ErrCodeNotFound
+	})).Return(nil)
+
+	err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+		Body:           `{"action": "get_report"}`,
+	})
+	assert.NoError(t, err)
+	mockConnMgr.AssertExpectations(t)
+}
@@ -0,0 +1,183 @@
+package streamer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pay-theory/streamer/internal/store"
+)
+
+// Result offload defaults
+const (
+	// DefaultResultOffloadThreshold keeps results well under the 400KB DynamoDB item limit
+	DefaultResultOffloadThreshold = 64 * 1024
+
+	// DefaultResultTokenTTL is how long a result download token stays valid
+	DefaultResultTokenTTL = 1 * time.Hour
+)
+
+// Result token errors
+var (
+	ErrInvalidResultToken = errors.New("invalid result token")
+	ErrResultTokenExpired = errors.New("result token expired")
+)
+
+// ResultTokenClaims identifies an offloaded result and who may fetch it
+type ResultTokenClaims struct {
+	Key       string `json:"key"`
+	RequestID string `json:"rid"`
+	TenantID  string `json:"tid,omitempty"`
+	UserID    string `json:"uid,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ResultTokenSigner issues and verifies HMAC-SHA256 signed result tokens.
+// A token is base64url(claims JSON) + "." + base64url(signature).
+type ResultTokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewResultTokenSigner creates a signer. A zero ttl uses DefaultResultTokenTTL.
+func NewResultTokenSigner(secret []byte, ttl time.Duration) (*ResultTokenSigner, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("result token secret must be at least 32 bytes")
+	}
+	if ttl <= 0 {
+		ttl = DefaultResultTokenTTL
+	}
+	return &ResultTokenSigner{secret: secret, ttl: ttl, now: time.Now}, nil
+}
+
+// Sign issues a token for claims, setting its expiry
+func (s *ResultTokenSigner) Sign(claims ResultTokenClaims) (string, time.Time, error) {
+	expiresAt := s.now().Add(s.ttl)
+	claims.ExpiresAt = expiresAt.Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to marshal token claims: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), expiresAt, nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (s *ResultTokenSigner) Verify(token string) (*ResultTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return nil, ErrInvalidResultToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidResultToken
+	}
+
+	var claims ResultTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Key == "" {
+		return nil, ErrInvalidResultToken
+	}
+
+	if s.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrResultTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func (s *ResultTokenSigner) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ResultReference points a client at an offloaded result.
+// Clients pass Token to the fetch_result action to download it.
+type ResultReference struct {
+	Key       string    `json:"key"`
+	Size      int       `json:"size"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Action    string    `json:"action"`
+}
+
+// ResultOffloader moves results larger than a threshold into a ResultStore
+type ResultOffloader struct {
+	results   store.ResultStore
+	signer    *ResultTokenSigner
+	threshold int
+}
+
+// NewResultOffloader creates an offloader. A zero threshold uses DefaultResultOffloadThreshold.
+func NewResultOffloader(results store.ResultStore, signer *ResultTokenSigner, threshold int) *ResultOffloader {
+	if threshold <= 0 {
+		threshold = DefaultResultOffloadThreshold
+	}
+	return &ResultOffloader{results: results, signer: signer, threshold: threshold}
+}
+
+// Offload stores result if its JSON encoding exceeds the threshold and returns
+// a replacement holding a "result_ref" in place of the data. Smaller results
+// are returned unchanged.
+func (o *ResultOffloader) Offload(ctx context.Context, request *Request, result map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if len(data) <= o.threshold {
+		return result, nil
+	}
+
+	key := ResultKey(request)
+	if err := o.results.Put(ctx, key, data); err != nil {
+		return nil, fmt.Errorf("failed to store result: %w", err)
+	}
+
+	token, expiresAt, err := o.signer.Sign(ResultTokenClaims{
+		Key:       key,
+		RequestID: request.ID,
+		TenantID:  request.TenantID,
+		UserID:    request.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offloaded := map[string]interface{}{
+		"success": result["success"],
+		"result_ref": &ResultReference{
+			Key:       key,
+			Size:      len(data),
+			Token:     token,
+			ExpiresAt: expiresAt,
+			Action:    "fetch_result",
+		},
+	}
+	if metadata, ok := result["metadata"]; ok {
+		offloaded["metadata"] = metadata
+	}
+	return offloaded, nil
+}
+
+// ResultKey returns a new key to store the result of a request under.
+// Request IDs can be chosen by clients, so each key ends in a generated
+// component that no other request can name.
+func ResultKey(request *Request) string {
+	tenant := request.TenantID
+	if tenant == "" {
+		tenant = "_"
+	}
+	return tenant + "/" + request.ID + "/" + uuid.NewString() + ".json"
+}
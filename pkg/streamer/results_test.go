@@ -0,0 +1,138 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryResultStore implements store.ResultStore for testing
+type memoryResultStore struct {
+	objects map[string][]byte
+	putErr  error
+}
+
+func (m *memoryResultStore) Put(ctx context.Context, key string, data []byte) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+	m.objects[key] = data
+	return nil
+}
+func (m *memoryResultStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.objects[key], nil
+}
+func (m *memoryResultStore) Delete(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func newTestSigner(t *testing.T) *ResultTokenSigner {
+	signer, err := NewResultTokenSigner([]byte(strings.Repeat("k", 32)), time.Minute)
+	require.NoError(t, err)
+	return signer
+}
+
+func TestResultTokenSigner(t *testing.T) {
+	signer := newTestSigner(t)
+
+	token, expiresAt, err := signer.Sign(ResultTokenClaims{Key: "tenant-1/req-1.json", RequestID: "req-1", TenantID: "tenant-1"})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+
+	claims, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1/req-1.json", claims.Key)
+	assert.Equal(t, "tenant-1", claims.TenantID)
+
+	t.Run("tampered", func(t *testing.T) {
+		forged, _, err := signer.Sign(ResultTokenClaims{Key: "tenant-2/req-9.json"})
+		require.NoError(t, err)
+		payload, _, _ := strings.Cut(forged, ".")
+		_, signature, _ := strings.Cut(token, ".")
+
+		_, err = signer.Verify(payload + "." + signature)
+		assert.ErrorIs(t, err, ErrInvalidResultToken)
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		other, err := NewResultTokenSigner([]byte(strings.Repeat("x", 32)), time.Minute)
+		require.NoError(t, err)
+		_, err = other.Verify(token)
+		assert.ErrorIs(t, err, ErrInvalidResultToken)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := signer.Verify("not-a-token")
+		assert.ErrorIs(t, err, ErrInvalidResultToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		signer.now = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { signer.now = time.Now }()
+
+		_, err := signer.Verify(token)
+		assert.ErrorIs(t, err, ErrResultTokenExpired)
+	})
+
+	_, err = NewResultTokenSigner([]byte("short"), 0)
+	assert.Error(t, err)
+}
+
+func TestResultOffloader_Offload(t *testing.T) {
+	ctx := context.Background()
+	request := &Request{ID: "req-1", TenantID: "tenant-1", UserID: "user-1"}
+
+	t.Run("small result stays inline", func(t *testing.T) {
+		results := &memoryResultStore{objects: make(map[string][]byte)}
+		offloader := NewResultOffloader(results, newTestSigner(t), 1024)
+
+		result := map[string]interface{}{"success": true, "rows": 3}
+		offloaded, err := offloader.Offload(ctx, request, result)
+		require.NoError(t, err)
+		assert.Equal(t, result, offloaded)
+		assert.Empty(t, results.objects)
+	})
+
+	t.Run("large result is stored", func(t *testing.T) {
+		results := &memoryResultStore{objects: make(map[string][]byte)}
+		signer := newTestSigner(t)
+		offloader := NewResultOffloader(results, signer, 1024)
+
+		result := map[string]interface{}{
+			"success":  true,
+			"preview":  strings.Repeat("x", 2048),
+			"metadata": map[string]interface{}{"rows": 10},
+		}
+		offloaded, err := offloader.Offload(ctx, request, result)
+		require.NoError(t, err)
+
+		assert.Equal(t, true, offloaded["success"])
+		assert.Equal(t, result["metadata"], offloaded["metadata"])
+		assert.NotContains(t, offloaded, "preview")
+
+		ref := offloaded["result_ref"].(*ResultReference)
+		assert.True(t, strings.HasPrefix(ref.Key, "tenant-1/req-1/"), ref.Key)
+		assert.True(t, strings.HasSuffix(ref.Key, ".json"), ref.Key)
+		assert.Equal(t, "fetch_result", ref.Action)
+		assert.Len(t, results.objects[ref.Key], ref.Size)
+
+		claims, err := signer.Verify(ref.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "req-1", claims.RequestID)
+		assert.Equal(t, "user-1", claims.UserID)
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		results := &memoryResultStore{putErr: errors.New("access denied")}
+		offloader := NewResultOffloader(results, newTestSigner(t), 10)
+
+		_, err := offloader.Offload(ctx, request, map[string]interface{}{"preview": strings.Repeat("x", 100)})
+		assert.ErrorContains(t, err, "access denied")
+	})
+}
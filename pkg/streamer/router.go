@@ -2,12 +2,16 @@ package streamer
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/pay-theory/streamer/pkg/codec"
 )
 
 // Router handles incoming WebSocket messages and routes them to appropriate handlers
@@ -40,12 +44,18 @@ type ConnectionManager interface {
 
 // DefaultRouter implements the Router interface
 type DefaultRouter struct {
-	handlers       map[string]Handler
-	asyncThreshold time.Duration
-	requestStore   RequestStore
-	connManager    ConnectionManager
-	middlewares    []Middleware
-	mu             sync.RWMutex
+	handlers          map[string]Handler
+	asyncThreshold    time.Duration
+	requestStore      RequestStore
+	connManager       ConnectionManager
+	principalResolver PrincipalResolver
+	rateLimiter       RateLimiter
+	quotaChecker      QuotaChecker
+	heartbeats        HeartbeatRecorder
+	secrets           *SecretBox
+	activity          *activityThrottle
+	middlewares       []Middleware
+	mu                sync.RWMutex
 }
 
 // NewRouter creates a new router instance
@@ -55,6 +65,7 @@ func NewRouter(store RequestStore, connManager ConnectionManager) *DefaultRouter
 		asyncThreshold: 5 * time.Second, // Default threshold
 		requestStore:   store,
 		connManager:    connManager,
+		activity:       newActivityThrottle(),
 		middlewares:    []Middleware{},
 	}
 }
@@ -99,15 +110,64 @@ func (r *DefaultRouter) SetMiddleware(middleware ...Middleware) {
 	r.middlewares = append(r.middlewares, middleware...)
 }
 
+// SetPrincipalResolver sets the resolver used to identify the caller of each message.
+// When set, every message must come from a registered connection.
+func (r *DefaultRouter) SetPrincipalResolver(resolver PrincipalResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.principalResolver = resolver
+}
+
+// SetRateLimiter sets the limiter consulted before each message is handled.
+// Limits apply to both synchronous and queued requests.
+func (r *DefaultRouter) SetRateLimiter(limiter RateLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rateLimiter = limiter
+}
+
+// SetQuotaChecker sets the checker consulted before a request is queued for async processing
+func (r *DefaultRouter) SetQuotaChecker(checker QuotaChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quotaChecker = checker
+}
+
+// SetSecretBox sets the box callback secrets are sealed in before a request
+// is stored. The CallbackSender must open them with the same key.
+func (r *DefaultRouter) SetSecretBox(box *SecretBox) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = box
+}
+
 // Route processes an incoming WebSocket event
 func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsocketProxyRequest) error {
-	// Parse the incoming message
+	// Resolve the caller from the connection record
+	r.mu.RLock()
+	resolver := r.principalResolver
+	r.mu.RUnlock()
+
+	messageCodec := codec.JSON
+	if resolver != nil {
+		principal, resolveErr := resolvePrincipal(ctx, resolver, event.RequestContext.ConnectionID)
+		if resolveErr != nil {
+			return r.sendError(ctx, event.RequestContext.ConnectionID, resolveErr)
+		}
+		ctx = WithPrincipal(ctx, principal)
+		messageCodec = codec.Lookup(principal.Metadata[codec.MetadataKey])
+	}
+
+	// Parse the incoming message with the codec negotiated at $connect
 	var message map[string]interface{}
-	if err := json.Unmarshal([]byte(event.Body), &message); err != nil {
+	if err := decodeMessage(event, messageCodec, &message); err != nil {
 		return r.sendError(ctx, event.RequestContext.ConnectionID,
 			NewError(ErrCodeValidation, "Invalid message format"))
 	}
 
+	// Any message shows the connection is alive, whatever handles it
+	r.recordActivity(ctx, event.RequestContext.ConnectionID)
+
 	// Extract action from message
 	action, ok := message["action"].(string)
 	if !ok || action == "" {
@@ -115,6 +175,12 @@ func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsoc
 			NewError(ErrCodeValidation, "Missing or invalid action"))
 	}
 
+	// An expired token only lets the client refresh it before the connection is closed
+	if principal, ok := PrincipalFromContext(ctx); ok && principal.Expired(time.Now()) && !allowedWhenExpired(action) {
+		return r.sendError(ctx, event.RequestContext.ConnectionID,
+			NewError(ErrCodeTokenExpired, "Token has expired; send auth.refresh with a new token"))
+	}
+
 	// Create request object
 	request := &Request{
 		ID:           generateRequestID(),
@@ -124,6 +190,12 @@ func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsoc
 		Metadata:     make(map[string]string),
 	}
 
+	// Attribute the request to the caller
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		request.UserID = principal.UserID
+		request.TenantID = principal.TenantID
+	}
+
 	// Extract request ID if provided
 	if id, ok := message["id"].(string); ok {
 		request.ID = id
@@ -148,11 +220,48 @@ func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsoc
 		request.Payload = payloadBytes
 	}
 
+	// Extract the callback an async request's outcome is delivered to
+	if callbackURL, ok := message["callback_url"].(string); ok && callbackURL != "" {
+		secret, _ := message["callback_secret"].(string)
+		if err := ValidateCallback(callbackURL, secret); err != nil {
+			return r.sendError(ctx, event.RequestContext.ConnectionID,
+				NewError(ErrCodeValidation, err.Error()))
+		}
+		r.mu.RLock()
+		secrets := r.secrets
+		r.mu.RUnlock()
+		sealed, err := secrets.Seal(request.ID, secret)
+		if err != nil {
+			return r.sendError(ctx, event.RequestContext.ConnectionID,
+				NewError(ErrCodeInternalError, "Failed to store callback"))
+		}
+		request.CallbackURL = callbackURL
+		request.CallbackSecret = sealed
+	}
+
 	// Get handler for action
 	r.mu.RLock()
 	handler, exists := r.handlers[action]
+	limiter := r.rateLimiter
+	quotaChecker := r.quotaChecker
 	r.mu.RUnlock()
 
+	// Enforce rate limits before doing any work for the caller
+	if limiter != nil {
+		if err := limiter.Allow(ctx, request); err != nil {
+			var limitErr *Error
+			if errors.As(err, &limitErr) {
+				return r.sendError(ctx, event.RequestContext.ConnectionID, limitErr)
+			}
+			// Fail open: an unavailable limiter should not reject legitimate traffic
+		}
+	}
+
+	// Pings are answered by the router unless an application handles them
+	if !exists && isHeartbeat(action) {
+		return r.heartbeat(ctx, request)
+	}
+
 	if !exists {
 		return r.sendError(ctx, event.RequestContext.ConnectionID,
 			NewError(ErrCodeInvalidAction, fmt.Sprintf("Unknown action: %s", action)))
@@ -166,6 +275,17 @@ func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsoc
 
 	// Check if request should be processed async
 	if handler.EstimatedDuration() > r.asyncThreshold {
+		// Enforce the tenant's async quota
+		if quotaChecker != nil {
+			if err := quotaChecker.CheckEnqueue(ctx, request); err != nil {
+				var quotaErr *Error
+				if errors.As(err, &quotaErr) {
+					return r.sendError(ctx, event.RequestContext.ConnectionID, quotaErr)
+				}
+				// Fail open, as with rate limiting
+			}
+		}
+
 		// Queue for async processing
 		if err := r.requestStore.Enqueue(ctx, request); err != nil {
 			return r.sendError(ctx, event.RequestContext.ConnectionID,
@@ -185,6 +305,10 @@ func (r *DefaultRouter) Route(ctx context.Context, event events.APIGatewayWebsoc
 	// Process synchronously
 	result, err := handler.Process(ctx, request)
 	if err != nil {
+		var streamerErr *Error
+		if errors.As(err, &streamerErr) {
+			return r.sendError(ctx, event.RequestContext.ConnectionID, streamerErr)
+		}
 		return r.sendError(ctx, event.RequestContext.ConnectionID,
 			NewError(ErrCodeInternalError, err.Error()))
 	}
@@ -212,6 +336,20 @@ func (r *DefaultRouter) sendError(ctx context.Context, connectionID string, err
 	return r.connManager.Send(ctx, connectionID, response)
 }
 
+// decodeMessage decodes a WebSocket message body. API Gateway delivers
+// binary frames base64 encoded.
+func decodeMessage(event events.APIGatewayWebsocketProxyRequest, c codec.Codec, v interface{}) error {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return err
+		}
+		body = decoded
+	}
+	return c.Unmarshal(body, v)
+}
+
 // generateRequestID generates a unique request ID
 func generateRequestID() string {
 	// In production, use a proper UUID generator
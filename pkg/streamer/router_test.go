@@ -2,6 +2,7 @@ package streamer
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"testing"
@@ -11,6 +12,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/pay-theory/streamer/pkg/codec"
 )
 
 // Mock RequestStore
@@ -336,6 +339,98 @@ func TestDefaultRouter_Route(t *testing.T) {
 		mockConnMgr.AssertExpectations(t)
 	})
 
+	t.Run("async handler with callback", func(t *testing.T) {
+		mockStore := new(mockRequestStore)
+		mockConnMgr := new(mockConnectionManager)
+		router := NewRouter(mockStore, mockConnMgr)
+		router.SetAsyncThreshold(1 * time.Second)
+
+		mockHandler := new(mockHandler)
+		mockHandler.On("EstimatedDuration").Return(2 * time.Second)
+		mockHandler.On("Validate", mock.Anything).Return(nil)
+
+		router.Handle("async-action", mockHandler)
+
+		// The callback is queued with the request
+		mockStore.On("Enqueue", mock.Anything, mock.MatchedBy(func(req *Request) bool {
+			return req.CallbackURL == "https://example.com/hooks" && req.CallbackSecret == "s3cret"
+		})).Return(nil)
+		mockConnMgr.On("Send", mock.Anything, "conn-async", mock.Anything).Return(nil)
+
+		event := events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+				ConnectionID: "conn-async",
+			},
+			Body: `{"action": "async-action", "callback_url": "https://example.com/hooks", "callback_secret": "s3cret"}`,
+		}
+
+		err := router.Route(context.Background(), event)
+		assert.NoError(t, err)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("callback secret is sealed", func(t *testing.T) {
+		mockStore := new(mockRequestStore)
+		mockConnMgr := new(mockConnectionManager)
+		router := NewRouter(mockStore, mockConnMgr)
+		router.SetAsyncThreshold(1 * time.Second)
+		box := newTestSecretBox(t)
+		router.SetSecretBox(box)
+
+		mockHandler := new(mockHandler)
+		mockHandler.On("EstimatedDuration").Return(2 * time.Second)
+		mockHandler.On("Validate", mock.Anything).Return(nil)
+		router.Handle("async-action", mockHandler)
+
+		var queued *Request
+		mockStore.On("Enqueue", mock.Anything, mock.AnythingOfType("*streamer.Request")).Run(func(args mock.Arguments) {
+			queued = args.Get(1).(*Request)
+		}).Return(nil)
+		mockConnMgr.On("Send", mock.Anything, "conn-async", mock.Anything).Return(nil)
+
+		event := events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+				ConnectionID: "conn-async",
+			},
+			Body: `{"action": "async-action", "id": "req-sealed", "callback_url": "https://example.com/hooks", "callback_secret": "s3cret"}`,
+		}
+
+		require.NoError(t, router.Route(context.Background(), event))
+		require.NotNil(t, queued)
+		assert.NotContains(t, queued.CallbackSecret, "s3cret")
+
+		secret, err := box.Open("req-sealed", queued.CallbackSecret)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", secret)
+	})
+
+	t.Run("invalid callback", func(t *testing.T) {
+		mockStore := new(mockRequestStore)
+		mockConnMgr := new(mockConnectionManager)
+		router := NewRouter(mockStore, mockConnMgr)
+
+		mockConnMgr.On("Send", mock.Anything, "conn-callback", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeValidation && err.Message == "callback_url must use https"
+		})).Return(nil)
+
+		event := events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+				ConnectionID: "conn-callback",
+			},
+			Body: `{"action": "async-action", "callback_url": "http://example.com/hooks", "callback_secret": "s3cret"}`,
+		}
+
+		err := router.Route(context.Background(), event)
+		assert.NoError(t, err)
+		mockConnMgr.AssertExpectations(t)
+		mockStore.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
 	t.Run("validation failure", func(t *testing.T) {
 		mockStore := new(mockRequestStore)
 		mockConnMgr := new(mockConnectionManager)
@@ -499,6 +594,84 @@ func TestDefaultRouter_Route(t *testing.T) {
 	})
 }
 
+func TestDefaultRouter_Route_WithCodec(t *testing.T) {
+	message := map[string]interface{}{
+		"action":  "echo",
+		"id":      "req-42",
+		"payload": map[string]interface{}{"count": 3, "tags": []string{"a", "b"}},
+	}
+
+	for _, c := range []codec.Codec{codec.MessagePack, codec.CBOR} {
+		t.Run(c.Name(), func(t *testing.T) {
+			mockStore := new(mockRequestStore)
+			mockConnMgr := new(mockConnectionManager)
+			resolver := new(mockPrincipalResolver)
+			router := NewRouter(mockStore, mockConnMgr)
+			router.SetPrincipalResolver(resolver)
+
+			resolver.On("ResolvePrincipal", mock.Anything, "conn-123").Return(&Principal{
+				ConnectionID: "conn-123",
+				UserID:       "user-123",
+				TenantID:     "tenant-456",
+				Metadata:     map[string]string{codec.MetadataKey: c.Name()},
+			}, nil)
+
+			var seenReq *Request
+			handler := NewHandlerFunc(func(ctx context.Context, req *Request) (*Result, error) {
+				seenReq = req
+				return &Result{RequestID: req.ID, Success: true}, nil
+			}, 10*time.Millisecond, nil)
+			require.NoError(t, router.Handle("echo", handler))
+
+			mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+			body, err := c.Marshal(message)
+			require.NoError(t, err)
+
+			err = router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+				RequestContext:  events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+				Body:            base64.StdEncoding.EncodeToString(body),
+				IsBase64Encoded: true,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, seenReq)
+			assert.Equal(t, "req-42", seenReq.ID)
+			assert.JSONEq(t, `{"count":3,"tags":["a","b"]}`, string(seenReq.Payload))
+		})
+	}
+
+	t.Run("JSON body on a binary connection is rejected", func(t *testing.T) {
+		mockStore := new(mockRequestStore)
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		router := NewRouter(mockStore, mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-123").Return(&Principal{
+			ConnectionID: "conn-123",
+			UserID:       "user-123",
+			TenantID:     "tenant-456",
+			Metadata:     map[string]string{codec.MetadataKey: codec.NameMessagePack},
+		}, nil)
+
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.MatchedBy(func(msg interface{}) bool {
+			m, ok := msg.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			err, ok := m["error"].(*Error)
+			return ok && err.Code == ErrCodeValidation && err.Message == "Invalid message format"
+		})).Return(nil)
+
+		err := router.Route(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "echo"}`,
+		})
+		assert.NoError(t, err)
+		mockConnMgr.AssertExpectations(t)
+	})
+}
+
 func TestDefaultRouter_sendError(t *testing.T) {
 	mockConnMgr := new(mockConnectionManager)
 	router := &DefaultRouter{
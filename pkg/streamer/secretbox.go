@@ -0,0 +1,95 @@
+package streamer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SecretBoxKeySize is the length of a SecretBox key, which selects AES-256
+const SecretBoxKeySize = 32
+
+// sealedSecretPrefix marks a sealed secret and the format it is sealed in
+const sealedSecretPrefix = "sealed:v1:"
+
+// ErrSecretSealed is returned when a sealed secret is opened without a key
+var ErrSecretSealed = errors.New("callback secret is sealed and no key is configured")
+
+// SecretBox encrypts callback secrets before they are stored, so request
+// records, their copies in the requests table's stream and any backups only
+// hold ciphertext. Secrets are sealed with AES-256-GCM under a random nonce
+// and bound to their request ID, so a sealed secret cannot be moved to
+// another request.
+//
+// A nil *SecretBox leaves secrets as they are, which suits local development
+// only.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a SecretBox from a SecretBoxKeySize byte key
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != SecretBoxKeySize {
+		return nil, fmt.Errorf("secret box key must be %d bytes, got %d", SecretBoxKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// ParseSecretBoxKey creates a SecretBox from a base64 encoded key, as kept
+// in Secrets Manager or an encrypted environment variable
+func ParseSecretBoxKey(encoded string) (*SecretBox, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret box key is not base64: %w", err)
+	}
+	return NewSecretBox(key)
+}
+
+// Seal encrypts the callback secret of the request with ID requestID
+func (b *SecretBox) Seal(requestID, secret string) (string, error) {
+	if b == nil || secret == "" {
+		return secret, nil
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), []byte(requestID))
+	return sealedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed for the request with ID requestID. Secrets
+// stored before sealing was configured are returned as they are.
+func (b *SecretBox) Open(requestID, stored string) (string, error) {
+	encoded, sealed := strings.CutPrefix(stored, sealedSecretPrefix)
+	if !sealed {
+		return stored, nil
+	}
+	if b == nil {
+		return "", ErrSecretSealed
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", errors.New("sealed callback secret is malformed")
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, []byte(requestID))
+	if err != nil {
+		return "", errors.New("sealed callback secret does not open with this key")
+	}
+	return string(secret), nil
+}
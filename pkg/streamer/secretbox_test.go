@@ -0,0 +1,105 @@
+package streamer
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSecretBox creates a SecretBox with a fixed key
+func newTestSecretBox(t *testing.T) *SecretBox {
+	t.Helper()
+	box, err := NewSecretBox([]byte(strings.Repeat("k", SecretBoxKeySize)))
+	require.NoError(t, err)
+	return box
+}
+
+func TestSecretBox(t *testing.T) {
+	box := newTestSecretBox(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		sealed, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(sealed, sealedSecretPrefix))
+		assert.NotContains(t, sealed, "s3cret")
+
+		opened, err := box.Open("req-1", sealed)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", opened)
+	})
+
+	t.Run("nonce is random", func(t *testing.T) {
+		first, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+		second, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("bound to the request", func(t *testing.T) {
+		sealed, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+
+		_, err = box.Open("req-2", sealed)
+		assert.ErrorContains(t, err, "does not open")
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		sealed, err := box.Seal("req-1", "s3cret")
+		require.NoError(t, err)
+		other, err := NewSecretBox([]byte(strings.Repeat("o", SecretBoxKeySize)))
+		require.NoError(t, err)
+
+		_, err = other.Open("req-1", sealed)
+		assert.ErrorContains(t, err, "does not open")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := box.Open("req-1", sealedSecretPrefix+"!!")
+		assert.ErrorContains(t, err, "malformed")
+		_, err = box.Open("req-1", sealedSecretPrefix+"AAAA")
+		assert.ErrorContains(t, err, "malformed")
+	})
+
+	t.Run("plaintext stored before sealing", func(t *testing.T) {
+		opened, err := box.Open("req-1", "s3cret")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", opened)
+	})
+
+	t.Run("empty secret", func(t *testing.T) {
+		sealed, err := box.Seal("req-1", "")
+		require.NoError(t, err)
+		assert.Empty(t, sealed)
+	})
+}
+
+func TestSecretBox_Nil(t *testing.T) {
+	var box *SecretBox
+
+	sealed, err := box.Seal("req-1", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", sealed)
+
+	opened, err := box.Open("req-1", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", opened)
+
+	_, err = box.Open("req-1", sealedSecretPrefix+"AAAA")
+	assert.ErrorIs(t, err, ErrSecretSealed)
+}
+
+func TestNewSecretBox(t *testing.T) {
+	_, err := NewSecretBox([]byte("short"))
+	assert.ErrorContains(t, err, "32 bytes")
+
+	_, err = ParseSecretBoxKey("not base64!")
+	assert.ErrorContains(t, err, "base64")
+
+	box, err := ParseSecretBoxKey(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", SecretBoxKeySize))) + "\n")
+	require.NoError(t, err)
+	assert.NotNil(t, box)
+}
@@ -5,16 +5,24 @@ import (
 	"context"
 	"encoding/json"
 	"time"
+
+	"github.com/pay-theory/streamer/pkg/types"
 )
 
 // Request represents an incoming request from a WebSocket connection
 type Request struct {
 	ID           string            `json:"id"`
 	ConnectionID string            `json:"connection_id"`
+	UserID       string            `json:"user_id,omitempty"`
+	TenantID     string            `json:"tenant_id,omitempty"`
 	Action       string            `json:"action"`
 	Payload      json.RawMessage   `json:"payload"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
 	CreatedAt    time.Time         `json:"created_at"`
+
+	// Callback the outcome of an async request is POSTed to, signed with CallbackSecret
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"-"`
 }
 
 // Result represents the response from processing a request
@@ -31,6 +39,7 @@ type Error struct {
 	Code    string                 `json:"code"`
 	Message string                 `json:"message"`
 	Details map[string]interface{} `json:"details,omitempty"`
+	Retry   *types.RetryInfo       `json:"retry,omitempty"`
 }
 
 // ProgressUpdate represents a progress notification for async operations
@@ -80,7 +89,9 @@ const (
 	ErrCodeInternalError = "INTERNAL_ERROR"
 	ErrCodeTimeout       = "TIMEOUT"
 	ErrCodeRateLimited   = "RATE_LIMITED"
+	ErrCodeQuotaExceeded = "QUOTA_EXCEEDED"
 	ErrCodeInvalidAction = "INVALID_ACTION"
+	ErrCodeTokenExpired  = "TOKEN_EXPIRED"
 )
 
 // NewError creates a new Error instance
@@ -101,6 +112,16 @@ func (e *Error) WithDetail(key string, value interface{}) *Error {
 	return e
 }
 
+// WithRetry adds retry guidance to the error
+func (e *Error) WithRetry(retryable bool, after time.Time, maxTries int) *Error {
+	e.Retry = &types.RetryInfo{
+		Retryable: retryable,
+		After:     after,
+		MaxTries:  maxTries,
+	}
+	return e
+}
+
 // Error implements the error interface
 func (e *Error) Error() string {
 	return e.Message
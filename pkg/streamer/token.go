@@ -0,0 +1,10 @@
+package streamer
+
+// ActionAuthRefresh replaces the token of a live connection. Besides
+// heartbeats, it is the only action accepted once the token has expired.
+const ActionAuthRefresh = "auth.refresh"
+
+// allowedWhenExpired reports whether action may be sent with an expired token
+func allowedWhenExpired(action string) bool {
+	return action == ActionAuthRefresh || isHeartbeat(action)
+}
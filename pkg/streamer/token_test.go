@@ -0,0 +1,102 @@
+package streamer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipal_Expired(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		want      bool
+	}{
+		{"nil principal", nil, false},
+		{"token without expiry", &Principal{}, false},
+		{"token not yet expired", &Principal{ExpiresAt: now.Add(time.Minute)}, false},
+		{"token expires now", &Principal{ExpiresAt: now}, true},
+		{"token expired", &Principal{ExpiresAt: now.Add(-time.Minute)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.principal.Expired(now))
+		})
+	}
+}
+
+func TestDefaultRouter_Route_ExpiredToken(t *testing.T) {
+	expired := &Principal{
+		ConnectionID: "conn-123",
+		UserID:       "user-123",
+		TenantID:     "tenant-456",
+		ExpiresAt:    time.Now().Add(-time.Minute),
+	}
+
+	isTokenExpired := mock.MatchedBy(func(msg interface{}) bool {
+		m, ok := msg.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		err, ok := m["error"].(*Error)
+		return ok && err.Code == ErrCodeTokenExpired
+	})
+
+	newRouter := func(t *testing.T) (*DefaultRouter, *mockConnectionManager, *bool) {
+		mockConnMgr := new(mockConnectionManager)
+		resolver := new(mockPrincipalResolver)
+		resolver.On("ResolvePrincipal", mock.Anything, "conn-123").Return(expired, nil)
+
+		router := NewRouter(new(mockRequestStore), mockConnMgr)
+		router.SetPrincipalResolver(resolver)
+
+		called := false
+		handler := NewHandlerFunc(func(ctx context.Context, req *Request) (*Result, error) {
+			called = true
+			return &Result{RequestID: req.ID, Success: true}, nil
+		}, 10*time.Millisecond, nil)
+		require.NoError(t, router.Handle("echo", handler))
+		require.NoError(t, router.Handle(ActionAuthRefresh, handler))
+		return router, mockConnMgr, &called
+	}
+
+	event := func(action string) events.APIGatewayWebsocketProxyRequest {
+		return events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{ConnectionID: "conn-123"},
+			Body:           `{"action": "` + action + `"}`,
+		}
+	}
+
+	t.Run("other actions are rejected", func(t *testing.T) {
+		router, mockConnMgr, called := newRouter(t)
+		mockConnMgr.On("Send", mock.Anything, "conn-123", isTokenExpired).Return(nil)
+
+		require.NoError(t, router.Route(context.Background(), event("echo")))
+		assert.False(t, *called)
+		mockConnMgr.AssertExpectations(t)
+	})
+
+	t.Run("refresh is still accepted", func(t *testing.T) {
+		router, mockConnMgr, called := newRouter(t)
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		require.NoError(t, router.Route(context.Background(), event(ActionAuthRefresh)))
+		assert.True(t, *called)
+	})
+
+	t.Run("heartbeats are still accepted", func(t *testing.T) {
+		router, mockConnMgr, _ := newRouter(t)
+		mockConnMgr.On("Send", mock.Anything, "conn-123", mock.Anything).Return(nil)
+
+		require.NoError(t, router.Route(context.Background(), event(ActionPing)))
+		mockConnMgr.AssertNotCalled(t, "Send", mock.Anything, "conn-123", isTokenExpired)
+	})
+}
@@ -2,6 +2,7 @@
 package types
 
 import (
+	"encoding/base64"
 	"time"
 )
 
@@ -19,8 +20,14 @@ const (
 	MessageTypeError          MessageType = "error"
 
 	// Control message types
-	MessageTypePing MessageType = "ping"
-	MessageTypePong MessageType = "pong"
+	MessageTypePing          MessageType = "ping"
+	MessageTypePong          MessageType = "pong"
+	MessageTypeClose         MessageType = "close"
+	MessageTypeTokenExpiring MessageType = "token_expiring"
+
+	// Transport message types
+	MessageTypeChunk      MessageType = "chunk"
+	MessageTypeCompressed MessageType = "compressed"
 )
 
 // Message is the base structure for all WebSocket messages
@@ -29,6 +36,19 @@ type Message struct {
 	ID        string                 `json:"id,omitempty"`
 	Timestamp int64                  `json:"timestamp"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+
+	// Sequence is the message's position in its connection's stream, set
+	// when the message is sent
+	Sequence uint64 `json:"sequence,omitempty"`
+
+	// DeliveryID is the ID a client acks for messages sent with reliable delivery
+	DeliveryID string `json:"delivery_id,omitempty"`
+}
+
+// Base returns the base message, letting code that handles any message type
+// reach the fields all of them share
+func (m *Message) Base() *Message {
+	return m
 }
 
 // RequestMessage represents an incoming WebSocket request
@@ -71,6 +91,50 @@ type ErrorMessage struct {
 	Error     *ErrorInfo `json:"error"`
 }
 
+// ChunkMessage carries one fragment of a message too large for a single frame.
+// Clients base64-decode Data from every chunk sharing a MessageID, concatenate
+// them in Sequence order (0 to Total-1) and parse the result as one message.
+type ChunkMessage struct {
+	Message
+	MessageID string `json:"message_id"`
+	Sequence  int    `json:"seq"`
+	Total     int    `json:"total"`
+	Data      string `json:"data"` // base64
+}
+
+// CompressedMessage carries a message compressed with the connection's
+// negotiated encoding. Clients base64-decode Data, decompress it with
+// Encoding ("gzip" or "br") and parse the result as one message.
+type CompressedMessage struct {
+	Message
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"` // base64
+}
+
+// CloseMessage tells a client the server is about to close its connection.
+// Code says why, so the client can decide whether to reconnect.
+type CloseMessage struct {
+	Message
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// TokenExpiringMessage warns a client that its token is about to expire.
+// The connection is closed at ExpiresAt unless the client refreshes it first.
+type TokenExpiringMessage struct {
+	Message
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// Close codes sent with CloseMessage
+const (
+	CloseCodeIdleTimeout     = "IDLE_TIMEOUT"
+	CloseCodeTokenExpired    = "TOKEN_EXPIRED"
+	CloseCodeTokenRevoked    = "TOKEN_REVOKED"
+	CloseCodeTenantSuspended = "TENANT_SUSPENDED"
+	CloseCodeServerClosed    = "SERVER_CLOSED"
+)
+
 // ErrorInfo contains structured error information
 type ErrorInfo struct {
 	Code    string                 `json:"code"`
@@ -95,6 +159,7 @@ const (
 	ErrorCodeUnauthorized     = "UNAUTHORIZED"
 	ErrorCodeForbidden        = "FORBIDDEN"
 	ErrorCodeRateLimited      = "RATE_LIMITED"
+	ErrorCodeQuotaExceeded    = "QUOTA_EXCEEDED"
 	ErrorCodeDuplicateRequest = "DUPLICATE_REQUEST"
 
 	// Server errors (5xx equivalent)
@@ -173,6 +238,43 @@ func NewErrorMessage(requestID string, errorInfo *ErrorInfo) *ErrorMessage {
 	return msg
 }
 
+// NewChunkMessage creates a chunk carrying the given fragment
+func NewChunkMessage(messageID string, sequence, total int, fragment []byte) *ChunkMessage {
+	return &ChunkMessage{
+		Message:   Message{Type: MessageTypeChunk, Timestamp: time.Now().Unix()},
+		MessageID: messageID,
+		Sequence:  sequence,
+		Total:     total,
+		Data:      base64.StdEncoding.EncodeToString(fragment),
+	}
+}
+
+// NewCompressedMessage creates a message carrying a compressed payload
+func NewCompressedMessage(encoding string, compressed []byte) *CompressedMessage {
+	return &CompressedMessage{
+		Message:  Message{Type: MessageTypeCompressed, Timestamp: time.Now().Unix()},
+		Encoding: encoding,
+		Data:     base64.StdEncoding.EncodeToString(compressed),
+	}
+}
+
+// NewTokenExpiringMessage creates a warning that the token expires at expiresAt
+func NewTokenExpiringMessage(expiresAt time.Time) *TokenExpiringMessage {
+	return &TokenExpiringMessage{
+		Message:   Message{Type: MessageTypeTokenExpiring, Timestamp: time.Now().Unix()},
+		ExpiresAt: expiresAt.Unix(),
+	}
+}
+
+// NewCloseMessage creates a close notice with the given code and reason
+func NewCloseMessage(code, reason string) *CloseMessage {
+	return &CloseMessage{
+		Message: Message{Type: MessageTypeClose, Timestamp: time.Now().Unix()},
+		Code:    code,
+		Reason:  reason,
+	}
+}
+
 // NewErrorInfo creates a new error info structure
 func NewErrorInfo(code, message string) *ErrorInfo {
 	return &ErrorInfo{
@@ -206,7 +308,7 @@ func IsClientError(code string) bool {
 	switch code {
 	case ErrorCodeValidation, ErrorCodeInvalidAction, ErrorCodeNotFound,
 		ErrorCodeUnauthorized, ErrorCodeForbidden, ErrorCodeRateLimited,
-		ErrorCodeDuplicateRequest:
+		ErrorCodeQuotaExceeded, ErrorCodeDuplicateRequest:
 		return true
 	default:
 		return false
@@ -227,7 +329,7 @@ func IsServerError(code string) bool {
 // IsRetryableError checks if an error code indicates a retryable condition
 func IsRetryableError(code string) bool {
 	switch code {
-	case ErrorCodeTimeout, ErrorCodeServiceUnavailable, ErrorCodeRateLimited:
+	case ErrorCodeTimeout, ErrorCodeServiceUnavailable, ErrorCodeRateLimited, ErrorCodeQuotaExceeded:
 		return true
 	default:
 		return false
@@ -17,6 +17,10 @@ func TestMessageTypes(t *testing.T) {
 	assert.Equal(t, MessageType("error"), MessageTypeError)
 	assert.Equal(t, MessageType("ping"), MessageTypePing)
 	assert.Equal(t, MessageType("pong"), MessageTypePong)
+	assert.Equal(t, MessageType("close"), MessageTypeClose)
+	assert.Equal(t, MessageType("token_expiring"), MessageTypeTokenExpiring)
+	assert.Equal(t, MessageType("chunk"), MessageTypeChunk)
+	assert.Equal(t, MessageType("compressed"), MessageTypeCompressed)
 }
 
 func TestErrorCodes(t *testing.T) {
@@ -254,6 +258,44 @@ func TestNewProgressMessage(t *testing.T) {
 	}
 }
 
+func TestNewChunkMessage(t *testing.T) {
+	msg := NewChunkMessage("msg-1", 1, 3, []byte(`{"data":`))
+
+	assert.Equal(t, MessageTypeChunk, msg.Type)
+	assert.Equal(t, "msg-1", msg.MessageID)
+	assert.Equal(t, 1, msg.Sequence)
+	assert.Equal(t, 3, msg.Total)
+	assert.Equal(t, "eyJkYXRhIjo=", msg.Data)
+	assert.NotZero(t, msg.Timestamp)
+}
+
+func TestNewCompressedMessage(t *testing.T) {
+	msg := NewCompressedMessage("gzip", []byte{0x1f, 0x8b})
+
+	assert.Equal(t, MessageTypeCompressed, msg.Type)
+	assert.Equal(t, "gzip", msg.Encoding)
+	assert.Equal(t, "H4s=", msg.Data)
+	assert.NotZero(t, msg.Timestamp)
+}
+
+func TestNewCloseMessage(t *testing.T) {
+	msg := NewCloseMessage(CloseCodeIdleTimeout, "No activity for 10m0s")
+
+	assert.Equal(t, MessageTypeClose, msg.Type)
+	assert.Equal(t, "IDLE_TIMEOUT", msg.Code)
+	assert.Equal(t, "No activity for 10m0s", msg.Reason)
+	assert.NotZero(t, msg.Timestamp)
+}
+
+func TestNewTokenExpiringMessage(t *testing.T) {
+	expiresAt := time.Now().Add(5 * time.Minute)
+	msg := NewTokenExpiringMessage(expiresAt)
+
+	assert.Equal(t, MessageTypeTokenExpiring, msg.Type)
+	assert.Equal(t, expiresAt.Unix(), msg.ExpiresAt)
+	assert.NotZero(t, msg.Timestamp)
+}
+
 func TestNewErrorMessage(t *testing.T) {
 	tests := []struct {
 		name      string